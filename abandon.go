@@ -0,0 +1,110 @@
+package db_migrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// AbandonRegistrations переводит сохраненные, но не выполненные миграции (models.StateRegistered) в состояние
+// models.StateAbandoned. Такие миграции не учитываются при планировании и проверке CheckFulfillment, но остаются в
+// таблице migrations для аудита.
+//
+// Если olderThan больше нуля, обрабатываются только миграции, зарегистрированные раньше указанного срока. Если
+// переданы versions, обрабатываются только миграции с указанными версиями. Для миграций, код которых зарегистрирован в
+// текущем процессе, возвращается ошибка.
+//
+// Возвращает количество миграций, переведенных в состояние models.StateAbandoned.
+func (m *MigrationManager) AbandonRegistrations(serviceName string, olderThan time.Duration, versions ...string) (int, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return 0, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	versionsFilter := make(map[models.Version]struct{}, len(versions))
+	for _, version := range versions {
+		parsedVersion, err := models.ParseVersion(version)
+		if err != nil {
+			return 0, err
+		}
+		versionsFilter[parsedVersion] = struct{}{}
+	}
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return 0, err
+	}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return 0, nil
+	}
+
+	err = repository.CreateStateHistoryTable(service.Db)
+	if err != nil {
+		return 0, err
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return 0, err
+	}
+
+	threshold := time.Now().UTC().Add(-olderThan)
+
+	toAbandon := make([]models.MigrationModel, 0)
+	for _, migrationModel := range savedMigrations {
+		if migrationModel.State != models.StateRegistered {
+			continue
+		}
+		if olderThan > 0 && !migrationModel.RegisteredOn.Before(threshold) {
+			continue
+		}
+		if len(versionsFilter) > 0 {
+			if _, ok := versionsFilter[migrationModel.Version]; !ok {
+				continue
+			}
+		}
+
+		_, found, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return 0, fmt.Errorf(
+				"migration (type: %s, version: %s) is registered in current process and cannot be abandoned",
+				migrationModel.Type, migrationModel.Version,
+			)
+		}
+
+		toAbandon = append(toAbandon, migrationModel)
+	}
+
+	for i := range toAbandon {
+		err = repository.TransitionState(service.Db, &toAbandon[i], models.StateAbandoned, "abandoned")
+		if err != nil {
+			return i, err
+		}
+
+		m.logger.Info(
+			fmt.Sprintf(
+				"migration (type: %s, version: %s) abandoned, service: %s",
+				toAbandon[i].Type, toAbandon[i].Version, serviceName,
+			),
+		)
+	}
+
+	return len(toAbandon), nil
+}
@@ -0,0 +1,105 @@
+package db_migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// newAbandonTestManagers возвращает менеджер, сохранивший миграции 1.0.1 и 1.0.2 без выполнения, и менеджер той же
+// базы данных с целевой версией 1.0.2, в котором код этих миграций не зарегистрирован.
+func newAbandonTestManagers(t *testing.T) (experimental *MigrationManager, current *MigrationManager) {
+	t.Helper()
+
+	connect, disconnect := newTestDatabase(t)
+	baseline := Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"}
+
+	experimental, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, experimental.RegisterService("service1", connect, disconnect, "1.0.0"))
+	require.NoError(t, experimental.Register("service1",
+		baseline,
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+	require.NoError(t, experimental.Migrate("service1"))
+
+	current, err = NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, current.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, current.Register("service1", baseline))
+	return experimental, current
+}
+
+func TestAbandonRegistrations(t *testing.T) {
+	t.Run("all registered", func(t *testing.T) {
+		_, m := newAbandonTestManagers(t)
+
+		status, err := m.Status("service1")
+		require.NoError(t, err)
+		require.True(t, status.HasPending)
+		require.Len(t, status.Migrations, 3)
+		require.Empty(t, status.Abandoned)
+
+		abandoned, err := m.AbandonRegistrations("service1", 0)
+		require.NoError(t, err)
+		require.Equal(t, 2, abandoned)
+
+		status, err = m.Status("service1")
+		require.NoError(t, err)
+		require.False(t, status.HasPending)
+		require.Len(t, status.Migrations, 1)
+		require.Len(t, status.Abandoned, 2)
+		require.Equal(t, "1.0.1.0", status.Abandoned[0].Version)
+		require.Equal(t, "1.0.2.0", status.Abandoned[1].Version)
+
+		abandoned, err = m.AbandonRegistrations("service1", 0)
+		require.NoError(t, err)
+		require.Zero(t, abandoned)
+	})
+
+	t.Run("older than", func(t *testing.T) {
+		experimental, m := newAbandonTestManagers(t)
+
+		service, _ := experimental.service("service1")
+		db := service.ConnectFunc()
+		require.NoError(t, db.Table(repository.MigrationsTable(db)).Where("version = ?", "1.0.1.0").
+			Update("registered_on", time.Now().UTC().Add(-48*time.Hour)).Error)
+
+		abandoned, err := m.AbandonRegistrations("service1", 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, 1, abandoned)
+
+		status, err := m.Status("service1")
+		require.NoError(t, err)
+		require.Len(t, status.Abandoned, 1)
+		require.Equal(t, "1.0.1.0", status.Abandoned[0].Version)
+		require.True(t, status.HasPending)
+	})
+
+	t.Run("explicit versions", func(t *testing.T) {
+		_, m := newAbandonTestManagers(t)
+
+		abandoned, err := m.AbandonRegistrations("service1", 0, "1.0.2")
+		require.NoError(t, err)
+		require.Equal(t, 1, abandoned)
+
+		status, err := m.Status("service1")
+		require.NoError(t, err)
+		require.Len(t, status.Abandoned, 1)
+		require.Equal(t, "1.0.2.0", status.Abandoned[0].Version)
+	})
+
+	t.Run("registered code", func(t *testing.T) {
+		experimental, _ := newAbandonTestManagers(t)
+
+		_, err := experimental.AbandonRegistrations("service1", 0, "1.0.1")
+		require.ErrorContains(t, err, "registered in current process")
+
+		status, err := experimental.Status("service1")
+		require.NoError(t, err)
+		require.Empty(t, status.Abandoned)
+	})
+}
@@ -0,0 +1,78 @@
+package db_migrator
+
+import (
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+// adoptReturnedRepeatables возвращает в состояние StateRegistered сохраненные миграции типа TypeRepeatable, ранее
+// отмеченные StateNotFound, код которых снова зарегистрирован. Сохраненная контрольная сумма не изменяется, поэтому
+// миграция выполняется, только если ее контрольная сумма изменилась (см. repeatableNeedsRun).
+func (m *MigrationManager) adoptReturnedRepeatables(db *gorm.DB, serviceName string, savedMigrations []models.MigrationModel) error {
+	for i := range savedMigrations {
+		if savedMigrations[i].Type != string(TypeRepeatable) || savedMigrations[i].State != models.StateNotFound {
+			continue
+		}
+
+		_, ok, err := m.findMigration(serviceName, savedMigrations[i])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		err = repository.TransitionState(db, &savedMigrations[i], models.StateRegistered, "registered again")
+		if err != nil {
+			return err
+		}
+
+		m.logger.Info(
+			fmt.Sprintf(
+				"migration (type: %s, Version: %s) is registered again, state reset from %s to %s, service: %s",
+				savedMigrations[i].Type, savedMigrations[i].Version, models.StateNotFound, models.StateRegistered, serviceName,
+			),
+		)
+		m.audit(AuditEvent{
+			Event:         AuditMigrationAdopted,
+			Service:       serviceName,
+			MigrationType: savedMigrations[i].Type,
+			Version:       savedMigrations[i].Version.String(),
+		})
+	}
+
+	return nil
+}
+
+// returnedRepeatable определяет, является ли сохраненная миграция миграцией типа TypeRepeatable, код которой
+// отсутствовал в одном из запусков: она отмечена StateNotFound или возвращена в StateRegistered после выполнения
+// (сохранена контрольная сумма).
+func returnedRepeatable(migrationModel models.MigrationModel) bool {
+	if migrationModel.Type != string(TypeRepeatable) {
+		return false
+	}
+	return migrationModel.State == models.StateNotFound ||
+		migrationModel.State == models.StateRegistered && len(migrationModel.Checksum) > 0
+}
+
+// returnedRepeatablePending определяет, будет ли выполнена миграция, для которой returnedRepeatable возвращает true:
+// код миграции зарегистрирован и ее контрольная сумма изменилась. Миграция, код которой по-прежнему отсутствует, не
+// считается невыполненной.
+func (m *MigrationManager) returnedRepeatablePending(serviceName string, migrationModel models.MigrationModel) (bool, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return repeatableNeedsRun(migrationModel, migration, migration.checksum(service.Db)), nil
+}
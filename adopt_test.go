@@ -0,0 +1,120 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newAdoptTestManagers возвращает функцию, создающую менеджер с сервисом service1 на общей базе данных sqlite, как
+// экземпляр приложения очередной версии, и функцию подключения к этой базе данных.
+func newAdoptTestManagers(t *testing.T) (func(migrations ...Migration) *MigrationManager, func() *gorm.DB) {
+	t.Helper()
+
+	connect, disconnect := newTestDatabase(t)
+	newManager := func(migrations ...Migration) *MigrationManager {
+		m, err := NewMigrationsManager()
+		require.NoError(t, err)
+		require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.0"))
+		require.NoError(t, m.Register("service1", append([]Migration{
+			{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table log(v text)"},
+		}, migrations...)...))
+		return m
+	}
+	return newManager, connect
+}
+
+func adoptTestRepeatable(checksum string) Migration {
+	return Migration{
+		MigrationType:   TypeRepeatable,
+		Version:         "1.0.0",
+		IsTransactional: true,
+		CheckSum:        func(*gorm.DB) string { return checksum },
+		Up:              "insert into log values ('" + checksum + "')",
+	}
+}
+
+func repeatableState(t *testing.T, m *MigrationManager) string {
+	t.Helper()
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	for _, migration := range status.Migrations {
+		if migration.Type == TypeRepeatable {
+			return migration.State
+		}
+	}
+	require.FailNow(t, "repeatable migration is not saved")
+	return ""
+}
+
+func requireFulfilled(t *testing.T, m *MigrationManager, fulfilled bool) {
+	t.Helper()
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.Equal(t, fulfilled, ok, "%v", reason)
+}
+
+func TestAdoptReturnedRepeatable(t *testing.T) {
+	newManager, connect := newAdoptTestManagers(t)
+	db := connect()
+	executions := func() []string {
+		var values []string
+		require.NoError(t, db.Raw("select v from log").Scan(&values).Error)
+		return values
+	}
+
+	m := newManager(adoptTestRepeatable("v1"))
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "success", repeatableState(t, m))
+	require.Equal(t, []string{"v1"}, executions())
+
+	// код миграции удален
+	m = newManager()
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "not found", repeatableState(t, m))
+	requireFulfilled(t, m, true)
+
+	// код возвращен без изменений: миграция снова зарегистрирована, но не выполняется
+	m = newManager(adoptTestRepeatable("v1"))
+	requireFulfilled(t, m, true)
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "registered", repeatableState(t, m))
+	require.Equal(t, []string{"v1"}, executions())
+	requireFulfilled(t, m, true)
+
+	var reasons []string
+	require.NoError(t, db.Raw(
+		"select reason from migration_state_history where type = ? and from_state = ? and to_state = ?",
+		"repeatable", "not found", "registered",
+	).Scan(&reasons).Error)
+	require.Equal(t, []string{"registered again"}, reasons)
+
+	// код изменен: миграция ожидает выполнения
+	m = newManager(adoptTestRepeatable("v2"))
+	requireFulfilled(t, m, false)
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "success", repeatableState(t, m))
+	require.Equal(t, []string{"v1", "v2"}, executions())
+	requireFulfilled(t, m, true)
+}
+
+func TestAdoptReturnedRepeatableChanged(t *testing.T) {
+	newManager, connect := newAdoptTestManagers(t)
+
+	require.NoError(t, newManager(adoptTestRepeatable("v1")).Migrate("service1"))
+	require.NoError(t, newManager().Migrate("service1"))
+
+	m := newManager(adoptTestRepeatable("v2"))
+	require.Equal(t, "not found", repeatableState(t, m))
+	requireFulfilled(t, m, false)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "success", repeatableState(t, m))
+
+	var values []string
+	require.NoError(t, connect().Raw("select v from log").Scan(&values).Error)
+	require.Equal(t, []string{"v1", "v2"}, values)
+}
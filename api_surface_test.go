@@ -0,0 +1,180 @@
+package db_migrator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// apiSurface - набор методов менеджера одного поколения API. Сценарии совместимости выполняются через каждый набор,
+// чтобы устаревшие методы и методы с контекстом давали одинаковый результат.
+type apiSurface struct {
+	addService       func(m *MigrationManager, name string, connect func() *gorm.DB, disconnect func(db *gorm.DB), targetVersion string) error
+	migrate          func(m *MigrationManager, serviceName string, opts RunOptions) error
+	downgrade        func(m *MigrationManager, serviceName string, opts RunOptions) error
+	checkFulfillment func(m *MigrationManager, serviceName string) (reasonErr error, ok bool, err error)
+}
+
+var apiSurfaces = map[string]apiSurface{
+	"deprecated": {
+		addService: func(m *MigrationManager, name string, connect func() *gorm.DB, disconnect func(db *gorm.DB), targetVersion string) error {
+			return m.RegisterService(name, connect, disconnect, targetVersion)
+		},
+		migrate: func(m *MigrationManager, serviceName string, opts RunOptions) error {
+			if reflect.ValueOf(opts).IsZero() {
+				return m.Migrate(serviceName)
+			}
+			return m.MigrateWithOptions(serviceName, opts)
+		},
+		downgrade: func(m *MigrationManager, serviceName string, opts RunOptions) error {
+			if reflect.ValueOf(opts).IsZero() {
+				return m.Downgrade(serviceName)
+			}
+			return m.DowngradeWithOptions(serviceName, opts)
+		},
+		checkFulfillment: func(m *MigrationManager, serviceName string) (error, bool, error) {
+			return m.CheckFulfillment(serviceName)
+		},
+	},
+	"context": {
+		addService: func(m *MigrationManager, name string, connect func() *gorm.DB, disconnect func(db *gorm.DB), targetVersion string) error {
+			return m.AddService(name, ServiceConfig{Connect: connect, Disconnect: disconnect, TargetVersion: targetVersion})
+		},
+		migrate: func(m *MigrationManager, serviceName string, opts RunOptions) error {
+			return m.MigrateContext(context.Background(), serviceName, opts)
+		},
+		downgrade: func(m *MigrationManager, serviceName string, opts RunOptions) error {
+			return m.DowngradeContext(context.Background(), serviceName, opts)
+		},
+		checkFulfillment: func(m *MigrationManager, serviceName string) (error, bool, error) {
+			result, err := m.CheckFulfillmentContext(context.Background(), serviceName)
+			return result.Reason, result.Ok, err
+		},
+	},
+}
+
+// newSurfaceManager создает менеджер с сервисом service1, добавленным через api, и миграциями
+// registerRunDirectionMigrations.
+func newSurfaceManager(t *testing.T, api apiSurface, targetVersion string) (*MigrationManager, func() *gorm.DB) {
+	t.Helper()
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, api.addService(m, "service1", connect, disconnect, targetVersion))
+	registerRunDirectionMigrations(t, m)
+	return m, connect
+}
+
+func savedVersion(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+
+	version, err := repository.GetVersion(db)
+	require.NoError(t, err)
+	return version.String()
+}
+
+func TestAPISurfaceCompatibility(t *testing.T) {
+	scenarios := map[string]func(t *testing.T, api apiSurface){
+		"migrate and check fulfillment": func(t *testing.T, api apiSurface) {
+			m, connect := newSurfaceManager(t, api, "1.0.2")
+
+			reason, ok, err := api.checkFulfillment(m, "service1")
+			require.NoError(t, err)
+			require.False(t, ok)
+			require.ErrorIs(t, reason, ErrHasForthcomingMigrations)
+
+			require.NoError(t, api.migrate(m, "service1", RunOptions{}))
+			require.Equal(t, "1.0.2.0", savedVersion(t, connect()))
+			require.True(t, connect().Migrator().HasColumn("a", "c"))
+
+			reason, ok, err = api.checkFulfillment(m, "service1")
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.NoError(t, reason)
+		},
+		"migrate to run target version": func(t *testing.T, api apiSurface) {
+			m, connect := newSurfaceManager(t, api, "1.0.2")
+
+			require.NoError(t, api.migrate(m, "service1", RunOptions{TargetVersion: "1.0.1"}))
+			require.Equal(t, "1.0.1.0", savedVersion(t, connect()))
+			require.False(t, connect().Migrator().HasColumn("a", "c"))
+
+			require.NoError(t, api.migrate(m, "service1", RunOptions{}))
+			require.Equal(t, "1.0.2.0", savedVersion(t, connect()))
+		},
+		"downgrade": func(t *testing.T, api apiSurface) {
+			m, connect := newSurfaceManager(t, api, "1.0.2")
+			require.NoError(t, api.migrate(m, "service1", RunOptions{}))
+
+			require.NoError(t, api.downgrade(m, "service1", RunOptions{Steps: 1}))
+			require.Equal(t, "1.0.1.0", savedVersion(t, connect()))
+			require.False(t, connect().Migrator().HasColumn("a", "c"))
+
+			require.NoError(t, api.downgrade(m, "service1", RunOptions{TargetVersion: "1.0.0"}))
+			require.Equal(t, "1.0.0.0", savedVersion(t, connect()))
+			require.False(t, connect().Migrator().HasColumn("a", "b"))
+		},
+		"downgrade to service target version": func(t *testing.T, api apiSurface) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			connect, disconnect := newTestDatabase(t)
+			require.NoError(t, api.addService(m, "service1", connect, disconnect, "1.0.2"))
+			registerRunDirectionMigrations(t, m)
+			require.NoError(t, api.migrate(m, "service1", RunOptions{}))
+
+			// повторное добавление сервиса изменяет целевую версию
+			require.NoError(t, api.addService(m, "service1", connect, disconnect, "1.0.1"))
+			require.NoError(t, api.downgrade(m, "service1", RunOptions{}))
+			require.Equal(t, "1.0.1.0", savedVersion(t, connect()))
+		},
+		"failed migration": func(t *testing.T, api apiSurface) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			connect, disconnect := newTestDatabase(t)
+			require.NoError(t, api.addService(m, "service1", connect, disconnect, "1.0.1"))
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+			))
+
+			err = api.migrate(m, "service1", RunOptions{})
+			var execErr *MigrationExecError
+			require.ErrorAs(t, err, &execErr)
+			require.Equal(t, "1.0.1", execErr.Version)
+
+			reason, ok, err := api.checkFulfillment(m, "service1")
+			require.NoError(t, err)
+			require.False(t, ok)
+			require.Error(t, reason)
+		},
+		"unknown service": func(t *testing.T, api apiSurface) {
+			m, _ := newSurfaceManager(t, api, "1.0.2")
+
+			require.ErrorIs(t, api.migrate(m, "unknown", RunOptions{}), ErrServiceNotFound)
+			require.ErrorIs(t, api.downgrade(m, "unknown", RunOptions{}), ErrServiceNotFound)
+			_, ok, err := api.checkFulfillment(m, "unknown")
+			require.ErrorIs(t, err, ErrServiceNotFound)
+			require.False(t, ok)
+		},
+		"invalid target version": func(t *testing.T, api apiSurface) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			connect, disconnect := newTestDatabase(t)
+			require.Error(t, api.addService(m, "service1", connect, disconnect, "1.x"))
+		},
+	}
+
+	for name, scenario := range scenarios {
+		for apiName, api := range apiSurfaces {
+			t.Run(name+"/"+apiName, func(t *testing.T) {
+				scenario(t, api)
+			})
+		}
+	}
+}
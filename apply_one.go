@@ -0,0 +1,150 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// ApplyOne выполняет одну ожидающую выполнения миграцию, не затрагивая остальные.
+//
+// Миграция должна быть зарегистрирована и не выполнена. Для миграций типа TypeVersioned требуется, чтобы все миграции
+// типа TypeVersioned с меньшей версией были выполнены. При force = true миграция выполняется вне очереди: сохраненная
+// версия базы данных в этом случае не изменяется, а пропущенные миграции будут выполнены следующим вызовом Migrate.
+// Миграции типов TypeBaseline и TypeRepeatable выполняются только при force = true.
+func (m *MigrationManager) ApplyOne(serviceName string, version string, mtype MigrationType, force bool) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	parsedVersion, err := models.ParseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if mtype != TypeVersioned && !force {
+		return fmt.Errorf("applying single %s migration requires force, version: %s", mtype, version)
+	}
+
+	service.Db = service.open()
+	defer func() {
+		m.closeAuxiliaryConnections(serviceName)
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = m.initSystemTables(serviceName)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.saveNewMigrations(serviceName)
+	if err != nil {
+		return err
+	}
+
+	var migrationModel models.MigrationModel
+	var found bool
+	for i := range savedMigrations {
+		if savedMigrations[i].Type == string(mtype) && savedMigrations[i].Version.Equals(parsedVersion) {
+			migrationModel = savedMigrations[i]
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return m.migrationNotFound(serviceName, string(mtype), parsedVersion)
+	}
+
+	// saveNewMigrations возвращает только ключевые поля, выполнению нужна полная строка миграции
+	migrationRows, err := repository.GetMigrationsByIds(service.Db, []uint32{migrationModel.Id})
+	if err != nil {
+		return err
+	}
+	if len(migrationRows) == 0 {
+		return m.migrationNotFound(serviceName, string(mtype), parsedVersion)
+	}
+	migrationModel = migrationRows[0]
+
+	if migrationModel.State == models.StateSuccess && mtype != TypeRepeatable {
+		return fmt.Errorf("migration (type: %s, Version: %s) is already applied", mtype, version)
+	}
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return m.migrationNotFound(serviceName, string(mtype), parsedVersion)
+	}
+
+	outOfOrder := false
+	if mtype == TypeVersioned {
+		savedVersion, _ := m.getSavedAppVersion(serviceName)
+
+		for i := range savedMigrations {
+			if savedMigrations[i].Type != string(TypeVersioned) || !savedMigrations[i].Version.LessThan(parsedVersion) {
+				continue
+			}
+			if savedMigrations[i].Version.LessOrEqual(savedVersion) || savedMigrations[i].State == models.StateSuccess {
+				continue
+			}
+			if savedMigrations[i].State == models.StateSkipped || savedMigrations[i].State == models.StateAbandoned {
+				continue
+			}
+
+			if !force {
+				return fmt.Errorf(
+					"migration (type: %s, Version: %s) must be applied before %s",
+					savedMigrations[i].Type, savedMigrations[i].Version, version,
+				)
+			}
+			outOfOrder = true
+		}
+	}
+
+	if outOfOrder || mtype != TypeVersioned {
+		impact := fmt.Sprintf("%s migration %s will be applied out of order", mtype, version)
+		if !migration.hasDown() {
+			impact += ", it cannot be downgraded"
+		}
+		err = m.confirmMigration(OperationForceApply, serviceName, migrationModel, impact)
+		if err != nil {
+			return err
+		}
+	}
+
+	rowsAffected, err := m.executeMigration(serviceName, service.Db, migrationModel, migration, false)
+	if errors.Is(err, ErrAbortedByHook) {
+		return err
+	}
+	if err != nil && !migration.IsAllowFailure {
+		return errors.Join(err, repository.TransitionState(service.Db, &migrationModel, models.StateFailure, "apply one failed"))
+	}
+
+	err = repository.UpdateMigrationRowsAffected(service.Db, &migrationModel, rowsAffected)
+	if err != nil {
+		return err
+	}
+
+	if outOfOrder {
+		m.logger.Warn(fmt.Sprintf("migration (type: %s, Version: %s) applied out of order, version is not changed", mtype, version))
+		return transitionExecuted(service.Db, &migrationModel, models.StateSuccess, "applied out of order", migration.checksum(service.Db), m.executedBy(service))
+	}
+
+	return m.saveStateOnSuccessfulMigration(serviceName, savedMigrations, migrationModel, migration)
+}
@@ -0,0 +1,105 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditRunStarted         AuditEventType = "run_started"
+	AuditRunFinished        AuditEventType = "run_finished"
+	AuditMigrationStarted   AuditEventType = "migration_started"
+	AuditMigrationSucceeded AuditEventType = "migration_succeeded"
+	AuditMigrationFailed    AuditEventType = "migration_failed"
+	AuditMigrationUndone    AuditEventType = "migration_undone"
+	AuditMigrationRepaired  AuditEventType = "migration_repaired"
+	// AuditMigrationAdopted - миграция типа TypeRepeatable, ранее отмеченная StateNotFound, снова зарегистрирована
+	AuditMigrationAdopted AuditEventType = "migration_adopted"
+)
+
+// AuditEvent - запись журнала аудита, сохраняемая одной строкой JSON.
+type AuditEvent struct {
+	Time          time.Time         `json:"time"`
+	Event         AuditEventType    `json:"event"`
+	Service       string            `json:"service"`
+	Direction     string            `json:"direction,omitempty"`
+	MigrationType string            `json:"migration_type,omitempty"`
+	Version       string            `json:"version,omitempty"`
+	Checksum      string            `json:"checksum,omitempty"`
+	AppliedBy     string            `json:"applied_by,omitempty"`
+	DurationMs    int64             `json:"duration_ms,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// LibraryVersion - версия библиотеки, выполнившей операцию.
+	LibraryVersion string `json:"library_version,omitempty"`
+}
+
+// audit записывает событие в журнал аудита, заданный опцией WithAuditWriter. Ошибки записи выводятся в лог и не
+// прерывают выполнение миграций.
+func (m *MigrationManager) audit(event AuditEvent) {
+	if m.auditWriter == nil {
+		return
+	}
+
+	event.Time = time.Now().UTC()
+	event.LibraryVersion = Version
+	if len(event.AppliedBy) == 0 {
+		event.AppliedBy = m.appliedBy()
+	}
+	if service, ok := m.service(event.Service); ok && event.Labels == nil {
+		event.Labels = service.runLabels
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to marshal audit event: %s", err))
+		return
+	}
+
+	m.auditMutex.Lock()
+	defer m.auditMutex.Unlock()
+
+	if _, err = m.auditWriter.Write(append(line, '\n')); err != nil {
+		m.logger.Error(fmt.Sprintf("fail to write audit event: %s", err))
+		return
+	}
+
+	switch w := m.auditWriter.(type) {
+	case interface{ Sync() error }:
+		err = w.Sync()
+	case interface{ Flush() error }:
+		err = w.Flush()
+	}
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to flush audit event: %s", err))
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// appliedBy возвращает идентификатор процесса, выполняющего миграции: значение WithIdentity или user@host.
+func (m *MigrationManager) appliedBy() string {
+	if len(m.identity) > 0 {
+		return m.identity
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	user := os.Getenv("USER")
+	if len(user) == 0 {
+		return host
+	}
+	return user + "@" + host
+}
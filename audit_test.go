@@ -0,0 +1,135 @@
+package db_migrator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer - буфер журнала аудита, подсчитывающий вызовы Sync.
+type syncBuffer struct {
+	bytes.Buffer
+	syncs int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.syncs++
+	return nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// decodeAuditEvents разбирает строки журнала аудита и проверяет, что события упорядочены по времени.
+func decodeAuditEvents(t *testing.T, data []byte) []AuditEvent {
+	t.Helper()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		if len(events) > 0 {
+			require.False(t, event.Time.Before(events[len(events)-1].Time))
+		}
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func auditEventTypes(events []AuditEvent) []AuditEventType {
+	types := make([]AuditEventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Event)
+	}
+	return types
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buffer syncBuffer
+	m, _ := newTestManager(t, "1.0.2", WithAuditWriter(&buffer), WithIdentity("deployer"))
+	registerRunDirectionMigrations(t, m)
+
+	require.NoError(t, m.Migrate("service1"))
+
+	events := decodeAuditEvents(t, buffer.Bytes())
+	require.Equal(t, []AuditEventType{
+		AuditRunStarted,
+		AuditMigrationStarted, AuditMigrationSucceeded,
+		AuditMigrationStarted, AuditMigrationSucceeded,
+		AuditMigrationStarted, AuditMigrationSucceeded,
+		AuditRunFinished,
+	}, auditEventTypes(events))
+	// каждая строка сбрасывается сразу после записи
+	require.Equal(t, len(events), buffer.syncs)
+
+	var versions []string
+	for _, event := range events {
+		require.Equal(t, "service1", event.Service)
+		require.Equal(t, DirectionUp, event.Direction)
+		require.Equal(t, "deployer", event.AppliedBy)
+		require.Equal(t, Version, event.LibraryVersion)
+		require.False(t, event.Time.IsZero())
+		require.Empty(t, event.Error)
+
+		if event.Event == AuditMigrationSucceeded {
+			require.NotEmpty(t, event.Checksum)
+			versions = append(versions, event.Version)
+		}
+	}
+	require.Equal(t, []string{"1.0.0.0", "1.0.1.0", "1.0.2.0"}, versions)
+
+	t.Run("downgrade", func(t *testing.T) {
+		buffer.Reset()
+		require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+
+		events := decodeAuditEvents(t, buffer.Bytes())
+		require.Contains(t, auditEventTypes(events), AuditMigrationUndone)
+		require.Equal(t, AuditRunStarted, events[0].Event)
+		require.Equal(t, AuditRunFinished, events[len(events)-1].Event)
+		for _, event := range events {
+			require.Equal(t, DirectionDown, event.Direction)
+			if event.Event == AuditMigrationUndone {
+				require.Equal(t, "1.0.2.0", event.Version)
+			}
+		}
+	})
+}
+
+func TestAuditWriterFailedMigration(t *testing.T) {
+	var buffer bytes.Buffer
+	m, _ := newTestManager(t, "1.0.1", WithAuditWriter(&buffer))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+	))
+
+	require.Error(t, m.Migrate("service1"))
+
+	events := decodeAuditEvents(t, buffer.Bytes())
+	require.Equal(t, []AuditEventType{
+		AuditRunStarted,
+		AuditMigrationStarted, AuditMigrationSucceeded,
+		AuditMigrationStarted, AuditMigrationFailed,
+		AuditRunFinished,
+	}, auditEventTypes(events))
+	require.Equal(t, "1.0.1.0", events[4].Version)
+	require.Contains(t, events[4].Error, "missing")
+	require.NotEmpty(t, events[5].Error)
+}
+
+func TestAuditWriterErrorDoesNotFailMigration(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2", WithAuditWriter(failingWriter{}))
+	registerRunDirectionMigrations(t, m)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, connect().Migrator().HasColumn("a", "c"))
+}
@@ -0,0 +1,81 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// AutoMigrateConfig описывает автоматическое выполнение Migrate при регистрации новых миграций после того, как для
+// сервиса уже был успешно выполнен Migrate в текущем процессе. Результаты выполнения миграций передаются
+// обработчикам WithAfterMigration и WithOnError, ошибка запуска выводится в лог.
+type AutoMigrateConfig struct {
+	// Services - сервисы, для которых включено автоматическое выполнение.
+	Services []string
+	// Async - выполнять Migrate асинхронно. Иначе Migrate выполняется внутри вызова Register.
+	Async bool
+}
+
+// autoMigrate выполняет Migrate для сервиса, если это включено опцией WithAutoMigrateOnRegister и для сервиса уже
+// был выполнен Migrate. Если Migrate уже выполняется, регистрация отмечается, и после его завершения Migrate
+// выполняется повторно, пока во время выполнения регистрируются новые миграции.
+func (m *MigrationManager) autoMigrate(serviceName string) {
+	if !slices.Contains(m.autoMigrateConfig.Services, serviceName) {
+		return
+	}
+
+	service, ok := m.service(serviceName)
+	if !ok {
+		return
+	}
+
+	service.mutex.Lock()
+	migrated := service.migrated
+	service.mutex.Unlock()
+
+	if !migrated {
+		return
+	}
+
+	m.autoMigrateMutex.Lock()
+	if m.autoMigrating == nil {
+		m.autoMigrating = make(map[string]bool)
+	}
+	if _, running := m.autoMigrating[serviceName]; running {
+		// выполняемый Migrate мог уже прочитать зарегистрированные миграции
+		m.autoMigrating[serviceName] = true
+		m.autoMigrateMutex.Unlock()
+		return
+	}
+	m.autoMigrating[serviceName] = false
+	m.autoMigrateMutex.Unlock()
+
+	run := func() {
+		m.logger.Info(fmt.Sprintf("new migrations registered after migrate, migrating service: %s", serviceName))
+
+		for {
+			err := m.MigrateContext(context.Background(), serviceName, RunOptions{})
+			if err != nil {
+				m.logger.Error(fmt.Sprintf("auto migrate fail, service: %s, err: %s", serviceName, err))
+			}
+
+			m.autoMigrateMutex.Lock()
+			if !m.autoMigrating[serviceName] {
+				delete(m.autoMigrating, serviceName)
+				m.autoMigrateMutex.Unlock()
+				return
+			}
+			m.autoMigrating[serviceName] = false
+			m.autoMigrateMutex.Unlock()
+
+			m.logger.Info(fmt.Sprintf("new migrations registered during auto migrate, migrating service: %s", serviceName))
+		}
+	}
+
+	if m.autoMigrateConfig.Async {
+		go run()
+		return
+	}
+
+	run()
+}
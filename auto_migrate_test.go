@@ -0,0 +1,92 @@
+package db_migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func autoMigrateTestMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table b(id int)"},
+	}
+}
+
+// TestAutoMigrateOnRegister проверяет, что миграция, зарегистрированная подключаемым модулем после Migrate, выполняется
+// внутри вызова Register.
+func TestAutoMigrateOnRegister(t *testing.T) {
+	m, connect := newTestManager(t, TargetLatest, WithAutoMigrateOnRegister(AutoMigrateConfig{Services: []string{"service1"}}))
+	require.NoError(t, m.Register("service1", autoMigrateTestMigrations()...))
+
+	// до первого Migrate регистрация не выполняет миграции
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "create table c(id int)"},
+	))
+	require.False(t, connect().Migrator().HasTable("a"))
+
+	require.NoError(t, m.Migrate("service1"))
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "create table plugin(id int)"},
+	))
+	require.True(t, connect().Migrator().HasTable("plugin"))
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+}
+
+// TestAutoMigrateRegisteredDuringRun проверяет, что миграция, зарегистрированная во время асинхронного Migrate,
+// выполняется повторным запуском, а результаты передаются обработчику WithAfterMigration.
+func TestAutoMigrateRegisteredDuringRun(t *testing.T) {
+	var m *MigrationManager
+	applied := make(chan string, 8)
+	registered := make(chan error, 1)
+
+	m, _ = newTestManager(t, TargetLatest,
+		WithAutoMigrateOnRegister(AutoMigrateConfig{Services: []string{"service1"}, Async: true}),
+		WithAfterMigration(func(service string, info MigrationInfo, duration time.Duration) {
+			if info.Version == "1.0.2" {
+				// второй модуль регистрирует миграцию, пока выполняется автоматический Migrate
+				go func() {
+					registered <- m.Register("service1",
+						Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "create table d(id int)"},
+					)
+				}()
+			}
+			applied <- info.Version
+		}),
+	)
+	require.NoError(t, m.Register("service1", autoMigrateTestMigrations()...))
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, "1.0.0", <-applied)
+	require.Equal(t, "1.0.1", <-applied)
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "create table c(id int)"},
+	))
+
+	for _, version := range []string{"1.0.2", "1.0.3"} {
+		select {
+		case got := <-applied:
+			require.Equal(t, version, got)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("migration %s was not applied", version)
+		}
+	}
+	require.NoError(t, <-registered)
+
+	// автоматический Migrate завершается, когда во время выполнения больше не регистрируются миграции
+	require.Eventually(t, func() bool {
+		m.autoMigrateMutex.Lock()
+		defer m.autoMigrateMutex.Unlock()
+		_, running := m.autoMigrating["service1"]
+		return !running
+	}, 10*time.Second, 10*time.Millisecond)
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+}
@@ -0,0 +1,67 @@
+package db_migrator
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// AuxiliaryConnection описывает дополнительное подключение сервиса, не являющееся сервисом миграций (например, база
+// данных, из которой переносятся данные). Для него не требуются таблицы version и migrations.
+type AuxiliaryConnection struct {
+	ConnectFunc    func() *gorm.DB
+	DisconnectFunc func(db *gorm.DB)
+}
+
+// auxiliaryConnections открывает подключения, объявленные в Migration.UsesAuxiliary. Подключения создаются один раз
+// за выполнение операции и закрываются вызовом closeAuxiliaryConnections.
+func (m *MigrationManager) auxiliaryConnections(serviceName string, migration *Migration) (map[string]*gorm.DB, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	connections := make(map[string]*gorm.DB, len(migration.UsesAuxiliary))
+	for _, name := range migration.UsesAuxiliary {
+		auxiliary, ok := service.AuxiliaryConnections[name]
+		if !ok || auxiliary.ConnectFunc == nil {
+			m.logger.Error(fmt.Sprintf("migration fail, auxiliary connection %s is not registered, service: %s", name, serviceName))
+			return nil, fmt.Errorf("auxiliary connection %s is not registered", name)
+		}
+
+		db, connected := service.auxiliaryDb[name]
+		if !connected {
+			db = auxiliary.ConnectFunc()
+			if service.auxiliaryDb == nil {
+				service.auxiliaryDb = make(map[string]*gorm.DB)
+			}
+			service.auxiliaryDb[name] = db
+		}
+
+		connections[name] = db
+	}
+
+	return connections, nil
+}
+
+// closeAuxiliaryConnections закрывает дополнительные подключения, открытые в рамках выполнения операции.
+func (m *MigrationManager) closeAuxiliaryConnections(serviceName string) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return
+	}
+
+	for name, db := range service.auxiliaryDb {
+		auxiliary := service.AuxiliaryConnections[name]
+		if auxiliary.DisconnectFunc != nil {
+			auxiliary.DisconnectFunc(db)
+		} else if sqlDb, err := db.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+	}
+
+	service.auxiliaryDb = nil
+}
@@ -0,0 +1,32 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLateBaselineKeepsAppliedMigrations(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	versioned := Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table a(id int)"}
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, previous.Register("service1", versioned))
+	require.NoError(t, previous.Migrate("service1"))
+
+	// baseline добавлен после того, как база данных уже обновлена миграциями типа versioned
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, m.Register("service1",
+		versioned,
+		Migration{MigrationType: TypeBaseline, Version: "1.0.2", IsTransactional: true, Up: "create table if not exists a(id int)"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	var states []string
+	require.NoError(t, connect().Raw("select state from migrations order by rank").Scan(&states).Error)
+	require.Equal(t, []string{"success", "success"}, states)
+}
@@ -0,0 +1,71 @@
+package db_migrator
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRegistrationBatchSize(t *testing.T) {
+	tests := []struct {
+		batchSize int
+		inserts   int64
+	}{
+		{batchSize: 2, inserts: 3},
+		{batchSize: 1, inserts: 5},
+		{batchSize: 100, inserts: 1},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("batch size %d", test.batchSize), func(t *testing.T) {
+			connect, disconnect := newTestDatabase(t)
+
+			// количество запросов INSERT в таблицу migrations
+			var inserts atomic.Int64
+			countingConnect := func() *gorm.DB {
+				db := connect()
+				require.NoError(t, db.Callback().Create().After("gorm:create").Register("test:count_inserts", func(tx *gorm.DB) {
+					if tx.Statement.Table == repository.MigrationsTable(tx) {
+						inserts.Add(1)
+					}
+				}))
+				return db
+			}
+
+			m, err := NewMigrationsManager(WithRegistrationBatchSize(test.batchSize))
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", countingConnect, disconnect, "1.0.4"))
+
+			migrations := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			}
+			for i := 1; i <= 4; i++ {
+				migrations = append(migrations, Migration{
+					MigrationType:   TypeVersioned,
+					Version:         fmt.Sprintf("1.0.%d", i),
+					IsTransactional: true,
+					Up:              fmt.Sprintf("alter table a add column c%d text", i),
+				})
+			}
+			require.NoError(t, m.Register("service1", migrations...))
+			require.NoError(t, m.Migrate("service1"))
+			require.Equal(t, test.inserts, inserts.Load())
+
+			savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+			require.NoError(t, err)
+			require.Len(t, savedMigrations, 5)
+
+			ids := make(map[int]struct{}, len(savedMigrations))
+			for i, migrationModel := range savedMigrations {
+				require.Equal(t, models.StateSuccess, migrationModel.State, migrationModel.Version.String())
+				require.Equal(t, i+1, migrationModel.Rank)
+				ids[int(migrationModel.Id)] = struct{}{}
+			}
+			require.Len(t, ids, 5, "every saved migration must have own id")
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const blueGreenConnectionsPollInterval = time.Second
+
+// BlueGreenSpec задает параметры BlueGreenMigrate.
+type BlueGreenSpec struct {
+	ServiceName string
+	// Maintenance - соединение с служебной базой данных того же сервера postgres (например, postgres), через которое
+	// создается и удаляется копия. Соединение не закрывается.
+	Maintenance *gorm.DB
+	// SourceDatabase - имя копируемой базы данных сервиса.
+	SourceDatabase string
+	// CloneDatabase - имя создаваемой копии. Если не задано, используется SourceDatabase с суффиксом времени запуска.
+	CloneDatabase string
+	// ConnectClone открывает соединение с базой данных database. Используется вместо ConnectFunc сервиса.
+	ConnectClone func(database string) *gorm.DB
+	// DisconnectClone закрывает соединение, открытое ConnectClone. Если не задан, закрывается *sql.DB.
+	DisconnectClone func(db *gorm.DB)
+	// ConnectionsWait - время ожидания закрытия активных соединений с SourceDatabase, необходимого для копирования.
+	// Значение 0 означает однократную проверку.
+	ConnectionsWait time.Duration
+	// TerminateConnections - по истечении ConnectionsWait завершить оставшиеся соединения с SourceDatabase вместо
+	// возврата ошибки.
+	TerminateConnections bool
+	// Verify - проверка копии после выполнения миграций. Ошибка приводит к удалению копии.
+	Verify func(ctx context.Context, db *gorm.DB) error
+	// Run - параметры запуска Migrate.
+	Run RunOptions
+}
+
+// BlueGreenMigrate выполняет миграции сервиса на копии базы данных (только postgres): создает копию SourceDatabase
+// через CREATE DATABASE ... TEMPLATE, выполняет Migrate на копии, вызывает Verify и возвращает имя копии, на которую
+// вызывающая сторона переключает приложение. Исходная база данных не изменяется.
+//
+// Для копирования к SourceDatabase не должно быть активных соединений (см. ConnectionsWait и TerminateConnections).
+// При ошибке на любом шаге после создания копия удаляется.
+func (m *MigrationManager) BlueGreenMigrate(ctx context.Context, spec BlueGreenSpec) (database string, err error) {
+	service, ok := m.service(spec.ServiceName)
+
+	if !ok {
+		return "", m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, spec.ServiceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	switch {
+	case spec.Maintenance == nil:
+		return "", m.misuse(errors.New("blue-green migrate requires maintenance connection"))
+	case spec.Maintenance.Dialector.Name() != "postgres":
+		return "", m.misuse(fmt.Errorf("blue-green migrate is not supported by %s dialect", spec.Maintenance.Dialector.Name()))
+	case len(spec.SourceDatabase) == 0:
+		return "", m.misuse(errors.New("blue-green migrate requires source database"))
+	case spec.ConnectClone == nil:
+		return "", m.misuse(errors.New("blue-green migrate requires ConnectClone"))
+	}
+
+	database = spec.CloneDatabase
+	if len(database) == 0 {
+		database = fmt.Sprintf("%s_%s", spec.SourceDatabase, time.Now().UTC().Format("20060102150405"))
+	}
+
+	disconnectClone := spec.DisconnectClone
+	if disconnectClone == nil {
+		disconnectClone = func(db *gorm.DB) {
+			if sqlDb, err := db.DB(); err == nil {
+				_ = sqlDb.Close()
+			}
+		}
+	}
+
+	err = m.waitSourceConnections(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+
+	m.logger.Info(fmt.Sprintf("creating database %s from template %s, service: %s", database, spec.SourceDatabase, spec.ServiceName))
+	err = spec.Maintenance.WithContext(ctx).Exec(fmt.Sprintf(
+		"CREATE DATABASE %s TEMPLATE %s", quotePostgresIdentifier(database), quotePostgresIdentifier(spec.SourceDatabase),
+	)).Error
+	if err != nil {
+		return "", fmt.Errorf("fail to create database %s: %w", database, err)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		m.logger.Warn(fmt.Sprintf("dropping database %s after failure, service: %s", database, spec.ServiceName))
+		dropErr := spec.Maintenance.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", quotePostgresIdentifier(database))).Error
+		if dropErr != nil {
+			err = errors.Join(err, fmt.Errorf("fail to drop database %s: %w", database, dropErr))
+		}
+		database = ""
+	}()
+
+	err = m.migrateClone(ctx, service, spec, database, disconnectClone)
+	if err != nil {
+		return database, err
+	}
+
+	if spec.Verify != nil {
+		db := spec.ConnectClone(database)
+		err = spec.Verify(ctx, db)
+		disconnectClone(db)
+		if err != nil {
+			return database, fmt.Errorf("verification of database %s failed: %w", database, err)
+		}
+	}
+
+	m.logger.Info(fmt.Sprintf("blue-green migration completed, service: %s, database: %s", spec.ServiceName, database))
+	return database, nil
+}
+
+// migrateClone выполняет Migrate сервиса на копии database, временно заменяя ConnectFunc и DisconnectFunc сервиса.
+func (m *MigrationManager) migrateClone(
+	ctx context.Context,
+	service *ServiceInfo,
+	spec BlueGreenSpec,
+	database string,
+	disconnectClone func(db *gorm.DB),
+) error {
+	connectFunc, disconnectFunc := service.ConnectFunc, service.DisconnectFunc
+	defer func() {
+		service.ConnectFunc, service.DisconnectFunc = connectFunc, disconnectFunc
+	}()
+
+	service.ConnectFunc = func() *gorm.DB { return spec.ConnectClone(database) }
+	service.DisconnectFunc = disconnectClone
+	return m.migrateWithOptions(ctx, spec.ServiceName, spec.Run, newMigrationReport(spec.ServiceName))
+}
+
+// waitSourceConnections ожидает закрытия соединений с копируемой базой данных и при необходимости завершает их.
+func (m *MigrationManager) waitSourceConnections(ctx context.Context, spec BlueGreenSpec) error {
+	countConnections := func(ctx context.Context) (int64, error) {
+		var count int64
+		err := spec.Maintenance.WithContext(ctx).
+			Raw("SELECT count(*) FROM pg_stat_activity WHERE datname = ? AND pid <> pg_backend_pid()", spec.SourceDatabase).
+			Scan(&count).Error
+		return count, err
+	}
+
+	var err error
+	if spec.ConnectionsWait > 0 {
+		err = waitFor(ctx, "source database connections", blueGreenConnectionsPollInterval, spec.ConnectionsWait,
+			func(ctx context.Context) (bool, error) {
+				count, err := countConnections(ctx)
+				return count == 0, err
+			},
+		)
+		if err == nil || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+	} else {
+		var count int64
+		count, err = countConnections(ctx)
+		if err != nil || count == 0 {
+			return err
+		}
+		err = fmt.Errorf("%d connections found", count)
+	}
+
+	if !spec.TerminateConnections {
+		return fmt.Errorf("database %s has active connections: %w", spec.SourceDatabase, err)
+	}
+
+	m.logger.Warn(fmt.Sprintf("terminating connections to database %s, service: %s", spec.SourceDatabase, spec.ServiceName))
+	return spec.Maintenance.WithContext(ctx).Exec(
+		"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = ? AND pid <> pg_backend_pid()",
+		spec.SourceDatabase,
+	).Error
+}
+
+func quotePostgresIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
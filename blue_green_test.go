@@ -0,0 +1,121 @@
+package db_migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresDatabaseDSN заменяет имя базы данных в строке подключения postgres в формате URL.
+func postgresDatabaseDSN(t *testing.T, dsn string, database string) string {
+	t.Helper()
+
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.Scheme == "" {
+		t.Skip("DB_MIGRATOR_TEST_POSTGRES_DSN must be a URL to connect to other databases")
+	}
+	parsed.Path = "/" + database
+	return parsed.String()
+}
+
+func openPostgresTestDatabase(t *testing.T, driver string, dsn string) *gorm.DB {
+	t.Helper()
+
+	sqlDb, err := sql.Open(driver, dsn)
+	require.NoError(t, err)
+	db, err := gorm.Open(sqlDialector{name: "postgres", conn: sqlDb}, &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	return db
+}
+
+func blueGreenTestMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	}
+}
+
+func TestBlueGreenMigrateRequiresPostgres(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1", blueGreenTestMigrations()...))
+
+	_, err := m.BlueGreenMigrate(context.Background(), BlueGreenSpec{
+		ServiceName:    "service1",
+		Maintenance:    connect(),
+		SourceDatabase: "service1",
+		ConnectClone:   func(string) *gorm.DB { return connect() },
+	})
+	require.ErrorContains(t, err, "not supported by sqlite dialect")
+}
+
+func TestBlueGreenMigratePostgres(t *testing.T) {
+	driver, dsn := postgresTestDSN(t)
+
+	maintenance := openPostgresTestDatabase(t, driver, postgresDatabaseDSN(t, dsn, "postgres"))
+	source := fmt.Sprintf("blue_green%d", time.Now().UnixNano())
+	require.NoError(t, maintenance.Exec("CREATE DATABASE "+quotePostgresIdentifier(source)).Error)
+	t.Cleanup(func() {
+		for _, database := range []string{source + "_clone", source + "_failed", source} {
+			_ = maintenance.Exec("DROP DATABASE IF EXISTS " + quotePostgresIdentifier(database)).Error
+		}
+		if sqlDb, err := maintenance.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+	})
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterServiceSQL("service1",
+		func() (*sql.DB, error) { return sql.Open(driver, postgresDatabaseDSN(t, dsn, source)) },
+		func(db *sql.DB) { _ = db.Close() },
+		"1.0.1",
+		WithSQLDialect("postgres"),
+	))
+	require.NoError(t, m.Register("service1", blueGreenTestMigrations()...))
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{TargetVersion: "1.0.0"}))
+
+	spec := BlueGreenSpec{
+		ServiceName:     "service1",
+		Maintenance:     maintenance,
+		SourceDatabase:  source,
+		CloneDatabase:   source + "_clone",
+		ConnectionsWait: 10 * time.Second,
+		ConnectClone: func(database string) *gorm.DB {
+			return openPostgresTestDatabase(t, driver, postgresDatabaseDSN(t, dsn, database))
+		},
+		Verify: func(ctx context.Context, db *gorm.DB) error {
+			return db.WithContext(ctx).Exec("SELECT b FROM a").Error
+		},
+	}
+
+	database, err := m.BlueGreenMigrate(context.Background(), spec)
+	require.NoError(t, err)
+	require.Equal(t, source+"_clone", database)
+
+	// сервис снова подключается к исходной базе данных, которая не изменилась
+	version, err := m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", version.String())
+
+	spec.CloneDatabase = source + "_failed"
+	spec.Verify = func(context.Context, *gorm.DB) error { return errors.New("verification failed") }
+	database, err = m.BlueGreenMigrate(context.Background(), spec)
+	require.ErrorContains(t, err, "verification failed")
+	require.Empty(t, database)
+
+	var clones int64
+	require.NoError(t, maintenance.Raw("SELECT count(*) FROM pg_database WHERE datname = ?", source+"_failed").Scan(&clones).Error)
+	require.Zero(t, clones, "failed clone must be dropped")
+
+	version, err = m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", version.String())
+}
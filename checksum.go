@@ -0,0 +1,173 @@
+package db_migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Вспомогательные функции для вычисления контрольной суммы по объектам базы данных. Предназначены для использования в
+// Migration.CheckSum миграций типа TypeRepeatable, которые необходимо выполнять повторно при изменении структуры
+// таблиц или представлений, а не текста миграции:
+//
+//	CheckSum: func(db *gorm.DB) string {
+//		sum, err := ChecksumOfTableDDL(db, "orders", "order_items")
+//		if err != nil {
+//			return "" // пустая контрольная сумма приведет к повторному выполнению при следующем успешном расчете
+//		}
+//		return sum
+//	}
+//
+// Результат детерминирован: строки упорядочиваются, пробельные символы в определениях нормализуются.
+
+// ChecksumOfTableDDL вычисляет контрольную сумму по определениям колонок перечисленных таблиц (имя, тип, допустимость
+// NULL и значение по умолчанию).
+func ChecksumOfTableDDL(db *gorm.DB, tables ...string) (string, error) {
+	if len(tables) == 0 {
+		return "", fmt.Errorf("no tables specified")
+	}
+
+	var query string
+	switch db.Dialector.Name() {
+	case "sqlite":
+		lines := make([]string, 0)
+		for _, table := range tables {
+			tableLines, err := queryLines(db, fmt.Sprintf("SELECT '%s', name, type, \"notnull\", dflt_value FROM pragma_table_info('%s')",
+				escapeLiteral(table), escapeLiteral(table)))
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, tableLines...)
+		}
+		return hashLines(lines), nil
+	case "mysql":
+		query = `SELECT table_name, column_name, column_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name IN ?`
+	case "postgres":
+		query = `SELECT table_name, column_name, data_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name IN ?`
+	default:
+		query = `SELECT table_name, column_name, data_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_name IN ?`
+	}
+
+	lines, err := queryLines(db, query, tables)
+	if err != nil {
+		return "", err
+	}
+
+	return hashLines(lines), nil
+}
+
+// ChecksumOfQuery вычисляет контрольную сумму по результату выполнения запроса. Порядок строк результата не влияет на
+// значение контрольной суммы.
+func ChecksumOfQuery(db *gorm.DB, query string, args ...interface{}) (string, error) {
+	lines, err := queryLines(db, query, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return hashLines(lines), nil
+}
+
+// ChecksumOfViewDefinitions вычисляет контрольную сумму по определениям перечисленных представлений.
+func ChecksumOfViewDefinitions(db *gorm.DB, views ...string) (string, error) {
+	if len(views) == 0 {
+		return "", fmt.Errorf("no views specified")
+	}
+
+	var query string
+	switch db.Dialector.Name() {
+	case "sqlite":
+		query = `SELECT name, sql FROM sqlite_master WHERE type = 'view' AND name IN ?`
+	case "mysql":
+		query = `SELECT table_name, view_definition
+			FROM information_schema.views
+			WHERE table_schema = DATABASE() AND table_name IN ?`
+	case "sqlserver":
+		query = `SELECT v.name, m.definition
+			FROM sys.views v JOIN sys.sql_modules m ON m.object_id = v.object_id
+			WHERE v.name IN ?`
+	default:
+		query = `SELECT viewname, definition FROM pg_views WHERE schemaname = current_schema() AND viewname IN ?`
+	}
+
+	lines, err := queryLines(db, query, views)
+	if err != nil {
+		return "", err
+	}
+
+	return hashLines(lines), nil
+}
+
+// queryLines выполняет запрос и возвращает строки результата, приведенные к нормализованному текстовому виду.
+func queryLines(db *gorm.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		fields := make([]string, len(values))
+		for i, value := range values {
+			fields[i] = normalizeValue(value)
+		}
+		lines = append(lines, strings.Join(fields, "\x1f"))
+	}
+
+	return lines, rows.Err()
+}
+
+func normalizeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return strings.Join(strings.Fields(string(v)), " ")
+	case string:
+		return strings.Join(strings.Fields(v), " ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func hashLines(lines []string) string {
+	sorted := make([]string, len(lines))
+	copy(sorted, lines)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, line := range sorted {
+		_, _ = h.Write([]byte(line))
+		_, _ = h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func escapeLiteral(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
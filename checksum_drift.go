@@ -0,0 +1,100 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrChecksumMismatch = errors.New("applied migration was changed after execution")
+
+// checksumMismatches возвращает успешно выполненные миграции типов TypeVersioned и TypeBaseline, сохраненная
+// контрольная сумма которых отличается от контрольной суммы зарегистрированной миграции. Миграции без сохраненной
+// контрольной суммы (выполненные предыдущими версиями библиотеки, см. ReconcileChecksums) и миграции, контрольную
+// сумму которых вычислить невозможно, не проверяются.
+func (m *MigrationManager) checksumMismatches(
+	db *gorm.DB,
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+) ([]ChecksumEntry, error) {
+	mismatches := make([]ChecksumEntry, 0)
+	for i := range savedMigrations {
+		if savedMigrations[i].Type == string(TypeRepeatable) || savedMigrations[i].State != models.StateSuccess {
+			continue
+		}
+		if len(savedMigrations[i].Checksum) == 0 {
+			continue
+		}
+
+		migration, found, err := m.findMigration(serviceName, savedMigrations[i])
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		expected, verifiable := expectedChecksum(db, migration)
+		if !verifiable || expected == savedMigrations[i].Checksum {
+			continue
+		}
+
+		mismatches = append(mismatches, ChecksumEntry{
+			MigrationType: MigrationType(savedMigrations[i].Type),
+			Version:       savedMigrations[i].Version.String(),
+			Stored:        savedMigrations[i].Checksum,
+			Expected:      expected,
+		})
+	}
+	return mismatches, nil
+}
+
+// checkChecksums возвращает ErrChecksumMismatch, если выполненные миграции были изменены после выполнения. Проверка
+// отключается опцией WithSkipChecksumValidation.
+func (m *MigrationManager) checkChecksums(db *gorm.DB, serviceName string, savedMigrations []models.MigrationModel) error {
+	if m.skipChecksumValidation {
+		return nil
+	}
+
+	mismatches, err := m.checksumMismatches(db, serviceName, savedMigrations)
+	if err != nil || len(mismatches) == 0 {
+		return err
+	}
+
+	migrations := make([]string, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		migrations = append(migrations, fmt.Sprintf("%s %s", mismatch.MigrationType, mismatch.Version))
+	}
+
+	return fmt.Errorf(
+		"%w: %s, service: %s (revert the changes or update stored checksums with ReconcileChecksums)",
+		ErrChecksumMismatch, strings.Join(migrations, ", "), serviceName,
+	)
+}
+
+// checkSavedChecksums выполняет checkChecksums для миграций, сохраненных в базе данных сервиса. Возвращает nil, если
+// системные таблицы еще не созданы.
+func (m *MigrationManager) checkSavedChecksums(serviceName string) (reasonErr error, err error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return nil, nil
+	}
+
+	// для проверки достаточно ключевых полей миграций, включающих контрольную сумму
+	savedMigrations, err := repository.GetMigrationKeys(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.checkChecksums(service.Db, serviceName, savedMigrations), nil
+}
@@ -0,0 +1,97 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestChecksumMismatch(t *testing.T) {
+	contentMigration := func(content string) Migration {
+		return Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			Content:         content,
+			UpF: func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+				return selfDb.Exec("alter table a add column c text").Error
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		// changed - миграция 1.0.1 или 1.0.2, зарегистрированная вместо выполненной
+		changed Migration
+		opts    []ManagerOption
+		wantErr bool
+	}{
+		{
+			name:    "unchanged",
+			changed: Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		},
+		{
+			name:    "changed up",
+			changed: Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column bb text"},
+			wantErr: true,
+		},
+		{
+			name:    "changed content",
+			changed: contentMigration("add column c, revision 2"),
+			wantErr: true,
+		},
+		{
+			name:    "validation skipped",
+			changed: Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column bb text"},
+			opts:    []ManagerOption{WithSkipChecksumValidation()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connect, disconnect := newTestDatabase(t)
+			applied := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+				contentMigration("add column c, revision 1"),
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.2"))
+			require.NoError(t, previous.Register("service1", applied...))
+			require.NoError(t, previous.Migrate("service1"))
+
+			migrations := []Migration{applied[0], applied[1], applied[2]}
+			if tt.changed.Version == "1.0.1" {
+				migrations[1] = tt.changed
+			} else {
+				migrations[2] = tt.changed
+			}
+
+			m, err := NewMigrationsManager(tt.opts...)
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2"))
+			require.NoError(t, m.Register("service1", migrations...))
+
+			reasonErr, ok, err := m.CheckFulfillment("service1")
+			require.NoError(t, err)
+			err = m.MigrateContext(context.Background(), "service1", RunOptions{})
+			if !tt.wantErr {
+				require.NoError(t, reasonErr)
+				require.True(t, ok)
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, reasonErr, ErrChecksumMismatch)
+			require.ErrorContains(t, reasonErr, "versioned "+tt.changed.Version+".0")
+			require.False(t, ok)
+
+			require.ErrorIs(t, err, ErrChecksumMismatch)
+			require.ErrorContains(t, err, "ReconcileChecksums")
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newChecksumTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	connect, _ := newTestDatabase(t)
+	db := connect()
+	require.NoError(t, db.Exec("create table orders(id int not null, total numeric default 0)").Error)
+	require.NoError(t, db.Exec("create table order_items(id int, order_id int)").Error)
+	require.NoError(t, db.Exec("create view order_totals as select id, total from orders").Error)
+	return db
+}
+
+func TestChecksumOfTableDDL(t *testing.T) {
+	db := newChecksumTestDatabase(t)
+
+	sum, err := ChecksumOfTableDDL(db, "orders", "order_items")
+	require.NoError(t, err)
+	require.NotEmpty(t, sum)
+
+	again, err := ChecksumOfTableDDL(db, "order_items", "orders")
+	require.NoError(t, err)
+	require.Equal(t, sum, again, "table order must not matter")
+
+	// данные не влияют на контрольную сумму
+	require.NoError(t, db.Exec("insert into orders(id, total) values (1, 10)").Error)
+	again, err = ChecksumOfTableDDL(db, "orders", "order_items")
+	require.NoError(t, err)
+	require.Equal(t, sum, again)
+
+	require.NoError(t, db.Exec("alter table order_items add column quantity int").Error)
+	changed, err := ChecksumOfTableDDL(db, "orders", "order_items")
+	require.NoError(t, err)
+	require.NotEqual(t, sum, changed)
+
+	other, err := ChecksumOfTableDDL(db, "orders")
+	require.NoError(t, err)
+	require.NotEqual(t, changed, other)
+
+	_, err = ChecksumOfTableDDL(db)
+	require.Error(t, err)
+}
+
+func TestChecksumOfQuery(t *testing.T) {
+	db := newChecksumTestDatabase(t)
+	require.NoError(t, db.Exec("insert into orders(id, total) values (1, 10), (2, 20)").Error)
+
+	sum, err := ChecksumOfQuery(db, "select id, total from orders order by id")
+	require.NoError(t, err)
+
+	again, err := ChecksumOfQuery(db, "select id, total from orders order by id desc")
+	require.NoError(t, err)
+	require.Equal(t, sum, again, "row order must not matter")
+
+	require.NoError(t, db.Exec("update orders set total = 30 where id = ?", 2).Error)
+	changed, err := ChecksumOfQuery(db, "select id, total from orders order by id")
+	require.NoError(t, err)
+	require.NotEqual(t, sum, changed)
+
+	filtered, err := ChecksumOfQuery(db, "select id, total from orders where id = ?", 1)
+	require.NoError(t, err)
+	require.NotEqual(t, changed, filtered)
+
+	_, err = ChecksumOfQuery(db, "select * from missing_table")
+	require.Error(t, err)
+}
+
+func TestChecksumOfViewDefinitions(t *testing.T) {
+	db := newChecksumTestDatabase(t)
+
+	sum, err := ChecksumOfViewDefinitions(db, "order_totals")
+	require.NoError(t, err)
+
+	// изменение таблицы не меняет определение представления
+	require.NoError(t, db.Exec("alter table order_items add column quantity int").Error)
+	again, err := ChecksumOfViewDefinitions(db, "order_totals")
+	require.NoError(t, err)
+	require.Equal(t, sum, again)
+
+	require.NoError(t, db.Exec("drop view order_totals").Error)
+	require.NoError(t, db.Exec("create view order_totals as select id, total * 2 as total from orders").Error)
+	changed, err := ChecksumOfViewDefinitions(db, "order_totals")
+	require.NoError(t, err)
+	require.NotEqual(t, sum, changed)
+
+	_, err = ChecksumOfViewDefinitions(db)
+	require.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrDatabaseAlreadyClaimed = errors.New("database is already used by another service")
+
+// claimDatabase сохраняет в таблицу migrator_meta имя сервиса, использующего базу данных. Если база данных уже
+// используется другим сервисом, возвращает ErrDatabaseAlreadyClaimed, кроме сервисов с опцией WithSharedDatabase или
+// WithTablePrefix.
+// Таблица migrator_meta должна существовать.
+func (m *MigrationManager) claimDatabase(db *gorm.DB, serviceName string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	claims, err := getServiceClaims(db)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(claims, serviceName) {
+		return nil
+	}
+
+	if len(claims) > 0 && !service.sharedDatabase && len(service.tableNames.Prefix) == 0 {
+		return fmt.Errorf(
+			"%w: database is claimed by %s, service %s cannot use it (check the connection settings, "+
+				"use WithSharedDatabase or ReleaseClaim)",
+			ErrDatabaseAlreadyClaimed, strings.Join(claims, ", "), serviceName,
+		)
+	}
+
+	if len(claims) > 0 {
+		m.logger.Warn(fmt.Sprintf("database is shared by services %s and %s", strings.Join(claims, ", "), serviceName))
+	}
+
+	claims = append(claims, serviceName)
+	slices.Sort(claims)
+	return saveServiceClaims(db, claims)
+}
+
+// ReleaseClaim удаляет из базы данных сервиса serviceName отметку об использовании ее сервисом claimedBy (см.
+// ErrDatabaseAlreadyClaimed). Используется, если база данных передана другому сервису намеренно. Возвращает ошибку,
+// если отметка сервиса claimedBy отсутствует.
+func (m *MigrationManager) ReleaseClaim(serviceName string, claimedBy string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	if !repository.HasMetaTable(service.Db) {
+		return fmt.Errorf("database of service %s is not claimed by %s", serviceName, claimedBy)
+	}
+
+	claims, err := getServiceClaims(service.Db)
+	if err != nil {
+		return err
+	}
+
+	index := slices.Index(claims, claimedBy)
+	if index < 0 {
+		return fmt.Errorf("database of service %s is not claimed by %s", serviceName, claimedBy)
+	}
+
+	m.logger.Warn(fmt.Sprintf("releasing claim of service %s on database of service %s", claimedBy, serviceName))
+
+	return saveServiceClaims(service.Db, slices.Delete(claims, index, index+1))
+}
+
+func getServiceClaims(db *gorm.DB) ([]string, error) {
+	encoded, err := repository.GetMeta(db, repository.MetaServiceClaims)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && len(encoded) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]string, 0)
+	if err := json.Unmarshal([]byte(encoded), &claims); err != nil {
+		return nil, fmt.Errorf("invalid service claims %q: %w", encoded, err)
+	}
+	return claims, nil
+}
+
+func saveServiceClaims(db *gorm.DB, claims []string) error {
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	return repository.SaveMeta(db, repository.MetaServiceClaims, string(encoded))
+}
+
+// adoptUnscopedTables переименовывает системные таблицы без префикса в таблицы с префиксом сервиса (см.
+// WithTablePrefix), если база данных не используется другими сервисами. Иначе таблицы без префикса остаются другим
+// сервисам, а для сервиса создаются новые таблицы.
+func (m *MigrationManager) adoptUnscopedTables(serviceName string, db *gorm.DB) error {
+	if len(repository.TablePrefix(db)) == 0 {
+		return nil
+	}
+
+	if repository.HasMetaTable(db) {
+		claims, err := getServiceClaims(db)
+		if err != nil {
+			return err
+		}
+
+		others := slices.DeleteFunc(slices.Clone(claims), func(claim string) bool { return claim == serviceName })
+		if len(others) > 0 {
+			return nil
+		}
+	}
+
+	adopted, err := repository.AdoptUnscopedTables(db)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range adopted {
+		m.logger.Warn(fmt.Sprintf("table %s renamed to %s%s for service %s", table, repository.TablePrefix(db), table, serviceName))
+	}
+	return nil
+}
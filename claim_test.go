@@ -0,0 +1,42 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseClaimedByAnotherService(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	migrations := []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table if not exists a(id int)"},
+	}
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("orders", connect, disconnect, "1.0.0"))
+	require.NoError(t, m.RegisterService("billing", connect, disconnect, "1.0.0"))
+	require.NoError(t, m.RegisterService("reports", connect, disconnect, "1.0.0", WithSharedDatabase()))
+	for _, service := range []string{"orders", "billing", "reports"} {
+		require.NoError(t, m.Register(service, migrations...))
+	}
+
+	require.NoError(t, m.Migrate("orders"))
+	require.NoError(t, m.Migrate("orders"), "repeated run of the claiming service")
+
+	err = m.Migrate("billing")
+	require.ErrorIs(t, err, ErrDatabaseAlreadyClaimed)
+	require.ErrorContains(t, err, "claimed by orders")
+
+	require.NoError(t, m.Migrate("reports"), "shared database may be claimed by several services")
+
+	require.NoError(t, m.ReleaseClaim("billing", "orders"))
+	err = m.Migrate("billing")
+	require.ErrorIs(t, err, ErrDatabaseAlreadyClaimed)
+	require.ErrorContains(t, err, "claimed by reports")
+
+	require.NoError(t, m.ReleaseClaim("billing", "reports"))
+	require.NoError(t, m.Migrate("billing"))
+
+	require.Error(t, m.ReleaseClaim("billing", "orders"), "claim is already released")
+}
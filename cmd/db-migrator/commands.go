@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	dbmigrator "github.com/Maksumys/db-migrator"
+)
+
+// databaseCommand разбирает флаги команды name, создает менеджер миграций и выполняет команду. Функция flagsFunc
+// регистрирует собственные флаги команды и возвращает проверку их значений.
+func databaseCommand(
+	name string,
+	args []string,
+	stderr io.Writer,
+	flagsFunc func(flags *flag.FlagSet) func() bool,
+	command func(manager *dbmigrator.MigrationManager, service string) int,
+) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	database := registerDatabaseFlags(flags)
+	valid := func() bool { return true }
+	if flagsFunc != nil {
+		valid = flagsFunc(flags)
+	}
+
+	dir, ok := parseDatabaseFlags(flags, database, args)
+	if !ok {
+		return exitUsage
+	}
+	if !valid() {
+		flags.Usage()
+		return exitUsage
+	}
+
+	manager, err := database.manager(dir, stderr)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	return command(manager, *database.service)
+}
+
+// migrateResult - результат команды migrate с флагом -only.
+type migrateResult struct {
+	Service string                   `json:"service"`
+	Type    dbmigrator.MigrationType `json:"type"`
+	Version string                   `json:"version"`
+}
+
+func runMigrate(args []string, stdout io.Writer, stderr io.Writer) int {
+	var only, migrationType *string
+	var force *bool
+	return databaseCommand("migrate", args, stderr, func(flags *flag.FlagSet) func() bool {
+		only = flags.String("only", "", "version of the single pending migration to apply")
+		migrationType = flags.String("type", string(dbmigrator.TypeVersioned), "type of the migration applied with -only")
+		force = flags.Bool("force", false, "apply the -only migration out of order")
+		return func() bool { return len(*only) > 0 || !*force }
+	}, func(manager *dbmigrator.MigrationManager, service string) int {
+		if len(*only) == 0 {
+			report, err := manager.MigrateWithReport(context.Background(), service, dbmigrator.RunOptions{})
+			return writeResult(stdout, stderr, report, err)
+		}
+
+		err := manager.ApplyOne(service, *only, dbmigrator.MigrationType(*migrationType), *force)
+		return writeResult(stdout, stderr, migrateResult{Service: service, Type: dbmigrator.MigrationType(*migrationType), Version: *only}, err)
+	})
+}
+
+// downgradeResult - результат команды downgrade: миграции, отмененные или, с флагом -dry-run, запланированные к
+// отмене.
+type downgradeResult struct {
+	Service    string                        `json:"service"`
+	DryRun     bool                          `json:"dry_run"`
+	Migrations []dbmigrator.PlannedMigration `json:"migrations"`
+}
+
+func runDowngrade(args []string, stdout io.Writer, stderr io.Writer) int {
+	var steps *int
+	var dryRun *bool
+	return databaseCommand("downgrade", args, stderr, func(flags *flag.FlagSet) func() bool {
+		steps = flags.Int("steps", 0, "number of latest versioned migrations to undo regardless of -target, 0 - all above -target")
+		dryRun = flags.Bool("dry-run", false, "print the migrations to undo without changing the database")
+		return func() bool { return *steps >= 0 }
+	}, func(manager *dbmigrator.MigrationManager, service string) int {
+		var planned []dbmigrator.PlannedMigration
+		var err error
+		if *steps > 0 {
+			planned, err = manager.PlanDowngradeSteps(service, *steps)
+		} else {
+			planned, err = manager.PlanDowngrade(service)
+		}
+
+		result := downgradeResult{Service: service, DryRun: *dryRun, Migrations: planned}
+		if err != nil || *dryRun {
+			return writeResult(stdout, stderr, result, err)
+		}
+
+		err = manager.DowngradeContext(context.Background(), service, dbmigrator.RunOptions{Steps: *steps})
+		return writeResult(stdout, stderr, result, err)
+	})
+}
+
+func runRedo(args []string, stdout io.Writer, stderr io.Writer) int {
+	var steps *int
+	return databaseCommand("redo", args, stderr, func(flags *flag.FlagSet) func() bool {
+		steps = flags.Int("steps", 1, "number of latest versioned migrations to undo and apply again")
+		return func() bool { return *steps > 0 }
+	}, func(manager *dbmigrator.MigrationManager, service string) int {
+		report, err := manager.RedoContext(context.Background(), service, *steps)
+		return writeResult(stdout, stderr, report, err)
+	})
+}
+
+// statusResult - результат команды status: история миграций и результат CheckFulfillment.
+type statusResult struct {
+	dbmigrator.ServiceStatus
+	Fulfilled bool `json:"fulfilled"`
+	// Reason - причина, по которой миграции не считаются выполненными
+	Reason string `json:"reason,omitempty"`
+}
+
+func runStatus(args []string, stdout io.Writer, stderr io.Writer) int {
+	var at *time.Time
+	return databaseCommand("status", args, stderr, func(flags *flag.FlagSet) func() bool {
+		flags.Func("at", "report the version and migrations at the given RFC 3339 time", func(value string) error {
+			t, err := time.Parse(time.RFC3339, value)
+			at = &t
+			return err
+		})
+		return func() bool { return true }
+	}, func(manager *dbmigrator.MigrationManager, service string) int {
+		var status dbmigrator.ServiceStatus
+		var err error
+		if at != nil {
+			status, err = manager.StatusAt(service, *at)
+		} else {
+			status, err = manager.Status(service)
+		}
+		if err != nil {
+			return writeResult(stdout, stderr, statusResult{ServiceStatus: status}, err)
+		}
+
+		results, err := manager.CheckFulfillmentAll(context.Background())
+		fulfillment := results[service]
+		if err == nil {
+			err = fulfillment.Err
+		}
+
+		result := statusResult{ServiceStatus: status, Fulfilled: fulfillment.Ok}
+		if fulfillment.Reason != nil {
+			result.Reason = fulfillment.Reason.Error()
+		}
+		if code := writeResult(stdout, stderr, result, err); code != exitOK || result.Fulfilled {
+			return code
+		}
+		return exitWarnings
+	})
+}
+
+func runValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	return databaseCommand("validate", args, stderr, nil, func(manager *dbmigrator.MigrationManager, service string) int {
+		result, err := manager.Validate(service)
+		if code := writeResult(stdout, stderr, result, err); code != exitOK {
+			return code
+		}
+
+		code := exitOK
+		for _, finding := range result.Findings {
+			switch {
+			case finding.Severity == dbmigrator.ValidationError:
+				code = exitErrors
+			case code == exitOK:
+				code = exitWarnings
+			}
+		}
+		return code
+	})
+}
+
+// compatibilityResult - результат команды compatibility.
+type compatibilityResult struct {
+	dbmigrator.CompatibilityReport
+	SafeToRun bool `json:"safe_to_run"`
+}
+
+func runCompatibility(args []string, stdout io.Writer, stderr io.Writer) int {
+	return databaseCommand("compatibility", args, stderr, nil, func(manager *dbmigrator.MigrationManager, service string) int {
+		report, err := manager.Compatibility(service)
+		if err != nil {
+			return writeResult(stdout, stderr, compatibilityResult{CompatibilityReport: report}, err)
+		}
+
+		safe, err := manager.SafeToRun(service)
+		if code := writeResult(stdout, stderr, compatibilityResult{CompatibilityReport: report, SafeToRun: safe}, err); code != exitOK {
+			return code
+		}
+		if !safe {
+			return exitErrors
+		}
+		return exitOK
+	})
+}
+
+func runChecksums(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "reconcile" {
+		_, _ = fmt.Fprintln(stderr, usage)
+		return exitUsage
+	}
+
+	var write, force *bool
+	return databaseCommand("checksums reconcile", args[1:], stderr, func(flags *flag.FlagSet) func() bool {
+		write = flags.Bool("write", false, "store expected checksums of migrations saved without one")
+		force = flags.Bool("force", false, "with -write, also overwrite mismatched checksums")
+		return func() bool { return *write || !*force }
+	}, func(manager *dbmigrator.MigrationManager, service string) int {
+		var opts []dbmigrator.ReconcileOption
+		if *force {
+			opts = append(opts, dbmigrator.WithForceOverwrite())
+		}
+
+		report, err := manager.ReconcileChecksums(service, *write, opts...)
+		if code := writeResult(stdout, stderr, report, err); code != exitOK {
+			return code
+		}
+		if len(report.Mismatched) > 0 && !*force {
+			return exitErrors
+		}
+		return exitOK
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dbmigrator "github.com/Maksumys/db-migrator"
+	"github.com/stretchr/testify/require"
+)
+
+// newCommandDatabase создает каталог миграций files и путь к новой базе данных sqlite.
+func newCommandDatabase(t *testing.T, files map[string]string) (dir string, dsn string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir, filepath.Join(t.TempDir(), "test.db")
+}
+
+// migrateCommandDatabase выполняет миграции каталога dir так же, как команды db-migrator.
+func migrateCommandDatabase(t *testing.T, dir string, dsn string) {
+	t.Helper()
+
+	driver, service, target := "sqlite3", "default", dbmigrator.TargetLatest
+	database := databaseFlags{driver: &driver, dsn: &dsn, service: &service, target: &target}
+	manager, err := database.manager(dir, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, manager.Migrate(service))
+}
+
+func hasColumn(t *testing.T, dsn string, table string, column string) bool {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	require.NoError(t, err)
+	return count > 0
+}
+
+func TestRedoCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, map[string]string{
+		"B1_0_0_0__init.sql":       "create table a(id int)",
+		"V1_0_1_0__add_b.up.sql":   "alter table a add column b text",
+		"V1_0_1_0__add_b.down.sql": "alter table a drop column b",
+		"V1_0_2_0__add_c.up.sql":   "alter table a add column c text",
+		"V1_0_2_0__add_c.down.sql": "alter table a drop column c",
+	})
+	migrateCommandDatabase(t, dir, dsn)
+
+	// миграция 1.0.2 изменена после выполнения
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "V1_0_2_0__add_c.up.sql"), []byte("alter table a add column d text"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "V1_0_2_0__add_c.down.sql"), []byte("alter table a drop column c"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"redo", "-driver", "sqlite3", "-dsn", dsn, "-steps", "1", dir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var report dbmigrator.RedoReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Undone, 1)
+	require.Equal(t, "1.0.2.0", report.Undone[0].Version)
+	require.Len(t, report.Reapplied, 1)
+	require.Equal(t, "1.0.2.0", report.Reapplied[0].Version)
+
+	require.False(t, hasColumn(t, dsn, "a", "c"))
+	require.True(t, hasColumn(t, dsn, "a", "d"))
+}
+
+func commandTestFiles() map[string]string {
+	return map[string]string{
+		"B1_0_0_0__init.sql":       "create table a(id int)",
+		"V1_0_1_0__add_b.up.sql":   "alter table a add column b text",
+		"V1_0_1_0__add_b.down.sql": "alter table a drop column b",
+		"V1_0_2_0__add_c.up.sql":   "alter table a add column c text",
+		"V1_0_2_0__add_c.down.sql": "alter table a drop column c",
+	}
+}
+
+// runCommand выполняет команду с флагами подключения к dsn и каталогом dir и возвращает код завершения и stdout.
+func runCommand(t *testing.T, dsn string, dir string, command []string, flags ...string) (int, []byte) {
+	t.Helper()
+
+	args := append(append(command, "-driver", "sqlite3", "-dsn", dsn), flags...)
+	var stdout, stderr bytes.Buffer
+	code := run(append(args, dir), &stdout, &stderr)
+	t.Log(stderr.String())
+	return code, stdout.Bytes()
+}
+
+func TestCompatibilityCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+	migrateCommandDatabase(t, dir, dsn)
+
+	code, out := runCommand(t, dsn, dir, []string{"compatibility"})
+	require.Equal(t, exitOK, code)
+	var compatibility compatibilityResult
+	require.NoError(t, json.Unmarshal(out, &compatibility))
+	require.True(t, compatibility.SafeToRun)
+	require.Equal(t, dbmigrator.CompatibilityExact, compatibility.Class)
+
+	// каталог бинарного файла предыдущей версии не содержит миграцию 1.0.2
+	older, _ := newCommandDatabase(t, map[string]string{
+		"B1_0_0_0__init.sql":       "create table a(id int)",
+		"V1_0_1_0__add_b.up.sql":   "alter table a add column b text",
+		"V1_0_1_0__add_b.down.sql": "alter table a drop column b",
+	})
+	code, out = runCommand(t, dsn, older, []string{"compatibility"})
+	compatibility = compatibilityResult{}
+	require.NoError(t, json.Unmarshal(out, &compatibility))
+	require.Equal(t, dbmigrator.CompatibilityDatabaseAhead, compatibility.Class)
+	require.Equal(t, exitOK, code, "database ahead is safe to run by default")
+}
+
+func TestDowngradeCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+	migrateCommandDatabase(t, dir, dsn)
+
+	code, out := runCommand(t, dsn, dir, []string{"downgrade"}, "-target", "1.0.0", "-dry-run")
+	require.Equal(t, exitOK, code)
+	var result downgradeResult
+	require.NoError(t, json.Unmarshal(out, &result))
+	require.True(t, result.DryRun)
+	require.Len(t, result.Migrations, 2)
+	require.Equal(t, "1.0.2.0", result.Migrations[0].Version)
+	require.True(t, hasColumn(t, dsn, "a", "c"), "dry run must not change the database")
+
+	// без -target целевая версия совпадает с сохраненной, -steps отменяет последние миграции независимо от нее
+	code, out = runCommand(t, dsn, dir, []string{"downgrade"}, "-steps", "1", "-dry-run")
+	require.Equal(t, exitOK, code)
+	result = downgradeResult{}
+	require.NoError(t, json.Unmarshal(out, &result))
+	require.Len(t, result.Migrations, 1)
+	require.Equal(t, "1.0.1.0", result.Migrations[0].ResultingVersion)
+
+	code, _ = runCommand(t, dsn, dir, []string{"downgrade"}, "-steps", "1")
+	require.Equal(t, exitOK, code)
+	require.True(t, hasColumn(t, dsn, "a", "b"))
+	require.False(t, hasColumn(t, dsn, "a", "c"))
+
+	code, _ = runCommand(t, dsn, dir, []string{"downgrade"}, "-target", "1.0.0")
+	require.Equal(t, exitOK, code)
+	require.False(t, hasColumn(t, dsn, "a", "b"))
+}
+
+func TestStatusCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+
+	code, out := runCommand(t, dsn, dir, []string{"status"})
+	require.Equal(t, exitWarnings, code)
+	var status statusResult
+	require.NoError(t, json.Unmarshal(out, &status))
+	require.False(t, status.Fulfilled)
+	require.NotEmpty(t, status.Reason)
+
+	migrateCommandDatabase(t, dir, dsn)
+
+	code, out = runCommand(t, dsn, dir, []string{"status"})
+	require.Equal(t, exitOK, code)
+	status = statusResult{}
+	require.NoError(t, json.Unmarshal(out, &status))
+	require.True(t, status.Fulfilled)
+	require.Equal(t, "1.0.2.0", status.Version)
+	require.Len(t, status.Migrations, 3)
+
+	code, out = runCommand(t, dsn, dir, []string{"status"}, "-at", "2000-01-01T00:00:00Z")
+	require.Equal(t, exitOK, code)
+	status = statusResult{}
+	require.NoError(t, json.Unmarshal(out, &status))
+	require.NotNil(t, status.At)
+	require.Empty(t, status.Version)
+	require.Empty(t, status.Migrations)
+
+	code, out = runCommand(t, dsn, dir, []string{"status"}, "-at", time.Now().Add(time.Minute).Format(time.RFC3339))
+	require.Equal(t, exitOK, code)
+	status = statusResult{}
+	require.NoError(t, json.Unmarshal(out, &status))
+	require.Equal(t, "1.0.2.0", status.Version)
+	require.Len(t, status.Migrations, 3)
+
+	code, _ = runCommand(t, dsn, dir, []string{"status"}, "-at", "yesterday")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestChecksumsCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+	migrateCommandDatabase(t, dir, dsn)
+
+	code, _ := runCommand(t, dsn, dir, []string{"checksums", "reconcile"})
+	require.Equal(t, exitOK, code)
+
+	// выполненная миграция изменена
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "V1_0_1_0__add_b.up.sql"), []byte("alter table a add column bb text"), 0o644))
+
+	code, out := runCommand(t, dsn, dir, []string{"checksums", "reconcile"})
+	require.Equal(t, exitErrors, code)
+	var reconcile dbmigrator.ReconcileReport
+	require.NoError(t, json.Unmarshal(out, &reconcile))
+	require.Len(t, reconcile.Mismatched, 1)
+
+	code, _ = runCommand(t, dsn, dir, []string{"checksums", "reconcile"}, "-write", "-force")
+	require.Equal(t, exitOK, code)
+
+	code, _ = runCommand(t, dsn, dir, []string{"checksums", "reconcile"})
+	require.Equal(t, exitOK, code)
+}
+
+func TestMigrateCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+
+	code, out := runCommand(t, dsn, dir, []string{"migrate"}, "-target", "1.0.0")
+	require.Equal(t, exitOK, code)
+	var report dbmigrator.MigrationReport
+	require.NoError(t, json.Unmarshal(out, &report))
+	require.Len(t, report.Entries, 1)
+	require.Equal(t, "1.0.0.0", report.FinalVersion)
+
+	code, _ = runCommand(t, dsn, dir, []string{"migrate"}, "-only", "1.0.2")
+	require.Equal(t, exitErrors, code, "1.0.1 must be applied first")
+
+	code, out = runCommand(t, dsn, dir, []string{"migrate"}, "-only", "1.0.1")
+	require.Equal(t, exitOK, code)
+	var applied migrateResult
+	require.NoError(t, json.Unmarshal(out, &applied))
+	require.Equal(t, migrateResult{Service: "default", Type: dbmigrator.TypeVersioned, Version: "1.0.1"}, applied)
+	require.True(t, hasColumn(t, dsn, "a", "b"))
+	require.False(t, hasColumn(t, dsn, "a", "c"))
+
+	code, out = runCommand(t, dsn, dir, []string{"migrate"})
+	require.Equal(t, exitOK, code)
+	report = dbmigrator.MigrationReport{}
+	require.NoError(t, json.Unmarshal(out, &report))
+	require.Len(t, report.Entries, 1)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+	require.True(t, hasColumn(t, dsn, "a", "c"))
+}
+
+func TestValidateCommand(t *testing.T) {
+	dir, dsn := newCommandDatabase(t, commandTestFiles())
+	migrateCommandDatabase(t, dir, dsn)
+
+	code, _ := runCommand(t, dsn, dir, []string{"validate"})
+	require.Equal(t, exitOK, code)
+
+	// выполненная миграция изменена
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "V1_0_1_0__add_b.up.sql"), []byte("alter table a add column bb text"), 0o644))
+
+	code, out := runCommand(t, dsn, dir, []string{"validate"})
+	require.Equal(t, exitErrors, code)
+	var validation dbmigrator.ValidationResult
+	require.NoError(t, json.Unmarshal(out, &validation))
+	require.False(t, validation.SafeToMigrate)
+}
+
+func TestLintCommand(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "testdata", "lint")
+	templateData := filepath.Join(t.TempDir(), "data.json")
+	require.NoError(t, os.WriteFile(templateData, []byte(`{"schema": "app"}`), 0o644))
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+	}{
+		{name: "clean", args: []string{"-strict", filepath.Join(fixtures, "clean")}, code: exitOK},
+		{
+			name:   "warnings",
+			args:   []string{filepath.Join(fixtures, "missing-down")},
+			code:   exitWarnings,
+			stdout: "missing-down/V1_0_1_0__add_b.sql: warning: migration 1.0.1.0 cannot be downgraded, down file is missing (missing-down)\n",
+		},
+		{
+			name:   "errors",
+			args:   []string{"-strict", "-format", "github", filepath.Join(fixtures, "destructive")},
+			code:   exitErrors,
+			stdout: "::error file=" + filepath.Join(fixtures, "destructive", "V1_0_1_0__drop_legacy.up.sql") + ",title=policy::",
+		},
+		{
+			name:   "template",
+			args:   []string{"-template-data", templateData, filepath.Join(fixtures, "template")},
+			code:   exitErrors,
+			stdout: "(template)",
+		},
+		{name: "invalid template data", args: []string{"-template-data", filepath.Join(fixtures, "template"), filepath.Join(fixtures, "template")}, code: exitUsage},
+		{name: "invalid format", args: []string{"-format", "xml", filepath.Join(fixtures, "clean")}, code: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, tt.code, run(append([]string{"lint"}, tt.args...), &stdout, &stderr), stderr.String())
+			require.Contains(t, stdout.String(), tt.stdout)
+		})
+	}
+}
+
+func TestCommandsUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, exitUsage, run([]string{"compatibility", "-driver", "sqlite3", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"downgrade", "-driver", "sqlite3", "-dsn", "x.db", "-steps", "-1", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"status", "-driver", "sqlite3", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"checksums"}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"checksums", "reconcile", "-driver", "sqlite3", "-dsn", "x.db", "-force", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"migrate", "-driver", "sqlite3", "-dsn", "x.db", "-force", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"redo", "-steps", "1", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"redo", "-driver", "sqlite3", "-dsn", "x.db", "-steps", "0", t.TempDir()}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run([]string{"unknown"}, &stdout, &stderr))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	dbmigrator "github.com/Maksumys/db-migrator"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// databaseFlags - флаги подключения к базе данных команд, работающих с миграциями сервиса.
+type databaseFlags struct {
+	driver  *string
+	dsn     *string
+	service *string
+	target  *string
+}
+
+func registerDatabaseFlags(flags *flag.FlagSet) databaseFlags {
+	return databaseFlags{
+		driver:  flags.String("driver", "", "database/sql driver name, e.g. sqlite3"),
+		dsn:     flags.String("dsn", "", "data source name passed to the driver"),
+		service: flags.String("service", "default", "service name the migrations are saved under"),
+		target:  flags.String("target", dbmigrator.TargetLatest, "target version of the service"),
+	}
+}
+
+// parseDatabaseFlags разбирает аргументы команды, требуя флаги подключения и единственный аргумент - каталог миграций.
+func parseDatabaseFlags(flags *flag.FlagSet, database databaseFlags, args []string) (string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", false
+	}
+	if flags.NArg() != 1 || len(*database.driver) == 0 || len(*database.dsn) == 0 {
+		flags.Usage()
+		return "", false
+	}
+	return flags.Arg(0), true
+}
+
+// manager создает менеджер миграций с сервисом, подключаемым драйвером database/sql, и миграциями каталога dir.
+func (f databaseFlags) manager(dir string, stderr io.Writer, opts ...dbmigrator.ManagerOption) (*dbmigrator.MigrationManager, error) {
+	opts = append([]dbmigrator.ManagerOption{dbmigrator.WithLogger(slog.New(slog.NewTextHandler(stderr, nil)))}, opts...)
+	manager, err := dbmigrator.NewMigrationsManager(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, dsn := *f.driver, *f.dsn
+	err = manager.RegisterServiceSQL(
+		*f.service,
+		func() (*sql.DB, error) { return sql.Open(driver, dsn) },
+		func(db *sql.DB) { _ = db.Close() },
+		*f.target,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.RegisterFS(*f.service, os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// writeResult выводит результат команды в формате JSON и возвращает код завершения: результат выводится и в случае
+// ошибки, содержа данные, полученные до ее возникновения.
+func writeResult(stdout io.Writer, stderr io.Writer, result any, err error) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(result); encodeErr != nil {
+		err = errors.Join(err, encodeErr)
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitErrors
+	}
+	return exitOK
+}
@@ -0,0 +1,185 @@
+// Команда db-migrator проверяет миграции из каталога и управляет миграциями базы данных.
+//
+// Использование:
+//
+//	db-migrator lint [-strict] [-baseline file] [-template-data file] [-format text|github] ./migrations
+//	db-migrator migrate [db flags] [-only version [-type type] [-force]] ./migrations
+//	db-migrator downgrade [db flags] [-steps n] [-dry-run] ./migrations
+//	db-migrator redo [db flags] [-steps n] ./migrations
+//	db-migrator status [db flags] [-at time] ./migrations
+//	db-migrator validate [db flags] ./migrations
+//	db-migrator compatibility [db flags] ./migrations
+//	db-migrator checksums reconcile [db flags] [-write [-force]] ./migrations
+//
+// Команда lint выполняет проверки без подключения к базе данных. С -template-data файлы миграций проверяются как
+// шаблоны text/template с данными из JSON файла. Коды завершения: 0 - нарушений нет, 1 - найдены только
+// предупреждения, 2 - найдены ошибки, 3 - некорректные аргументы или ошибка чтения каталога.
+//
+// Остальные команды регистрируют миграции из каталога (см. MigrationManager.RegisterFS) и подключаются к базе данных
+// через database/sql: -driver name -dsn dsn [-service name] [-target version]. В сборку команды включен драйвер
+// sqlite3, другие драйверы подключаются импортом в собственной сборке. Целевая версия по умолчанию - последняя версия
+// миграций каталога; для downgrade -target задает версию, до которой отменяются миграции. Для status -at задает момент
+// времени в формате RFC 3339, на который выводятся версия и миграции (см. MigrationManager.StatusAt). Результат
+// выводится в stdout в формате JSON, журнал - в stderr. Коды завершения: 0 - команда выполнена, 1 - status: миграции не
+// выполнены, validate: найдены только предупреждения, 2 - ошибка выполнения, validate: найдены ошибки, compatibility:
+// приложение несовместимо с базой данных, checksums reconcile: остались несовпадающие контрольные суммы, 3 -
+// некорректные аргументы.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	dbmigrator "github.com/Maksumys/db-migrator"
+)
+
+const (
+	exitOK       = 0
+	exitWarnings = 1
+	exitErrors   = 2
+	exitUsage    = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// commands - подкоманды по имени, получающие аргументы после имени подкоманды.
+var commands = map[string]func(args []string, stdout io.Writer, stderr io.Writer) int{
+	"lint":          runLint,
+	"migrate":       runMigrate,
+	"downgrade":     runDowngrade,
+	"redo":          runRedo,
+	"status":        runStatus,
+	"validate":      runValidate,
+	"compatibility": runCompatibility,
+	"checksums":     runChecksums,
+}
+
+const usage = `usage:
+  db-migrator lint [-strict] [-baseline file] [-template-data file] [-format text|github] <dir>
+  db-migrator migrate [db flags] [-only version [-type type] [-force]] <dir>
+  db-migrator downgrade [db flags] [-steps n] [-dry-run] <dir>
+  db-migrator redo [db flags] [-steps n] <dir>
+  db-migrator status [db flags] [-at time] <dir>
+  db-migrator validate [db flags] <dir>
+  db-migrator compatibility [db flags] <dir>
+  db-migrator checksums reconcile [db flags] [-write [-force]] <dir>
+db flags: -driver name -dsn dsn [-service name] [-target version]`
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprintln(stderr, usage)
+		return exitUsage
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintln(stderr, usage)
+		return exitUsage
+	}
+	return command(args[1:], stdout, stderr)
+}
+
+func runLint(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	strict := flags.Bool("strict", false, "check migrations against the strict policy profile")
+	baselineFile := flags.String("baseline", "", "file with versions or file names of migrations on the main branch, one per line")
+	templateDataFile := flags.String("template-data", "", "JSON file with data of migration templates, enables template checks")
+	format := flags.String("format", "text", "output format: text or github")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 || *format != "text" && *format != "github" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	opts := dbmigrator.LintOptions{Profile: dbmigrator.RelaxedProfile()}
+	if *strict {
+		opts.Profile = dbmigrator.StrictProfile()
+	}
+	if len(*baselineFile) > 0 {
+		baseline, err := readBaseline(*baselineFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		opts.Baseline = baseline
+	}
+	if len(*templateDataFile) > 0 {
+		data, err := readTemplateData(*templateDataFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		opts.RenderTemplates = true
+		opts.TemplateData = data
+	}
+
+	findings, err := dbmigrator.Lint(os.DirFS(flags.Arg(0)), opts)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, finding := range findings {
+		// пути в сообщениях указываются относительно текущего каталога, как ожидают аннотации GitHub
+		finding.File = strings.TrimSuffix(flags.Arg(0), "/") + "/" + finding.File
+		if *format == "github" {
+			_, _ = fmt.Fprintln(stdout, finding.GitHubAnnotation())
+		} else {
+			_, _ = fmt.Fprintln(stdout, finding)
+		}
+
+		switch {
+		case finding.Severity == dbmigrator.LintError:
+			code = exitErrors
+		case code == exitOK:
+			code = exitWarnings
+		}
+	}
+
+	return code
+}
+
+// readTemplateData читает данные шаблонов миграций из JSON файла с объектом.
+func readTemplateData(name string) (map[string]any, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]any)
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("invalid template data %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// readBaseline читает непустые строки файла, строки, начинающиеся с #, пропускаются.
+func readBaseline(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	baseline := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		baseline = append(baseline, line)
+	}
+	return baseline, scanner.Err()
+}
@@ -0,0 +1,176 @@
+package db_migrator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// CompatibilityClass - соотношение миграций приложения и базы данных.
+type CompatibilityClass string
+
+const (
+	// CompatibilityExact - все зарегистрированные миграции выполнены, неизвестных приложению миграций нет.
+	CompatibilityExact CompatibilityClass = "exact"
+	// CompatibilityDatabaseAhead - в базе данных выполнены миграции, не зарегистрированные в приложении (например,
+	// после отката приложения на предыдущую версию).
+	CompatibilityDatabaseAhead CompatibilityClass = "database_ahead"
+	// CompatibilityBinaryAhead - в приложении есть невыполненные миграции.
+	CompatibilityBinaryAhead CompatibilityClass = "binary_ahead"
+	// CompatibilityDiverged - одновременно CompatibilityDatabaseAhead и CompatibilityBinaryAhead.
+	CompatibilityDiverged CompatibilityClass = "diverged"
+)
+
+// CompatibilityMigration описывает миграцию в составе CompatibilityReport.
+type CompatibilityMigration struct {
+	Type    MigrationType `json:"type"`
+	Version string        `json:"version"`
+}
+
+// CompatibilityReport - результат сравнения миграций приложения с миграциями, сохраненными в базе данных.
+type CompatibilityReport struct {
+	Service string             `json:"service"`
+	Class   CompatibilityClass `json:"class"`
+	// Fingerprint - хеш зарегистрированных миграций приложения (см. Fingerprint)
+	Fingerprint string `json:"fingerprint"`
+	// Unknown - выполненные миграции, не зарегистрированные в приложении, упорядоченные по версии
+	Unknown []CompatibilityMigration `json:"unknown"`
+	// Pending - зарегистрированные миграции, не выполненные в базе данных, упорядоченные по версии
+	Pending []CompatibilityMigration `json:"pending"`
+}
+
+// Compatibility сравнивает миграции, зарегистрированные в приложении, с миграциями, выполненными в базе данных
+// сервиса, и определяет, может ли приложение работать с базой данных (см. CompatibilityClass и SafeToRun). Метод не
+// изменяет базу данных.
+//
+// Выполненными считаются миграции в состоянии StateSuccess; пропущенные, исключенные и отсутствующие в коде миграции
+// не учитываются. Миграции типа TypeRepeatable, выполненные с другой контрольной суммой, не считаются невыполненными.
+func (m *MigrationManager) Compatibility(serviceName string) (CompatibilityReport, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return CompatibilityReport{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+
+	return m.compatibility(serviceName)
+}
+
+// SafeToRun определяет, может ли приложение работать с базой данных сервиса: база данных соответствует приложению
+// или приложение содержит невыполненные миграции. Если база данных опережает приложение, результат определяется
+// опцией WithForbidOlderBinary: без нее работа допускается. При CompatibilityDiverged возвращается false.
+func (m *MigrationManager) SafeToRun(serviceName string) (bool, error) {
+	report, err := m.Compatibility(serviceName)
+	if err != nil {
+		return false, err
+	}
+	return m.safeToRun(report.Class), nil
+}
+
+func (m *MigrationManager) safeToRun(class CompatibilityClass) bool {
+	switch class {
+	case CompatibilityExact, CompatibilityBinaryAhead:
+		return true
+	case CompatibilityDatabaseAhead:
+		return !m.forbidOlderBinary
+	default:
+		return false
+	}
+}
+
+func (m *MigrationManager) compatibility(serviceName string) (CompatibilityReport, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return CompatibilityReport{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	fingerprint, err := m.fingerprint(serviceName)
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+
+	report := CompatibilityReport{
+		Service:     serviceName,
+		Fingerprint: fingerprint,
+		Unknown:     []CompatibilityMigration{},
+		Pending:     []CompatibilityMigration{},
+	}
+
+	savedMigrations := make([]models.MigrationModel, 0)
+	if repository.HasMigrationsTable(service.Db) {
+		savedMigrations, err = repository.GetMigrationKeys(service.Db)
+		if err != nil {
+			return CompatibilityReport{}, err
+		}
+	}
+
+	saved := make(map[uint32]models.MigrationModel, len(savedMigrations))
+	for _, migrationModel := range savedMigrations {
+		saved[getMigrationIdentifier(migrationModel.Version, migrationModel.Type)] = migrationModel
+
+		if migrationModel.State != models.StateSuccess {
+			continue
+		}
+		_, found, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return CompatibilityReport{}, err
+		}
+		if !found {
+			report.Unknown = append(report.Unknown, CompatibilityMigration{
+				Type:    MigrationType(migrationModel.Type),
+				Version: migrationModel.Version.String(),
+			})
+		}
+	}
+
+	for _, migration := range service.registeredMigrations {
+		migrationModel, ok := saved[migration.Identifier]
+		if ok && migrationModel.State != models.StateRegistered && migrationModel.State != models.StateFailure &&
+			migrationModel.State != models.StateUndone && migrationModel.State != models.StateNotFound {
+			continue
+		}
+
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return CompatibilityReport{}, err
+		}
+		report.Pending = append(report.Pending, CompatibilityMigration{Type: migration.MigrationType, Version: version.String()})
+	}
+
+	for _, migrations := range [][]CompatibilityMigration{report.Unknown, report.Pending} {
+		sort.SliceStable(migrations, func(i, j int) bool {
+			a, _ := models.ParseVersion(migrations[i].Version)
+			b, _ := models.ParseVersion(migrations[j].Version)
+			return a.LessThan(b)
+		})
+	}
+
+	switch {
+	case len(report.Unknown) > 0 && len(report.Pending) > 0:
+		report.Class = CompatibilityDiverged
+	case len(report.Unknown) > 0:
+		report.Class = CompatibilityDatabaseAhead
+	case len(report.Pending) > 0:
+		report.Class = CompatibilityBinaryAhead
+	default:
+		report.Class = CompatibilityExact
+	}
+
+	return report, nil
+}
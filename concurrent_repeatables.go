@@ -0,0 +1,104 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+// canRunConcurrently определяет, может ли миграция выполняться параллельно с другими: это найденная миграция типа
+// TypeRepeatable без зависимостей от других сервисов и дополнительных подключений.
+func (m *MigrationManager) canRunConcurrently(serviceName string, migrationModel models.MigrationModel) bool {
+	if migrationModel.Type != string(TypeRepeatable) {
+		return false
+	}
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+	if err != nil || !ok {
+		return false
+	}
+
+	return len(migration.Dependency) == 0 && len(migration.UsesAuxiliary) == 0
+}
+
+// applyRepeatablesConcurrently выполняет миграции типа TypeRepeatable пулом из RunOptions.RepeatableConcurrency
+// обработчиков. Обработчики используют пул соединений service.Db и не открывают и не закрывают соединения сервиса,
+// поэтому ConnectFunc может возвращать общий для приложения пул. После первой ошибки или отмены ctx новые миграции не
+// запускаются, уже запущенные завершаются.
+func (m *MigrationManager) applyRepeatablesConcurrently(
+	ctx context.Context,
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+	migrationModels []models.MigrationModel,
+	opts RunOptions,
+) (migrationOutcome, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return migrationOutcome{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	workers := min(opts.RepeatableConcurrency, len(migrationModels))
+
+	m.logger.Info(
+		fmt.Sprintf(
+			"executing %d repeatable migrations with concurrency %d, service: %s",
+			len(migrationModels), workers, serviceName,
+		),
+	)
+
+	jobs := make(chan models.MigrationModel)
+
+	var (
+		mutex   sync.Mutex
+		outcome migrationOutcome
+		errs    []error
+		wg      sync.WaitGroup
+	)
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(errs) > 0
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for migrationModel := range jobs {
+				result, err := m.applyMigration(serviceName, service.Db, savedMigrations, migrationModel, opts)
+
+				mutex.Lock()
+				outcome.add(result)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, migrationModel := range migrationModels {
+		if failed() {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			mutex.Lock()
+			errs = append(errs, err)
+			mutex.Unlock()
+			break
+		}
+		jobs <- migrationModel
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return outcome, errors.Join(errs...)
+}
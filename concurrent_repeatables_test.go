@@ -0,0 +1,54 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestConcurrentRepeatablesShareServiceConnection проверяет, что параллельные обработчики не открывают и не закрывают
+// соединения сервиса: ConnectFunc возвращает общий пул приложения, закрываемый DisconnectFunc.
+func TestConcurrentRepeatablesShareServiceConnection(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	pool := connect()
+	sqlDb, err := pool.DB()
+	require.NoError(t, err)
+	sqlDb.SetMaxOpenConns(1)
+
+	var connects, disconnects int
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", func() *gorm.DB {
+		connects++
+		return pool
+	}, func(db *gorm.DB) {
+		disconnects++
+	}, "1.0.0"))
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+	for i := 0; i < 6; i++ {
+		require.NoError(t, m.Register("service1", Migration{
+			MigrationType:   TypeRepeatable,
+			Version:         fmt.Sprintf("1.0.0.%d", i),
+			IsTransactional: true,
+			Up:              fmt.Sprintf("create view if not exists v%d as select * from a", i),
+			CheckSum:        fixtureChecksum("v1"),
+		}))
+	}
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{RepeatableConcurrency: 3})
+	require.NoError(t, err)
+	require.Len(t, report.Entries, 7)
+	for _, entry := range report.Entries {
+		require.Equal(t, OutcomeExecuted, entry.Outcome, "%s %s: %s", entry.Type, entry.Version, entry.Error)
+	}
+	require.Equal(t, 1, connects)
+	require.Equal(t, 1, disconnects)
+
+	disconnect(pool)
+}
@@ -0,0 +1,95 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+var ErrNotConfirmed = errors.New("operation was not confirmed")
+
+type Operation string
+
+const (
+	// OperationDowngrade - отмена миграций Downgrade, подтверждается при отмене не менее порога миграций (см.
+	// WithConfirmationThreshold).
+	OperationDowngrade Operation = "downgrade"
+	// OperationForceVersion - миграция отмечается выполненной без выполнения, сохраненная версия повышается (см.
+	// MarkApplied).
+	OperationForceVersion Operation = "force_version"
+	// OperationForceApply - миграция выполняется ApplyOne с force вне очереди или вне зависимости от типа.
+	OperationForceApply Operation = "force_apply"
+	// OperationBaselineTeardown - миграция типа TypeBaseline повторно выполняется в базе данных, в которой выполнены
+	// другие миграции (см. RerunForce).
+	OperationBaselineTeardown Operation = "baseline_teardown"
+)
+
+// ConfirmationRequest описывает опасную операцию, для выполнения которой требуется подтверждение.
+type ConfirmationRequest struct {
+	Operation   Operation
+	ServiceName string
+	// Versions - версии миграций, затрагиваемых операцией, в порядке выполнения.
+	Versions []string
+	// Migrations - миграции, затрагиваемые операцией, в порядке выполнения (см. FormatPlan).
+	Migrations []PlannedMigration
+	// Impact - человекочитаемое описание последствий операции.
+	Impact string
+}
+
+// confirm запрашивает подтверждение операции. Если функция подтверждения не задана, операция считается
+// подтвержденной.
+func (m *MigrationManager) confirm(request ConfirmationRequest) error {
+	if m.confirmation == nil {
+		return nil
+	}
+
+	ok, err := m.confirmation(request)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		m.logger.Warn(fmt.Sprintf("%s was not confirmed, service: %s", request.Operation, request.ServiceName))
+		return fmt.Errorf("%w: %s, service: %s", ErrNotConfirmed, request.Operation, request.ServiceName)
+	}
+
+	return nil
+}
+
+// confirmMigration запрашивает подтверждение операции operation над одной миграцией migrationModel.
+func (m *MigrationManager) confirmMigration(
+	operation Operation,
+	serviceName string,
+	migrationModel models.MigrationModel,
+	impact string,
+) error {
+	if m.confirmation == nil {
+		return nil
+	}
+
+	plannedMigration, err := m.plannedMigration(serviceName, migrationModel, DirectionUp)
+	if err != nil {
+		return err
+	}
+
+	return m.confirm(ConfirmationRequest{
+		Operation:   operation,
+		ServiceName: serviceName,
+		Versions:    []string{migrationModel.Version.String()},
+		Migrations:  []PlannedMigration{plannedMigration},
+		Impact:      impact,
+	})
+}
+
+// downgradeNeedsConfirmation определяет, требуется ли подтверждение отмены count миграций.
+func (m *MigrationManager) downgradeNeedsConfirmation(count int) bool {
+	return count > 0 && count >= m.confirmationThreshold
+}
+
+func migrationVersions(migrations []models.MigrationModel) []string {
+	versions := make([]string, 0, len(migrations))
+	for i := range migrations {
+		versions = append(versions, migrations[i].Version.String())
+	}
+	return versions
+}
@@ -0,0 +1,101 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// confirmations возвращает функцию подтверждения, сохраняющую запросы и возвращающую approve.
+func confirmations(requests *[]ConfirmationRequest, approve *bool) func(ConfirmationRequest) (bool, error) {
+	return func(request ConfirmationRequest) (bool, error) {
+		*requests = append(*requests, request)
+		return *approve, nil
+	}
+}
+
+func TestConfirmationDowngrade(t *testing.T) {
+	var requests []ConfirmationRequest
+	approve := false
+	m, connect := newTestManager(t, "1.0.3", WithConfirmation(confirmations(&requests, &approve)))
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+	require.Empty(t, requests, "migrate is not a dangerous operation")
+
+	require.ErrorIs(t, m.DowngradeTo("service1", "1.0.1"), ErrNotConfirmed)
+	require.Len(t, requests, 1)
+	require.Equal(t, OperationDowngrade, requests[0].Operation)
+	require.Equal(t, "service1", requests[0].ServiceName)
+	require.Equal(t, []string{"1.0.3.0", "1.0.2.0"}, requests[0].Versions)
+	require.Len(t, requests[0].Migrations, 2)
+	require.Contains(t, requests[0].Impact, "resulting version: 1.0.1.0")
+	require.True(t, connect().Migrator().HasTable("d"), "declined downgrade must not change the database")
+
+	approve = true
+	require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+	require.Len(t, requests, 2)
+	require.False(t, connect().Migrator().HasTable("c"))
+}
+
+func TestConfirmationThreshold(t *testing.T) {
+	var requests []ConfirmationRequest
+	approve := false
+	m, connect := newTestManager(t, "1.0.3",
+		WithConfirmation(confirmations(&requests, &approve)),
+		WithConfirmationThreshold(2),
+	)
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.NoError(t, m.DowngradeSteps("service1", 1))
+	require.Empty(t, requests)
+	require.False(t, connect().Migrator().HasTable("d"))
+
+	require.ErrorIs(t, m.DowngradeSteps("service1", 2), ErrNotConfirmed)
+	require.Len(t, requests, 1)
+	require.True(t, connect().Migrator().HasTable("c"))
+}
+
+func TestConfirmationForceOperations(t *testing.T) {
+	var requests []ConfirmationRequest
+	approve := false
+	m, connect := newTestManager(t, "1.0.3", WithConfirmation(confirmations(&requests, &approve)))
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("")...))
+	require.NoError(t, m.MigrateTo("service1", "1.0.1"))
+
+	// ApplyOne вне очереди
+	require.ErrorIs(t, m.ApplyOne("service1", "1.0.3", TypeVersioned, true), ErrNotConfirmed)
+	require.Equal(t, OperationForceApply, requests[len(requests)-1].Operation)
+	require.Contains(t, requests[len(requests)-1].Impact, "cannot be downgraded")
+	require.False(t, connect().Migrator().HasTable("d"))
+
+	// ApplyOne в порядке версий не требует подтверждения
+	require.NoError(t, m.ApplyOne("service1", "1.0.2", TypeVersioned, true))
+	require.Len(t, requests, 1)
+
+	// MarkApplied
+	require.ErrorIs(t, m.MarkApplied("service1", "1.0.3"), ErrNotConfirmed)
+	require.Equal(t, OperationForceVersion, requests[len(requests)-1].Operation)
+	require.Equal(t, []string{"1.0.3.0"}, requests[len(requests)-1].Versions)
+	version, err := repository.GetVersion(connect())
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", version.String())
+
+	approve = true
+	require.NoError(t, m.MarkApplied("service1", "1.0.3"))
+	version, err = repository.GetVersion(connect())
+	require.NoError(t, err)
+	require.Equal(t, "1.0.3.0", version.String())
+
+	// повторное выполнение миграции TypeBaseline в заполненной базе данных
+	approve = false
+	require.NoError(t, connect().Exec("drop table a").Error)
+	require.ErrorIs(t, m.Rerun("service1", "1.0.0", TypeBaseline, RerunForce()), ErrNotConfirmed)
+	require.Equal(t, OperationBaselineTeardown, requests[len(requests)-1].Operation)
+	require.False(t, connect().Migrator().HasTable("a"))
+
+	approve = true
+	require.NoError(t, m.Rerun("service1", "1.0.0", TypeBaseline, RerunForce()))
+	require.True(t, connect().Migrator().HasTable("a"))
+}
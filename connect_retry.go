@@ -0,0 +1,88 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxConnectBackoff ограничивает интервал между попытками подключения при экспоненциальном увеличении.
+const maxConnectBackoff = 30 * time.Second
+
+type connectRetryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// delay возвращает интервал перед попыткой attempt + 1: backoff, удваиваемый с каждой попыткой, со случайной добавкой
+// до половины интервала.
+func (p connectRetryPolicy) delay(attempt int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < attempt && delay < maxConnectBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxConnectBackoff)
+
+	if delay/2 <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// connect подключается к базе данных сервиса. Если задан WithConnectRetry, соединение проверяется запросом ping, а при
+// ошибке подключение повторяется до maxAttempts раз; ожидание между попытками прерывается отменой ctx. Без
+// WithConnectRetry возвращается результат ConnectFunc без проверки, как и до появления опции.
+func (m *MigrationManager) connect(ctx context.Context, serviceName string, service *ServiceInfo) (*gorm.DB, error) {
+	if m.connectRetry.maxAttempts <= 1 {
+		return service.open(), nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		db := service.open()
+		err := pingConnection(ctx, db)
+		if err == nil {
+			return db, nil
+		}
+
+		if db != nil {
+			service.DisconnectFunc(db)
+		}
+
+		if attempt >= m.connectRetry.maxAttempts {
+			return nil, fmt.Errorf("fail to connect to service %s after %d attempts: %w", serviceName, attempt, err)
+		}
+
+		delay := m.connectRetry.delay(attempt)
+		m.logger.Warn(
+			fmt.Sprintf(
+				"fail to connect, attempt %d of %d, retrying in %s, service: %s, err: %s",
+				attempt, m.connectRetry.maxAttempts, delay, serviceName, err,
+			),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connect to service %s interrupted: %w", serviceName, errors.Join(ctx.Err(), err))
+		case <-time.After(delay):
+		}
+	}
+}
+
+func pingConnection(ctx context.Context, db *gorm.DB) error {
+	if db == nil {
+		return errors.New("connect function returned no connection")
+	}
+	if db.Error != nil {
+		return db.Error
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
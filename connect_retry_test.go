@@ -0,0 +1,113 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newFlakyConnection возвращает функции подключения, первые failures вызовов которых возвращают закрытое соединение,
+// не проходящее проверку ping. onFailure вызывается при каждом неудачном подключении. connects и disconnects -
+// количество вызовов подключения и отключения.
+func newFlakyConnection(t *testing.T, failures int, onFailure func()) (connect func() *gorm.DB, disconnect func(*gorm.DB), connects *int, disconnects *int) {
+	t.Helper()
+
+	testConnect, _ := newTestDatabase(t)
+	connects, disconnects = new(int), new(int)
+
+	connect = func() *gorm.DB {
+		*connects++
+		db := testConnect()
+		if *connects <= failures {
+			sqlDb, err := db.DB()
+			require.NoError(t, err)
+			require.NoError(t, sqlDb.Close())
+			if onFailure != nil {
+				onFailure()
+			}
+		}
+		return db
+	}
+	disconnect = func(db *gorm.DB) {
+		*disconnects++
+		if sqlDb, err := db.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+	}
+	return connect, disconnect, connects, disconnects
+}
+
+func TestConnectRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		wantErr     bool
+		// wantConnects - количество попыток подключения
+		wantConnects int
+	}{
+		{name: "no failures", failures: 0, maxAttempts: 3, wantConnects: 1},
+		{name: "fails then succeeds", failures: 2, maxAttempts: 3, wantConnects: 3},
+		{name: "attempts exhausted", failures: 3, maxAttempts: 3, wantErr: true, wantConnects: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connect, disconnect, connects, disconnects := newFlakyConnection(t, tt.failures, nil)
+
+			m, err := NewMigrationsManager(WithConnectRetry(tt.maxAttempts, time.Millisecond))
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.0"))
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			))
+
+			err = m.MigrateContext(context.Background(), "service1", RunOptions{})
+			require.Equal(t, tt.wantConnects, *connects)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "fail to connect to service service1 after 3 attempts")
+				require.ErrorContains(t, err, "sql: database is closed")
+				// каждое неудачное соединение закрывается
+				require.Equal(t, tt.failures, *disconnects)
+				return
+			}
+
+			require.NoError(t, err)
+			// неудачные соединения и соединение запуска
+			require.Equal(t, tt.failures+1, *disconnects)
+		})
+	}
+}
+
+func TestConnectRetryCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// отмена после первой неудачной попытки прерывает ожидание перед второй
+	connect, disconnect, connects, _ := newFlakyConnection(t, 1, cancel)
+
+	m, err := NewMigrationsManager(WithConnectRetry(3, time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.0"))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	err = m.MigrateContext(ctx, "service1", RunOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "connect to service service1 interrupted")
+	require.Equal(t, 1, *connects)
+}
+
+func TestConnectRetryDelay(t *testing.T) {
+	policy := connectRetryPolicy{maxAttempts: 10, backoff: time.Second}
+
+	for attempt, base := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: maxConnectBackoff} {
+		delay := policy.delay(attempt)
+		require.GreaterOrEqual(t, delay, base)
+		require.Less(t, delay, base+base/2)
+	}
+}
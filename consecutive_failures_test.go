@@ -0,0 +1,75 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestFailureWarnAndContinueUntilNConsecutive(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0")
+
+	fail := false
+	runs := 0
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType:          TypeRepeatable,
+			Version:                "1.0.0",
+			IsTransactional:        true,
+			OnFailure:              FailureWarnAndContinueUntilNConsecutive,
+			MaxConsecutiveFailures: 2,
+			// контрольная сумма меняется при каждом запуске, поэтому миграция выполняется каждым Migrate
+			CheckSum: func(*gorm.DB) string { return fmt.Sprintf("run%d", runs) },
+			UpF: func(*gorm.DB, map[string]*gorm.DB) error {
+				if fail {
+					return errors.New("refresh failed")
+				}
+				return nil
+			},
+		},
+	))
+
+	repeatable := func() models.MigrationModel {
+		t.Helper()
+
+		savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+		require.NoError(t, err)
+		for _, migrationModel := range savedMigrations {
+			if migrationModel.Type == string(TypeRepeatable) {
+				return migrationModel
+			}
+		}
+		require.FailNow(t, "repeatable migration is not saved")
+		return models.MigrationModel{}
+	}
+
+	migrate := func(failing bool) error {
+		fail = failing
+		runs++
+		return m.Migrate("service1")
+	}
+
+	require.NoError(t, migrate(true))
+	require.Equal(t, models.StateFailedAllowed, repeatable().State)
+	require.Equal(t, 1, repeatable().FailedAttempts)
+
+	// успешное выполнение сбрасывает счетчик последовательных ошибок
+	require.NoError(t, migrate(false))
+	require.Equal(t, models.StateSuccess, repeatable().State)
+	require.Zero(t, repeatable().FailedAttempts)
+
+	require.NoError(t, migrate(true))
+	require.Equal(t, models.StateFailedAllowed, repeatable().State)
+	require.Equal(t, 1, repeatable().FailedAttempts)
+
+	// вторая ошибка подряд прерывает Migrate
+	require.ErrorContains(t, migrate(true), "refresh failed")
+	require.Equal(t, models.StateFailure, repeatable().State)
+	require.Equal(t, 2, repeatable().FailedAttempts)
+}
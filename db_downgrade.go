@@ -1,51 +1,239 @@
 package db_migrator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Maksumys/db-migrator/internal/models"
 	"github.com/Maksumys/db-migrator/internal/repository"
 	"gorm.io/gorm"
 	"sort"
+	"time"
 )
 
-// Downgrade осуществляет отмену успешно выполненных или пропущенных миграций в обратном порядке.
+// Downgrade выполняет DowngradeContext с фоновым контекстом и параметрами запуска по умолчанию.
+//
+// Deprecated: используйте DowngradeContext.
+func (m *MigrationManager) Downgrade(serviceName string) error {
+	return m.DowngradeContext(context.Background(), serviceName, RunOptions{})
+}
+
+// DowngradeWithOptions выполняет DowngradeContext с фоновым контекстом.
+//
+// Deprecated: используйте DowngradeContext.
+func (m *MigrationManager) DowngradeWithOptions(serviceName string, opts RunOptions) error {
+	return m.DowngradeContext(context.Background(), serviceName, opts)
+}
+
+// DowngradeTo выполняет DowngradeContext с фоновым контекстом, отменяя миграции выше версии version вместо целевой
+// версии сервиса (см. RunOptions.TargetVersion).
+func (m *MigrationManager) DowngradeTo(serviceName string, version string) error {
+	return m.DowngradeContext(context.Background(), serviceName, RunOptions{TargetVersion: version})
+}
+
+// DowngradeSteps выполняет DowngradeContext с фоновым контекстом, отменяя ровно steps последних выполненных миграций
+// типа TypeVersioned независимо от целевой версии сервиса (см. RunOptions.Steps).
+func (m *MigrationManager) DowngradeSteps(serviceName string, steps int) error {
+	if steps <= 0 {
+		return m.misuse(fmt.Errorf("downgrade steps must be positive, got %d", steps))
+	}
+	return m.DowngradeContext(context.Background(), serviceName, RunOptions{Steps: steps})
+}
+
+// DowngradeContext осуществляет отмену успешно выполненных или пропущенных миграций в обратном порядке.
 // Миграции типа TypeRepeatable и TypeBaseline не отменяются.
-// Новые миграции при вызове Downgrade не сохраняются.
+// Новые миграции при вызове DowngradeContext не сохраняются.
 //
-// Паникует в случае, если какая-либо из миграций не была найдена.
-func (m *MigrationManager) Downgrade(serviceName string) (err error) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// Возвращает ошибку в случае, если какая-либо из миграций не была найдена или для нее не заданы Down и DownF; в
+// этом случае ни одна миграция не отменяется. При отмене ctx выполнение прерывается перед отменой следующей миграции.
+func (m *MigrationManager) DowngradeContext(ctx context.Context, serviceName string, opts RunOptions) error {
+	service, ok := m.service(serviceName)
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	if opts.Steps < 0 {
+		return m.misuse(fmt.Errorf("downgrade steps must not be negative, got %d", opts.Steps))
+	}
+	if opts.Steps > 0 && len(opts.TargetVersion) > 0 {
+		return m.misuse(fmt.Errorf("downgrade steps and target version are mutually exclusive"))
+	}
 
-	service, ok := m.services[serviceName]
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	return m.downgradeWithOptions(ctx, serviceName, opts)
+}
+
+func (m *MigrationManager) downgradeWithOptions(ctx context.Context, serviceName string, opts RunOptions) (err error) {
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
-	service.Db = service.ConnectFunc()
+	labels, err := m.runLabels(opts)
+	if err != nil {
+		return err
+	}
+
+	service.runLabels = labels
 	defer func() {
+		service.runLabels = nil
+	}()
+
+	if len(opts.TargetVersion) > 0 {
+		service.runTargetVersion, err = m.parseRunTargetVersion(serviceName, opts.TargetVersion)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			service.runTargetVersion = nil
+		}()
+	}
+
+	m.audit(AuditEvent{Event: AuditRunStarted, Service: serviceName, Direction: DirectionDown})
+	defer func() {
+		m.audit(AuditEvent{Event: AuditRunFinished, Service: serviceName, Direction: DirectionDown, Error: errorString(err)})
+	}()
+
+	service.Db, err = m.connect(ctx, serviceName, service)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		m.closeAuxiliaryConnections(serviceName)
 		service.DisconnectFunc(service.Db)
 	}()
 
+	m.identifyConnection(service.Db)
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	releaseLock, err := m.acquireProcessLock(ctx, service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+
 	m.logger.Info("preparing downgrade execution")
 
-	if !repository.HasVersionTable(service.Db) || !repository.HasVersionTable(service.Db) {
+	err = m.adoptUnscopedTables(serviceName, service.Db)
+	if err != nil {
+		return err
+	}
+
+	if !repository.HasVersionTable(service.Db) || !repository.HasMigrationsTable(service.Db) {
 		return fmt.Errorf("no migration table or Version table found, cannot perform downgrade")
 	}
 
-	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderDESC)
+	err = repository.MigrateVersionTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = m.repairVersionTable(serviceName, service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = repository.MigrateMigrationsTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = repository.CreateStateHistoryTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = repository.CreateVersionHistoryTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	if repository.HasMetaTable(service.Db) {
+		err = m.claimDatabase(service.Db, serviceName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.checkRunDirection(service.Db, serviceName, DirectionDown, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkSchemaDrift(service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+
+	run := m.startRun(service.Db, serviceName, DirectionDown)
+	defer func() {
+		m.finishRun(service.Db, serviceName, run, err)
+	}()
+
+	keys, err := repository.GetMigrationKeys(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkInterrupted(service.Db, serviceName, keys, opts.ResumeInterrupted)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.downgradeMigrations(serviceName, downgradeBoundary(service, opts.Steps))
+	if err != nil {
+		return err
+	}
+
+	plan, err := m.planDowngrade(serviceName, savedMigrations, opts.Steps)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkDowngradable(serviceName, plan)
 	if err != nil {
 		return err
 	}
 
-	plan, err := m.planDowngrade(serviceName)
+	run.PlanHash = planHash(plan)
+
+	plannedMigrations, err := m.plannedMigrations(serviceName, plan, DirectionDown)
 	if err != nil {
 		return err
 	}
 
+	m.logPlan(serviceName, DirectionDown, plannedMigrations)
+
+	if m.downgradeNeedsConfirmation(plan.Len()) {
+		err = m.confirm(ConfirmationRequest{
+			Operation:   OperationDowngrade,
+			ServiceName: serviceName,
+			Versions:    migrationVersions(plan.Migrations()),
+			Migrations:  plannedMigrations,
+			Impact: fmt.Sprintf(
+				"%d migrations will be undone, resulting version: %s",
+				plan.Len(), previousVersion(plan.Migrations()[plan.Len()-1], savedMigrations),
+			),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	for !plan.IsEmpty() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		migrationModel := plan.PopFirst()
 
 		migration, ok, err := m.findMigration(serviceName, migrationModel)
@@ -55,14 +243,30 @@ func (m *MigrationManager) Downgrade(serviceName string) (err error) {
 		}
 
 		if !ok {
-			return fmt.Errorf(
-				"migration (type: %s, Version: %s) not found",
-				migrationModel.Type, migrationModel.Version,
-			)
+			return m.migrationNotFound(serviceName, migrationModel.Type, migrationModel.Version)
 		}
 
+		m.audit(AuditEvent{
+			Event:         AuditMigrationStarted,
+			Service:       serviceName,
+			Direction:     DirectionDown,
+			MigrationType: migrationModel.Type,
+			Version:       migrationModel.Version.String(),
+		})
+
+		startedAt := time.Now()
 		err = m.executeDowngrade(serviceName, migrationModel, migration)
 		if err != nil {
+			run.Failed++
+			m.audit(AuditEvent{
+				Event:         AuditMigrationFailed,
+				Service:       serviceName,
+				Direction:     DirectionDown,
+				MigrationType: migrationModel.Type,
+				Version:       migrationModel.Version.String(),
+				DurationMs:    time.Since(startedAt).Milliseconds(),
+				Error:         err.Error(),
+			})
 			return err
 		}
 
@@ -70,6 +274,16 @@ func (m *MigrationManager) Downgrade(serviceName string) (err error) {
 		if err != nil {
 			return err
 		}
+
+		run.Applied++
+		m.audit(AuditEvent{
+			Event:         AuditMigrationUndone,
+			Service:       serviceName,
+			Direction:     DirectionDown,
+			MigrationType: migrationModel.Type,
+			Version:       migrationModel.Version.String(),
+			DurationMs:    time.Since(startedAt).Milliseconds(),
+		})
 	}
 
 	m.logger.Info("Downgrade completed")
@@ -77,26 +291,72 @@ func (m *MigrationManager) Downgrade(serviceName string) (err error) {
 	return
 }
 
-func (m *MigrationManager) planDowngrade(serviceName string) (migrationsPlan, error) {
-	savedMigrations, err := m.saveNewMigrations(serviceName)
+// planDowngrade составляет план отката. При steps = 0 отменяются миграции выше целевой версии сервиса, иначе - ровно
+// steps последних выполненных миграций независимо от целевой версии; если столько миграций отменить нельзя,
+// возвращается ошибка. Новые миграции при этом не сохраняются.
+func (m *MigrationManager) planDowngrade(serviceName string, savedMigrations []models.MigrationModel, steps int) (migrationsPlan, error) {
+	inputs, err := m.planInputs(serviceName, savedMigrations)
 	if err != nil {
 		return migrationsPlan{}, err
 	}
 
-	planner := downgradePlanner{
-		manager:         m,
-		savedMigrations: savedMigrations,
+	if steps > 0 {
+		inputs.targetVersion = models.Version{}
+	}
+
+	planner := downgradePlanner{inputs: inputs, steps: steps}
+	plan, err := planner.MakePlan()
+	if err != nil {
+		return migrationsPlan{}, err
+	}
+
+	if steps > 0 && plan.Len() < steps {
+		return migrationsPlan{}, fmt.Errorf(
+			"cannot downgrade %d steps, service %s has only %d applied versioned migrations", steps, serviceName, plan.Len(),
+		)
+	}
+
+	return plan, nil
+}
+
+// downgradeBoundary возвращает версию, выше которой выбираются сохраненные миграции для отката (см.
+// downgradeMigrations): при steps > 0 граница не ограничивает откат.
+func downgradeBoundary(service *ServiceInfo, steps int) models.Version {
+	if steps > 0 {
+		return models.Version{}
+	}
+	return service.targetVersion()
+}
+
+// checkDowngradable проверяет до начала отката, что все миграции плана зарегистрированы и могут быть отменены.
+func (m *MigrationManager) checkDowngradable(serviceName string, plan migrationsPlan) error {
+	for _, migrationModel := range plan.Migrations() {
+		migration, ok, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return m.migrationNotFound(serviceName, migrationModel.Type, migrationModel.Version)
+		}
+
+		if !migration.hasDown() {
+			return fmt.Errorf(
+				"migration (type: %s, Version: %s) cannot be downgraded, because Down, DownSource and DownF is empty",
+				migrationModel.Type, migrationModel.Version,
+			)
+		}
 	}
 
-	return planner.MakePlan(serviceName)
+	return nil
 }
 
-func (m *MigrationManager) executeDowngrade(serviceName string, migrationModel models.MigrationModel, migration *Migration) error {
-	service, ok := m.services[serviceName]
+func (m *MigrationManager) executeDowngrade(serviceName string, migrationModel models.MigrationModel, migration *Migration) (err error) {
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Info(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	m.logger.Info(
@@ -104,21 +364,46 @@ func (m *MigrationManager) executeDowngrade(serviceName string, migrationModel m
 			"downgrading %s migration: Version %s. State: %s",
 			migrationModel.Type, migrationModel.Version, migrationModel.State,
 		),
+		m.executedByAttrs(serviceName)...,
 	)
 
 	if migration.MigrationType != TypeVersioned {
 		return fmt.Errorf("versioned migration must satisfy VersionedMigrator interface")
 	}
-	if len(migration.Down) == 0 && migration.DownF == nil {
-		return fmt.Errorf("fail to downgrade, because Down and DownF is empty")
+	if !migration.hasDown() {
+		return fmt.Errorf("fail to downgrade, because Down, DownSource and DownF is empty")
+	}
+
+	info := m.migrationInfo(serviceName, migration, DirectionDown)
+	err = m.beforeMigration(serviceName, info)
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	defer func() {
+		m.afterMigration(serviceName, info, startedAt, err)
+	}()
+
+	auxiliaryDb, err := m.auxiliaryConnections(serviceName, migration)
+	if err != nil {
+		return err
 	}
 
 	if migration.IsTransactional {
 		err := service.Db.Transaction(func(tx *gorm.DB) error {
-			if len(migration.Down) > 0 {
-				return tx.Exec(migration.Down).Error
+			tx = migration.session(tx)
+			if migration.hasDownSQL() {
+				down, err := migration.downSQL()
+				if err != nil {
+					return err
+				}
+				if err := tx.Exec(down).Error; err != nil {
+					return downgradeExecError(migration, down, err)
+				}
+				return nil
 			} else {
-				return migration.DownF(tx, nil)
+				return migration.DownF(tx, auxiliaryDb)
 			}
 		})
 
@@ -127,40 +412,45 @@ func (m *MigrationManager) executeDowngrade(serviceName string, migrationModel m
 			return err
 		}
 	} else {
-		db, err := service.Db.DB()
+		exec, err := migration.execer(service.Db)
 		if err != nil {
 			return err
 		}
 
-		if len(migration.Down) > 0 {
-			_, err = db.Exec(migration.Down)
-			if err != nil {
-				return err
+		restoreState, err := markRunning(service.Db, migrationModel)
+		if err != nil {
+			return err
+		}
+
+		if migration.hasDownSQL() {
+			var down string
+			down, err = migration.downSQL()
+			if err == nil {
+				if _, err = exec(down); err != nil {
+					err = downgradeExecError(migration, down, err)
+				}
 			}
 		} else {
-			return migration.DownF(service.Db, nil)
+			err = migration.DownF(migration.session(service.Db), auxiliaryDb)
+		}
+		if err != nil {
+			return errors.Join(err, restoreState())
 		}
 	}
 
-	m.logger.Info("downgrade complete")
+	m.logger.Info("downgrade complete", m.executedByAttrs(serviceName)...)
 	return nil
 }
 
 func (m *MigrationManager) saveStateAfterDowngrading(serviceName string, savedMigrations []models.MigrationModel, migrationModel models.MigrationModel, migration *Migration) error {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
-	if migration.CheckSum == nil {
-		migration.CheckSum = func(db *gorm.DB) string {
-			return ""
-		}
-	}
-
-	err := repository.UpdateMigrationStateExecuted(service.Db, &migrationModel, models.StateUndone, migration.CheckSum(service.Db))
+	err := transitionExecuted(service.Db, &migrationModel, models.StateUndone, "downgraded", migration.checksum(service.Db), m.executedBy(service))
 	if err != nil {
 		return err
 	}
@@ -173,13 +463,25 @@ func (m *MigrationManager) saveVersionDowngrade(
 	migrationModel models.MigrationModel,
 	savedMigrations []models.MigrationModel,
 ) error {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	source := repository.VersionSource{
+		Version:   migrationModel.Version.String(),
+		Type:      VersionSetByUndo,
+		Rank:      migrationModel.Rank,
+		Direction: DirectionDown,
 	}
 
+	return repository.SaveVersion(service.Db, previousVersion(migrationModel, savedMigrations), source)
+}
+
+// previousVersion возвращает версию, которая будет сохранена после отката миграции migrationModel.
+func previousVersion(migrationModel models.MigrationModel, savedMigrations []models.MigrationModel) models.Version {
 	// фильтруем миграции типа TypeRepeatable
 	filteredMigrations := make([]models.MigrationModel, 0, len(savedMigrations))
 	for i := range savedMigrations {
@@ -209,5 +511,15 @@ func (m *MigrationManager) saveVersionDowngrade(
 		}
 	}
 
-	return repository.SaveVersion(service.Db, versionToSave)
+	return versionToSave
+}
+
+// downgradeExecError оборачивает ошибку выполнения Down в MigrationExecError.
+func downgradeExecError(migration *Migration, statement string, err error) error {
+	return &MigrationExecError{
+		Version:   migration.Version,
+		Type:      string(migration.MigrationType),
+		Statement: statement,
+		Err:       err,
+	}
 }
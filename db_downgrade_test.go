@@ -0,0 +1,95 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func downgradeTestMigrations(down103 string) []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table b(id int)", Down: "drop table b"},
+		{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "create table c(id int)", Down: "drop table c"},
+		{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "create table d(id int)", Down: down103},
+	}
+}
+
+// TestDowngradeStepsAtTargetVersion проверяет, что DowngradeSteps отменяет последние миграции, когда целевая версия
+// сервиса совпадает с сохраненной версией, и сохраняет предыдущую версию.
+func TestDowngradeStepsAtTargetVersion(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.3")
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.NoError(t, m.DowngradeSteps("service1", 2))
+
+	db := connect()
+	require.True(t, db.Migrator().HasTable("b"))
+	require.False(t, db.Migrator().HasTable("c"))
+	require.False(t, db.Migrator().HasTable("d"))
+
+	version, err := repository.GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", version.String())
+
+	require.NoError(t, m.DowngradeSteps("service1", 1))
+	version, err = repository.GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", version.String())
+	require.False(t, db.Migrator().HasTable("b"))
+}
+
+// TestDowngradeStepsMoreThanApplied проверяет, что DowngradeSteps не отменяет миграции, если выполненных миграций
+// меньше запрошенного количества.
+func TestDowngradeStepsMoreThanApplied(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.3")
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.ErrorContains(t, m.DowngradeSteps("service1", 4), "has only 3 applied versioned migrations")
+
+	db := connect()
+	require.True(t, db.Migrator().HasTable("d"))
+	version, err := repository.GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.3.0", version.String())
+
+	require.Error(t, m.DowngradeSteps("service1", 0))
+	require.Error(t, m.DowngradeContext(context.Background(), "service1", RunOptions{Steps: 1, TargetVersion: "1.0.2"}))
+}
+
+// TestDowngradeRequiresDown проверяет, что откат через миграцию без Down и DownF завершается ошибкой до отмены
+// каких-либо миграций.
+func TestDowngradeRequiresDown(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.3")
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.ErrorContains(t, m.DowngradeTo("service1", "1.0.1"), "cannot be downgraded")
+	require.ErrorContains(t, m.DowngradeSteps("service1", 1), "cannot be downgraded")
+
+	db := connect()
+	require.True(t, db.Migrator().HasTable("c"))
+	require.True(t, db.Migrator().HasTable("d"))
+	version, err := repository.GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.3.0", version.String())
+}
+
+// TestDowngradeWithoutMigrationsTable проверяет, что откат базы данных без таблицы migrations завершается ошибкой и не
+// создает таблицу.
+func TestDowngradeWithoutMigrationsTable(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.3")
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+	require.NoError(t, db.Migrator().DropTable(repository.MigrationsTable(db)))
+
+	require.ErrorContains(t, m.DowngradeSteps("service1", 1), "no migration table or Version table found")
+	require.False(t, repository.HasMigrationsTable(db))
+	require.True(t, db.Migrator().HasTable("d"))
+}
@@ -1,127 +1,506 @@
 package db_migrator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Maksumys/db-migrator/internal/models"
 	"github.com/Maksumys/db-migrator/internal/repository"
 	"gorm.io/gorm"
 	"sort"
+	"time"
 )
 
-// Migrate сохраняет и выполняет миграции в нужном порядке. Для этого на первом шаге создаются системные таблицы Version
-// и migrations, затем определяется необходимость проведения миграции типа TypeBaseline, после чего выполняются миграции
-// типов TypeVersioned. Миграции типа TypeRepeatable выполняются в последнюю очередь.
+// Migrate выполняет MigrateContext с фоновым контекстом и параметрами запуска по умолчанию.
+//
+// Deprecated: используйте MigrateContext.
+func (m *MigrationManager) Migrate(serviceName string) error {
+	return m.MigrateContext(context.Background(), serviceName, RunOptions{})
+}
+
+// MigrateWithOptions выполняет MigrateContext с фоновым контекстом.
+//
+// Deprecated: используйте MigrateContext.
+func (m *MigrationManager) MigrateWithOptions(serviceName string, opts RunOptions) error {
+	return m.MigrateContext(context.Background(), serviceName, opts)
+}
+
+// MigrateContext сохраняет и выполняет миграции в нужном порядке. Для этого на первом шаге создаются системные таблицы
+// Version и migrations, затем определяется необходимость проведения миграции типа TypeBaseline, после чего выполняются
+// миграции типов TypeVersioned. Миграции типа TypeRepeatable выполняются в последнюю очередь.
 // Все зарегистрированные миграции сохраняются в таблицу migrations. Миграции считаются новыми по инедтификатору
 // f(версия, тип миграции).
 //
-// Паникует при попытке сохранить миграцию с версией меньшей, чем уже сохраненные.
-// Паникует в случае, если какая-либо из необходимых в рамках выполнения операции миграций не была найдена.
-func (m *MigrationManager) Migrate(serviceName string) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// Возвращает ошибку при попытке сохранить миграцию с версией меньшей, чем уже сохраненные, и в случае, если
+// какая-либо из необходимых в рамках выполнения операции миграций не была найдена. При отмене ctx выполнение
+// прерывается перед следующей миграцией.
+//
+// Если миграция заблокирована другим экземпляром приложения и задана опция WithLockWaitPolicy, ожидает завершения
+// миграций другим экземпляром и возвращает nil, если по истечении ожидания миграции выполнены.
+//
+// Отчет о выполнении возвращает MigrateWithReport.
+func (m *MigrationManager) MigrateContext(ctx context.Context, serviceName string, opts RunOptions) error {
+	_, err := m.MigrateWithReport(ctx, serviceName, opts)
+	return err
+}
 
-	service, ok := m.services[serviceName]
+// migrateWithOptions выполняет миграции сервиса и заполняет report результатами обработки миграций.
+func (m *MigrationManager) migrateWithOptions(
+	ctx context.Context,
+	serviceName string,
+	opts RunOptions,
+	report *MigrationReport,
+) (err error) {
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	labels, err := m.runLabels(opts)
+	if err != nil {
+		return err
 	}
 
-	service.Db = service.ConnectFunc()
+	service.runLabels = labels
 	defer func() {
+		service.runLabels = nil
+	}()
+
+	if len(opts.TargetVersion) > 0 {
+		service.runTargetVersion, err = m.parseRunTargetVersion(serviceName, opts.TargetVersion)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			service.runTargetVersion = nil
+		}()
+	}
+
+	m.audit(AuditEvent{Event: AuditRunStarted, Service: serviceName, Direction: DirectionUp})
+	defer func() {
+		m.audit(AuditEvent{Event: AuditRunFinished, Service: serviceName, Direction: DirectionUp, Error: errorString(err)})
+	}()
+
+	phases := &report.Phases
+	timer := &phaseTimer{}
+	timer.start(&phases.Connect)
+
+	err = m.ensureDatabase(serviceName)
+	if err != nil {
+		return err
+	}
+
+	service.runPhases = phases
+	service.Db, err = m.connect(ctx, serviceName, service)
+	if err != nil {
+		service.runPhases = nil
+		return err
+	}
+	defer func() {
+		timer.start(&phases.Cleanup)
+		m.closeAuxiliaryConnections(serviceName)
 		service.DisconnectFunc(service.Db)
+		timer.stop()
+		service.runPhases = nil
+
+		m.logSlowPhases(serviceName, *phases)
 	}()
 
-	m.logger.Info("preparing migrations execution")
+	m.identifyConnection(service.Db)
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	releaseLock, err := m.acquireProcessLock(ctx, service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+
+	timer.start(&phases.InitTables)
+
+	fingerprint, err := m.fingerprint(serviceName)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info(fmt.Sprintf("preparing migrations execution, binary fingerprint: %s", fingerprint))
+
+	err = m.initSystemTables(serviceName)
+	if err != nil {
+		return err
+	}
+
+	err = m.claimDatabase(service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkRunTargetVersion(serviceName)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkRunDirection(service.Db, serviceName, DirectionUp, opts)
+	if err != nil {
+		return err
+	}
 
-	err := m.initSystemTables(serviceName)
+	err = m.checkSchemaDrift(service.Db, serviceName)
 	if err != nil {
 		return err
 	}
 
+	run := m.startRun(service.Db, serviceName, DirectionUp)
+	defer func() {
+		timer.stop()
+		run.PhaseTimings = encodePhaseTimings(*phases)
+		m.finishRun(service.Db, serviceName, run, err)
+		report.FinalVersion = run.FinalVersion
+	}()
+
+	timer.start(&phases.SaveRegistrations)
+
 	savedMigrations, err := m.saveNewMigrations(serviceName)
 	if err != nil {
 		return err
 	}
 
-	plan, err := m.planMigrate(serviceName, savedMigrations)
+	report.AheadOfBinary, err = countMigrationsAhead(service.registeredMigrations, savedMigrations)
+	if err != nil {
+		return err
+	}
+
+	timer.start(&phases.Plan)
 
+	err = m.checkInterrupted(service.Db, serviceName, savedMigrations, opts.ResumeInterrupted)
 	if err != nil {
 		return err
 	}
 
-	for !plan.IsEmpty() {
-		migrationModel := plan.PopFirst()
+	err = m.checkChecksums(service.Db, serviceName, savedMigrations)
+	if err != nil {
+		return err
+	}
 
-		migration, ok, err := m.findMigration(serviceName, migrationModel)
+	planningMigrations, err := m.planningMigrations(serviceName)
+	if err != nil {
+		return err
+	}
 
+	plan, err := m.planMigrate(serviceName, planningMigrations)
+	if err != nil {
+		return err
+	}
+
+	// порядок по версии используется при сохранении состояния миграции TypeBaseline
+	sortByVersion(savedMigrations)
+
+	run.PlanHash = planHash(plan)
+	run.Skipped += len(plan.skipped)
+
+	plannedMigrations, err := m.plannedMigrations(serviceName, plan, DirectionUp)
+	if err != nil {
+		return err
+	}
+
+	err = m.profile.validatePlan(plannedMigrations)
+	if err != nil {
+		return err
+	}
+
+	m.logPlan(serviceName, DirectionUp, plannedMigrations)
+
+	err = m.ensureExtensions(serviceName, plan.Migrations())
+	if err != nil {
+		return err
+	}
+
+	timer.start(&phases.Execute)
+
+	for _, skipped := range plan.skipped {
+		err = repository.TransitionState(service.Db, &skipped.migrationModel, models.StateSkipped, skipped.historyReason())
 		if err != nil {
 			return err
 		}
 
-		if !ok {
-			if !m.allowBypassNotFound(migrationModel) {
-				return fmt.Errorf(
-					"migration (type: %s, Version: %s) not found\n",
-					migrationModel.Type, migrationModel.Version,
-				)
-			}
+		report.Entries = append(report.Entries, MigrationReportEntry{
+			Type:      MigrationType(skipped.migrationModel.Type),
+			Version:   skipped.migrationModel.Version.String(),
+			Outcome:   OutcomeSkipped,
+			Reason:    skipped.code,
+			StartedAt: time.Now(),
+		})
+	}
 
-			m.logger.Info(
-				fmt.Sprintf(
-					"migration (type: %s, Version: %s) not found, skipping",
-					migrationModel.Type, migrationModel.Version,
-				),
-			)
-			err = repository.UpdateMigrationState(service.Db, &migrationModel, models.StateNotFound)
-			if err != nil {
-				return err
-			}
+	// независимые миграции типа TypeRepeatable при RunOptions.RepeatableConcurrency > 1 выполняются параллельно
+	// после остальных миграций плана
+	concurrentRepeatables := make([]models.MigrationModel, 0)
+
+	for !plan.IsEmpty() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
+		migrationModel := plan.PopFirst()
+
+		if opts.RepeatableConcurrency > 1 && m.canRunConcurrently(serviceName, migrationModel) {
+			concurrentRepeatables = append(concurrentRepeatables, migrationModel)
 			continue
 		}
 
-		err = m.executeMigration(serviceName, migrationModel, migration)
-		if err != nil && !migration.IsAllowFailure {
-			return errors.Join(err, repository.UpdateMigrationState(service.Db, &migrationModel, models.StateFailure))
+		outcome, err := m.applyMigration(serviceName, service.Db, savedMigrations, migrationModel, opts)
+		outcome.addTo(run, report)
+		if err != nil {
+			return err
 		}
+	}
 
-		err = m.saveStateOnSuccessfulMigration(serviceName, savedMigrations, migrationModel, migration)
+	if len(concurrentRepeatables) > 0 {
+		outcome, err := m.applyRepeatablesConcurrently(ctx, serviceName, savedMigrations, concurrentRepeatables, opts)
+		outcome.addTo(run, report)
 		if err != nil {
 			return err
 		}
 	}
 
+	service.migrated = true
+	service.upToDate = true
+
 	m.logger.Info(fmt.Sprintf("migrations completed for service: %s, current repository Version is Up to date", serviceName))
 	return nil
 }
 
+// migrationOutcome - итог выполнения миграций, учитываемый в записи о запуске и отчете о выполнении.
+type migrationOutcome struct {
+	applied      int
+	failed       int
+	skipped      int
+	rowsAffected int64
+	entries      []MigrationReportEntry
+}
+
+func (o *migrationOutcome) add(other migrationOutcome) {
+	o.applied += other.applied
+	o.failed += other.failed
+	o.skipped += other.skipped
+	o.rowsAffected += other.rowsAffected
+	o.entries = append(o.entries, other.entries...)
+}
+
+func (o migrationOutcome) addTo(run *models.RunModel, report *MigrationReport) {
+	run.Applied += o.applied
+	run.Failed += o.failed
+	run.Skipped += o.skipped
+	run.RowsAffected += o.rowsAffected
+	report.Entries = append(report.Entries, o.entries...)
+}
+
+// applyMigration выполняет запланированную миграцию через соединение db и сохраняет ее состояние.
+func (m *MigrationManager) applyMigration(
+	serviceName string,
+	db *gorm.DB,
+	savedMigrations []models.MigrationModel,
+	migrationModel models.MigrationModel,
+	opts RunOptions,
+) (migrationOutcome, error) {
+	var outcome migrationOutcome
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+
+	if err != nil {
+		return outcome, err
+	}
+
+	if !ok {
+		if !m.allowBypassNotFound(migrationModel) {
+			return outcome, m.migrationNotFound(serviceName, migrationModel.Type, migrationModel.Version)
+		}
+
+		m.logger.Info(
+			fmt.Sprintf(
+				"migration (type: %s, Version: %s) not found, skipping",
+				migrationModel.Type, migrationModel.Version,
+			),
+		)
+		err = repository.TransitionState(db, &migrationModel, models.StateNotFound, "migration not registered")
+		if err != nil {
+			return outcome, err
+		}
+
+		outcome.skipped++
+		outcome.entries = append(outcome.entries, MigrationReportEntry{
+			Type:      MigrationType(migrationModel.Type),
+			Version:   migrationModel.Version.String(),
+			Outcome:   OutcomeNotFound,
+			StartedAt: time.Now(),
+		})
+		return outcome, nil
+	}
+
+	m.audit(AuditEvent{
+		Event:         AuditMigrationStarted,
+		Service:       serviceName,
+		Direction:     DirectionUp,
+		MigrationType: migrationModel.Type,
+		Version:       migrationModel.Version.String(),
+	})
+
+	startedAt := time.Now()
+	rowsAffected, err := m.executeMigration(serviceName, db, migrationModel, migration, opts.ResumeFromLastStatement)
+
+	outcome.rowsAffected += rowsAffected
+
+	entry := MigrationReportEntry{
+		Type:         MigrationType(migrationModel.Type),
+		Version:      migrationModel.Version.String(),
+		StartedAt:    startedAt,
+		Duration:     time.Since(startedAt),
+		RowsAffected: rowsAffected,
+	}
+	report := func(migrationOutcome MigrationOutcome) {
+		entry.Outcome = migrationOutcome
+		outcome.entries = append(outcome.entries, entry)
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+		outcome.failed++
+		m.audit(AuditEvent{
+			Event:         AuditMigrationFailed,
+			Service:       serviceName,
+			Direction:     DirectionUp,
+			MigrationType: migrationModel.Type,
+			Version:       migrationModel.Version.String(),
+			DurationMs:    time.Since(startedAt).Milliseconds(),
+			Error:         err.Error(),
+		})
+	}
+
+	if errors.Is(err, ErrAbortedByHook) {
+		report(OutcomeFailed)
+		return outcome, err
+	}
+	if err != nil && migration.OnFailure != FailureAbort {
+		err = m.saveStateOnAllowedFailure(serviceName, migrationModel, migration, err)
+		if err != nil {
+			report(OutcomeFailed)
+			return outcome, err
+		}
+		report(OutcomeFailedAllowed)
+		return outcome, nil
+	}
+	if err != nil && !migration.IsAllowFailure {
+		report(OutcomeFailed)
+		return outcome, errors.Join(err, repository.TransitionState(db, &migrationModel, models.StateFailure, "migration failed"))
+	}
+
+	err = repository.UpdateMigrationRowsAffected(db, &migrationModel, rowsAffected)
+	if err != nil {
+		report(OutcomeFailed)
+		return outcome, err
+	}
+
+	err = m.saveStateOnSuccessfulMigration(serviceName, savedMigrations, migrationModel, migration)
+	if err != nil {
+		report(OutcomeFailed)
+		return outcome, err
+	}
+
+	report(OutcomeExecuted)
+
+	outcome.applied++
+	m.audit(AuditEvent{
+		Event:         AuditMigrationSucceeded,
+		Service:       serviceName,
+		Direction:     DirectionUp,
+		MigrationType: migrationModel.Type,
+		Version:       migrationModel.Version.String(),
+		Checksum:      migration.checksum(db),
+		DurationMs:    time.Since(startedAt).Milliseconds(),
+	})
+
+	return outcome, nil
+}
+
 func (m *MigrationManager) planMigrate(serviceName string, savedMigrations []models.MigrationModel) (migrationsPlan, error) {
-	planner := migratePlanner{
-		manager:         m,
-		savedMigrations: savedMigrations,
+	inputs, err := m.planInputs(serviceName, savedMigrations)
+	if err != nil {
+		return migrationsPlan{}, err
 	}
-	return planner.MakePlan(serviceName)
+
+	planner := migratePlanner{inputs: inputs}
+	return planner.MakePlan()
+}
+
+// sortByVersion упорядочивает сохраненные миграции по версии, сохраняя порядок миграций с одинаковой версией.
+func sortByVersion(savedMigrations []models.MigrationModel) {
+	sort.SliceStable(savedMigrations, func(i, j int) bool {
+		return savedMigrations[j].Version.MoreThan(savedMigrations[i].Version)
+	})
 }
 
 func (m *MigrationManager) initSystemTables(serviceName string) error {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	err := m.adoptUnscopedTables(serviceName, service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = repository.CreateSchema(service.Db)
+	if err != nil {
+		return err
 	}
 
 	hasVersionTable := repository.HasVersionTable(service.Db)
 	hasMigrationsTable := repository.HasMigrationsTable(service.Db)
 
+	if hasVersionTable {
+		diff, err := repository.CheckVersionTableColumns(service.Db)
+		if err != nil {
+			return err
+		}
+		if len(diff.Missing) > 0 {
+			return foreignTableError(repository.VersionTable(service.Db), diff)
+		}
+	}
+
+	if hasMigrationsTable {
+		diff, err := repository.CheckMigrationsTableColumns(service.Db)
+		if err != nil {
+			return err
+		}
+		if len(diff.Missing) > 0 {
+			return foreignTableError(repository.MigrationsTable(service.Db), diff)
+		}
+	}
+
 	if !hasVersionTable {
 		m.logger.Warn("table versions not found, creating")
 		err := repository.CreateVersionTable(service.Db)
 		if err != nil {
 			return err
 		}
+	} else {
+		err := repository.MigrateVersionTable(service.Db)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.repairVersionTable(serviceName, service.Db)
+	if err != nil {
+		return err
 	}
 
 	if !hasMigrationsTable {
@@ -130,31 +509,116 @@ func (m *MigrationManager) initSystemTables(serviceName string) error {
 		if err != nil {
 			return err
 		}
+	} else {
+		err := repository.MigrateMigrationsTable(service.Db)
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	err = repository.CreateStateHistoryTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = repository.CreateVersionHistoryTable(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = saveLibraryMeta(service.Db)
+	if err != nil {
+		return err
+	}
+
+	// признак мог не поддерживаться версией библиотеки, выполнявшей предыдущие запуски
+	return repository.SyncDirtyFlag(service.Db)
 }
 
+func foreignTableError(table string, diff repository.TableColumnsDiff) error {
+	return fmt.Errorf(
+		"%w: table %s, missing columns: %v, unexpected columns: %v; "+
+			"the table may belong to the application, consider configuring another system table name (WithSystemTableNames)",
+		ErrForeignMigrationsTable, table, diff.Missing, diff.Unexpected,
+	)
+}
+
+// saveNewMigrations сохраняет новые зарегистрированные миграции и возвращает ключевые поля сохраненных миграций (см.
+// repository.GetMigrationKeys) вместе с новыми миграциями.
 func (m *MigrationManager) saveNewMigrations(serviceName string) ([]models.MigrationModel, error) {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return nil, fmt.Errorf("service %s not found", serviceName)
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	// для проверок и сохранения новых миграций достаточно ключевых полей, полные строки читает planningMigrations
+	savedMigrations, err := repository.GetMigrationKeys(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRank, err := repository.GetMaxRank(service.Db)
+	if err != nil {
+		return nil, err
 	}
 
-	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderASC)
+	newMigrations, err := m.newMigrations(serviceName, savedMigrations, maxRank)
 	if err != nil {
 		return nil, err
 	}
 
-	maxRank := 0
-	for i := range savedMigrations {
-		if rank := savedMigrations[i].Rank; rank > maxRank {
-			maxRank = rank
+	for i := range newMigrations {
+		newMigrations[i].Description, err = encryptValue(m.encryptor, newMigrations[i].Description)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	err = service.Db.Transaction(func(tx *gorm.DB) error {
+		err := m.adoptReturnedRepeatables(tx, serviceName, savedMigrations)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := repository.SaveMigrations(tx, newMigrations, m.registrationBatchSize(tx))
+		if err != nil {
+			return err
+		}
+
+		for i := range migrations {
+			migrations[i].Description, err = decryptValue(m.encryptor, migrations[i].Description)
+			if err != nil {
+				return err
+			}
+		}
+
+		savedMigrations = append(savedMigrations, migrations...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return savedMigrations, nil
+}
+
+// newMigrations возвращает зарегистрированные миграции, еще не сохраненные в базе данных, упорядоченные по версии и
+// с назначенным рангом. Проверяет, что база данных не опережает приложение и что новые миграции не имеют версию ниже
+// уже сохраненных. Описания миграций не шифруются.
+func (m *MigrationManager) newMigrations(
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+	maxRank int,
+) ([]repository.SaveMigrationRequest, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
 	newMigrations := make([]repository.SaveMigrationRequest, 0, len(service.registeredMigrations))
 	for i := range service.registeredMigrations {
 		if migrationIsNew(service.registeredMigrations[i], savedMigrations) {
@@ -174,15 +638,40 @@ func (m *MigrationManager) saveNewMigrations(serviceName string) ([]models.Migra
 		}
 	}
 
-	// запрет на сохранение миграций с версией, которая ниже максимальной версии из уже зарегистрированных миграций
+	aheadCount, err := countMigrationsAhead(service.registeredMigrations, savedMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	if aheadCount > 0 {
+		if m.forbidOlderBinary {
+			return nil, fmt.Errorf("%w: database ahead of binary by %d migrations", ErrDatabaseAheadOfBinary, aheadCount)
+		}
+		m.logger.Warn(fmt.Sprintf("database ahead of binary by %d migrations, service: %s", aheadCount, serviceName))
+	}
+
+	// запрет на сохранение миграций с версией, которая ниже максимальной версии из уже зарегистрированных миграций.
+	// Проверяются только действительно новые миграции: если все зарегистрированные миграции уже сохранены (например,
+	// при откате приложения на предыдущую версию), ошибка не возвращается. С WithAllowOutOfOrder такие миграции
+	// сохраняются с признаком OutOfOrder.
 	for i := range newMigrations {
 		for j := range savedMigrations {
 			if savedMigrations[j].Version.MoreThan(newMigrations[i].Version) {
-				return nil, errors.New(fmt.Sprintf(
-					"attempting to register migration with lower Version than existing one, type: %s, version: %s",
+				if service.allowOutOfOrder {
+					m.logger.Warn(fmt.Sprintf(
+						"registering out-of-order migration (type: %s, Version: %s) below saved Version %s, service: %s",
+						newMigrations[i].Type, newMigrations[i].Version, savedMigrations[j].Version, serviceName,
+					))
+					newMigrations[i].OutOfOrder = true
+					break
+				}
+				return nil, fmt.Errorf(
+					"%w: type: %s, version: %s, saved version: %s",
+					ErrRegisteredVersionTooLow,
 					newMigrations[i].Type,
 					newMigrations[i].Version,
-				))
+					savedMigrations[j].Version,
+				)
 			}
 		}
 	}
@@ -191,152 +680,492 @@ func (m *MigrationManager) saveNewMigrations(serviceName string) ([]models.Migra
 		return newMigrations[i].Version.LessThan(newMigrations[j].Version)
 	})
 
-	err = service.Db.Transaction(func(tx *gorm.DB) error {
-		for i := range newMigrations {
-			newMigrations[i].Rank = maxRank + (i + 1)
-			migration, err := repository.SaveMigration(tx, newMigrations[i])
-
-			if err != nil {
-				return err
-			}
-
-			savedMigrations = append(savedMigrations, migration)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+	for i := range newMigrations {
+		newMigrations[i].Rank = maxRank + (i + 1)
 	}
 
-	return savedMigrations, nil
+	return newMigrations, nil
 }
 
-func (m *MigrationManager) executeMigration(serviceName string, migrationModel models.MigrationModel, migration *Migration) error {
-	service, ok := m.services[serviceName]
-
-	if !ok {
-		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+// registrationBatchSize возвращает размер пачки при сохранении новых миграций. Для sqlserver пакетная вставка не
+// используется из-за ограничения на количество параметров запроса.
+func (m *MigrationManager) registrationBatchSize(db *gorm.DB) int {
+	if db.Dialector.Name() == "sqlserver" {
+		return 1
 	}
+	return m.batchSize
+}
 
+// executeMigration выполняет миграцию и возвращает количество затронутых строк. При resume нетранзакционная миграция
+// продолжается с выражения, следующего за последним успешно выполненным.
+func (m *MigrationManager) executeMigration(
+	serviceName string,
+	db *gorm.DB,
+	migrationModel models.MigrationModel,
+	migration *Migration,
+	resume bool,
+) (rowsAffected int64, err error) {
 	m.logger.Info(
 		fmt.Sprintf(
 			"executing %s migration: Version %s. State: %s. Service %s.",
 			migrationModel.Type, migrationModel.Version, migrationModel.State, serviceName,
 		),
+		m.executedByAttrs(serviceName)...,
 	)
 
-	if len(migration.Up) == 0 && migration.UpF == nil || len(migration.Up) > 0 && migration.UpF != nil {
-		m.logger.Error(fmt.Sprintf("migration fail, because Up and upf is empty or both is not nil, service: %s", serviceName))
-		return errors.New("fail to migrate, because Up and upf is empty or both is not nil")
+	if err := migration.validateSources(); err != nil {
+		m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+		return 0, err
 	}
 
+	info := m.migrationInfo(serviceName, migration, DirectionUp)
+	err = m.beforeMigration(serviceName, info)
+	if err != nil {
+		return 0, err
+	}
+
+	startedAt := time.Now()
+	defer func() {
+		m.afterMigration(serviceName, info, startedAt, err)
+	}()
+
+	// соединения с зависимостями не сохраняются в ServiceInfo зависимостей, чтобы не пересекаться с операциями над
+	// ними, выполняемыми параллельно
 	depsServices := make(map[string]*ServiceInfo)
+	depsServicesDb := make(map[string]*gorm.DB)
 
+	// соединения зависимостей закрываются при любом завершении, в том числе при панике в пользовательских функциях,
+	// ошибки закрытия добавляются к возвращаемой ошибке
 	defer func() {
-		for _, v := range depsServices {
-			v.DisconnectFunc(v.Db)
+		for name, depsService := range depsServices {
+			if disconnectErr := disconnectDependency(name, depsService, depsServicesDb[name]); disconnectErr != nil {
+				m.logger.Error(fmt.Sprintf("fail to disconnect dependency %s, service: %s, err: %s", name, serviceName, disconnectErr))
+				err = errors.Join(err, disconnectErr)
+			}
 		}
 	}()
 
 	if migration.Dependency != nil && len(migration.Dependency) > 0 {
 		for _, dependency := range migration.Dependency {
-			depsService, ok := m.services[dependency.Name]
+			depsService, ok := m.service(dependency.Name)
 
 			if !ok {
-				m.logger.Error(fmt.Sprintf("migration fail, dependency is not valid, service: %s", serviceName))
-				return errors.New("dependency is not valid")
+				m.logger.Error(fmt.Sprintf("migration fail, dependency %s is not added, service: %s", dependency.Name, serviceName))
+				return 0, dependencyError(dependency, "", ErrDependencyNotFound)
 			}
 
 			if depsService.ConnectFunc == nil {
-				m.logger.Error(fmt.Sprintf("migration fail, dependency is not registered, service: %s", serviceName))
-				return errors.New("dependency is not valid")
+				m.logger.Error(fmt.Sprintf("migration fail, dependency %s is not registered, service: %s", dependency.Name, serviceName))
+				return 0, dependencyError(dependency, "", ErrDependencyNotConnected)
+			}
+
+			depsDb, err := connectDependency(dependency.Name, depsService)
+			if err != nil {
+				m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+				return 0, err
 			}
 
-			depsService.Db = depsService.ConnectFunc()
 			depsServices[dependency.Name] = depsService
+			depsServicesDb[dependency.Name] = depsDb
 
-			if !repository.HasVersionTable(depsService.Db) {
-				return errors.New("dependency is not valid")
+			if !repository.HasVersionTable(depsDb) {
+				return 0, dependencyError(dependency, "", ErrDependencyNotInitialized)
 			}
 
-			version, err := repository.GetVersion(depsService.Db)
+			version, err := repository.GetVersion(depsDb)
 			if err != nil {
-				return err
+				return 0, err
 			}
 
 			if version.Equals(models.Version{}) {
-				return errors.New("dependency is not valid")
+				return 0, dependencyError(dependency, "", ErrDependencyNotMigrated)
 			}
 
 			dependencyVersion, err := models.ParseVersion(dependency.Version)
 
 			if err != nil {
-				return err
+				return 0, err
 			}
 
 			if (dependency.Strict && !version.Equals(dependencyVersion)) || version.LessThan(dependencyVersion) {
-				return errors.New("dependency version is not valid")
+				return 0, dependencyError(dependency, version.String(), ErrDependencyNotSatisfied)
 			}
 		}
 	}
 
-	depsServicesDb := make(map[string]*gorm.DB)
+	auxiliaryDb, err := m.auxiliaryConnections(serviceName, migration)
+	if err != nil {
+		return 0, err
+	}
+
+	for s, db := range auxiliaryDb {
+		depsServicesDb[s] = db
+	}
 
-	for s, info := range depsServices {
-		depsServicesDb[s] = info.Db
+	steps := &optionalSteps{
+		logger:        m.logger,
+		serviceName:   serviceName,
+		migrationType: migrationModel.Type,
+		version:       migrationModel.Version.String(),
 	}
+	db = withOptionalSteps(db, steps)
+
+	// состояние миграции сохраняется без ограничения времени выполнения
+	stateDb := db
+	var restoreState func() error
+	defer func() {
+		if err != nil && restoreState != nil {
+			err = errors.Join(err, restoreState())
+		}
+	}()
+
+	timeout := m.timeoutOf(migration)
+	if timeout > 0 {
+		parent := db.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		db = db.WithContext(ctx)
+
+		defer func() {
+			if err != nil && ctx.Err() != nil {
+				err = fmt.Errorf(
+					"%w: %s (type: %s, version: %s): %w",
+					ErrMigrationTimeout, timeout, migrationModel.Type, migrationModel.Version, err,
+				)
+			}
+		}()
+	}
+
+	counter := &rowsAffectedCounter{}
 
 	if migration.IsTransactional {
-		err := service.Db.Transaction(func(tx *gorm.DB) error {
-			if len(migration.Up) > 0 {
-				return tx.Exec(migration.Up).Error
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := lockMigrationRow(tx, migrationModel)
+			if err != nil {
+				return err
+			}
+
+			if timeout > 0 && m.statementTimeout && tx.Dialector.Name() == "postgres" {
+				err = tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			tx = withRowsAffectedCounter(migration.session(tx), counter)
+
+			if migration.hasUpSQL() {
+				up, err := migration.upSQL()
+				if err != nil {
+					return err
+				}
+				n, err := execTransactionalUp(tx, migration, up)
+				counter.value.Add(n)
+				if err != nil {
+					return err
+				}
 			} else {
-				return migration.UpF(tx, depsServicesDb)
+				err := migration.UpF(tx, depsServicesDb)
+				if err != nil {
+					return err
+				}
 			}
+
+			// проверка внутри транзакции, чтобы при недостаточном количестве строк изменения были отменены
+			return checkRowsAffected(migration, counter.value.Load())
 		})
 
 		if err != nil {
 			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
-			return err
+			return counter.value.Load(), err
 		}
 	} else {
-		db, err := service.Db.DB()
+		// для нетранзакционных миграций блокировка удерживается только на время проверки состояния
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return lockMigrationRow(tx, migrationModel)
+		})
 		if err != nil {
 			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
-			return err
+			return 0, err
+		}
+
+		restoreState, err = markRunning(stateDb, migrationModel)
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+			return 0, err
+		}
+
+		exec, err := migration.execer(db)
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+			return 0, err
+		}
+
+		var hygiene hygieneSnapshot
+		if !m.skipHygieneChecks {
+			hygiene = m.takeHygieneSnapshot(db)
 		}
 
-		if len(migration.Up) > 0 {
-			_, err = db.Exec(migration.Up)
+		if migration.hasUpSQL() {
+			err = m.execStatements(db, exec, migrationModel, migration, resume, counter)
 			if err != nil {
 				m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
-				return err
+				return counter.value.Load(), err
 			}
 		} else {
-			err = migration.UpF(service.Db, depsServicesDb)
+			err = migration.UpF(withRowsAffectedCounter(migration.session(db), counter), depsServicesDb)
 			if err != nil {
 				m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
-				return err
+				return counter.value.Load(), err
+			}
+		}
+
+		err = checkRowsAffected(migration, counter.value.Load())
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+			return counter.value.Load(), err
+		}
+
+		if !m.skipHygieneChecks {
+			err = m.checkHygiene(db, serviceName, migrationModel, hygiene)
+			if err != nil {
+				m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+				return counter.value.Load(), err
 			}
 		}
 	}
 
-	m.logger.Info(fmt.Sprintf("migration Complete, service: %s", serviceName))
+	lastError := steps.lastError()
+	if lastError != "" || migrationModel.LastError != "" {
+		err = repository.UpdateMigrationLastError(stateDb, &migrationModel, lastError)
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("migration fail, service: %s, err: %s", serviceName, err))
+			return counter.value.Load(), err
+		}
+	}
+
+	m.logger.Info(
+		fmt.Sprintf("migration Complete, service: %s, rows affected: %d", serviceName, counter.value.Load()),
+		m.executedByAttrs(serviceName)...,
+	)
+	return counter.value.Load(), nil
+}
+
+// connectDependency открывает соединение с сервисом-зависимостью. Паника в ConnectFunc возвращается как ошибка.
+func connectDependency(name string, service *ServiceInfo) (db *gorm.DB, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("connect to dependency %s panicked: %v", name, r)
+		}
+	}()
+
+	db = service.open()
+	if db == nil {
+		return nil, fmt.Errorf("connect to dependency %s returned nil", name)
+	}
+	return db, nil
+}
+
+// disconnectDependency закрывает соединение с сервисом-зависимостью. Если DisconnectFunc не задан, закрывается пул
+// соединений. Паника в DisconnectFunc возвращается как ошибка.
+func disconnectDependency(name string, service *ServiceInfo, db *gorm.DB) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("disconnect from dependency %s panicked: %v", name, r)
+		}
+	}()
+
+	if service.DisconnectFunc != nil {
+		service.DisconnectFunc(db)
+		return nil
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
+
+// execTransactionalUp выполняет Up транзакционной миграции в транзакции tx по одному выражению и возвращает сумму
+// затронутых строк всех выражений. Необязательные выражения (см. optionalStatementMarker) выполняются через
+// RunOptional. При Migration.DisableStatementSplitting Up выполняется одним запросом.
+func execTransactionalUp(tx *gorm.DB, migration *Migration, up string) (int64, error) {
+	if migration.DisableStatementSplitting {
+		res := tx.Exec(up)
+		if res.Error != nil {
+			return 0, &MigrationExecError{
+				Version:   migration.Version,
+				Type:      string(migration.MigrationType),
+				Statement: up,
+				Err:       res.Error,
+			}
+		}
+		return res.RowsAffected, nil
+	}
+
+	var rowsAffected int64
+	statements := splitStatements(up)
+	for i, statement := range statements {
+		if isOptionalStatement(statement) {
+			err := RunOptional(tx, fmt.Sprintf("statement %d (%s)", i+1, statementSnippet(statement)), func(tx *gorm.DB) error {
+				res := tx.Exec(statement)
+				if res.Error != nil {
+					return res.Error
+				}
+				rowsAffected += res.RowsAffected
+				return nil
+			})
+			if err != nil {
+				return rowsAffected, err
+			}
+			continue
+		}
+
+		res := tx.Exec(statement)
+		if res.Error != nil {
+			return rowsAffected, &MigrationExecError{
+				Version:        migration.Version,
+				Type:           string(migration.MigrationType),
+				Statement:      statement,
+				StatementIndex: i + 1,
+				StatementCount: len(statements),
+				Err:            res.Error,
+			}
+		}
+		rowsAffected += res.RowsAffected
+	}
+	return rowsAffected, nil
+}
+
+// execStatements выполняет Up нетранзакционной миграции по одному выражению, сохраняя после каждого количество
+// выполненных выражений, чтобы при повторном запуске с RunOptions.ResumeFromLastStatement пропустить уже примененные.
+// При Migration.DisableStatementSplitting Up выполняется целиком.
+func (m *MigrationManager) execStatements(
+	gormDb *gorm.DB,
+	exec func(query string) (int64, error),
+	migrationModel models.MigrationModel,
+	migration *Migration,
+	resume bool,
+	counter *rowsAffectedCounter,
+) error {
+	up, err := migration.upSQL()
+	if err != nil {
+		return err
+	}
+
+	statements := []string{up}
+	if !migration.DisableStatementSplitting {
+		statements = splitStatements(up)
+	}
+
+	start := 0
+	if resume && migrationModel.LastStatement > 0 && migrationModel.LastStatement <= len(statements) {
+		start = migrationModel.LastStatement
+		m.logger.Info(
+			fmt.Sprintf(
+				"resuming migration (type: %s, Version: %s) from statement %d of %d",
+				migrationModel.Type, migrationModel.Version, start+1, len(statements),
+			),
+		)
+	}
+
+	for i := start; i < len(statements); i++ {
+		m.logger.Debug(
+			fmt.Sprintf(
+				"executing statement %d of %d of migration (type: %s, Version: %s)",
+				i+1, len(statements), migrationModel.Type, migrationModel.Version,
+			),
+		)
+
+		n, err := exec(statements[i])
+		if err != nil && isOptionalStatement(statements[i]) {
+			err = optionalStepsOf(gormDb).record(fmt.Sprintf("statement %d (%s)", i+1, statementSnippet(statements[i])), err)
+		}
+		if err != nil {
+			return &MigrationExecError{
+				Version:        migrationModel.Version.String(),
+				Type:           migrationModel.Type,
+				Statement:      statements[i],
+				StatementIndex: i + 1,
+				StatementCount: len(statements),
+				Err:            err,
+			}
+		}
+		counter.value.Add(n)
+
+		err = repository.UpdateMigrationLastStatement(gormDb, &migrationModel, i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return repository.UpdateMigrationLastStatement(gormDb, &migrationModel, 0)
+}
+
+// lockMigrationRow блокирует строку выполняемой миграции и проверяет, что ее состояние не было изменено другим
+// процессом с момента планирования.
+func lockMigrationRow(tx *gorm.DB, migrationModel models.MigrationModel) error {
+	locked, err := repository.LockMigration(tx, migrationModel.Id)
+	if err != nil {
+		return fmt.Errorf(
+			"%w (type: %s, version: %s): %w",
+			ErrMigrationLocked, migrationModel.Type, migrationModel.Version, err,
+		)
+	}
+
+	if locked.State != migrationModel.State {
+		return fmt.Errorf(
+			"%w (type: %s, version: %s): state changed from %s to %s",
+			ErrMigrationLocked, migrationModel.Type, migrationModel.Version, migrationModel.State, locked.State,
+		)
+	}
+
 	return nil
 }
 
+func checkRowsAffected(migration *Migration, rowsAffected int64) error {
+	if migration.ExpectRowsMin > 0 && rowsAffected < migration.ExpectRowsMin {
+		return fmt.Errorf(
+			"%w: expected at least %d, got %d (type: %s, version: %s)",
+			ErrRowsAffectedBelowExpected, migration.ExpectRowsMin, rowsAffected, migration.MigrationType, migration.Version,
+		)
+	}
+	return nil
+}
+
+// outOfOrderBelowSaved возвращает true, если миграция выполняется вне очереди (см. WithAllowOutOfOrder) и ее версия
+// ниже сохраненной версии базы данных, которую в этом случае нельзя понижать.
+func (m *MigrationManager) outOfOrderBelowSaved(serviceName string, migrationModel models.MigrationModel) (bool, error) {
+	if !migrationModel.OutOfOrder {
+		return false, nil
+	}
+
+	savedVersion, err := m.getSavedAppVersion(serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return savedVersion.MoreThan(migrationModel.Version), nil
+}
+
 func (m *MigrationManager) saveStateOnSuccessfulMigration(
 	serviceName string,
 	savedMigrations []models.MigrationModel,
 	migrationModel models.MigrationModel,
 	migration *Migration,
 ) error {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return fmt.Errorf("service %s not found", serviceName)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	migrationVersion, err := models.ParseVersion(migration.Version)
@@ -345,15 +1174,34 @@ func (m *MigrationManager) saveStateOnSuccessfulMigration(
 		return err
 	}
 
+	source := repository.VersionSource{
+		Version:   migrationVersion.String(),
+		Type:      string(migration.MigrationType),
+		Rank:      migrationModel.Rank,
+		Direction: DirectionUp,
+	}
+
 	switch migration.MigrationType {
 	case TypeVersioned:
-		err := repository.SaveVersion(service.Db, migrationVersion)
+		lowersVersion, err := m.outOfOrderBelowSaved(serviceName, migrationModel)
+		if err != nil {
+			return err
+		}
+		if lowersVersion {
+			m.logger.Info(fmt.Sprintf(
+				"out-of-order migration (type: %s, Version: %s) applied, saved Version is not changed, service: %s",
+				migrationModel.Type, migrationModel.Version, serviceName,
+			))
+			break
+		}
+
+		err = repository.SaveVersion(service.Db, migrationVersion, source)
 		if err != nil {
 			return err
 		}
 
 	case TypeBaseline:
-		err := repository.SaveVersion(service.Db, migrationVersion)
+		err := repository.SaveVersion(service.Db, migrationVersion, source)
 		if err != nil {
 			return err
 		}
@@ -363,34 +1211,90 @@ func (m *MigrationManager) saveStateOnSuccessfulMigration(
 			if migrationModel.Id == savedMigrations[i].Id {
 				break
 			}
+			if savedMigrations[i].State == models.StateSuccess {
+				continue
+			}
 
-			err = repository.UpdateMigrationState(service.Db, &savedMigrations[i], models.StateSkipped)
+			err = repository.TransitionState(service.Db, &savedMigrations[i], models.StateSkipped, "covered by baseline "+migrationVersion.String())
+			if errors.Is(err, repository.ErrInvalidTransition) {
+				// выполненные, отмененные и исключенные миграции не пропускаются
+				continue
+			}
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	if migration.CheckSum == nil {
-		migration.CheckSum = func(db *gorm.DB) string {
-			return ""
-		}
-	}
-
-	err = repository.UpdateMigrationStateExecuted(
+	err = transitionExecuted(
 		service.Db,
 		&migrationModel,
 		models.StateSuccess,
-		migration.CheckSum(service.Db),
+		"migration applied",
+		migration.checksum(service.Db),
+		m.executedBy(service),
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if migrationModel.FailedAttempts > 0 {
+		err = repository.UpdateMigrationFailedAttempts(service.Db, &migrationModel, 0)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// saveStateOnAllowedFailure сохраняет ошибку миграции типа TypeRepeatable, допускаемую политикой OnFailure. При
+// достижении Migration.MaxConsecutiveFailures последовательных ошибок возвращает исходную ошибку.
+func (m *MigrationManager) saveStateOnAllowedFailure(
+	serviceName string,
+	migrationModel models.MigrationModel,
+	migration *Migration,
+	migrationErr error,
+) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	attempts := migrationModel.FailedAttempts + 1
+
+	if migration.OnFailure == FailureWarnAndContinueUntilNConsecutive && attempts >= migration.MaxConsecutiveFailures {
+		m.logger.Error(
+			fmt.Sprintf(
+				"migration (type: %s, Version: %s) failed %d times in a row, service: %s",
+				migrationModel.Type, migrationModel.Version, attempts, serviceName,
+			),
+		)
+		return errors.Join(
+			migrationErr,
+			repository.TransitionState(service.Db, &migrationModel, models.StateFailure, "too many consecutive failures"),
+			repository.UpdateMigrationFailedAttempts(service.Db, &migrationModel, attempts),
+		)
+	}
+
+	m.logger.Warn(
+		fmt.Sprintf(
+			"migration (type: %s, Version: %s) failed, continuing, consecutive failures: %d, service: %s, err: %s",
+			migrationModel.Type, migrationModel.Version, attempts, serviceName, migrationErr,
+		),
+	)
+
+	err := repository.TransitionState(service.Db, &migrationModel, models.StateFailedAllowed, "failure allowed by policy")
+	if err != nil {
+		return err
+	}
+
+	return repository.UpdateMigrationFailedAttempts(service.Db, &migrationModel, attempts)
+}
+
 func (m *MigrationManager) allowBypassNotFound(migrationModel models.MigrationModel) bool {
 	return migrationModel.Type == string(TypeRepeatable)
 }
@@ -0,0 +1,90 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnknownDependency = errors.New("dependency connection is not available")
+
+// DepConnections - соединения с зависимостями (Migration.Dependency) и дополнительными подключениями
+// (Migration.UsesAuxiliary), передаваемые в UpDeps и DownDeps.
+type DepConnections struct {
+	version  string
+	declared []string
+	dbs      map[string]*gorm.DB
+}
+
+func newDepConnections(migration *Migration, dbs map[string]*gorm.DB) DepConnections {
+	declared := make([]string, 0, len(migration.Dependency)+len(migration.UsesAuxiliary))
+	for _, dependency := range migration.Dependency {
+		declared = append(declared, dependency.Name)
+	}
+	declared = append(declared, migration.UsesAuxiliary...)
+	sort.Strings(declared)
+
+	return DepConnections{version: migration.Version, declared: declared, dbs: dbs}
+}
+
+// Get возвращает соединение с зависимостью name. Возвращает ErrUnknownDependency с перечнем объявленных
+// зависимостей, если name не объявлена в миграции или соединение с ней не открыто (например, при откате
+// открываются только дополнительные подключения).
+func (d DepConnections) Get(name string) (*gorm.DB, error) {
+	if db, ok := d.dbs[name]; ok && db != nil {
+		return db, nil
+	}
+
+	for _, declared := range d.declared {
+		if declared == name {
+			return nil, fmt.Errorf(
+				"%w: %s is declared but not connected, version: %s", ErrUnknownDependency, name, d.version,
+			)
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %s is not declared, version: %s, declared: [%s]",
+		ErrUnknownDependency, name, d.version, strings.Join(d.declared, ", "),
+	)
+}
+
+// Names возвращает имена объявленных зависимостей и дополнительных подключений в алфавитном порядке.
+func (d DepConnections) Names() []string {
+	names := make([]string, len(d.declared))
+	copy(names, d.declared)
+	return names
+}
+
+// adaptDepsFuncs приводит UpDeps и DownDeps к UpF и DownF, через которые выполняются миграции.
+func adaptDepsFuncs(migration *Migration) error {
+	if migration.depsAdapted {
+		return nil
+	}
+
+	if migration.UpDeps != nil {
+		if migration.UpF != nil {
+			return fmt.Errorf("only one of UpF and UpDeps may be set, version: %s", migration.Version)
+		}
+		upDeps := migration.UpDeps
+		migration.UpF = func(selfDb *gorm.DB, depsDb map[string]*gorm.DB) error {
+			return upDeps(selfDb, newDepConnections(migration, depsDb))
+		}
+	}
+
+	if migration.DownDeps != nil {
+		if migration.DownF != nil {
+			return fmt.Errorf("only one of DownF and DownDeps may be set, version: %s", migration.Version)
+		}
+		downDeps := migration.DownDeps
+		migration.DownF = func(selfDb *gorm.DB, depsDb map[string]*gorm.DB) error {
+			return downDeps(selfDb, newDepConnections(migration, depsDb))
+		}
+	}
+
+	migration.depsAdapted = migration.UpDeps != nil || migration.DownDeps != nil
+	return nil
+}
@@ -0,0 +1,221 @@
+package db_migrator
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDepConnectionsGet(t *testing.T) {
+	usersDb := &gorm.DB{}
+	migration := &Migration{
+		Version:       "1.0.1",
+		Dependency:    []DbDependency{{Name: "users", Version: "1.0.0"}, {Name: "billing", Version: "1.0.0"}},
+		UsesAuxiliary: []string{"replica"},
+	}
+	deps := newDepConnections(migration, map[string]*gorm.DB{"users": usersDb, "billing": nil})
+
+	tests := []struct {
+		name    string
+		dep     string
+		want    *gorm.DB
+		wantErr string
+	}{
+		{name: "connected dependency", dep: "users", want: usersDb},
+		{name: "declared but not connected", dep: "replica", wantErr: "replica is declared but not connected, version: 1.0.1"},
+		{name: "nil connection", dep: "billing", wantErr: "billing is declared but not connected"},
+		{name: "typo lists declared names", dep: "user", wantErr: "user is not declared, version: 1.0.1, declared: [billing, replica, users]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := deps.Get(tt.dep)
+			if len(tt.wantErr) > 0 {
+				require.ErrorIs(t, err, ErrUnknownDependency)
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Same(t, tt.want, db)
+		})
+	}
+
+	require.Equal(t, []string{"billing", "replica", "users"}, deps.Names())
+}
+
+func TestUpDepsReadsDependency(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	usersConnect, usersDisconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("users", usersConnect, usersDisconnect, "1.0.0"))
+	require.NoError(t, m.Register("users",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table users(id int); insert into users values (1), (2)"},
+	))
+	require.NoError(t, m.Migrate("users"))
+
+	ordersConnect, ordersDisconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("orders", ordersConnect, ordersDisconnect, "1.0.2"))
+
+	var copied int64
+	require.NoError(t, m.Register("orders",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table orders(user_id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Dependency:      []DbDependency{{Name: "users", Version: "1.0.0"}},
+			UpDeps: func(selfDb *gorm.DB, deps DepConnections) error {
+				usersDb, err := deps.Get("users")
+				if err != nil {
+					return err
+				}
+				var ids []int
+				if err := usersDb.Raw("select id from users order by id").Scan(&ids).Error; err != nil {
+					return err
+				}
+				for _, id := range ids {
+					if err := selfDb.Exec("insert into orders values (?)", id).Error; err != nil {
+						return err
+					}
+				}
+				copied = int64(len(ids))
+				return nil
+			},
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			Dependency:      []DbDependency{{Name: "users", Version: "1.0.0"}},
+			UpDeps: func(selfDb *gorm.DB, deps DepConnections) error {
+				_, err := deps.Get("user")
+				return err
+			},
+		},
+	))
+
+	err = m.Migrate("orders")
+	require.ErrorIs(t, err, ErrUnknownDependency)
+	require.ErrorContains(t, err, "declared: [users]")
+	require.Equal(t, int64(2), copied)
+
+	var count int64
+	require.NoError(t, ordersConnect().Raw("select count(*) from orders").Scan(&count).Error)
+	require.Equal(t, int64(2), count)
+}
+
+// countingDatabase возвращает функции подключения к новой базе данных sqlite, считающие открытые и закрытые
+// соединения.
+func countingDatabase(t *testing.T) (connect func() *gorm.DB, disconnect func(db *gorm.DB), opened *atomic.Int32, closed *atomic.Int32) {
+	t.Helper()
+
+	opened, closed = &atomic.Int32{}, &atomic.Int32{}
+	baseConnect, baseDisconnect := newTestDatabase(t)
+	connect = func() *gorm.DB {
+		opened.Add(1)
+		return baseConnect()
+	}
+	disconnect = func(db *gorm.DB) {
+		closed.Add(1)
+		baseDisconnect(db)
+	}
+	return connect, disconnect, opened, closed
+}
+
+func TestDependencyCleanupOnSecondDependencyFailure(t *testing.T) {
+	tests := map[string]struct {
+		// billing возвращает функции подключения к сервису billing, добавляемому вторым в Migration.Dependency.
+		billing func(t *testing.T) (func() *gorm.DB, func(db *gorm.DB))
+		// migrateBilling - выполнить миграции сервиса billing до миграции сервиса orders.
+		migrateBilling bool
+		err            string
+		errIs          error
+	}{
+		"connect panics": {
+			billing: func(t *testing.T) (func() *gorm.DB, func(db *gorm.DB)) {
+				_, disconnect := newTestDatabase(t)
+				return func() *gorm.DB { panic("billing is down") }, disconnect
+			},
+			err: "connect to dependency billing panicked: billing is down",
+		},
+		"connect returns nil": {
+			billing: func(t *testing.T) (func() *gorm.DB, func(db *gorm.DB)) {
+				_, disconnect := newTestDatabase(t)
+				return func() *gorm.DB { return nil }, disconnect
+			},
+			err: "connect to dependency billing returned nil",
+		},
+		"dependency not initialized": {
+			billing: func(t *testing.T) (func() *gorm.DB, func(db *gorm.DB)) {
+				return newTestDatabase(t)
+			},
+			errIs: ErrDependencyNotInitialized,
+		},
+		"disconnect panics": {
+			billing: func(t *testing.T) (func() *gorm.DB, func(db *gorm.DB)) {
+				connect, disconnect := newTestDatabase(t)
+				panicking := false
+				return connect, func(db *gorm.DB) {
+					disconnect(db)
+					// миграция billing закрывает соединение без ошибки, паникует закрытие соединения зависимости
+					if panicking {
+						panic("billing pool is closed")
+					}
+					panicking = true
+				}
+			},
+			migrateBilling: true,
+			err:            "disconnect from dependency billing panicked: billing pool is closed",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+
+			usersConnect, usersDisconnect, usersOpened, usersClosed := countingDatabase(t)
+			require.NoError(t, m.RegisterService("users", usersConnect, usersDisconnect, "1.0.0"))
+			require.NoError(t, m.Register("users",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table users(id int)"},
+			))
+			require.NoError(t, m.Migrate("users"))
+
+			billingConnect, billingDisconnect := test.billing(t)
+			require.NoError(t, m.RegisterService("billing", billingConnect, billingDisconnect, "1.0.0"))
+			if test.migrateBilling {
+				require.NoError(t, m.Register("billing",
+					Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table invoices(id int)"},
+				))
+				require.NoError(t, m.Migrate("billing"))
+			}
+
+			ordersConnect, ordersDisconnect := newTestDatabase(t)
+			require.NoError(t, m.RegisterService("orders", ordersConnect, ordersDisconnect, "1.0.1"))
+			require.NoError(t, m.Register("orders",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table orders(id int)"},
+				Migration{
+					MigrationType:   TypeVersioned,
+					Version:         "1.0.1",
+					IsTransactional: true,
+					Dependency:      []DbDependency{{Name: "users", Version: "1.0.0"}, {Name: "billing", Version: "1.0.0"}},
+					Up:              "alter table orders add column user_id int",
+				},
+			))
+
+			err = m.Migrate("orders")
+			if test.errIs != nil {
+				require.ErrorIs(t, err, test.errIs)
+			} else {
+				require.ErrorContains(t, err, test.err)
+			}
+
+			// соединение с первой зависимостью закрыто
+			require.Greater(t, usersOpened.Load(), int32(1))
+			require.Equal(t, usersOpened.Load(), usersClosed.Load())
+		})
+	}
+}
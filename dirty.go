@@ -0,0 +1,56 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+// IsDirty возвращает признак наличия миграций сервиса в состоянии failure или running (выполнение нетранзакционной
+// миграции не завершено или прервано), аналогичный флагу dirty golang-migrate. Признак хранится в таблице
+// migrator_meta под ключом dirty ("true" или "false"), что позволяет читать его внешним инструментам, и изменяется
+// вместе с состоянием миграций в одной транзакции. Метод не изменяет базу данных.
+func (m *MigrationManager) IsDirty(serviceName string) (bool, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return false, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	return isDirty(service.Db)
+}
+
+// isDirty читает признак dirty из migrator_meta. Если признак не сохранен (база данных не обновлялась текущей версией
+// библиотеки), он вычисляется по таблице migrations.
+func isDirty(db *gorm.DB) (bool, error) {
+	if repository.HasMetaTable(db) {
+		value, err := repository.GetMeta(db, repository.MetaDirty)
+		if err == nil {
+			return strconv.ParseBool(value)
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return false, err
+		}
+	}
+
+	if !repository.HasMigrationsTable(db) {
+		return false, nil
+	}
+
+	dirty, err := repository.CountDirtyMigrations(db)
+	if err != nil {
+		return false, err
+	}
+	return dirty > 0, nil
+}
@@ -0,0 +1,221 @@
+package db_migrator
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+const encryptedValuePrefix = "enc:"
+
+// ValueEncryptor шифрует значения колонок таблицы migrations, которые могут содержать чувствительные данные
+// (description).
+type ValueEncryptor interface {
+	// KeyID возвращает идентификатор ключа. Сохраняется вместе с зашифрованным значением для ротации ключей.
+	KeyID() string
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+type aesGCMEncryptor struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewAESGCMEncryptor создает ValueEncryptor на основе AES-GCM. Длина key должна составлять 16, 24 или 32 байта.
+func NewAESGCMEncryptor(keyID string, key []byte) (ValueEncryptor, error) {
+	if len(keyID) == 0 || strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid key id: %q", keyID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesGCMEncryptor{keyID: keyID, aead: aead}, nil
+}
+
+func (e *aesGCMEncryptor) KeyID() string {
+	return e.keyID
+}
+
+func (e *aesGCMEncryptor) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := e.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (e *aesGCMEncryptor) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < e.aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():]
+	plaintext, err := e.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// encryptValue шифрует значение, добавляя префикс с идентификатором ключа. Без заданного шифрования значение
+// возвращается без изменений.
+func encryptValue(encryptor ValueEncryptor, value string) (string, error) {
+	if encryptor == nil || len(value) == 0 {
+		return value, nil
+	}
+
+	encrypted, err := encryptor.Encrypt(value)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedValuePrefix + encryptor.KeyID() + ":" + encrypted, nil
+}
+
+// decryptValue расшифровывает значение. Значения, сохраненные до включения шифрования, возвращаются без изменений.
+func decryptValue(encryptor ValueEncryptor, value string) (string, error) {
+	keyID, encrypted, ok := splitEncryptedValue(value)
+	if !ok {
+		return value, nil
+	}
+
+	if encryptor == nil {
+		return "", fmt.Errorf("value is encrypted with key %s, but no encryptor configured", keyID)
+	}
+
+	if keyID != encryptor.KeyID() {
+		return "", fmt.Errorf("value is encrypted with key %s, configured key is %s", keyID, encryptor.KeyID())
+	}
+
+	return encryptor.Decrypt(encrypted)
+}
+
+func splitEncryptedValue(value string) (keyID string, encrypted string, ok bool) {
+	if !strings.HasPrefix(value, encryptedValuePrefix) {
+		return "", "", false
+	}
+
+	keyID, encrypted, ok = strings.Cut(strings.TrimPrefix(value, encryptedValuePrefix), ":")
+	return keyID, encrypted, ok
+}
+
+// getSavedMigrations возвращает сохраненные миграции с расшифрованными значениями.
+func (m *MigrationManager) getSavedMigrations(db *gorm.DB, order repository.Order) ([]models.MigrationModel, error) {
+	savedMigrations, err := repository.GetMigrationsSorted(db, order)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decryptMigrations(savedMigrations)
+}
+
+// decryptMigrations расшифровывает значения прочитанных миграций.
+func (m *MigrationManager) decryptMigrations(migrations []models.MigrationModel) ([]models.MigrationModel, error) {
+	var err error
+	for i := range migrations {
+		migrations[i].Description, err = decryptValue(m.encryptor, migrations[i].Description)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return migrations, nil
+}
+
+// ReencryptMetadata перешифровывает значения, зашифрованные oldEnc, с помощью newEnc. Значения, сохраненные до
+// включения шифрования, шифруются newEnc. Если newEnc равен nil, значения расшифровываются. На время перешифрования
+// захватывается межпроцессная блокировка сервиса, как в Migrate; при наличии прерванных миграций возвращается
+// ErrPreviousRunInterrupted.
+func (m *MigrationManager) ReencryptMetadata(serviceName string, oldEnc ValueEncryptor, newEnc ValueEncryptor) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return nil
+	}
+
+	releaseLock, err := m.acquireProcessLock(context.Background(), service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+
+	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderASC)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkInterrupted(service.Db, serviceName, savedMigrations, false)
+	if err != nil {
+		return err
+	}
+
+	return service.Db.Transaction(func(tx *gorm.DB) error {
+		for i := range savedMigrations {
+			if keyID, _, ok := splitEncryptedValue(savedMigrations[i].Description); ok && newEnc != nil && keyID == newEnc.KeyID() {
+				continue
+			}
+
+			plaintext, err := decryptValue(oldEnc, savedMigrations[i].Description)
+			if err != nil {
+				return err
+			}
+
+			encrypted, err := encryptValue(newEnc, plaintext)
+			if err != nil {
+				return err
+			}
+
+			err = repository.UpdateMigrationDescription(tx, &savedMigrations[i], encrypted)
+			if err != nil {
+				return err
+			}
+		}
+
+		m.logger.Info(fmt.Sprintf("migrations metadata reencrypted, service: %s", serviceName))
+		return nil
+	})
+}
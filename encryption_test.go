@@ -0,0 +1,159 @@
+package db_migrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestEncryptor(t *testing.T, keyID string, key string) ValueEncryptor {
+	t.Helper()
+
+	encryptor, err := NewAESGCMEncryptor(keyID, []byte(key))
+	require.NoError(t, err)
+	return encryptor
+}
+
+// savedDescriptions возвращает значения колонки description таблицы migrations без расшифровки.
+func savedDescriptions(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+
+	savedMigrations, err := repository.GetMigrationsSorted(db, repository.OrderASC)
+	require.NoError(t, err)
+
+	descriptions := make([]string, 0, len(savedMigrations))
+	for _, migrationModel := range savedMigrations {
+		descriptions = append(descriptions, migrationModel.Description)
+	}
+	return descriptions
+}
+
+func TestNewAESGCMEncryptor(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		encryptor, err := NewAESGCMEncryptor("k1", []byte(strings.Repeat("k", size)))
+		require.NoError(t, err)
+		require.Equal(t, "k1", encryptor.KeyID())
+	}
+
+	_, err := NewAESGCMEncryptor("k1", []byte("short"))
+	require.Error(t, err)
+
+	_, err = NewAESGCMEncryptor("", []byte(strings.Repeat("k", 16)))
+	require.ErrorContains(t, err, "invalid key id")
+
+	_, err = NewAESGCMEncryptor("k:1", []byte(strings.Repeat("k", 16)))
+	require.ErrorContains(t, err, "invalid key id")
+}
+
+func TestEncryptValueRoundTrip(t *testing.T) {
+	encryptor := newTestEncryptor(t, "k1", "0123456789abcdef")
+
+	encrypted, err := encryptValue(encryptor, "add column b")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encrypted, "enc:k1:"))
+	require.NotContains(t, encrypted, "add column b")
+
+	again, err := encryptValue(encryptor, "add column b")
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted, again, "nonce must be random")
+
+	decrypted, err := decryptValue(encryptor, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "add column b", decrypted)
+
+	plain, err := decryptValue(encryptor, "saved before encryption")
+	require.NoError(t, err)
+	require.Equal(t, "saved before encryption", plain)
+
+	empty, err := encryptValue(encryptor, "")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
+
+func TestDecryptValueKeyMismatch(t *testing.T) {
+	encrypted, err := encryptValue(newTestEncryptor(t, "k1", "0123456789abcdef"), "add column b")
+	require.NoError(t, err)
+
+	_, err = decryptValue(newTestEncryptor(t, "k2", "fedcba9876543210"), encrypted)
+	require.ErrorContains(t, err, "encrypted with key k1, configured key is k2")
+
+	_, err = decryptValue(nil, encrypted)
+	require.ErrorContains(t, err, "no encryptor configured")
+
+	// тот же идентификатор с другим ключом
+	_, err = decryptValue(newTestEncryptor(t, "k1", "fedcba9876543210"), encrypted)
+	require.Error(t, err)
+}
+
+func TestReencryptMetadata(t *testing.T) {
+	oldEnc := newTestEncryptor(t, "k1", "0123456789abcdef")
+	newEnc := newTestEncryptor(t, "k2", "fedcba9876543210")
+
+	m, connect := newTestManager(t, "1.0.1", WithColumnEncryption(oldEnc))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", Description: "create a", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", Description: "add b", IsTransactional: true, Up: "alter table a add column b text"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	for _, description := range savedDescriptions(t, connect()) {
+		require.True(t, strings.HasPrefix(description, "enc:k1:"), description)
+	}
+
+	require.NoError(t, m.ReencryptMetadata("service1", oldEnc, newEnc))
+
+	for _, description := range savedDescriptions(t, connect()) {
+		require.True(t, strings.HasPrefix(description, "enc:k2:"), description)
+	}
+
+	_, err := m.Status("service1")
+	require.ErrorContains(t, err, "configured key is k1")
+
+	// повторный вызов пропускает значения, уже зашифрованные новым ключом
+	require.NoError(t, m.ReencryptMetadata("service1", oldEnc, newEnc))
+
+	rotated, err := NewMigrationsManager(WithColumnEncryption(newEnc))
+	require.NoError(t, err)
+	service, _ := m.service("service1")
+	require.NoError(t, rotated.RegisterService("service1", service.ConnectFunc, service.DisconnectFunc, "1.0.1"))
+
+	status, err := rotated.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "create a", status.Migrations[0].Description)
+	require.Equal(t, "add b", status.Migrations[1].Description)
+
+	require.NoError(t, rotated.ReencryptMetadata("service1", newEnc, nil))
+	require.Equal(t, []string{"create a", "add b"}, savedDescriptions(t, connect()))
+}
+
+func TestReencryptMetadataLocks(t *testing.T) {
+	enc := newTestEncryptor(t, "k1", "0123456789abcdef")
+
+	t.Run("process lock", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.0", WithLockLease(time.Hour), WithLockTimeout(200*time.Millisecond))
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", Description: "create a", IsTransactional: true, Up: "create table a(id int)"},
+		))
+		require.NoError(t, m.Migrate("service1"))
+		lockOfCrashedProcess(t, connect, time.Minute)
+
+		require.ErrorIs(t, m.ReencryptMetadata("service1", nil, enc), ErrLockNotAcquired)
+		require.Equal(t, []string{"create a"}, savedDescriptions(t, connect()))
+	})
+
+	t.Run("interrupted run", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.0")
+		require.NoError(t, m.Register("service1", lockTestMigrations()...))
+		require.NoError(t, m.Migrate("service1"))
+
+		db := connect()
+		require.NoError(t, db.Table(repository.MigrationsTable(db)).Where("1 = 1").Update("state", models.StateRunning).Error)
+
+		require.ErrorIs(t, m.ReencryptMetadata("service1", nil, enc), ErrPreviousRunInterrupted)
+	})
+}
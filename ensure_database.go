@@ -0,0 +1,134 @@
+package db_migrator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var databaseEncodingRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// EnsureDatabaseConfig задает создание базы данных сервиса перед Migrate (см. WithEnsureDatabase).
+type EnsureDatabaseConfig struct {
+	// Database - имя базы данных сервиса.
+	Database string
+	// Maintenance открывает соединение с служебной базой данных того же сервера (например, postgres или template1 для
+	// postgres), через которое создается база данных. Пользователь соединения должен иметь право создавать базы данных.
+	Maintenance func() *gorm.DB
+	// DisconnectMaintenance закрывает соединение, открытое Maintenance. Если не задан, закрывается *sql.DB.
+	DisconnectMaintenance func(db *gorm.DB)
+	// Owner - владелец создаваемой базы данных (только postgres). Если не задан, владельцем становится пользователь
+	// соединения Maintenance.
+	Owner string
+	// Encoding - кодировка создаваемой базы данных (ENCODING для postgres, CHARACTER SET для mysql). Если не задана,
+	// используется кодировка сервера по умолчанию.
+	Encoding string
+}
+
+// ensureDatabase создает базу данных сервиса, если задана опция WithEnsureDatabase и база данных не существует.
+func (m *MigrationManager) ensureDatabase(serviceName string) (err error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	config := service.ensureDatabase
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Database) == 0 || config.Maintenance == nil {
+		return fmt.Errorf("ensure database of service %s: database name and maintenance connection are required", serviceName)
+	}
+	if len(config.Encoding) > 0 && !databaseEncodingRegexp.MatchString(config.Encoding) {
+		return fmt.Errorf("ensure database of service %s: invalid encoding %q", serviceName, config.Encoding)
+	}
+
+	db := config.Maintenance()
+	defer func() {
+		if disconnectErr := disconnectMaintenance(config, db); disconnectErr != nil {
+			m.logger.Error(fmt.Sprintf("fail to close maintenance connection, service: %s, err: %s", serviceName, disconnectErr))
+		}
+	}()
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return m.ensurePostgresDatabase(db, serviceName, config)
+	case "mysql":
+		return m.ensureMysqlDatabase(db, serviceName, config)
+	default:
+		return fmt.Errorf(
+			"ensure database of service %s: automatic database creation is not supported for %s",
+			serviceName, db.Dialector.Name(),
+		)
+	}
+}
+
+func (m *MigrationManager) ensurePostgresDatabase(db *gorm.DB, serviceName string, config *EnsureDatabaseConfig) error {
+	exists, err := postgresDatabaseExists(db, config.Database)
+	if err != nil || exists {
+		return err
+	}
+
+	query := "CREATE DATABASE " + quotePostgresIdentifier(config.Database)
+	if len(config.Owner) > 0 {
+		query += " OWNER " + quotePostgresIdentifier(config.Owner)
+	}
+	if len(config.Encoding) > 0 {
+		query += " ENCODING '" + config.Encoding + "'"
+	}
+
+	m.logger.Info(fmt.Sprintf("database %s not found, creating, service: %s", config.Database, serviceName))
+
+	err = db.Exec(query).Error
+	if err != nil {
+		// база данных могла быть создана параллельно другим экземпляром приложения
+		if exists, existsErr := postgresDatabaseExists(db, config.Database); existsErr == nil && exists {
+			return nil
+		}
+		return fmt.Errorf("fail to create database %s: %w", config.Database, err)
+	}
+
+	return nil
+}
+
+func postgresDatabaseExists(db *gorm.DB, database string) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT count(*) FROM pg_database WHERE datname = ?", database).Scan(&count).Error
+	return count > 0, err
+}
+
+func (m *MigrationManager) ensureMysqlDatabase(db *gorm.DB, serviceName string, config *EnsureDatabaseConfig) error {
+	if len(config.Owner) > 0 {
+		m.logger.Warn(fmt.Sprintf("database owner is not supported for mysql and is ignored, service: %s", serviceName))
+	}
+
+	query := "CREATE DATABASE IF NOT EXISTS `" + strings.ReplaceAll(config.Database, "`", "``") + "`"
+	if len(config.Encoding) > 0 {
+		query += " CHARACTER SET " + config.Encoding
+	}
+
+	err := db.Exec(query).Error
+	if err != nil {
+		return fmt.Errorf("fail to create database %s: %w", config.Database, err)
+	}
+
+	return nil
+}
+
+func disconnectMaintenance(config *EnsureDatabaseConfig, db *gorm.DB) error {
+	if config.DisconnectMaintenance != nil {
+		config.DisconnectMaintenance(db)
+		return nil
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
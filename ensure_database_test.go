@@ -0,0 +1,106 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEnsureDatabaseConfig(t *testing.T) {
+	connect, _ := newTestDatabase(t)
+
+	tests := map[string]struct {
+		config EnsureDatabaseConfig
+		err    string
+	}{
+		"unsupported dialect": {
+			config: EnsureDatabaseConfig{Database: "service1", Maintenance: connect},
+			err:    "automatic database creation is not supported for sqlite",
+		},
+		"without maintenance": {
+			config: EnsureDatabaseConfig{Database: "service1"},
+			err:    "database name and maintenance connection are required",
+		},
+		"without database": {
+			config: EnsureDatabaseConfig{Maintenance: connect},
+			err:    "database name and maintenance connection are required",
+		},
+		"invalid encoding": {
+			config: EnsureDatabaseConfig{Database: "service1", Maintenance: connect, Encoding: "UTF8' TEMPLATE template0"},
+			err:    "invalid encoding",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+
+			serviceConnect, disconnect := newTestDatabase(t)
+			require.NoError(t, m.RegisterService("service1", serviceConnect, disconnect, "1.0.0", WithEnsureDatabase(test.config)))
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			))
+
+			require.ErrorContains(t, m.Migrate("service1"), test.err)
+			require.False(t, serviceConnect().Migrator().HasTable("a"))
+		})
+	}
+}
+
+func TestEnsureDatabasePostgres(t *testing.T) {
+	driver, dsn := postgresTestDSN(t)
+
+	maintenance := func() *gorm.DB {
+		return openPostgresTestDatabase(t, driver, postgresDatabaseDSN(t, dsn, "postgres"))
+	}
+	database := fmt.Sprintf("ensure%d", time.Now().UnixNano())
+	databaseExists := func() bool {
+		db := maintenance()
+		defer func() { require.NoError(t, disconnectMaintenance(&EnsureDatabaseConfig{}, db)) }()
+
+		exists, err := postgresDatabaseExists(db, database)
+		require.NoError(t, err)
+		return exists
+	}
+	dropDatabase := func() {
+		db := maintenance()
+		defer func() { require.NoError(t, disconnectMaintenance(&EnsureDatabaseConfig{}, db)) }()
+
+		require.NoError(t, db.Exec("DROP DATABASE IF EXISTS "+quotePostgresIdentifier(database)).Error)
+	}
+	t.Cleanup(dropDatabase)
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterServiceSQL("service1",
+		func() (*sql.DB, error) { return sql.Open(driver, postgresDatabaseDSN(t, dsn, database)) },
+		func(db *sql.DB) { _ = db.Close() },
+		"1.0.0",
+		WithSQLDialect("postgres"),
+		WithEnsureDatabase(EnsureDatabaseConfig{Database: database, Maintenance: maintenance, Encoding: "UTF8"}),
+	))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	require.False(t, databaseExists())
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, databaseExists())
+
+	// существующая база данных не пересоздается
+	require.NoError(t, m.Migrate("service1"))
+
+	// база данных удалена и создается заново при следующем запуске
+	dropDatabase()
+	require.False(t, databaseExists())
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, databaseExists())
+
+	version, err := m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", version.String())
+}
@@ -0,0 +1,91 @@
+package db_migrator
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// EstimateReport - оценка стоимости выполнения миграции, вычисляемая Migration.Estimate при планировании.
+type EstimateReport struct {
+	// EstimatedRows - оценка количества строк, затрагиваемых миграцией.
+	EstimatedRows int64
+	// TableBytes - размер затрагиваемых таблиц вместе с индексами в байтах.
+	TableBytes int64
+	// Warning - предупреждение для оператора, выводится вместе с планом.
+	Warning string
+}
+
+// EstimateTableRewrite возвращает функцию для Migration.Estimate, оценивающую стоимость перезаписи таблицы table:
+// количество строк по статистике базы данных и размер таблицы с индексами (pg_total_relation_size для postgres,
+// information_schema.tables для mysql). Для sqlite количество строк вычисляется точно, размер не определяется.
+func EstimateTableRewrite(table string) func(db *gorm.DB) (EstimateReport, error) {
+	return func(db *gorm.DB) (EstimateReport, error) {
+		var report EstimateReport
+
+		var err error
+		switch db.Dialector.Name() {
+		case "postgres":
+			err = db.Raw(
+				`SELECT GREATEST(c.reltuples, 0)::BIGINT, pg_total_relation_size(c.oid)
+				FROM pg_class c WHERE c.oid = to_regclass(?)`,
+				table,
+			).Row().Scan(&report.EstimatedRows, &report.TableBytes)
+		case "mysql":
+			err = db.Raw(
+				`SELECT COALESCE(table_rows, 0), COALESCE(data_length, 0) + COALESCE(index_length, 0)
+				FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`,
+				table,
+			).Row().Scan(&report.EstimatedRows, &report.TableBytes)
+		default:
+			err = db.Table(table).Count(&report.EstimatedRows).Error
+		}
+		if err != nil {
+			return EstimateReport{}, fmt.Errorf("estimate rewrite of table %s: %w", table, err)
+		}
+
+		return report, nil
+	}
+}
+
+// estimate вызывает Migration.Estimate в транзакции, которая всегда откатывается, чтобы оценка не изменяла базу
+// данных.
+func estimate(db *gorm.DB, migration *Migration) (*EstimateReport, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.Rollback()
+
+	report, err := migration.Estimate(tx)
+	if err != nil {
+		return nil, fmt.Errorf("estimate migration (type: %s, Version: %s): %w", migration.MigrationType, migration.Version, err)
+	}
+
+	return &report, nil
+}
+
+// String возвращает краткое описание оценки для вывода в плане.
+func (r EstimateReport) String() string {
+	s := fmt.Sprintf("~%d rows", r.EstimatedRows)
+	if r.TableBytes > 0 {
+		s += ", " + formatBytes(r.TableBytes)
+	}
+	if len(r.Warning) > 0 {
+		s += ", " + r.Warning
+	}
+	return s
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,130 @@
+package db_migrator
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEstimateTableRewrite(t *testing.T) {
+	_, connect := newTestManager(t, "1.0.0")
+	db := connect()
+	require.NoError(t, db.Exec("create table a(id int)").Error)
+	require.NoError(t, db.Exec("insert into a values (1), (2), (3)").Error)
+
+	// для sqlite количество строк вычисляется точно, размер не определяется
+	report, err := EstimateTableRewrite("a")(db)
+	require.NoError(t, err)
+	require.Equal(t, EstimateReport{EstimatedRows: 3}, report)
+	require.Equal(t, "~3 rows", report.String())
+
+	_, err = EstimateTableRewrite("missing")(db)
+	require.ErrorContains(t, err, "estimate rewrite of table missing")
+}
+
+func TestEstimateReportString(t *testing.T) {
+	require.Equal(t, "~0 rows", EstimateReport{}.String())
+	require.Equal(t, "~10 rows, 512 B", EstimateReport{EstimatedRows: 10, TableBytes: 512}.String())
+	require.Equal(t, "~10 rows, 1.5 KiB, table rewrite", EstimateReport{EstimatedRows: 10, TableBytes: 1536, Warning: "table rewrite"}.String())
+	require.Equal(t, "~1 rows, 3.0 GiB", EstimateReport{EstimatedRows: 1, TableBytes: 3 << 30}.String())
+}
+
+// newEstimateTestManager создает менеджер с выполненной миграцией 1.0.0, заполняющей таблицу a тремя строками, и
+// невыполненной миграцией 1.0.1 с оценкой estimate.
+func newEstimateTestManager(t *testing.T, estimate func(db *gorm.DB) (EstimateReport, error), opts ...ManagerOption) (*MigrationManager, func() *gorm.DB) {
+	t.Helper()
+
+	m, connect := newTestManager(t, "1.0.1", opts...)
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int); insert into a values (1), (2), (3)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Up:              "alter table a add column b text",
+			Down:            "alter table a drop column b",
+			Estimate:        estimate,
+		},
+	))
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{TargetVersion: "1.0.0"}))
+	return m, connect
+}
+
+func TestEstimateInPlan(t *testing.T) {
+	var logs bytes.Buffer
+	m, connect := newEstimateTestManager(t, EstimateTableRewrite("a"), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	plan, err := m.Plan("service1")
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Equal(t, &EstimateReport{EstimatedRows: 3}, plan[0].Estimate)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.Contains(t, logs.String(), `↑ 1.0.1.0      versioned   (~3 rows)`)
+
+	// оценка не вычисляется при откате
+	downgradePlan, err := m.PlanDowngradeTo("service1", "1.0.0")
+	require.NoError(t, err)
+	require.Len(t, downgradePlan, 1)
+	require.Nil(t, downgradePlan[0].Estimate)
+
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestEstimateIsRolledBack(t *testing.T) {
+	m, connect := newEstimateTestManager(t, func(db *gorm.DB) (EstimateReport, error) {
+		if err := db.Exec("insert into a values (4)").Error; err != nil {
+			return EstimateReport{}, err
+		}
+		var rows int64
+		err := db.Table("a").Count(&rows).Error
+		return EstimateReport{EstimatedRows: rows}, err
+	})
+
+	plan, err := m.Plan("service1")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), plan[0].Estimate.EstimatedRows)
+
+	var rows int64
+	require.NoError(t, connect().Table("a").Count(&rows).Error)
+	require.Equal(t, int64(3), rows)
+}
+
+func TestEstimateError(t *testing.T) {
+	estimateErr := errors.New("statistics are not available")
+	m, connect := newEstimateTestManager(t, func(*gorm.DB) (EstimateReport, error) {
+		return EstimateReport{}, estimateErr
+	})
+
+	_, err := m.Plan("service1")
+	require.ErrorIs(t, err, estimateErr)
+	require.ErrorContains(t, err, "estimate migration (type: versioned, Version: 1.0.1)")
+
+	require.ErrorIs(t, m.Migrate("service1"), estimateErr)
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestEstimatePlanGate(t *testing.T) {
+	var gated []PlannedMigration
+	gate := PlanGate(func(migrations []PlannedMigration) error {
+		gated = migrations
+		for _, migration := range migrations {
+			if migration.Estimate != nil && migration.Estimate.EstimatedRows > 2 {
+				return errors.New("table rewrite of more than 2 rows requires a maintenance window")
+			}
+		}
+		return nil
+	})
+	m, connect := newEstimateTestManager(t, EstimateTableRewrite("a"), WithPolicyProfile(NewProfile(RelaxedProfile(), gate)))
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrPolicyViolation)
+	require.ErrorContains(t, err, "requires a maintenance window")
+	require.Len(t, gated, 1)
+	require.Equal(t, &EstimateReport{EstimatedRows: 3}, gated[0].Estimate)
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
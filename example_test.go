@@ -0,0 +1,99 @@
+package db_migrator_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	migrator "github.com/Maksumys/db-migrator"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func ExampleSchemaVersion() {
+	version, err := migrator.ParseSchemaVersion("1.4.2")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(version)
+	fmt.Println(version.AtLeast("1.4"), version.AtLeast("1.4.2.1"))
+	fmt.Println(version.Between("1.0", "1.4.2.0"), version.Between("1.5", "2.0"))
+	fmt.Println(version.SameMinor("1.4.9"))
+
+	// версия с меткой предшествует той же версии без метки
+	rc, err := migrator.ParseSchemaVersion("1.5.0-rc.1")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(rc.AtLeast("1.5.0"), rc.Between("1.4", "1.5.0"))
+
+	// Output:
+	// 1.4.2.0
+	// true false
+	// true false
+	// true
+	// false true
+}
+
+// Условие, зависящее от версии схемы: новый код включается, только когда база данных обновлена до версии, в которой
+// появилась колонка status.
+func ExampleMigrationManager_SavedVersionAtLeast() {
+	dir, err := os.MkdirTemp("", "db-migrator-example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	connect := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "orders.db")), &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{SingularTable: true},
+			Logger:         logger.Discard,
+		})
+		if err != nil {
+			panic(err)
+		}
+		return db
+	}
+	disconnect := func(db *gorm.DB) {
+		sqlDb, _ := db.DB()
+		_ = sqlDb.Close()
+	}
+
+	m, err := migrator.NewMigrationsManager(migrator.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		panic(err)
+	}
+	if err := m.RegisterService("orders", connect, disconnect, "1.1.0"); err != nil {
+		panic(err)
+	}
+	err = m.Register("orders",
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table orders(id int)"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.1.0", IsTransactional: true, Up: "alter table orders add column status text"},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	statusEnabled := func() bool {
+		ok, err := m.SavedVersionAtLeast("orders", "1.1.0")
+		if err != nil {
+			panic(err)
+		}
+		return ok
+	}
+
+	fmt.Println("status enabled:", statusEnabled())
+	if err := m.Migrate("orders"); err != nil {
+		panic(err)
+	}
+	fmt.Println("status enabled:", statusEnabled())
+
+	// Output:
+	// status enabled: false
+	// status enabled: true
+}
@@ -0,0 +1,98 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+var ErrExtensionUnavailable = errors.New("required database extension is not available")
+
+// ensureExtensions проверяет наличие расширений, необходимых запланированным миграциям (Migration.RequiredExtensions
+// и WithRequiredExtensions), и создает отсутствующие, если не отключено WithExtensionAutoCreate(false). Вызывается до
+// выполнения первой миграции плана. Для диалектов, отличных от postgres, расширения не проверяются.
+func (m *MigrationManager) ensureExtensions(serviceName string, migrationModels []models.MigrationModel) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	// requiredBy - версии запланированных миграций, которым необходимо расширение
+	requiredBy := make(map[string][]string)
+	for _, migrationModel := range migrationModels {
+		migration, found, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		for _, extension := range service.requiredExtensions {
+			requiredBy[extension] = append(requiredBy[extension], migrationModel.Version.String())
+		}
+		for _, extension := range migration.RequiredExtensions {
+			requiredBy[extension] = append(requiredBy[extension], migrationModel.Version.String())
+		}
+	}
+
+	if len(requiredBy) == 0 {
+		return nil
+	}
+
+	if service.Db.Dialector.Name() != "postgres" {
+		m.logger.Warn(
+			fmt.Sprintf(
+				"required extensions are not supported by %s dialect, skipping check, service: %s",
+				service.Db.Dialector.Name(), serviceName,
+			),
+		)
+		return nil
+	}
+
+	var installed []string
+	err := service.Db.Raw("SELECT extname FROM pg_extension").Scan(&installed).Error
+	if err != nil {
+		return err
+	}
+
+	installedSet := make(map[string]struct{}, len(installed))
+	for _, extension := range installed {
+		installedSet[extension] = struct{}{}
+	}
+
+	missing := make([]string, 0, len(requiredBy))
+	for extension := range requiredBy {
+		if _, ok := installedSet[extension]; !ok {
+			missing = append(missing, extension)
+		}
+	}
+	sort.Strings(missing)
+
+	errs := make([]error, 0)
+	for _, extension := range missing {
+		if !m.extensionAutoCreate {
+			errs = append(errs, fmt.Errorf(
+				"%w: %s is not installed, required by migrations: %s",
+				ErrExtensionUnavailable, extension, strings.Join(requiredBy[extension], ", "),
+			))
+			continue
+		}
+
+		m.logger.Info(fmt.Sprintf("creating extension %s, service: %s", extension, serviceName))
+		err = service.Db.Exec(fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", quotePostgresIdentifier(extension))).Error
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"%w: %s could not be created, required by migrations: %s: %w",
+				ErrExtensionUnavailable, extension, strings.Join(requiredBy[extension], ", "), err,
+			))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,121 @@
+package db_migrator
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func extensionsTestMigrations(extensions ...string) []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text", RequiredExtensions: extensions},
+	}
+}
+
+func TestRequiredExtensionsUnsupportedDialect(t *testing.T) {
+	var logs bytes.Buffer
+	m, err := NewMigrationsManager(
+		WithExtensionAutoCreate(false),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.1", WithRequiredExtensions("pgcrypto")))
+	require.NoError(t, m.Register("service1", extensionsTestMigrations("uuid-ossp")...))
+
+	require.NoError(t, m.Migrate("service1"))
+	require.Contains(t, logs.String(), "required extensions are not supported by sqlite dialect")
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+// newPostgresExtensionsManager создает менеджер с сервисом service1 на новой базе данных postgres и возвращает его
+// вместе с подключением к этой базе данных.
+func newPostgresExtensionsManager(t *testing.T, opts ...ManagerOption) (*MigrationManager, *gorm.DB) {
+	t.Helper()
+
+	driver, dsn := postgresTestDSN(t)
+
+	maintenance := openPostgresTestDatabase(t, driver, postgresDatabaseDSN(t, dsn, "postgres"))
+	database := fmt.Sprintf("extensions%d", time.Now().UnixNano())
+	require.NoError(t, maintenance.Exec("CREATE DATABASE "+quotePostgresIdentifier(database)).Error)
+
+	db := openPostgresTestDatabase(t, driver, postgresDatabaseDSN(t, dsn, database))
+	t.Cleanup(func() {
+		if sqlDb, err := db.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+		_ = maintenance.Exec("DROP DATABASE IF EXISTS " + quotePostgresIdentifier(database)).Error
+		if sqlDb, err := maintenance.DB(); err == nil {
+			_ = sqlDb.Close()
+		}
+	})
+
+	m, err := NewMigrationsManager(opts...)
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterServiceSQL("service1",
+		func() (*sql.DB, error) { return sql.Open(driver, postgresDatabaseDSN(t, dsn, database)) },
+		func(db *sql.DB) { _ = db.Close() },
+		"1.0.1",
+		WithSQLDialect("postgres"),
+	))
+	return m, db
+}
+
+func TestRequiredExtensionsPostgres(t *testing.T) {
+	t.Run("present", func(t *testing.T) {
+		m, db := newPostgresExtensionsManager(t, WithExtensionAutoCreate(false))
+		require.NoError(t, m.Register("service1", extensionsTestMigrations("plpgsql")...))
+
+		require.NoError(t, m.Migrate("service1"))
+		require.True(t, db.Migrator().HasColumn("a", "b"))
+	})
+
+	t.Run("creatable", func(t *testing.T) {
+		m, db := newPostgresExtensionsManager(t)
+
+		var available int64
+		require.NoError(t, db.Raw("SELECT count(*) FROM pg_available_extensions WHERE name = 'pgcrypto'").Scan(&available).Error)
+		if available == 0 {
+			t.Skip("pgcrypto is not available on the test server")
+		}
+
+		require.NoError(t, m.Register("service1", extensionsTestMigrations("pgcrypto")...))
+		if err := m.Migrate("service1"); err != nil {
+			require.ErrorIs(t, err, ErrExtensionUnavailable)
+			t.Skipf("test role cannot create extensions: %s", err)
+		}
+
+		var installed int64
+		require.NoError(t, db.Raw("SELECT count(*) FROM pg_extension WHERE extname = 'pgcrypto'").Scan(&installed).Error)
+		require.EqualValues(t, 1, installed)
+	})
+
+	t.Run("auto create disabled", func(t *testing.T) {
+		m, db := newPostgresExtensionsManager(t, WithExtensionAutoCreate(false))
+		require.NoError(t, m.Register("service1", extensionsTestMigrations("db_migrator_missing")...))
+
+		err := m.Migrate("service1")
+		require.ErrorIs(t, err, ErrExtensionUnavailable)
+		require.ErrorContains(t, err, "db_migrator_missing is not installed, required by migrations: 1.0.1.0")
+		// ни одна миграция плана не выполняется
+		require.False(t, db.Migrator().HasTable("a"))
+	})
+
+	t.Run("cannot be created", func(t *testing.T) {
+		m, db := newPostgresExtensionsManager(t)
+		require.NoError(t, m.Register("service1", extensionsTestMigrations("db_migrator_missing")...))
+
+		err := m.Migrate("service1")
+		require.ErrorIs(t, err, ErrExtensionUnavailable)
+		require.ErrorContains(t, err, "db_migrator_missing could not be created, required by migrations: 1.0.1.0")
+		require.False(t, db.Migrator().HasTable("a"))
+	})
+}
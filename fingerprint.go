@@ -0,0 +1,120 @@
+package db_migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+// MigrationFingerprint содержит хеш отдельной зарегистрированной миграции.
+type MigrationFingerprint struct {
+	MigrationType MigrationType
+	Version       string
+	Hash          string
+}
+
+// Fingerprint возвращает хеш набора зарегистрированных миграций сервиса. Хеш учитывает версии, типы и содержимое
+// миграций и не зависит от порядка регистрации, что позволяет определить, какие миграции содержит бинарный файл, без
+// подключения к базе данных.
+func (m *MigrationManager) Fingerprint(serviceName string) (string, error) {
+	defer m.lockService(serviceName)()
+
+	return m.fingerprint(serviceName)
+}
+
+// ListFingerprint возвращает хеши зарегистрированных миграций сервиса, упорядоченные по версии и типу.
+func (m *MigrationManager) ListFingerprint(serviceName string) ([]MigrationFingerprint, error) {
+	defer m.lockService(serviceName)()
+
+	return m.listFingerprint(serviceName)
+}
+
+func (m *MigrationManager) fingerprint(serviceName string) (string, error) {
+	fingerprints, err := m.listFingerprint(serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for i := range fingerprints {
+		_, _ = h.Write([]byte(fingerprints[i].Hash))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *MigrationManager) listFingerprint(serviceName string) ([]MigrationFingerprint, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	type versionedFingerprint struct {
+		version     models.Version
+		fingerprint MigrationFingerprint
+	}
+
+	fingerprints := make([]versionedFingerprint, 0, len(service.registeredMigrations))
+	for _, migration := range service.registeredMigrations {
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		fingerprints = append(fingerprints, versionedFingerprint{
+			version: version,
+			fingerprint: MigrationFingerprint{
+				MigrationType: migration.MigrationType,
+				Version:       version.String(),
+				Hash:          migrationContentHash(version, migration),
+			},
+		})
+	}
+
+	sort.Slice(fingerprints, func(i, j int) bool {
+		if !fingerprints[i].version.Equals(fingerprints[j].version) {
+			return fingerprints[i].version.LessThan(fingerprints[j].version)
+		}
+		return fingerprints[i].fingerprint.MigrationType < fingerprints[j].fingerprint.MigrationType
+	})
+
+	result := make([]MigrationFingerprint, 0, len(fingerprints))
+	for i := range fingerprints {
+		result = append(result, fingerprints[i].fingerprint)
+	}
+	return result, nil
+}
+
+// migrationContentHash вычисляет хеш миграции. Для миграций, заданных функциями UpF и DownF, учитывается только
+// факт их наличия, т.к. содержимое функции недоступно. Содержимое UpSource и DownSource учитывается через их
+// контрольные суммы.
+func migrationContentHash(version models.Version, migration *Migration) string {
+	h := sha256.New()
+	for _, part := range []string{
+		version.String(),
+		string(migration.MigrationType),
+		sourceHashPart(migration.Up, migration.UpSource),
+		sourceHashPart(migration.Down, migration.DownSource),
+		fmt.Sprintf("upf:%t downf:%t", migration.UpF != nil, migration.DownF != nil),
+	} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceHashPart возвращает content или, если задан source, контрольную сумму его содержимого.
+func sourceHashPart(content string, source MigrationSource) string {
+	if source == nil {
+		return content
+	}
+	checksum, err := sourceChecksum(source)
+	if err != nil {
+		return "source:unreadable"
+	}
+	return "source:" + checksum
+}
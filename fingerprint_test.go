@@ -0,0 +1,88 @@
+package db_migrator
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func fingerprintTestMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text", Down: "alter table a drop column b"},
+		{MigrationType: TypeRepeatable, Version: "1.0.1", IsTransactional: true, Up: "create view v as select 1"},
+		{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, UpSource: FileSource(fstest.MapFS{
+			"up.sql": {Data: []byte("alter table a add column c text")},
+		}, "up.sql")},
+	}
+}
+
+func registeredFingerprint(t *testing.T, migrations []Migration) (string, []MigrationFingerprint) {
+	t.Helper()
+
+	m, _ := newTestManager(t, "1.0.2")
+	require.NoError(t, m.Register("service1", migrations...))
+
+	fingerprint, err := m.Fingerprint("service1")
+	require.NoError(t, err)
+	list, err := m.ListFingerprint("service1")
+	require.NoError(t, err)
+	return fingerprint, list
+}
+
+func TestFingerprintRegistrationOrder(t *testing.T) {
+	migrations := fingerprintTestMigrations()
+	fingerprint, list := registeredFingerprint(t, migrations)
+
+	reversed := slices.Clone(migrations)
+	slices.Reverse(reversed)
+	reversedFingerprint, reversedList := registeredFingerprint(t, reversed)
+
+	require.Equal(t, fingerprint, reversedFingerprint)
+	require.Equal(t, list, reversedList)
+	require.Len(t, list, 4)
+	require.Equal(t, TypeBaseline, list[0].MigrationType)
+	require.Equal(t, "1.0.2.0", list[3].Version)
+}
+
+func TestFingerprintContentChange(t *testing.T) {
+	fingerprint, list := registeredFingerprint(t, fingerprintTestMigrations())
+
+	tests := []struct {
+		name   string
+		index  int
+		change func(migration *Migration)
+	}{
+		{name: "up", index: 1, change: func(migration *Migration) { migration.Up = "alter table a add column bb text" }},
+		{name: "down", index: 1, change: func(migration *Migration) { migration.Down = "select 1" }},
+		{name: "repeatable", index: 2, change: func(migration *Migration) { migration.Up = "create view v as select 2" }},
+		{name: "up source", index: 3, change: func(migration *Migration) {
+			migration.UpSource = FileSource(fstest.MapFS{"up.sql": {Data: []byte("alter table a add column cc text")}}, "up.sql")
+		}},
+		{name: "up function", index: 1, change: func(migration *Migration) {
+			migration.Up = ""
+			migration.UpF = func(selfDb *gorm.DB, depsDb map[string]*gorm.DB) error { return nil }
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations := fingerprintTestMigrations()
+			test.change(&migrations[test.index])
+
+			changedFingerprint, changedList := registeredFingerprint(t, migrations)
+			require.NotEqual(t, fingerprint, changedFingerprint)
+
+			changed := 0
+			for i := range list {
+				require.Equal(t, list[i].Version, changedList[i].Version)
+				if list[i].Hash != changedList[i].Hash {
+					changed++
+				}
+			}
+			require.Equal(t, 1, changed, "only the changed migration hash differs")
+		})
+	}
+}
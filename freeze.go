@@ -0,0 +1,32 @@
+package db_migrator
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AllowLateRegistrations разрешает регистрацию миграций сервиса после выполнения Migrate при включенной опции
+// WithFreezeAfterMigrate.
+func (m *MigrationManager) AllowLateRegistrations(serviceName string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.lateRegistrationsAllowed = true
+	return nil
+}
+
+// registrationsFrozen проверяет, запрещена ли регистрация миграций сервиса опцией WithFreezeAfterMigrate. Сервисы,
+// для которых включено WithAutoMigrateOnRegister, не блокируются: для них поздняя регистрация ожидаема.
+func (m *MigrationManager) registrationsFrozen(serviceName string, service *ServiceInfo) bool {
+	if !m.freezeAfterMigrate || !service.migrated || service.lateRegistrationsAllowed {
+		return false
+	}
+	return !slices.Contains(m.autoMigrateConfig.Services, serviceName)
+}
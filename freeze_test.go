@@ -0,0 +1,99 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lateRepeatable() Migration {
+	return Migration{
+		MigrationType:       TypeRepeatable,
+		Version:             "1.0.2",
+		IsTransactional:     true,
+		RepeatUnconditional: true,
+		Up:                  "create table if not exists late(id int)",
+	}
+}
+
+func TestFreezeAfterMigrate(t *testing.T) {
+	t.Run("never migrated", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.2", WithFreezeAfterMigrate())
+		registerRunDirectionMigrations(t, m)
+
+		require.NoError(t, m.Register("service1", lateRepeatable()))
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.2", WithFreezeAfterMigrate())
+		registerRunDirectionMigrations(t, m)
+		require.NoError(t, m.Migrate("service1"))
+
+		err := m.Register("service1", lateRepeatable())
+		require.ErrorIs(t, err, ErrRegistrationsFrozen)
+		require.ErrorContains(t, err, "AllowLateRegistrations")
+		require.ErrorContains(t, err, "WithAutoMigrateOnRegister")
+
+		require.ErrorIs(t, m.RegisterLite("service1", MigrationLite{
+			MigrationType:   TypeRepeatable,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			UpTxF:           func(*sql.Tx) error { return nil },
+		}), ErrRegistrationsFrozen)
+
+		require.ErrorIs(t, m.RegisterFS("service1", fstest.MapFS{
+			"R1_0_2_0__late.sql": {Data: []byte("create table if not exists late(id int)")},
+		}, "."), ErrRegistrationsFrozen)
+
+		// отклоненные миграции не сохраняются следующим запуском
+		require.NoError(t, m.Migrate("service1"))
+		require.False(t, connect().Migrator().HasTable("late"))
+		status, err := m.Status("service1")
+		require.NoError(t, err)
+		require.Len(t, status.Migrations, 3)
+	})
+
+	t.Run("failed migrate", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.2", WithFreezeAfterMigrate())
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table missing add column b text"},
+		))
+		require.Error(t, m.Migrate("service1"))
+
+		require.NoError(t, m.Register("service1", lateRepeatable()))
+	})
+
+	t.Run("thawed", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.2", WithFreezeAfterMigrate())
+		registerRunDirectionMigrations(t, m)
+		require.NoError(t, m.Migrate("service1"))
+
+		require.NoError(t, m.AllowLateRegistrations("service1"))
+		require.NoError(t, m.Register("service1", lateRepeatable()))
+		require.NoError(t, m.Migrate("service1"))
+		require.True(t, connect().Migrator().HasTable("late"))
+	})
+
+	t.Run("auto migrate on register", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.2",
+			WithFreezeAfterMigrate(),
+			WithAutoMigrateOnRegister(AutoMigrateConfig{Services: []string{"service1"}}),
+		)
+		registerRunDirectionMigrations(t, m)
+		require.NoError(t, m.Migrate("service1"))
+
+		require.NoError(t, m.Register("service1", lateRepeatable()))
+		require.True(t, connect().Migrator().HasTable("late"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.2")
+		registerRunDirectionMigrations(t, m)
+		require.NoError(t, m.Migrate("service1"))
+
+		require.NoError(t, m.Register("service1", lateRepeatable()))
+	})
+}
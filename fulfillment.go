@@ -0,0 +1,127 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const fulfillmentParallelism = 4
+
+// FulfillmentResult содержит результат CheckFulfillment для одного сервиса.
+type FulfillmentResult struct {
+	Reason error
+	Ok     bool
+	Err    error
+}
+
+type FulfillmentResults map[string]FulfillmentResult
+
+// Ok возвращает true, если миграции всех сервисов установлены корректно.
+func (r FulfillmentResults) Ok() bool {
+	for _, result := range r {
+		if !result.Ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckFulfillmentAll выполняет CheckFulfillment для всех зарегистрированных сервисов параллельно. Ошибка проверки
+// одного сервиса (в том числе ошибка подключения) не прерывает проверку остальных и возвращается в FulfillmentResult.
+// Возвращаемая ошибка не равна nil только при отмене ctx.
+//
+// Соединения открываются и закрываются функциями сервиса ConnectFunc и DisconnectFunc, как при Migrate: мигратор не
+// хранит соединения между вызовами. Чтобы проверка не открывала новые соединения, ConnectFunc сервиса должна
+// возвращать постоянный пул приложения, а DisconnectFunc - не закрывать его.
+func (m *MigrationManager) CheckFulfillmentAll(ctx context.Context) (FulfillmentResults, error) {
+	services := m.servicesSnapshot()
+
+	serviceNames := make([]string, 0, len(services))
+	for name, service := range services {
+		service.mutex.Lock()
+		connectable := service.ConnectFunc != nil
+		service.mutex.Unlock()
+
+		if !connectable {
+			continue
+		}
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	results := make(FulfillmentResults, len(serviceNames))
+	resultsMutex := sync.Mutex{}
+	semaphore := make(chan struct{}, fulfillmentParallelism)
+	wg := sync.WaitGroup{}
+
+	for _, name := range serviceNames {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return results, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(serviceName string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			result := m.checkFulfillmentSafe(ctx, serviceName)
+
+			resultsMutex.Lock()
+			results[serviceName] = result
+			resultsMutex.Unlock()
+		}(name)
+	}
+
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// Healthy проверяет миграции всех сервисов с помощью CheckFulfillmentAll и возвращает nil, если они выполнены, иначе
+// ошибку с причинами по каждому сервису. Предназначен для проб готовности.
+func (m *MigrationManager) Healthy(ctx context.Context) error {
+	results, err := m.CheckFulfillmentAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	serviceNames := make([]string, 0, len(results))
+	for name := range results {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	errs := make([]error, 0)
+	for _, name := range serviceNames {
+		result := results[name]
+		switch {
+		case result.Err != nil:
+			errs = append(errs, fmt.Errorf("service %s: %w", name, result.Err))
+		case !result.Ok:
+			errs = append(errs, fmt.Errorf("service %s: %w", name, result.Reason))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkFulfillmentSafe выполняет проверку сервиса, преобразуя панику при подключении в ошибку.
+func (m *MigrationManager) checkFulfillmentSafe(ctx context.Context, serviceName string) (result FulfillmentResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error(fmt.Sprintf("check fulfillment fail, service: %s, err: %v", serviceName, r))
+			result = FulfillmentResult{Err: fmt.Errorf("check fulfillment of service %s: %v", serviceName, r)}
+		}
+	}()
+
+	defer m.lockService(serviceName)()
+
+	reason, ok, err := m.checkFulfillment(ctx, serviceName)
+	return FulfillmentResult{Reason: reason, Ok: ok, Err: err}
+}
@@ -0,0 +1,82 @@
+package db_migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFulfillmentTargetBelowRegistered(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.ErrorIs(t, reason, ErrTargetVersionNotLatest)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", status.Version)
+}
+
+// TestCheckFulfillmentAll проверяет результаты выполненного, невыполненного и недоступного сервисов: ошибка
+// подключения одного сервиса не прерывает проверку остальных.
+func TestCheckFulfillmentAll(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	migrations := []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	}
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("healthy", connect, disconnect, "1.0.1"))
+	require.NoError(t, m.Register("healthy", migrations...))
+	require.NoError(t, m.Migrate("healthy"))
+
+	connect, disconnect = newTestDatabase(t)
+	require.NoError(t, m.RegisterService("pending", connect, disconnect, "1.0.1"))
+	require.NoError(t, m.Register("pending", migrations...))
+
+	require.NoError(t, m.RegisterServiceSQL("unreachable", func() (*sql.DB, error) {
+		return nil, errors.New("connection refused")
+	}, nil, "1.0.1", WithSQLDialect("postgres")))
+	require.NoError(t, m.Register("unreachable", migrations...))
+
+	results, err := m.CheckFulfillmentAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.False(t, results.Ok())
+
+	require.True(t, results["healthy"].Ok)
+	require.NoError(t, results["healthy"].Err)
+
+	require.False(t, results["pending"].Ok)
+	require.NoError(t, results["pending"].Err)
+	require.ErrorIs(t, results["pending"].Reason, ErrHasForthcomingMigrations)
+
+	require.False(t, results["unreachable"].Ok)
+	require.ErrorContains(t, results["unreachable"].Err, "connection refused")
+
+	err = m.Healthy(context.Background())
+	require.ErrorIs(t, err, ErrHasForthcomingMigrations)
+	require.ErrorContains(t, err, "service pending")
+	require.ErrorContains(t, err, "service unreachable")
+	require.NotContains(t, err.Error(), "service healthy")
+
+	require.NoError(t, m.Migrate("pending"))
+	err = m.Healthy(context.Background())
+	require.ErrorContains(t, err, "service unreachable")
+	require.NotContains(t, err.Error(), "service pending")
+}
@@ -0,0 +1,76 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var testDatabaseCounter atomic.Int64
+
+// newTestDatabase возвращает функции подключения к новой базе данных sqlite во временном каталоге теста. Каждое
+// подключение открывает собственный пул соединений, как ConnectFunc реального сервиса.
+func newTestDatabase(t testing.TB) (connect func() *gorm.DB, disconnect func(db *gorm.DB)) {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), fmt.Sprintf("test%d.db", testDatabaseCounter.Add(1))) + "?_busy_timeout=5000"
+	connect = func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{SingularTable: true},
+			NowFunc:        func() time.Time { return time.Now().UTC() },
+			Logger:         logger.Discard,
+		})
+		require.NoError(t, err)
+		return db
+	}
+	disconnect = func(db *gorm.DB) {
+		sqlDb, err := db.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDb.Close())
+	}
+	return connect, disconnect
+}
+
+// newTestManager создает менеджер с сервисом service1 на новой базе данных sqlite.
+func newTestManager(t testing.TB, targetVersion string, opts ...ManagerOption) (*MigrationManager, func() *gorm.DB) {
+	t.Helper()
+
+	m, err := NewMigrationsManager(opts...)
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, targetVersion))
+	return m, connect
+}
+
+// postgresTestDSN возвращает имя драйвера database/sql и строку подключения к тестовой базе данных postgres из
+// переменных окружения DB_MIGRATOR_TEST_POSTGRES_DRIVER (по умолчанию pgx) и DB_MIGRATOR_TEST_POSTGRES_DSN. Тест
+// пропускается, если строка подключения не задана или драйвер не включен в тестовую сборку.
+func postgresTestDSN(t testing.TB) (driver string, dsn string) {
+	t.Helper()
+
+	dsn = os.Getenv("DB_MIGRATOR_TEST_POSTGRES_DSN")
+	if len(dsn) == 0 {
+		t.Skip("DB_MIGRATOR_TEST_POSTGRES_DSN is not set")
+	}
+
+	driver = os.Getenv("DB_MIGRATOR_TEST_POSTGRES_DRIVER")
+	if len(driver) == 0 {
+		driver = "pgx"
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		t.Skipf("database/sql driver %s is not linked into the test binary", driver)
+	}
+	return driver, dsn
+}
@@ -0,0 +1,171 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// SavedMigrationInfo описывает миграцию, сохраненную в таблице migrations.
+type SavedMigrationInfo struct {
+	Type         MigrationType `json:"type"`
+	Version      string        `json:"version"`
+	Description  string        `json:"description"`
+	State        string        `json:"state"`
+	RegisteredOn time.Time     `json:"registered_on"`
+	// ExecutedOn - время последнего выполнения или отмены миграции, nil, если миграция не выполнялась
+	ExecutedOn *time.Time `json:"executed_on,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+	Rank       int        `json:"rank"`
+	// Labels - метки запуска, последним выполнившего или отменившего миграцию (см. RunOptions.RunLabels)
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func newSavedMigrationInfo(migrationModel models.MigrationModel) SavedMigrationInfo {
+	info := SavedMigrationInfo{
+		Type:         MigrationType(migrationModel.Type),
+		Version:      migrationModel.Version.String(),
+		Description:  migrationModel.Description,
+		State:        string(migrationModel.State),
+		RegisteredOn: migrationModel.RegisteredOn.Time,
+		Checksum:     migrationModel.Checksum,
+		Rank:         migrationModel.Rank,
+		Labels:       decodeRunLabels(migrationModel.RunLabels),
+	}
+	if migrationModel.ExecutedOn != nil {
+		executedOn := migrationModel.ExecutedOn.Time
+		info.ExecutedOn = &executedOn
+	}
+	return info
+}
+
+// VersionAt возвращает версию базы данных сервиса на момент t по данным системных таблиц.
+//
+// Версия определяется по последней записи таблицы version_history, сохраненной не позднее t. Если таких записей нет,
+// например, таблица создана более ранней версией библиотеки, версия определяется по итоговой версии последнего запуска
+// Migrate или Downgrade, завершенного не позднее t (таблица migration_runs), а при отсутствии запусков - по наибольшей
+// версии успешно выполненных к моменту t миграций типов TypeVersioned и TypeBaseline; в этом случае отмененные
+// впоследствии миграции не учитываются.
+//
+// Особенности:
+//   - для t раньше первой миграции возвращается пустая строка;
+//   - для t во время запуска возвращается версия после последней выполненной к этому моменту миграции запуска, без
+//     таблицы version_history - версия, сохраненная предыдущим завершенным запуском;
+//   - время записывается по часам экземпляра, выполнявшего запуск, поэтому при расхождении часов экземпляров результат
+//     для t, близкого ко времени запуска, может отличаться на величину расхождения.
+func (m *MigrationManager) VersionAt(serviceName string, t time.Time) (string, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return "", m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	return m.versionAt(serviceName, service, t.UTC())
+}
+
+func (m *MigrationManager) versionAt(serviceName string, service *ServiceInfo, t time.Time) (string, error) {
+	if repository.HasVersionHistoryTable(service.Db) {
+		history, err := repository.GetVersionReachedBefore(service.Db, t)
+		if err == nil {
+			return history.Version.String(), nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return "", err
+		}
+	}
+
+	if repository.HasRunsTable(service.Db) {
+		if err := repository.MigrateRunsTable(service.Db); err != nil {
+			return "", err
+		}
+
+		run, err := repository.GetLastRunFinishedBefore(service.Db, serviceName, t)
+		if err == nil {
+			return run.FinalVersion, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return "", err
+		}
+	}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return "", nil
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return "", err
+	}
+
+	var version models.Version
+	found := false
+	for _, migrationModel := range savedMigrations {
+		if migrationModel.Type == string(TypeRepeatable) || migrationModel.State != models.StateSuccess {
+			continue
+		}
+		if migrationModel.ExecutedOn == nil || migrationModel.ExecutedOn.Time.After(t) {
+			continue
+		}
+		if !found || migrationModel.Version.MoreThan(version) {
+			version = migrationModel.Version
+			found = true
+		}
+	}
+
+	if !found {
+		return "", nil
+	}
+
+	return version.String(), nil
+}
+
+// MigrationsAppliedBetween возвращает миграции сервиса, выполненные или отмененные в интервале [from, to), в порядке
+// выполнения. Таблица migrations хранит только время последнего выполнения миграции, поэтому повторно выполненные
+// миграции типа TypeRepeatable и отмененные миграции попадают в интервал, содержащий последнее выполнение. Время
+// записывается по часам экземпляра, выполнявшего миграцию (см. VersionAt).
+func (m *MigrationManager) MigrationsAppliedBetween(serviceName string, from, to time.Time) ([]SavedMigrationInfo, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return nil, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return []SavedMigrationInfo{}, nil
+	}
+
+	migrationModels, err := repository.GetMigrationsExecutedBetween(service.Db, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]SavedMigrationInfo, 0, len(migrationModels))
+	for _, migrationModel := range migrationModels {
+		migrationModel.Description, err = decryptValue(m.encryptor, migrationModel.Description)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, newSavedMigrationInfo(migrationModel))
+	}
+
+	return migrations, nil
+}
@@ -0,0 +1,99 @@
+package db_migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedVersionHistory добавляет в таблицу version_history записи об изменении версии в моменты reachedOn.
+func seedVersionHistory(t *testing.T, db *gorm.DB, reachedOn time.Time, version string, direction string) {
+	t.Helper()
+
+	parsed, err := models.ParseVersion(version)
+	require.NoError(t, err)
+	require.NoError(t, db.Table(repository.VersionHistoryTable(db)).Create(&models.VersionHistoryModel{
+		Version:   parsed,
+		Direction: direction,
+		ReachedOn: models.CustomTime{Time: reachedOn},
+	}).Error)
+}
+
+func TestVersionAt(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+	require.NoError(t, m.Migrate("service1"))
+
+	base := time.Now().UTC().Add(-10 * time.Hour).Truncate(time.Second)
+	db := connect()
+	seedVersionHistory(t, db, base, "1.0.0", "up")
+	seedVersionHistory(t, db, base.Add(time.Hour), "1.0.1", "up")
+	seedVersionHistory(t, db, base.Add(2*time.Hour), "1.0.2", "up")
+	seedVersionHistory(t, db, base.Add(3*time.Hour), "1.0.1", "down")
+	// запись экземпляра с отстающими часами сохранена последней, но учитывается по записанному времени
+	seedVersionHistory(t, db, base.Add(150*time.Minute), "1.0.3", "up")
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		version string
+	}{
+		{name: "before first migration", at: base.Add(-time.Hour), version: ""},
+		{name: "at first migration", at: base, version: "1.0.0.0"},
+		{name: "during run", at: base.Add(90 * time.Minute), version: "1.0.1.0"},
+		{name: "after downgrade", at: base.Add(3*time.Hour + time.Minute), version: "1.0.1.0"},
+		{name: "lagging clock", at: base.Add(160 * time.Minute), version: "1.0.3.0"},
+		{name: "in local time zone", at: base.Add(2 * time.Hour).In(time.FixedZone("UTC+3", 3*60*60)), version: "1.0.2.0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := m.VersionAt("service1", test.at)
+			require.NoError(t, err)
+			require.Equal(t, test.version, version)
+		})
+	}
+
+	t.Run("without version history", func(t *testing.T) {
+		require.NoError(t, connect().Migrator().DropTable(repository.VersionHistoryTable(db)))
+
+		version, err := m.VersionAt("service1", base.Add(90*time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, version, "run finished later")
+
+		version, err = m.VersionAt("service1", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, "1.0.2.0", version)
+	})
+}
+
+func TestStatusAt(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+	require.NoError(t, m.Migrate("service1"))
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	seedVersionHistory(t, connect(), base, "1.0.0", "up")
+
+	status, err := m.StatusAt("service1", base)
+	require.NoError(t, err)
+	require.NotNil(t, status.At)
+	require.True(t, base.Equal(*status.At))
+	require.Equal(t, "1.0.0.0", status.Version)
+	require.Empty(t, status.VersionSetByType)
+	require.Empty(t, status.Migrations, "migrations were executed after t")
+	require.False(t, status.HasPending)
+
+	status, err = m.StatusAt("service1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", status.Version)
+	require.Len(t, status.Migrations, 3)
+
+	current, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Nil(t, current.At)
+	require.Equal(t, "1.0.2.0", current.Version)
+}
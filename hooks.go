@@ -0,0 +1,72 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+var ErrAbortedByHook = errors.New("migration aborted by before migration hook")
+
+// MigrationInfo описывает выполняемую миграцию для обработчиков WithBeforeMigration, WithAfterMigration и WithOnError.
+type MigrationInfo struct {
+	Type            MigrationType
+	Version         string
+	Description     string
+	IsTransactional bool
+	// Direction - направление выполнения: DirectionUp или DirectionDown
+	Direction string
+	// Labels - метки запуска (см. RunOptions.RunLabels)
+	Labels map[string]string
+}
+
+func newMigrationInfo(migration *Migration, direction string) MigrationInfo {
+	return MigrationInfo{
+		Type:            migration.MigrationType,
+		Version:         migration.Version,
+		Description:     migration.Description,
+		IsTransactional: migration.IsTransactional,
+		Direction:       direction,
+	}
+}
+
+// migrationInfo возвращает описание миграции для обработчиков с метками выполняемого запуска сервиса.
+func (m *MigrationManager) migrationInfo(serviceName string, migration *Migration, direction string) MigrationInfo {
+	info := newMigrationInfo(migration, direction)
+	if service, ok := m.service(serviceName); ok {
+		info.Labels = maps.Clone(service.runLabels)
+	}
+	return info
+}
+
+// beforeMigration вызывает обработчик WithBeforeMigration. Ошибка обработчика оборачивается в ErrAbortedByHook.
+func (m *MigrationManager) beforeMigration(serviceName string, info MigrationInfo) error {
+	if m.beforeMigrationHook == nil {
+		return nil
+	}
+
+	err := m.beforeMigrationHook(serviceName, info)
+	if err != nil {
+		m.logger.Warn(fmt.Sprintf(
+			"migration (type: %s, Version: %s) aborted by hook, service: %s, err: %s",
+			info.Type, info.Version, serviceName, err,
+		))
+		return fmt.Errorf("%w: %w", ErrAbortedByHook, err)
+	}
+	return nil
+}
+
+// afterMigration вызывает обработчик WithAfterMigration или WithOnError в зависимости от результата выполнения
+// миграции err.
+func (m *MigrationManager) afterMigration(serviceName string, info MigrationInfo, startedAt time.Time, err error) {
+	if err != nil {
+		if m.onErrorHook != nil {
+			m.onErrorHook(serviceName, info, err)
+		}
+		return
+	}
+	if m.afterMigrationHook != nil {
+		m.afterMigrationHook(serviceName, info, time.Since(startedAt))
+	}
+}
@@ -0,0 +1,105 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// hookRecorder записывает вызовы обработчиков в порядке вызова.
+type hookRecorder struct {
+	events []string
+	errs   []error
+	// failBefore - версия, для которой обработчик WithBeforeMigration возвращает ошибку
+	failBefore string
+}
+
+func (r *hookRecorder) options() []ManagerOption {
+	return []ManagerOption{
+		WithBeforeMigration(func(service string, info MigrationInfo) error {
+			r.events = append(r.events, fmt.Sprintf("before %s %s %s", info.Direction, info.Type, info.Version))
+			if info.Version == r.failBefore {
+				return errors.New("rejected by hook")
+			}
+			return nil
+		}),
+		WithAfterMigration(func(service string, info MigrationInfo, duration time.Duration) {
+			r.events = append(r.events, fmt.Sprintf("after %s %s %s", info.Direction, info.Type, info.Version))
+		}),
+		WithOnError(func(service string, info MigrationInfo, err error) {
+			r.events = append(r.events, fmt.Sprintf("error %s %s %s", info.Direction, info.Type, info.Version))
+			r.errs = append(r.errs, err)
+		}),
+	}
+}
+
+func TestHooksOrder(t *testing.T) {
+	recorder := &hookRecorder{}
+	m, _ := newTestManager(t, "1.0.1", recorder.options()...)
+	registerRunDirectionMigrations(t, m)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+
+	require.Equal(t, []string{
+		"before up baseline 1.0.0",
+		"after up baseline 1.0.0",
+		"before up versioned 1.0.1",
+		"after up versioned 1.0.1",
+		"before down versioned 1.0.1",
+		"after down versioned 1.0.1",
+	}, recorder.events)
+	require.Empty(t, recorder.errs)
+}
+
+func TestBeforeMigrationHookAborts(t *testing.T) {
+	recorder := &hookRecorder{failBefore: "1.0.1"}
+	m, connect := newTestManager(t, "1.0.2", recorder.options()...)
+	registerRunDirectionMigrations(t, m)
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrAbortedByHook)
+	require.ErrorContains(t, err, "rejected by hook")
+
+	require.Equal(t, []string{
+		"before up baseline 1.0.0",
+		"after up baseline 1.0.0",
+		"before up versioned 1.0.1",
+	}, recorder.events, "aborted migration is not reported to OnError")
+
+	savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+	require.NoError(t, err)
+	require.Equal(t, models.StateSuccess, savedMigrations[0].State)
+	require.Equal(t, models.StateRegistered, savedMigrations[1].State, "state must not change")
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestOnErrorHook(t *testing.T) {
+	recorder := &hookRecorder{}
+	m, _ := newTestManager(t, "1.0.1", recorder.options()...)
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, IsAllowFailure: true, Up: "insert into missing_table values (1)"},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, RepeatUnconditional: true, Up: "insert into missing_view values (1)"},
+	))
+
+	err := m.Migrate("service1")
+	require.ErrorContains(t, err, "missing_view")
+
+	require.Equal(t, []string{
+		"before up baseline 1.0.0",
+		"after up baseline 1.0.0",
+		"before up versioned 1.0.1",
+		"error up versioned 1.0.1",
+		"before up repeatable 1.0.0",
+		"error up repeatable 1.0.0",
+	}, recorder.events, "allowed failure is reported and the run continues")
+	require.Len(t, recorder.errs, 2)
+	require.ErrorContains(t, recorder.errs[0], "missing_table")
+	require.ErrorContains(t, recorder.errs[1], "missing_view")
+}
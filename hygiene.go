@@ -0,0 +1,97 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrLeakedState = errors.New("migration left open transactions or session objects behind")
+
+// hygieneSnapshot - состояние соединений базы данных, сравниваемое до и после выполнения нетранзакционной миграции.
+type hygieneSnapshot struct {
+	// inUse - количество соединений пула, занятых в момент снимка
+	inUse int
+	// objects - открытые транзакции, подготовленные транзакции, advisory locks и временные таблицы (только postgres)
+	objects map[string]struct{}
+}
+
+// postgresHygieneQueries - запросы, возвращающие описания объектов, оставляемых миграциями открытыми.
+var postgresHygieneQueries = []string{
+	`SELECT 'session ' || pid || ' is idle in transaction' FROM pg_stat_activity
+		WHERE datname = current_database() AND usename = current_user AND state LIKE 'idle in transaction%'`,
+	`SELECT 'prepared transaction ' || gid FROM pg_prepared_xacts WHERE database = current_database()`,
+	`SELECT 'advisory lock ' || classid || ':' || objid || ' held by session ' || pid FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND database = (SELECT oid FROM pg_database WHERE datname = current_database())`,
+	`SELECT 'temporary table ' || n.nspname || '.' || c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relpersistence = 't' AND c.relkind = 'r'`,
+}
+
+// takeHygieneSnapshot снимает состояние соединений. Ошибки получения состояния (например, из-за недостатка прав)
+// выводятся в лог, в этом случае соответствующая проверка не выполняется.
+func (m *MigrationManager) takeHygieneSnapshot(db *gorm.DB) hygieneSnapshot {
+	snapshot := hygieneSnapshot{objects: make(map[string]struct{})}
+
+	if sqlDb, err := db.DB(); err == nil {
+		snapshot.inUse = sqlDb.Stats().InUse
+	}
+
+	if db.Dialector.Name() != "postgres" {
+		return snapshot
+	}
+
+	for _, query := range postgresHygieneQueries {
+		var objects []string
+		if err := db.Raw(query).Scan(&objects).Error; err != nil {
+			m.logger.Debug(fmt.Sprintf("fail to check connection state after migration, err: %s", err))
+			continue
+		}
+		for _, object := range objects {
+			snapshot.objects[object] = struct{}{}
+		}
+	}
+
+	return snapshot
+}
+
+// leakedState возвращает описания объектов, появившихся после снимка before.
+func (s hygieneSnapshot) leakedState(before hygieneSnapshot) []string {
+	leaked := make([]string, 0)
+	for object := range s.objects {
+		if _, ok := before.objects[object]; !ok {
+			leaked = append(leaked, object)
+		}
+	}
+	sort.Strings(leaked)
+
+	if s.inUse > before.inUse {
+		leaked = append(leaked, fmt.Sprintf("%d connections of the pool are not released", s.inUse-before.inUse))
+	}
+
+	return leaked
+}
+
+// checkHygiene сравнивает состояние соединений после выполнения нетранзакционной миграции со снимком before и выводит
+// предупреждение об оставленных миграцией открытых транзакциях и объектах сессии либо, при WithStrictHygiene,
+// возвращает ErrLeakedState. Изменения, внесенные параллельно другими сессиями, также могут быть отнесены к миграции.
+func (m *MigrationManager) checkHygiene(db *gorm.DB, serviceName string, migrationModel models.MigrationModel, before hygieneSnapshot) error {
+	leaked := m.takeHygieneSnapshot(db).leakedState(before)
+	if len(leaked) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"migration (type: %s, Version: %s) left state behind: %s, service: %s",
+		migrationModel.Type, migrationModel.Version, strings.Join(leaked, "; "), serviceName,
+	)
+	if m.strictHygiene {
+		return fmt.Errorf("%w: %s", ErrLeakedState, message)
+	}
+
+	m.logger.Warn(message)
+	return nil
+}
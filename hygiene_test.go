@@ -0,0 +1,81 @@
+package db_migrator
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestHygiene(t *testing.T) {
+	tests := []struct {
+		name string
+		// leak - миграция оставляет открытую транзакцию
+		leak        bool
+		opts        []ManagerOption
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "clean migration"},
+		{name: "leaked transaction", leak: true, wantWarning: true},
+		{name: "leaked transaction strict", leak: true, opts: []ManagerOption{WithStrictHygiene()}, wantErr: true},
+		{name: "checks skipped", leak: true, opts: []ManagerOption{WithStrictHygiene(), WithSkipHygieneChecks()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			m, connect := newTestManager(t, "1.0.1", append(tt.opts, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))...)
+
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				Migration{
+					MigrationType: TypeVersioned,
+					Version:       "1.0.1",
+					UpF: func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+						if tt.leak {
+							// транзакция не завершается и занимает соединение пула
+							tx := selfDb.Begin()
+							t.Cleanup(func() { tx.Rollback() })
+							if err := tx.Exec("select 1").Error; err != nil {
+								return err
+							}
+						}
+						return selfDb.Exec("alter table a add column b text").Error
+					},
+				},
+			))
+
+			err := m.Migrate("service1")
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrLeakedState)
+				require.ErrorContains(t, err, "1 connections of the pool are not released")
+			} else {
+				require.NoError(t, err)
+			}
+			require.True(t, connect().Migrator().HasColumn("a", "b"))
+			require.Equal(t, tt.wantWarning, bytes.Contains(logs.Bytes(), []byte("level=WARN msg=\"migration (type: versioned, Version: 1.0.1.0) left state behind: 1 connections of the pool are not released")))
+		})
+	}
+}
+
+func TestHygienePostgres(t *testing.T) {
+	m, _ := newPostgresExtensionsManager(t, WithStrictHygiene())
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "CREATE TABLE a (id int)"},
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.1",
+			// временная таблица и advisory lock остаются в сессии соединения пула после выполнения миграции
+			Up: "CREATE TEMP TABLE leaked (id int); SELECT pg_advisory_lock(4242); ALTER TABLE a ADD COLUMN b text",
+		},
+	))
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrLeakedState)
+	require.ErrorContains(t, err, ".leaked")
+	require.ErrorContains(t, err, "advisory lock 0:4242")
+}
@@ -0,0 +1,112 @@
+package inmemory_test
+
+import (
+	"fmt"
+
+	migrator "github.com/Maksumys/db-migrator"
+	"github.com/Maksumys/db-migrator/inmemory"
+	"gorm.io/gorm"
+)
+
+func ExampleNewManager() {
+	m, err := inmemory.NewManager("orders", "1.1.0")
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	err = m.Register(
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", Description: "initial schema", IsTransactional: true, Up: "CREATE TABLE orders (id BIGINT PRIMARY KEY)"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.1.0", Description: "order status", IsTransactional: true, Up: "ALTER TABLE orders ADD COLUMN status TEXT"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.2.0", Description: "not released yet", IsTransactional: true, Up: "ALTER TABLE orders ADD COLUMN total NUMERIC"},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	report, err := m.Migrate()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, execution := range m.Executions() {
+		fmt.Printf("%s %s: %v\n", execution.Type, execution.Version, execution.Statements)
+	}
+	fmt.Println("version:", report.FinalVersion)
+
+	// Output:
+	// baseline 1.0.0: [CREATE TABLE orders (id BIGINT PRIMARY KEY)]
+	// versioned 1.1.0: [ALTER TABLE orders ADD COLUMN status TEXT]
+	// version: 1.1.0.0
+}
+
+func ExampleManager_Plan() {
+	m, err := inmemory.NewManager("orders", "1.2.0")
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	err = m.Register(
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", Description: "initial schema", IsTransactional: true, Up: "CREATE TABLE orders (id BIGINT)"},
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.1.0", Description: "schema as of 1.1", IsTransactional: true, Up: "CREATE TABLE orders (id BIGINT, status TEXT)"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1", Description: "covered by baseline 1.1", IsTransactional: true, Up: "ALTER TABLE orders ADD COLUMN status TEXT"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.2.0", Description: "order total", IsTransactional: false, Up: "ALTER TABLE orders ADD COLUMN total NUMERIC", Down: "ALTER TABLE orders DROP COLUMN total"},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	plan, err := m.Plan()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(migrator.FormatPlan(migrator.DirectionUp, plan))
+
+	// Output:
+	// ↑ 1.1.0.0      baseline   schema as of 1.1
+	// ↑ 1.2.0.0      versioned  order total [non-tx]
+}
+
+func ExampleManager_Status() {
+	m, err := inmemory.NewManager("orders", "1.0.0")
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	err = m.Register(
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "CREATE TABLE orders (id BIGINT)"},
+		migrator.Migration{MigrationType: migrator.TypeRepeatable, Version: "1.0.0", Description: "reporting view", IsTransactional: true, Up: "CREATE OR REPLACE VIEW order_ids AS SELECT id FROM orders", CheckSum: func(*gorm.DB) string { return "v1" }},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("pending before migrate:", status.HasPending)
+
+	if _, err := m.Migrate(); err != nil {
+		panic(err)
+	}
+
+	status, err = m.Status()
+	if err != nil {
+		panic(err)
+	}
+	for _, migration := range status.Migrations {
+		fmt.Printf("%s %s %s\n", migration.Type, migration.Version, migration.State)
+	}
+	fmt.Println("version:", status.Version)
+	fmt.Println("pending after migrate:", status.HasPending)
+
+	// Output:
+	// pending before migrate: true
+	// baseline 1.0.0.0 success
+	// repeatable 1.0.0.0 success
+	// version: 1.0.0.0
+	// pending after migrate: false
+}
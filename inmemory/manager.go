@@ -0,0 +1,203 @@
+// Package inmemory предоставляет менеджер миграций одного сервиса без внешней базы данных для примеров в
+// документации и экспериментов с планированием.
+//
+// Системные таблицы мигратора хранятся в базе данных sqlite в памяти процесса, поэтому план, проверки, переходы
+// состояний и отчеты формируются тем же MigrationManager, что и в production. Код миграций (Up, UpSource, UpF, Down,
+// DownF) выполняется в сессии gorm с DryRun: запросы не выполняются, а записываются (см. Manager.Executions).
+// Пакет использует драйвер github.com/mattn/go-sqlite3 и требует cgo, поэтому вынесен из основного пакета.
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	migrator "github.com/Maksumys/db-migrator"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var databaseCounter atomic.Int64
+
+// Manager - менеджер миграций сервиса, хранящий состояние в памяти процесса (см. описание пакета).
+//
+// НЕ ДЛЯ PRODUCTION: состояние теряется при Close или завершении процесса, миграции не изменяют данные. Миграции,
+// проверяющие количество затронутых строк (Migration.ExpectRowsMin), завершаются ошибкой, так как в режиме DryRun
+// запросы не затрагивают строк. Migration.CheckSum вызывается с соединением с базой данных в памяти.
+type Manager struct {
+	serviceName string
+	manager     *migrator.MigrationManager
+	db          *gorm.DB
+	recorder    *recorder
+}
+
+// Execution - выполнение миграции, записанное Manager вместо выполнения.
+type Execution struct {
+	Type        migrator.MigrationType
+	Version     string
+	Description string
+	// Direction - направление выполнения: migrator.DirectionUp или migrator.DirectionDown
+	Direction string
+	// Statements - запросы миграции в порядке выполнения, включая запросы UpF и DownF
+	Statements []string
+}
+
+// NewManager создает менеджер миграций сервиса serviceName с целевой версией targetVersion, хранящий состояние в
+// памяти. Опции opts применяются к сервису так же, как в MigrationManager.AddService (например,
+// migrator.WithAllowOutOfOrder). Не для использования в production.
+func NewManager(serviceName string, targetVersion string, opts ...migrator.ServiceOption) (*Manager, error) {
+	// база данных в памяти существует, пока открыто хотя бы одно соединение, поэтому соединение сервиса не
+	// закрывается до Close
+	dsn := fmt.Sprintf("file:inmemory%d?mode=memory&cache=shared", databaseCounter.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := &recorder{}
+	if err := recorder.register(db); err != nil {
+		return nil, errors.Join(err, closeDb(db))
+	}
+
+	manager, err := migrator.NewMigrationsManager(
+		migrator.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		migrator.WithBeforeMigration(recorder.beforeMigration),
+	)
+	if err != nil {
+		return nil, errors.Join(err, closeDb(db))
+	}
+
+	err = manager.AddService(serviceName, migrator.ServiceConfig{
+		Connect:       func() *gorm.DB { return db },
+		Disconnect:    func(*gorm.DB) {},
+		TargetVersion: targetVersion,
+		Options:       opts,
+	})
+	if err != nil {
+		return nil, errors.Join(err, closeDb(db))
+	}
+
+	return &Manager{serviceName: serviceName, manager: manager, db: db, recorder: recorder}, nil
+}
+
+// Register регистрирует миграции так же, как MigrationManager.Register. Код миграций будет выполняться в сессии с
+// DryRun, остальные параметры Migration.SessionOptions сохраняются.
+func (m *Manager) Register(migrations ...migrator.Migration) error {
+	for i := range migrations {
+		session := gorm.Session{}
+		if migrations[i].SessionOptions != nil {
+			session = *migrations[i].SessionOptions
+		}
+		session.DryRun = true
+		migrations[i].SessionOptions = &session
+	}
+	return m.manager.Register(m.serviceName, migrations...)
+}
+
+// Plan возвращает миграции, которые будут обработаны при вызове Migrate (см. MigrationManager.Plan).
+func (m *Manager) Plan() ([]migrator.PlannedMigration, error) {
+	return m.manager.Plan(m.serviceName)
+}
+
+// Migrate выполняет план (см. MigrationManager.MigrateWithReport), записывая запросы миграций в Executions.
+func (m *Manager) Migrate() (migrator.MigrationReport, error) {
+	return m.manager.MigrateWithReport(context.Background(), m.serviceName, migrator.RunOptions{})
+}
+
+// DowngradeTo отменяет миграции выше версии version (см. MigrationManager.DowngradeTo), записывая запросы Down и
+// DownF в Executions.
+func (m *Manager) DowngradeTo(version string) error {
+	return m.manager.DowngradeTo(m.serviceName, version)
+}
+
+// Status возвращает состояние миграций сервиса (см. MigrationManager.Status).
+func (m *Manager) Status() (migrator.ServiceStatus, error) {
+	return m.manager.Status(m.serviceName)
+}
+
+// Executions возвращает выполнения, записанные Migrate и DowngradeTo, в порядке выполнения.
+func (m *Manager) Executions() []Execution {
+	return m.recorder.list()
+}
+
+// Close удаляет базу данных в памяти. После Close менеджер не используется.
+func (m *Manager) Close() error {
+	return closeDb(m.db)
+}
+
+func closeDb(db *gorm.DB) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
+
+// recorder записывает выполнения миграций: выполнение начинается обработчиком WithBeforeMigration, запросы
+// добавляются обработчиками gorm для сессий с DryRun, в которых выполняется только код миграций.
+type recorder struct {
+	mutex      sync.Mutex
+	executions []Execution
+}
+
+func (r *recorder) register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().After("*").Register("inmemory:record_create", r.record),
+		callbacks.Query().After("*").Register("inmemory:record_query", r.record),
+		callbacks.Update().After("*").Register("inmemory:record_update", r.record),
+		callbacks.Delete().After("*").Register("inmemory:record_delete", r.record),
+		callbacks.Row().After("*").Register("inmemory:record_row", r.record),
+		callbacks.Raw().After("*").Register("inmemory:record_raw", r.record),
+	)
+}
+
+func (r *recorder) beforeMigration(_ string, info migrator.MigrationInfo) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.executions = append(r.executions, Execution{
+		Type:        info.Type,
+		Version:     info.Version,
+		Description: info.Description,
+		Direction:   info.Direction,
+		Statements:  []string{},
+	})
+	return nil
+}
+
+func (r *recorder) record(db *gorm.DB) {
+	if !db.DryRun || db.Statement.SQL.Len() == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.executions) == 0 {
+		return
+	}
+	last := &r.executions[len(r.executions)-1]
+	last.Statements = append(last.Statements, db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+}
+
+func (r *recorder) list() []Execution {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	executions := make([]Execution, len(r.executions))
+	for i, execution := range r.executions {
+		execution.Statements = append([]string{}, execution.Statements...)
+		executions[i] = execution
+	}
+	return executions
+}
@@ -0,0 +1,150 @@
+package inmemory
+
+import (
+	"testing"
+
+	migrator "github.com/Maksumys/db-migrator"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestManager(t *testing.T, targetVersion string, opts ...migrator.ServiceOption) *Manager {
+	t.Helper()
+
+	m, err := NewManager("service1", targetVersion, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, m.Close()) })
+	return m
+}
+
+func TestManager(t *testing.T) {
+	m := newTestManager(t, "1.0.1")
+
+	checksum := "v1"
+	var checksumDb *gorm.DB
+	require.NoError(t, m.Register(
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text; alter table a add column c text"},
+		migrator.Migration{MigrationType: migrator.TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v as select 1", CheckSum: func(db *gorm.DB) string {
+			checksumDb = db
+			return checksum
+		}},
+	))
+
+	report, err := m.Migrate()
+	require.NoError(t, err)
+	require.Len(t, report.Entries, 3)
+	require.Equal(t, "1.0.1.0", report.FinalVersion)
+	require.NotNil(t, checksumDb)
+
+	executions := m.Executions()
+	require.Len(t, executions, 3)
+	require.Equal(t, []string{"alter table a add column b text", "alter table a add column c text"}, executions[1].Statements)
+	require.Equal(t, migrator.DirectionUp, executions[1].Direction)
+
+	// запросы миграций не выполняются
+	require.False(t, m.db.Migrator().HasTable("a"))
+
+	// повторный запуск ничего не выполняет
+	report, err = m.Migrate()
+	require.NoError(t, err)
+	require.Empty(t, report.Entries)
+	require.Len(t, m.Executions(), 3)
+
+	// изменение контрольной суммы выполняет миграцию типа repeatable повторно
+	checksum = "v2"
+	plan, err := m.Plan()
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Equal(t, migrator.TypeRepeatable, plan[0].MigrationType)
+
+	_, err = m.Migrate()
+	require.NoError(t, err)
+	require.Len(t, m.Executions(), 4)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.False(t, status.HasPending)
+	require.Equal(t, "1.0.1.0", status.Version)
+}
+
+func TestManagerRegister(t *testing.T) {
+	m := newTestManager(t, "1.0.1")
+
+	require.ErrorIs(t, m.Register(
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "select 1"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1.0", IsTransactional: true, Up: "select 2"},
+	), migrator.ErrDuplicateMigration)
+
+	_, err := NewManager("service1", "1.x")
+	require.Error(t, err)
+
+	require.NoError(t, m.Register(migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "select 1"}))
+	_, err = m.Migrate()
+	require.NoError(t, err)
+
+	// без WithAllowOutOfOrder миграция ниже сохраненной версии не выполняется
+	require.NoError(t, m.Register(migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.0", IsTransactional: true, Up: "select 0"}))
+	_, err = m.Migrate()
+	require.Error(t, err)
+	require.Len(t, m.Executions(), 1)
+}
+
+func TestManagerOutOfOrder(t *testing.T) {
+	m := newTestManager(t, "1.0.2", migrator.WithAllowOutOfOrder())
+
+	require.NoError(t, m.Register(
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.0", IsTransactional: true, Up: "select 0"},
+		migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "select 2"},
+	))
+	_, err := m.Migrate()
+	require.NoError(t, err)
+
+	require.NoError(t, m.Register(migrator.Migration{MigrationType: migrator.TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "select 1"}))
+	plan, err := m.Plan()
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Equal(t, "1.0.1.0", plan[0].Version)
+
+	report, err := m.Migrate()
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+
+	executions := m.Executions()
+	require.Len(t, executions, 3)
+	require.Equal(t, "1.0.1", executions[2].Version)
+	require.Equal(t, []string{"select 1"}, executions[2].Statements)
+}
+
+func TestManagerGoFuncsAndDowngrade(t *testing.T) {
+	m := newTestManager(t, "1.0.1")
+
+	type order struct {
+		Id     int
+		Status string
+	}
+	require.NoError(t, m.Register(
+		migrator.Migration{MigrationType: migrator.TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table orders(id int, status text)"},
+		migrator.Migration{
+			MigrationType: migrator.TypeVersioned, Version: "1.0.1", IsTransactional: false,
+			UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+				return db.Table("orders").Where("status = ?", "new").Update("status", "open").Error
+			},
+			Down: "update orders set status = 'new' where status = 'open'",
+		},
+	))
+
+	_, err := m.Migrate()
+	require.NoError(t, err)
+	require.NoError(t, m.DowngradeTo("1.0.0"))
+
+	executions := m.Executions()
+	require.Len(t, executions, 3)
+	require.Equal(t, []string{"UPDATE `orders` SET `status`=\"open\" WHERE status = \"new\""}, executions[1].Statements)
+	require.Equal(t, migrator.DirectionDown, executions[2].Direction)
+	require.Equal(t, []string{"update orders set status = 'new' where status = 'open'"}, executions[2].Statements)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", status.Version)
+}
@@ -0,0 +1,14 @@
+package models
+
+type LockModel struct {
+	Service    string `gorm:"primaryKey"`
+	Owner      string
+	AcquiredAt CustomTime `gorm:"type:datetime"`
+	// HeartbeatAt - время последнего продления аренды блокировки владельцем, пустое для записей, сохраненных до
+	// появления аренды
+	HeartbeatAt *CustomTime `gorm:"type:datetime"`
+}
+
+func (v LockModel) TableName() string {
+	return "migrator_lock"
+}
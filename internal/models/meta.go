@@ -0,0 +1,10 @@
+package models
+
+type MetaModel struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
+
+func (v MetaModel) TableName() string {
+	return "migrator_meta"
+}
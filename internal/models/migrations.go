@@ -9,18 +9,42 @@ const (
 	StateRegistered MigrationState = "registered"
 	StateSkipped    MigrationState = "skipped"
 	StateNotFound   MigrationState = "not found"
+	StateAbandoned  MigrationState = "abandoned"
+	// StateFailedAllowed - миграция типа TypeRepeatable завершилась ошибкой, которая допускается политикой OnFailure
+	StateFailedAllowed MigrationState = "failed allowed"
+	// StateRunning - нетранзакционная миграция выполняется. Миграция, оставшаяся в этом состоянии, была прервана и
+	// могла быть применена частично
+	StateRunning MigrationState = "running"
 )
 
 type MigrationModel struct {
-	Id           uint32 `gorm:"primaryKey"`
-	Rank         int
-	Type         string
-	Version      Version
-	Description  string
-	RegisteredOn CustomTime  `gorm:"type:datetime"`
-	ExecutedOn   *CustomTime `gorm:"type:datetime"`
-	Checksum     string
-	State        MigrationState
+	Id             uint32 `gorm:"primaryKey"`
+	Rank           int
+	Type           string
+	Version        Version
+	Description    string
+	RegisteredOn   CustomTime  `gorm:"type:datetime"`
+	ExecutedOn     *CustomTime `gorm:"type:datetime"`
+	Checksum       string
+	State          MigrationState
+	RowsAffected   int64
+	FailedAttempts int
+	// LastStatement - количество успешно выполненных выражений нетранзакционной миграции, завершившейся ошибкой
+	LastStatement int
+	// AppliedBy - идентификатор процесса, последним выполнившим или отменившим миграцию
+	AppliedBy string
+	// AppVersion - версия приложения (целевая версия сервиса), последним выполнившего или отменившего миграцию
+	AppVersion string
+	// OutOfOrder - миграция зарегистрирована с версией ниже уже сохраненных миграций и выполняется, несмотря на то
+	// что сохраненная версия базы данных выше (см. WithAllowOutOfOrder)
+	OutOfOrder bool
+	// StartedAt - время начала последнего выполнения нетранзакционной миграции (см. StateRunning)
+	StartedAt *CustomTime `gorm:"type:datetime"`
+	// LastError - ошибки необязательных шагов последнего успешного выполнения миграции (см. RunOptional),
+	// информационное поле, не влияющее на состояние миграции
+	LastError string
+	// RunLabels - метки запуска (JSON), последним выполнившего или отменившего миграцию
+	RunLabels string
 }
 
 func (v MigrationModel) TableName() string {
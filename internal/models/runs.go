@@ -0,0 +1,27 @@
+package models
+
+type RunModel struct {
+	Id             int64 `gorm:"primaryKey;autoIncrement:false"`
+	Service        string
+	Direction      string
+	TargetVersion  string
+	StartedAt      CustomTime  `gorm:"type:datetime"`
+	FinishedAt     *CustomTime `gorm:"type:datetime"`
+	Applied        int
+	Skipped        int
+	Failed         int
+	RowsAffected   int64
+	FinalVersion   string
+	TriggeredBy    string
+	LibraryVersion string
+	Fingerprint    string
+	PlanHash       string
+	Error          string
+	Labels         string
+	SchemaSnapshot string
+	PhaseTimings   string
+}
+
+func (v RunModel) TableName() string {
+	return "migration_runs"
+}
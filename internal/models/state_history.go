@@ -0,0 +1,16 @@
+package models
+
+// StateHistoryModel - запись об изменении состояния миграции.
+type StateHistoryModel struct {
+	MigrationId uint32
+	Type        string
+	Version     Version
+	FromState   MigrationState
+	ToState     MigrationState
+	Reason      string
+	ChangedOn   CustomTime `gorm:"type:datetime"`
+}
+
+func (v StateHistoryModel) TableName() string {
+	return "migration_state_history"
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql/driver"
+	"fmt"
 	"time"
 )
 
@@ -17,13 +18,42 @@ func (c CustomTime) Value() (driver.Value, error) {
 	return c.Time, nil
 }
 
+// timeLayouts - форматы времени, в которых драйверы (например, sqlite) возвращают время в виде строки.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
 func (c *CustomTime) Scan(value interface{}) error {
-	switch value.(type) {
+	switch v := value.(type) {
 	case time.Time:
-		*c = CustomTime{Time: value.(time.Time)}
+		*c = CustomTime{Time: v}
 	case int64:
-		*c = CustomTime{Time: time.Unix(value.(int64), 0)}
+		*c = CustomTime{Time: time.Unix(v, 0)}
+	case string:
+		return c.parse(v)
+	case []byte:
+		return c.parse(string(v))
 	}
 
 	return nil
 }
+
+func (c *CustomTime) parse(value string) error {
+	if len(value) == 0 {
+		*c = CustomTime{}
+		return nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			*c = CustomTime{Time: t}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time value: %q", value)
+}
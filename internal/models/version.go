@@ -9,9 +9,18 @@ import (
 )
 
 type VersionModel struct {
+	// Id - всегда VersionRowId, ограничение уникальности не допускает больше одной строки в таблице
+	Id      int
 	Version Version
+	// SetByVersion и SetByType - версия и тип миграции, после выполнения или отмены которой была сохранена версия
+	SetByVersion string
+	SetByType    string
+	SetAt        *CustomTime `gorm:"type:datetime"`
 }
 
+// VersionRowId - идентификатор единственной строки таблицы version.
+const VersionRowId = 1
+
 func (v VersionModel) TableName() string {
 	return "version"
 }
@@ -21,6 +30,9 @@ type Version struct {
 	Minor      int
 	Patch      int
 	PreRelease int
+	// Label - текстовая пред-релизная метка semver (например, "rc.2" в "1.5.0-rc.2"). Версия с меткой предшествует
+	// той же версии без метки, метки сравниваются по правилам semver.
+	Label string
 }
 
 func (v Version) Value() (driver.Value, error) {
@@ -44,10 +56,13 @@ func (v *Version) Scan(value interface{}) error {
 	default:
 		err = errors.New("invalid type")
 	}
-	return nil
+	return err
 }
 
 func (v Version) String() string {
+	if len(v.Label) > 0 {
+		return fmt.Sprintf("%d.%d.%d.%d-%s", v.Major, v.Minor, v.Patch, v.PreRelease, v.Label)
+	}
 	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Patch, v.PreRelease)
 }
 
@@ -80,7 +95,67 @@ func (v Version) MoreThan(version Version) bool {
 		return false
 	}
 
-	return false
+	return compareLabels(v.Label, version.Label) > 0
+}
+
+// compareLabels сравнивает пред-релизные метки по правилам semver: отсутствие метки старше любой метки, части метки
+// сравниваются слева направо, числовые части - как числа и младше текстовых, более длинная метка при равных
+// начальных частях старше.
+func compareLabels(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if c := compareLabelParts(aParts[i], bParts[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(aParts) > len(bParts):
+		return 1
+	case len(aParts) < len(bParts):
+		return -1
+	}
+	return 0
+}
+
+func compareLabelParts(a string, b string) int {
+	aNumeric, bNumeric := isNumeric(a), isNumeric(b)
+	switch {
+	case aNumeric && bNumeric:
+		// числовые части без ведущих нулей: более длинная больше
+		if len(a) != len(b) {
+			if len(a) > len(b) {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
 }
 
 func (v Version) MoreOrEqual(version Version) bool {
@@ -95,22 +170,111 @@ func (v Version) LessOrEqual(version Version) bool {
 	return !v.MoreThan(version)
 }
 
+// maxVersionSegment - максимальное значение части версии, помещающееся в знаковое 32-битное целое.
+const maxVersionSegment = 1<<31 - 1
+
+// ParseVersion разбирает версию вида major.minor.patch.prerelease. Допускаются также версии major.minor.patch и
+// major.minor, недостающие части считаются равными 0 ("1.4.2" - 1.4.2.0). Каждая часть должна состоять только из
+// цифр и не превышать maxVersionSegment; пробелы, знаки и пустые части не допускаются. Ведущие нули допускаются
+// ("01" - 1). После версии через "-" может следовать пред-релизная метка semver ("1.5.0-rc.2", см. Version.Label).
 func ParseVersion(versionString string) (Version, error) {
-	versions := strings.Split(versionString, ".")
+	core, label, hasLabel := strings.Cut(versionString, "-")
+	if hasLabel {
+		if err := validateLabel(label); err != nil {
+			return Version{}, fmt.Errorf("invalid Version format: %s: %w", versionString, err)
+		}
+	}
 
-	if len(versions) != 4 {
+	versions := strings.Split(core, ".")
+
+	if len(versions) < 2 || len(versions) > 4 {
 		return Version{}, errors.New(fmt.Sprintf("invalid Version format: %s", versionString))
 	}
 
-	major, _ := strconv.Atoi(versions[0])
-	minor, _ := strconv.Atoi(versions[1])
-	patch, _ := strconv.Atoi(versions[2])
-	preRelease, _ := strconv.Atoi(versions[3])
+	var segments [4]int
+	for i, segment := range versions {
+		value, err := parseVersionSegment(segment)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid Version format: %s: %w", versionString, err)
+		}
+		segments[i] = value
+	}
 
 	return Version{
-		Major:      major,
-		Minor:      minor,
-		Patch:      patch,
-		PreRelease: preRelease,
+		Major:      segments[0],
+		Minor:      segments[1],
+		Patch:      segments[2],
+		PreRelease: segments[3],
+		Label:      label,
 	}, nil
 }
+
+// validateLabel проверяет пред-релизную метку: непустые части из латинских букв, цифр и "-", разделенные точками;
+// числовые части без ведущих нулей.
+func validateLabel(label string) error {
+	for _, part := range strings.Split(label, ".") {
+		if len(part) == 0 {
+			return errors.New("empty pre-release label part")
+		}
+		for _, r := range part {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return fmt.Errorf("pre-release label part %q contains invalid characters", part)
+			}
+		}
+		if isNumeric(part) && len(part) > 1 && part[0] == '0' {
+			return fmt.Errorf("numeric pre-release label part %q has leading zeros", part)
+		}
+	}
+	return nil
+}
+
+func parseVersionSegment(segment string) (int, error) {
+	if len(segment) == 0 {
+		return 0, errors.New("empty segment")
+	}
+
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("segment %q is not a non-negative integer", segment)
+		}
+	}
+
+	value, err := strconv.ParseUint(segment, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("segment %q exceeds %d", segment, maxVersionSegment)
+	}
+	return int(value), nil
+}
+
+// AtLeast возвращает true, если версия больше или равна version. Некорректная строка version считается
+// недостижимой, метод возвращает false.
+func (v Version) AtLeast(version string) bool {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	return v.MoreOrEqual(parsed)
+}
+
+// Between возвращает true, если версия находится в диапазоне lo - hi включительно. Некорректная граница диапазона
+// делает диапазон пустым.
+func (v Version) Between(lo string, hi string) bool {
+	loVersion, err := ParseVersion(lo)
+	if err != nil {
+		return false
+	}
+	hiVersion, err := ParseVersion(hi)
+	if err != nil {
+		return false
+	}
+	return v.MoreOrEqual(loVersion) && v.LessOrEqual(hiVersion)
+}
+
+// SameMinor возвращает true, если мажорная и минорная части версии совпадают с version.
+func (v Version) SameMinor(version string) bool {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	return v.Major == parsed.Major && v.Minor == parsed.Minor
+}
@@ -0,0 +1,15 @@
+package models
+
+// VersionHistoryModel - запись об изменении сохраненной версии базы данных.
+type VersionHistoryModel struct {
+	Version Version
+	// Direction - направление изменения: up при выполнении миграции, down при отмене
+	Direction string
+	// MigrationRank - rank миграции, после выполнения или отмены которой сохранена версия
+	MigrationRank int
+	ReachedOn     CustomTime `gorm:"type:datetime"`
+}
+
+func (v VersionHistoryModel) TableName() string {
+	return "version_history"
+}
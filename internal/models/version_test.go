@@ -0,0 +1,231 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.2.3.4", want: Version{Major: 1, Minor: 2, Patch: 3, PreRelease: 4}},
+		{input: "0.0.0.0", want: Version{}},
+		{input: "01.002.3.0", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "1.4.2", want: Version{Major: 1, Minor: 4, Patch: 2}},
+		{input: "1.4", want: Version{Major: 1, Minor: 4}},
+		{input: "1.4.", wantErr: true},
+		{input: "2147483647.0.0.0", want: Version{Major: 2147483647}},
+		{input: "2147483648.0.0.0", wantErr: true},
+		{input: "99999999999999999999.0.0.0", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "1", wantErr: true},
+		{input: "1.2.3.4.5", wantErr: true},
+		{input: "1..3.4", wantErr: true},
+		{input: "1.2.3.", wantErr: true},
+		{input: " 1.2.3.4", wantErr: true},
+		{input: "1.2.3.4 ", wantErr: true},
+		{input: "1. 2.3.4", wantErr: true},
+		{input: "+1.2.3.4", wantErr: true},
+		{input: "1.2.x.4", wantErr: true},
+		{input: "v1.2.3.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVersionScan(t *testing.T) {
+	var v Version
+	require.NoError(t, v.Scan("1.2.3.4"))
+	require.Equal(t, Version{Major: 1, Minor: 2, Patch: 3, PreRelease: 4}, v)
+
+	require.NoError(t, v.Scan([]byte("4.3.2.1")))
+	require.Equal(t, Version{Major: 4, Minor: 3, Patch: 2, PreRelease: 1}, v)
+
+	require.Error(t, v.Scan("1.2.x.4"))
+	require.Error(t, v.Scan(42))
+}
+
+func TestVersionShortFormsCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1.4", b: "1.4.0.0", want: 0},
+		{a: "1.4.2", b: "1.4.2.0", want: 0},
+		{a: "1.4", b: "1.4.0.1", want: -1},
+		{a: "1.4.2", b: "1.4.1.9", want: 1},
+		{a: "1.10", b: "1.9.9.9", want: 1},
+		{a: "2.0", b: "1.99.99", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := ParseVersion(tt.a)
+			require.NoError(t, err)
+			b, err := ParseVersion(tt.b)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want == 0, a.Equals(b))
+			require.Equal(t, tt.want > 0, a.MoreThan(b))
+			require.Equal(t, tt.want < 0, a.LessThan(b))
+			require.Equal(t, tt.want < 0, b.MoreThan(a))
+		})
+	}
+}
+
+func TestParseVersionLabel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.5.0-rc.2", want: Version{Major: 1, Minor: 5, Label: "rc.2"}},
+		{input: "1.5.0.1-alpha", want: Version{Major: 1, Minor: 5, PreRelease: 1, Label: "alpha"}},
+		{input: "1.5-x-y.1", want: Version{Major: 1, Minor: 5, Label: "x-y.1"}},
+		{input: "1.5.0-", wantErr: true},
+		{input: "1.5.0-rc..1", wantErr: true},
+		{input: "1.5.0-rc.01", wantErr: true},
+		{input: "1.5.0-rc_1", wantErr: true},
+		{input: "1.5.0-rc 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+
+			// каноническая запись разбирается в ту же версию
+			reparsed, err := ParseVersion(got.String())
+			require.NoError(t, err)
+			require.Equal(t, got, reparsed)
+		})
+	}
+}
+
+func TestCompareLabels(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "rc.1", b: "rc.1", want: 0},
+		{a: "", b: "rc.1", want: 1},
+		{a: "rc.1", b: "", want: -1},
+		{a: "alpha", b: "beta", want: -1},
+		{a: "alpha", b: "alpha.1", want: -1},
+		{a: "alpha.1", b: "alpha.beta", want: -1},
+		{a: "beta.2", b: "beta.11", want: -1},
+		{a: "beta.11", b: "rc.1", want: -1},
+		{a: "1", b: "alpha", want: -1},
+		{a: "rc.10", b: "rc.9", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.want, compareLabels(tt.a, tt.b))
+			require.Equal(t, -tt.want, compareLabels(tt.b, tt.a))
+		})
+	}
+}
+
+func TestVersionLabelOrder(t *testing.T) {
+	// порядок semver: 1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-alpha.beta < 1.0.0-beta < 1.0.0-beta.2 < 1.0.0-beta.11 <
+	// 1.0.0-rc.1 < 1.0.0
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.0.0.1-rc.1", "1.0.0.1",
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		prev, err := ParseVersion(ordered[i-1])
+		require.NoError(t, err)
+		next, err := ParseVersion(ordered[i])
+		require.NoError(t, err)
+
+		require.True(t, next.MoreThan(prev), "%s > %s", ordered[i], ordered[i-1])
+		require.True(t, prev.LessThan(next), "%s < %s", ordered[i-1], ordered[i])
+		require.False(t, prev.Equals(next))
+	}
+}
+
+func TestVersionPredicates(t *testing.T) {
+	tests := []struct {
+		version   string
+		atLeast   string
+		lo        string
+		hi        string
+		wantLeast bool
+		wantIn    bool
+	}{
+		{version: "1.4.0.0", atLeast: "1.4.0.0", lo: "1.4.0.0", hi: "1.4.0.0", wantLeast: true, wantIn: true},
+		{version: "1.4.0.0", atLeast: "1.4", lo: "1.4", hi: "1.4", wantLeast: true, wantIn: true},
+		{version: "1.4.2", atLeast: "1.4.2.0", lo: "1.0", hi: "1.4.2", wantLeast: true, wantIn: true},
+		{version: "1.4.0.0", atLeast: "1.4.0.1", lo: "1.4.0.1", hi: "1.5", wantLeast: false, wantIn: false},
+		{version: "1.4.0.1", atLeast: "1.4", lo: "1.3", hi: "1.4", wantLeast: true, wantIn: false},
+		{version: "1.5.0-rc.1", atLeast: "1.5.0", lo: "1.5.0-beta", hi: "1.5.0", wantLeast: false, wantIn: true},
+		{version: "1.5.0-rc.1", atLeast: "1.5.0-rc.1", lo: "1.4", hi: "1.5.0-rc.0", wantLeast: true, wantIn: false},
+		{version: "1.5.0", atLeast: "1.5.0-rc.2", lo: "1.5.0-rc.2", hi: "1.5.0-rc.3", wantLeast: true, wantIn: false},
+		// некорректная граница не выполняется
+		{version: "1.4.0.0", atLeast: "1.x", lo: "1.0", hi: "latest", wantLeast: false, wantIn: false},
+		{version: "1.4.0.0", atLeast: "", lo: "", hi: "2.0", wantLeast: false, wantIn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.atLeast+" "+tt.lo+"-"+tt.hi, func(t *testing.T) {
+			version, err := ParseVersion(tt.version)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantLeast, version.AtLeast(tt.atLeast))
+			require.Equal(t, tt.wantIn, version.Between(tt.lo, tt.hi))
+		})
+	}
+
+	// диапазон с нижней границей больше верхней пуст
+	version, err := ParseVersion("1.4.0.0")
+	require.NoError(t, err)
+	require.False(t, version.Between("1.5", "1.3"))
+}
+
+func TestVersionSameMinor(t *testing.T) {
+	tests := []struct {
+		version string
+		other   string
+		want    bool
+	}{
+		{version: "1.4.0.0", other: "1.4.0.0", want: true},
+		{version: "1.4.0.0", other: "1.4.7.3", want: true},
+		{version: "1.4.2", other: "1.4", want: true},
+		{version: "1.4.0-rc.1", other: "1.4.0", want: true},
+		{version: "1.4.0.0", other: "1.5.0.0", want: false},
+		{version: "1.4.0.0", other: "2.4.0.0", want: false},
+		{version: "1.4.0.0", other: "1.x", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.other, func(t *testing.T) {
+			version, err := ParseVersion(tt.version)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, version.SameMinor(tt.other))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// columnKind - тип колонки системной таблицы, переводимый в тип диалекта (см. sqlType).
+type columnKind int
+
+const (
+	// columnText - строка произвольной длины
+	columnText columnKind = iota
+	// columnKey - строка, используемая в первичном ключе или индексе
+	columnKey
+	columnInt
+	columnBigInt
+	columnTimestamp
+	// columnMigrationId - идентификатор миграции (uint32)
+	columnMigrationId
+	columnBool
+)
+
+type column struct {
+	name       string
+	kind       columnKind
+	primaryKey bool
+}
+
+// sqlType возвращает тип колонки kind для диалекта dialect. Типы postgres совпадают с типами, использовавшимися до
+// поддержки других диалектов.
+func sqlType(dialect string, kind columnKind) string {
+	switch dialect {
+	case "mysql":
+		switch kind {
+		case columnKey:
+			return "VARCHAR(255)"
+		case columnTimestamp:
+			return "DATETIME(6)"
+		case columnMigrationId:
+			return "BIGINT"
+		case columnBool:
+			return "BOOLEAN"
+		}
+	case "sqlite":
+		switch kind {
+		case columnKey:
+			return "TEXT"
+		case columnTimestamp:
+			return "DATETIME"
+		case columnMigrationId:
+			return "INTEGER"
+		case columnBool:
+			return "BOOLEAN"
+		}
+	case "sqlserver":
+		switch kind {
+		case columnText:
+			return "NVARCHAR(MAX)"
+		case columnKey:
+			return "NVARCHAR(255)"
+		case columnTimestamp:
+			return "DATETIMEOFFSET"
+		case columnMigrationId:
+			return "BIGINT"
+		case columnBool:
+			return "BIT"
+		}
+	}
+
+	switch kind {
+	case columnInt:
+		return "INTEGER"
+	case columnBigInt:
+		return "BIGINT"
+	case columnTimestamp:
+		return "TIMESTAMPTZ"
+	case columnMigrationId:
+		return "NUMERIC"
+	case columnBool:
+		return "BOOLEAN"
+	default:
+		return "TEXT"
+	}
+}
+
+// columnDefinition возвращает определение колонки для CREATE TABLE и ALTER TABLE.
+func columnDefinition(db *gorm.DB, c column) string {
+	definition := quote(db, c.name) + " " + sqlType(db.Dialector.Name(), c.kind)
+	if c.primaryKey {
+		definition += " PRIMARY KEY"
+	}
+	return definition
+}
+
+// createTable создает таблицу table с колонками columns, если она не существует.
+func createTable(db *gorm.DB, table string, columns []column) error {
+	definitions := make([]string, 0, len(columns))
+	for _, c := range columns {
+		definitions = append(definitions, columnDefinition(db, c))
+	}
+
+	statement := "CREATE TABLE " + quote(db, table) + " (\n\t" + strings.Join(definitions, ",\n\t") + "\n)"
+	if db.Dialector.Name() == "sqlserver" {
+		// sqlserver не поддерживает CREATE TABLE IF NOT EXISTS
+		return db.Exec("IF OBJECT_ID(?, 'U') IS NULL "+statement, table).Error
+	}
+	return db.Exec(strings.Replace(statement, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)).Error
+}
+
+// addColumn добавляет колонку c в существующую таблицу table.
+func addColumn(db *gorm.DB, table string, c column) error {
+	keyword := " ADD COLUMN "
+	if db.Dialector.Name() == "sqlserver" {
+		keyword = " ADD "
+	}
+	return db.Exec("ALTER TABLE " + quote(db, table) + keyword + columnDefinition(db, c)).Error
+}
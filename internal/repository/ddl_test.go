@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils/tests"
+)
+
+// dialectStub - диалект с заданным именем, не подключающийся к базе данных. Идентификаторы экранируются обратными
+// кавычками независимо от имени диалекта.
+type dialectStub struct {
+	tests.DummyDialector
+	name string
+}
+
+func (d dialectStub) Name() string {
+	return d.name
+}
+
+// statementRecorder - логгер gorm, сохраняющий выполняемые запросы.
+type statementRecorder struct {
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *statementRecorder) Info(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Warn(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	statement, _ := fc()
+	r.statements = append(r.statements, statement)
+}
+
+// newDryRunDb возвращает соединение с диалектом dialect, которое не выполняет запросы, а сохраняет их в recorder.
+func newDryRunDb(t *testing.T, dialect string) (*gorm.DB, *statementRecorder) {
+	t.Helper()
+
+	recorder := &statementRecorder{}
+	db, err := gorm.Open(dialectStub{name: dialect}, &gorm.Config{DryRun: true, Logger: recorder})
+	require.NoError(t, err)
+	return db, recorder
+}
+
+func TestCreateMigrationsTableDDL(t *testing.T) {
+	tests := []struct {
+		dialect   string
+		prefix    string
+		fragments []string
+	}{
+		{
+			dialect: "postgres",
+			prefix:  "CREATE TABLE IF NOT EXISTS `migrations` (",
+			fragments: []string{
+				"`id` NUMERIC PRIMARY KEY", "`rank` BIGINT", "`version` TEXT", "`executed_on` TIMESTAMPTZ",
+				"`out_of_order` BOOLEAN", "`last_error` TEXT",
+			},
+		},
+		{
+			dialect: "mysql",
+			prefix:  "CREATE TABLE IF NOT EXISTS `migrations` (",
+			fragments: []string{
+				"`id` BIGINT PRIMARY KEY", "`rank` BIGINT", "`version` TEXT", "`executed_on` DATETIME(6)",
+				"`out_of_order` BOOLEAN", "`last_error` TEXT",
+			},
+		},
+		{
+			dialect: "sqlite",
+			prefix:  "CREATE TABLE IF NOT EXISTS `migrations` (",
+			fragments: []string{
+				"`id` INTEGER PRIMARY KEY", "`rank` BIGINT", "`version` TEXT", "`executed_on` DATETIME",
+				"`out_of_order` BOOLEAN", "`last_error` TEXT",
+			},
+		},
+		{
+			dialect: "sqlserver",
+			prefix:  "IF OBJECT_ID(\"migrations\", 'U') IS NULL CREATE TABLE `migrations` (",
+			fragments: []string{
+				"`id` BIGINT PRIMARY KEY", "`rank` BIGINT", "`version` NVARCHAR(MAX)", "`executed_on` DATETIMEOFFSET",
+				"`out_of_order` BIT", "`last_error` NVARCHAR(MAX)",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect, func(t *testing.T) {
+			db, recorder := newDryRunDb(t, test.dialect)
+
+			require.NoError(t, CreateMigrationsTable(db))
+			require.Len(t, recorder.statements, 1)
+
+			statement := recorder.statements[0]
+			require.True(t, strings.HasPrefix(statement, test.prefix), statement)
+			for _, fragment := range test.fragments {
+				require.Contains(t, statement, fragment)
+			}
+			// колонки, добавляемые в существующие таблицы, входят в создаваемую таблицу
+			for _, column := range migrationsTableColumns {
+				require.Contains(t, statement, "`"+column.name+"` "+sqlType(test.dialect, column.kind))
+			}
+		})
+	}
+}
+
+func TestAddColumnDDL(t *testing.T) {
+	tests := map[string]string{
+		"postgres":  "ALTER TABLE `migrations` ADD COLUMN `started_at` TIMESTAMPTZ",
+		"mysql":     "ALTER TABLE `migrations` ADD COLUMN `started_at` DATETIME(6)",
+		"sqlite":    "ALTER TABLE `migrations` ADD COLUMN `started_at` DATETIME",
+		"sqlserver": "ALTER TABLE `migrations` ADD `started_at` DATETIMEOFFSET",
+	}
+	for dialect, expected := range tests {
+		t.Run(dialect, func(t *testing.T) {
+			db, recorder := newDryRunDb(t, dialect)
+
+			require.NoError(t, addColumn(db, MigrationsTable(db), column{name: "started_at", kind: columnTimestamp}))
+			require.Equal(t, []string{expected}, recorder.statements)
+		})
+	}
+}
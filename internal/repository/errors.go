@@ -4,4 +4,8 @@ import "errors"
 
 var (
 	ErrNotFound = errors.New("not found")
+	// ErrConflictingVersions - таблица version содержит несколько строк с разными версиями
+	ErrConflictingVersions = errors.New("version table contains conflicting rows")
+	// ErrLocked - строка заблокирована другой транзакцией
+	ErrLocked = errors.New("row is locked by another transaction")
 )
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var lockTableColumns = []column{
+	{name: "heartbeat_at", kind: columnTimestamp},
+}
+
+func CreateLockTable(db *gorm.DB) error {
+	return createTable(db, LockTable(db), append([]column{
+		{name: "service", kind: columnKey, primaryKey: true},
+		{name: "owner", kind: columnText},
+		{name: "acquired_at", kind: columnTimestamp},
+	}, lockTableColumns...))
+}
+
+// MigrateLockTable добавляет в таблицу блокировок колонки, отсутствующие в таблицах, созданных предыдущими версиями
+// библиотеки.
+func MigrateLockTable(db *gorm.DB) error {
+	for _, column := range lockTableColumns {
+		if hasColumn(db, lockTableName, column.name) {
+			continue
+		}
+		if err := addColumn(db, LockTable(db), column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func HasLockTable(db *gorm.DB) bool {
+	return hasTable(db, lockTableName)
+}
+
+// TryLock сохраняет запись блокировки сервиса владельцем owner. Возвращает false, если блокировка удерживается
+// другим владельцем. Запрос выполняется с контекстом ctx, размещение таблицы определяется соединением db.
+func TryLock(ctx context.Context, db *gorm.DB, service string, owner string, now time.Time) (bool, error) {
+	lock := models.LockModel{
+		Service:     service,
+		Owner:       owner,
+		AcquiredAt:  models.CustomTime{Time: now},
+		HeartbeatAt: &models.CustomTime{Time: now},
+	}
+	res := db.Table(LockTable(db)).WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// TakeOverLock передает владельцу owner блокировку сервиса, если она принадлежит владельцу previousOwner и ее аренда
+// не продлевалась с момента staleBefore. Возвращает false, если блокировка за это время была освобождена, продлена
+// или перехвачена другим процессом.
+func TakeOverLock(ctx context.Context, db *gorm.DB, service string, previousOwner string, owner string, now time.Time, staleBefore time.Time) (bool, error) {
+	res := db.Table(LockTable(db)).WithContext(ctx).
+		Where("service = ? AND owner = ? AND COALESCE(heartbeat_at, acquired_at) < ?", service, previousOwner, staleBefore).
+		Updates(map[string]interface{}{
+			"owner":        owner,
+			"acquired_at":  models.CustomTime{Time: now},
+			"heartbeat_at": models.CustomTime{Time: now},
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// HeartbeatLock продлевает аренду блокировки сервиса, принадлежащей владельцу owner. Возвращает false, если
+// блокировка владельцу больше не принадлежит.
+func HeartbeatLock(db *gorm.DB, service string, owner string, now time.Time) (bool, error) {
+	res := db.Table(LockTable(db)).Where("service = ? AND owner = ?", service, owner).
+		Update("heartbeat_at", models.CustomTime{Time: now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// GetLock возвращает запись блокировки сервиса.
+func GetLock(db *gorm.DB, service string) (models.LockModel, error) {
+	var locks []models.LockModel
+	err := db.Table(LockTable(db)).Where("service = ?", service).Limit(1).Find(&locks).Error
+	if err != nil {
+		return models.LockModel{}, err
+	}
+	if len(locks) == 0 {
+		return models.LockModel{}, ErrNotFound
+	}
+	return locks[0], nil
+}
+
+// Unlock удаляет запись блокировки сервиса, если она принадлежит владельцу owner.
+func Unlock(db *gorm.DB, service string, owner string) error {
+	return db.Table(LockTable(db)).Where("service = ? AND owner = ?", service, owner).Delete(&models.LockModel{}).Error
+}
+
+// DeleteLock удаляет запись блокировки сервиса независимо от владельца.
+func DeleteLock(db *gorm.DB, service string) error {
+	return db.Table(LockTable(db)).Where("service = ?", service).Delete(&models.LockModel{}).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MetaLibraryVersion    = "library_version"
+	MetaSchemaVersion     = "schema_version"
+	MetaMinLibraryVersion = "min_library_version"
+	MetaServiceClaims     = "service_claims"
+	// MetaDirty - есть миграции в состояниях failure и running ("true" или "false"), поддерживается TransitionState
+	MetaDirty = "dirty"
+)
+
+func GetMeta(db *gorm.DB, key string) (string, error) {
+	var row models.MetaModel
+	res := db.Table(MetaTable(db)).Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).Limit(1).Find(&row)
+
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return "", ErrNotFound
+	}
+
+	return row.Value, nil
+}
+
+func SaveMeta(db *gorm.DB, key string, value string) error {
+	_, err := GetMeta(db, key)
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return db.Table(MetaTable(db)).Create(&models.MetaModel{Key: key, Value: value}).Error
+	case err != nil:
+		return err
+	}
+
+	return db.Table(MetaTable(db)).Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).Update("value", value).Error
+}
+
+func HasMetaTable(db *gorm.DB) bool {
+	return hasTable(db, metaTableName)
+}
+
+func CreateMetaTable(db *gorm.DB) error {
+	return createTable(db, MetaTable(db), []column{
+		{name: "key", kind: columnKey, primaryKey: true},
+		{name: "value", kind: columnText},
+	})
+}
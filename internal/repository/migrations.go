@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"errors"
 	"github.com/Maksumys/db-migrator/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"hash/fnv"
+	"sort"
 	"time"
 )
 
@@ -14,23 +17,233 @@ const (
 	OrderDESC Order = "DESC"
 )
 
+// orderByRank возвращает сортировку по колонке rank. Имя колонки экранируется, так как rank - зарезервированное слово
+// mysql.
+func orderByRank(order Order) clause.OrderByColumn {
+	return clause.OrderByColumn{Column: clause.Column{Name: "rank"}, Desc: order == OrderDESC}
+}
+
 func GetMigrationsSorted(db *gorm.DB, order Order) ([]models.MigrationModel, error) {
 	var migrations []models.MigrationModel
-	err := db.Order("rank " + string(order)).Find(&migrations).Error
+	err := db.Table(MigrationsTable(db)).Order(orderByRank(order)).Find(&migrations).Error
+	return migrations, err
+}
+
+// GetMigrationKeys возвращает сохраненные миграции без описания и служебных полей (id, rank, type, version, state, checksum).
+// Используется для проверок, не требующих полного содержимого таблицы migrations.
+func GetMigrationKeys(db *gorm.DB) ([]models.MigrationModel, error) {
+	var migrations []models.MigrationModel
+	err := db.Table(MigrationsTable(db)).Select("id", "rank", "type", "version", "state", "checksum").Order(orderByRank(OrderASC)).Find(&migrations).Error
+	return migrations, err
+}
+
+// typeRepeatable - значение колонки type миграций типа db_migrator.TypeRepeatable.
+const typeRepeatable = "repeatable"
+
+// migrationsIdChunkSize - максимальное количество идентификаторов в одном запросе GetMigrationsByIds, не
+// превышающее ограничения диалектов на количество параметров запроса.
+const migrationsIdChunkSize = 500
+
+// GetMigrationsByIds возвращает миграции с идентификаторами ids, упорядоченные по rank. Миграции читаются частями по
+// migrationsIdChunkSize идентификаторов.
+func GetMigrationsByIds(db *gorm.DB, ids []uint32) ([]models.MigrationModel, error) {
+	migrations := make([]models.MigrationModel, 0, len(ids))
+	for start := 0; start < len(ids); start += migrationsIdChunkSize {
+		end := min(start+migrationsIdChunkSize, len(ids))
+
+		var chunk []models.MigrationModel
+		err := db.Table(MigrationsTable(db)).Where("id IN ?", ids[start:end]).Order(orderByRank(OrderASC)).Find(&chunk).Error
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, chunk...)
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Rank < migrations[j].Rank
+	})
+	return migrations, nil
+}
+
+// GetMigrationsAbove возвращает миграции с версией не ниже version, упорядоченные по rank. Версии сравниваются по
+// ключевым полям миграций (см. GetMigrationKeys), полные строки читаются только для отобранных миграций.
+func GetMigrationsAbove(db *gorm.DB, version models.Version) ([]models.MigrationModel, error) {
+	keys, err := GetMigrationKeys(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint32, 0)
+	for i := range keys {
+		if keys[i].Version.MoreOrEqual(version) {
+			ids = append(ids, keys[i].Id)
+		}
+	}
+
+	return GetMigrationsByIds(db, ids)
+}
+
+// GetMigrationsOfType возвращает сохраненные миграции типа migrationType, упорядоченные по rank.
+func GetMigrationsOfType(db *gorm.DB, migrationType string) ([]models.MigrationModel, error) {
+	var migrations []models.MigrationModel
+	err := db.Table(MigrationsTable(db)).Where("type = ?", migrationType).Order(orderByRank(OrderASC)).Find(&migrations).Error
+	return migrations, err
+}
+
+// GetRepeatables возвращает сохраненные миграции типа repeatable, упорядоченные по rank.
+func GetRepeatables(db *gorm.DB) ([]models.MigrationModel, error) {
+	return GetMigrationsOfType(db, typeRepeatable)
+}
+
+// GetPendingOutOfOrder возвращает невыполненные миграции, зарегистрированные вне очереди (см.
+// models.MigrationModel.OutOfOrder), упорядоченные по rank. Таблица, созданная версией библиотеки без поддержки
+// таких миграций, их не содержит.
+func GetPendingOutOfOrder(db *gorm.DB) ([]models.MigrationModel, error) {
+	var migrations []models.MigrationModel
+	if !hasColumn(db, migrationsTableName, "out_of_order") {
+		return migrations, nil
+	}
+
+	err := db.Table(MigrationsTable(db)).Where("out_of_order = ? AND state <> ?", true, models.StateSuccess).
+		Order(orderByRank(OrderASC)).Find(&migrations).Error
+	return migrations, err
+}
+
+// GetMigrationsExecutedBetween возвращает миграции, время последнего выполнения которых находится в интервале
+// [from, to), упорядоченные по времени выполнения.
+func GetMigrationsExecutedBetween(db *gorm.DB, from time.Time, to time.Time) ([]models.MigrationModel, error) {
+	var migrations []models.MigrationModel
+	err := db.Table(MigrationsTable(db)).Where("executed_on >= ? AND executed_on < ?", from, to).
+		Order("executed_on ASC").Order(orderByRank(OrderASC)).Find(&migrations).Error
 	return migrations, err
 }
 
-func UpdateMigrationState(db *gorm.DB, model *models.MigrationModel, state models.MigrationState) error {
-	return db.Model(model).Update("state", state).Error
+// GetMaxRank возвращает максимальный rank сохраненных миграций или 0, если миграции не сохранены.
+func GetMaxRank(db *gorm.DB) (int, error) {
+	var maxRank *int
+	err := db.Table(MigrationsTable(db)).Select("MAX(?)", clause.Column{Name: "rank"}).Scan(&maxRank).Error
+	if err != nil || maxRank == nil {
+		return 0, err
+	}
+	return *maxRank, nil
+}
+
+// CountMigrationsInState возвращает количество сохраненных миграций в одном из состояний states.
+func CountMigrationsInState(db *gorm.DB, states ...models.MigrationState) (int64, error) {
+	var count int64
+	err := db.Table(MigrationsTable(db)).Where("state IN ?", states).Count(&count).Error
+	return count, err
 }
 
-func UpdateMigrationStateExecuted(db *gorm.DB, model *models.MigrationModel, state models.MigrationState, checksum string) error {
+// ExecutedBy - процесс, выполнивший или отменивший миграцию.
+type ExecutedBy struct {
+	AppliedBy  string
+	AppVersion string
+	// RunLabels - метки запуска в JSON, пустая строка, если метки не заданы
+	RunLabels string
+}
+
+// UpdateMigrationExecuted сохраняет время выполнения, контрольную сумму миграции и выполнивший ее процесс. Состояние
+// изменяется отдельно через TransitionState.
+func UpdateMigrationExecuted(db *gorm.DB, model *models.MigrationModel, checksum string, executedBy ExecutedBy) error {
 	now := time.Now().UTC()
-	return db.Model(model).Updates(models.MigrationModel{
+	err := db.Table(MigrationsTable(db)).Model(model).Updates(models.MigrationModel{
 		ExecutedOn: &models.CustomTime{Time: now},
-		State:      state,
 		Checksum:   checksum,
+		AppliedBy:  executedBy.AppliedBy,
+		AppVersion: executedBy.AppVersion,
 	}).Error
+	if err != nil {
+		return err
+	}
+
+	// метки сохраняются и пустыми, чтобы не оставлять метки предыдущего запуска
+	return db.Table(MigrationsTable(db)).Model(model).Update("run_labels", executedBy.RunLabels).Error
+}
+
+// MarkMigrationRunning переводит миграцию в состояние StateRunning и сохраняет время начала выполнения в одной
+// транзакции. Возвращает состояние миграции до изменения.
+func MarkMigrationRunning(db *gorm.DB, model *models.MigrationModel) (models.MigrationState, error) {
+	var from models.MigrationState
+	startedAt := &models.CustomTime{Time: time.Now().UTC()}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var states []models.MigrationState
+		err := tx.Table(MigrationsTable(tx)).Where("id = ?", model.Id).Pluck("state", &states).Error
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return ErrNotFound
+		}
+		from = states[0]
+
+		err = TransitionState(tx, model, models.StateRunning, "execution started")
+		if err != nil {
+			return err
+		}
+
+		return tx.Table(MigrationsTable(tx)).Model(model).Update("started_at", startedAt).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	model.StartedAt = startedAt
+
+	return from, nil
+}
+
+func UpdateMigrationDescription(db *gorm.DB, model *models.MigrationModel, description string) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("description", description).Error
+}
+
+func UpdateMigrationChecksum(db *gorm.DB, model *models.MigrationModel, checksum string) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("checksum", checksum).Error
+}
+
+func UpdateMigrationFailedAttempts(db *gorm.DB, model *models.MigrationModel, attempts int) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("failed_attempts", attempts).Error
+}
+
+func UpdateMigrationRowsAffected(db *gorm.DB, model *models.MigrationModel, rowsAffected int64) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("rows_affected", rowsAffected).Error
+}
+
+// UpdateMigrationLastStatement сохраняет количество успешно выполненных выражений нетранзакционной миграции.
+func UpdateMigrationLastStatement(db *gorm.DB, model *models.MigrationModel, lastStatement int) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("last_statement", lastStatement).Error
+}
+
+// UpdateMigrationLastError сохраняет ошибки необязательных шагов миграции.
+func UpdateMigrationLastError(db *gorm.DB, model *models.MigrationModel, lastError string) error {
+	return db.Table(MigrationsTable(db)).Model(model).Update("last_error", lastError).Error
+}
+
+// LockMigration блокирует строку миграции до завершения транзакции db. Для диалектов, поддерживающих NOWAIT,
+// при занятой блокировке запрос сразу завершается ошибкой. Для остальных диалектов используется SKIP LOCKED: занятая
+// строка не возвращается, и LockMigration возвращает ErrLocked, не ожидая освобождения блокировки.
+func LockMigration(db *gorm.DB, id uint32) (models.MigrationModel, error) {
+	var migration models.MigrationModel
+
+	query := db.Table(MigrationsTable(db)).Where("id = ?", id)
+	skipLocked := false
+	switch db.Dialector.Name() {
+	case "postgres", "mysql":
+		query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsNoWait})
+	case "sqlite", "sqlserver":
+		// sqlite блокирует базу данных целиком при записи, блокировка строк не поддерживается; sqlserver не
+		// поддерживает FOR UPDATE, выполнение миграций сервиса сериализуется блокировкой migrator_lock
+	default:
+		// обычный FOR UPDATE ожидал бы освобождения блокировки зависшим процессом без ограничения времени
+		query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+		skipLocked = true
+	}
+
+	err := query.First(&migration).Error
+	if skipLocked && errors.Is(err, gorm.ErrRecordNotFound) {
+		return migration, ErrLocked
+	}
+	return migration, err
 }
 
 type SaveMigrationRequest struct {
@@ -39,40 +252,108 @@ type SaveMigrationRequest struct {
 	Version     models.Version
 	Description string
 	State       models.MigrationState
+	// OutOfOrder - см. models.MigrationModel.OutOfOrder
+	OutOfOrder bool
 }
 
 func SaveMigration(db *gorm.DB, request SaveMigrationRequest) (models.MigrationModel, error) {
+	migration := newMigrationModel(request, time.Now().UTC())
+	return migration, db.Table(MigrationsTable(db)).Save(&migration).Error
+}
+
+// SaveMigrations сохраняет миграции пачками по batchSize записей. При batchSize <= 1 миграции сохраняются по одной.
+// Возвращает сохраненные миграции в порядке requests.
+func SaveMigrations(db *gorm.DB, requests []SaveMigrationRequest, batchSize int) ([]models.MigrationModel, error) {
+	if batchSize <= 1 {
+		migrations := make([]models.MigrationModel, 0, len(requests))
+		for i := range requests {
+			migration, err := SaveMigration(db, requests[i])
+			if err != nil {
+				return nil, err
+			}
+			migrations = append(migrations, migration)
+		}
+		return migrations, nil
+	}
+
+	now := time.Now().UTC()
+	migrations := make([]models.MigrationModel, 0, len(requests))
+	for i := range requests {
+		migrations = append(migrations, newMigrationModel(requests[i], now))
+	}
+
+	if len(migrations) == 0 {
+		return migrations, nil
+	}
+
+	return migrations, db.Table(MigrationsTable(db)).CreateInBatches(&migrations, batchSize).Error
+}
+
+// NewMigrationModels возвращает модели миграций, которые были бы сохранены SaveMigrations, не сохраняя их.
+func NewMigrationModels(requests []SaveMigrationRequest) []models.MigrationModel {
+	now := time.Now().UTC()
+	migrations := make([]models.MigrationModel, 0, len(requests))
+	for i := range requests {
+		migrations = append(migrations, newMigrationModel(requests[i], now))
+	}
+	return migrations
+}
+
+func newMigrationModel(request SaveMigrationRequest, registeredOn time.Time) models.MigrationModel {
 	h := fnv.New32a()
 	_, _ = h.Write([]byte(request.Type + request.Version.String()))
-	migration := models.MigrationModel{
+	return models.MigrationModel{
 		Id:           h.Sum32(),
 		Rank:         request.Rank,
 		Type:         request.Type,
 		Version:      request.Version,
 		Description:  request.Description,
-		RegisteredOn: models.CustomTime{Time: time.Now().UTC()},
+		RegisteredOn: models.CustomTime{Time: registeredOn},
 		State:        request.State,
+		OutOfOrder:   request.OutOfOrder,
 	}
-
-	return migration, db.Save(&migration).Error
 }
 
 func HasMigrationsTable(db *gorm.DB) bool {
-	return db.Migrator().HasTable(models.MigrationModel{}.TableName())
+	return hasTable(db, migrationsTableName)
 }
 
 func CreateMigrationsTable(db *gorm.DB) error {
-	return db.Exec(`
-		CREATE TABLE IF NOT EXISTS migrations (
-			id NUMERIC PRIMARY KEY,
-			rank BIGINT,
-			type TEXT,
-			version TEXT,
-			description TEXT,
-			registered_on TIMESTAMPTZ,
-			executed_on TIMESTAMPTZ,
-			checksum TEXT,
-			state TEXT
-		)
-	`).Error
+	return createTable(db, MigrationsTable(db), append([]column{
+		{name: "id", kind: columnMigrationId, primaryKey: true},
+		{name: "rank", kind: columnBigInt},
+		{name: "type", kind: columnText},
+		{name: "version", kind: columnText},
+		{name: "description", kind: columnText},
+		{name: "registered_on", kind: columnTimestamp},
+		{name: "executed_on", kind: columnTimestamp},
+		{name: "checksum", kind: columnText},
+		{name: "state", kind: columnText},
+	}, migrationsTableColumns...))
+}
+
+// migrationsTableColumns - колонки таблицы migrations, появившиеся в новых версиях библиотеки.
+var migrationsTableColumns = []column{
+	{name: "rows_affected", kind: columnBigInt},
+	{name: "failed_attempts", kind: columnBigInt},
+	{name: "last_statement", kind: columnBigInt},
+	{name: "applied_by", kind: columnText},
+	{name: "app_version", kind: columnText},
+	{name: "out_of_order", kind: columnBool},
+	{name: "started_at", kind: columnTimestamp},
+	{name: "last_error", kind: columnText},
+	{name: "run_labels", kind: columnText},
+}
+
+// MigrateMigrationsTable добавляет в существующую таблицу migrations колонки, появившиеся в новых версиях библиотеки.
+func MigrateMigrationsTable(db *gorm.DB) error {
+	for _, column := range migrationsTableColumns {
+		if hasColumn(db, migrationsTableName, column.name) {
+			continue
+		}
+		if err := addColumn(db, MigrationsTable(db), column); err != nil {
+			return err
+		}
+	}
+	return nil
 }
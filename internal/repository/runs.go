@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+func SaveRun(db *gorm.DB, run *models.RunModel) error {
+	return db.Table(RunsTable(db)).Save(run).Error
+}
+
+// GetRuns возвращает последние limit запусков сервиса, начиная с самого нового.
+func GetRuns(db *gorm.DB, service string, limit int) ([]models.RunModel, error) {
+	var runs []models.RunModel
+	query := db.Table(RunsTable(db)).Where("service = ?", service).Order("started_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// GetLastSchemaSnapshot возвращает снимок схемы, сохраненный последним завершенным запуском сервиса.
+func GetLastSchemaSnapshot(db *gorm.DB, service string) (string, error) {
+	var runs []models.RunModel
+	err := db.Table(RunsTable(db)).Where("service = ? AND schema_snapshot IS NOT NULL AND schema_snapshot <> ''", service).
+		Order("started_at DESC").Order("id DESC").Limit(1).Find(&runs).Error
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", ErrNotFound
+	}
+	return runs[0].SchemaSnapshot, nil
+}
+
+func HasRunsTable(db *gorm.DB) bool {
+	return hasTable(db, runsTableName)
+}
+
+func CreateRunsTable(db *gorm.DB) error {
+	return createTable(db, RunsTable(db), []column{
+		{name: "id", kind: columnBigInt, primaryKey: true},
+		{name: "service", kind: columnText},
+		{name: "direction", kind: columnText},
+		{name: "target_version", kind: columnText},
+		{name: "started_at", kind: columnTimestamp},
+		{name: "finished_at", kind: columnTimestamp},
+		{name: "applied", kind: columnBigInt},
+		{name: "skipped", kind: columnBigInt},
+		{name: "failed", kind: columnBigInt},
+		{name: "rows_affected", kind: columnBigInt},
+		{name: "final_version", kind: columnText},
+		{name: "triggered_by", kind: columnText},
+		{name: "library_version", kind: columnText},
+		{name: "fingerprint", kind: columnText},
+		{name: "plan_hash", kind: columnText},
+		{name: "error", kind: columnText},
+		{name: "labels", kind: columnText},
+		{name: "schema_snapshot", kind: columnText},
+		{name: "phase_timings", kind: columnText},
+	})
+}
+
+// runsTableColumns - колонки таблицы migration_runs, появившиеся в новых версиях библиотеки.
+var runsTableColumns = []column{
+	{name: "labels", kind: columnText},
+	{name: "target_version", kind: columnText},
+	{name: "schema_snapshot", kind: columnText},
+	{name: "phase_timings", kind: columnText},
+}
+
+// MigrateRunsTable добавляет в существующую таблицу migration_runs колонки, появившиеся в новых версиях библиотеки.
+func MigrateRunsTable(db *gorm.DB) error {
+	for _, column := range runsTableColumns {
+		if hasColumn(db, runsTableName, column.name) {
+			continue
+		}
+		if err := addColumn(db, RunsTable(db), column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLastRunFinishedBefore возвращает последний запуск сервиса, завершенный не позднее t и сохранивший итоговую
+// версию.
+func GetLastRunFinishedBefore(db *gorm.DB, service string, t time.Time) (models.RunModel, error) {
+	var runs []models.RunModel
+	err := db.Table(RunsTable(db)).Where("service = ? AND finished_at IS NOT NULL AND finished_at <= ? AND final_version <> ''", service, t).
+		Order("finished_at DESC").Order("id DESC").Limit(1).Find(&runs).Error
+	if err != nil {
+		return models.RunModel{}, err
+	}
+	if len(runs) == 0 {
+		return models.RunModel{}, ErrNotFound
+	}
+	return runs[0], nil
+}
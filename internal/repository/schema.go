@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// requiredMigrationsColumns - колонки таблицы migrations, присутствующие во всех версиях библиотеки.
+var requiredMigrationsColumns = []string{
+	"id", "rank", "type", "version", "description", "registered_on", "executed_on", "checksum", "state",
+}
+
+var requiredVersionColumns = []string{"version"}
+
+// TableColumnsDiff содержит расхождения колонок существующей таблицы с ожидаемыми.
+type TableColumnsDiff struct {
+	Missing    []string
+	Unexpected []string
+}
+
+// CheckMigrationsTableColumns сравнивает колонки таблицы migrations с ожидаемыми. Колонки, добавляемые
+// MigrateMigrationsTable, не считаются отсутствующими.
+func CheckMigrationsTableColumns(db *gorm.DB) (TableColumnsDiff, error) {
+	optional := make([]string, 0, len(migrationsTableColumns))
+	for _, column := range migrationsTableColumns {
+		optional = append(optional, column.name)
+	}
+	return checkTableColumns(db, MigrationsTable(db), requiredMigrationsColumns, optional)
+}
+
+// CheckVersionTableColumns сравнивает колонки таблицы version с ожидаемыми. Колонки, добавляемые
+// MigrateVersionTable, не считаются отсутствующими.
+func CheckVersionTableColumns(db *gorm.DB) (TableColumnsDiff, error) {
+	optional := make([]string, 0, len(versionTableColumns))
+	for _, column := range versionTableColumns {
+		optional = append(optional, column.name)
+	}
+	return checkTableColumns(db, VersionTable(db), requiredVersionColumns, optional)
+}
+
+func checkTableColumns(db *gorm.DB, table string, required []string, optional []string) (TableColumnsDiff, error) {
+	columnTypes, err := db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return TableColumnsDiff{}, err
+	}
+
+	existing := make(map[string]struct{}, len(columnTypes))
+	for _, columnType := range columnTypes {
+		existing[strings.ToLower(columnType.Name())] = struct{}{}
+	}
+
+	diff := TableColumnsDiff{}
+	known := make(map[string]struct{}, len(required)+len(optional))
+	for _, column := range required {
+		known[column] = struct{}{}
+		if _, ok := existing[column]; !ok {
+			diff.Missing = append(diff.Missing, column)
+		}
+	}
+	for _, column := range optional {
+		known[column] = struct{}{}
+	}
+
+	for _, columnType := range columnTypes {
+		if _, ok := known[strings.ToLower(columnType.Name())]; !ok {
+			diff.Unexpected = append(diff.Unexpected, columnType.Name())
+		}
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// Базовые имена системных таблиц сервиса. К ним добавляются префикс и схема сервиса (см. TableNames).
+const (
+	migrationsTableName     = "migrations"
+	versionTableName        = "version"
+	versionHistoryTableName = "version_history"
+	stateHistoryTableName   = "migration_state_history"
+	runsTableName           = "migration_runs"
+	metaTableName           = "migrator_meta"
+	lockTableName           = "migrator_lock"
+)
+
+// scopedTables - таблицы сервиса, переносимые AdoptUnscopedTables.
+var scopedTables = []string{migrationsTableName, versionTableName, versionHistoryTableName, stateHistoryTableName}
+
+// sharedTables - таблицы, которые без префикса и схемы используются всеми сервисами базы данных. Размещаются согласно
+// префиксу и схеме соединения, но не переносятся AdoptUnscopedTables: таблицы без префикса могут принадлежать другим
+// сервисам.
+var sharedTables = []string{runsTableName, metaTableName, lockTableName}
+
+// TableNames - размещение системных таблиц сервиса: migrations, version, version_history, migration_state_history,
+// migration_runs, migrator_meta и migrator_lock.
+type TableNames struct {
+	// Prefix добавляется к именам всех таблиц
+	Prefix string
+	// Schema - схема (для mysql - база данных), в которой размещаются таблицы. Пустое значение - текущая схема
+	Schema string
+	// Version и Migrations заменяют имена таблиц version и migrations
+	Version    string
+	Migrations string
+}
+
+type tableNamesKey struct{}
+
+// WithTableNames возвращает соединение, запросы репозитория через которое используют системные таблицы, размещенные
+// согласно names. Размещение передается через контекст соединения и сохраняется в транзакциях и сессиях, полученных
+// из него.
+func WithTableNames(db *gorm.DB, names TableNames) *gorm.DB {
+	if db == nil || names == (TableNames{}) {
+		return db
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, tableNamesKey{}, names))
+}
+
+func tableNamesOf(db *gorm.DB) TableNames {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return TableNames{}
+	}
+
+	names, _ := db.Statement.Context.Value(tableNamesKey{}).(TableNames)
+	return names
+}
+
+// TablePrefix возвращает префикс системных таблиц соединения.
+func TablePrefix(db *gorm.DB) string {
+	return tableNamesOf(db).Prefix
+}
+
+// tableName возвращает имя системной таблицы base без схемы.
+func tableName(db *gorm.DB, base string) string {
+	names := tableNamesOf(db)
+
+	name := base
+	switch {
+	case base == migrationsTableName && len(names.Migrations) > 0:
+		name = names.Migrations
+	case base == versionTableName && len(names.Version) > 0:
+		name = names.Version
+	}
+	return names.Prefix + name
+}
+
+// qualifiedTableName возвращает имя системной таблицы base со схемой.
+func qualifiedTableName(db *gorm.DB, base string) string {
+	name := tableName(db, base)
+	if schema := tableNamesOf(db).Schema; len(schema) > 0 {
+		return schema + "." + name
+	}
+	return name
+}
+
+// quote экранирует имя таблицы или схемы для использования в запросе.
+func quote(db *gorm.DB, name string) string {
+	return db.Statement.Quote(name)
+}
+
+// MigrationsTable возвращает имя таблицы migrations соединения.
+func MigrationsTable(db *gorm.DB) string {
+	return qualifiedTableName(db, migrationsTableName)
+}
+
+// VersionTable возвращает имя таблицы version соединения.
+func VersionTable(db *gorm.DB) string {
+	return qualifiedTableName(db, versionTableName)
+}
+
+// VersionHistoryTable возвращает имя таблицы version_history соединения.
+func VersionHistoryTable(db *gorm.DB) string {
+	return qualifiedTableName(db, versionHistoryTableName)
+}
+
+// StateHistoryTable возвращает имя таблицы migration_state_history соединения.
+func StateHistoryTable(db *gorm.DB) string {
+	return qualifiedTableName(db, stateHistoryTableName)
+}
+
+// RunsTable возвращает имя таблицы migration_runs соединения.
+func RunsTable(db *gorm.DB) string {
+	return qualifiedTableName(db, runsTableName)
+}
+
+// MetaTable возвращает имя таблицы migrator_meta соединения.
+func MetaTable(db *gorm.DB) string {
+	return qualifiedTableName(db, metaTableName)
+}
+
+// LockTable возвращает имя таблицы migrator_lock соединения.
+func LockTable(db *gorm.DB) string {
+	return qualifiedTableName(db, lockTableName)
+}
+
+// ScopedTableNames возвращает имена системных таблиц сервиса без схемы.
+func ScopedTableNames(db *gorm.DB) []string {
+	tables := make([]string, 0, len(scopedTables)+len(sharedTables))
+	for _, base := range append(slices.Clone(scopedTables), sharedTables...) {
+		tables = append(tables, tableName(db, base))
+	}
+	return tables
+}
+
+// hasTable проверяет наличие системной таблицы base с учетом схемы соединения.
+func hasTable(db *gorm.DB, base string) bool {
+	name := tableName(db, base)
+	schema := tableNamesOf(db).Schema
+	if len(schema) == 0 {
+		return db.Migrator().HasTable(name)
+	}
+
+	var count int64
+	var err error
+	switch db.Dialector.Name() {
+	case "sqlite":
+		err = db.Raw("SELECT count(*) FROM "+quote(db, schema)+".sqlite_master WHERE type = 'table' AND name = ?", name).
+			Row().Scan(&count)
+	default:
+		err = db.Raw(
+			"SELECT count(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ? AND table_type = 'BASE TABLE'",
+			schema, name,
+		).Row().Scan(&count)
+	}
+	return err == nil && count > 0
+}
+
+// hasColumn проверяет наличие колонки column в системной таблице base с учетом схемы соединения.
+func hasColumn(db *gorm.DB, base string, column string) bool {
+	name := tableName(db, base)
+	schema := tableNamesOf(db).Schema
+	if len(schema) == 0 {
+		return db.Migrator().HasColumn(name, column)
+	}
+
+	var count int64
+	var err error
+	switch db.Dialector.Name() {
+	case "sqlite":
+		err = db.Raw("SELECT count(*) FROM pragma_table_info(?, ?) WHERE name = ?", name, schema, column).Row().Scan(&count)
+	default:
+		err = db.Raw(
+			"SELECT count(*) FROM information_schema.columns WHERE table_schema = ? AND table_name = ? AND column_name = ?",
+			schema, name, column,
+		).Row().Scan(&count)
+	}
+	return err == nil && count > 0
+}
+
+// CreateSchema создает схему системных таблиц соединения, если она задана и не существует. Схема создается только
+// для postgres, для остальных диалектов она должна существовать.
+func CreateSchema(db *gorm.DB) error {
+	schema := tableNamesOf(db).Schema
+	if len(schema) == 0 || db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec("CREATE SCHEMA IF NOT EXISTS " + quote(db, schema)).Error
+}
+
+// AdoptUnscopedTables переименовывает системные таблицы без префикса в таблицы с префиксом соединения, если таблицы
+// с префиксом еще не созданы. Таблицы не переименовываются, если для соединения заданы схема или имена таблиц.
+// Возвращает имена переименованных таблиц.
+func AdoptUnscopedTables(db *gorm.DB) ([]string, error) {
+	names := tableNamesOf(db)
+	if len(names.Prefix) == 0 || names != (TableNames{Prefix: names.Prefix}) {
+		return nil, nil
+	}
+	if hasTable(db, migrationsTableName) || !db.Migrator().HasTable(migrationsTableName) {
+		return nil, nil
+	}
+
+	adopted := make([]string, 0, len(scopedTables))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, base := range scopedTables {
+			if !tx.Migrator().HasTable(base) || hasTable(tx, base) {
+				continue
+			}
+			if err := renameTable(tx, base, tableName(tx, base)); err != nil {
+				return err
+			}
+			adopted = append(adopted, base)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return adopted, nil
+}
+
+func renameTable(db *gorm.DB, from string, to string) error {
+	if db.Dialector.Name() == "sqlserver" {
+		return db.Exec("EXEC sp_rename ?, ?", from, to).Error
+	}
+	return db.Exec("ALTER TABLE " + quote(db, from) + " RENAME TO " + quote(db, to)).Error
+}
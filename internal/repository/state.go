@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTransition = errors.New("invalid migration state transition")
+
+// allowedTransitions - допустимые изменения состояния миграции. Изменение в то же состояние допускается, только если
+// оно указано явно (например, повторное выполнение миграции типа repeatable).
+var allowedTransitions = map[models.MigrationState][]models.MigrationState{
+	// зарегистрированная миграция выполняется, пропускается (ниже baseline или вне диапазона версий), отмечается
+	// отсутствующей (код миграции типа repeatable удален) или исключается из выполнения (Abandon)
+	models.StateRegistered: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed,
+		models.StateSkipped, models.StateNotFound, models.StateAbandoned, models.StateRunning,
+	},
+	// миграция, завершившаяся ошибкой, выполняется повторно, сбрасывается Repair или пропускается baseline
+	models.StateFailure: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed,
+		models.StateRegistered, models.StateSkipped, models.StateNotFound, models.StateRunning,
+	},
+	models.StateFailedAllowed: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed,
+		models.StateSkipped, models.StateNotFound, models.StateRunning,
+	},
+	// выполненная миграция выполняется повторно (repeatable, Rerun), отменяется или пропускается (repeatable вне
+	// диапазона версий)
+	models.StateSuccess: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed,
+		models.StateUndone, models.StateSkipped, models.StateNotFound, models.StateRunning,
+	},
+	// отмененная миграция выполняется повторно
+	models.StateUndone: {
+		models.StateSuccess, models.StateFailure, models.StateRunning,
+	},
+	// пропущенная миграция выполняется (ApplyOne, MarkApplied, repeatable в диапазоне версий), отменяется Downgrade
+	// ниже baseline или снова пропускается
+	models.StateSkipped: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed,
+		models.StateUndone, models.StateSkipped, models.StateNotFound, models.StateRunning,
+	},
+	// выполнение нетранзакционной миграции завершается, при ошибке миграция возвращается в исходное состояние;
+	// прерванная миграция отмечается ошибкой (RunOptions.ResumeInterrupted) или сбрасывается Repair
+	models.StateRunning: {
+		models.StateSuccess, models.StateFailure, models.StateFailedAllowed, models.StateUndone,
+		models.StateRegistered, models.StateSkipped,
+	},
+	// код миграции типа repeatable зарегистрирован снова
+	models.StateNotFound: {
+		models.StateRegistered, models.StateNotFound,
+	},
+	// исключенная миграция не изменяется
+	models.StateAbandoned: {},
+}
+
+// CanTransition определяет, допускается ли изменение состояния миграции from на to.
+func CanTransition(from models.MigrationState, to models.MigrationState) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionState изменяет состояние миграции на to и сохраняет изменение с причиной reason в таблицу
+// migration_state_history. Текущее состояние читается из таблицы migrations; если изменение не допускается
+// allowedTransitions, возвращается ErrInvalidTransition и состояние не изменяется.
+func TransitionState(db *gorm.DB, model *models.MigrationModel, to models.MigrationState, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var states []models.MigrationState
+		err := tx.Table(MigrationsTable(tx)).Where("id = ?", model.Id).Pluck("state", &states).Error
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return ErrNotFound
+		}
+
+		from := states[0]
+		if !CanTransition(from, to) {
+			return fmt.Errorf(
+				"%w: migration (type: %s, version: %s) from %q to %q (%s)",
+				ErrInvalidTransition, model.Type, model.Version, from, to, reason,
+			)
+		}
+
+		err = tx.Table(MigrationsTable(tx)).Model(model).Update("state", to).Error
+		if err != nil {
+			return err
+		}
+		model.State = to
+
+		err = tx.Table(StateHistoryTable(tx)).Create(&models.StateHistoryModel{
+			MigrationId: model.Id,
+			Type:        model.Type,
+			Version:     model.Version,
+			FromState:   from,
+			ToState:     to,
+			Reason:      reason,
+			ChangedOn:   models.CustomTime{Time: time.Now().UTC()},
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if isDirtyState(from) || isDirtyState(to) {
+			return SyncDirtyFlag(tx)
+		}
+		return nil
+	})
+}
+
+// dirtyStates - состояния миграций, при наличии которых база данных считается dirty: миграция завершилась ошибкой
+// или выполнение нетранзакционной миграции не завершено (в том числе прервано).
+var dirtyStates = []models.MigrationState{models.StateFailure, models.StateRunning}
+
+func isDirtyState(state models.MigrationState) bool {
+	for _, dirty := range dirtyStates {
+		if state == dirty {
+			return true
+		}
+	}
+	return false
+}
+
+// CountDirtyMigrations возвращает количество сохраненных миграций в состояниях failure и running.
+func CountDirtyMigrations(db *gorm.DB) (int64, error) {
+	return CountMigrationsInState(db, dirtyStates...)
+}
+
+// SyncDirtyFlag сохраняет в migrator_meta признак наличия миграций в состояниях failure и running. Если таблица
+// migrator_meta не создана, признак не сохраняется.
+func SyncDirtyFlag(db *gorm.DB) error {
+	if !HasMetaTable(db) {
+		return nil
+	}
+
+	dirty, err := CountDirtyMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	return SaveMeta(db, MetaDirty, strconv.FormatBool(dirty > 0))
+}
+
+func CreateStateHistoryTable(db *gorm.DB) error {
+	return createTable(db, StateHistoryTable(db), []column{
+		{name: "migration_id", kind: columnMigrationId},
+		{name: "type", kind: columnText},
+		{name: "version", kind: columnText},
+		{name: "from_state", kind: columnText},
+		{name: "to_state", kind: columnText},
+		{name: "reason", kind: columnText},
+		{name: "changed_on", kind: columnTimestamp},
+	})
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from models.MigrationState
+		to   models.MigrationState
+		want bool
+	}{
+		{from: models.StateRegistered, to: models.StateSuccess, want: true},
+		{from: models.StateRegistered, to: models.StateRegistered, want: false},
+		{from: models.StateRegistered, to: models.StateUndone, want: false},
+		{from: models.StateSuccess, to: models.StateSuccess, want: true},
+		{from: models.StateSuccess, to: models.StateUndone, want: true},
+		{from: models.StateSuccess, to: models.StateSkipped, want: true},
+		{from: models.StateSuccess, to: models.StateRegistered, want: false},
+		{from: models.StateFailure, to: models.StateRegistered, want: true},
+		{from: models.StateUndone, to: models.StateSkipped, want: false},
+		{from: models.StateUndone, to: models.StateSuccess, want: true},
+		{from: models.StateSkipped, to: models.StateSkipped, want: true},
+		{from: models.StateRunning, to: models.StateFailure, want: true},
+		{from: models.StateRunning, to: models.StateRunning, want: false},
+		{from: models.StateNotFound, to: models.StateRegistered, want: true},
+		{from: models.StateNotFound, to: models.StateSuccess, want: false},
+		{from: models.StateAbandoned, to: models.StateRegistered, want: false},
+		{from: models.StateAbandoned, to: models.StateAbandoned, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			require.Equal(t, tt.want, CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestDirtyStates(t *testing.T) {
+	for _, state := range []models.MigrationState{
+		models.StateSuccess, models.StateUndone, models.StateRegistered, models.StateSkipped,
+		models.StateNotFound, models.StateAbandoned, models.StateFailedAllowed,
+	} {
+		require.False(t, isDirtyState(state), state)
+	}
+	require.True(t, isDirtyState(models.StateFailure))
+	require.True(t, isDirtyState(models.StateRunning))
+}
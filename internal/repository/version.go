@@ -1,51 +1,228 @@
 package repository
 
 import (
-	"errors"
+	"fmt"
 	"github.com/Maksumys/db-migrator/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
+// VersionSource описывает миграцию, в результате выполнения или отмены которой сохраняется версия.
+type VersionSource struct {
+	Version string
+	Type    string
+	// Rank - rank миграции
+	Rank int
+	// Direction - направление изменения версии (up или down), сохраняемое в таблицу version_history
+	Direction string
+}
+
 func GetVersion(db *gorm.DB) (models.Version, error) {
-	var row models.VersionModel
-	res := db.First(&row)
+	row, err := GetVersionRow(db)
+	if err != nil {
+		return models.Version{}, err
+	}
+	return row.Version, nil
+}
 
-	if res.Error != nil {
-		switch {
-		case errors.Is(res.Error, gorm.ErrRecordNotFound):
-			return models.Version{}, ErrNotFound
-		default:
-			return models.Version{}, res.Error
-		}
+// GetVersionRow возвращает сохраненную версию вместе с информацией о миграции, которая ее установила. Если таблица
+// содержит несколько строк с разными версиями, возвращается ErrConflictingVersions (см. RepairVersionTable).
+func GetVersionRow(db *gorm.DB) (models.VersionModel, error) {
+	var rows []models.VersionModel
+	err := db.Table(VersionTable(db)).Find(&rows).Error
+	if err != nil {
+		return models.VersionModel{}, err
 	}
 
-	if res.RowsAffected == 0 {
-		return models.Version{}, ErrNotFound
+	if len(rows) == 0 {
+		return models.VersionModel{}, ErrNotFound
 	}
 
-	return row.Version, nil
+	for i := range rows[1:] {
+		if !rows[i+1].Version.Equals(rows[0].Version) {
+			return models.VersionModel{}, fmt.Errorf("%w: %d rows", ErrConflictingVersions, len(rows))
+		}
+	}
+
+	return rows[0], nil
 }
 
-func SaveVersion(db *gorm.DB, version models.Version) error {
-	var row models.VersionModel
-	count := db.Find(&row).RowsAffected
+// SaveVersion сохраняет текущую версию в единственную строку таблицы version, удаляя лишние строки, и добавляет запись
+// об изменении в таблицу version_history.
+func SaveVersion(db *gorm.DB, version models.Version, source VersionSource) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(VersionTable(tx)).Where("id IS NULL OR id <> ?", models.VersionRowId).Delete(&models.VersionModel{}).Error
+		if err != nil {
+			return err
+		}
 
-	if count == 0 {
-		_ = db.Create(&models.VersionModel{Version: version}).Error
-		return nil
-	}
+		setAt := &models.CustomTime{Time: time.Now()}
+
+		err = tx.Table(VersionTable(tx)).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"version", "set_by_version", "set_by_type", "set_at"}),
+		}).Create(&models.VersionModel{
+			Id:           models.VersionRowId,
+			Version:      version,
+			SetByVersion: source.Version,
+			SetByType:    source.Type,
+			SetAt:        setAt,
+		}).Error
+		if err != nil {
+			return err
+		}
 
-	return db.Model(&models.VersionModel{}).Where("version = ?", row.Version).Update("version", version).Error
+		return tx.Table(VersionHistoryTable(tx)).Create(&models.VersionHistoryModel{
+			Version:       version,
+			Direction:     source.Direction,
+			MigrationRank: source.Rank,
+			ReachedOn:     models.CustomTime{Time: setAt.UTC()},
+		}).Error
+	})
 }
 
 func HasVersionTable(db *gorm.DB) bool {
-	return db.Migrator().HasTable(models.VersionModel{}.TableName())
+	return hasTable(db, versionTableName)
 }
 
 func CreateVersionTable(db *gorm.DB) error {
-	return db.Exec(`
-		CREATE TABLE IF NOT EXISTS version (
-			version TEXT
-		)
-	`).Error
+	return createTable(db, VersionTable(db), []column{
+		{name: "id", kind: columnInt, primaryKey: true},
+		{name: "version", kind: columnText},
+		{name: "set_by_version", kind: columnText},
+		{name: "set_by_type", kind: columnText},
+		{name: "set_at", kind: columnTimestamp},
+	})
+}
+
+// versionTableColumns - колонки таблицы version, появившиеся в новых версиях библиотеки.
+var versionTableColumns = []column{
+	{name: "set_by_version", kind: columnText},
+	{name: "set_by_type", kind: columnText},
+	{name: "set_at", kind: columnTimestamp},
+	{name: "id", kind: columnInt},
+}
+
+// MigrateVersionTable добавляет в существующую таблицу version колонки, появившиеся в новых версиях библиотеки.
+func MigrateVersionTable(db *gorm.DB) error {
+	for _, column := range versionTableColumns {
+		if hasColumn(db, versionTableName, column.name) {
+			continue
+		}
+		if err := addColumn(db, VersionTable(db), column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionRowIndexSuffix - суффикс имени уникального индекса, ограничивающего таблицу version, созданную до появления
+// колонки id, одной строкой.
+const versionRowIndexSuffix = "_single_row"
+
+// RepairVersionTable оставляет в таблице version одну строку с максимальной версией и создает ограничение
+// уникальности, если таблица создана до его появления. Возвращает количество удаленных строк.
+func RepairVersionTable(db *gorm.DB) (int, error) {
+	var removed int
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rows []models.VersionModel
+		err := tx.Table(VersionTable(tx)).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		latest := rows[0]
+		for _, row := range rows[1:] {
+			if row.Version.MoreThan(latest.Version) {
+				latest = row
+			}
+		}
+
+		if len(rows) == 1 && latest.Id == models.VersionRowId {
+			return nil
+		}
+
+		err = tx.Table(VersionTable(tx)).Where("1 = 1").Delete(&models.VersionModel{}).Error
+		if err != nil {
+			return err
+		}
+
+		latest.Id = models.VersionRowId
+		removed = len(rows) - 1
+		return tx.Table(VersionTable(tx)).Create(&latest).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, createVersionRowIndex(db)
+}
+
+func createVersionRowIndex(db *gorm.DB) error {
+	table := tableName(db, versionTableName)
+	index := table + versionRowIndexSuffix
+	schema := tableNamesOf(db).Schema
+
+	switch db.Dialector.Name() {
+	case "mysql":
+		var count int64
+		err := db.Raw(
+			"SELECT count(*) FROM information_schema.statistics WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) "+
+				"AND table_name = ? AND index_name = ?",
+			schema, table, index,
+		).Row().Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		return db.Exec("CREATE UNIQUE INDEX " + quote(db, index) + " ON " + quote(db, VersionTable(db)) + " (id)").Error
+	case "sqlserver":
+		return db.Exec(
+			"IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = ? AND object_id = OBJECT_ID(?)) "+
+				"CREATE UNIQUE INDEX "+quote(db, index)+" ON "+quote(db, VersionTable(db))+" (id)",
+			index, VersionTable(db),
+		).Error
+	case "sqlite":
+		// в sqlite схема указывается у имени индекса, а не у таблицы
+		if len(schema) > 0 {
+			index = schema + "." + index
+		}
+		return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + quote(db, index) + " ON " + quote(db, table) + " (id)").Error
+	default:
+		return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + quote(db, index) + " ON " + quote(db, VersionTable(db)) + " (id)").Error
+	}
+}
+
+// GetVersionHistory возвращает записи об изменении версии в хронологическом порядке.
+func GetVersionHistory(db *gorm.DB) ([]models.VersionHistoryModel, error) {
+	var history []models.VersionHistoryModel
+	err := db.Table(VersionHistoryTable(db)).Order("reached_on ASC").Find(&history).Error
+	return history, err
+}
+
+func HasVersionHistoryTable(db *gorm.DB) bool {
+	return hasTable(db, versionHistoryTableName)
+}
+
+func CreateVersionHistoryTable(db *gorm.DB) error {
+	return createTable(db, VersionHistoryTable(db), []column{
+		{name: "version", kind: columnText},
+		{name: "direction", kind: columnText},
+		{name: "migration_rank", kind: columnBigInt},
+		{name: "reached_on", kind: columnTimestamp},
+	})
+}
+
+// GetVersionReachedBefore возвращает последнюю запись об изменении версии, сохраненную не позднее t.
+func GetVersionReachedBefore(db *gorm.DB, t time.Time) (models.VersionHistoryModel, error) {
+	var history []models.VersionHistoryModel
+	err := db.Table(VersionHistoryTable(db)).Where("reached_on <= ?", t).Order("reached_on DESC").Limit(1).Find(&history).Error
+	if err != nil {
+		return models.VersionHistoryModel{}, err
+	}
+	if len(history) == 0 {
+		return models.VersionHistoryModel{}, ErrNotFound
+	}
+	return history[0], nil
 }
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+		Logger:         logger.Discard,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDb, err := db.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDb.Close())
+	})
+	return db
+}
+
+// newLegacyVersionTable создает таблицу version без колонки id, как в базах данных ранних версий библиотеки, и
+// сохраняет в нее строки versions.
+func newLegacyVersionTable(t *testing.T, versions ...string) *gorm.DB {
+	t.Helper()
+
+	db := newTestDb(t)
+	require.NoError(t, db.Exec("create table version(version text)").Error)
+	for _, version := range versions {
+		require.NoError(t, db.Exec("insert into version(version) values (?)", version).Error)
+	}
+	require.NoError(t, MigrateVersionTable(db))
+	require.NoError(t, CreateVersionHistoryTable(db))
+	return db
+}
+
+func parseVersion(t *testing.T, version string) models.Version {
+	t.Helper()
+
+	parsed, err := models.ParseVersion(version)
+	require.NoError(t, err)
+	return parsed
+}
+
+func countVersionRows(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+
+	var count int64
+	require.NoError(t, db.Table(VersionTable(db)).Count(&count).Error)
+	return count
+}
+
+func TestGetVersionConflictingRows(t *testing.T) {
+	db := newLegacyVersionTable(t, "1.0.0.0", "1.0.2.0", "1.0.1.0")
+
+	_, err := GetVersion(db)
+	require.ErrorIs(t, err, ErrConflictingVersions)
+
+	// строки с одинаковой версией не конфликтуют
+	db = newLegacyVersionTable(t, "1.0.1.0", "1.0.1.0")
+	version, err := GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", version.String())
+}
+
+func TestSaveVersionMultipleRows(t *testing.T) {
+	db := newLegacyVersionTable(t, "1.0.0.0")
+	_, err := RepairVersionTable(db)
+	require.NoError(t, err)
+
+	// строки без id, добавленные, например, при восстановлении из резервной копии
+	require.NoError(t, db.Exec("insert into version(version) values (?), (?)", "1.0.2.0", "1.0.1.0").Error)
+	_, err = GetVersion(db)
+	require.ErrorIs(t, err, ErrConflictingVersions)
+
+	require.NoError(t, SaveVersion(db, parseVersion(t, "1.0.3.0"), VersionSource{Direction: "up"}))
+
+	require.EqualValues(t, 1, countVersionRows(t, db))
+	version, err := GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.3.0", version.String())
+
+	// повторное сохранение обновляет ту же строку
+	require.NoError(t, SaveVersion(db, parseVersion(t, "1.0.2.0"), VersionSource{Direction: "down"}))
+	require.EqualValues(t, 1, countVersionRows(t, db))
+	version, err = GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", version.String())
+
+	history, err := GetVersionHistory(db)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+}
+
+func TestRepairVersionTable(t *testing.T) {
+	db := newLegacyVersionTable(t, "1.0.0.0", "1.0.2.0", "1.0.1.0")
+
+	removed, err := RepairVersionTable(db)
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	require.EqualValues(t, 1, countVersionRows(t, db))
+	version, err := GetVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", version.String())
+
+	removed, err = RepairVersionTable(db)
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	// ограничение уникальности не допускает вторую строку
+	require.Error(t, db.Exec("insert into version(id, version) values (?, ?)", models.VersionRowId, "1.0.5.0").Error)
+}
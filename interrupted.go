@@ -0,0 +1,72 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrPreviousRunInterrupted = errors.New("previous run was interrupted while executing non-transactional migrations")
+
+// markRunning переводит нетранзакционную миграцию в состояние StateRunning перед выполнением. Возвращаемая функция
+// возвращает миграцию в исходное состояние после ошибки выполнения, чтобы дальнейшее изменение состояния не
+// отличалось от выполнения без StateRunning. Транзакционные миграции не отмечаются: при прерывании их изменения
+// отменяются базой данных.
+func markRunning(db *gorm.DB, migrationModel models.MigrationModel) (restore func() error, err error) {
+	from, err := repository.MarkMigrationRunning(db, &migrationModel)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return repository.TransitionState(db, &migrationModel, from, "execution failed")
+	}, nil
+}
+
+// checkInterrupted проверяет, что предыдущий запуск не был прерван во время выполнения нетранзакционных миграций
+// (миграции в состоянии StateRunning). Такие миграции могли быть применены частично, поэтому без
+// RunOptions.ResumeInterrupted возвращается ErrPreviousRunInterrupted. С ResumeInterrupted миграции отмечаются
+// ошибкой и выполняются повторно (см. также RunOptions.ResumeFromLastStatement). Состояния в savedMigrations
+// обновляются.
+func (m *MigrationManager) checkInterrupted(
+	db *gorm.DB,
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+	resume bool,
+) error {
+	interrupted := make([]string, 0)
+	for i := range savedMigrations {
+		if savedMigrations[i].State != models.StateRunning {
+			continue
+		}
+
+		if !resume {
+			interrupted = append(interrupted, fmt.Sprintf("%s %s", savedMigrations[i].Type, savedMigrations[i].Version))
+			continue
+		}
+
+		err := repository.TransitionState(db, &savedMigrations[i], models.StateFailure, "interrupted run resumed")
+		if err != nil {
+			return err
+		}
+		m.logger.Warn(
+			fmt.Sprintf(
+				"interrupted migration (type: %s, Version: %s) marked as failed and will be retried, service: %s",
+				savedMigrations[i].Type, savedMigrations[i].Version, serviceName,
+			),
+		)
+	}
+
+	if len(interrupted) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: %s, service: %s (check the database and retry with RunOptions.ResumeInterrupted or reset with Repair)",
+		ErrPreviousRunInterrupted, strings.Join(interrupted, ", "), serviceName,
+	)
+}
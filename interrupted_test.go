@@ -0,0 +1,83 @@
+package db_migrator
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// migrateKilled выполняет Migrate, прерывая выполнение горутины внутри миграции, как при завершении процесса:
+// отложенные функции выполняются, но Migrate не возвращает ошибку выполнения.
+func migrateKilled(m *MigrationManager, serviceName string) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = m.Migrate(serviceName)
+	}()
+	<-done
+}
+
+func TestMigrateInterruptedRun(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+
+	var crash atomic.Bool
+	crash.Store(true)
+
+	require.NoError(t, m.Register("service1",
+		Migration{
+			MigrationType:   TypeBaseline,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			Up:              "create table a(id int)",
+		},
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.1",
+			UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+				err := db.Exec("create table if not exists b(id int)").Error
+				if err != nil {
+					return err
+				}
+				if crash.Load() {
+					runtime.Goexit()
+				}
+				return db.Exec("insert into b(id) values (1)").Error
+			},
+		},
+	))
+
+	migrateKilled(m, "service1")
+
+	dirty, err := m.IsDirty("service1")
+	require.NoError(t, err)
+	require.True(t, dirty)
+
+	result, err := m.Validate("service1")
+	require.NoError(t, err)
+	require.False(t, result.SafeToMigrate)
+	require.Contains(t, result.Findings, ValidationFinding{
+		Severity:      ValidationError,
+		Kind:          ValidationInterrupted,
+		MigrationType: TypeVersioned,
+		Version:       "1.0.1.0",
+		Message:       "migration execution was interrupted, migration may be partially applied",
+	})
+
+	crash.Store(false)
+
+	err = m.Migrate("service1")
+	require.ErrorIs(t, err, ErrPreviousRunInterrupted)
+
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{ResumeInterrupted: true}))
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+
+	dirty, err = m.IsDirty("service1")
+	require.NoError(t, err)
+	require.False(t, dirty)
+}
@@ -0,0 +1,108 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// libraryVersion - версия библиотеки, сохраняемая в таблицу migrator_meta.
+	libraryVersion = Version
+	// systemSchemaVersion - версия структуры системных таблиц. Увеличивается при изменении их семантики.
+	systemSchemaVersion = 1
+)
+
+var ErrLibraryTooOld = errors.New("migrator library version is lower than required by database")
+
+// checkLibraryVersion проверяет, что версия библиотеки не ниже минимальной версии, сохраненной в базе данных с помощью
+// SetMinimumLibraryVersion.
+func (m *MigrationManager) checkLibraryVersion(db *gorm.DB) error {
+	if !repository.HasMetaTable(db) {
+		return nil
+	}
+
+	minVersionString, err := repository.GetMeta(db, repository.MetaMinLibraryVersion)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	minVersion, err := models.ParseVersion(minVersionString)
+	if err != nil {
+		return err
+	}
+
+	currentVersion, err := models.ParseVersion(libraryVersion)
+	if err != nil {
+		return err
+	}
+
+	if currentVersion.LessThan(minVersion) {
+		m.logger.Error(fmt.Sprintf("migrator library %s is too old, required: %s", currentVersion, minVersion))
+		return fmt.Errorf("%w: running %s, upgrade to %s or later", ErrLibraryTooOld, currentVersion, minVersion)
+	}
+
+	return nil
+}
+
+// saveLibraryMeta сохраняет в таблицу migrator_meta версию библиотеки и версию структуры системных таблиц.
+func saveLibraryMeta(db *gorm.DB) error {
+	if !repository.HasMetaTable(db) {
+		err := repository.CreateMetaTable(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := repository.SaveMeta(db, repository.MetaLibraryVersion, libraryVersion)
+	if err != nil {
+		return err
+	}
+
+	return repository.SaveMeta(db, repository.MetaSchemaVersion, strconv.Itoa(systemSchemaVersion))
+}
+
+// SetMinimumLibraryVersion сохраняет в базу данных минимальную версию библиотеки, необходимую для работы с ней.
+// Экземпляры с более старой версией библиотеки завершают любые операции ошибкой ErrLibraryTooOld.
+func (m *MigrationManager) SetMinimumLibraryVersion(serviceName string, version string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	parsedVersion, err := models.ParseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	if !repository.HasMetaTable(service.Db) {
+		err = repository.CreateMetaTable(service.Db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return repository.SaveMeta(service.Db, repository.MetaMinLibraryVersion, parsedVersion.String())
+}
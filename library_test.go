@@ -0,0 +1,30 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinimumLibraryVersion(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+	registerRunDirectionMigrations(t, m)
+
+	require.NoError(t, m.SetMinimumLibraryVersion("service1", libraryVersion))
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{TargetVersion: "1.0.0"}))
+
+	// база данных обновлена экземпляром с более новой версией библиотеки
+	require.NoError(t, repository.SaveMeta(connect(), repository.MetaMinLibraryVersion, "999.0.0.0"))
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrLibraryTooOld)
+	require.ErrorContains(t, err, "upgrade to 999.0.0.0")
+	require.False(t, connect().Migrator().HasColumn("a", "b"), "no migration must be executed")
+
+	require.ErrorIs(t, m.DowngradeTo("service1", "1.0.0"), ErrLibraryTooOld)
+	require.ErrorIs(t, m.SetMinimumLibraryVersion("service1", "1.0.0"), ErrLibraryTooOld)
+
+	_, err = m.SavedVersion("service1")
+	require.ErrorIs(t, err, ErrLibraryTooOld)
+}
@@ -0,0 +1,250 @@
+package db_migrator
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintOptions задает параметры проверки каталога миграций Lint.
+type LintOptions struct {
+	// Profile - профиль политик, которому должны соответствовать миграции (например, StrictProfile).
+	Profile Profile
+	// Baseline - версии или имена файлов миграций основной ветки. Новая миграция типа TypeVersioned с версией ниже
+	// максимальной из Baseline не будет выполнена на базах данных, уже обновленных до этой версии.
+	Baseline []string
+	// RenderTemplates - файлы миграций являются шаблонами text/template (см. Migration.RenderTemplate) и проверяются
+	// выполнением с данными TemplateData. Остальные проверки выполняются для результата.
+	RenderTemplates bool
+	TemplateData    map[string]any
+}
+
+// LintFinding - найденное нарушение.
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	File     string       `json:"file"`
+	Rule     string       `json:"rule"`
+	Message  string       `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", f.File, f.Severity, f.Message, f.Rule)
+}
+
+// GitHubAnnotation возвращает нарушение в формате команды GitHub Actions (::error file=...::message).
+func (f LintFinding) GitHubAnnotation() string {
+	message := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(f.Message)
+	return fmt.Sprintf("::%s file=%s,title=%s::%s", f.Severity, f.File, f.Rule, message)
+}
+
+// Lint проверяет файлы миграций каталога fsys (формат имен см. RegisterFS) без подключения к базе данных: имена
+// файлов, параметры в комментариях заголовка, повторяющиеся версии, файлы down без up, пустые файлы, версии ниже
+// LintOptions.Baseline, выполнение шаблонов и соответствие LintOptions.Profile (в том числе Ticket из заголовка и
+// запрещенные выражения). Возвращает все найденные нарушения, упорядоченные по имени файла; ошибка возвращается
+// только при невозможности прочитать каталог или Baseline.
+func Lint(fsys fs.FS, opts LintOptions) ([]LintFinding, error) {
+	baseline, err := lintBaselineVersion(opts.Baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	type migrationKey struct {
+		migrationType MigrationType
+		version       string
+	}
+
+	findings := make([]LintFinding, 0)
+	files := make(map[migrationKey]map[bool]string)
+	contents := make(map[string][]byte)
+	directives := make(map[string]migrationDirectives)
+
+	err = fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(filePath) != ".sql" {
+			return nil
+		}
+
+		file, err := parseMigrationFile(filePath)
+		if err != nil {
+			findings = append(findings, LintFinding{Severity: LintError, File: filePath, Rule: "file-name", Message: err.Error()})
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		contents[filePath] = content
+
+		fileDirectives, err := parseMigrationDirectives(filePath, string(content))
+		if err == nil {
+			err = validateMigrationDirectives(file, fileDirectives)
+		}
+		if err != nil {
+			findings = append(findings, LintFinding{Severity: LintError, File: filePath, Rule: "directive", Message: err.Error()})
+		} else {
+			directives[filePath] = fileDirectives
+		}
+
+		key := migrationKey{migrationType: file.migrationType, version: file.version}
+		if files[key] == nil {
+			files[key] = make(map[bool]string)
+		}
+		if previous, ok := files[key][file.down]; ok {
+			findings = append(findings, LintFinding{
+				Severity: LintError,
+				File:     filePath,
+				Rule:     "duplicate-version",
+				Message:  fmt.Sprintf("migration (type: %s, version: %s) is already defined in %s", key.migrationType, key.version, previous),
+			})
+			return nil
+		}
+		files[key][file.down] = filePath
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, paths := range files {
+		upPath, hasUp := paths[false]
+		downPath, hasDown := paths[true]
+
+		if !hasUp {
+			findings = append(findings, LintFinding{
+				Severity: LintError,
+				File:     downPath,
+				Rule:     "down-without-up",
+				Message:  fmt.Sprintf("migration (type: %s, version: %s) has down file but no up file", key.migrationType, key.version),
+			})
+			continue
+		}
+
+		if hasDown {
+			up, _ := parseMigrationFile(upPath)
+			down, _ := parseMigrationFile(downPath)
+			if up.description != down.description {
+				findings = append(findings, LintFinding{
+					Severity: LintError,
+					File:     downPath,
+					Rule:     "description-mismatch",
+					Message:  fmt.Sprintf("up and down files of migration %s have different descriptions", key.version),
+				})
+			}
+		}
+
+		if len(strings.TrimSpace(string(contents[upPath]))) == 0 {
+			findings = append(findings, LintFinding{Severity: LintError, File: upPath, Rule: "empty-file", Message: "up file is empty"})
+		}
+
+		version, _ := models.ParseVersion(key.version)
+		if baseline != nil && key.migrationType == TypeVersioned && version.LessThan(*baseline) && !lintInBaseline(opts.Baseline, version) {
+			findings = append(findings, LintFinding{
+				Severity: LintError,
+				File:     upPath,
+				Rule:     "version-below-baseline",
+				Message:  fmt.Sprintf("version %s is lower than the latest version %s of the main branch", key.version, baseline),
+			})
+		}
+
+		up, upRendered := lintRender(opts, upPath, key.version+"/up", contents[upPath], &findings)
+		down, downRendered := "", true
+		if hasDown {
+			down, downRendered = lintRender(opts, downPath, key.version+"/down", contents[downPath], &findings)
+		}
+
+		// политики проверяются для результата выполнения шаблонов
+		migration := Migration{
+			MigrationType:   key.migrationType,
+			Version:         key.version,
+			Ticket:          directives[upPath].ticket,
+			IsTransactional: true,
+			Up:              up,
+			Down:            down,
+		}
+		if upRendered && downRendered {
+			if err := opts.Profile.validateMigration(&migration); err != nil {
+				findings = append(findings, LintFinding{Severity: LintError, File: upPath, Rule: "policy", Message: err.Error()})
+			}
+		}
+
+		if key.migrationType == TypeVersioned && !hasDown && !opts.Profile.RequireDown {
+			findings = append(findings, LintFinding{
+				Severity: LintWarning,
+				File:     upPath,
+				Rule:     "missing-down",
+				Message:  fmt.Sprintf("migration %s cannot be downgraded, down file is missing", key.version),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings, nil
+}
+
+// lintRender возвращает содержимое файла filePath, при LintOptions.RenderTemplates - результат выполнения шаблона.
+// Ошибка шаблона добавляется в findings, в этом случае возвращается false.
+func lintRender(opts LintOptions, filePath string, name string, content []byte, findings *[]LintFinding) (string, bool) {
+	if !opts.RenderTemplates {
+		return string(content), true
+	}
+
+	rendered, err := renderTemplate(name, string(content), opts.TemplateData)
+	if err != nil {
+		*findings = append(*findings, LintFinding{Severity: LintError, File: filePath, Rule: "template", Message: err.Error()})
+		return "", false
+	}
+	return rendered, true
+}
+
+// lintBaselineVersion возвращает максимальную версию Baseline или nil, если Baseline пуст.
+func lintBaselineVersion(baseline []string) (*models.Version, error) {
+	var latest *models.Version
+	for _, entry := range baseline {
+		version, err := lintBaselineEntryVersion(entry)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || version.MoreThan(*latest) {
+			latest = &version
+		}
+	}
+	return latest, nil
+}
+
+// lintBaselineEntryVersion разбирает элемент Baseline: версию или имя файла миграции.
+func lintBaselineEntryVersion(entry string) (models.Version, error) {
+	if file, err := parseMigrationFile(entry); err == nil {
+		return models.ParseVersion(file.version)
+	}
+	return models.ParseVersion(entry)
+}
+
+// lintInBaseline определяет, присутствует ли миграция с версией version в основной ветке.
+func lintInBaseline(baseline []string, version models.Version) bool {
+	for _, entry := range baseline {
+		if entryVersion, err := lintBaselineEntryVersion(entry); err == nil && entryVersion.Equals(version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package db_migrator
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	type finding struct {
+		Severity LintSeverity
+		File     string
+		Rule     string
+		// Message - фрагмент сообщения
+		Message string
+	}
+
+	tests := []struct {
+		// dir - каталог testdata/lint с одним нарушением
+		dir  string
+		opts LintOptions
+		want []finding
+	}{
+		{
+			dir:  "clean",
+			opts: LintOptions{Profile: StrictProfile()},
+		},
+		{
+			dir: "file-name",
+			want: []finding{
+				{LintError, "V1_0_1__add_b.sql", "file-name", "invalid migration file name"},
+			},
+		},
+		{
+			dir: "duplicate-version",
+			want: []finding{
+				{LintError, "v2/V1_0_1_0__add_b.up.sql", "duplicate-version", "is already defined in V1_0_1_0__add_b.up.sql"},
+			},
+		},
+		{
+			dir: "down-without-up",
+			want: []finding{
+				{LintError, "V1_0_1_0__add_b.down.sql", "down-without-up", "has down file but no up file"},
+			},
+		},
+		{
+			dir: "description-mismatch",
+			want: []finding{
+				{LintError, "V1_0_1_0__drop_b.down.sql", "description-mismatch", "have different descriptions"},
+			},
+		},
+		{
+			dir: "empty-file",
+			want: []finding{
+				{LintError, "V1_0_1_0__add_b.up.sql", "empty-file", "up file is empty"},
+			},
+		},
+		{
+			dir:  "version-below-baseline",
+			opts: LintOptions{Baseline: []string{"B1_0_0_0__baseline.sql", "V1_0_2_0__add_c.up.sql"}},
+			want: []finding{
+				{LintError, "V1_0_1_0__add_b.up.sql", "version-below-baseline", "version 1.0.1.0 is lower than the latest version 1.0.2.0"},
+			},
+		},
+		{
+			dir: "missing-down",
+			want: []finding{
+				{LintWarning, "V1_0_1_0__add_b.sql", "missing-down", "down file is missing"},
+			},
+		},
+		{
+			dir: "directive",
+			want: []finding{
+				{LintError, "R1_0_1_0__view.sql", "directive", `invalid min-version "1.x": R1_0_1_0__view.sql`},
+			},
+		},
+		{
+			dir:  "missing-ticket",
+			opts: LintOptions{Profile: StrictProfile()},
+			want: []finding{
+				{LintError, "V1_0_1_0__add_b.up.sql", "policy", "Ticket is required"},
+			},
+		},
+		{
+			dir:  "destructive",
+			opts: LintOptions{Profile: StrictProfile()},
+			want: []finding{
+				{LintError, "V1_0_1_0__drop_legacy.up.sql", "policy", `destructive statement "drop table legacy" is forbidden`},
+			},
+		},
+		{
+			dir:  "template",
+			opts: LintOptions{RenderTemplates: true, TemplateData: map[string]any{"schema": "app"}},
+			want: []finding{
+				{LintError, "V1_0_1_0__add_b.up.sql", "template", `render migration template 1.0.1.0/up`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			findings, err := Lint(os.DirFS(path.Join("testdata", "lint", tt.dir)), tt.opts)
+			require.NoError(t, err)
+			require.Len(t, findings, len(tt.want), findings)
+
+			for i, want := range tt.want {
+				require.Equal(t, want.Severity, findings[i].Severity)
+				require.Equal(t, want.File, findings[i].File)
+				require.Equal(t, want.Rule, findings[i].Rule)
+				require.Contains(t, findings[i].Message, want.Message)
+			}
+		})
+	}
+}
+
+func TestLintFindingFormat(t *testing.T) {
+	finding := LintFinding{Severity: LintError, File: "migrations/V1_0_1_0__a.sql", Rule: "policy", Message: "100% bad\nline"}
+
+	require.Equal(t, "migrations/V1_0_1_0__a.sql: error: 100% bad\nline (policy)", finding.String())
+	require.Equal(t, "::error file=migrations/V1_0_1_0__a.sql,title=policy::100%25 bad%0Aline", finding.GitHubAnnotation())
+}
@@ -0,0 +1,54 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// lockWaitPollInterval - базовый интервал проверки выполнения миграций другим экземпляром приложения.
+const lockWaitPollInterval = time.Second
+
+type lockWaitPolicy struct {
+	maxWait time.Duration
+	jitter  time.Duration
+}
+
+// interval возвращает интервал опроса со случайной добавкой в пределах jitter, чтобы одновременно запущенные
+// экземпляры не обращались к базе данных синхронно.
+func (p lockWaitPolicy) interval() time.Duration {
+	if p.jitter <= 0 {
+		return lockWaitPollInterval
+	}
+	return lockWaitPollInterval + time.Duration(rand.Int63n(int64(p.jitter)))
+}
+
+// waitForLockHolder ожидает, пока экземпляр приложения, удерживающий блокировку миграции (ErrMigrationLocked) или
+// межпроцессную блокировку сервиса (ErrLockNotAcquired), выполнит миграции. Интервал опроса со случайной добавкой
+// вычисляется заново перед каждым опросом. Возвращает nil и отмечает report.SatisfiedByOtherInstance, если база
+// данных удовлетворяет зарегистрированным миграциям, иначе - исходную ошибку lockErr с указанием времени ожидания.
+func (m *MigrationManager) waitForLockHolder(ctx context.Context, serviceName string, lockErr error, report *MigrationReport) error {
+	m.logger.Info(
+		fmt.Sprintf(
+			"migrations are being applied by another instance, waiting up to %s, service: %s",
+			m.lockWait.maxWait, serviceName,
+		),
+	)
+
+	err := waitForIntervals(ctx, "lock holder", m.lockWait.interval, m.lockWait.maxWait, func(ctx context.Context) (bool, error) {
+		_, ok, err := m.checkFulfillment(ctx, serviceName)
+		return ok, err
+	})
+	if err != nil {
+		return fmt.Errorf("%w: migrations still pending after waiting %s: %w", lockErr, m.lockWait.maxWait, err)
+	}
+
+	service, _ := m.service(serviceName)
+	service.migrated = true
+	service.upToDate = true
+	report.SatisfiedByOtherInstance = true
+
+	m.logger.Info(fmt.Sprintf("migrations satisfied by another instance, service: %s", serviceName))
+	return nil
+}
@@ -0,0 +1,132 @@
+package db_migrator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// lockWaitTestManagers создает count менеджеров с сервисом service1 на одной базе данных. Миграция 1.0.1
+// выполняется, пока не закрыт канал release, и считает выполнения в executed.
+func lockWaitTestManagers(t *testing.T, count int, executed *atomic.Int32, started chan<- struct{}, release <-chan struct{}, opts ...ManagerOption) []*MigrationManager {
+	t.Helper()
+
+	connect, disconnect := newTestDatabase(t)
+	var once sync.Once
+	managers := make([]*MigrationManager, 0, count)
+	for i := 0; i < count; i++ {
+		m, err := NewMigrationsManager(opts...)
+		require.NoError(t, err)
+		require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.1"))
+		require.NoError(t, m.Register("service1",
+			lockTestMigrations()[0],
+			Migration{
+				MigrationType: TypeVersioned,
+				Version:       "1.0.1",
+				UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+					executed.Add(1)
+					once.Do(func() { close(started) })
+					<-release
+					return nil
+				},
+			},
+		))
+		managers = append(managers, m)
+	}
+	return managers
+}
+
+// TestLockWaitSatisfiedByOtherInstance проверяет, что экземпляры, не получившие межпроцессную блокировку сервиса,
+// дожидаются выполнения миграций экземпляром, удерживающим блокировку, и не выполняют миграции повторно.
+func TestLockWaitSatisfiedByOtherInstance(t *testing.T) {
+	var executed atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	managers := lockWaitTestManagers(t, 3, &executed, started, release,
+		WithLockTimeout(100*time.Millisecond), WithLockWaitPolicy(30*time.Second, 100*time.Millisecond),
+	)
+
+	holder := make(chan error, 1)
+	go func() {
+		_, err := managers[0].MigrateWithReport(context.Background(), "service1", RunOptions{})
+		holder <- err
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	reports := make([]MigrationReport, len(managers)-1)
+	errs := make([]error, len(managers)-1)
+	for i, m := range managers[1:] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+		}()
+	}
+
+	// ожидающие экземпляры не получают блокировку, пока ее удерживает первый
+	time.Sleep(300 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-holder)
+	wg.Wait()
+
+	for i := range reports {
+		require.NoError(t, errs[i])
+		require.True(t, reports[i].SatisfiedByOtherInstance)
+		require.Empty(t, reports[i].Entries)
+	}
+	require.Equal(t, int32(1), executed.Load())
+}
+
+func TestLockWaitTimeout(t *testing.T) {
+	var executed atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	managers := lockWaitTestManagers(t, 2, &executed, started, release,
+		WithLockTimeout(100*time.Millisecond), WithLockWaitPolicy(200*time.Millisecond, 0),
+	)
+
+	holder := make(chan error, 1)
+	go func() {
+		holder <- managers[0].Migrate("service1")
+	}()
+	<-started
+
+	report, err := managers[1].MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, report.SatisfiedByOtherInstance)
+
+	close(release)
+	require.NoError(t, <-holder)
+	require.Equal(t, int32(1), executed.Load())
+}
+
+// TestLockWaitIntervalJitter проверяет, что интервал опроса со случайной добавкой вычисляется перед каждым опросом.
+func TestLockWaitIntervalJitter(t *testing.T) {
+	policy := lockWaitPolicy{maxWait: time.Minute, jitter: time.Second}
+
+	intervals := make(map[time.Duration]struct{})
+	for i := 0; i < 20; i++ {
+		interval := policy.interval()
+		require.GreaterOrEqual(t, interval, lockWaitPollInterval)
+		require.Less(t, interval, lockWaitPollInterval+policy.jitter)
+		intervals[interval] = struct{}{}
+	}
+	require.Greater(t, len(intervals), 1)
+
+	calls := 0
+	polls := 0
+	err := waitForIntervals(context.Background(), "test", func() time.Duration {
+		calls++
+		return time.Millisecond
+	}, 0, func(context.Context) (bool, error) {
+		polls++
+		return polls == 4, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+}
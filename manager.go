@@ -1,28 +1,60 @@
 package db_migrator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Maksumys/db-migrator/internal/models"
 	"github.com/Maksumys/db-migrator/internal/repository"
 	"gorm.io/gorm"
 	"hash/fnv"
+	"io"
 	"log/slog"
 	"sync"
+	"time"
+)
+
+const (
+	defaultRegistrationBatchSize = 100
 )
 
 var (
-	ErrHasForthcomingMigrations = errors.New("found not completed forthcoming migrations, consider migrating")
-	ErrHasFailedMigrations      = errors.New("found failed migrations, consider fixing your Db")
-	ErrTargetVersionNotLatest   = errors.New("target Version falls behind migrations, consider raising target Version")
+	ErrHasForthcomingMigrations   = errors.New("found not completed forthcoming migrations, consider migrating")
+	ErrHasFailedMigrations        = errors.New("found failed migrations, consider fixing your Db")
+	ErrTargetVersionNotLatest     = errors.New("target Version falls behind migrations, consider raising target Version")
+	ErrTargetBelowSavedVersion    = errors.New("target Version is below saved Version, use Downgrade instead")
+	ErrRowsAffectedBelowExpected  = errors.New("migration affected fewer rows than expected")
+	ErrDatabaseAheadOfBinary      = errors.New("database contains migrations newer than registered ones")
+	ErrMigrationLocked            = errors.New("migration is already being executed elsewhere")
+	ErrConflictingRunDirection    = errors.New("run conflicts with a recent run in the opposite direction")
+	ErrForeignMigrationsTable     = errors.New("system table exists but has unexpected schema")
+	ErrRegistrationsFrozen        = errors.New("registrations are frozen after migrate")
+	ErrServiceNotFound            = errors.New("service not found")
+	ErrMigrationTimeout           = errors.New("migration timed out")
+	ErrHasFailedAllowedMigrations = errors.New("found repeatable migrations failed with allowed failure policy")
+	ErrMigrationNotFound          = errors.New("migration not found")
+	ErrRegisteredVersionTooLow    = errors.New("registered migration has lower Version than already saved one")
+	ErrDependencyNotFound         = errors.New("dependency service is not added")
+	ErrDependencyNotConnected     = errors.New("dependency service has no connection")
+	ErrDependencyNotInitialized   = errors.New("dependency service has no Version table")
+	ErrDependencyNotMigrated      = errors.New("dependency service has no saved Version")
+	ErrDependencyNotSatisfied     = errors.New("dependency Version does not satisfy requirement")
+	ErrDuplicateMigration         = errors.New("migration with the same Version and type is already registered")
 )
 
 // NewMigrationsManager создает экземпляр управляющего миграциями (выступает в качестве фасада).
 // TargetVersion - версия, до которой необходимо выполнить миграцию или до необходимо осуществить откат.
 func NewMigrationsManager(opts ...ManagerOption) (*MigrationManager, error) {
 	manager := MigrationManager{
-		logger:   slog.Default(),
-		services: make(map[string]*ServiceInfo),
+		logger:    slog.Default(),
+		services:  make(map[string]*ServiceInfo),
+		batchSize: defaultRegistrationBatchSize,
+
+		planLogging:         true,
+		extensionAutoCreate: true,
+		locale:              LocaleEN,
+		lockTimeout:         defaultLockTimeout,
+		lockLease:           defaultLockLease,
 	}
 
 	for _, opt := range opts {
@@ -39,107 +71,457 @@ type ServiceInfo struct {
 	TargetVersion           models.Version
 	registeredMigrations    []*Migration
 	registeredMigrationsSet map[uint32]*Migration
+
+	AuxiliaryConnections map[string]AuxiliaryConnection
+	auxiliaryDb          map[string]*gorm.DB
+
+	// targetLatest - целевая версия задана как TargetLatest и вычисляется по зарегистрированным миграциям
+	targetLatest bool
+	// migrated - для сервиса успешно выполнен Migrate в текущем процессе
+	migrated bool
+	// upToDate - после успешного выполнения Migrate новые миграции не регистрировались
+	upToDate bool
+	// cachedReport - отчет последнего успешного Migrate, возвращаемый с WithRunOncePerProcess
+	cachedReport *MigrationReport
+	// runLabels - метки выполняемого запуска
+	runLabels map[string]string
+	// runTargetVersion - целевая версия выполняемого запуска, заданная RunOptions.TargetVersion
+	runTargetVersion *models.Version
+	// runPhases - длительность этапов выполняемого запуска Migrate
+	runPhases *PhaseTimings
+	// lateRegistrationsAllowed - регистрация миграций после Migrate разрешена вызовом AllowLateRegistrations
+	lateRegistrationsAllowed bool
+	// requiredExtensions - расширения базы данных, необходимые всем миграциям сервиса
+	requiredExtensions []string
+	// sharedDatabase - база данных сервиса может использоваться другими сервисами (см. WithSharedDatabase)
+	sharedDatabase bool
+	// ensureDatabase - параметры создания базы данных сервиса перед Migrate (см. WithEnsureDatabase)
+	ensureDatabase *EnsureDatabaseConfig
+	// sqlDialect - диалект сервиса, зарегистрированного RegisterServiceSQL (см. WithSQLDialect)
+	sqlDialect string
+	// tableNames - размещение системных таблиц сервиса (см. WithTablePrefix, WithSystemTableNames и WithSchema)
+	tableNames repository.TableNames
+	// templateData - данные шаблонов миграций сервиса (см. WithServiceTemplateData)
+	templateData map[string]any
+	// allowOutOfOrder - разрешена регистрация миграций с версией ниже сохраненных (см. WithAllowOutOfOrder)
+	allowOutOfOrder bool
+
+	// mutex сериализует регистрацию миграций и операции над базой данных сервиса
+	mutex sync.Mutex
 }
 
 type MigrationManager struct {
 	logger   *slog.Logger
 	services map[string]*ServiceInfo
 
-	mutex sync.Mutex
+	forbidOlderBinary bool
+	confirmation      func(ConfirmationRequest) (bool, error)
+	// confirmationThreshold - минимальное количество отменяемых миграций, при котором Downgrade требует подтверждения
+	confirmationThreshold int
+	profile               Profile
+	autoMigrateConfig     AutoMigrateConfig
+	// autoMigrating - сервисы, для которых выполняется автоматический Migrate, со значением true, если во время
+	// выполнения были зарегистрированы новые миграции
+	autoMigrating    map[string]bool
+	autoMigrateMutex sync.Mutex
+	runOnce          bool
+	encryptor        ValueEncryptor
+	batchSize        int
+	auditWriter      io.Writer
+	auditMutex       sync.Mutex
+	defaultRunLabels map[string]string
+	templateData     map[string]any
+
+	directionConflictWindow time.Duration
+	planLogging             bool
+	freezeAfterMigrate      bool
+	locale                  Locale
+	lockWait                lockWaitPolicy
+	panicOnMisuse           bool
+	schemaTracking          bool
+	strictSchemaTracking    bool
+	extensionAutoCreate     bool
+	overlapThreshold        int
+	migrationTimeout        time.Duration
+	statementTimeout        bool
+	slowPhaseThreshold      time.Duration
+	lockTimeout             time.Duration
+	lockLease               time.Duration
+	skipChecksumValidation  bool
+	skipHygieneChecks       bool
+	strictHygiene           bool
+	connectRetry            connectRetryPolicy
+	identity                string
+
+	allowDuplicateRegistration bool
+
+	beforeMigrationHook func(service string, info MigrationInfo) error
+	afterMigrationHook  func(service string, info MigrationInfo, duration time.Duration)
+	onErrorHook         func(service string, info MigrationInfo, err error)
+
+	// mutex защищает services. Операции над сервисом сериализуются блокировкой ServiceInfo.mutex, поэтому операции
+	// над разными сервисами выполняются параллельно
+	mutex sync.RWMutex
 }
 
-func (m *MigrationManager) RegisterService(name string, connectFunc func() *gorm.DB, disconnectFunc func(db *gorm.DB), targetVersion string) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// RegisterService регистрирует сервис или обновляет параметры зарегистрированного сервиса.
+//
+// Deprecated: используйте AddService.
+func (m *MigrationManager) RegisterService(
+	name string,
+	connectFunc func() *gorm.DB,
+	disconnectFunc func(db *gorm.DB),
+	targetVersion string,
+	opts ...ServiceOption,
+) error {
+	return m.AddService(name, ServiceConfig{
+		Connect:       connectFunc,
+		Disconnect:    disconnectFunc,
+		TargetVersion: targetVersion,
+		Options:       opts,
+	})
+}
 
-	parsedTargetVersion, err := models.ParseVersion(targetVersion)
-	if err != nil {
-		return err
+// AddService регистрирует сервис или обновляет параметры зарегистрированного сервиса.
+func (m *MigrationManager) AddService(name string, config ServiceConfig) error {
+	var parsedTargetVersion models.Version
+	targetLatest := config.TargetVersion == TargetLatest
+	if !targetLatest {
+		var err error
+		parsedTargetVersion, err = models.ParseVersion(config.TargetVersion)
+		if err != nil {
+			return m.misuse(err)
+		}
+	}
+
+	service := m.serviceOrCreate(name)
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.ConnectFunc = config.Connect
+	service.DisconnectFunc = config.Disconnect
+	service.TargetVersion = parsedTargetVersion
+	service.targetLatest = targetLatest
+
+	for _, opt := range config.Options {
+		opt(service)
 	}
 
+	if len(service.tableNames.Prefix) > 0 && !tablePrefixRegexp.MatchString(service.tableNames.Prefix) {
+		return m.misuse(fmt.Errorf("invalid table prefix %q of service %s", service.tableNames.Prefix, name))
+	}
+	for _, identifier := range []string{service.tableNames.Schema, service.tableNames.Version, service.tableNames.Migrations} {
+		if len(identifier) > 0 && !systemIdentifierRegexp.MatchString(identifier) {
+			return m.misuse(fmt.Errorf("invalid system table or schema name %q of service %s", identifier, name))
+		}
+	}
+
+	return nil
+}
+
+// open подключается к базе данных сервиса через ConnectFunc. Запросы к системным таблицам через полученное
+// соединение используют размещение таблиц сервиса (см. WithTablePrefix, WithSystemTableNames и WithSchema).
+func (s *ServiceInfo) open() *gorm.DB {
+	return repository.WithTableNames(s.ConnectFunc(), s.tableNames)
+}
+
+// service возвращает зарегистрированный сервис.
+func (m *MigrationManager) service(name string) (*ServiceInfo, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
 	service, ok := m.services[name]
+	return service, ok
+}
 
+// serviceOrCreate возвращает зарегистрированный сервис, регистрируя его при отсутствии.
+func (m *MigrationManager) serviceOrCreate(name string) *ServiceInfo {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	service, ok := m.services[name]
 	if !ok {
 		service = &ServiceInfo{
-			ConnectFunc:             connectFunc,
-			DisconnectFunc:          disconnectFunc,
-			TargetVersion:           parsedTargetVersion,
 			registeredMigrations:    make([]*Migration, 0),
 			registeredMigrationsSet: make(map[uint32]*Migration),
 		}
 		m.services[name] = service
-	} else {
-		service.ConnectFunc = connectFunc
-		service.DisconnectFunc = disconnectFunc
-		service.TargetVersion = parsedTargetVersion
-		m.services[name] = service
 	}
+	return service
+}
 
-	return nil
+// servicesSnapshot возвращает копию списка зарегистрированных сервисов.
+func (m *MigrationManager) servicesSnapshot() map[string]*ServiceInfo {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	services := make(map[string]*ServiceInfo, len(m.services))
+	for name, service := range m.services {
+		services[name] = service
+	}
+	return services
+}
+
+// lockService захватывает блокировку сервиса и возвращает функцию ее освобождения. Для незарегистрированного сервиса
+// блокировка не захватывается.
+func (m *MigrationManager) lockService(name string) func() {
+	service, ok := m.service(name)
+	if !ok {
+		return func() {}
+	}
+
+	service.mutex.Lock()
+	return service.mutex.Unlock
 }
 
 func (m *MigrationManager) GetServiceInfoUnsafe(name string) (*ServiceInfo, bool) {
-	serviceInfo, ok := m.services[name]
+	serviceInfo, ok := m.service(name)
 	return serviceInfo, ok
 }
 
 // Register сохраняет миграции в память.
 // По умолчанию миграции осуществляются внутри транзакции.
 //
-// Паникует при регистрации миграций с одинаковымм версией и типом.
+// Миграции регистрируются все или ни одной: при ошибке проверки любой миграции, в том числе при повторной
+// регистрации миграции с той же версией и типом (ErrDuplicateMigration), ни одна миграция вызова не регистрируется.
+// С WithAllowDuplicateRegistration повторные регистрации пропускаются.
 func (m *MigrationManager) Register(serviceName string, migrationsStruct ...Migration) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	added, err := m.register(serviceName, migrationsStruct...)
+	if err != nil {
+		return err
+	}
 
-	service, ok := m.services[serviceName]
+	if added > 0 {
+		m.autoMigrate(serviceName)
+	}
 
-	if !ok {
-		service = &ServiceInfo{
-			registeredMigrations:    make([]*Migration, 0),
-			registeredMigrationsSet: make(map[uint32]*Migration),
-		}
+	return nil
+}
 
-		m.services[serviceName] = service
+// register сохраняет миграции в память и возвращает количество добавленных миграций. Все миграции проверяются до
+// изменения зарегистрированных миграций сервиса.
+func (m *MigrationManager) register(serviceName string, migrationsStruct ...Migration) (int, error) {
+	service := m.serviceOrCreate(serviceName)
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if m.registrationsFrozen(serviceName, service) {
+		return 0, m.misuse(fmt.Errorf(
+			"%w: service %s was already migrated in this process, call AllowLateRegistrations "+
+				"or use WithAutoMigrateOnRegister to apply late registrations",
+			ErrRegistrationsFrozen, serviceName,
+		))
 	}
 
+	// новые миграции вызова, в том числе для проверки повторной регистрации внутри вызова
+	batch := make(map[uint32]*Migration, len(migrationsStruct))
+	accepted := make([]*Migration, 0, len(migrationsStruct))
 	for i := 0; i < len(migrationsStruct); i++ {
-		migrationVersion, err := models.ParseVersion(migrationsStruct[i].Version)
+		err := adaptDepsFuncs(&migrationsStruct[i])
+		if err != nil {
+			return 0, m.misuse(err)
+		}
+
+		migrationVersion, err := validateMigration(&migrationsStruct[i])
 		if err != nil {
-			return err
+			return 0, m.misuse(err)
+		}
+
+		err = renderTemplates(&migrationsStruct[i], m.serviceTemplateData(service))
+		if err != nil {
+			return 0, m.misuse(err)
+		}
+
+		err = m.profile.validateMigration(&migrationsStruct[i])
+		if err != nil {
+			return 0, m.misuse(err)
 		}
 
 		identifier := getMigrationIdentifier(migrationVersion, string(migrationsStruct[i].MigrationType))
-		if _, ok = service.registeredMigrationsSet[identifier]; ok {
-			continue
+		_, registered := service.registeredMigrationsSet[identifier]
+		_, inBatch := batch[identifier]
+		if registered || inBatch {
+			if m.allowDuplicateRegistration {
+				continue
+			}
+			return 0, m.misuse(fmt.Errorf(
+				"%w: type: %s, version: %s, service: %s",
+				ErrDuplicateMigration, migrationsStruct[i].MigrationType, migrationVersion, serviceName,
+			))
 		}
 
+		m.warnBaselineVersionedPair(serviceName, service, batch, migrationVersion, migrationsStruct[i].MigrationType)
+
 		migrationsStruct[i].Identifier = identifier
-		service.registeredMigrationsSet[identifier] = &migrationsStruct[i]
-		service.registeredMigrations = append(service.registeredMigrations, &migrationsStruct[i])
+		batch[identifier] = &migrationsStruct[i]
+		accepted = append(accepted, &migrationsStruct[i])
 	}
 
-	return nil
+	for _, migration := range accepted {
+		service.registeredMigrationsSet[migration.Identifier] = migration
+		service.registeredMigrations = append(service.registeredMigrations, migration)
+		service.resetRunCache()
+	}
+
+	return len(accepted), nil
 }
 
-// CheckFulfillment проверяет корректность установки всех миграций. Проверяется, что нет миграций со статусом
-// models.StateFailure, затем проверяется, что все зарегистрированные миграции выше послденей сохраненной версии сохранены и
-// выполнены успешно, затем проверяется, что target версия установлена выше или равной последней найденной миграции.
+// warnBaselineVersionedPair предупреждает о регистрации миграций типов TypeVersioned и TypeBaseline с одной версией:
+// такие миграции допустимы, но обычно являются ошибкой копирования. batch - проверенные миграции текущего вызова
+// Register.
+func (m *MigrationManager) warnBaselineVersionedPair(
+	serviceName string,
+	service *ServiceInfo,
+	batch map[uint32]*Migration,
+	version models.Version,
+	migrationType MigrationType,
+) {
+	var pairType MigrationType
+	switch migrationType {
+	case TypeVersioned:
+		pairType = TypeBaseline
+	case TypeBaseline:
+		pairType = TypeVersioned
+	default:
+		return
+	}
+
+	pairIdentifier := getMigrationIdentifier(version, string(pairType))
+	_, registered := service.registeredMigrationsSet[pairIdentifier]
+	_, inBatch := batch[pairIdentifier]
+	if registered || inBatch {
+		m.logger.Warn(fmt.Sprintf(
+			"%s and %s migrations registered with the same version %s, service: %s",
+			migrationType, pairType, version, serviceName,
+		))
+	}
+}
+
+// validateMigration проверяет корректность полей миграции и возвращает ее разобранную версию.
+func validateMigration(migration *Migration) (models.Version, error) {
+	version, err := models.ParseVersion(migration.Version)
+	if err != nil {
+		return models.Version{}, err
+	}
+
+	switch migration.MigrationType {
+	case TypeBaseline, TypeVersioned, TypeRepeatable:
+	default:
+		return models.Version{}, fmt.Errorf("unknown migration type: %s, version: %s", migration.MigrationType, migration.Version)
+	}
+
+	if err := migration.validateSources(); err != nil {
+		return models.Version{}, err
+	}
+
+	if migration.MigrationType != TypeRepeatable && (len(migration.MinVersion) > 0 || len(migration.MaxVersion) > 0) {
+		return models.Version{}, fmt.Errorf(
+			"MinVersion and MaxVersion are allowed only for repeatable migrations, version: %s", migration.Version,
+		)
+	}
+
+	for _, bound := range []string{migration.MinVersion, migration.MaxVersion} {
+		if len(bound) == 0 {
+			continue
+		}
+		if _, err = models.ParseVersion(bound); err != nil {
+			return models.Version{}, err
+		}
+	}
+
+	// версии зависимостей проверяются при регистрации, а не при выполнении плана
+	for _, dependency := range migration.Dependency {
+		if _, err = models.ParseVersion(dependency.Version); err != nil {
+			return models.Version{}, fmt.Errorf("dependency %s: %w, version: %s", dependency.Name, err, migration.Version)
+		}
+	}
+
+	if migration.MigrationType != TypeRepeatable && migration.OnFailure != FailureAbort {
+		return models.Version{}, fmt.Errorf(
+			"OnFailure is allowed only for repeatable migrations, version: %s", migration.Version,
+		)
+	}
+
+	if migration.OnFailure == FailureWarnAndContinueUntilNConsecutive && migration.MaxConsecutiveFailures <= 0 {
+		return models.Version{}, fmt.Errorf(
+			"MaxConsecutiveFailures must be positive, version: %s", migration.Version,
+		)
+	}
+
+	if migration.MigrationType != TypeRepeatable && migration.RepeatUnconditional {
+		return models.Version{}, fmt.Errorf(
+			"RepeatUnconditional is allowed only for repeatable migrations, version: %s", migration.Version,
+		)
+	}
+
+	return version, nil
+}
+
+// CheckFulfillment проверяет корректность установки всех миграций. Проверяется, что все зарегистрированные миграции
+// до целевой версии сохранены и выполнены, что нет миграций со статусом models.StateFailure и что контрольные суммы
+// выполненных миграций не изменились.
+//
+// Следующие причины не мешают работе сервиса и возвращаются с ok = true:
+//   - ErrHasFailedAllowedMigrations - найдены миграции типа TypeRepeatable, ошибка которых допущена политикой
+//     OnFailure;
+//   - ErrTargetVersionNotLatest - зарегистрированы или сохранены миграции выше целевой версии, которые Migrate не
+//     выполняет до повышения целевой версии.
+//
+// Deprecated: используйте CheckFulfillmentContext.
 func (m *MigrationManager) CheckFulfillment(serviceName string) (reasonErr error, ok bool, err error) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	result, err := m.CheckFulfillmentContext(context.Background(), serviceName)
+	return result.Reason, result.Ok, err
+}
 
-	service, ok := m.services[serviceName]
+// CheckFulfillmentContext проверяет, что миграции сервиса выполнены. Причина невыполнения возвращается в
+// FulfillmentResult.Reason, ошибка проверки - в FulfillmentResult.Err и в качестве второго значения.
+func (m *MigrationManager) CheckFulfillmentContext(ctx context.Context, serviceName string) (FulfillmentResult, error) {
+	if err := ctx.Err(); err != nil {
+		return FulfillmentResult{Err: err}, err
+	}
+
+	service, ok := m.service(serviceName)
+	if !ok {
+		err := m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+		return FulfillmentResult{Reason: ErrServiceNotFound, Err: err}, err
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	reason, ok, err := m.checkFulfillment(ctx, serviceName)
+	return FulfillmentResult{Reason: reason, Ok: ok, Err: err}, err
+}
+
+func (m *MigrationManager) checkFulfillment(ctx context.Context, serviceName string) (reasonErr error, ok bool, err error) {
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return errors.New("service not found"), false, fmt.Errorf("service %s not found", serviceName)
+		return ErrServiceNotFound, false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
-	service.Db = service.ConnectFunc()
+	service.Db, err = m.connect(ctx, serviceName, service)
+	if err != nil {
+		return nil, false, err
+	}
 	defer func() {
 		service.DisconnectFunc(service.Db)
 	}()
 
+	// без проверки соединения недоступная база данных неотличима от базы данных без системных таблиц
+	err = pingConnection(ctx, service.Db)
+	if err != nil {
+		return nil, false, fmt.Errorf("fail to connect to service %s: %w", serviceName, err)
+	}
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, false, err
+	}
+
 	hasForthcoming, err := m.hasForthcomingMigrations(serviceName)
 	if err != nil {
 		return nil, false, err
@@ -156,12 +538,29 @@ func (m *MigrationManager) CheckFulfillment(serviceName string) (reasonErr error
 		return ErrHasFailedMigrations, false, err
 	}
 
+	checksumErr, err := m.checkSavedChecksums(serviceName)
+	if err != nil {
+		return nil, false, err
+	}
+	if checksumErr != nil {
+		return checksumErr, false, nil
+	}
+
+	hasFailedAllowed, err := m.hasMigrationsInState(serviceName, models.StateFailedAllowed)
+	if err != nil {
+		return nil, false, err
+	}
+	if hasFailedAllowed {
+		return ErrHasFailedAllowedMigrations, true, nil
+	}
+
+	// миграции выше целевой версии не выполняются Migrate, поэтому сервис считается обновленным до целевой версии
 	targetVersionNotLatest, err := m.targetVersionNotLatest(serviceName)
 	if err != nil {
 		return nil, false, err
 	}
 	if targetVersionNotLatest {
-		return ErrTargetVersionNotLatest, false, nil
+		return ErrTargetVersionNotLatest, true, nil
 	}
 
 	return nil, true, nil
@@ -169,11 +568,16 @@ func (m *MigrationManager) CheckFulfillment(serviceName string) (reasonErr error
 
 // hasFailedMigrations определяет есть ли миграции, не выполненные из-за ошибки.
 func (m *MigrationManager) hasFailedMigrations(serviceName string) (bool, error) {
-	service, ok := m.services[serviceName]
+	return m.hasMigrationsInState(serviceName, models.StateFailure)
+}
+
+// hasMigrationsInState определяет есть ли сохраненные миграции в состоянии state.
+func (m *MigrationManager) hasMigrationsInState(serviceName string, state models.MigrationState) (bool, error) {
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return false, fmt.Errorf("service %s not found", serviceName)
+		return false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	// не было выполнено ни одной, следовательно, пока ошибок не было
@@ -181,27 +585,22 @@ func (m *MigrationManager) hasFailedMigrations(serviceName string) (bool, error)
 		return false, nil
 	}
 
-	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderASC)
+	count, err := repository.CountMigrationsInState(service.Db, state)
 	if err != nil {
 		return false, err
 	}
 
-	for i := range savedMigrations {
-		if savedMigrations[i].State == models.StateFailure {
-			return true, nil
-		}
-	}
-	return false, nil
+	return count > 0, nil
 }
 
 // hasForthcomingMigrations проверяет, есть ли зарегистрированные или сохраненные невыполненные миграции, выше текущей
 // сохраненной версии.
 func (m *MigrationManager) hasForthcomingMigrations(serviceName string) (bool, error) {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return false, fmt.Errorf("service %s not found", serviceName)
+		return false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	// не было выполнено ни одной
@@ -215,21 +614,76 @@ func (m *MigrationManager) hasForthcomingMigrations(serviceName string) (bool, e
 		return false, err
 	}
 
-	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderASC)
+	savedMigrations, err := repository.GetMigrationKeys(service.Db)
+	if err != nil {
+		return false, err
+	}
+
+	// признак OutOfOrder не входит в ключевые поля миграций
+	outOfOrder, err := repository.GetPendingOutOfOrder(service.Db)
 	if err != nil {
 		return false, err
 	}
+	pendingOutOfOrder := make(map[uint32]struct{}, len(outOfOrder))
+	for i := range outOfOrder {
+		pendingOutOfOrder[outOfOrder[i].Id] = struct{}{}
+	}
+
+	// миграции выше целевой версии не выполняются Migrate (см. planMigrationsVersioned), поэтому не считаются
+	// ожидающими выполнения; о них сообщает ErrTargetVersionNotLatest
+	targetVersion := service.targetVersion()
 
 	for i := range savedMigrations {
-		if savedMigrations[i].Version.MoreOrEqual(savedVersion) && savedMigrations[i].State != models.StateSuccess {
+		if savedMigrations[i].State == models.StateAbandoned {
+			continue
+		}
+		if returnedRepeatable(savedMigrations[i]) {
+			pending, err := m.returnedRepeatablePending(serviceName, savedMigrations[i])
+			if err != nil {
+				return false, err
+			}
+			if pending {
+				return true, nil
+			}
+			continue
+		}
+		if savedMigrations[i].Type != string(TypeRepeatable) && savedMigrations[i].Version.MoreThan(targetVersion) {
+			continue
+		}
+		if savedMigrations[i].State == models.StateSuccess {
+			continue
+		}
+		if savedMigrations[i].Type == string(TypeRepeatable) {
+			pending, err := m.repeatablePending(serviceName, savedMigrations[i], savedVersion)
+			if err != nil {
+				return false, err
+			}
+			if pending {
+				return true, nil
+			}
+			continue
+		}
+		// миграции, зарегистрированные вне очереди, выполняются несмотря на более высокую сохраненную версию
+		if _, ok := pendingOutOfOrder[savedMigrations[i].Id]; ok || savedMigrations[i].Version.MoreOrEqual(savedVersion) {
 			return true, nil
 		}
 	}
 
 	for i := range service.registeredMigrations {
+		migration := service.registeredMigrations[i]
+		if migration.MigrationType != TypeRepeatable {
+			migrationVersion, err := models.ParseVersion(migration.Version)
+			if err != nil {
+				return false, err
+			}
+			if migrationVersion.MoreThan(targetVersion) {
+				continue
+			}
+		}
+
 		// достаточно проверить, что миграция еще не сохранена, т.к. создание новых миграций разрешено только для версий
 		// выше текущей максимальной версии сохраненных миграций
-		if migrationIsNew(service.registeredMigrations[i], savedMigrations) {
+		if migrationIsNew(migration, savedMigrations) {
 			return true, nil
 		}
 	}
@@ -237,14 +691,48 @@ func (m *MigrationManager) hasForthcomingMigrations(serviceName string) (bool, e
 	return false, nil
 }
 
+// repeatablePending определяет, ожидает ли выполнения сохраненная миграция типа TypeRepeatable, не выполненная
+// успешно, так же, как planMigrationsRepeatable: миграция вне диапазона версий (см. Migration.MinVersion и
+// Migration.MaxVersion) или с неизменной контрольной суммой не выполняется. Миграция, ошибка которой допущена
+// политикой OnFailure, не считается ожидающей: о ней сообщает ErrHasFailedAllowedMigrations.
+func (m *MigrationManager) repeatablePending(
+	serviceName string,
+	migrationModel models.MigrationModel,
+	savedVersion models.Version,
+) (bool, error) {
+	switch migrationModel.State {
+	case models.StateFailedAllowed:
+		return false, nil
+	case models.StateFailure:
+		return true, nil
+	}
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		// незарегистрированная миграция будет отмечена Migrate как отсутствующая
+		return migrationModel.State != models.StateSkipped, nil
+	}
+
+	inRange, _, _, err := repeatableInVersionRange(savedVersion, migration)
+	if err != nil || !inRange {
+		return false, err
+	}
+
+	service, _ := m.service(serviceName)
+	return repeatableNeedsRun(migrationModel, migration, migration.checksum(service.Db)), nil
+}
+
 // targetVersionNotLatest проверяет, является ли target версия выше или равной максимальной версии зарегистрированной
 // или сохраненной миграции.
 func (m *MigrationManager) targetVersionNotLatest(serviceName string) (bool, error) {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return false, fmt.Errorf("service %s not found", serviceName)
+		return false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	// не было выполнено ни одной, следовательно, пока ошибок не было
@@ -252,7 +740,12 @@ func (m *MigrationManager) targetVersionNotLatest(serviceName string) (bool, err
 		return false, nil
 	}
 
-	savedMigrations, err := repository.GetMigrationsSorted(service.Db, repository.OrderASC)
+	// целевая версия TargetLatest всегда соответствует последней зарегистрированной миграции
+	if service.targetLatest {
+		return false, nil
+	}
+
+	savedMigrations, err := repository.GetMigrationKeys(service.Db)
 	if err != nil {
 		return false, err
 	}
@@ -279,11 +772,11 @@ func (m *MigrationManager) targetVersionNotLatest(serviceName string) (bool, err
 }
 
 func (m *MigrationManager) findMigration(serviceName string, migrationModel models.MigrationModel) (*Migration, bool, error) {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return nil, false, fmt.Errorf("service %s not found", serviceName)
+		return nil, false, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	migrationModelIdentifier := getMigrationIdentifier(migrationModel.Version, migrationModel.Type)
@@ -305,11 +798,11 @@ func (m *MigrationManager) findMigration(serviceName string, migrationModel mode
 }
 
 func (m *MigrationManager) getSavedAppVersion(serviceName string) (models.Version, error) {
-	service, ok := m.services[serviceName]
+	service, ok := m.service(serviceName)
 
 	if !ok {
 		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
-		return models.Version{}, fmt.Errorf("service %s not found", serviceName)
+		return models.Version{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
 	}
 
 	savedAppVersion, err := repository.GetVersion(service.Db)
@@ -331,9 +824,50 @@ func migrationIsNew(migration *Migration, savedMigrations []models.MigrationMode
 	return true
 }
 
+// countMigrationsAhead возвращает количество сохраненных миграций, которые не зарегистрированы и имеют версию выше
+// максимальной версии зарегистрированных миграций. Ненулевое значение означает, что база данных обновлена более новой
+// версией приложения.
+func countMigrationsAhead(registeredMigrations []*Migration, savedMigrations []models.MigrationModel) (int, error) {
+	var maxRegisteredVersion models.Version
+	for i := range registeredMigrations {
+		version, err := models.ParseVersion(registeredMigrations[i].Version)
+		if err != nil {
+			return 0, err
+		}
+		if version.MoreThan(maxRegisteredVersion) {
+			maxRegisteredVersion = version
+		}
+	}
+
+	registered := make(map[uint32]struct{}, len(registeredMigrations))
+	for i := range registeredMigrations {
+		registered[registeredMigrations[i].Identifier] = struct{}{}
+	}
+
+	count := 0
+	for i := range savedMigrations {
+		if _, ok := registered[getMigrationIdentifier(savedMigrations[i].Version, savedMigrations[i].Type)]; ok {
+			continue
+		}
+		if savedMigrations[i].Version.MoreThan(maxRegisteredVersion) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
 func getMigrationIdentifier(version models.Version, migrationType string) uint32 {
 	h := fnv.New32a()
 	// fmv.sum64a always writes with no error
 	_, _ = h.Write([]byte(version.String() + migrationType))
 	return h.Sum32()
 }
+
+// misuse возвращает ошибку использования библиотеки или паникует с ней, если задана опция WithPanicOnMisuse.
+func (m *MigrationManager) misuse(err error) error {
+	if m.panicOnMisuse {
+		panic(err)
+	}
+	return err
+}
@@ -1,7 +1,9 @@
 package db_migrator
 
 import (
+	"io"
 	"log/slog"
+	"time"
 )
 
 type ManagerOption func(*MigrationManager)
@@ -11,3 +13,313 @@ func WithLogger(logger *slog.Logger) ManagerOption {
 		m.logger = logger
 	}
 }
+
+// WithForbidOlderBinary запрещает выполнение Migrate, если в базе данных сохранены миграции с версией выше
+// максимальной из зарегистрированных (например, при откате приложения на предыдущую версию). По умолчанию такая
+// ситуация допускается: новые миграции не выполняются, в лог выводится предупреждение.
+func WithForbidOlderBinary() ManagerOption {
+	return func(m *MigrationManager) {
+		m.forbidOlderBinary = true
+	}
+}
+
+// WithConfirmation задает функцию подтверждения опасных операций: Downgrade, MarkApplied, ApplyOne с force и Rerun
+// миграции типа TypeBaseline с RerunForce (см. Operation). Функция вызывается перед выполнением операции, при
+// возврате false операция прерывается с ошибкой ErrNotConfirmed. Если опция не задана, операции выполняются без
+// подтверждения.
+func WithConfirmation(confirmation func(ConfirmationRequest) (bool, error)) ManagerOption {
+	return func(m *MigrationManager) {
+		m.confirmation = confirmation
+	}
+}
+
+// WithConfirmationThreshold задает минимальное количество отменяемых миграций, при котором Downgrade требует
+// подтверждения (см. WithConfirmation). По умолчанию подтверждается отмена любого количества миграций.
+func WithConfirmationThreshold(migrations int) ManagerOption {
+	return func(m *MigrationManager) {
+		m.confirmationThreshold = migrations
+	}
+}
+
+// WithPolicyProfile задает профиль политик, проверяемых при регистрации миграций и перед их выполнением.
+// По умолчанию используется RelaxedProfile.
+func WithPolicyProfile(profile Profile) ManagerOption {
+	return func(m *MigrationManager) {
+		m.profile = profile
+	}
+}
+
+// WithAutoMigrateOnRegister включает автоматическое выполнение Migrate при регистрации новых миграций для сервисов,
+// для которых Migrate уже был успешно выполнен в текущем процессе. Используется при отложенной регистрации миграций
+// подключаемыми модулями.
+func WithAutoMigrateOnRegister(config AutoMigrateConfig) ManagerOption {
+	return func(m *MigrationManager) {
+		m.autoMigrateConfig = config
+	}
+}
+
+// WithRunOncePerProcess включает кеширование результата Migrate: после успешного выполнения повторные вызовы Migrate
+// для сервиса только сверяют сохраненную версию базы данных с целевой и возвращают отчет последнего выполнения (см.
+// MigrateWithReport), не планируя и не сохраняя миграции. Кеш сбрасывается регистрацией новых миграций, вызовом
+// InvalidateRunCache, операциями, изменяющими состояние миграций (Downgrade, Redo, Repair, MarkApplied, Rerun,
+// ApplyOne и другие), а также если сохраненная версия оказалась ниже целевой. Запуск с RunOptions.TargetVersion
+// выполняется полностью.
+func WithRunOncePerProcess() ManagerOption {
+	return func(m *MigrationManager) {
+		m.runOnce = true
+	}
+}
+
+// WithColumnEncryption включает шифрование значений колонки description таблицы migrations. Значения, сохраненные до
+// включения шифрования, читаются без изменений. Для ротации ключей используется ReencryptMetadata.
+func WithColumnEncryption(encryptor ValueEncryptor) ManagerOption {
+	return func(m *MigrationManager) {
+		m.encryptor = encryptor
+	}
+}
+
+// WithRegistrationBatchSize задает количество записей, сохраняемых одним запросом при сохранении новых миграций в
+// таблицу migrations. Значение 1 отключает пакетную вставку. По умолчанию равен 100.
+func WithRegistrationBatchSize(batchSize int) ManagerOption {
+	return func(m *MigrationManager) {
+		m.batchSize = batchSize
+	}
+}
+
+// WithAuditWriter включает журнал аудита: каждое изменение состояния (начало и завершение запуска, начало, успешное
+// выполнение, ошибка и отмена миграции) записывается в w отдельной строкой JSON. Ошибки записи не прерывают выполнение
+// миграций.
+func WithAuditWriter(w io.Writer) ManagerOption {
+	return func(m *MigrationManager) {
+		m.auditWriter = w
+	}
+}
+
+// WithDefaultRunLabels задает метки, добавляемые к каждому запуску Migrate и Downgrade (см. RunOptions.RunLabels).
+func WithDefaultRunLabels(labels map[string]string) ManagerOption {
+	return func(m *MigrationManager) {
+		m.defaultRunLabels = labels
+	}
+}
+
+// WithTemplateData задает данные шаблонов миграций с Migration.RenderTemplate, например имена табличных пространств
+// и ролей, различающиеся между окружениями. Значения доступны в шаблоне как {{ .name }}.
+func WithTemplateData(data map[string]any) ManagerOption {
+	return func(m *MigrationManager) {
+		m.templateData = data
+	}
+}
+
+// WithAllowDuplicateRegistration разрешает повторную регистрацию миграции с той же версией и типом: повторная
+// регистрация пропускается, как до появления ErrDuplicateMigration. Предназначена для сценариев, регистрирующих
+// одни и те же миграции несколько раз (например, повторные попытки запуска).
+func WithAllowDuplicateRegistration() ManagerOption {
+	return func(m *MigrationManager) {
+		m.allowDuplicateRegistration = true
+	}
+}
+
+// WithDirectionConflictWindow задает окно, в пределах которого запуск в направлении, противоположном предыдущему
+// запуску, завершается ошибкой ErrConflictingRunDirection без RunOptions.AcknowledgeDirectionChange. По умолчанию
+// равно 0: проверка отключена.
+func WithDirectionConflictWindow(window time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.directionConflictWindow = window
+	}
+}
+
+// WithPlanLogging включает или отключает вывод плана (см. FormatPlan) в лог с уровнем Info перед выполнением Migrate и
+// Downgrade. По умолчанию включено, пустой план не выводится.
+func WithPlanLogging(enabled bool) ManagerOption {
+	return func(m *MigrationManager) {
+		m.planLogging = enabled
+	}
+}
+
+// WithLockWaitPolicy задает ожидание при блокировке миграции или межпроцессной блокировки сервиса другим экземпляром
+// приложения (ErrMigrationLocked, ErrLockNotAcquired): вместо возврата ошибки Migrate до maxWait опрашивает базу
+// данных с интервалом, увеличенным на случайную величину до jitter (своей для каждого опроса), и завершается успешно,
+// если другой экземпляр выполнил все миграции; в этом случае в отчете отмечается
+// MigrationReport.SatisfiedByOtherInstance. По умолчанию ожидание отключено.
+func WithLockWaitPolicy(maxWait time.Duration, jitter time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.lockWait = lockWaitPolicy{maxWait: maxWait, jitter: jitter}
+	}
+}
+
+// WithFreezeAfterMigrate запрещает регистрацию миграций сервиса после того, как для него был выполнен Migrate в
+// текущем процессе: Register и RegisterLite возвращают ErrRegistrationsFrozen. Запрет снимается вызовом
+// AllowLateRegistrations, а также не действует для сервисов, указанных в WithAutoMigrateOnRegister.
+func WithFreezeAfterMigrate() ManagerOption {
+	return func(m *MigrationManager) {
+		m.freezeAfterMigrate = true
+	}
+}
+
+// WithLocale задает язык описаний, возвращаемых Describe. По умолчанию используется LocaleEN.
+func WithLocale(locale Locale) ManagerOption {
+	return func(m *MigrationManager) {
+		m.locale = locale
+	}
+}
+
+// WithPanicOnMisuse включает панику вместо возврата ошибки при ошибках использования библиотеки: регистрации
+// некорректных миграций, некорректной целевой версии и обращении к незарегистрированному сервису. Предназначена для
+// кода инициализации, рассчитывающего на немедленное падение.
+func WithPanicOnMisuse() ManagerOption {
+	return func(m *MigrationManager) {
+		m.panicOnMisuse = true
+	}
+}
+
+// WithSchemaTracking включает отслеживание изменений схемы вне миграций: по завершении Migrate и Downgrade снимок
+// структуры таблиц сохраняется в таблицу migration_runs, а в начале следующего запуска сравнивается с текущей схемой.
+// При расхождении в лог выводится предупреждение с перечнем измененных таблиц. Системные таблицы мигратора не
+// учитываются.
+func WithSchemaTracking() ManagerOption {
+	return func(m *MigrationManager) {
+		m.schemaTracking = true
+	}
+}
+
+// WithStrictSchemaTracking включает WithSchemaTracking, при этом расхождение схемы прерывает запуск с ошибкой
+// ErrSchemaDrift до выполнения миграций.
+func WithStrictSchemaTracking() ManagerOption {
+	return func(m *MigrationManager) {
+		m.schemaTracking = true
+		m.strictSchemaTracking = true
+	}
+}
+
+// WithExtensionAutoCreate определяет, создаются ли отсутствующие расширения, указанные в Migration.RequiredExtensions
+// и WithRequiredExtensions (по умолчанию true). При false отсутствие расширения приводит к ErrExtensionUnavailable до
+// выполнения миграций.
+func WithExtensionAutoCreate(enabled bool) ManagerOption {
+	return func(m *MigrationManager) {
+		m.extensionAutoCreate = enabled
+	}
+}
+
+// WithRegistrationOverlapCheck включает проверку перед MigrateAll: если доля миграций, совпадающих у двух сервисов по
+// типу, версии и тексту Up, превышает percent процентов, выводится предупреждение (или возвращается
+// ErrRegistrationOverlap, см. Profile.ForbidRegistrationOverlap). См. RegistrationOverlaps.
+func WithRegistrationOverlapCheck(percent int) ManagerOption {
+	return func(m *MigrationManager) {
+		m.overlapThreshold = percent
+	}
+}
+
+// WithMigrationTimeout задает ограничение времени выполнения миграций, для которых не задан Migration.Timeout.
+func WithMigrationTimeout(timeout time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.migrationTimeout = timeout
+	}
+}
+
+// WithLockTimeout задает время ожидания межпроцессной блокировки сервиса, захватываемой Migrate и Downgrade (для
+// postgres - pg_advisory_lock, для остальных диалектов - запись в таблице migrator_lock). По истечении времени запуск
+// завершается ошибкой ErrLockNotAcquired. Значение 0 снимает ограничение. По умолчанию равно 10 минутам.
+//
+// Запись в migrator_lock, оставшаяся после аварийного завершения процесса, перехватывается по истечении аренды (см.
+// WithLockLease) или удаляется MigrationManager.ReleaseLock.
+func WithLockTimeout(timeout time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.lockTimeout = timeout
+	}
+}
+
+// WithLockLease задает срок аренды записи блокировки в таблице migrator_lock (для диалектов, кроме postgres). Процесс,
+// удерживающий блокировку, продлевает аренду каждую треть срока; запись, аренда которой не продлевалась дольше срока,
+// считается оставшейся после аварийного завершения процесса и перехватывается ожидающим процессом. Срок должен
+// превышать возможные задержки записи в migrator_lock, например на время транзакционной миграции в sqlite. Значение 0
+// отключает аренду: запись удаляется только владельцем или MigrationManager.ReleaseLock. По умолчанию равно 2 минутам.
+func WithLockLease(lease time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.lockLease = lease
+	}
+}
+
+// WithSkipChecksumValidation отключает проверку контрольных сумм выполненных миграций типов TypeVersioned и
+// TypeBaseline (см. ErrChecksumMismatch) в Migrate и CheckFulfillment.
+func WithSkipChecksumValidation() ManagerOption {
+	return func(m *MigrationManager) {
+		m.skipChecksumValidation = true
+	}
+}
+
+// WithSkipHygieneChecks отключает проверку состояния соединений после нетранзакционных миграций (см.
+// WithStrictHygiene).
+func WithSkipHygieneChecks() ManagerOption {
+	return func(m *MigrationManager) {
+		m.skipHygieneChecks = true
+	}
+}
+
+// WithStrictHygiene включает ошибку ErrLeakedState вместо предупреждения, если нетранзакционная миграция оставила
+// незавершенные транзакции, advisory locks, временные таблицы (postgres) или занятые соединения пула. Проверка
+// выполняется после каждой нетранзакционной миграции сравнением с состоянием до ее выполнения.
+func WithStrictHygiene() ManagerOption {
+	return func(m *MigrationManager) {
+		m.strictHygiene = true
+	}
+}
+
+// WithConnectRetry включает повторные попытки подключения к базе данных сервиса в Migrate, Downgrade и
+// CheckFulfillment: соединение, полученное через ConnectFunc, проверяется запросом ping, а при ошибке (или если
+// ConnectFunc вернул nil) подключение повторяется до maxAttempts раз с интервалом backoff, удваиваемым с каждой
+// попыткой (не более 30 секунд), со случайной добавкой. Каждая неудачная попытка выводится в лог с уровнем Warn,
+// итоговая ошибка оборачивает ошибку последней попытки. Значения maxAttempts 0 и 1 отключают повторы.
+func WithConnectRetry(maxAttempts int, backoff time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.connectRetry = connectRetryPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+// WithIdentity задает идентификатор процесса, выполняющего миграции (например, имя пода), сохраняемый в таблице
+// migrations, журнале аудита и таблице migration_runs. По умолчанию используется user@host.
+func WithIdentity(identity string) ManagerOption {
+	return func(m *MigrationManager) {
+		m.identity = identity
+	}
+}
+
+// WithSlowPhaseThreshold включает вывод в лог с уровнем Info длительности этапов Migrate (см. PhaseTimings) одной
+// строкой, если длительность какого-либо этапа превысила threshold. Значение 0 отключает вывод.
+func WithSlowPhaseThreshold(threshold time.Duration) ManagerOption {
+	return func(m *MigrationManager) {
+		m.slowPhaseThreshold = threshold
+	}
+}
+
+// WithStatementTimeout включает установку statement_timeout (SET LOCAL) в транзакционных миграциях с ограничением
+// времени выполнения для postgres, чтобы запрос прерывался сервером, а не только отменой контекста.
+func WithStatementTimeout() ManagerOption {
+	return func(m *MigrationManager) {
+		m.statementTimeout = true
+	}
+}
+
+// WithBeforeMigration задает обработчик, вызываемый перед выполнением каждой миграции при Migrate и Downgrade (а также
+// ApplyOne и Rerun) до выполнения SQL. Ошибка обработчика прерывает запуск с ErrAbortedByHook, состояние миграции при
+// этом не изменяется. При RunOptions.RepeatableConcurrency обработчики могут вызываться параллельно.
+func WithBeforeMigration(hook func(service string, info MigrationInfo) error) ManagerOption {
+	return func(m *MigrationManager) {
+		m.beforeMigrationHook = hook
+	}
+}
+
+// WithAfterMigration задает обработчик, вызываемый после успешного выполнения каждой миграции с длительностью ее
+// выполнения.
+func WithAfterMigration(hook func(service string, info MigrationInfo, duration time.Duration)) ManagerOption {
+	return func(m *MigrationManager) {
+		m.afterMigrationHook = hook
+	}
+}
+
+// WithOnError задает обработчик, вызываемый при ошибке выполнения миграции, в том числе допустимой (IsAllowFailure,
+// Migration.OnFailure). Не вызывается, если выполнение прервано обработчиком WithBeforeMigration.
+func WithOnError(hook func(service string, info MigrationInfo, err error)) ManagerOption {
+	return func(m *MigrationManager) {
+		m.onErrorHook = hook
+	}
+}
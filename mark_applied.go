@@ -0,0 +1,145 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// MarkApplied отмечает зарегистрированную миграцию с версией version как успешно выполненную, не выполняя ее:
+// сохраняет новые миграции, переводит миграцию в StateSuccess с текущими временем выполнения и контрольной суммой и,
+// для миграций типов TypeVersioned и TypeBaseline, повышает сохраненную версию базы данных. Используется при переходе
+// на библиотеку в существующей базе данных, изменения которой уже применены.
+//
+// Повторный вызов для уже выполненной миграции ничего не изменяет. Возвращает ошибку, если version выше целевой
+// версии сервиса или версии соответствует несколько миграций разных типов.
+func (m *MigrationManager) MarkApplied(serviceName string, version string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	parsedVersion, err := models.ParseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if targetVersion := service.serviceTargetVersion(); parsedVersion.MoreThan(targetVersion) {
+		return fmt.Errorf("version %s is above target version %s, service: %s", version, targetVersion, serviceName)
+	}
+
+	var migration *Migration
+	for _, registered := range service.registeredMigrations {
+		registeredVersion, err := models.ParseVersion(registered.Version)
+		if err != nil {
+			return err
+		}
+		if !registeredVersion.Equals(parsedVersion) {
+			continue
+		}
+		if migration != nil {
+			return fmt.Errorf(
+				"version %s matches %s and %s migrations, service: %s",
+				version, migration.MigrationType, registered.MigrationType, serviceName,
+			)
+		}
+		migration = registered
+	}
+
+	if migration == nil {
+		return fmt.Errorf("migration with version %s is not registered, service: %s", version, serviceName)
+	}
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	err = m.initSystemTables(serviceName)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.saveNewMigrations(serviceName)
+	if err != nil {
+		return err
+	}
+
+	var migrationModel models.MigrationModel
+	found := false
+	for i := range savedMigrations {
+		if savedMigrations[i].Type == string(migration.MigrationType) && savedMigrations[i].Version.Equals(parsedVersion) {
+			migrationModel = savedMigrations[i]
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("migration (type: %s, Version: %s) is not saved, service: %s", migration.MigrationType, version, serviceName)
+	}
+
+	if migrationModel.State == models.StateSuccess {
+		m.logger.Info(
+			fmt.Sprintf(
+				"migration (type: %s, Version: %s) already applied, service: %s",
+				migrationModel.Type, migrationModel.Version, serviceName,
+			),
+		)
+		return nil
+	}
+
+	err = m.confirmMigration(
+		OperationForceVersion, serviceName, migrationModel,
+		fmt.Sprintf("%s migration %s will be marked as applied without execution", migrationModel.Type, migrationModel.Version),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = transitionExecuted(service.Db, &migrationModel, models.StateSuccess, "marked as applied", migration.checksum(service.Db), m.executedBy(service))
+	if err != nil {
+		return err
+	}
+
+	if migration.MigrationType != TypeRepeatable {
+		savedVersion, err := m.getSavedAppVersion(serviceName)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		if errors.Is(err, repository.ErrNotFound) || parsedVersion.MoreThan(savedVersion) {
+			source := repository.VersionSource{
+				Version:   parsedVersion.String(),
+				Type:      VersionSetByMark,
+				Rank:      migrationModel.Rank,
+				Direction: DirectionUp,
+			}
+			err = repository.SaveVersion(service.Db, parsedVersion, source)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	m.logger.Info(
+		fmt.Sprintf(
+			"migration (type: %s, Version: %s) marked as applied, service: %s",
+			migrationModel.Type, migrationModel.Version, serviceName,
+		),
+	)
+
+	return nil
+}
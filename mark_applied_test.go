@@ -0,0 +1,64 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMarkApplied(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+
+	executed := make([]string, 0)
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+			executed = append(executed, "1.0.0")
+			return db.Exec("create table a(id int)").Error
+		}},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+			executed = append(executed, "1.0.1")
+			return db.Exec("create table b(id int)").Error
+		}},
+	))
+
+	require.NoError(t, m.MarkApplied("service1", "1.0.0"))
+	require.Empty(t, executed, "marked migration must not be executed")
+	require.False(t, connect().Migrator().HasTable("a"))
+
+	savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+	require.NoError(t, err)
+	require.Len(t, savedMigrations, 2)
+	require.Equal(t, models.StateSuccess, savedMigrations[0].State)
+	require.NotNil(t, savedMigrations[0].ExecutedOn)
+	require.Equal(t, models.StateRegistered, savedMigrations[1].State)
+
+	record, err := m.SavedVersionRecord("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0.0", record.Version.String())
+	require.Equal(t, VersionSetByMark, record.SetByType)
+
+	// повторный вызов для выполненной миграции ничего не изменяет
+	require.NoError(t, m.MarkApplied("service1", "1.0.0"))
+
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, []string{"1.0.1"}, executed)
+
+	version, err := m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", version.String())
+}
+
+func TestMarkAppliedRejects(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.0")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	))
+
+	require.ErrorContains(t, m.MarkApplied("service1", "1.0.1"), "above target version")
+	require.ErrorContains(t, m.MarkApplied("service1", "0.9.0"), "is not registered")
+	require.ErrorIs(t, m.MarkApplied("service2", "1.0.0"), ErrServiceNotFound)
+}
@@ -0,0 +1,133 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// MigrateAllOptions задает параметры MigrateAll.
+type MigrateAllOptions struct {
+	// Exclude - сервисы, для которых Migrate не выполняется.
+	Exclude []string
+	// Run - параметры запуска Migrate для каждого сервиса.
+	Run RunOptions
+}
+
+// MigrateAll выполняет MigrateContext для всех зарегистрированных сервисов, кроме исключенных. Сервисы упорядочиваются
+// так, чтобы сервисы, указанные в DbDependency миграций, выполнялись раньше зависящих от них; при отсутствии
+// зависимостей и для циклических зависимостей используется алфавитный порядок.
+//
+// Ошибка одного сервиса не прерывает выполнение остальных. Возвращает ошибки по сервисам (nil для успешно
+// выполненных) и объединение всех ошибок. При отмене ctx оставшиеся сервисы не выполняются и получают ошибку ctx.
+func (m *MigrationManager) MigrateAll(ctx context.Context, opts MigrateAllOptions) (map[string]error, error) {
+	order := m.migrationOrder(opts.Exclude)
+
+	err := m.checkRegistrationOverlaps()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]error, len(order))
+	errs := make([]error, 0)
+
+	for _, serviceName := range order {
+		if err := ctx.Err(); err != nil {
+			results[serviceName] = err
+			errs = append(errs, fmt.Errorf("service %s: %w", serviceName, err))
+			continue
+		}
+
+		err := m.MigrateContext(ctx, serviceName, opts.Run)
+		results[serviceName] = err
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", serviceName, err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// migrationOrder возвращает сервисы с заданным ConnectFunc в порядке выполнения: зависимости раньше зависящих от них
+// сервисов, в остальном - по алфавиту.
+func (m *MigrationManager) migrationOrder(exclude []string) []string {
+	services := m.servicesSnapshot()
+
+	// dependencies[a] содержит имена сервисов, указанных в DbDependency миграций a
+	dependencies := make(map[string][]string, len(services))
+	serviceNames := make([]string, 0, len(services))
+	for name, service := range services {
+		service.mutex.Lock()
+		connectable := service.ConnectFunc != nil
+		for _, migration := range service.registeredMigrations {
+			for _, dependency := range migration.Dependency {
+				dependencies[name] = append(dependencies[name], dependency.Name)
+			}
+		}
+		service.mutex.Unlock()
+
+		if !connectable || slices.Contains(exclude, name) {
+			continue
+		}
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	// dependsOn[a] содержит сервисы, которые должны быть выполнены до a
+	dependsOn := make(map[string]map[string]struct{}, len(serviceNames))
+	for _, name := range serviceNames {
+		dependsOn[name] = make(map[string]struct{})
+		for _, dependency := range dependencies[name] {
+			if dependency == name || !slices.Contains(serviceNames, dependency) {
+				continue
+			}
+			dependsOn[name][dependency] = struct{}{}
+		}
+	}
+
+	order := make([]string, 0, len(serviceNames))
+	done := make(map[string]struct{}, len(serviceNames))
+
+	for len(order) < len(serviceNames) {
+		progressed := false
+		for _, name := range serviceNames {
+			if _, ok := done[name]; ok {
+				continue
+			}
+			if !dependenciesDone(dependsOn[name], done) {
+				continue
+			}
+			order = append(order, name)
+			done[name] = struct{}{}
+			progressed = true
+			break
+		}
+
+		if progressed {
+			continue
+		}
+
+		// циклическая зависимость: выполняем первый по алфавиту из оставшихся сервисов
+		for _, name := range serviceNames {
+			if _, ok := done[name]; !ok {
+				m.logger.Warn(fmt.Sprintf("cyclic service dependency detected, migrating %s first", name))
+				order = append(order, name)
+				done[name] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return order
+}
+
+func dependenciesDone(dependencies map[string]struct{}, done map[string]struct{}) bool {
+	for dependency := range dependencies {
+		if _, ok := done[dependency]; !ok {
+			return false
+		}
+	}
+	return true
+}
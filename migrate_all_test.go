@@ -0,0 +1,104 @@
+package db_migrator
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMigrationOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// dependencies - зависимости сервисов, указанные в DbDependency миграций
+		dependencies map[string][]string
+		// detached - сервисы без ConnectFunc
+		detached []string
+		exclude  []string
+		want     []string
+	}{
+		{
+			name:         "alphabetical without dependencies",
+			dependencies: map[string][]string{"c": nil, "a": nil, "b": nil},
+			want:         []string{"a", "b", "c"},
+		},
+		{
+			name:         "dependencies first",
+			dependencies: map[string][]string{"a": {"c"}, "b": nil, "c": {"b"}},
+			want:         []string{"b", "c", "a"},
+		},
+		{
+			name:         "diamond",
+			dependencies: map[string][]string{"app": {"users", "billing"}, "billing": {"users"}, "users": nil},
+			want:         []string{"users", "billing", "app"},
+		},
+		{
+			name:         "cycle falls back to alphabetical",
+			dependencies: map[string][]string{"a": {"b"}, "b": {"a"}, "c": nil},
+			want:         []string{"c", "a", "b"},
+		},
+		{
+			name:         "self, unknown and excluded dependencies are ignored",
+			dependencies: map[string][]string{"a": {"a", "missing", "b"}, "b": {"c"}, "c": nil},
+			exclude:      []string{"b"},
+			want:         []string{"a", "c"},
+		},
+		{
+			name:         "services without connection are skipped",
+			dependencies: map[string][]string{"a": {"b"}, "b": nil},
+			detached:     []string{"b"},
+			want:         []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+
+			for name, dependencies := range tt.dependencies {
+				if !slices.Contains(tt.detached, name) {
+					require.NoError(t, m.RegisterService(name, func() *gorm.DB { return nil }, func(*gorm.DB) {}, "1.0.1"))
+				}
+
+				migration := Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "select 1"}
+				for _, dependency := range dependencies {
+					migration.Dependency = append(migration.Dependency, DbDependency{Name: dependency, Version: "1.0.0"})
+				}
+				require.NoError(t, m.Register(name, migration))
+			}
+
+			require.Equal(t, tt.want, m.migrationOrder(tt.exclude))
+		})
+	}
+}
+
+func TestMigrateAllRunsDependenciesFirst(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	usersConnect, usersDisconnect := newTestDatabase(t)
+	ordersConnect, ordersDisconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("orders", ordersConnect, ordersDisconnect, "1.0.1"))
+	require.NoError(t, m.RegisterService("users", usersConnect, usersDisconnect, "1.0.0"))
+
+	require.NoError(t, m.Register("orders",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table orders(id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Up:              "alter table orders add column user_id int",
+			Dependency:      []DbDependency{{Name: "users", Version: "1.0.0"}},
+		},
+	))
+	require.NoError(t, m.Register("users",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table users(id int)"},
+	))
+
+	results, err := m.MigrateAll(context.Background(), MigrateAllOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]error{"orders": nil, "users": nil}, results)
+}
@@ -0,0 +1,114 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// MigrateTo выполняет MigrateContext с фоновым контекстом, ограничивая выполняемые миграции версией version вместо
+// целевой версии сервиса (см. RunOptions.TargetVersion). Целевая версия сервиса не изменяется.
+//
+// Возвращает ErrTargetBelowSavedVersion, если version ниже сохраненной версии базы данных.
+func (m *MigrationManager) MigrateTo(serviceName string, version string) error {
+	return m.MigrateContext(context.Background(), serviceName, RunOptions{TargetVersion: version})
+}
+
+// TargetLatest - целевая версия сервиса, равная максимальной версии зарегистрированных миграций на момент запуска.
+// Позволяет не изменять целевую версию при каждом выпуске, если сервису всегда нужны все миграции.
+const TargetLatest = "latest"
+
+// targetVersion возвращает целевую версию выполняемого запуска: заданную RunOptions.TargetVersion или целевую версию
+// сервиса.
+func (s *ServiceInfo) targetVersion() models.Version {
+	if s.runTargetVersion != nil {
+		return *s.runTargetVersion
+	}
+	return s.serviceTargetVersion()
+}
+
+// serviceTargetVersion возвращает целевую версию сервиса. Для TargetLatest это максимальная версия
+// зарегистрированных миграций.
+func (s *ServiceInfo) serviceTargetVersion() models.Version {
+	if !s.targetLatest {
+		return s.TargetVersion
+	}
+
+	var latest models.Version
+	for _, migration := range s.registeredMigrations {
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			continue
+		}
+		if version.MoreThan(latest) {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// parseRunTargetVersion разбирает RunOptions.TargetVersion и проверяет, что версия соответствует зарегистрированной
+// миграции типа TypeVersioned или TypeBaseline.
+func (m *MigrationManager) parseRunTargetVersion(serviceName string, version string) (*models.Version, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	targetVersion, err := models.ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range service.registeredMigrations {
+		if migration.MigrationType == TypeRepeatable {
+			continue
+		}
+
+		migrationVersion, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return nil, err
+		}
+		if migrationVersion.Equals(targetVersion) {
+			return &targetVersion, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no versioned or baseline migration with version %s registered, service: %s", version, serviceName)
+}
+
+// checkRunTargetVersion проверяет, что целевая версия запуска не ниже сохраненной версии базы данных.
+func (m *MigrationManager) checkRunTargetVersion(serviceName string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	if service.runTargetVersion == nil {
+		return nil
+	}
+
+	savedVersion, err := m.getSavedAppVersion(serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if service.runTargetVersion.LessThan(savedVersion) {
+		return fmt.Errorf(
+			"%w: target version %s, saved version %s, service: %s",
+			ErrTargetBelowSavedVersion, service.runTargetVersion, savedVersion, serviceName,
+		)
+	}
+
+	return nil
+}
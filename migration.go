@@ -1,6 +1,8 @@
 package db_migrator
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -12,6 +14,19 @@ const (
 	TypeRepeatable MigrationType = "repeatable"
 )
 
+// FailurePolicy определяет поведение при ошибке выполнения миграции типа TypeRepeatable.
+type FailurePolicy string
+
+const (
+	// FailureAbort прерывает выполнение Migrate. Используется по умолчанию.
+	FailureAbort FailurePolicy = ""
+	// FailureWarnAndContinue сохраняет ошибку и продолжает выполнение Migrate.
+	FailureWarnAndContinue FailurePolicy = "warn"
+	// FailureWarnAndContinueUntilNConsecutive продолжает выполнение Migrate, пока количество последовательных ошибок
+	// не достигнет Migration.MaxConsecutiveFailures.
+	FailureWarnAndContinueUntilNConsecutive FailurePolicy = "warn until n consecutive"
+)
+
 type DbDependency struct {
 	Name    string
 	Version string
@@ -22,19 +37,107 @@ type Migration struct {
 	MigrationType MigrationType
 	Version       string
 	Description   string
+	// Ticket - идентификатор задачи, в рамках которой создана миграция.
+	Ticket string
 
 	IsTransactional bool
 	IsAllowFailure  bool
 
+	// Up - SQL миграции. Выражение, начинающееся с комментария "-- migrator:optional", необязательное: его ошибка
+	// записывается в last_error и не прерывает миграцию (см. RunOptional).
 	Up   string
 	Down string
 
+	// UpSource и DownSource - альтернатива Up и Down для больших миграций: текст читается только при выполнении
+	// миграции, а контрольная сумма вычисляется потоком. Несовместимы с Up/UpF и Down/DownF соответственно.
+	UpSource   MigrationSource
+	DownSource MigrationSource
+
+	// RenderTemplate - Up, Down, UpSource и DownSource являются шаблонами text/template и выполняются с данными
+	// WithTemplateData и WithServiceTemplateData до вычисления контрольной суммы. Ошибки шаблонов Up и Down
+	// возвращаются при регистрации. В шаблоне доступны функции quoteIdent и quoteLiteral.
+	RenderTemplate bool
+
+	// UpF может выполнять необязательные шаги через RunOptional.
 	UpF   func(selfDb *gorm.DB, depsDb map[string]*gorm.DB) error
 	DownF func(selfDb *gorm.DB, depsDb map[string]*gorm.DB) error
 
-	CheckSum            func(selfDb *gorm.DB) string
+	// UpDeps и DownDeps - альтернатива UpF и DownF, получающая соединения с зависимостями через DepConnections.
+	// Обращение к необъявленной зависимости возвращает ошибку вместо nil.
+	UpDeps   func(selfDb *gorm.DB, deps DepConnections) error
+	DownDeps func(selfDb *gorm.DB, deps DepConnections) error
+	// depsAdapted - UpF и DownF получены из UpDeps и DownDeps
+	depsAdapted bool
+
+	CheckSum func(selfDb *gorm.DB) string
+	// Content - содержимое миграции, заданной функцией UpF (например, текст выполняемых запросов или номер ревизии),
+	// по которому вычисляется контрольная сумма при отсутствии CheckSum. Позволяет обнаружить изменение выполненной
+	// миграции (см. ErrChecksumMismatch).
+	Content             string
 	Identifier          uint32
 	RepeatUnconditional bool
 
+	// MinVersion и MaxVersion ограничивают диапазон версий базы данных (включительно), в котором выполняется миграция
+	// типа TypeRepeatable. Пустое значение снимает ограничение.
+	MinVersion string
+	MaxVersion string
+
+	// OnFailure определяет поведение при ошибке выполнения миграции типа TypeRepeatable.
+	OnFailure              FailurePolicy
+	MaxConsecutiveFailures int
+
 	Dependency []DbDependency
+	// UsesAuxiliary - имена дополнительных подключений сервиса (см. WithAuxiliaryConnection), передаваемых в UpF и
+	// DownF в depsDb.
+	UsesAuxiliary []string
+
+	// ExpectRowsMin - минимальное количество строк, которое должна затронуть миграция. Если значение больше нуля и
+	// миграция затронула меньше строк, выполнение завершается ошибкой ErrRowsAffectedBelowExpected.
+	ExpectRowsMin int64
+
+	// DisableStatementSplitting - выполнять Up одним запросом, не разбивая на выражения. Количество затронутых
+	// строк в этом случае определяется драйвером (обычно по последнему выражению), а для нетранзакционной миграции
+	// прогресс выполнения не сохраняется и RunOptions.ResumeFromLastStatement не действует.
+	DisableStatementSplitting bool
+
+	// SessionOptions - параметры сессии gorm (например, PrepareStmt, Logger, AllowGlobalUpdate), применяемые к
+	// соединению, передаваемому в UpF и DownF, и к выполнению Up и Down. Соединение сервиса не изменяется. Для
+	// транзакционных миграций сессия создается поверх транзакции и использует ее соединение; параметры, влияющие на
+	// управление транзакциями (SkipDefaultTransaction, DisableNestedTransaction), в этом случае действуют только на
+	// вложенные вызовы внутри UpF и DownF. DryRun приводит к тому, что миграция не выполняется, но считается успешной.
+	SessionOptions *gorm.Session
+
+	// Estimate - оценка стоимости выполнения миграции (например, EstimateTableRewrite). Вызывается при планировании
+	// Migrate в откатываемой транзакции, результат выводится вместе с планом и доступен Profile.PlanGate.
+	Estimate func(db *gorm.DB) (EstimateReport, error)
+
+	// RequiredExtensions - расширения базы данных, необходимые миграции (например, "uuid-ossp", "pg_trgm"). Наличие
+	// расширений проверяется до выполнения плана, отсутствующие создаются (см. WithExtensionAutoCreate). Учитывается
+	// только для postgres.
+	RequiredExtensions []string
+
+	// SharedAcrossServices - миграция намеренно регистрируется для нескольких сервисов (например, общая миграция
+	// библиотеки) и не учитывается при проверке WithRegistrationOverlapCheck.
+	SharedAcrossServices bool
+
+	// Timeout ограничивает время выполнения Up или UpF. Контекст с этим ограничением передается в соединение, через
+	// которое выполняется миграция; по истечении времени миграция завершается ошибкой ErrMigrationTimeout. Если не
+	// задан, используется значение WithMigrationTimeout.
+	Timeout time.Duration
+}
+
+// timeoutOf возвращает ограничение времени выполнения миграции: Migration.Timeout или значение по умолчанию,
+// заданное WithMigrationTimeout.
+func (m *MigrationManager) timeoutOf(migration *Migration) time.Duration {
+	if migration.Timeout > 0 {
+		return migration.Timeout
+	}
+	return m.migrationTimeout
+}
+
+// checksum возвращает контрольную сумму миграции, сохраняемую после выполнения (см. expectedChecksum), или пустую
+// строку, если ее невозможно вычислить.
+func (m *Migration) checksum(db *gorm.DB) string {
+	checksum, _ := expectedChecksum(db, m)
+	return checksum
 }
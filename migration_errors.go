@@ -0,0 +1,76 @@
+package db_migrator
+
+import (
+	"fmt"
+)
+
+// DependencyError - ошибка проверки зависимости миграции от другого сервиса (см. Migration.Dependency). Причина
+// доступна через errors.Is: ErrDependencyNotFound, ErrDependencyNotConnected, ErrDependencyNotInitialized,
+// ErrDependencyNotMigrated или ErrDependencyNotSatisfied.
+type DependencyError struct {
+	// ServiceName - имя сервиса-зависимости.
+	ServiceName string
+	// RequiredVersion - версия зависимости, требуемая миграцией.
+	RequiredVersion string
+	// ActualVersion - сохраненная версия зависимости; пустая, если версию определить не удалось.
+	ActualVersion string
+	// Strict - требуется точное совпадение версии (см. DbDependency.Strict).
+	Strict bool
+
+	Err error
+}
+
+func (e *DependencyError) Error() string {
+	if len(e.ActualVersion) == 0 {
+		return fmt.Sprintf("%s: dependency %s, required version %s", e.Err, e.ServiceName, e.RequiredVersion)
+	}
+	return fmt.Sprintf(
+		"%s: dependency %s, required version %s, actual version %s",
+		e.Err, e.ServiceName, e.RequiredVersion, e.ActualVersion,
+	)
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// MigrationExecError - ошибка выполнения SQL миграции. Исходная ошибка драйвера доступна через errors.Unwrap и
+// errors.As.
+type MigrationExecError struct {
+	Version string
+	Type    string
+	// Statement - выполнявшееся выражение.
+	Statement string
+	// StatementIndex и StatementCount - номер выражения (начиная с 1) и количество выражений миграции; равны 0, если
+	// миграция выполнялась одним запросом.
+	StatementIndex int
+	StatementCount int
+
+	Err error
+}
+
+func (e *MigrationExecError) Error() string {
+	if e.StatementCount == 0 {
+		return fmt.Sprintf(
+			"migration (type: %s, Version: %s) failed (%s): %s", e.Type, e.Version, statementSnippet(e.Statement), e.Err,
+		)
+	}
+	return fmt.Sprintf(
+		"migration (type: %s, Version: %s) failed at statement %d of %d (%s): %s",
+		e.Type, e.Version, e.StatementIndex, e.StatementCount, statementSnippet(e.Statement), e.Err,
+	)
+}
+
+func (e *MigrationExecError) Unwrap() error {
+	return e.Err
+}
+
+func dependencyError(dependency DbDependency, actualVersion string, err error) *DependencyError {
+	return &DependencyError{
+		ServiceName:     dependency.Name,
+		RequiredVersion: dependency.Version,
+		ActualVersion:   actualVersion,
+		Strict:          dependency.Strict,
+		Err:             err,
+	}
+}
@@ -0,0 +1,136 @@
+package db_migrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationExecError(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Up:              "alter table a add column b text; alter table missing add column b text",
+		},
+	))
+
+	err := m.Migrate("service1")
+	var execErr *MigrationExecError
+	require.ErrorAs(t, err, &execErr)
+	require.Equal(t, "1.0.1", execErr.Version)
+	require.Equal(t, "versioned", execErr.Type)
+	require.Equal(t, "alter table missing add column b text", execErr.Statement)
+	require.Equal(t, 2, execErr.StatementIndex)
+	require.Equal(t, 2, execErr.StatementCount)
+	require.ErrorContains(t, execErr.Err, "no such table: missing")
+	require.Equal(t, execErr.Err, errors.Unwrap(execErr))
+	require.ErrorContains(t, err, "failed at statement 2 of 2")
+}
+
+func TestMigrationNotFoundError(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+
+	err := m.ApplyOne("service1", "1.0.5", TypeVersioned, false)
+	require.ErrorIs(t, err, ErrMigrationNotFound)
+
+	var execErr *MigrationExecError
+	require.False(t, errors.As(err, &execErr))
+}
+
+func TestDependencyErrors(t *testing.T) {
+	tests := map[string]struct {
+		dependency DbDependency
+		// migrateUsers - выполнить миграции сервиса users перед миграцией сервиса service1.
+		migrateUsers  bool
+		err           error
+		actualVersion string
+	}{
+		"not found": {
+			dependency: DbDependency{Name: "billing", Version: "1.0.0"},
+			err:        ErrDependencyNotFound,
+		},
+		"not initialized": {
+			dependency: DbDependency{Name: "users", Version: "1.0.0"},
+			err:        ErrDependencyNotInitialized,
+		},
+		"not satisfied": {
+			dependency:    DbDependency{Name: "users", Version: "1.0.1"},
+			migrateUsers:  true,
+			err:           ErrDependencyNotSatisfied,
+			actualVersion: "1.0.0.0",
+		},
+		"strict": {
+			dependency:    DbDependency{Name: "users", Version: "0.9.0", Strict: true},
+			migrateUsers:  true,
+			err:           ErrDependencyNotSatisfied,
+			actualVersion: "1.0.0.0",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.1")
+
+			usersConnect, usersDisconnect := newTestDatabase(t)
+			require.NoError(t, m.RegisterService("users", usersConnect, usersDisconnect, "1.0.0"))
+			require.NoError(t, m.Register("users",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table users(id int)"},
+			))
+			if test.migrateUsers {
+				require.NoError(t, m.Migrate("users"))
+			}
+
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				Migration{
+					MigrationType:   TypeVersioned,
+					Version:         "1.0.1",
+					IsTransactional: true,
+					Dependency:      []DbDependency{test.dependency},
+					Up:              "alter table a add column b text",
+				},
+			))
+
+			err := m.Migrate("service1")
+			require.ErrorIs(t, err, test.err)
+
+			var dependencyErr *DependencyError
+			require.ErrorAs(t, err, &dependencyErr)
+			require.Equal(t, test.dependency.Name, dependencyErr.ServiceName)
+			require.Equal(t, test.dependency.Version, dependencyErr.RequiredVersion)
+			require.Equal(t, test.actualVersion, dependencyErr.ActualVersion)
+			require.Equal(t, test.dependency.Strict, dependencyErr.Strict)
+		})
+	}
+}
+
+func TestRegisteredVersionTooLowError(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	// миграция из параллельной ветки с версией ниже уже сохраненной
+	other, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, other.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, other.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+
+	err = other.Migrate("service1")
+	require.ErrorIs(t, err, ErrRegisteredVersionTooLow)
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
@@ -0,0 +1,229 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrLossyConversion = errors.New("migration uses gorm-specific features and cannot be converted to MigrationLite")
+
+// MigrationLite описывает миграцию, функции которой работают с *sql.DB вместо *gorm.DB. Используется командами, не
+// использующими gorm в коде миграций.
+//
+// В отличие от Migration, не поддерживает зависимости от других сервисов. Миграции, заданные функциями UpF и DownF,
+// выполняются вне транзакции и получают *sql.DB. Транзакционные миграции задаются функциями UpTxF и DownTxF, которые
+// получают *sql.Tx транзакции, открытой мигратором: фиксация и откат выполняются мигратором, вызывать Commit и
+// Rollback в функциях нельзя.
+type MigrationLite struct {
+	MigrationType MigrationType
+	Version       string
+	Description   string
+	Ticket        string
+
+	IsTransactional bool
+	IsAllowFailure  bool
+
+	Up   string
+	Down string
+
+	UpF   func(db *sql.DB) error
+	DownF func(db *sql.DB) error
+
+	UpTxF   func(tx *sql.Tx) error
+	DownTxF func(tx *sql.Tx) error
+
+	CheckSum            func(db *sql.DB) string
+	Content             string
+	RepeatUnconditional bool
+
+	MinVersion string
+	MaxVersion string
+
+	OnFailure              FailurePolicy
+	MaxConsecutiveFailures int
+
+	DisableStatementSplitting bool
+
+	RequiredExtensions []string
+
+	SharedAcrossServices bool
+
+	Timeout time.Duration
+}
+
+// ToMigration преобразует MigrationLite в Migration. Функции UpF, DownF и CheckSum получают *sql.DB, извлеченный из
+// соединения сервиса, функции UpTxF и DownTxF - *sql.Tx транзакции миграции при выполнении и при откате.
+func ToMigration(lite MigrationLite) Migration {
+	migration := Migration{
+		MigrationType:       lite.MigrationType,
+		Version:             lite.Version,
+		Description:         lite.Description,
+		Ticket:              lite.Ticket,
+		IsTransactional:     lite.IsTransactional,
+		IsAllowFailure:      lite.IsAllowFailure,
+		Up:                  lite.Up,
+		Down:                lite.Down,
+		Content:             lite.Content,
+		RepeatUnconditional: lite.RepeatUnconditional,
+		MinVersion:          lite.MinVersion,
+		MaxVersion:          lite.MaxVersion,
+
+		OnFailure:              lite.OnFailure,
+		MaxConsecutiveFailures: lite.MaxConsecutiveFailures,
+
+		DisableStatementSplitting: lite.DisableStatementSplitting,
+
+		RequiredExtensions: lite.RequiredExtensions,
+
+		SharedAcrossServices: lite.SharedAcrossServices,
+
+		Timeout: lite.Timeout,
+	}
+
+	if lite.UpF != nil {
+		migration.UpF = func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+			db, err := selfDb.DB()
+			if err != nil {
+				return err
+			}
+			return lite.UpF(db)
+		}
+	}
+
+	if lite.DownF != nil {
+		migration.DownF = func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+			db, err := selfDb.DB()
+			if err != nil {
+				return err
+			}
+			return lite.DownF(db)
+		}
+	}
+
+	if lite.UpTxF != nil {
+		migration.UpF = func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+			tx, err := sqlTx(selfDb)
+			if err != nil {
+				return err
+			}
+			return lite.UpTxF(tx)
+		}
+	}
+
+	if lite.DownTxF != nil {
+		migration.DownF = func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+			tx, err := sqlTx(selfDb)
+			if err != nil {
+				return err
+			}
+			return lite.DownTxF(tx)
+		}
+	}
+
+	if lite.CheckSum != nil {
+		migration.CheckSum = func(selfDb *gorm.DB) string {
+			db, err := selfDb.DB()
+			if err != nil {
+				return ""
+			}
+			return lite.CheckSum(db)
+		}
+	}
+
+	return migration
+}
+
+// ToLite преобразует Migration в MigrationLite. Возвращает ErrLossyConversion, если миграция использует возможности,
+// доступные только при работе через gorm: зависимости, дополнительные подключения, функции UpF, DownF, CheckSum,
+// Estimate, параметры сессии или ожидаемое количество строк.
+func ToLite(m Migration) (MigrationLite, error) {
+	switch {
+	case len(m.Dependency) > 0:
+		return MigrationLite{}, fmt.Errorf("%w: Dependency is set, version: %s", ErrLossyConversion, m.Version)
+	case len(m.UsesAuxiliary) > 0:
+		return MigrationLite{}, fmt.Errorf("%w: UsesAuxiliary is set, version: %s", ErrLossyConversion, m.Version)
+	case m.UpF != nil || m.DownF != nil:
+		return MigrationLite{}, fmt.Errorf("%w: UpF or DownF is set, version: %s", ErrLossyConversion, m.Version)
+	case m.RenderTemplate:
+		return MigrationLite{}, fmt.Errorf("%w: RenderTemplate is set, version: %s", ErrLossyConversion, m.Version)
+	case m.UpSource != nil || m.DownSource != nil:
+		return MigrationLite{}, fmt.Errorf("%w: UpSource or DownSource is set, version: %s", ErrLossyConversion, m.Version)
+	case m.CheckSum != nil:
+		return MigrationLite{}, fmt.Errorf("%w: CheckSum is set, version: %s", ErrLossyConversion, m.Version)
+	case m.ExpectRowsMin > 0:
+		return MigrationLite{}, fmt.Errorf("%w: ExpectRowsMin is set, version: %s", ErrLossyConversion, m.Version)
+	case m.Estimate != nil:
+		return MigrationLite{}, fmt.Errorf("%w: Estimate is set, version: %s", ErrLossyConversion, m.Version)
+	case m.SessionOptions != nil:
+		return MigrationLite{}, fmt.Errorf("%w: SessionOptions is set, version: %s", ErrLossyConversion, m.Version)
+	}
+
+	return MigrationLite{
+		MigrationType:       m.MigrationType,
+		Version:             m.Version,
+		Description:         m.Description,
+		Ticket:              m.Ticket,
+		IsTransactional:     m.IsTransactional,
+		IsAllowFailure:      m.IsAllowFailure,
+		Up:                  m.Up,
+		Down:                m.Down,
+		Content:             m.Content,
+		RepeatUnconditional: m.RepeatUnconditional,
+		MinVersion:          m.MinVersion,
+		MaxVersion:          m.MaxVersion,
+
+		OnFailure:              m.OnFailure,
+		MaxConsecutiveFailures: m.MaxConsecutiveFailures,
+
+		DisableStatementSplitting: m.DisableStatementSplitting,
+
+		RequiredExtensions: m.RequiredExtensions,
+
+		SharedAcrossServices: m.SharedAcrossServices,
+
+		Timeout: m.Timeout,
+	}, nil
+}
+
+// RegisterLite сохраняет миграции MigrationLite в память. Миграции проходят ту же проверку, что и при вызове Register.
+// Транзакционные миграции задаются только через UpTxF и DownTxF, нетранзакционные - только через UpF и DownF; иное
+// сочетание является ошибкой использования (см. WithPanicOnMisuse).
+func (m *MigrationManager) RegisterLite(serviceName string, migrations ...MigrationLite) error {
+	converted := make([]Migration, 0, len(migrations))
+	for i := range migrations {
+		if migrations[i].IsTransactional && (migrations[i].UpF != nil || migrations[i].DownF != nil) {
+			return m.misuse(fmt.Errorf(
+				"transactional MigrationLite with UpF or DownF is not supported, use UpTxF and DownTxF, version: %s",
+				migrations[i].Version,
+			))
+		}
+		if !migrations[i].IsTransactional && (migrations[i].UpTxF != nil || migrations[i].DownTxF != nil) {
+			return m.misuse(fmt.Errorf(
+				"non-transactional MigrationLite with UpTxF or DownTxF is not supported, use UpF and DownF, version: %s",
+				migrations[i].Version,
+			))
+		}
+		converted = append(converted, ToMigration(migrations[i]))
+	}
+
+	return m.Register(serviceName, converted...)
+}
+
+// sqlTx возвращает *sql.Tx транзакции, в которой выполняется db. Возвращает ошибку, если db не находится в
+// транзакции.
+func sqlTx(db *gorm.DB) (*sql.Tx, error) {
+	connPool := db.Statement.ConnPool
+	if preparedTx, ok := connPool.(*gorm.PreparedStmtTX); ok {
+		connPool = preparedTx.Tx
+	}
+
+	tx, ok := connPool.(*sql.Tx)
+	if !ok || tx == nil {
+		return nil, fmt.Errorf("connection is not a *sql.Tx transaction: %T", connPool)
+	}
+	return tx, nil
+}
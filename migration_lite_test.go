@@ -0,0 +1,130 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMigrationLiteRoundTrip(t *testing.T) {
+	lite := MigrationLite{
+		MigrationType:             TypeRepeatable,
+		Version:                   "1.2.3",
+		Description:               "refresh view",
+		Ticket:                    "OPS-1",
+		IsTransactional:           true,
+		IsAllowFailure:            true,
+		Up:                        "create view v as select 1",
+		Down:                      "drop view v",
+		Content:                   "view v",
+		RepeatUnconditional:       true,
+		MinVersion:                "1.0.0",
+		MaxVersion:                "2.0.0",
+		OnFailure:                 FailureWarnAndContinueUntilNConsecutive,
+		MaxConsecutiveFailures:    3,
+		DisableStatementSplitting: true,
+		RequiredExtensions:        []string{"pgcrypto"},
+		SharedAcrossServices:      true,
+		Timeout:                   time.Minute,
+	}
+
+	converted, err := ToLite(ToMigration(lite))
+	require.NoError(t, err)
+	require.Equal(t, lite, converted)
+}
+
+func TestToLiteLossyConversion(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration Migration
+	}{
+		{name: "dependency", migration: Migration{Dependency: []DbDependency{{Name: "service2", Version: "1.0.0"}}}},
+		{name: "auxiliary", migration: Migration{UsesAuxiliary: []string{"reports"}}},
+		{name: "up function", migration: Migration{UpF: func(*gorm.DB, map[string]*gorm.DB) error { return nil }}},
+		{name: "checksum", migration: Migration{CheckSum: func(*gorm.DB) string { return "v1" }}},
+		{name: "template", migration: Migration{RenderTemplate: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.migration.MigrationType = TypeVersioned
+			test.migration.Version = "1.0.0"
+
+			_, err := ToLite(test.migration)
+			require.ErrorIs(t, err, ErrLossyConversion)
+		})
+	}
+}
+
+func TestRegisterLite(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+
+	var inTx, outsideTx bool
+	require.NoError(t, m.RegisterLite("service1",
+		MigrationLite{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, UpTxF: func(tx *sql.Tx) error {
+			inTx = true
+			_, err := tx.Exec("create table a(id int)")
+			return err
+		}},
+		MigrationLite{MigrationType: TypeVersioned, Version: "1.0.1", UpF: func(db *sql.DB) error {
+			outsideTx = true
+			_, err := db.Exec("alter table a add column b text")
+			return err
+		}},
+	))
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, inTx)
+	require.True(t, outsideTx)
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestRegisterLiteRejectsInvalid(t *testing.T) {
+	noop := func(*sql.DB) error { return nil }
+	noopTx := func(*sql.Tx) error { return nil }
+
+	tests := []struct {
+		name      string
+		migration MigrationLite
+		err       string
+	}{
+		{
+			name:      "transactional with UpF",
+			migration: MigrationLite{MigrationType: TypeVersioned, Version: "1.0.0", IsTransactional: true, UpF: noop},
+			err:       "use UpTxF and DownTxF",
+		},
+		{
+			name:      "non-transactional with UpTxF",
+			migration: MigrationLite{MigrationType: TypeVersioned, Version: "1.0.0", UpTxF: noopTx},
+			err:       "use UpF and DownF",
+		},
+		{
+			name:      "invalid version",
+			migration: MigrationLite{MigrationType: TypeVersioned, Version: "1.x", Up: "select 1"},
+		},
+		{
+			name:      "unknown type",
+			migration: MigrationLite{MigrationType: "seed", Version: "1.0.0", Up: "select 1"},
+			err:       "unknown migration type",
+		},
+		{
+			name:      "version range of versioned migration",
+			migration: MigrationLite{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 1", MinVersion: "1.0.0"},
+			err:       "allowed only for repeatable migrations",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.0")
+
+			err := m.RegisterLite("service1", test.migration)
+			require.Error(t, err)
+			require.ErrorContains(t, err, test.err)
+
+			plan, err := m.Plan("service1")
+			require.NoError(t, err)
+			require.Empty(t, plan, "invalid migration must not be registered")
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// raceMigration выполняет одну ожидающую миграцию двумя менеджерами одновременно и проверяет, что она выполнена
+// один раз: второй менеджер ожидает блокировку или завершается ErrLockNotAcquired или ErrMigrationLocked.
+func raceMigration(t *testing.T, register func(m *MigrationManager), table string) {
+	t.Helper()
+
+	migrations := []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: fmt.Sprintf("create table %s(id int)", table)},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+			if err := db.Exec(fmt.Sprintf("insert into %s values (1)", table)).Error; err != nil {
+				return err
+			}
+			// вторая попытка выполнения должна начаться, пока первая не завершена
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		}},
+	}
+
+	managers := make([]*MigrationManager, 2)
+	for i := range managers {
+		m, err := NewMigrationsManager(WithLockTimeout(5 * time.Second))
+		require.NoError(t, err)
+		register(m)
+		managers[i] = m
+	}
+
+	require.NoError(t, managers[0].Register("service1", migrations[0]))
+	require.NoError(t, managers[0].Migrate("service1"))
+	for _, m := range managers {
+		require.NoError(t, m.Register("service1", migrations[1]))
+	}
+
+	errs := make([]error, len(managers))
+	wg := sync.WaitGroup{}
+	for i, m := range managers {
+		wg.Add(1)
+		go func(i int, m *MigrationManager) {
+			defer wg.Done()
+			errs[i] = m.Migrate("service1")
+		}(i, m)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, ErrLockNotAcquired) || errors.Is(err, ErrMigrationLocked), "unexpected error: %v", err)
+	}
+	require.Positive(t, succeeded)
+
+	var count int
+	service, _ := managers[0].service("service1")
+	db := service.open()
+	defer service.DisconnectFunc(db)
+	require.NoError(t, db.Raw(fmt.Sprintf("select count(*) from %s", table)).Scan(&count).Error)
+	require.Equal(t, 1, count, "migration must be executed once")
+
+	for _, m := range managers {
+		reason, ok, err := m.CheckFulfillment("service1")
+		require.NoError(t, err)
+		require.True(t, ok, "%v", reason)
+	}
+}
+
+func TestMigrationRaceSqlite(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	raceMigration(t, func(m *MigrationManager) {
+		require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.1"))
+	}, "race")
+}
+
+// TestMigrationRacePostgres проверяет блокировку строки миграции FOR UPDATE NOWAIT в postgres.
+func TestMigrationRacePostgres(t *testing.T) {
+	driver, dsn := postgresTestDSN(t)
+
+	suffix := time.Now().UnixNano()
+	prefix := fmt.Sprintf("race%d_", suffix)
+	raceMigration(t, func(m *MigrationManager) {
+		require.NoError(t, m.RegisterServiceSQL("service1",
+			func() (*sql.DB, error) { return sql.Open(driver, dsn) },
+			func(db *sql.DB) { _ = db.Close() },
+			"1.0.1",
+			WithTablePrefix(prefix),
+		))
+	}, fmt.Sprintf("race%d", suffix))
+}
@@ -0,0 +1,106 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// callMisuse вызывает f и возвращает ошибку, возвращенную f или переданную в panic.
+func callMisuse(f func() error) (err error, panicked bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			panicked = true
+			var ok bool
+			if err, ok = recovered.(error); !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+		}
+	}()
+	return f(), false
+}
+
+func TestPanicOnMisuse(t *testing.T) {
+	tests := map[string]struct {
+		call func(m *MigrationManager) error
+		err  error
+		text string
+	}{
+		"invalid target version": {
+			call: func(m *MigrationManager) error {
+				connect, disconnect := newTestDatabase(t)
+				return m.AddService("service2", ServiceConfig{Connect: connect, Disconnect: disconnect, TargetVersion: "1.x"})
+			},
+			text: "1.x",
+		},
+		"invalid migration": {
+			call: func(m *MigrationManager) error {
+				return m.Register("service1", Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true})
+			},
+			text: "exactly one of Up, UpSource and UpF",
+		},
+		"duplicate migration": {
+			call: func(m *MigrationManager) error {
+				migration := Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "select 1"}
+				return m.Register("service1", migration, migration)
+			},
+			err: ErrDuplicateMigration,
+		},
+		"invalid migration file": {
+			call: func(m *MigrationManager) error {
+				return m.RegisterFS("service1", fstest.MapFS{"V1_0_1__a.sql": {Data: []byte("select 1")}}, ".")
+			},
+			text: "invalid migration file name",
+		},
+		"unknown service": {
+			call: func(m *MigrationManager) error {
+				_, err := m.Plan("unknown")
+				return err
+			},
+			err: ErrServiceNotFound,
+		},
+		"unknown service fulfillment": {
+			call: func(m *MigrationManager) error {
+				_, err := m.CheckFulfillmentContext(context.Background(), "unknown")
+				return err
+			},
+			err: ErrServiceNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			check := func(t *testing.T, err error) {
+				require.Error(t, err)
+				if test.err != nil {
+					require.ErrorIs(t, err, test.err)
+				}
+				require.ErrorContains(t, err, test.text)
+			}
+
+			m, _ := newTestManager(t, "1.0.1")
+			err, panicked := callMisuse(func() error { return test.call(m) })
+			require.False(t, panicked, "without WithPanicOnMisuse misuse must return an error")
+			check(t, err)
+
+			m, _ = newTestManager(t, "1.0.1", WithPanicOnMisuse())
+			err, panicked = callMisuse(func() error { return test.call(m) })
+			require.True(t, panicked, "with WithPanicOnMisuse misuse must panic")
+			check(t, err)
+		})
+	}
+
+	// ошибки выполнения миграций не считаются ошибками использования
+	t.Run("migration error", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.0", WithPanicOnMisuse())
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "alter table missing add column b text"},
+		))
+		err, panicked := callMisuse(func() error { return m.Migrate("service1") })
+		require.False(t, panicked)
+		require.ErrorContains(t, err, "no such table: missing")
+	})
+}
@@ -0,0 +1,44 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func olderBinaryMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	}
+}
+
+func TestOlderBinaryAgainstNewerDatabase(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+
+	newer, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, newer.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, newer.Register("service1", olderBinaryMigrations()...))
+	require.NoError(t, newer.Migrate("service1"))
+
+	// предыдущая версия приложения после отката не знает о миграции 1.0.2
+	older, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, older.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, older.Register("service1", olderBinaryMigrations()[:2]...))
+
+	report, err := older.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Empty(t, report.Entries)
+	require.Equal(t, 1, report.AheadOfBinary)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+
+	strict, err := NewMigrationsManager(WithForbidOlderBinary())
+	require.NoError(t, err)
+	require.NoError(t, strict.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, strict.Register("service1", olderBinaryMigrations()[:2]...))
+	require.ErrorIs(t, strict.Migrate("service1"), ErrDatabaseAheadOfBinary)
+}
@@ -0,0 +1,119 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// optionalStatementMarker - комментарий перед выражением SQL миграции, отмечающий выражение как необязательное:
+// ошибка его выполнения записывается в last_error и не прерывает миграцию. В транзакционной миграции выражение
+// выполняется внутри точки сохранения, поэтому ошибка не прерывает транзакцию. Маркер не действует при
+// Migration.DisableStatementSplitting.
+const optionalStatementMarker = "-- migrator:optional"
+
+type optionalStepsKey struct{}
+
+// optionalSteps накапливает ошибки необязательных шагов миграции (см. RunOptional).
+type optionalSteps struct {
+	logger        *slog.Logger
+	serviceName   string
+	migrationType string
+	version       string
+
+	mutex      sync.Mutex
+	savepoints int
+	errors     []string
+}
+
+func withOptionalSteps(db *gorm.DB, steps *optionalSteps) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, optionalStepsKey{}, steps))
+}
+
+func optionalStepsOf(db *gorm.DB) *optionalSteps {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return nil
+	}
+	steps, _ := db.Statement.Context.Value(optionalStepsKey{}).(*optionalSteps)
+	return steps
+}
+
+// savepoint возвращает имя новой точки сохранения, уникальное в пределах выполнения миграции.
+func (s *optionalSteps) savepoint() string {
+	if s == nil {
+		return "migrator_optional"
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.savepoints++
+	return fmt.Sprintf("migrator_optional_%d", s.savepoints)
+}
+
+// record сохраняет ошибку необязательного шага. Если шаг выполняется вне миграции (s равен nil), ошибку негде
+// сохранить, и она возвращается.
+func (s *optionalSteps) record(name string, err error) error {
+	if s == nil {
+		return fmt.Errorf("optional step %s failed outside of migration execution: %w", name, err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errors = append(s.errors, fmt.Sprintf("%s: %s", name, err))
+	s.logger.Warn(fmt.Sprintf(
+		"optional step %s of migration (type: %s, Version: %s) failed, continuing, service: %s, err: %s",
+		name, s.migrationType, s.version, s.serviceName, err,
+	))
+	return nil
+}
+
+// lastError возвращает ошибки необязательных шагов для сохранения в колонку last_error.
+func (s *optionalSteps) lastError() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return strings.Join(s.errors, "; ")
+}
+
+// RunOptional выполняет необязательный шаг миграции: ошибка step записывается в журнал и в колонку last_error
+// таблицы migrations и не прерывает миграцию. Если db находится в транзакции (UpF транзакционной миграции), шаг
+// выполняется внутри точки сохранения (SAVEPOINT), к которой транзакция откатывается при ошибке, поэтому транзакция
+// миграции продолжается. name используется в журнале и last_error.
+//
+// В качестве db необходимо передавать экземпляр, полученный в UpF (или производный от него): если db не связан с
+// выполняемой миграцией, ошибку шага негде сохранить, и RunOptional возвращает ее (после отката к точке сохранения).
+// Кроме того, возвращается ошибка создания точки сохранения или отката к ней.
+func RunOptional(db *gorm.DB, name string, step func(tx *gorm.DB) error) error {
+	steps := optionalStepsOf(db)
+
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); !inTransaction {
+		if err := step(db); err != nil {
+			return steps.record(name, err)
+		}
+		return nil
+	}
+
+	savepoint := steps.savepoint()
+	if err := db.SavePoint(savepoint).Error; err != nil {
+		return fmt.Errorf("create savepoint for optional step %s: %w", name, err)
+	}
+
+	if err := step(db); err != nil {
+		if rollbackErr := db.RollbackTo(savepoint).Error; rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback optional step %s: %w", name, rollbackErr))
+		}
+		return steps.record(name, err)
+	}
+	return nil
+}
+
+// isOptionalStatement возвращает true, если выражение отмечено optionalStatementMarker.
+func isOptionalStatement(statement string) bool {
+	return strings.HasPrefix(strings.TrimSpace(statement), optionalStatementMarker)
+}
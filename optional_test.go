@@ -0,0 +1,84 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRunOptional(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.3")
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+				err := db.Exec("insert into a(id) values (1)").Error
+				if err != nil {
+					return err
+				}
+				err = RunOptional(db, "grant", func(tx *gorm.DB) error {
+					err := tx.Exec("insert into a(id) values (100)").Error
+					if err != nil {
+						return err
+					}
+					return tx.Exec("insert into missing(id) values (1)").Error
+				})
+				if err != nil {
+					return err
+				}
+				return db.Exec("insert into a(id) values (2)").Error
+			},
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			Up: `insert into a(id) values (3);
+-- migrator:optional
+insert into missing(id) values (1);
+insert into a(id) values (4);`,
+		},
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.3",
+			Up: `insert into a(id) values (5);
+-- migrator:optional
+insert into missing(id) values (1);
+insert into a(id) values (6);`,
+		},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+
+	var ids []int
+	require.NoError(t, db.Raw("select id from a order by id").Scan(&ids).Error)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, ids)
+
+	var rows []struct {
+		Version   string
+		LastError string
+	}
+	require.NoError(t, db.Raw("select version, last_error from migrations where type = ? order by version", "versioned").Scan(&rows).Error)
+	require.Len(t, rows, 3)
+	require.Contains(t, rows[0].LastError, "grant: no such table: missing")
+	require.Contains(t, rows[1].LastError, "statement 2 (-- migrator:optional insert into missing(id) values (1)): no such table: missing")
+	require.Contains(t, rows[2].LastError, "statement 2 (-- migrator:optional insert into missing(id) values (1)): no such table: missing")
+
+	err := RunOptional(db, "untracked", func(tx *gorm.DB) error {
+		return tx.Exec("insert into missing(id) values (1)").Error
+	})
+	require.ErrorContains(t, err, "optional step untracked failed outside of migration execution")
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return RunOptional(tx, "untracked", func(tx *gorm.DB) error {
+			return tx.Exec("insert into missing(id) values (1)").Error
+		})
+	})
+	require.ErrorContains(t, err, "optional step untracked failed outside of migration execution")
+}
@@ -0,0 +1,49 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutOfOrderMigration(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2", WithAllowOutOfOrder()))
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	// миграция из параллельной ветки с версией ниже сохраненной, первая попытка завершается ошибкой
+	require.NoError(t, m.AllowLateRegistrations("service1"))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+	))
+	require.Error(t, m.Migrate("service1"))
+
+	_, err = m.Repair("service1")
+	require.NoError(t, err)
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.ErrorIs(t, reason, ErrHasForthcomingMigrations)
+
+	service, _ := m.service("service1")
+	service.registeredMigrations[len(service.registeredMigrations)-1].Up = "alter table a add column b text"
+
+	require.NoError(t, m.Migrate("service1"))
+
+	reason, ok, err = m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", status.Version)
+}
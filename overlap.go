@@ -0,0 +1,109 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrRegistrationOverlap = errors.New("services share too many identical migrations")
+
+// RegistrationOverlap описывает пару сервисов с совпадающими миграциями.
+type RegistrationOverlap struct {
+	Services [2]string
+	// Versions - версии миграций, совпадающих по версии и тексту Up
+	Versions []string
+	// Percent - доля совпадающих миграций от количества сравниваемых миграций меньшего из сервисов
+	Percent int
+}
+
+// RegistrationOverlaps возвращает пары сервисов, доля совпадающих миграций (одинаковые тип, версия и Up) которых
+// превышает порог, заданный WithRegistrationOverlapCheck. Большая доля совпадений обычно означает, что набор
+// миграций одного сервиса по ошибке зарегистрирован для другого. Миграции с SharedAcrossServices и миграции, заданные
+// функцией UpF, не сравниваются. Если проверка не включена, возвращает nil.
+func (m *MigrationManager) RegistrationOverlaps() []RegistrationOverlap {
+	return m.registrationOverlaps()
+}
+
+func (m *MigrationManager) registrationOverlaps() []RegistrationOverlap {
+	if m.overlapThreshold <= 0 {
+		return nil
+	}
+
+	services := m.servicesSnapshot()
+
+	serviceNames := make([]string, 0, len(services))
+	keys := make(map[string]map[string]string, len(services))
+	for name, service := range services {
+		serviceKeys := make(map[string]string)
+		service.mutex.Lock()
+		for _, migration := range service.registeredMigrations {
+			if migration.SharedAcrossServices || !migration.hasUpSQL() {
+				continue
+			}
+			checksum, err := migration.upChecksum()
+			if err != nil {
+				continue
+			}
+			key := string(migration.MigrationType) + ":" + migration.Version + ":" + checksum
+			serviceKeys[key] = migration.Version
+		}
+		service.mutex.Unlock()
+		if len(serviceKeys) == 0 {
+			continue
+		}
+		serviceNames = append(serviceNames, name)
+		keys[name] = serviceKeys
+	}
+	sort.Strings(serviceNames)
+
+	overlaps := make([]RegistrationOverlap, 0)
+	for i := range serviceNames {
+		for j := i + 1; j < len(serviceNames); j++ {
+			a, b := keys[serviceNames[i]], keys[serviceNames[j]]
+
+			versions := make([]string, 0)
+			for key, version := range a {
+				if _, ok := b[key]; ok {
+					versions = append(versions, version)
+				}
+			}
+
+			percent := len(versions) * 100 / min(len(a), len(b))
+			if percent <= m.overlapThreshold {
+				continue
+			}
+
+			sort.Strings(versions)
+			overlaps = append(overlaps, RegistrationOverlap{
+				Services: [2]string{serviceNames[i], serviceNames[j]},
+				Versions: versions,
+				Percent:  percent,
+			})
+		}
+	}
+
+	return overlaps
+}
+
+// checkRegistrationOverlaps выводит предупреждение о совпадающих наборах миграций сервисов или, если профиль политик
+// запрещает совпадения (Profile.ForbidRegistrationOverlap), возвращает ErrRegistrationOverlap.
+func (m *MigrationManager) checkRegistrationOverlaps() error {
+	errs := make([]error, 0)
+	for _, overlap := range m.registrationOverlaps() {
+		message := fmt.Sprintf(
+			"services %s and %s share %d%% of migrations (%s), check that migrations are registered for the right "+
+				"service or mark intentionally shared migrations with SharedAcrossServices",
+			overlap.Services[0], overlap.Services[1], overlap.Percent, strings.Join(overlap.Versions, ", "),
+		)
+
+		if m.profile.ForbidRegistrationOverlap {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrRegistrationOverlap, message))
+			continue
+		}
+		m.logger.Warn(message)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,151 @@
+package db_migrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// overlapMigrations возвращает миграции 1.0.0 - 1.0.3 сервиса, первые shared из которых совпадают у всех сервисов.
+func overlapMigrations(serviceName string, shared int) []Migration {
+	migrations := make([]Migration, 0, 4)
+	for i := 0; i < 4; i++ {
+		up := fmt.Sprintf("create table t%d(id int)", i)
+		if i >= shared {
+			up = fmt.Sprintf("create table %s_t%d(id int)", serviceName, i)
+		}
+		migrations = append(migrations, Migration{
+			MigrationType:   TypeVersioned,
+			Version:         fmt.Sprintf("1.0.%d", i),
+			IsTransactional: true,
+			Up:              up,
+		})
+	}
+	return migrations
+}
+
+func TestRegistrationOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		// shared - количество совпадающих миграций из четырех
+		shared    int
+		threshold int
+		// sharedAcrossServices - совпадающие миграции помечены SharedAcrossServices
+		sharedAcrossServices bool
+		want                 []RegistrationOverlap
+	}{
+		{
+			name:      "overlapping",
+			shared:    4,
+			threshold: 50,
+			want: []RegistrationOverlap{
+				{Services: [2]string{"a", "b"}, Versions: []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3"}, Percent: 100},
+			},
+		},
+		{
+			name:      "above threshold",
+			shared:    3,
+			threshold: 50,
+			want: []RegistrationOverlap{
+				{Services: [2]string{"a", "b"}, Versions: []string{"1.0.0", "1.0.1", "1.0.2"}, Percent: 75},
+			},
+		},
+		{
+			name:      "touching threshold",
+			shared:    2,
+			threshold: 50,
+			want:      []RegistrationOverlap{},
+		},
+		{
+			name:      "disjoint",
+			shared:    0,
+			threshold: 50,
+			want:      []RegistrationOverlap{},
+		},
+		{
+			name:                 "shared across services",
+			shared:               4,
+			threshold:            50,
+			sharedAcrossServices: true,
+			want:                 []RegistrationOverlap{},
+		},
+		{
+			name:   "check disabled",
+			shared: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMigrationsManager(WithRegistrationOverlapCheck(tt.threshold))
+			require.NoError(t, err)
+
+			for _, serviceName := range []string{"b", "a"} {
+				require.NoError(t, m.RegisterService(serviceName, func() *gorm.DB { return nil }, func(*gorm.DB) {}, "1.0.3"))
+				migrations := overlapMigrations(serviceName, tt.shared)
+				for i := 0; i < tt.shared; i++ {
+					migrations[i].SharedAcrossServices = tt.sharedAcrossServices
+				}
+				require.NoError(t, m.Register(serviceName, migrations...))
+			}
+
+			require.Equal(t, tt.want, m.RegistrationOverlaps())
+		})
+	}
+}
+
+func TestMigrateAllRegistrationOverlap(t *testing.T) {
+	tests := []struct {
+		name   string
+		shared int
+		forbid bool
+		// wantErr - MigrateAll прерывается ErrRegistrationOverlap
+		wantErr bool
+		// wantWarning - выводится предупреждение о совпадении
+		wantWarning bool
+	}{
+		{name: "overlapping", shared: 4, wantWarning: true},
+		{name: "overlapping forbidden", shared: 4, forbid: true, wantErr: true},
+		{name: "touching forbidden", shared: 2, forbid: true},
+		{name: "disjoint forbidden", shared: 0, forbid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			m, err := NewMigrationsManager(
+				WithRegistrationOverlapCheck(50),
+				WithPolicyProfile(NewProfile(Profile{}, ForbidRegistrationOverlap(tt.forbid))),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+			require.NoError(t, err)
+
+			connects := make(map[string]func() *gorm.DB)
+			for _, serviceName := range []string{"a", "b"} {
+				connect, disconnect := newTestDatabase(t)
+				connects[serviceName] = connect
+				require.NoError(t, m.RegisterService(serviceName, connect, disconnect, "1.0.3"))
+				require.NoError(t, m.Register(serviceName, overlapMigrations(serviceName, tt.shared)...))
+			}
+
+			results, err := m.MigrateAll(context.Background(), MigrateAllOptions{})
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrRegistrationOverlap)
+				require.ErrorContains(t, err, "services a and b share 100% of migrations")
+				require.Nil(t, results)
+				require.False(t, connects["a"]().Migrator().HasTable("t0"))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, map[string]error{"a": nil, "b": nil}, results)
+			require.True(t, connects["a"]().Migrator().HasTable("t3") || connects["a"]().Migrator().HasTable("a_t3"))
+			require.Equal(t, tt.wantWarning, bytes.Contains(logs.Bytes(), []byte("services a and b share")))
+		})
+	}
+}
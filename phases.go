@@ -0,0 +1,121 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PhaseTimings - длительность этапов запуска Migrate.
+type PhaseTimings struct {
+	// Connect - подключение к базе данных и проверка версии библиотеки
+	Connect time.Duration `json:"connect"`
+	// InitTables - создание и обновление системных таблиц, проверки направления запуска и дрейфа схемы
+	InitTables time.Duration `json:"init_tables"`
+	// SaveRegistrations - сохранение новых зарегистрированных миграций
+	SaveRegistrations time.Duration `json:"save_registrations"`
+	// Plan - построение и проверка плана, включая вычисление контрольных сумм и Migration.Estimate
+	Plan time.Duration `json:"plan"`
+	// Checksums - длительность вычисления контрольных сумм миграций типа TypeRepeatable по версиям, входит в Plan
+	Checksums map[string]time.Duration `json:"checksums,omitempty"`
+	// Execute - выполнение миграций плана. Длительность отдельных миграций приводится в MigrationReport.Entries
+	Execute time.Duration `json:"execute"`
+	// Cleanup - закрытие соединений. Не сохраняется в таблицу migration_runs, так как запись о запуске сохраняется
+	// до закрытия соединений
+	Cleanup time.Duration `json:"cleanup"`
+}
+
+// exceeds определяет, превышает ли длительность какого-либо этапа threshold.
+func (p PhaseTimings) exceeds(threshold time.Duration) bool {
+	for _, phase := range []time.Duration{p.Connect, p.InitTables, p.SaveRegistrations, p.Plan, p.Execute, p.Cleanup} {
+		if phase > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// String возвращает длительность этапов одной строкой.
+func (p PhaseTimings) String() string {
+	result := fmt.Sprintf(
+		"connect: %s, init tables: %s, save registrations: %s, plan: %s",
+		p.Connect, p.InitTables, p.SaveRegistrations, p.Plan,
+	)
+
+	if len(p.Checksums) > 0 {
+		versions := make([]string, 0, len(p.Checksums))
+		for version := range p.Checksums {
+			versions = append(versions, version)
+		}
+		sort.Strings(versions)
+
+		checksums := make([]string, 0, len(versions))
+		for _, version := range versions {
+			checksums = append(checksums, fmt.Sprintf("%s=%s", version, p.Checksums[version]))
+		}
+		result += fmt.Sprintf(" (checksums: %s)", strings.Join(checksums, ", "))
+	}
+
+	return result + fmt.Sprintf(", execute: %s, cleanup: %s", p.Execute, p.Cleanup)
+}
+
+// phaseTimer измеряет длительность последовательных этапов запуска.
+type phaseTimer struct {
+	phase     *time.Duration
+	startedAt time.Time
+}
+
+// start завершает измерение текущего этапа и начинает измерение этапа phase.
+func (t *phaseTimer) start(phase *time.Duration) {
+	t.stop()
+	t.phase = phase
+	t.startedAt = time.Now()
+}
+
+// stop добавляет к текущему этапу время, прошедшее с начала его измерения.
+func (t *phaseTimer) stop() {
+	if t.phase == nil {
+		return
+	}
+	*t.phase += time.Since(t.startedAt)
+	t.phase = nil
+}
+
+// logSlowPhases выводит длительность этапов запуска, если какой-либо из них превысил порог WithSlowPhaseThreshold.
+func (m *MigrationManager) logSlowPhases(serviceName string, phases PhaseTimings) {
+	if m.slowPhaseThreshold <= 0 || !phases.exceeds(m.slowPhaseThreshold) {
+		return
+	}
+	m.logger.Info(fmt.Sprintf("slow migration run, service: %s, %s", serviceName, phases))
+}
+
+func encodePhaseTimings(phases PhaseTimings) string {
+	encoded, _ := json.Marshal(phases)
+	return string(encoded)
+}
+
+func decodePhaseTimings(encoded string) *PhaseTimings {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	phases := PhaseTimings{}
+	if err := json.Unmarshal([]byte(encoded), &phases); err != nil {
+		return nil
+	}
+	return &phases
+}
+
+// recordChecksumTiming сохраняет длительность вычисления контрольной суммы миграции версии version, если выполняется
+// запуск Migrate.
+func (s *ServiceInfo) recordChecksumTiming(version string, duration time.Duration) {
+	if s.runPhases == nil {
+		return
+	}
+	if s.runPhases.Checksums == nil {
+		s.runPhases.Checksums = make(map[string]time.Duration)
+	}
+	s.runPhases.Checksums[version] += duration
+}
@@ -0,0 +1,71 @@
+package db_migrator
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPhaseTimings(t *testing.T) {
+	const checksumDelay = 20 * time.Millisecond
+
+	var logs bytes.Buffer
+	m, _ := newTestManager(t, "1.0.1",
+		WithSlowPhaseThreshold(checksumDelay/2),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{
+			MigrationType:   TypeRepeatable,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			// намеренно медленное вычисление контрольной суммы
+			CheckSum: func(*gorm.DB) string {
+				time.Sleep(checksumDelay)
+				return "v1"
+			},
+			Up: "create view v as select id from a",
+		},
+	))
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+
+	phases := report.Phases
+	require.GreaterOrEqual(t, phases.Checksums["1.0.1"], checksumDelay)
+	require.GreaterOrEqual(t, phases.Plan, phases.Checksums["1.0.1"])
+	require.Positive(t, phases.Connect)
+	require.Positive(t, phases.InitTables)
+	require.Positive(t, phases.Execute)
+	require.Positive(t, phases.Cleanup)
+
+	require.Contains(t, phases.String(), "(checksums: 1.0.1=")
+	require.Contains(t, logs.String(), "slow migration run, service: service1, connect: ")
+
+	// сохраненная запись о запуске содержит длительность этапов, кроме Cleanup
+	runs, err := m.Runs("service1", 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.NotNil(t, runs[0].Phases)
+	require.Equal(t, phases.Plan, runs[0].Phases.Plan)
+	require.Equal(t, phases.Checksums, runs[0].Phases.Checksums)
+	require.Zero(t, runs[0].Phases.Cleanup)
+}
+
+func TestPhaseTimingsExceeds(t *testing.T) {
+	phases := PhaseTimings{Connect: time.Millisecond, Plan: 3 * time.Millisecond, Checksums: map[string]time.Duration{
+		"1.0.1": time.Hour,
+	}}
+
+	require.True(t, phases.exceeds(2*time.Millisecond))
+	require.False(t, phases.exceeds(3*time.Millisecond))
+	// длительность контрольных сумм входит в Plan и не сравнивается отдельно
+	require.False(t, phases.exceeds(time.Minute))
+}
@@ -0,0 +1,318 @@
+package db_migrator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// planDescriptionWidth - максимальная длина описания миграции в FormatPlan.
+const planDescriptionWidth = 60
+
+// PlannedMigration описывает миграцию, входящую в план выполнения или отката.
+type PlannedMigration struct {
+	MigrationType MigrationType
+	Version       string
+	Description   string
+	State         string
+	// HasDown - для миграции задан Down, DownSource или DownF.
+	HasDown bool
+	// Irreversible - миграция не может быть отменена (не зарегистрирована или не задан Down и DownF).
+	Irreversible bool
+	// ResultingVersion - версия базы данных после выполнения или отката миграции.
+	ResultingVersion string
+	// NonTransactional - миграция выполняется вне транзакции.
+	NonTransactional bool
+	// AllowFailure - ошибка выполнения миграции не прерывает выполнение плана.
+	AllowFailure bool
+	// Estimate - оценка стоимости выполнения миграции, если задан Migration.Estimate.
+	Estimate *EstimateReport
+	// GoFunc - миграция выполняется функцией (UpF или DownF в зависимости от направления), а не SQL.
+	GoFunc bool
+}
+
+// FormatPlan возвращает человекочитаемое представление плана: по одной строке на миграцию со стрелкой направления
+// (DirectionUp или DirectionDown), версией, типом, описанием и флагами [non-tx], [allow-fail], [irreversible].
+func FormatPlan(direction string, migrations []PlannedMigration) string {
+	arrow := "↑"
+	if direction == DirectionDown {
+		arrow = "↓"
+	}
+
+	var b strings.Builder
+	for i, migration := range migrations {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+
+		fmt.Fprintf(&b, "%s %-12s %-10s %s", arrow, migration.Version, migration.MigrationType, truncateDescription(migration.Description))
+
+		if migration.NonTransactional {
+			b.WriteString(" [non-tx]")
+		}
+		if migration.AllowFailure {
+			b.WriteString(" [allow-fail]")
+		}
+		if direction == DirectionDown && migration.Irreversible {
+			b.WriteString(" [irreversible]")
+		}
+		if migration.Estimate != nil {
+			fmt.Fprintf(&b, " (%s)", migration.Estimate)
+		}
+	}
+	return b.String()
+}
+
+func truncateDescription(description string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if utf8.RuneCountInString(description) <= planDescriptionWidth {
+		return description
+	}
+	return string([]rune(description)[:planDescriptionWidth-3]) + "..."
+}
+
+// plannedMigrations преобразует план в список PlannedMigration. ResultingVersion не заполняется. Оценка стоимости
+// выполнения вычисляется только для direction = DirectionUp.
+func (m *MigrationManager) plannedMigrations(serviceName string, plan migrationsPlan, direction string) ([]PlannedMigration, error) {
+	plannedMigrations := make([]PlannedMigration, 0, plan.Len())
+	for _, migrationModel := range plan.Migrations() {
+		plannedMigration, err := m.plannedMigration(serviceName, migrationModel, direction)
+		if err != nil {
+			return nil, err
+		}
+		plannedMigrations = append(plannedMigrations, plannedMigration)
+	}
+	return plannedMigrations, nil
+}
+
+func (m *MigrationManager) plannedMigration(
+	serviceName string,
+	migrationModel models.MigrationModel,
+	direction string,
+) (PlannedMigration, error) {
+	migration, found, err := m.findMigration(serviceName, migrationModel)
+	if err != nil {
+		return PlannedMigration{}, err
+	}
+
+	plannedMigration := PlannedMigration{
+		MigrationType: MigrationType(migrationModel.Type),
+		Version:       migrationModel.Version.String(),
+		Description:   migrationModel.Description,
+		State:         string(migrationModel.State),
+	}
+
+	if found {
+		plannedMigration.HasDown = migration.hasDown()
+		plannedMigration.NonTransactional = !migration.IsTransactional
+		plannedMigration.AllowFailure = migration.IsAllowFailure || migration.OnFailure != FailureAbort
+		plannedMigration.GoFunc = direction == DirectionUp && migration.UpF != nil ||
+			direction == DirectionDown && migration.DownF != nil
+
+		if direction == DirectionUp && migration.Estimate != nil {
+			service, _ := m.service(serviceName)
+			plannedMigration.Estimate, err = estimate(service.Db, migration)
+			if err != nil {
+				return PlannedMigration{}, err
+			}
+		}
+	}
+	plannedMigration.Irreversible = !plannedMigration.HasDown
+
+	return plannedMigration, nil
+}
+
+// logPlan выводит план в лог перед выполнением, если это не отключено опцией WithPlanLogging.
+func (m *MigrationManager) logPlan(serviceName string, direction string, migrations []PlannedMigration) {
+	if !m.planLogging || len(migrations) == 0 {
+		return
+	}
+	m.logger.Info(fmt.Sprintf("execution plan for service %s:\n%s", serviceName, FormatPlan(direction, migrations)))
+}
+
+// Plan возвращает упорядоченный список миграций, которые будут выполнены при вызове Migrate: миграцию TypeBaseline,
+// если она требуется, невыполненные миграции TypeVersioned и миграции TypeRepeatable, контрольная сумма которых
+// изменилась. Метод не изменяет базу данных: новые зарегистрированные миграции учитываются без сохранения, системные
+// таблицы не создаются.
+func (m *MigrationManager) Plan(serviceName string) ([]PlannedMigration, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return nil, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := m.previewMigratePlan(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	plannedMigrations, err := m.plannedMigrations(serviceName, plan, DirectionUp)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultingVersion models.Version
+	if repository.HasVersionTable(service.Db) {
+		resultingVersion, _ = m.getSavedAppVersion(serviceName)
+	}
+	for i, migrationModel := range plan.Migrations() {
+		if migrationModel.Type != string(TypeRepeatable) {
+			resultingVersion = migrationModel.Version
+		}
+		plannedMigrations[i].ResultingVersion = resultingVersion.String()
+	}
+
+	return plannedMigrations, nil
+}
+
+// previewMigratePlan составляет план выполнения Migrate, не изменяя базу данных: новые зарегистрированные миграции
+// учитываются без сохранения.
+func (m *MigrationManager) previewMigratePlan(serviceName string) (migrationsPlan, error) {
+	savedMigrations, err := m.previewPlanningMigrations(serviceName)
+	if err != nil {
+		return migrationsPlan{}, err
+	}
+
+	return m.planMigrate(serviceName, savedMigrations)
+}
+
+// previewPlanningMigrations возвращает сохраненные миграции, необходимые планировщику (см. planningMigrations),
+// вместе с новыми зарегистрированными миграциями, которые были бы сохранены Migrate, не изменяя базу данных.
+func (m *MigrationManager) previewPlanningMigrations(serviceName string) ([]models.MigrationModel, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	var err error
+	keys := make([]models.MigrationModel, 0)
+	savedMigrations := make([]models.MigrationModel, 0)
+	maxRank := 0
+	if repository.HasMigrationsTable(service.Db) {
+		keys, err = repository.GetMigrationKeys(service.Db)
+		if err != nil {
+			return nil, err
+		}
+
+		maxRank, err = repository.GetMaxRank(service.Db)
+		if err != nil {
+			return nil, err
+		}
+
+		savedMigrations, err = m.planningMigrations(serviceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newMigrations, err := m.newMigrations(serviceName, keys, maxRank)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(savedMigrations, repository.NewMigrationModels(newMigrations)...), nil
+}
+
+// PlanDowngrade возвращает упорядоченный список миграций, которые будут отменены при вызове Downgrade. Для каждой
+// миграции указывается наличие Down или DownF и версия базы данных после ее отмены: ResultingVersion последней
+// миграции - версия, на которой окажется база данных. Метод не изменяет базу данных, в том числе не сохраняет новые
+// зарегистрированные миграции.
+func (m *MigrationManager) PlanDowngrade(serviceName string) ([]PlannedMigration, error) {
+	return m.planDowngradePreview(serviceName, RunOptions{})
+}
+
+// PlanDowngradeTo возвращает план отката DowngradeTo с версией version (см. PlanDowngrade).
+func (m *MigrationManager) PlanDowngradeTo(serviceName string, version string) ([]PlannedMigration, error) {
+	return m.planDowngradePreview(serviceName, RunOptions{TargetVersion: version})
+}
+
+// PlanDowngradeSteps возвращает план отката DowngradeSteps с количеством steps (см. PlanDowngrade). Как и
+// DowngradeSteps, возвращает ошибку, если выполненных миграций меньше steps.
+func (m *MigrationManager) PlanDowngradeSteps(serviceName string, steps int) ([]PlannedMigration, error) {
+	if steps <= 0 {
+		return nil, m.misuse(fmt.Errorf("downgrade steps must be positive, got %d", steps))
+	}
+	return m.planDowngradePreview(serviceName, RunOptions{Steps: steps})
+}
+
+// planDowngradePreview составляет план отката с параметрами opts.TargetVersion и opts.Steps тем же путем, что и
+// DowngradeContext, не изменяя базу данных.
+func (m *MigrationManager) planDowngradePreview(serviceName string, opts RunOptions) ([]PlannedMigration, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	if opts.Steps > 0 && len(opts.TargetVersion) > 0 {
+		return nil, m.misuse(fmt.Errorf("downgrade steps and target version are mutually exclusive"))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if len(opts.TargetVersion) > 0 {
+		var err error
+		service.runTargetVersion, err = m.parseRunTargetVersion(serviceName, opts.TargetVersion)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			service.runTargetVersion = nil
+		}()
+	}
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	if !repository.HasVersionTable(service.Db) || !repository.HasMigrationsTable(service.Db) {
+		return []PlannedMigration{}, nil
+	}
+
+	savedMigrations, err := m.downgradeMigrations(serviceName, downgradeBoundary(service, opts.Steps))
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := m.planDowngrade(serviceName, savedMigrations, opts.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	plannedMigrations, err := m.plannedMigrations(serviceName, plan, DirectionDown)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migrationModel := range plan.Migrations() {
+		plannedMigrations[i].ResultingVersion = previousVersion(migrationModel, savedMigrations).String()
+	}
+
+	return plannedMigrations, nil
+}
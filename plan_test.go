@@ -0,0 +1,135 @@
+package db_migrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		direction  string
+		migrations []PlannedMigration
+		want       string
+	}{
+		{
+			name:      "empty plan",
+			direction: DirectionUp,
+			want:      "",
+		},
+		{
+			name:      "up with flags and estimate",
+			direction: DirectionUp,
+			migrations: []PlannedMigration{
+				{MigrationType: TypeBaseline, Version: "1.0.0.0", Description: "init"},
+				{
+					MigrationType:    TypeVersioned,
+					Version:          "1.0.1.0",
+					Description:      "add  index\non\tusers",
+					NonTransactional: true,
+					Irreversible:     true,
+					Estimate:         &EstimateReport{EstimatedRows: 1200, TableBytes: 2048, Warning: "table rewrite"},
+				},
+				{MigrationType: TypeRepeatable, Version: "1.0.2.0", Description: "views", AllowFailure: true},
+			},
+			want: "↑ 1.0.0.0      baseline   init\n" +
+				"↑ 1.0.1.0      versioned  add index on users [non-tx] (~1200 rows, 2.0 KiB, table rewrite)\n" +
+				"↑ 1.0.2.0      repeatable views [allow-fail]",
+		},
+		{
+			name:      "down marks irreversible migrations",
+			direction: DirectionDown,
+			migrations: []PlannedMigration{
+				{MigrationType: TypeVersioned, Version: "1.0.2.0", Description: "drop column", Irreversible: true},
+				{MigrationType: TypeVersioned, Version: "1.0.1.0", Description: "add column", HasDown: true},
+			},
+			want: "↓ 1.0.2.0      versioned  drop column [irreversible]\n" +
+				"↓ 1.0.1.0      versioned  add column",
+		},
+		{
+			name:      "long description is truncated",
+			direction: DirectionUp,
+			migrations: []PlannedMigration{
+				{MigrationType: TypeVersioned, Version: "1.0.1.0", Description: strings.Repeat("я", 70)},
+			},
+			want: "↑ 1.0.1.0      versioned  " + strings.Repeat("я", 57) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, FormatPlan(tt.direction, tt.migrations))
+		})
+	}
+}
+
+// TestPlanDowngradeMatchesDowngrade проверяет, что PlanDowngradeTo и PlanDowngradeSteps не изменяют базу данных и
+// возвращают ровно те миграции, которые затем отменяет Downgrade, и версию, на которой оказывается база данных.
+func TestPlanDowngradeMatchesDowngrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		plan      func(m *MigrationManager) ([]PlannedMigration, error)
+		downgrade func(m *MigrationManager) error
+		want      []string
+		landing   string
+	}{
+		{
+			name:      "to version",
+			plan:      func(m *MigrationManager) ([]PlannedMigration, error) { return m.PlanDowngradeTo("service1", "1.0.1") },
+			downgrade: func(m *MigrationManager) error { return m.DowngradeTo("service1", "1.0.1") },
+			want:      []string{"1.0.3.0", "1.0.2.0"},
+			landing:   "1.0.1.0",
+		},
+		{
+			name:      "steps at target version",
+			plan:      func(m *MigrationManager) ([]PlannedMigration, error) { return m.PlanDowngradeSteps("service1", 1) },
+			downgrade: func(m *MigrationManager) error { return m.DowngradeSteps("service1", 1) },
+			want:      []string{"1.0.3.0"},
+			landing:   "1.0.2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, connect := newTestManager(t, "1.0.3")
+			require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+			require.NoError(t, m.Migrate("service1"))
+
+			planned, err := tt.plan(m)
+			require.NoError(t, err)
+			versions := make([]string, 0, len(planned))
+			for _, migration := range planned {
+				require.True(t, migration.HasDown)
+				versions = append(versions, migration.Version)
+			}
+			require.Equal(t, tt.want, versions)
+			require.Equal(t, tt.landing, planned[len(planned)-1].ResultingVersion)
+			require.True(t, connect().Migrator().HasTable("d"), "preview must not change the database")
+
+			require.NoError(t, tt.downgrade(m))
+
+			status, err := m.Status("service1")
+			require.NoError(t, err)
+			undone := make([]string, 0)
+			for i := len(status.Migrations) - 1; i >= 0; i-- {
+				if status.Migrations[i].State == string(models.StateUndone) {
+					undone = append(undone, status.Migrations[i].Version)
+				}
+			}
+			require.Equal(t, tt.want, undone)
+			require.Equal(t, tt.landing, status.Version)
+		})
+	}
+}
+
+func TestPlanDowngradeStepsMoreThanApplied(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.3")
+	require.NoError(t, m.Register("service1", downgradeTestMigrations("drop table d")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	_, err := m.PlanDowngradeSteps("service1", 4)
+	require.ErrorContains(t, err, "has only 3 applied versioned migrations")
+}
@@ -2,14 +2,30 @@ package db_migrator
 
 import (
 	"container/list"
+	"errors"
 	"fmt"
 	"github.com/Maksumys/db-migrator/internal/models"
-	"gorm.io/gorm"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"log/slog"
 	"sort"
+	"time"
 )
 
 type migrationsPlan struct {
 	migrationsToRun *list.List
+	// skipped - миграции, исключенные из плана с указанием причины
+	skipped []skippedMigration
+}
+
+type skippedMigration struct {
+	migrationModel models.MigrationModel
+	code           ReasonCode
+	reason         string
+}
+
+// historyReason возвращает причину пропуска для истории состояний миграции.
+func (s skippedMigration) historyReason() string {
+	return fmt.Sprintf("skipped (%s): %s", s.code, s.reason)
 }
 
 func newMigrationsPlan() migrationsPlan {
@@ -22,54 +38,251 @@ func (p migrationsPlan) IsEmpty() bool {
 	return p.migrationsToRun.Len() == 0
 }
 
+func (p migrationsPlan) Len() int {
+	return p.migrationsToRun.Len()
+}
+
+// Migrations возвращает запланированные миграции в порядке выполнения, не изменяя план.
+func (p migrationsPlan) Migrations() []models.MigrationModel {
+	migrations := make([]models.MigrationModel, 0, p.migrationsToRun.Len())
+	for e := p.migrationsToRun.Front(); e != nil; e = e.Next() {
+		migrations = append(migrations, e.Value.(models.MigrationModel))
+	}
+	return migrations
+}
+
 func (p migrationsPlan) PopFirst() models.MigrationModel {
 	first := p.migrationsToRun.Front()
 	p.migrationsToRun.Remove(first)
 	return first.Value.(models.MigrationModel)
 }
 
-type migratePlanner struct {
-	manager         *MigrationManager
+// planInputs - входные данные планирования, собранные менеджером до построения плана. Планировщики не обращаются к
+// базе данных и состоянию менеджера, что позволяет проверять их без подключения к базе данных.
+type planInputs struct {
+	// savedMigrations - сохраненные миграции. Планировщики не изменяют срез.
 	savedMigrations []models.MigrationModel
+	// savedVersion - сохраненная версия базы данных, нулевая, если версия еще не сохранялась.
+	savedVersion  models.Version
+	targetVersion models.Version
+	// registered - зарегистрированные миграции по идентификатору f(версия, тип миграции).
+	registered map[uint32]*Migration
+	// checksums - текущие контрольные суммы зарегистрированных миграций по идентификатору.
+	checksums map[uint32]string
+	logger    *slog.Logger
+}
 
-	plannedBaseline   models.MigrationModel
-	baselineIsPlanned bool
+// findMigration возвращает зарегистрированную миграцию, соответствующую сохраненной.
+func (in planInputs) findMigration(migrationModel models.MigrationModel) (*Migration, bool) {
+	migration, ok := in.registered[getMigrationIdentifier(migrationModel.Version, migrationModel.Type)]
+	return migration, ok
 }
 
-func (p *migratePlanner) MakePlan(serviceName string) (migrationsPlan, error) {
-	plan := newMigrationsPlan()
-	p.planMigrationsBaseline(serviceName, &plan)
+// sortedMigrations возвращает копию сохраненных миграций, упорядоченную функцией less.
+func (in planInputs) sortedMigrations(less func(a, b models.MigrationModel) bool) []models.MigrationModel {
+	sorted := make([]models.MigrationModel, len(in.savedMigrations))
+	copy(sorted, in.savedMigrations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
 
-	err := p.planMigrationsVersioned(serviceName, &plan)
+// planInputs собирает входные данные планирования для сервиса: сохраненную версию, зарегистрированные миграции и
+// их текущие контрольные суммы.
+func (m *MigrationManager) planInputs(serviceName string, savedMigrations []models.MigrationModel) (planInputs, error) {
+	service, ok := m.service(serviceName)
 
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return planInputs{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	var savedVersion models.Version
+	if repository.HasVersionTable(service.Db) {
+		var err error
+		savedVersion, err = m.getSavedAppVersion(serviceName)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return planInputs{}, err
+		}
+	}
+
+	registered := make(map[uint32]*Migration, len(service.registeredMigrations))
+	checksums := make(map[uint32]string, len(service.registeredMigrations))
+	for _, migration := range service.registeredMigrations {
+		migrationVersion, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return planInputs{}, err
+		}
+
+		identifier := getMigrationIdentifier(migrationVersion, string(migration.MigrationType))
+		registered[identifier] = migration
+		if migration.MigrationType == TypeRepeatable {
+			startedAt := time.Now()
+			checksums[identifier] = migration.checksum(service.Db)
+			service.recordChecksumTiming(migration.Version, time.Since(startedAt))
+		}
+	}
+
+	return planInputs{
+		savedMigrations: savedMigrations,
+		savedVersion:    savedVersion,
+		targetVersion:   service.targetVersion(),
+		registered:      registered,
+		checksums:       checksums,
+		logger:          m.logger,
+	}, nil
+}
+
+// planningMigrations возвращает сохраненные миграции, необходимые migratePlanner, с расшифрованными значениями:
+// миграции с версией не ниже сохраненной, все миграции типов TypeBaseline и TypeRepeatable и невыполненные миграции,
+// зарегистрированные вне очереди. Остальные миграции ниже сохраненной версии планировщик пропускает, поэтому их
+// строки не читаются; полную историю возвращает getSavedMigrations.
+func (m *MigrationManager) planningMigrations(serviceName string) ([]models.MigrationModel, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	var savedVersion models.Version
+	if repository.HasVersionTable(service.Db) {
+		var err error
+		savedVersion, err = m.getSavedAppVersion(serviceName)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	migrations, err := repository.GetMigrationsAbove(service.Db, savedVersion)
 	if err != nil {
-		return plan, err
+		return nil, err
+	}
+
+	// при нулевой сохраненной версии прочитаны все миграции
+	if savedVersion.MoreThan(models.Version{}) {
+		baselines, err := repository.GetMigrationsOfType(service.Db, string(TypeBaseline))
+		if err != nil {
+			return nil, err
+		}
+
+		repeatables, err := repository.GetRepeatables(service.Db)
+		if err != nil {
+			return nil, err
+		}
+
+		outOfOrder, err := repository.GetPendingOutOfOrder(service.Db)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = mergeMigrations(migrations, baselines, repeatables, outOfOrder)
 	}
 
-	err = p.planMigrationsRepeatable(serviceName, &plan)
+	return m.decryptMigrations(migrations)
+}
+
+// downgradeMigrations возвращает сохраненные миграции, необходимые downgradePlanner и previousVersion при отмене
+// миграций выше версии targetVersion, с расшифрованными значениями: миграции типов TypeVersioned и TypeBaseline,
+// начиная с ближайшей к targetVersion версии не выше нее.
+func (m *MigrationManager) downgradeMigrations(serviceName string, targetVersion models.Version) ([]models.MigrationModel, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
 
+	keys, err := repository.GetMigrationKeys(service.Db)
 	if err != nil {
-		return plan, err
+		return nil, err
 	}
 
-	return plan, nil
+	// версия, сохраняемая после отмены самой ранней из отменяемых миграций
+	var lowerBound models.Version
+	for i := range keys {
+		if keys[i].Type == string(TypeRepeatable) {
+			continue
+		}
+		if keys[i].Version.LessOrEqual(targetVersion) && keys[i].Version.MoreThan(lowerBound) {
+			lowerBound = keys[i].Version
+		}
+	}
+
+	ids := make([]uint32, 0)
+	for i := range keys {
+		if keys[i].Type != string(TypeRepeatable) && keys[i].Version.MoreOrEqual(lowerBound) {
+			ids = append(ids, keys[i].Id)
+		}
+	}
+
+	migrations, err := repository.GetMigrationsByIds(service.Db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decryptMigrations(migrations)
 }
 
-func (p *migratePlanner) planMigrationsBaseline(serviceName string, plan *migrationsPlan) {
-	if !p.baselineRequired() {
-		return
+// mergeMigrations объединяет выборки сохраненных миграций без повторов, упорядочивая результат по rank.
+func mergeMigrations(selections ...[]models.MigrationModel) []models.MigrationModel {
+	seen := make(map[uint32]struct{})
+	merged := make([]models.MigrationModel, 0)
+	for _, selection := range selections {
+		for _, migrationModel := range selection {
+			if _, ok := seen[migrationModel.Id]; ok {
+				continue
+			}
+			seen[migrationModel.Id] = struct{}{}
+			merged = append(merged, migrationModel)
+		}
 	}
-	p.manager.logger.Warn("no successful baseline migrations found, planning to execute latest available")
 
-	relevantBaseline, ok, err := p.findRelevantBaseline(serviceName)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Rank < merged[j].Rank
+	})
+	return merged
+}
+
+type migratePlanner struct {
+	inputs planInputs
+
+	plannedBaseline   models.MigrationModel
+	baselineIsPlanned bool
+}
+
+func (p *migratePlanner) MakePlan() (migrationsPlan, error) {
+	plan := newMigrationsPlan()
+
+	// миграция TypeBaseline выбирается в порядке сохранения, остальные миграции планируются в порядке версий
+	p.planMigrationsBaseline(p.inputs.savedMigrations, &plan)
+
+	savedMigrations := p.inputs.sortedMigrations(func(a, b models.MigrationModel) bool {
+		return b.Version.MoreThan(a.Version)
+	})
+
+	p.planMigrationsVersioned(savedMigrations, &plan)
+
+	err := p.planMigrationsRepeatable(savedMigrations, &plan)
 
 	if err != nil {
-		p.manager.logger.Error(err.Error())
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+func (p *migratePlanner) planMigrationsBaseline(savedMigrations []models.MigrationModel, plan *migrationsPlan) {
+	if !baselineRequired(savedMigrations) {
 		return
 	}
+	p.inputs.logger.Warn("no successful baseline migrations found, planning to execute latest available")
+
+	relevantBaseline, ok := p.findRelevantBaseline(savedMigrations)
 
 	if !ok {
-		p.manager.logger.Error("no relevant baseline migrations for current target Version found")
+		p.inputs.logger.Error("no relevant baseline migrations for current target Version found")
 		return
 	}
 
@@ -79,18 +292,8 @@ func (p *migratePlanner) planMigrationsBaseline(serviceName string, plan *migrat
 	p.plannedBaseline = relevantBaseline
 }
 
-func (p *migratePlanner) planMigrationsVersioned(serviceName string, plan *migrationsPlan) error {
-	service, ok := p.manager.services[serviceName]
-
-	if !ok {
-		return fmt.Errorf("fail to get service")
-	}
-
-	sort.SliceStable(p.savedMigrations, func(i, j int) bool {
-		return p.savedMigrations[j].Version.MoreThan(p.savedMigrations[i].Version)
-	})
-
-	for _, migrationModel := range p.savedMigrations {
+func (p *migratePlanner) planMigrationsVersioned(savedMigrations []models.MigrationModel, plan *migrationsPlan) {
+	for _, migrationModel := range savedMigrations {
 		if migrationModel.Type != string(TypeVersioned) {
 			continue
 		}
@@ -100,14 +303,17 @@ func (p *migratePlanner) planMigrationsVersioned(serviceName string, plan *migra
 		if migrationModel.State == models.StateSkipped {
 			continue
 		}
-
-		if migrationModel.Version.MoreThan(service.TargetVersion) {
+		if migrationModel.State == models.StateAbandoned {
 			continue
 		}
 
-		version, _ := p.manager.getSavedAppVersion(serviceName)
+		if migrationModel.Version.MoreThan(p.inputs.targetVersion) {
+			continue
+		}
 
-		if migrationModel.Version.LessOrEqual(version) {
+		// миграции, зарегистрированные вне очереди (см. WithAllowOutOfOrder), выполняются несмотря на более высокую
+		// сохраненную версию
+		if migrationModel.Version.LessOrEqual(p.inputs.savedVersion) && !migrationModel.OutOfOrder {
 			continue
 		}
 
@@ -119,46 +325,44 @@ func (p *migratePlanner) planMigrationsVersioned(serviceName string, plan *migra
 
 		plan.migrationsToRun.PushBack(migrationModel)
 	}
-
-	return nil
 }
 
-func (p *migratePlanner) planMigrationsRepeatable(serviceName string, plan *migrationsPlan) error {
-	service, ok := p.manager.services[serviceName]
-
-	if !ok {
-		return fmt.Errorf("fail to get service")
-	}
-
-	sort.SliceStable(p.savedMigrations, func(i, j int) bool {
-		return p.savedMigrations[j].Version.MoreThan(p.savedMigrations[i].Version)
-	})
-
-	for _, migrationModel := range p.savedMigrations {
+func (p *migratePlanner) planMigrationsRepeatable(savedMigrations []models.MigrationModel, plan *migrationsPlan) error {
+	for _, migrationModel := range savedMigrations {
 		if migrationModel.Type != string(TypeRepeatable) {
 			continue
 		}
-
-		migration, ok, err := p.manager.findMigration(serviceName, migrationModel)
-
-		if err != nil {
-			return err
+		if migrationModel.State == models.StateAbandoned {
+			continue
 		}
 
+		migration, ok := p.inputs.findMigration(migrationModel)
+
 		if !ok {
 			// добавляем в очередь, чтобы при выполнении проставить необходимые статусы
 			plan.migrationsToRun.PushBack(migrationModel)
 			continue
 		}
 
-		if migration.CheckSum == nil {
-			migration.CheckSum = func(db *gorm.DB) string {
-				return ""
-			}
+		inRange, code, reason, err := p.repeatableInVersionRange(plan, migration)
+		if err != nil {
+			return err
+		}
+
+		if !inRange {
+			p.inputs.logger.Info(
+				fmt.Sprintf(
+					"migration (type: %s, Version: %s) skipped (%s): %s",
+					migrationModel.Type, migrationModel.Version, code, reason,
+				),
+			)
+			plan.skipped = append(plan.skipped, skippedMigration{migrationModel: migrationModel, code: code, reason: reason})
+			continue
 		}
 
-		if !migration.RepeatUnconditional && migrationModel.Checksum == migration.CheckSum(service.Db) {
-			p.manager.logger.Info(
+		checksum := p.inputs.checksums[migration.Identifier]
+		if !repeatableNeedsRun(migrationModel, migration, checksum) {
+			p.inputs.logger.Info(
 				fmt.Sprintf(
 					"migration (type: %s, Version: %s, checksum: %s) checksum not changed, skipping",
 					migrationModel.Type, migrationModel.Version, migrationModel.Checksum,
@@ -173,73 +377,113 @@ func (p *migratePlanner) planMigrationsRepeatable(serviceName string, plan *migr
 	return nil
 }
 
-func (p *migratePlanner) baselineRequired() bool {
-	for _, migration := range p.savedMigrations {
-		if migration.Type == string(TypeBaseline) && migration.State == models.StateSuccess {
-			return false
+// repeatableNeedsRun определяет, требуется ли повторное выполнение миграции типа TypeRepeatable: миграция выполняется
+// безусловно, предыдущее выполнение завершилось допустимой ошибкой или изменилась контрольная сумма checksum.
+func repeatableNeedsRun(migrationModel models.MigrationModel, migration *Migration, checksum string) bool {
+	if migration.RepeatUnconditional || migrationModel.State == models.StateFailedAllowed {
+		return true
+	}
+	return migrationModel.Checksum != checksum
+}
+
+// repeatableInVersionRange проверяет, что версия базы данных после выполнения запланированных миграций находится в
+// диапазоне Migration.MinVersion - Migration.MaxVersion (включительно).
+func (p *migratePlanner) repeatableInVersionRange(plan *migrationsPlan, migration *Migration) (bool, ReasonCode, string, error) {
+	version := p.inputs.savedVersion
+	for _, planned := range plan.Migrations() {
+		if planned.Type != string(TypeRepeatable) && planned.Version.MoreThan(version) {
+			version = planned.Version
 		}
 	}
-	return true
+
+	return repeatableInVersionRange(version, migration)
 }
 
-func (p *migratePlanner) findRelevantBaseline(serviceName string) (models.MigrationModel, bool, error) {
-	service, ok := p.manager.services[serviceName]
+// repeatableInVersionRange проверяет, что версия базы данных version находится в диапазоне Migration.MinVersion -
+// Migration.MaxVersion (включительно). Если версия вне диапазона, возвращаются код и описание причины пропуска.
+func repeatableInVersionRange(version models.Version, migration *Migration) (bool, ReasonCode, string, error) {
+	if len(migration.MinVersion) > 0 {
+		minVersion, err := models.ParseVersion(migration.MinVersion)
+		if err != nil {
+			return false, ReasonNone, "", err
+		}
+		if version.LessThan(minVersion) {
+			return false, ReasonBelowMinVersion, fmt.Sprintf("database version %s is lower than min version %s", version, minVersion), nil
+		}
+	}
 
-	if !ok {
-		return models.MigrationModel{}, false, fmt.Errorf("fail to get service")
+	if len(migration.MaxVersion) > 0 {
+		maxVersion, err := models.ParseVersion(migration.MaxVersion)
+		if err != nil {
+			return false, ReasonNone, "", err
+		}
+		if version.MoreThan(maxVersion) {
+			return false, ReasonAboveMaxVersion, fmt.Sprintf("database version %s is higher than max version %s", version, maxVersion), nil
+		}
 	}
 
+	return true, ReasonNone, "", nil
+}
+
+func baselineRequired(savedMigrations []models.MigrationModel) bool {
+	for _, migration := range savedMigrations {
+		if migration.Type == string(TypeBaseline) && migration.State == models.StateSuccess {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *migratePlanner) findRelevantBaseline(savedMigrations []models.MigrationModel) (models.MigrationModel, bool) {
 	var latestBaselineMigration models.MigrationModel
 	var latestBaselineMigrationFound bool
 
-	for _, migrationModel := range p.savedMigrations {
+	for _, migrationModel := range savedMigrations {
 		if migrationModel.Type != string(TypeBaseline) {
 			continue
 		}
 
-		if migrationModel.Version.LessOrEqual(service.TargetVersion) {
+		if migrationModel.Version.LessOrEqual(p.inputs.targetVersion) {
 			latestBaselineMigration = migrationModel
 			latestBaselineMigrationFound = true
 		}
 	}
 
-	return latestBaselineMigration, latestBaselineMigrationFound, nil
+	return latestBaselineMigration, latestBaselineMigrationFound
 }
 
 type downgradePlanner struct {
-	manager         *MigrationManager
-	savedMigrations []models.MigrationModel
+	inputs planInputs
+	// steps - максимальное количество отменяемых миграций, 0 - без ограничения
+	steps int
 }
 
-func (p *downgradePlanner) MakePlan(serviceName string) (migrationsPlan, error) {
+func (p *downgradePlanner) MakePlan() (migrationsPlan, error) {
 	plan := newMigrationsPlan()
 
-	service, ok := p.manager.services[serviceName]
-
-	if !ok {
-		return migrationsPlan{}, fmt.Errorf("fail to get service")
-	}
-
-	sort.SliceStable(p.savedMigrations, func(i, j int) bool {
-		return p.savedMigrations[i].Version.MoreThan(p.savedMigrations[j].Version)
+	savedMigrations := p.inputs.sortedMigrations(func(a, b models.MigrationModel) bool {
+		return a.Version.MoreThan(b.Version)
 	})
 
-	for _, migrationModel := range p.savedMigrations {
+	for _, migrationModel := range savedMigrations {
 		if migrationModel.Type != string(TypeVersioned) {
 			continue
 		}
 
-		version, _ := p.manager.getSavedAppVersion(serviceName)
-
-		if migrationModel.Version.MoreThan(version) {
+		if migrationModel.Version.MoreThan(p.inputs.savedVersion) {
 			continue
 		}
-		if migrationModel.Version.LessOrEqual(service.TargetVersion) {
+		if migrationModel.Version.LessOrEqual(p.inputs.targetVersion) {
 			continue
 		}
-		if migrationModel.State == models.StateUndone {
+		// отменяются только выполненные миграции: миграции в остальных состояниях (не выполненные, завершившиеся
+		// ошибкой, пропущенные, отмененные) не учитываются и в steps
+		if migrationModel.State != models.StateSuccess {
 			continue
 		}
+		if p.steps > 0 && plan.Len() == p.steps {
+			break
+		}
 
 		plan.migrationsToRun.PushBack(migrationModel)
 	}
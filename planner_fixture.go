@@ -0,0 +1,250 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+// plannerFixtureFormat - версия формата PlannerFixture, увеличивается при несовместимых изменениях.
+const plannerFixtureFormat = 1
+
+// PlannerFixture - входные данные планировщика Migrate, достаточные для воспроизведения плана без базы данных и кода
+// миграций (см. RecordPlannerFixture). Текст миграций не сохраняется, только хеши содержимого.
+type PlannerFixture struct {
+	Format         int                        `json:"format"`
+	Service        string                     `json:"service"`
+	LibraryVersion string                     `json:"library_version"`
+	SavedVersion   string                     `json:"saved_version"`
+	TargetVersion  string                     `json:"target_version"`
+	Saved          []PlannerFixtureSaved      `json:"saved"`
+	Registered     []PlannerFixtureRegistered `json:"registered"`
+}
+
+// PlannerFixtureSaved - сохраненная миграция, в том числе еще не сохраненная новая миграция в состоянии registered.
+type PlannerFixtureSaved struct {
+	Type           MigrationType `json:"type"`
+	Version        string        `json:"version"`
+	State          string        `json:"state"`
+	Checksum       string        `json:"checksum,omitempty"`
+	Rank           int           `json:"rank"`
+	FailedAttempts int           `json:"failed_attempts,omitempty"`
+	OutOfOrder     bool          `json:"out_of_order,omitempty"`
+}
+
+// PlannerFixtureRegistered - зарегистрированная миграция.
+type PlannerFixtureRegistered struct {
+	Type MigrationType `json:"type"`
+	// Version - версия в каноническом виде
+	Version string `json:"version"`
+	// ContentHash - хеш содержимого миграции (см. ListFingerprint)
+	ContentHash string `json:"content_hash"`
+	// Checksum - текущая контрольная сумма миграции типа TypeRepeatable
+	Checksum            string `json:"checksum,omitempty"`
+	RepeatUnconditional bool   `json:"repeat_unconditional,omitempty"`
+	MinVersion          string `json:"min_version,omitempty"`
+	MaxVersion          string `json:"max_version,omitempty"`
+}
+
+// PlanDecision - решение планировщика по отдельной миграции.
+type PlanDecision struct {
+	Type    MigrationType `json:"type"`
+	Version string        `json:"version"`
+	State   string        `json:"state"`
+	// Reason и Message - причина исключения миграции из плана, только для PlanDecisions.Skipped
+	Reason  ReasonCode `json:"reason,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// PlanDecisions - результат планирования: миграции в порядке выполнения и миграции, исключенные из плана с указанием
+// причины.
+type PlanDecisions struct {
+	Run     []PlanDecision `json:"run"`
+	Skipped []PlanDecision `json:"skipped"`
+}
+
+// RecordPlannerFixture записывает в w входные данные планировщика Migrate сервиса в формате JSON: сохраненные
+// миграции, читаемые для планирования (выполненные миграции типа TypeVersioned ниже сохраненной версии не читаются),
+// сохраненную и целевую версии, зарегистрированные миграции и их контрольные суммы. Результат не зависит от времени
+// записи и порядка регистрации миграций и может быть приложен к сообщению об ошибке планирования для воспроизведения
+// через ReplayPlannerFixture. Метод не изменяет базу данных.
+func (m *MigrationManager) RecordPlannerFixture(serviceName string, w io.Writer) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.previewPlanningMigrations(serviceName)
+	if err != nil {
+		return err
+	}
+
+	inputs, err := m.planInputs(serviceName, savedMigrations)
+	if err != nil {
+		return err
+	}
+
+	fixture := PlannerFixture{
+		Format:         plannerFixtureFormat,
+		Service:        serviceName,
+		LibraryVersion: libraryVersion,
+		SavedVersion:   inputs.savedVersion.String(),
+		TargetVersion:  inputs.targetVersion.String(),
+		Saved:          make([]PlannerFixtureSaved, 0, len(inputs.savedMigrations)),
+		Registered:     make([]PlannerFixtureRegistered, 0, len(inputs.registered)),
+	}
+
+	for _, migrationModel := range inputs.savedMigrations {
+		fixture.Saved = append(fixture.Saved, PlannerFixtureSaved{
+			Type:           MigrationType(migrationModel.Type),
+			Version:        migrationModel.Version.String(),
+			State:          string(migrationModel.State),
+			Checksum:       migrationModel.Checksum,
+			Rank:           migrationModel.Rank,
+			FailedAttempts: migrationModel.FailedAttempts,
+			OutOfOrder:     migrationModel.OutOfOrder,
+		})
+	}
+
+	for identifier, migration := range inputs.registered {
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return err
+		}
+
+		fixture.Registered = append(fixture.Registered, PlannerFixtureRegistered{
+			Type:                migration.MigrationType,
+			Version:             version.String(),
+			ContentHash:         migrationContentHash(version, migration),
+			Checksum:            inputs.checksums[identifier],
+			RepeatUnconditional: migration.RepeatUnconditional,
+			MinVersion:          migration.MinVersion,
+			MaxVersion:          migration.MaxVersion,
+		})
+	}
+
+	sort.SliceStable(fixture.Saved, func(i, j int) bool {
+		return fixture.Saved[i].Rank < fixture.Saved[j].Rank
+	})
+	sort.Slice(fixture.Registered, func(i, j int) bool {
+		a, _ := models.ParseVersion(fixture.Registered[i].Version)
+		b, _ := models.ParseVersion(fixture.Registered[j].Version)
+		if !a.Equals(b) {
+			return a.LessThan(b)
+		}
+		return fixture.Registered[i].Type < fixture.Registered[j].Type
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fixture)
+}
+
+// ReplayPlannerFixture строит план Migrate по входным данным, записанным RecordPlannerFixture, без подключения к базе
+// данных.
+func ReplayPlannerFixture(r io.Reader) (PlanDecisions, error) {
+	var fixture PlannerFixture
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		return PlanDecisions{}, err
+	}
+	if fixture.Format != plannerFixtureFormat {
+		return PlanDecisions{}, fmt.Errorf("unsupported planner fixture format: %d", fixture.Format)
+	}
+
+	inputs := planInputs{
+		savedMigrations: make([]models.MigrationModel, 0, len(fixture.Saved)),
+		registered:      make(map[uint32]*Migration, len(fixture.Registered)),
+		checksums:       make(map[uint32]string, len(fixture.Registered)),
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	var err error
+	if len(fixture.SavedVersion) > 0 {
+		if inputs.savedVersion, err = models.ParseVersion(fixture.SavedVersion); err != nil {
+			return PlanDecisions{}, err
+		}
+	}
+	if inputs.targetVersion, err = models.ParseVersion(fixture.TargetVersion); err != nil {
+		return PlanDecisions{}, err
+	}
+
+	for _, saved := range fixture.Saved {
+		version, err := models.ParseVersion(saved.Version)
+		if err != nil {
+			return PlanDecisions{}, err
+		}
+
+		inputs.savedMigrations = append(inputs.savedMigrations, models.MigrationModel{
+			Id:             getMigrationIdentifier(version, string(saved.Type)),
+			Rank:           saved.Rank,
+			Type:           string(saved.Type),
+			Version:        version,
+			Checksum:       saved.Checksum,
+			State:          models.MigrationState(saved.State),
+			FailedAttempts: saved.FailedAttempts,
+			OutOfOrder:     saved.OutOfOrder,
+		})
+	}
+
+	for _, registered := range fixture.Registered {
+		version, err := models.ParseVersion(registered.Version)
+		if err != nil {
+			return PlanDecisions{}, err
+		}
+
+		identifier := getMigrationIdentifier(version, string(registered.Type))
+		inputs.registered[identifier] = &Migration{
+			MigrationType:       registered.Type,
+			Version:             registered.Version,
+			Identifier:          identifier,
+			RepeatUnconditional: registered.RepeatUnconditional,
+			MinVersion:          registered.MinVersion,
+			MaxVersion:          registered.MaxVersion,
+		}
+		inputs.checksums[identifier] = registered.Checksum
+	}
+
+	planner := migratePlanner{inputs: inputs}
+	plan, err := planner.MakePlan()
+	if err != nil {
+		return PlanDecisions{}, err
+	}
+
+	decisions := PlanDecisions{Run: []PlanDecision{}, Skipped: []PlanDecision{}}
+	for _, migrationModel := range plan.Migrations() {
+		decisions.Run = append(decisions.Run, PlanDecision{
+			Type:    MigrationType(migrationModel.Type),
+			Version: migrationModel.Version.String(),
+			State:   string(migrationModel.State),
+		})
+	}
+	for _, skipped := range plan.skipped {
+		decisions.Skipped = append(decisions.Skipped, PlanDecision{
+			Type:    MigrationType(skipped.migrationModel.Type),
+			Version: skipped.migrationModel.Version.String(),
+			State:   string(skipped.migrationModel.State),
+			Reason:  skipped.code,
+			Message: skipped.reason,
+		})
+	}
+
+	return decisions, nil
+}
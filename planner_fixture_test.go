@@ -0,0 +1,202 @@
+package db_migrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var updateFixtures = flag.Bool("update", false, "rewrite planner fixtures and golden plans")
+
+// plannerFixturesDir - корпус входных данных планировщика: <name>.json записан RecordPlannerFixture,
+// <name>.golden.json - ожидаемый результат ReplayPlannerFixture.
+const plannerFixturesDir = "fixtures/planner"
+
+func fixtureChecksum(checksum string) func(*gorm.DB) string {
+	return func(*gorm.DB) string { return checksum }
+}
+
+// plannerFixtureScenarios приводят базу данных в исходное состояние предыдущими запусками и возвращают менеджер
+// следующей версии приложения, для которого записываются входные данные планировщика.
+var plannerFixtureScenarios = []struct {
+	name  string
+	setup func(t *testing.T) *MigrationManager
+}{
+	{
+		name: "baseline_equal_versions",
+		setup: func(t *testing.T) *MigrationManager {
+			m, _ := newTestManager(t, "1.0.2")
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				Migration{MigrationType: TypeBaseline, Version: "1.0.1", IsTransactional: true, Up: "create table a(id int, b text)"},
+				Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table if not exists b(id int)"},
+				Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+			))
+			return m
+		},
+	},
+	{
+		name: "out_of_order",
+		setup: func(t *testing.T) *MigrationManager {
+			connect, disconnect := newTestDatabase(t)
+			applied := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+				{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "alter table a add column d text"},
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.3"))
+			require.NoError(t, previous.Register("service1", applied...))
+			require.NoError(t, previous.Migrate("service1"))
+
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, m.AddService("service1", ServiceConfig{
+				Connect:       connect,
+				Disconnect:    disconnect,
+				TargetVersion: "1.0.3",
+				Options:       []ServiceOption{WithAllowOutOfOrder()},
+			}))
+			require.NoError(t, m.Register("service1", append(applied,
+				Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+			)...))
+			return m
+		},
+	},
+	{
+		name: "stale_repeatables",
+		setup: func(t *testing.T) *MigrationManager {
+			connect, disconnect := newTestDatabase(t)
+			versioned := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.1"))
+			require.NoError(t, previous.Register("service1", versioned...))
+			require.NoError(t, previous.Register("service1",
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v1 as select 1", CheckSum: fixtureChecksum("v1")},
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.1", IsTransactional: true, Up: "create view v2 as select 1", CheckSum: fixtureChecksum("v1")},
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.1.1", IsTransactional: true, Up: "create view v3 as select 1", CheckSum: fixtureChecksum("v1")},
+			))
+			require.NoError(t, previous.Migrate("service1"))
+
+			// следующая версия: v1 не изменилась, v2 больше не применима, v3 удалена, v4 добавлена
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2"))
+			require.NoError(t, m.Register("service1", versioned...))
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v1 as select 1", CheckSum: fixtureChecksum("v1")},
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.1", IsTransactional: true, Up: "create view v2 as select 1", CheckSum: fixtureChecksum("v1"), MaxVersion: "1.0.1"},
+				Migration{MigrationType: TypeRepeatable, Version: "1.0.2", IsTransactional: true, Up: "create view v4 as select 1", CheckSum: fixtureChecksum("v2")},
+			))
+			return m
+		},
+	},
+}
+
+// TestRecordPlannerFixture проверяет, что план, воспроизведенный по записанным входным данным, совпадает с
+// миграциями, фактически обработанными Migrate.
+func TestRecordPlannerFixture(t *testing.T) {
+	for _, scenario := range plannerFixtureScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			m := scenario.setup(t)
+
+			var fixture bytes.Buffer
+			require.NoError(t, m.RecordPlannerFixture("service1", &fixture))
+
+			var again bytes.Buffer
+			require.NoError(t, m.RecordPlannerFixture("service1", &again))
+			require.Equal(t, fixture.String(), again.String(), "recording must be deterministic")
+
+			if *updateFixtures {
+				require.NoError(t, os.MkdirAll(plannerFixturesDir, 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(plannerFixturesDir, scenario.name+".json"), fixture.Bytes(), 0o644))
+			}
+
+			decisions, err := ReplayPlannerFixture(bytes.NewReader(fixture.Bytes()))
+			require.NoError(t, err)
+
+			report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+			require.NoError(t, err)
+
+			var executed, skipped []string
+			for _, entry := range report.Entries {
+				key := string(entry.Type) + " " + entry.Version
+				if entry.Outcome == OutcomeSkipped {
+					skipped = append(skipped, key)
+					continue
+				}
+				executed = append(executed, key)
+			}
+
+			var planned, planSkipped []string
+			for _, decision := range decisions.Run {
+				planned = append(planned, string(decision.Type)+" "+decision.Version)
+			}
+			for _, decision := range decisions.Skipped {
+				planSkipped = append(planSkipped, string(decision.Type)+" "+decision.Version)
+			}
+
+			require.Equal(t, planned, executed)
+			require.ElementsMatch(t, planSkipped, skipped)
+		})
+	}
+}
+
+// TestReplayPlannerFixtures воспроизводит корпус записанных входных данных и сравнивает план с эталонным.
+func TestReplayPlannerFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(plannerFixturesDir, "*.json"))
+	require.NoError(t, err)
+
+	fixtures := 0
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".golden.json") {
+			continue
+		}
+		fixtures++
+
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(path)
+			require.NoError(t, err)
+			defer file.Close()
+
+			decisions, err := ReplayPlannerFixture(file)
+			require.NoError(t, err)
+
+			got, err := json.MarshalIndent(decisions, "", "  ")
+			require.NoError(t, err)
+			got = append(got, '\n')
+
+			goldenPath := filepath.Join(plannerFixturesDir, name+".golden.json")
+			if *updateFixtures {
+				require.NoError(t, os.WriteFile(goldenPath, got, 0o644))
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			require.NoError(t, err)
+			require.Equal(t, string(want), string(got))
+		})
+	}
+	require.Positive(t, fixtures, "planner fixtures corpus is empty")
+}
+
+func TestReplayPlannerFixtureRejectsUnknownFormat(t *testing.T) {
+	_, err := ReplayPlannerFixture(strings.NewReader(`{"format": 99, "target_version": "1.0.0"}`))
+	require.ErrorContains(t, err, "unsupported planner fixture format: 99")
+}
@@ -0,0 +1,370 @@
+package db_migrator
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// plannerRow - сохраненная миграция во входных данных планировщика.
+type plannerRow struct {
+	migrationType MigrationType
+	version       string
+	state         models.MigrationState
+	checksum      string
+	outOfOrder    bool
+}
+
+// plannerRegistered - зарегистрированная миграция во входных данных планировщика.
+type plannerRegistered struct {
+	migrationType MigrationType
+	version       string
+	checksum      string
+	unconditional bool
+	minVersion    string
+	maxVersion    string
+}
+
+func mustVersion(t *testing.T, version string) models.Version {
+	t.Helper()
+
+	parsed, err := models.ParseVersion(version)
+	require.NoError(t, err)
+	return parsed
+}
+
+// newPlanInputs собирает входные данные планировщика без базы данных. Ранг сохраненных миграций соответствует их
+// порядку в rows.
+func newPlanInputs(t *testing.T, savedVersion string, targetVersion string, rows []plannerRow, registered []plannerRegistered) planInputs {
+	t.Helper()
+
+	inputs := planInputs{
+		savedMigrations: make([]models.MigrationModel, 0, len(rows)),
+		targetVersion:   mustVersion(t, targetVersion),
+		registered:      make(map[uint32]*Migration, len(registered)),
+		checksums:       make(map[uint32]string, len(registered)),
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if len(savedVersion) > 0 {
+		inputs.savedVersion = mustVersion(t, savedVersion)
+	}
+
+	for i, row := range rows {
+		version := mustVersion(t, row.version)
+		inputs.savedMigrations = append(inputs.savedMigrations, models.MigrationModel{
+			Id:         getMigrationIdentifier(version, string(row.migrationType)),
+			Rank:       i + 1,
+			Type:       string(row.migrationType),
+			Version:    version,
+			Checksum:   row.checksum,
+			State:      row.state,
+			OutOfOrder: row.outOfOrder,
+		})
+	}
+
+	for _, migration := range registered {
+		version := mustVersion(t, migration.version)
+		identifier := getMigrationIdentifier(version, string(migration.migrationType))
+		inputs.registered[identifier] = &Migration{
+			MigrationType:       migration.migrationType,
+			Version:             migration.version,
+			Identifier:          identifier,
+			RepeatUnconditional: migration.unconditional,
+			MinVersion:          migration.minVersion,
+			MaxVersion:          migration.maxVersion,
+		}
+		inputs.checksums[identifier] = migration.checksum
+	}
+
+	return inputs
+}
+
+// planVersions возвращает миграции плана в виде "тип версия".
+func planVersions(plan migrationsPlan) []string {
+	planned := make([]string, 0, plan.Len())
+	for _, migrationModel := range plan.Migrations() {
+		planned = append(planned, migrationModel.Type+" "+migrationModel.Version.String())
+	}
+	return planned
+}
+
+func TestMigratePlanner(t *testing.T) {
+	tests := []struct {
+		name          string
+		savedVersion  string
+		targetVersion string
+		rows          []plannerRow
+		registered    []plannerRegistered
+		want          []string
+		wantSkipped   []ReasonCode
+	}{
+		{
+			name:          "fresh database runs latest baseline within target and newer versioned",
+			targetVersion: "1.0.3",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateRegistered},
+				{migrationType: TypeBaseline, version: "1.0.2", state: models.StateRegistered},
+				{migrationType: TypeBaseline, version: "1.0.4", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.2", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.3", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.4", state: models.StateRegistered},
+			},
+			want: []string{"baseline 1.0.2.0", "versioned 1.0.2.0", "versioned 1.0.3.0"},
+		},
+		{
+			name:          "baseline and versioned with equal versions",
+			targetVersion: "1.0.1",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.1", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.0", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+			},
+			want: []string{"baseline 1.0.1.0", "versioned 1.0.1.0"},
+		},
+		{
+			name:          "no baseline within target",
+			targetVersion: "1.0.1",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "2.0.0", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+			},
+			want: []string{"versioned 1.0.1.0"},
+		},
+		{
+			name:          "applied database runs only versions above saved and up to target",
+			savedVersion:  "1.0.1",
+			targetVersion: "1.0.3",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.2", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.3", state: models.StateFailure},
+				{migrationType: TypeVersioned, version: "1.0.4", state: models.StateRegistered},
+			},
+			want: []string{"versioned 1.0.2.0", "versioned 1.0.3.0"},
+		},
+		{
+			name:          "skipped and abandoned versioned are not planned",
+			savedVersion:  "1.0.0",
+			targetVersion: "1.0.3",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateSkipped},
+				{migrationType: TypeVersioned, version: "1.0.2", state: models.StateAbandoned},
+				{migrationType: TypeVersioned, version: "1.0.3", state: models.StateRegistered},
+			},
+			want: []string{"versioned 1.0.3.0"},
+		},
+		{
+			name:          "out of order versioned below saved version",
+			savedVersion:  "1.0.3",
+			targetVersion: "1.0.3",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.2", state: models.StateRegistered, outOfOrder: true},
+				{migrationType: TypeVersioned, version: "1.0.3", state: models.StateSuccess},
+			},
+			want: []string{"versioned 1.0.2.0"},
+		},
+		{
+			name:          "versioned planned in version order regardless of rank",
+			savedVersion:  "1.0.0",
+			targetVersion: "1.0.3",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.3", state: models.StateRegistered},
+				{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+			},
+			want: []string{"versioned 1.0.1.0", "versioned 1.0.3.0"},
+		},
+		{
+			name:          "repeatable checksums",
+			savedVersion:  "1.0.0",
+			targetVersion: "1.0.0",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeRepeatable, version: "1.0.1", state: models.StateSuccess, checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.2", state: models.StateSuccess, checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.3", state: models.StateSuccess, checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.4", state: models.StateFailedAllowed, checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.5", state: models.StateRegistered},
+			},
+			registered: []plannerRegistered{
+				{migrationType: TypeRepeatable, version: "1.0.1", checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.2", checksum: "b"},
+				{migrationType: TypeRepeatable, version: "1.0.3", checksum: "a", unconditional: true},
+				{migrationType: TypeRepeatable, version: "1.0.4", checksum: "a"},
+				{migrationType: TypeRepeatable, version: "1.0.5", checksum: "a"},
+			},
+			want: []string{"repeatable 1.0.2.0", "repeatable 1.0.3.0", "repeatable 1.0.4.0", "repeatable 1.0.5.0"},
+		},
+		{
+			name:          "repeatable without registered migration is planned to update its state",
+			savedVersion:  "1.0.0",
+			targetVersion: "1.0.0",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeRepeatable, version: "1.0.1", state: models.StateSuccess, checksum: "a"},
+			},
+			want: []string{"repeatable 1.0.1.0"},
+		},
+		{
+			name:          "repeatable version range uses version after planned migrations",
+			savedVersion:  "1.0.0",
+			targetVersion: "1.0.2",
+			rows: []plannerRow{
+				{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+				{migrationType: TypeVersioned, version: "1.0.2", state: models.StateRegistered},
+				{migrationType: TypeRepeatable, version: "1.0.3", state: models.StateRegistered},
+				{migrationType: TypeRepeatable, version: "1.0.4", state: models.StateRegistered},
+				{migrationType: TypeRepeatable, version: "1.0.5", state: models.StateRegistered},
+			},
+			registered: []plannerRegistered{
+				{migrationType: TypeRepeatable, version: "1.0.3", checksum: "a", minVersion: "1.0.2"},
+				{migrationType: TypeRepeatable, version: "1.0.4", checksum: "a", minVersion: "1.0.3"},
+				{migrationType: TypeRepeatable, version: "1.0.5", checksum: "a", maxVersion: "1.0.1"},
+			},
+			want:        []string{"versioned 1.0.2.0", "repeatable 1.0.3.0"},
+			wantSkipped: []ReasonCode{ReasonBelowMinVersion, ReasonAboveMaxVersion},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := migratePlanner{inputs: newPlanInputs(t, tt.savedVersion, tt.targetVersion, tt.rows, tt.registered)}
+
+			plan, err := planner.MakePlan()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, planVersions(plan))
+
+			skipped := make([]ReasonCode, 0, len(plan.skipped))
+			for _, migration := range plan.skipped {
+				skipped = append(skipped, migration.code)
+			}
+			require.ElementsMatch(t, tt.wantSkipped, skipped)
+		})
+	}
+}
+
+func TestMigratePlannerDoesNotModifyInputs(t *testing.T) {
+	inputs := newPlanInputs(t, "1.0.0", "1.0.3", []plannerRow{
+		{migrationType: TypeVersioned, version: "1.0.3", state: models.StateRegistered},
+		{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.1", state: models.StateRegistered},
+	}, nil)
+	saved := append([]models.MigrationModel(nil), inputs.savedMigrations...)
+
+	planner := migratePlanner{inputs: inputs}
+	_, err := planner.MakePlan()
+	require.NoError(t, err)
+	require.Equal(t, saved, inputs.savedMigrations)
+}
+
+func TestMigratePlannerInvalidVersionRange(t *testing.T) {
+	inputs := newPlanInputs(t, "1.0.0", "1.0.0", []plannerRow{
+		{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+		{migrationType: TypeRepeatable, version: "1.0.1", state: models.StateRegistered},
+	}, []plannerRegistered{
+		{migrationType: TypeRepeatable, version: "1.0.1", checksum: "a", minVersion: "1.x"},
+	})
+
+	planner := migratePlanner{inputs: inputs}
+	_, err := planner.MakePlan()
+	require.Error(t, err)
+}
+
+func TestDowngradePlanner(t *testing.T) {
+	rows := []plannerRow{
+		{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.1", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.2", state: models.StateUndone},
+		{migrationType: TypeVersioned, version: "1.0.3", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.4", state: models.StateSuccess},
+		{migrationType: TypeRepeatable, version: "1.0.4", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.5", state: models.StateRegistered},
+	}
+
+	tests := []struct {
+		name          string
+		savedVersion  string
+		targetVersion string
+		steps         int
+		want          []string
+	}{
+		{
+			name:          "down to target in descending version order",
+			savedVersion:  "1.0.4",
+			targetVersion: "1.0.0",
+			want:          []string{"versioned 1.0.4.0", "versioned 1.0.3.0", "versioned 1.0.1.0"},
+		},
+		{
+			name:          "target is kept",
+			savedVersion:  "1.0.4",
+			targetVersion: "1.0.3",
+			want:          []string{"versioned 1.0.4.0"},
+		},
+		{
+			name:          "steps limit",
+			savedVersion:  "1.0.4",
+			targetVersion: "1.0.0",
+			steps:         2,
+			want:          []string{"versioned 1.0.4.0", "versioned 1.0.3.0"},
+		},
+		{
+			name:          "target at saved version",
+			savedVersion:  "1.0.4",
+			targetVersion: "1.0.4",
+			want:          []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := downgradePlanner{inputs: newPlanInputs(t, tt.savedVersion, tt.targetVersion, rows, nil), steps: tt.steps}
+
+			plan, err := planner.MakePlan()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, planVersions(plan))
+		})
+	}
+}
+
+func TestDowngradePlannerSkipsNotAppliedMigrations(t *testing.T) {
+	// миграции 1.0.2 - 1.0.5 сохранены вне очереди после выполнения 1.0.6 (см. WithAllowOutOfOrder) и не выполнены
+	rows := []plannerRow{
+		{migrationType: TypeBaseline, version: "1.0.0", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.1", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.6", state: models.StateSuccess},
+		{migrationType: TypeVersioned, version: "1.0.2", state: models.StateFailure, outOfOrder: true},
+		{migrationType: TypeVersioned, version: "1.0.3", state: models.StateRegistered, outOfOrder: true},
+		{migrationType: TypeVersioned, version: "1.0.4", state: models.StateSkipped, outOfOrder: true},
+		{migrationType: TypeVersioned, version: "1.0.5", state: models.StateAbandoned, outOfOrder: true},
+	}
+
+	tests := []struct {
+		name          string
+		targetVersion string
+		steps         int
+		want          []string
+	}{
+		{name: "down to target", targetVersion: "1.0.0", want: []string{"versioned 1.0.6.0", "versioned 1.0.1.0"}},
+		{name: "steps count applied migrations only", targetVersion: "1.0.0", steps: 2, want: []string{"versioned 1.0.6.0", "versioned 1.0.1.0"}},
+		{name: "single step", targetVersion: "1.0.0", steps: 1, want: []string{"versioned 1.0.6.0"}},
+		{name: "target above not applied migrations", targetVersion: "1.0.1", want: []string{"versioned 1.0.6.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := downgradePlanner{inputs: newPlanInputs(t, "1.0.6", tt.targetVersion, rows, nil), steps: tt.steps}
+
+			plan, err := planner.MakePlan()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, planVersions(plan))
+		})
+	}
+}
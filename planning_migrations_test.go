@@ -0,0 +1,288 @@
+package db_migrator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// planningParityScenarios дополняют plannerFixtureScenarios историями, в которых planningMigrations читает
+// существенно меньше строк, чем getSavedMigrations.
+var planningParityScenarios = []struct {
+	name  string
+	setup func(t *testing.T) *MigrationManager
+}{
+	{
+		name: "baseline_below_saved_version",
+		setup: func(t *testing.T) *MigrationManager {
+			connect, disconnect := newTestDatabase(t)
+			applied := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+				{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.2"))
+			require.NoError(t, previous.Register("service1", applied...))
+			require.NoError(t, previous.Migrate("service1"))
+
+			// новая миграция TypeBaseline не должна выполняться поверх существующей схемы
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.4"))
+			require.NoError(t, m.Register("service1", append(applied,
+				Migration{MigrationType: TypeBaseline, Version: "1.0.3", IsTransactional: true, Up: "create table a(id int, b text, c text, d text)"},
+				Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "alter table a add column d text"},
+				Migration{MigrationType: TypeVersioned, Version: "1.0.4", IsTransactional: true, Up: "alter table a add column e text"},
+			)...))
+			return m
+		},
+	},
+	{
+		name: "failed_out_of_order",
+		setup: func(t *testing.T) *MigrationManager {
+			connect, disconnect := newTestDatabase(t)
+			applied := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+				{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "alter table a add column d text"},
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.3"))
+			require.NoError(t, previous.Register("service1", applied...))
+			require.NoError(t, previous.Migrate("service1"))
+
+			// миграция вне очереди завершилась ошибкой и остается невыполненной ниже сохраненной версии
+			failing, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, failing.AddService("service1", ServiceConfig{
+				Connect: connect, Disconnect: disconnect, TargetVersion: "1.0.3",
+				Options: []ServiceOption{WithAllowOutOfOrder()},
+			}))
+			require.NoError(t, failing.Register("service1", append(applied,
+				Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table missing add column c text"},
+			)...))
+			require.Error(t, failing.Migrate("service1"))
+
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, m.AddService("service1", ServiceConfig{
+				Connect: connect, Disconnect: disconnect, TargetVersion: "1.0.3",
+				Options: []ServiceOption{WithAllowOutOfOrder()},
+			}))
+			require.NoError(t, m.Register("service1", append(applied,
+				Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+			)...))
+			return m
+		},
+	},
+	{
+		name: "long_history",
+		setup: func(t *testing.T) *MigrationManager {
+			connect, disconnect := newTestDatabase(t)
+			applied := []Migration{
+				{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			}
+			for i := 1; i <= 200; i++ {
+				applied = append(applied, Migration{
+					MigrationType: TypeVersioned, Version: fmt.Sprintf("1.0.%d", i), IsTransactional: true,
+					Up: fmt.Sprintf("create table t%d(id int)", i),
+				})
+			}
+			repeatables := func(checksum string) []Migration {
+				return []Migration{
+					{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v1 as select 1", CheckSum: fixtureChecksum("v1")},
+					{MigrationType: TypeRepeatable, Version: "1.0.50", IsTransactional: true, Up: "create view v2 as select 1", CheckSum: fixtureChecksum(checksum)},
+					{MigrationType: TypeRepeatable, Version: "1.0.100", IsTransactional: true, Up: "create view v3 as select 1", CheckSum: fixtureChecksum(checksum), MaxVersion: "1.0.200"},
+				}
+			}
+
+			previous, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.200"))
+			require.NoError(t, previous.Register("service1", applied...))
+			require.NoError(t, previous.Register("service1", repeatables("v1")...))
+			require.NoError(t, previous.Migrate("service1"))
+
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.201"))
+			require.NoError(t, m.Register("service1", applied...))
+			require.NoError(t, m.Register("service1", repeatables("v2")...))
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeVersioned, Version: "1.0.201", IsTransactional: true, Up: "create table t201(id int)"},
+			))
+			return m
+		},
+	},
+}
+
+// plansWithFullAndTargetedLoad сохраняет новые миграции сервиса service1 и возвращает планы Migrate, построенные по
+// полной истории миграций и по выборке planningMigrations, а также количество строк каждой выборки.
+func plansWithFullAndTargetedLoad(t *testing.T, m *MigrationManager) (full, targeted migrationsPlan, fullRows, targetedRows int) {
+	t.Helper()
+
+	service, ok := m.service("service1")
+	require.True(t, ok)
+	service.Db = service.open()
+	defer service.DisconnectFunc(service.Db)
+
+	require.NoError(t, m.initSystemTables("service1"))
+	_, err := m.saveNewMigrations("service1")
+	require.NoError(t, err)
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	require.NoError(t, err)
+	full, err = m.planMigrate("service1", savedMigrations)
+	require.NoError(t, err)
+
+	planningMigrations, err := m.planningMigrations("service1")
+	require.NoError(t, err)
+	targeted, err = m.planMigrate("service1", planningMigrations)
+	require.NoError(t, err)
+
+	return full, targeted, len(savedMigrations), len(planningMigrations)
+}
+
+// TestPlanningMigrationsParity проверяет, что план, построенный по выборке planningMigrations, совпадает с планом,
+// построенным по полной истории миграций.
+func TestPlanningMigrationsParity(t *testing.T) {
+	scenarios := append(plannerFixtureScenarios[:len(plannerFixtureScenarios):len(plannerFixtureScenarios)], planningParityScenarios...)
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			m := scenario.setup(t)
+
+			full, targeted, fullRows, targetedRows := plansWithFullAndTargetedLoad(t, m)
+			require.LessOrEqual(t, targetedRows, fullRows)
+			require.Equal(t, full.Migrations(), targeted.Migrations())
+			require.Equal(t, full.skipped, targeted.skipped)
+		})
+	}
+}
+
+func TestPlanningMigrationsSkipAppliedHistory(t *testing.T) {
+	for _, scenario := range planningParityScenarios {
+		if scenario.name != "long_history" {
+			continue
+		}
+		m := scenario.setup(t)
+
+		full, _, fullRows, targetedRows := plansWithFullAndTargetedLoad(t, m)
+		// миграции 1.0.201 и 1.0.200, миграция TypeBaseline и три миграции TypeRepeatable
+		require.Equal(t, 205, fullRows)
+		require.Equal(t, 6, targetedRows)
+
+		var planned []string
+		for _, migrationModel := range full.Migrations() {
+			planned = append(planned, migrationModel.Type+" "+migrationModel.Version.String())
+		}
+		require.Equal(t, []string{"versioned 1.0.201.0", "repeatable 1.0.50.0"}, planned)
+		require.Len(t, full.skipped, 1)
+	}
+}
+
+// TestPlanDowngradeReadsFromPreviousVersion проверяет, что выборка downgradeMigrations содержит миграцию, версия
+// которой сохраняется после отмены, даже если целевая версия не совпадает с версией сохраненной миграции.
+func TestPlanDowngradeReadsFromPreviousVersion(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.4")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "create table b(id int)", Down: "drop table b"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.4", IsTransactional: true, Up: "create table c(id int)", Down: "drop table c"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.6", IsTransactional: true, Up: "create table d(id int)", Down: "drop table d"},
+	))
+	require.NoError(t, m.MigrateTo("service1", "1.0.4"))
+
+	service, _ := m.service("service1")
+	service.mutex.Lock()
+	service.TargetVersion = models.Version{Major: 1, Minor: 0, Patch: 3}
+	service.mutex.Unlock()
+
+	planned, err := m.PlanDowngrade("service1")
+	require.NoError(t, err)
+	require.Len(t, planned, 1)
+	require.Equal(t, "1.0.4.0", planned[0].Version)
+	require.Equal(t, "1.0.2.0", planned[0].ResultingVersion)
+}
+
+// seedMigrationsHistory сохраняет count выполненных миграций типа TypeVersioned с описаниями, миграцию TypeBaseline и
+// несколько миграций TypeRepeatable и устанавливает версию последней из них.
+func seedMigrationsHistory(b *testing.B, db *gorm.DB, count int) {
+	b.Helper()
+
+	description := strings.Repeat("long migration description ", 8)
+	requests := []repository.SaveMigrationRequest{
+		{Rank: 1, Type: string(TypeBaseline), Version: models.Version{Major: 1}, Description: description, State: models.StateSuccess},
+	}
+	for i := 1; i <= count; i++ {
+		requests = append(requests, repository.SaveMigrationRequest{
+			Rank: i + 1, Type: string(TypeVersioned), Version: models.Version{Major: 1, Minor: i / 1000, Patch: i % 1000},
+			Description: description, State: models.StateSuccess,
+		})
+	}
+	for i := 0; i < 20; i++ {
+		requests = append(requests, repository.SaveMigrationRequest{
+			Rank: count + i + 2, Type: string(TypeRepeatable), Version: models.Version{Major: 1, Patch: i},
+			Description: description, State: models.StateSuccess,
+		})
+	}
+
+	_, err := repository.SaveMigrations(db, requests, defaultRegistrationBatchSize)
+	require.NoError(b, err)
+
+	last := requests[count].Version
+	require.NoError(b, repository.SaveVersion(db, last, repository.VersionSource{Version: last.String(), Type: string(TypeVersioned)}))
+}
+
+// BenchmarkPlanningMigrations сравнивает чтение истории из 10000 выполненных миграций для планирования полной
+// выборкой getSavedMigrations и выборкой planningMigrations.
+func BenchmarkPlanningMigrations(b *testing.B) {
+	m, _ := newTestManager(b, "2.0.0")
+	service, _ := m.service("service1")
+	service.Db = service.open()
+	defer service.DisconnectFunc(service.Db)
+
+	require.NoError(b, m.initSystemTables("service1"))
+	seedMigrationsHistory(b, service.Db, 10000)
+
+	queries := 0
+	countQuery := func(*gorm.DB) { queries++ }
+	require.NoError(b, service.Db.Callback().Query().After("gorm:query").Register("bench:count_query", countQuery))
+	require.NoError(b, service.Db.Callback().Row().After("gorm:row").Register("bench:count_row", countQuery))
+
+	loads := []struct {
+		name string
+		load func() ([]models.MigrationModel, error)
+	}{
+		{name: "full", load: func() ([]models.MigrationModel, error) {
+			return m.getSavedMigrations(service.Db, repository.OrderASC)
+		}},
+		{name: "targeted", load: func() ([]models.MigrationModel, error) {
+			return m.planningMigrations("service1")
+		}},
+	}
+
+	for _, load := range loads {
+		b.Run(load.name, func(b *testing.B) {
+			b.ReportAllocs()
+			queries = 0
+			rows := 0
+			for i := 0; i < b.N; i++ {
+				migrations, err := load.load()
+				require.NoError(b, err)
+				rows += len(migrations)
+			}
+			b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+			b.ReportMetric(float64(rows)/float64(b.N), "rows/op")
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrPolicyViolation = errors.New("migration policy violation")
+
+// Profile объединяет набор политик, проверяемых при регистрации миграций и планировании их выполнения. Позволяет
+// задавать разную строгость для разных окружений.
+type Profile struct {
+	// RequireDown - для миграций типа TypeVersioned должен быть задан Down, DownSource или DownF.
+	RequireDown bool
+	// ForbidAllowFailure - запрещает регистрацию миграций с IsAllowFailure.
+	ForbidAllowFailure bool
+	// RequireTicketMetadata - для миграций типов TypeVersioned и TypeBaseline должен быть задан Ticket.
+	RequireTicketMetadata bool
+	// ForbidNonTransactional - запрещает регистрацию миграций, выполняемых вне транзакции.
+	ForbidNonTransactional bool
+	// ForbidDestructiveStatements - запрещает в Up миграций выражения, удаляющие данные: DROP TABLE, DROP SCHEMA,
+	// DROP DATABASE, DROP COLUMN, TRUNCATE и DELETE без WHERE. Проверяется только Up, заданный строкой; Down не
+	// проверяется.
+	ForbidDestructiveStatements bool
+	// MaxPlanSize - максимальное количество миграций в плане выполнения. Значение 0 снимает ограничение.
+	MaxPlanSize int
+	// PlanGate - произвольная проверка плана выполнения Migrate, в том числе по оценкам Migration.Estimate. Ошибка
+	// прерывает выполнение до применения миграций.
+	PlanGate func(migrations []PlannedMigration) error
+	// ForbidRedo - запрещает Redo, предназначенный для разработки миграций.
+	ForbidRedo bool
+	// ForbidRegistrationOverlap - совпадение наборов миграций сервисов (см. WithRegistrationOverlapCheck) прерывает
+	// MigrateAll с ошибкой ErrRegistrationOverlap вместо предупреждения.
+	ForbidRegistrationOverlap bool
+}
+
+type ProfileOption func(*Profile)
+
+// StrictProfile возвращает профиль для production окружения: все проверки включены, размер плана не ограничен.
+func StrictProfile() Profile {
+	return Profile{
+		RequireDown:                 true,
+		ForbidAllowFailure:          true,
+		RequireTicketMetadata:       true,
+		ForbidNonTransactional:      true,
+		ForbidDestructiveStatements: true,
+		ForbidRedo:                  true,
+		ForbidRegistrationOverlap:   true,
+	}
+}
+
+// RelaxedProfile возвращает профиль без проверок. Используется по умолчанию.
+func RelaxedProfile() Profile {
+	return Profile{}
+}
+
+// NewProfile собирает профиль на основе base с применением опций.
+func NewProfile(base Profile, opts ...ProfileOption) Profile {
+	for _, opt := range opts {
+		opt(&base)
+	}
+	return base
+}
+
+func RequireDown(require bool) ProfileOption {
+	return func(p *Profile) {
+		p.RequireDown = require
+	}
+}
+
+func ForbidAllowFailure(forbid bool) ProfileOption {
+	return func(p *Profile) {
+		p.ForbidAllowFailure = forbid
+	}
+}
+
+func RequireTicketMetadata(require bool) ProfileOption {
+	return func(p *Profile) {
+		p.RequireTicketMetadata = require
+	}
+}
+
+func ForbidNonTransactional(forbid bool) ProfileOption {
+	return func(p *Profile) {
+		p.ForbidNonTransactional = forbid
+	}
+}
+
+func ForbidDestructiveStatements(forbid bool) ProfileOption {
+	return func(p *Profile) {
+		p.ForbidDestructiveStatements = forbid
+	}
+}
+
+func MaxPlanSize(size int) ProfileOption {
+	return func(p *Profile) {
+		p.MaxPlanSize = size
+	}
+}
+
+func PlanGate(gate func(migrations []PlannedMigration) error) ProfileOption {
+	return func(p *Profile) {
+		p.PlanGate = gate
+	}
+}
+
+func ForbidRedo(forbid bool) ProfileOption {
+	return func(p *Profile) {
+		p.ForbidRedo = forbid
+	}
+}
+
+func ForbidRegistrationOverlap(forbid bool) ProfileOption {
+	return func(p *Profile) {
+		p.ForbidRegistrationOverlap = forbid
+	}
+}
+
+// validateMigration проверяет миграцию на соответствие политикам профиля.
+func (p Profile) validateMigration(migration *Migration) error {
+	if p.RequireDown && migration.MigrationType == TypeVersioned && !migration.hasDown() {
+		return fmt.Errorf("%w: Down, DownSource or DownF is required, version: %s", ErrPolicyViolation, migration.Version)
+	}
+
+	if p.ForbidAllowFailure && migration.IsAllowFailure {
+		return fmt.Errorf("%w: IsAllowFailure is forbidden, version: %s", ErrPolicyViolation, migration.Version)
+	}
+
+	if p.RequireTicketMetadata && migration.MigrationType != TypeRepeatable && len(migration.Ticket) == 0 {
+		return fmt.Errorf("%w: Ticket is required, version: %s", ErrPolicyViolation, migration.Version)
+	}
+
+	if p.ForbidNonTransactional && !migration.IsTransactional {
+		return fmt.Errorf("%w: non-transactional migrations are forbidden, version: %s", ErrPolicyViolation, migration.Version)
+	}
+
+	if p.ForbidDestructiveStatements {
+		if statement, ok := destructiveStatement(migration.Up); ok {
+			return fmt.Errorf(
+				"%w: destructive statement %q is forbidden, version: %s",
+				ErrPolicyViolation, statementSnippet(statement), migration.Version,
+			)
+		}
+	}
+
+	return nil
+}
+
+// destructiveStatementRegexps - выражения, запрещаемые Profile.ForbidDestructiveStatements.
+var destructiveStatementRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)^DROP\s+(TABLE|SCHEMA|DATABASE)\b`),
+	regexp.MustCompile(`(?is)^ALTER\s+TABLE\b.*\bDROP\s+COLUMN\b`),
+	regexp.MustCompile(`(?is)^TRUNCATE\b`),
+	regexp.MustCompile(`(?is)^DELETE\s+FROM\s+\S+$`),
+}
+
+// destructiveStatement возвращает первое выражение sql, удаляющее данные.
+func destructiveStatement(sql string) (string, bool) {
+	for _, statement := range splitStatements(sql) {
+		statement = stripLeadingComments(statement)
+		for _, re := range destructiveStatementRegexps {
+			if re.MatchString(statement) {
+				return statement, true
+			}
+		}
+	}
+	return "", false
+}
+
+// stripLeadingComments удаляет комментарии в начале выражения.
+func stripLeadingComments(statement string) string {
+	for {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case strings.HasPrefix(statement, "--"):
+			_, statement, _ = strings.Cut(statement, "\n")
+		case strings.HasPrefix(statement, "/*"):
+			_, statement, _ = strings.Cut(statement, "*/")
+		default:
+			return statement
+		}
+	}
+}
+
+// validatePlan проверяет план выполнения на соответствие политикам профиля.
+func (p Profile) validatePlan(migrations []PlannedMigration) error {
+	if p.MaxPlanSize > 0 && len(migrations) > p.MaxPlanSize {
+		return fmt.Errorf("%w: plan contains %d migrations, maximum is %d", ErrPolicyViolation, len(migrations), p.MaxPlanSize)
+	}
+
+	if p.PlanGate != nil {
+		if err := p.PlanGate(migrations); err != nil {
+			return fmt.Errorf("%w: %w", ErrPolicyViolation, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package db_migrator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestStrictProfileRequiresDown(t *testing.T) {
+	strict := func(migration Migration) Migration {
+		migration.MigrationType = TypeVersioned
+		migration.Version = "1.0.1"
+		migration.Ticket = "OPS-1"
+		migration.IsTransactional = true
+		return migration
+	}
+
+	rejected := map[string]Migration{
+		"without down": strict(Migration{Up: "alter table a add column b text"}),
+		"up function":  strict(Migration{UpF: func(*gorm.DB, map[string]*gorm.DB) error { return nil }}),
+	}
+	for name, migration := range rejected {
+		t.Run(name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.1", WithPolicyProfile(StrictProfile()))
+
+			require.ErrorIs(t, m.Register("service1", migration), ErrPolicyViolation)
+
+			plan, err := m.Plan("service1")
+			require.NoError(t, err)
+			require.Empty(t, plan)
+		})
+	}
+
+	accepted := map[string]Migration{
+		"down":          strict(Migration{Up: "alter table a add column b text", Down: "alter table a drop column b"}),
+		"down function": strict(Migration{Up: "alter table a add column b text", DownF: func(*gorm.DB, map[string]*gorm.DB) error { return nil }}),
+		"down source": strict(Migration{Up: "alter table a add column b text", DownSource: FileSource(fstest.MapFS{
+			"down.sql": {Data: []byte("alter table a drop column b")},
+		}, "down.sql")}),
+	}
+	for name, migration := range accepted {
+		t.Run(name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.1", WithPolicyProfile(StrictProfile()))
+			require.NoError(t, m.Register("service1", migration))
+		})
+	}
+
+	t.Run("relaxed profile", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.1")
+		require.NoError(t, m.Register("service1", rejected["without down"]))
+	})
+
+	t.Run("baseline", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.0", WithPolicyProfile(StrictProfile()))
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", Ticket: "OPS-1", IsTransactional: true, Up: "create table a(id int)"},
+		))
+	})
+}
+
+func TestForbidDestructiveStatements(t *testing.T) {
+	tests := map[string]bool{
+		"alter table a add column b text":                              false,
+		"delete from a where id = 1":                                   false,
+		"create table a_copy as select * from a":                       false,
+		"insert into log values ('drop table a')":                      false,
+		"-- drop table a\ncreate index a_id on a(id)":                  false,
+		"drop index a_id":                                              false,
+		"drop table a":                                                 true,
+		"DROP TABLE IF EXISTS a":                                       true,
+		"drop schema app cascade":                                      true,
+		"alter table a add column c text; alter table a drop column b": true,
+		"truncate table a":                                             true,
+		"-- cleanup\ndelete from a":                                    true,
+		"/* cleanup */ DELETE FROM a;":                                 true,
+	}
+
+	profile := NewProfile(Profile{}, ForbidDestructiveStatements(true))
+	for up, destructive := range tests {
+		t.Run(up, func(t *testing.T) {
+			migration := Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: up}
+			err := profile.validateMigration(&migration)
+			if !destructive {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrPolicyViolation)
+			require.ErrorContains(t, err, "destructive statement")
+		})
+	}
+
+	t.Run("down is not checked", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.1", WithPolicyProfile(profile))
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			Migration{
+				MigrationType:   TypeVersioned,
+				Version:         "1.0.1",
+				IsTransactional: true,
+				Up:              "alter table a add column b text",
+				Down:            "alter table a drop column b",
+			},
+		))
+		require.ErrorIs(t, m.Register("service1",
+			Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "drop table a"},
+		), ErrPolicyViolation)
+	})
+}
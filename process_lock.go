@@ -0,0 +1,283 @@
+package db_migrator
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrLockNotAcquired = errors.New("migration lock is held by another process")
+
+const (
+	defaultLockTimeout      = 10 * time.Minute
+	defaultLockLease        = 2 * time.Minute
+	processLockPollInterval = time.Second
+)
+
+// acquireProcessLock захватывает межпроцессную блокировку сервиса на время Migrate или Downgrade: для postgres -
+// pg_advisory_lock в отдельном соединении, для остальных диалектов - запись в таблице migrator_lock. Ожидание
+// блокировки ограничено WithLockTimeout, по истечении возвращается ErrLockNotAcquired. Аренда записи в migrator_lock
+// продлевается, пока блокировка удерживается (см. WithLockLease). Возвращает функцию освобождения блокировки, ошибки
+// освобождения выводятся в лог.
+func (m *MigrationManager) acquireProcessLock(ctx context.Context, db *gorm.DB, serviceName string) (func(), error) {
+	var (
+		release func()
+		err     error
+	)
+	if db.Dialector.Name() == "postgres" {
+		release, err = m.acquireAdvisoryLock(ctx, db, serviceName)
+	} else {
+		release, err = m.acquireLockRow(ctx, db, serviceName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: service %s: %w", ErrLockNotAcquired, serviceName, err)
+	}
+
+	m.logger.Debug(fmt.Sprintf("migration lock acquired, service: %s", serviceName))
+	return release, nil
+}
+
+func (m *MigrationManager) acquireAdvisoryLock(ctx context.Context, db *gorm.DB, serviceName string) (func(), error) {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// advisory lock принадлежит сессии, поэтому захват и освобождение выполняются в одном соединении пула
+	conn, err := sqlDb.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := processLockKey(db, serviceName)
+	err = m.waitProcessLock(ctx, serviceName, func(ctx context.Context) (bool, error) {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+		return acquired, err
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		m.releaseAdvisoryLock(conn, serviceName, key)
+	}, nil
+}
+
+func (m *MigrationManager) releaseAdvisoryLock(conn *sql.Conn, serviceName string, key int64) {
+	_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to release migration lock, service: %s, err: %s", serviceName, err))
+	}
+	if err := conn.Close(); err != nil {
+		m.logger.Error(fmt.Sprintf("fail to close migration lock connection, service: %s, err: %s", serviceName, err))
+	}
+}
+
+func (m *MigrationManager) acquireLockRow(ctx context.Context, db *gorm.DB, serviceName string) (func(), error) {
+	err := repository.CreateLockTable(db)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.MigrateLockTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := processLockOwner(m.appliedBy())
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.waitProcessLock(ctx, serviceName, func(ctx context.Context) (bool, error) {
+		now := time.Now().UTC()
+		acquired, err := repository.TryLock(ctx, db, serviceName, owner, now)
+		if err != nil || acquired || m.lockLease <= 0 {
+			return acquired, err
+		}
+		return m.takeOverStaleLock(ctx, db, serviceName, owner, now)
+	})
+	if err != nil {
+		if lock, lockErr := repository.GetLock(db, serviceName); lockErr == nil {
+			return nil, fmt.Errorf(
+				"held by %s since %s: %w",
+				lock.Owner, lock.AcquiredAt.Format(time.RFC3339), err,
+			)
+		}
+		return nil, err
+	}
+
+	stopHeartbeat := m.startLockHeartbeat(db, serviceName, owner)
+	return func() {
+		stopHeartbeat()
+		if err := repository.Unlock(db, serviceName, owner); err != nil {
+			m.logger.Error(fmt.Sprintf("fail to release migration lock, service: %s, err: %s", serviceName, err))
+		}
+	}, nil
+}
+
+// takeOverStaleLock перехватывает запись блокировки, аренда которой не продлевалась дольше WithLockLease: владелец
+// такой записи считается аварийно завершенным.
+func (m *MigrationManager) takeOverStaleLock(ctx context.Context, db *gorm.DB, serviceName string, owner string, now time.Time) (bool, error) {
+	lock, err := repository.GetLock(db, serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	heartbeat := lock.AcquiredAt.Time
+	if lock.HeartbeatAt != nil {
+		heartbeat = lock.HeartbeatAt.Time
+	}
+	if now.Sub(heartbeat) < m.lockLease {
+		return false, nil
+	}
+
+	acquired, err := repository.TakeOverLock(ctx, db, serviceName, lock.Owner, owner, now, now.Add(-m.lockLease))
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	m.logger.Warn(
+		fmt.Sprintf(
+			"migration lock of %s taken over, last heartbeat: %s, service: %s",
+			lock.Owner, heartbeat.Format(time.RFC3339), serviceName,
+		),
+	)
+	return true, nil
+}
+
+// startLockHeartbeat продлевает аренду записи блокировки каждую треть WithLockLease до вызова возвращаемой функции.
+func (m *MigrationManager) startLockHeartbeat(db *gorm.DB, serviceName string, owner string) func() {
+	if m.lockLease <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(m.lockLease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			held, err := repository.HeartbeatLock(db, serviceName, owner, time.Now().UTC())
+			if err != nil {
+				m.logger.Warn(fmt.Sprintf("fail to renew migration lock, service: %s, err: %s", serviceName, err))
+				continue
+			}
+			if !held {
+				m.logger.Error(fmt.Sprintf("migration lock was taken over by another process, service: %s", serviceName))
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// ReleaseLock удаляет запись блокировки сервиса в таблице migrator_lock независимо от владельца. Предназначен для
+// снятия блокировки аварийно завершенного процесса, не дожидаясь истечения аренды (см. WithLockLease). Удаление
+// блокировки работающего процесса допускает одновременное выполнение миграций.
+//
+// Для postgres блокировка (pg_advisory_lock) принадлежит соединению и освобождается сервером при его закрытии,
+// ReleaseLock ее не затрагивает.
+func (m *MigrationManager) ReleaseLock(serviceName string) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	if service.Db.Dialector.Name() == "postgres" || !repository.HasLockTable(service.Db) {
+		return nil
+	}
+
+	lock, err := repository.GetLock(service.Db, serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = repository.DeleteLock(service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Warn(fmt.Sprintf("migration lock of %s released, service: %s", lock.Owner, serviceName))
+	return nil
+}
+
+// waitProcessLock вызывает tryLock до успешного захвата блокировки, но не дольше WithLockTimeout.
+func (m *MigrationManager) waitProcessLock(ctx context.Context, serviceName string, tryLock func(ctx context.Context) (bool, error)) error {
+	attempt := 0
+	return waitFor(ctx, "migration lock", processLockPollInterval, m.lockTimeout, func(ctx context.Context) (bool, error) {
+		acquired, err := tryLock(ctx)
+		if err == nil && !acquired && attempt == 0 {
+			m.logger.Info(
+				fmt.Sprintf(
+					"migration lock is held by another process, waiting up to %s, service: %s",
+					m.lockTimeout, serviceName,
+				),
+			)
+		}
+		attempt++
+		return acquired, err
+	})
+}
+
+// processLockKey возвращает ключ advisory lock сервиса. Для сервисов с префиксом или схемой системных таблиц ключ
+// включает имя таблицы migrator_lock, чтобы одноименные сервисы с разным размещением таблиц не блокировали друг друга.
+func processLockKey(db *gorm.DB, serviceName string) int64 {
+	name := serviceName
+	if lockTable := repository.LockTable(db); lockTable != (models.LockModel{}).TableName() {
+		name = lockTable + "/" + serviceName
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("db-migrator:" + name))
+	return int64(h.Sum64())
+}
+
+// processLockOwner возвращает уникальный идентификатор владельца блокировки: пользователь и хост, идентификатор
+// процесса и случайный суффикс.
+func processLockOwner(appliedBy string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d/%s", appliedBy, os.Getpid(), hex.EncodeToString(suffix)), nil
+}
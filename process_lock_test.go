@@ -0,0 +1,118 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// lockOfCrashedProcess сохраняет запись блокировки service1, оставшуюся от процесса, завершенного acquiredAgo назад.
+func lockOfCrashedProcess(t *testing.T, connect func() *gorm.DB, acquiredAgo time.Duration) {
+	t.Helper()
+
+	db := connect()
+	require.NoError(t, repository.CreateLockTable(db))
+	acquired, err := repository.TryLock(context.Background(), db, "service1", "crashed", time.Now().UTC().Add(-acquiredAgo))
+	require.NoError(t, err)
+	require.True(t, acquired)
+}
+
+func lockTestMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	}
+}
+
+func TestStaleLockIsTakenOver(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0", WithLockLease(time.Minute), WithLockTimeout(5*time.Second))
+	lockOfCrashedProcess(t, connect, time.Hour)
+
+	require.NoError(t, m.Register("service1", lockTestMigrations()...))
+	require.NoError(t, m.Migrate("service1"))
+
+	_, err := repository.GetLock(connect(), "service1")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestLiveLockIsNotTakenOver(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0", WithLockLease(time.Hour), WithLockTimeout(500*time.Millisecond))
+	lockOfCrashedProcess(t, connect, time.Minute)
+
+	require.NoError(t, m.Register("service1", lockTestMigrations()...))
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+	require.ErrorContains(t, err, "held by crashed")
+}
+
+func TestReleaseLock(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0", WithLockLease(0), WithLockTimeout(500*time.Millisecond))
+	require.NoError(t, m.ReleaseLock("service1"), "no lock table yet")
+
+	lockOfCrashedProcess(t, connect, time.Hour)
+
+	require.NoError(t, m.Register("service1", lockTestMigrations()...))
+	require.ErrorIs(t, m.Migrate("service1"), ErrLockNotAcquired, "lease disabled, stale lock must be kept")
+
+	require.NoError(t, m.ReleaseLock("service1"))
+	require.NoError(t, m.Migrate("service1"))
+}
+
+// TestLockHeartbeat проверяет, что аренда блокировки продлевается во время выполнения миграции, дольше срока аренды.
+func TestLockHeartbeat(t *testing.T) {
+	const lease = 300 * time.Millisecond
+	m, connect := newTestManager(t, "1.0.1", WithLockLease(lease))
+
+	var (
+		lockErr error
+		attempt = make(chan struct{})
+	)
+	require.NoError(t, m.Register("service1",
+		lockTestMigrations()[0],
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.1",
+			UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+				time.Sleep(3 * lease)
+
+				other, err := NewMigrationsManager(WithLockLease(lease), WithLockTimeout(lease))
+				if err != nil {
+					return err
+				}
+				err = other.RegisterService("service1", connect, func(db *gorm.DB) {
+					sqlDb, _ := db.DB()
+					_ = sqlDb.Close()
+				}, "1.0.1")
+				if err != nil {
+					return err
+				}
+				lockErr = other.Migrate("service1")
+				close(attempt)
+				return nil
+			},
+		},
+	))
+
+	require.NoError(t, m.Migrate("service1"))
+	<-attempt
+	require.ErrorIs(t, lockErr, ErrLockNotAcquired)
+
+	_, err := repository.GetLock(connect(), "service1")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestMigrateLockTableAddsHeartbeat(t *testing.T) {
+	connect, _ := newTestDatabase(t)
+	db := connect()
+	require.NoError(t, db.Exec("create table migrator_lock(service varchar(255) primary key, owner text, acquired_at datetime)").Error)
+	require.NoError(t, db.Exec("insert into migrator_lock(service, owner, acquired_at) values ('service1', 'crashed', ?)", time.Now().UTC().Add(-time.Hour)).Error)
+
+	m, err := NewMigrationsManager(WithLockLease(time.Minute), WithLockTimeout(5*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, func(db *gorm.DB) {}, "1.0.0"))
+	require.NoError(t, m.Register("service1", lockTestMigrations()...))
+	require.NoError(t, m.Migrate("service1"), "lock without heartbeat must expire by acquisition time")
+}
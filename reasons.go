@@ -0,0 +1,225 @@
+package db_migrator
+
+import (
+	"errors"
+)
+
+// ReasonCode - машиночитаемый код причины ошибки или пропуска миграции. Коды стабильны между версиями библиотеки,
+// поэтому внешние инструменты должны ориентироваться на них, а не на текст ошибок.
+type ReasonCode string
+
+const (
+	ReasonNone                     ReasonCode = ""
+	ReasonForthcomingMigrations    ReasonCode = "forthcoming_migrations"
+	ReasonFailedMigrations         ReasonCode = "failed_migrations"
+	ReasonFailedAllowedMigrations  ReasonCode = "failed_allowed_migrations"
+	ReasonTargetVersionNotLatest   ReasonCode = "target_version_not_latest"
+	ReasonTargetBelowSaved         ReasonCode = "target_below_saved_version"
+	ReasonRowsAffectedBelow        ReasonCode = "rows_affected_below_expected"
+	ReasonDatabaseAheadOfBinary    ReasonCode = "database_ahead_of_binary"
+	ReasonMigrationLocked          ReasonCode = "migration_locked"
+	ReasonConflictingDirection     ReasonCode = "conflicting_run_direction"
+	ReasonForeignTable             ReasonCode = "foreign_system_table"
+	ReasonRegistrationsFrozen      ReasonCode = "registrations_frozen"
+	ReasonNotConfirmed             ReasonCode = "not_confirmed"
+	ReasonLibraryTooOld            ReasonCode = "library_too_old"
+	ReasonLossyConversion          ReasonCode = "lossy_conversion"
+	ReasonPolicyViolation          ReasonCode = "policy_violation"
+	ReasonSchemaDrift              ReasonCode = "schema_drift"
+	ReasonServiceNotFound          ReasonCode = "service_not_found"
+	ReasonExtensionUnavailable     ReasonCode = "extension_unavailable"
+	ReasonRegistrationOverlap      ReasonCode = "registration_overlap"
+	ReasonMigrationTimeout         ReasonCode = "migration_timeout"
+	ReasonBelowMinVersion          ReasonCode = "below_min_version"
+	ReasonAboveMaxVersion          ReasonCode = "above_max_version"
+	ReasonAbortedByHook            ReasonCode = "aborted_by_hook"
+	ReasonLockNotAcquired          ReasonCode = "lock_not_acquired"
+	ReasonDatabaseClaimed          ReasonCode = "database_already_claimed"
+	ReasonChecksumMismatch         ReasonCode = "checksum_mismatch"
+	ReasonLeakedState              ReasonCode = "leaked_state"
+	ReasonInvalidTransition        ReasonCode = "invalid_state_transition"
+	ReasonConflictingVersions      ReasonCode = "conflicting_versions"
+	ReasonMigrationNotFound        ReasonCode = "migration_not_found"
+	ReasonRegisteredVersionTooLow  ReasonCode = "registered_version_too_low"
+	ReasonDependencyNotFound       ReasonCode = "dependency_not_found"
+	ReasonDependencyNotConnected   ReasonCode = "dependency_not_connected"
+	ReasonDependencyNotInitialized ReasonCode = "dependency_not_initialized"
+	ReasonDependencyNotMigrated    ReasonCode = "dependency_not_migrated"
+	ReasonDependencyNotSatisfied   ReasonCode = "dependency_not_satisfied"
+	ReasonDuplicateMigration       ReasonCode = "duplicate_migration"
+	ReasonPreviousRunInterrupted   ReasonCode = "previous_run_interrupted"
+)
+
+// reasonErrors сопоставляет ошибки библиотеки с кодами причин. Порядок важен: ошибка, оборачивающая несколько
+// ошибок библиотеки, получает код первой из них.
+var reasonErrors = []struct {
+	err  error
+	code ReasonCode
+}{
+	{err: ErrHasForthcomingMigrations, code: ReasonForthcomingMigrations},
+	{err: ErrHasFailedMigrations, code: ReasonFailedMigrations},
+	{err: ErrHasFailedAllowedMigrations, code: ReasonFailedAllowedMigrations},
+	{err: ErrTargetVersionNotLatest, code: ReasonTargetVersionNotLatest},
+	{err: ErrTargetBelowSavedVersion, code: ReasonTargetBelowSaved},
+	{err: ErrRowsAffectedBelowExpected, code: ReasonRowsAffectedBelow},
+	{err: ErrDatabaseAheadOfBinary, code: ReasonDatabaseAheadOfBinary},
+	{err: ErrMigrationLocked, code: ReasonMigrationLocked},
+	{err: ErrConflictingRunDirection, code: ReasonConflictingDirection},
+	{err: ErrForeignMigrationsTable, code: ReasonForeignTable},
+	{err: ErrRegistrationsFrozen, code: ReasonRegistrationsFrozen},
+	{err: ErrNotConfirmed, code: ReasonNotConfirmed},
+	{err: ErrLibraryTooOld, code: ReasonLibraryTooOld},
+	{err: ErrLossyConversion, code: ReasonLossyConversion},
+	{err: ErrPolicyViolation, code: ReasonPolicyViolation},
+	{err: ErrServiceNotFound, code: ReasonServiceNotFound},
+	{err: ErrSchemaDrift, code: ReasonSchemaDrift},
+	{err: ErrExtensionUnavailable, code: ReasonExtensionUnavailable},
+	{err: ErrRegistrationOverlap, code: ReasonRegistrationOverlap},
+	{err: ErrMigrationTimeout, code: ReasonMigrationTimeout},
+	{err: ErrAbortedByHook, code: ReasonAbortedByHook},
+	{err: ErrLockNotAcquired, code: ReasonLockNotAcquired},
+	{err: ErrDatabaseAlreadyClaimed, code: ReasonDatabaseClaimed},
+	{err: ErrChecksumMismatch, code: ReasonChecksumMismatch},
+	{err: ErrLeakedState, code: ReasonLeakedState},
+	{err: ErrInvalidTransition, code: ReasonInvalidTransition},
+	{err: ErrConflictingVersions, code: ReasonConflictingVersions},
+	{err: ErrMigrationNotFound, code: ReasonMigrationNotFound},
+	{err: ErrRegisteredVersionTooLow, code: ReasonRegisteredVersionTooLow},
+	{err: ErrDependencyNotFound, code: ReasonDependencyNotFound},
+	{err: ErrDependencyNotConnected, code: ReasonDependencyNotConnected},
+	{err: ErrDependencyNotInitialized, code: ReasonDependencyNotInitialized},
+	{err: ErrDependencyNotMigrated, code: ReasonDependencyNotMigrated},
+	{err: ErrDependencyNotSatisfied, code: ReasonDependencyNotSatisfied},
+	{err: ErrDuplicateMigration, code: ReasonDuplicateMigration},
+	{err: ErrPreviousRunInterrupted, code: ReasonPreviousRunInterrupted},
+}
+
+// ReasonOf возвращает код причины ошибки err. Для ошибок, не относящихся к библиотеке, возвращается ReasonNone.
+func ReasonOf(err error) ReasonCode {
+	if err == nil {
+		return ReasonNone
+	}
+	for _, reasonError := range reasonErrors {
+		if errors.Is(err, reasonError.err) {
+			return reasonError.code
+		}
+	}
+	return ReasonNone
+}
+
+type Locale string
+
+const (
+	LocaleEN Locale = "en"
+	LocaleRU Locale = "ru"
+)
+
+// messageCatalog - человекочитаемые описания кодов причин по языкам.
+var messageCatalog = map[Locale]map[ReasonCode]string{
+	LocaleEN: {
+		ReasonForthcomingMigrations:    "there are migrations that have not been applied yet",
+		ReasonFailedMigrations:         "some migrations failed, the database requires attention",
+		ReasonFailedAllowedMigrations:  "some repeatable migrations failed under an allowed failure policy",
+		ReasonTargetVersionNotLatest:   "the target version is lower than the latest migration",
+		ReasonTargetBelowSaved:         "the requested version is below the database version, use downgrade instead",
+		ReasonRowsAffectedBelow:        "the migration affected fewer rows than expected",
+		ReasonDatabaseAheadOfBinary:    "the database contains migrations newer than this binary",
+		ReasonMigrationLocked:          "the migration is being executed by another instance",
+		ReasonConflictingDirection:     "the run conflicts with a recent run in the opposite direction",
+		ReasonForeignTable:             "a system table exists but was not created by the migrator",
+		ReasonRegistrationsFrozen:      "migrations cannot be registered after migrate in this process",
+		ReasonNotConfirmed:             "the operation was not confirmed",
+		ReasonLibraryTooOld:            "the migrator library is older than the database requires",
+		ReasonLossyConversion:          "the migration cannot be converted without losing behaviour",
+		ReasonPolicyViolation:          "the migration violates the configured policy profile",
+		ReasonServiceNotFound:          "the service is not registered",
+		ReasonSchemaDrift:              "the schema was changed outside of migrations since the previous run",
+		ReasonExtensionUnavailable:     "a database extension required by migrations is not available",
+		ReasonRegistrationOverlap:      "two services share too many identical migrations, check registrations",
+		ReasonMigrationTimeout:         "the migration did not finish within its timeout",
+		ReasonBelowMinVersion:          "the database version is lower than the migration's minimum version",
+		ReasonAboveMaxVersion:          "the database version is higher than the migration's maximum version",
+		ReasonAbortedByHook:            "the migration was aborted by a before migration hook",
+		ReasonLockNotAcquired:          "another process holds the migration lock of the service",
+		ReasonDatabaseClaimed:          "the database is already used by another service, check the connection settings",
+		ReasonChecksumMismatch:         "an applied migration was changed after it was executed",
+		ReasonLeakedState:              "the migration left an open transaction or session objects behind",
+		ReasonInvalidTransition:        "the migration cannot change to the requested state from its current state",
+		ReasonConflictingVersions:      "the version table contains several rows with different versions",
+		ReasonMigrationNotFound:        "the migration is not registered",
+		ReasonRegisteredVersionTooLow:  "a new migration has a lower version than an already saved one",
+		ReasonDependencyNotFound:       "the dependency service is not added",
+		ReasonDependencyNotConnected:   "the dependency service has no connection",
+		ReasonDependencyNotInitialized: "the dependency service has no version table",
+		ReasonDependencyNotMigrated:    "the dependency service has no saved version",
+		ReasonDependencyNotSatisfied:   "the dependency version does not satisfy the requirement",
+		ReasonDuplicateMigration:       "a migration with the same version and type is already registered",
+		ReasonPreviousRunInterrupted:   "a previous run was interrupted while executing non-transactional migrations",
+	},
+	LocaleRU: {
+		ReasonForthcomingMigrations:    "есть невыполненные миграции",
+		ReasonFailedMigrations:         "некоторые миграции завершились ошибкой, требуется вмешательство",
+		ReasonFailedAllowedMigrations:  "некоторые повторяемые миграции завершились допустимой ошибкой",
+		ReasonTargetVersionNotLatest:   "целевая версия ниже версии последней миграции",
+		ReasonTargetBelowSaved:         "запрошенная версия ниже версии базы данных, используйте откат",
+		ReasonRowsAffectedBelow:        "миграция изменила меньше строк, чем ожидалось",
+		ReasonDatabaseAheadOfBinary:    "в базе данных есть миграции новее, чем в приложении",
+		ReasonMigrationLocked:          "миграция выполняется другим экземпляром приложения",
+		ReasonConflictingDirection:     "запуск противоречит недавнему запуску в обратном направлении",
+		ReasonForeignTable:             "системная таблица существует, но создана не мигратором",
+		ReasonRegistrationsFrozen:      "регистрация миграций после Migrate в этом процессе запрещена",
+		ReasonNotConfirmed:             "операция не подтверждена",
+		ReasonLibraryTooOld:            "версия библиотеки ниже требуемой базой данных",
+		ReasonLossyConversion:          "миграцию нельзя преобразовать без потери поведения",
+		ReasonPolicyViolation:          "миграция нарушает профиль политик",
+		ReasonServiceNotFound:          "сервис не зарегистрирован",
+		ReasonSchemaDrift:              "схема изменена вне миграций после предыдущего запуска",
+		ReasonExtensionUnavailable:     "расширение базы данных, необходимое миграциям, недоступно",
+		ReasonRegistrationOverlap:      "у двух сервисов слишком много одинаковых миграций, проверьте регистрацию",
+		ReasonMigrationTimeout:         "миграция не завершилась за отведенное время",
+		ReasonBelowMinVersion:          "версия базы данных ниже минимальной версии миграции",
+		ReasonAboveMaxVersion:          "версия базы данных выше максимальной версии миграции",
+		ReasonAbortedByHook:            "миграция прервана обработчиком перед выполнением",
+		ReasonLockNotAcquired:          "блокировка миграций сервиса удерживается другим процессом",
+		ReasonDatabaseClaimed:          "база данных уже используется другим сервисом, проверьте параметры подключения",
+		ReasonChecksumMismatch:         "выполненная миграция изменена после выполнения",
+		ReasonLeakedState:              "миграция оставила открытую транзакцию или объекты сессии",
+		ReasonInvalidTransition:        "миграция не может перейти в требуемое состояние из текущего",
+		ReasonConflictingVersions:      "таблица version содержит несколько строк с разными версиями",
+		ReasonMigrationNotFound:        "миграция не зарегистрирована",
+		ReasonRegisteredVersionTooLow:  "версия новой миграции ниже версии уже сохраненной миграции",
+		ReasonDependencyNotFound:       "сервис-зависимость не добавлен",
+		ReasonDependencyNotConnected:   "у сервиса-зависимости нет подключения",
+		ReasonDependencyNotInitialized: "у сервиса-зависимости нет таблицы version",
+		ReasonDependencyNotMigrated:    "у сервиса-зависимости нет сохраненной версии",
+		ReasonDependencyNotSatisfied:   "версия сервиса-зависимости не удовлетворяет требованию",
+		ReasonDuplicateMigration:       "миграция с такой же версией и типом уже зарегистрирована",
+		ReasonPreviousRunInterrupted:   "предыдущий запуск был прерван во время выполнения нетранзакционных миграций",
+	},
+}
+
+// Message возвращает описание кода причины на языке locale. Для неизвестного языка используется LocaleEN,
+// для неизвестного кода - сам код.
+func Message(locale Locale, code ReasonCode) string {
+	messages, ok := messageCatalog[locale]
+	if !ok {
+		messages = messageCatalog[LocaleEN]
+	}
+	if message, ok := messages[code]; ok {
+		return message
+	}
+	return string(code)
+}
+
+// Describe возвращает описание ошибки на языке, заданном опцией WithLocale. Для ошибок без кода причины
+// возвращается err.Error().
+func (m *MigrationManager) Describe(err error) string {
+	if err == nil {
+		return ""
+	}
+	code := ReasonOf(err)
+	if code == ReasonNone {
+		return err.Error()
+	}
+	return Message(m.locale, code)
+}
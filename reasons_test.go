@@ -0,0 +1,103 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReasonCatalog(t *testing.T) {
+	tests := []struct {
+		err  error
+		code ReasonCode
+		en   string
+		ru   string
+	}{
+		{err: ErrHasForthcomingMigrations, code: "forthcoming_migrations", en: "there are migrations that have not been applied yet", ru: "есть невыполненные миграции"},
+		{err: ErrHasFailedMigrations, code: "failed_migrations", en: "some migrations failed, the database requires attention", ru: "некоторые миграции завершились ошибкой, требуется вмешательство"},
+		{err: ErrHasFailedAllowedMigrations, code: "failed_allowed_migrations", en: "some repeatable migrations failed under an allowed failure policy", ru: "некоторые повторяемые миграции завершились допустимой ошибкой"},
+		{err: ErrTargetVersionNotLatest, code: "target_version_not_latest", en: "the target version is lower than the latest migration", ru: "целевая версия ниже версии последней миграции"},
+		{err: ErrTargetBelowSavedVersion, code: "target_below_saved_version", en: "the requested version is below the database version, use downgrade instead", ru: "запрошенная версия ниже версии базы данных, используйте откат"},
+		{err: ErrRowsAffectedBelowExpected, code: "rows_affected_below_expected", en: "the migration affected fewer rows than expected", ru: "миграция изменила меньше строк, чем ожидалось"},
+		{err: ErrDatabaseAheadOfBinary, code: "database_ahead_of_binary", en: "the database contains migrations newer than this binary", ru: "в базе данных есть миграции новее, чем в приложении"},
+		{err: ErrMigrationLocked, code: "migration_locked", en: "the migration is being executed by another instance", ru: "миграция выполняется другим экземпляром приложения"},
+		{err: ErrConflictingRunDirection, code: "conflicting_run_direction", en: "the run conflicts with a recent run in the opposite direction", ru: "запуск противоречит недавнему запуску в обратном направлении"},
+		{err: ErrForeignMigrationsTable, code: "foreign_system_table", en: "a system table exists but was not created by the migrator", ru: "системная таблица существует, но создана не мигратором"},
+		{err: ErrRegistrationsFrozen, code: "registrations_frozen", en: "migrations cannot be registered after migrate in this process", ru: "регистрация миграций после Migrate в этом процессе запрещена"},
+		{err: ErrNotConfirmed, code: "not_confirmed", en: "the operation was not confirmed", ru: "операция не подтверждена"},
+		{err: ErrLibraryTooOld, code: "library_too_old", en: "the migrator library is older than the database requires", ru: "версия библиотеки ниже требуемой базой данных"},
+		{err: ErrLossyConversion, code: "lossy_conversion", en: "the migration cannot be converted without losing behaviour", ru: "миграцию нельзя преобразовать без потери поведения"},
+		{err: ErrPolicyViolation, code: "policy_violation", en: "the migration violates the configured policy profile", ru: "миграция нарушает профиль политик"},
+		{err: ErrServiceNotFound, code: "service_not_found", en: "the service is not registered", ru: "сервис не зарегистрирован"},
+		{err: ErrSchemaDrift, code: "schema_drift", en: "the schema was changed outside of migrations since the previous run", ru: "схема изменена вне миграций после предыдущего запуска"},
+		{err: ErrExtensionUnavailable, code: "extension_unavailable", en: "a database extension required by migrations is not available", ru: "расширение базы данных, необходимое миграциям, недоступно"},
+		{err: ErrRegistrationOverlap, code: "registration_overlap", en: "two services share too many identical migrations, check registrations", ru: "у двух сервисов слишком много одинаковых миграций, проверьте регистрацию"},
+		{err: ErrMigrationTimeout, code: "migration_timeout", en: "the migration did not finish within its timeout", ru: "миграция не завершилась за отведенное время"},
+		{err: ErrAbortedByHook, code: "aborted_by_hook", en: "the migration was aborted by a before migration hook", ru: "миграция прервана обработчиком перед выполнением"},
+		{err: ErrLockNotAcquired, code: "lock_not_acquired", en: "another process holds the migration lock of the service", ru: "блокировка миграций сервиса удерживается другим процессом"},
+		{err: ErrDatabaseAlreadyClaimed, code: "database_already_claimed", en: "the database is already used by another service, check the connection settings", ru: "база данных уже используется другим сервисом, проверьте параметры подключения"},
+		{err: ErrChecksumMismatch, code: "checksum_mismatch", en: "an applied migration was changed after it was executed", ru: "выполненная миграция изменена после выполнения"},
+		{err: ErrLeakedState, code: "leaked_state", en: "the migration left an open transaction or session objects behind", ru: "миграция оставила открытую транзакцию или объекты сессии"},
+		{err: ErrInvalidTransition, code: "invalid_state_transition", en: "the migration cannot change to the requested state from its current state", ru: "миграция не может перейти в требуемое состояние из текущего"},
+		{err: ErrConflictingVersions, code: "conflicting_versions", en: "the version table contains several rows with different versions", ru: "таблица version содержит несколько строк с разными версиями"},
+		{err: ErrMigrationNotFound, code: "migration_not_found", en: "the migration is not registered", ru: "миграция не зарегистрирована"},
+		{err: ErrRegisteredVersionTooLow, code: "registered_version_too_low", en: "a new migration has a lower version than an already saved one", ru: "версия новой миграции ниже версии уже сохраненной миграции"},
+		{err: ErrDependencyNotFound, code: "dependency_not_found", en: "the dependency service is not added", ru: "сервис-зависимость не добавлен"},
+		{err: ErrDependencyNotConnected, code: "dependency_not_connected", en: "the dependency service has no connection", ru: "у сервиса-зависимости нет подключения"},
+		{err: ErrDependencyNotInitialized, code: "dependency_not_initialized", en: "the dependency service has no version table", ru: "у сервиса-зависимости нет таблицы version"},
+		{err: ErrDependencyNotMigrated, code: "dependency_not_migrated", en: "the dependency service has no saved version", ru: "у сервиса-зависимости нет сохраненной версии"},
+		{err: ErrDependencyNotSatisfied, code: "dependency_not_satisfied", en: "the dependency version does not satisfy the requirement", ru: "версия сервиса-зависимости не удовлетворяет требованию"},
+		{err: ErrDuplicateMigration, code: "duplicate_migration", en: "a migration with the same version and type is already registered", ru: "миграция с такой же версией и типом уже зарегистрирована"},
+		{err: ErrPreviousRunInterrupted, code: "previous_run_interrupted", en: "a previous run was interrupted while executing non-transactional migrations", ru: "предыдущий запуск был прерван во время выполнения нетранзакционных миграций"},
+	}
+	require.Len(t, tests, len(reasonErrors), "every library error must be listed")
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			require.Equal(t, tt.code, ReasonOf(tt.err))
+			require.Equal(t, tt.code, ReasonOf(fmt.Errorf("service: service1: %w", tt.err)))
+			require.Equal(t, tt.en, Message(LocaleEN, tt.code))
+			require.Equal(t, tt.ru, Message(LocaleRU, tt.code))
+		})
+	}
+}
+
+func TestReasonOf(t *testing.T) {
+	require.Equal(t, ReasonNone, ReasonOf(nil))
+	require.Equal(t, ReasonNone, ReasonOf(errors.New("no such table: missing")))
+
+	// ошибка, оборачивающая несколько ошибок библиотеки, получает код первой из них в reasonErrors
+	joined := errors.Join(ErrPolicyViolation, ErrHasFailedMigrations)
+	require.Equal(t, ReasonFailedMigrations, ReasonOf(joined))
+
+	dependencyErr := &DependencyError{ServiceName: "users", RequiredVersion: "1.0.1", Err: ErrDependencyNotSatisfied}
+	require.Equal(t, ReasonDependencyNotSatisfied, ReasonOf(fmt.Errorf("migration fail: %w", dependencyErr)))
+}
+
+func TestMessageFallback(t *testing.T) {
+	require.Equal(t, Message(LocaleEN, ReasonMigrationLocked), Message(Locale("de"), ReasonMigrationLocked))
+	require.Equal(t, "unknown_reason", Message(LocaleRU, ReasonCode("unknown_reason")))
+
+	// каталоги языков содержат одинаковые коды
+	require.Len(t, messageCatalog[LocaleRU], len(messageCatalog[LocaleEN]))
+	for code := range messageCatalog[LocaleEN] {
+		require.Contains(t, messageCatalog[LocaleRU], code)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	notFound := fmt.Errorf("%w: version: 1.0.1", ErrMigrationNotFound)
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.Equal(t, "the migration is not registered", m.Describe(notFound))
+
+	m, err = NewMigrationsManager(WithLocale(LocaleRU))
+	require.NoError(t, err)
+	require.Equal(t, "миграция не зарегистрирована", m.Describe(notFound))
+
+	require.Empty(t, m.Describe(nil))
+	require.Equal(t, "no such table: missing", m.Describe(errors.New("no such table: missing")))
+}
@@ -0,0 +1,178 @@
+package db_migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ChecksumEntry описывает расхождение сохраненной и ожидаемой контрольной суммы миграции.
+type ChecksumEntry struct {
+	MigrationType MigrationType
+	Version       string
+	Stored        string
+	Expected      string
+}
+
+// ReconcileReport содержит результат сверки контрольных сумм выполненных миграций.
+type ReconcileReport struct {
+	// Empty - миграции без сохраненной контрольной суммы.
+	Empty []ChecksumEntry
+	// Mismatched - миграции, сохраненная контрольная сумма которых отличается от ожидаемой.
+	Mismatched []ChecksumEntry
+	// Unverifiable - миграции, контрольную сумму которых невозможно вычислить (заданы функцией UpF без CheckSum).
+	Unverifiable []ChecksumEntry
+	// Written - миграции, контрольная сумма которых была записана.
+	Written []ChecksumEntry
+}
+
+type ReconcileOption func(*reconcileOptions)
+
+type reconcileOptions struct {
+	force bool
+}
+
+// WithForceOverwrite разрешает перезаписывать непустые несовпадающие контрольные суммы.
+func WithForceOverwrite() ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.force = true
+	}
+}
+
+// ReconcileChecksums сверяет сохраненные контрольные суммы успешно выполненных миграций с ожидаемыми. Ожидаемая
+// контрольная сумма вычисляется с помощью Migration.CheckSum, а при его отсутствии - как sha256 от Up.
+//
+// При write = true пустые контрольные суммы заполняются ожидаемыми значениями. Несовпадающие непустые значения
+// перезаписываются только с опцией WithForceOverwrite.
+func (m *MigrationManager) ReconcileChecksums(serviceName string, write bool, opts ...ReconcileOption) (ReconcileReport, error) {
+	options := reconcileOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return ReconcileReport{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	report := ReconcileReport{}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return report, nil
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return report, err
+	}
+
+	for i := range savedMigrations {
+		if savedMigrations[i].State != models.StateSuccess {
+			continue
+		}
+
+		migration, found, err := m.findMigration(serviceName, savedMigrations[i])
+		if err != nil {
+			return report, err
+		}
+		if !found {
+			continue
+		}
+
+		entry := ChecksumEntry{
+			MigrationType: MigrationType(savedMigrations[i].Type),
+			Version:       savedMigrations[i].Version.String(),
+			Stored:        savedMigrations[i].Checksum,
+		}
+
+		expected, verifiable := expectedChecksum(service.Db, migration)
+		if !verifiable {
+			report.Unverifiable = append(report.Unverifiable, entry)
+			continue
+		}
+		entry.Expected = expected
+
+		if entry.Stored == entry.Expected {
+			continue
+		}
+
+		overwrite := len(entry.Stored) == 0 || options.force
+		if len(entry.Stored) == 0 {
+			report.Empty = append(report.Empty, entry)
+		} else {
+			report.Mismatched = append(report.Mismatched, entry)
+		}
+
+		if !write || !overwrite {
+			continue
+		}
+
+		err = repository.UpdateMigrationChecksum(service.Db, &savedMigrations[i], expected)
+		if err != nil {
+			return report, err
+		}
+		report.Written = append(report.Written, entry)
+	}
+
+	m.logger.Info(
+		fmt.Sprintf(
+			"checksums reconciled, service: %s, empty: %d, mismatched: %d, unverifiable: %d, written: %d",
+			serviceName, len(report.Empty), len(report.Mismatched), len(report.Unverifiable), len(report.Written),
+		),
+	)
+
+	return report, nil
+}
+
+// expectedChecksum возвращает ожидаемую контрольную сумму миграции. Второе значение равно false, если контрольную
+// сумму вычислить невозможно.
+func expectedChecksum(db *gorm.DB, migration *Migration) (string, bool) {
+	if migration.CheckSum != nil {
+		return migration.CheckSum(db), true
+	}
+
+	// для миграций типа TypeRepeatable без CheckSum сохраняется пустая контрольная сумма, иначе миграция будет
+	// выполняться повторно при каждом запуске
+	if migration.MigrationType == TypeRepeatable {
+		return "", true
+	}
+
+	if migration.hasUpSQL() {
+		checksum, err := migration.upChecksum()
+		if err != nil {
+			return "", false
+		}
+		return checksum, true
+	}
+
+	if len(migration.Content) > 0 {
+		return contentChecksum(migration.Content), true
+	}
+
+	return "", false
+}
+
+// contentChecksum вычисляет sha256 от текста миграции.
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
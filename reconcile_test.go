@@ -0,0 +1,101 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newReconcileTestManager создает менеджер с выполненными миграциями 1.0.0, 1.0.1 (SQL) и 1.0.2 (UpF без CheckSum и
+// Content).
+func newReconcileTestManager(t *testing.T) (*MigrationManager, func() *gorm.DB) {
+	t.Helper()
+
+	m, connect := newTestManager(t, "1.0.2")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+			return db.Exec("alter table a add column c text").Error
+		}},
+	))
+	require.NoError(t, m.Migrate("service1"))
+	return m, connect
+}
+
+func storedChecksum(t *testing.T, db *gorm.DB, version string) string {
+	t.Helper()
+
+	var checksum string
+	require.NoError(t, db.Raw("select checksum from migrations where version = ?", version).Scan(&checksum).Error)
+	return checksum
+}
+
+func TestReconcileChecksumsBackfillsEmpty(t *testing.T) {
+	m, connect := newReconcileTestManager(t)
+	db := connect()
+	require.NoError(t, db.Exec("update migrations set checksum = '' where version = ?", "1.0.1.0").Error)
+
+	expected := ChecksumEntry{MigrationType: TypeVersioned, Version: "1.0.1.0", Expected: contentChecksum("alter table a add column b text")}
+
+	// без write отчет не изменяет базу данных
+	report, err := m.ReconcileChecksums("service1", false)
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumEntry{expected}, report.Empty)
+	require.Empty(t, report.Mismatched)
+	require.Empty(t, report.Written)
+	require.Equal(t, []ChecksumEntry{{MigrationType: TypeVersioned, Version: "1.0.2.0"}}, report.Unverifiable)
+	require.Empty(t, storedChecksum(t, db, "1.0.1.0"))
+
+	report, err = m.ReconcileChecksums("service1", true)
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumEntry{expected}, report.Written)
+	require.Equal(t, expected.Expected, storedChecksum(t, db, "1.0.1.0"))
+
+	report, err = m.ReconcileChecksums("service1", true)
+	require.NoError(t, err)
+	require.Empty(t, report.Empty)
+	require.Empty(t, report.Written)
+}
+
+func TestReconcileChecksumsMismatch(t *testing.T) {
+	m, connect := newReconcileTestManager(t)
+	db := connect()
+	require.NoError(t, db.Exec("update migrations set checksum = 'stale' where version = ?", "1.0.1.0").Error)
+
+	expected := ChecksumEntry{
+		MigrationType: TypeVersioned,
+		Version:       "1.0.1.0",
+		Stored:        "stale",
+		Expected:      contentChecksum("alter table a add column b text"),
+	}
+
+	// несовпадающая контрольная сумма не перезаписывается без WithForceOverwrite
+	report, err := m.ReconcileChecksums("service1", true)
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumEntry{expected}, report.Mismatched)
+	require.Empty(t, report.Written)
+	require.Equal(t, "stale", storedChecksum(t, db, "1.0.1.0"))
+
+	report, err = m.ReconcileChecksums("service1", true, WithForceOverwrite())
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumEntry{expected}, report.Mismatched)
+	require.Equal(t, []ChecksumEntry{expected}, report.Written)
+	require.Equal(t, expected.Expected, storedChecksum(t, db, "1.0.1.0"))
+
+	report, err = m.ReconcileChecksums("service1", false)
+	require.NoError(t, err)
+	require.Empty(t, report.Mismatched)
+}
+
+func TestReconcileChecksumsWithoutMigrationsTable(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.0")
+
+	report, err := m.ReconcileChecksums("service1", true)
+	require.NoError(t, err)
+	require.Equal(t, ReconcileReport{}, report)
+
+	_, err = m.ReconcileChecksums("unknown", true)
+	require.ErrorIs(t, err, ErrServiceNotFound)
+}
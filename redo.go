@@ -0,0 +1,280 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// RedoReport - отчет о выполнении Redo.
+type RedoReport struct {
+	Service string `json:"service"`
+	// Undone - отмененные миграции в порядке отмены
+	Undone []MigrationReportEntry `json:"undone"`
+	// Reapplied - повторно выполненные миграции в порядке выполнения
+	Reapplied []MigrationReportEntry `json:"reapplied"`
+	// FinalVersion - сохраненная версия базы данных после выполнения
+	FinalVersion string        `json:"final_version,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// Redo выполняет RedoContext с фоновым контекстом.
+func (m *MigrationManager) Redo(serviceName string, steps int) (RedoReport, error) {
+	return m.RedoContext(context.Background(), serviceName, steps)
+}
+
+// RedoContext отменяет steps последних миграций типа TypeVersioned и сразу выполняет текущие зарегистрированные
+// версии именно этих миграций; другие невыполненные миграции не выполняются. Обе фазы выполняются в одном соединении
+// под одной межпроцессной блокировкой, поэтому другой процесс не может выполнить миграции между ними. Предназначен
+// для разработки миграций.
+//
+// Возвращает ErrPolicyViolation, если профиль политик запрещает Redo (см. StrictProfile), и ошибку, если какая-либо
+// из отменяемых миграций не может быть отменена; в этих случаях база данных не изменяется. Отчет заполняется и в
+// случае ошибки, содержа миграции, обработанные до ее возникновения.
+func (m *MigrationManager) RedoContext(ctx context.Context, serviceName string, steps int) (RedoReport, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return RedoReport{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if steps <= 0 {
+		return RedoReport{}, m.misuse(fmt.Errorf("redo steps must be positive, got %d", steps))
+	}
+
+	if m.profile.ForbidRedo {
+		return RedoReport{}, fmt.Errorf("%w: redo is forbidden by policy profile, service: %s", ErrPolicyViolation, serviceName)
+	}
+
+	report := RedoReport{
+		Service:   serviceName,
+		Undone:    []MigrationReportEntry{},
+		Reapplied: []MigrationReportEntry{},
+		StartedAt: time.Now(),
+	}
+	err := m.redo(ctx, serviceName, steps, &report)
+	report.Duration = time.Since(report.StartedAt)
+
+	// кешированный отчет Migrate не описывает отмененные и повторно выполненные миграции
+	service.resetRunCache()
+
+	return report, err
+}
+
+func (m *MigrationManager) redo(ctx context.Context, serviceName string, steps int, report *RedoReport) (err error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.Db, err = m.connect(ctx, serviceName, service)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		m.closeAuxiliaryConnections(serviceName)
+		service.DisconnectFunc(service.Db)
+	}()
+
+	m.identifyConnection(service.Db)
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	releaseLock, err := m.acquireProcessLock(ctx, service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+
+	if !repository.HasVersionTable(service.Db) || !repository.HasMigrationsTable(service.Db) {
+		return fmt.Errorf("no migration table or Version table found, cannot perform redo")
+	}
+
+	err = m.initSystemTables(serviceName)
+	if err != nil {
+		return err
+	}
+
+	err = m.claimDatabase(service.Db, serviceName)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderDESC)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkInterrupted(service.Db, serviceName, savedMigrations, false)
+	if err != nil {
+		return err
+	}
+
+	inputs, err := m.planInputs(serviceName, savedMigrations)
+	if err != nil {
+		return err
+	}
+
+	// отменяются последние выполненные миграции независимо от целевой версии сервиса
+	inputs.targetVersion = models.Version{}
+	planner := downgradePlanner{inputs: inputs, steps: steps}
+	plan, err := planner.MakePlan()
+	if err != nil {
+		return err
+	}
+
+	if plan.IsEmpty() {
+		m.logger.Info(fmt.Sprintf("nothing to redo, service: %s", serviceName))
+		report.FinalVersion = savedVersionString(service)
+		return nil
+	}
+
+	err = m.checkDowngradable(serviceName, plan)
+	if err != nil {
+		return err
+	}
+
+	undone := plan.Migrations()
+	versions := migrationVersions(undone)
+
+	err = m.redoDowngrade(ctx, serviceName, savedMigrations, plan, report)
+	if err != nil {
+		return fmt.Errorf("redo failed on downgrade: %w", err)
+	}
+
+	err = m.redoMigrate(ctx, serviceName, undone, report)
+	if err != nil {
+		return fmt.Errorf("redo failed on migrate, undone versions: %s: %w", strings.Join(versions, ", "), err)
+	}
+
+	report.FinalVersion = savedVersionString(service)
+	m.logger.Info(
+		fmt.Sprintf(
+			"redo completed, service: %s, redone: %d (%s), version: %s",
+			serviceName, len(versions), strings.Join(versions, ", "), report.FinalVersion,
+		),
+	)
+
+	return nil
+}
+
+// redoDowngrade отменяет миграции плана отката.
+func (m *MigrationManager) redoDowngrade(
+	ctx context.Context,
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+	plan migrationsPlan,
+	report *RedoReport,
+) (err error) {
+	service, _ := m.service(serviceName)
+
+	run := m.startRun(service.Db, serviceName, DirectionDown)
+	run.PlanHash = planHash(plan)
+	defer func() {
+		m.finishRun(service.Db, serviceName, run, err)
+	}()
+
+	for _, migrationModel := range plan.Migrations() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		migration, ok, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return m.migrationNotFound(serviceName, migrationModel.Type, migrationModel.Version)
+		}
+
+		entry := MigrationReportEntry{
+			Type:      MigrationType(migrationModel.Type),
+			Version:   migrationModel.Version.String(),
+			Outcome:   OutcomeExecuted,
+			StartedAt: time.Now(),
+		}
+
+		err = m.executeDowngrade(serviceName, migrationModel, migration)
+		if err == nil {
+			err = m.saveStateAfterDowngrading(serviceName, savedMigrations, migrationModel, migration)
+		}
+		entry.Duration = time.Since(entry.StartedAt)
+		if err != nil {
+			run.Failed++
+			entry.Outcome = OutcomeFailed
+			entry.Error = err.Error()
+			report.Undone = append(report.Undone, entry)
+			return err
+		}
+
+		run.Applied++
+		report.Undone = append(report.Undone, entry)
+	}
+
+	return nil
+}
+
+// redoMigrate повторно выполняет отмененные миграции undone.
+func (m *MigrationManager) redoMigrate(ctx context.Context, serviceName string, undone []models.MigrationModel, report *RedoReport) (err error) {
+	service, _ := m.service(serviceName)
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return err
+	}
+
+	saved := make(map[uint32]models.MigrationModel, len(savedMigrations))
+	for _, migrationModel := range savedMigrations {
+		saved[migrationModel.Id] = migrationModel
+	}
+
+	// миграции выполняются в порядке, обратном порядку отмены, в сохраненном после отмены состоянии
+	plan := newMigrationsPlan()
+	for _, migrationModel := range undone {
+		plan.migrationsToRun.PushFront(saved[migrationModel.Id])
+	}
+
+	run := m.startRun(service.Db, serviceName, DirectionUp)
+	run.PlanHash = planHash(plan)
+	defer func() {
+		m.finishRun(service.Db, serviceName, run, err)
+	}()
+
+	for _, migrationModel := range plan.Migrations() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		outcome, err := m.applyMigration(serviceName, service.Db, savedMigrations, migrationModel, RunOptions{})
+		run.Applied += outcome.applied
+		run.Failed += outcome.failed
+		run.RowsAffected += outcome.rowsAffected
+		report.Reapplied = append(report.Reapplied, outcome.entries...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func savedVersionString(service *ServiceInfo) string {
+	version, err := repository.GetVersion(service.Db)
+	if err != nil {
+		return ""
+	}
+	return version.String()
+}
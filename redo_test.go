@@ -0,0 +1,125 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func redoTestMigrations(up102 string, down102 string) []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table b(id int)", Down: "drop table b"},
+		{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: up102, Down: down102},
+	}
+}
+
+// TestRedoAppliesEditedMigration проверяет, что Redo отменяет миграцию, выполненную до ее изменения, и выполняет
+// измененный текст, не выполняя другие ожидающие миграции.
+func TestRedoAppliesEditedMigration(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, previous.Register("service1", redoTestMigrations("alter table b add column c text", "alter table b drop column c")...))
+	require.NoError(t, previous.Migrate("service1"))
+
+	connects := 0
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", func() *gorm.DB {
+		connects++
+		return connect()
+	}, disconnect, "1.0.3"))
+	// миграция 1.0.2 изменена разработчиком: Down отменяет выполненный ранее текст, Up добавляет другую колонку
+	require.NoError(t, m.Register("service1", redoTestMigrations("alter table b add column d text", "alter table b drop column c")...))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "create table pending(id int)"},
+	))
+
+	report, err := m.Redo("service1", 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, connects, "both phases must use one connection")
+
+	require.Equal(t, "service1", report.Service)
+	require.Len(t, report.Undone, 1)
+	require.Equal(t, "1.0.2.0", report.Undone[0].Version)
+	require.Equal(t, OutcomeExecuted, report.Undone[0].Outcome)
+	require.Len(t, report.Reapplied, 1)
+	require.Equal(t, "1.0.2.0", report.Reapplied[0].Version)
+	require.Equal(t, OutcomeExecuted, report.Reapplied[0].Outcome)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+
+	db := connect()
+	require.False(t, db.Migrator().HasColumn("b", "c"))
+	require.True(t, db.Migrator().HasColumn("b", "d"))
+	require.False(t, db.Migrator().HasTable("pending"), "pending migration must not be applied by redo")
+
+	_, err = repository.GetLock(db, "service1")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// следующий Migrate выполняет только ожидающую миграцию
+	report2, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Len(t, report2.Entries, 1)
+	require.Equal(t, "1.0.3.0", report2.Entries[0].Version)
+}
+
+func TestRedoSeveralSteps(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	require.NoError(t, m.Register("service1", redoTestMigrations("alter table b add column c text", "alter table b drop column c")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	report, err := m.Redo("service1", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.0.2.0", "1.0.1.0"}, reportVersions(report.Undone))
+	require.Equal(t, []string{"1.0.1.0", "1.0.2.0"}, reportVersions(report.Reapplied))
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+	require.True(t, connect().Migrator().HasColumn("b", "c"))
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+}
+
+func TestRedoRequiresDown(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	require.NoError(t, m.Register("service1", redoTestMigrations("alter table b add column c text", "")...))
+	require.NoError(t, m.Migrate("service1"))
+
+	report, err := m.Redo("service1", 1)
+	require.ErrorContains(t, err, "cannot be downgraded")
+	require.Empty(t, report.Undone)
+	require.True(t, connect().Migrator().HasColumn("b", "c"))
+}
+
+func reportVersions(entries []MigrationReportEntry) []string {
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+	}
+	return versions
+}
+
+// TestRedoResetsRunCache проверяет, что после Redo кешированный с WithRunOncePerProcess отчет Migrate не
+// возвращается: миграции в нем отменены и выполнены повторно.
+func TestRedoResetsRunCache(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2", WithRunOncePerProcess())
+	require.NoError(t, m.Register("service1", redoTestMigrations("alter table b add column c text", "alter table b drop column c")...))
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Entries, 3)
+
+	_, err = m.Redo("service1", 1)
+	require.NoError(t, err)
+
+	report, err = m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Empty(t, report.Entries)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+}
@@ -0,0 +1,261 @@
+package db_migrator
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"gorm.io/gorm"
+)
+
+// migrationFileRegexp - имя файла миграции: префикс типа (V, B, R), версия с разделителем "_", описание после "__" и
+// для TypeVersioned необязательный суффикс направления.
+var migrationFileRegexp = regexp.MustCompile(`^([VBR])(\d+_\d+_\d+_\d+)?__(.+?)(\.up|\.down)?\.sql$`)
+
+var migrationFilePrefixes = map[string]MigrationType{
+	"V": TypeVersioned,
+	"B": TypeBaseline,
+	"R": TypeRepeatable,
+}
+
+// RegisterFS регистрирует миграции из файлов каталога dir файловой системы fsys (например, embed.FS), включая
+// вложенные каталоги. Имена файлов:
+//   - V1_0_0_0__create_users.up.sql и V1_0_0_0__create_users.down.sql (или V1_0_0_0__create_users.sql без Down) -
+//     миграция TypeVersioned;
+//   - B1_0_0_0__baseline.sql - миграция TypeBaseline;
+//   - R1_0_0_0__refresh_views.sql - миграция TypeRepeatable, контрольная сумма которой вычисляется по содержимому
+//     файла, поэтому миграция выполняется повторно при его изменении.
+//
+// Описание миграции получается из имени файла заменой "_" на пробелы. Параметры миграции задаются комментариями
+// в начале файла up (см. parseMigrationDirectives): "-- migrator:ticket=OPS-1" для Migration.Ticket и
+// "-- migrator:min-version=1.0.2.0 max-version=1.0.5.0" для Migration.MinVersion и Migration.MaxVersion миграций
+// TypeRepeatable. Миграции выполняются в транзакции, opts
+// применяются к каждой миграции. Файлы без расширения .sql пропускаются, некорректные имена файлов и повторяющиеся
+// версии приводят к ошибке до регистрации какой-либо миграции. Миграции регистрируются в порядке версий, как при
+// вызове Register.
+func (m *MigrationManager) RegisterFS(serviceName string, fsys fs.FS, dir string, opts ...MigrationOption) error {
+	migrations, err := readMigrationFiles(fsys, dir)
+	if err != nil {
+		return m.misuse(err)
+	}
+
+	for i := range migrations {
+		for _, opt := range opts {
+			opt(&migrations[i])
+		}
+	}
+
+	return m.Register(serviceName, migrations...)
+}
+
+// migrationFile - разобранное имя файла миграции.
+type migrationFile struct {
+	path          string
+	migrationType MigrationType
+	version       string
+	description   string
+	down          bool
+}
+
+func parseMigrationFile(filePath string) (migrationFile, error) {
+	match := migrationFileRegexp.FindStringSubmatch(path.Base(filePath))
+	if match == nil {
+		return migrationFile{}, fmt.Errorf("invalid migration file name: %s", filePath)
+	}
+
+	file := migrationFile{
+		path:          filePath,
+		migrationType: migrationFilePrefixes[match[1]],
+		version:       strings.ReplaceAll(match[2], "_", "."),
+		description:   strings.TrimSpace(strings.ReplaceAll(match[3], "_", " ")),
+		down:          match[4] == ".down",
+	}
+
+	// миграции идентифицируются типом и версией, поэтому версия обязательна, в том числе для TypeRepeatable
+	if len(file.version) == 0 {
+		return migrationFile{}, fmt.Errorf("migration file name must contain version: %s", filePath)
+	}
+	if len(file.description) == 0 {
+		return migrationFile{}, fmt.Errorf("migration file name must contain description: %s", filePath)
+	}
+	if len(match[4]) > 0 && file.migrationType != TypeVersioned {
+		return migrationFile{}, fmt.Errorf("only versioned migrations may have up and down files: %s", filePath)
+	}
+
+	return file, nil
+}
+
+// migrationDirectivePrefix - префикс комментария с параметрами миграции в начале файла.
+const migrationDirectivePrefix = "-- migrator:"
+
+// migrationDirectives - параметры миграции, заданные комментариями в начале файла.
+type migrationDirectives struct {
+	ticket     string
+	minVersion string
+	maxVersion string
+}
+
+func (d migrationDirectives) empty() bool {
+	return d == migrationDirectives{}
+}
+
+// parseMigrationDirectives разбирает комментарии вида "-- migrator:ключ=значение ключ=значение" в начале содержимого
+// файла filePath, до первого выражения. Комментарий "-- migrator:optional" относится к следующему за ним выражению и
+// завершает заголовок. Неизвестные ключи и некорректные значения приводят к ошибке.
+func parseMigrationDirectives(filePath string, content string) (migrationDirectives, error) {
+	var directives migrationDirectives
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") || strings.HasPrefix(line, optionalStatementMarker) {
+			break
+		}
+		if !strings.HasPrefix(line, migrationDirectivePrefix) {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(line, migrationDirectivePrefix)) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || len(value) == 0 {
+				return migrationDirectives{}, fmt.Errorf("malformed migration directive %q: %s", field, filePath)
+			}
+
+			switch key {
+			case "ticket":
+				directives.ticket = value
+			case "min-version", "max-version":
+				if _, err := models.ParseVersion(value); err != nil {
+					return migrationDirectives{}, fmt.Errorf("invalid %s %q: %s: %w", key, value, filePath, err)
+				}
+				if key == "min-version" {
+					directives.minVersion = value
+				} else {
+					directives.maxVersion = value
+				}
+			default:
+				return migrationDirectives{}, fmt.Errorf("unknown migration directive %q: %s", key, filePath)
+			}
+		}
+	}
+	return directives, nil
+}
+
+// validateMigrationDirectives проверяет, что параметры directives допустимы для файла file.
+func validateMigrationDirectives(file migrationFile, directives migrationDirectives) error {
+	if file.down && !directives.empty() {
+		return fmt.Errorf("migration directives are allowed only in up files: %s", file.path)
+	}
+	if file.migrationType != TypeRepeatable && (len(directives.minVersion) > 0 || len(directives.maxVersion) > 0) {
+		return fmt.Errorf("min-version and max-version are allowed only for repeatable migrations: %s", file.path)
+	}
+	return nil
+}
+
+// readMigrationFiles читает миграции из каталога dir, объединяя файлы up и down одной миграции.
+func readMigrationFiles(fsys fs.FS, dir string) ([]Migration, error) {
+	type migrationKey struct {
+		migrationType MigrationType
+		version       string
+	}
+
+	migrations := make(map[migrationKey]*Migration)
+	sources := make(map[migrationKey]map[bool]string)
+
+	err := fs.WalkDir(fsys, dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(filePath) != ".sql" {
+			return nil
+		}
+
+		file, err := parseMigrationFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		directives, err := parseMigrationDirectives(filePath, string(content))
+		if err != nil {
+			return err
+		}
+		err = validateMigrationDirectives(file, directives)
+		if err != nil {
+			return err
+		}
+
+		key := migrationKey{migrationType: file.migrationType, version: file.version}
+		if sources[key] == nil {
+			sources[key] = make(map[bool]string)
+		}
+		if previous, ok := sources[key][file.down]; ok {
+			return fmt.Errorf("duplicate migration (type: %s, version: %s): %s and %s", key.migrationType, key.version, previous, filePath)
+		}
+		sources[key][file.down] = filePath
+
+		migration, ok := migrations[key]
+		if !ok {
+			migration = &Migration{
+				MigrationType:   file.migrationType,
+				Version:         file.version,
+				Description:     file.description,
+				IsTransactional: true,
+			}
+			migrations[key] = migration
+		}
+		if migration.Description != file.description {
+			return fmt.Errorf("up and down files of migration %s have different descriptions: %s", file.version, filePath)
+		}
+
+		if file.down {
+			migration.Down = string(content)
+		} else {
+			migration.Up = string(content)
+			migration.Ticket = directives.ticket
+			migration.MinVersion = directives.minVersion
+			migration.MaxVersion = directives.maxVersion
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Migration, 0, len(migrations))
+	for key, migration := range migrations {
+		if len(migration.Up) == 0 {
+			return nil, fmt.Errorf("migration (type: %s, version: %s) has no up file or it is empty", key.migrationType, key.version)
+		}
+
+		if migration.MigrationType == TypeRepeatable {
+			checksum := contentChecksum(migration.Up)
+			migration.CheckSum = func(*gorm.DB) string {
+				return checksum
+			}
+		}
+
+		result = append(result, *migration)
+	}
+
+	// формат версии проверен migrationFileRegexp
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := models.ParseVersion(result[i].Version)
+		b, _ := models.ParseVersion(result[j].Version)
+		if !a.Equals(b) {
+			return b.MoreThan(a)
+		}
+		return result[i].MigrationType < result[j].MigrationType
+	})
+
+	return result, nil
+}
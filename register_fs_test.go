@@ -0,0 +1,238 @@
+package db_migrator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigrationFile(t *testing.T) {
+	valid := []struct {
+		name string
+		file migrationFile
+	}{
+		{name: "V1_0_0_0__create_users.up.sql", file: migrationFile{migrationType: TypeVersioned, version: "1.0.0.0", description: "create users"}},
+		{name: "V1_0_0_0__create_users.down.sql", file: migrationFile{migrationType: TypeVersioned, version: "1.0.0.0", description: "create users", down: true}},
+		{name: "V1_2_10_0__add_index.sql", file: migrationFile{migrationType: TypeVersioned, version: "1.2.10.0", description: "add index"}},
+		{name: "B1_0_0_0__baseline.sql", file: migrationFile{migrationType: TypeBaseline, version: "1.0.0.0", description: "baseline"}},
+		{name: "R1_0_0_1__refresh_views.sql", file: migrationFile{migrationType: TypeRepeatable, version: "1.0.0.1", description: "refresh views"}},
+		{name: "migrations/v1/V1_0_1_0__a.sql", file: migrationFile{migrationType: TypeVersioned, version: "1.0.1.0", description: "a"}},
+	}
+	for _, test := range valid {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := parseMigrationFile(test.name)
+			require.NoError(t, err)
+			test.file.path = test.name
+			require.Equal(t, test.file, file)
+		})
+	}
+
+	rejected := []struct {
+		name string
+		err  string
+	}{
+		{name: "V__create_users.sql", err: "must contain version"},
+		{name: "V1_0_0_0__.sql", err: "invalid migration file name"},
+		{name: "V1_0_0__create_users.sql", err: "invalid migration file name"},
+		{name: "V1_0_0_0_create_users.sql", err: "invalid migration file name"},
+		{name: "X1_0_0_0__create_users.sql", err: "invalid migration file name"},
+		{name: "v1_0_0_0__create_users.sql", err: "invalid migration file name"},
+		{name: "R1_0_0_0__refresh_views.up.sql", err: "only versioned migrations"},
+		{name: "B1_0_0_0__baseline.down.sql", err: "only versioned migrations"},
+		{name: "V1_0_0_0_____.sql", err: "must contain description"},
+	}
+	for _, test := range rejected {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseMigrationFile(test.name)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestRegisterFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/B1_0_0_0__baseline.sql":        {Data: []byte("create table a(id int)")},
+		"migrations/V1_0_1_0__add_b.up.sql":        {Data: []byte("alter table a add column b text")},
+		"migrations/V1_0_1_0__add_b.down.sql":      {Data: []byte("alter table a drop column b")},
+		"migrations/views/R1_0_1_0__view.sql":      {Data: []byte("create view if not exists v as select id from a")},
+		"migrations/README.md":                     {Data: []byte("not a migration")},
+		"other/V9_0_0_0__outside_of_directory.sql": {Data: []byte("select 1")},
+	}
+
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.RegisterFS("service1", fsys, "migrations"))
+
+	plan, err := m.Plan("service1")
+	require.NoError(t, err)
+	require.Len(t, plan, 3)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestRegisterFSVersionRangeDirective(t *testing.T) {
+	fsys := fstest.MapFS{
+		"B1_0_0_0__baseline.sql": {Data: []byte("create table a(id int)")},
+		"V1_0_1_0__add_b.sql":    {Data: []byte("alter table a add column b text")},
+		"R1_0_0_1__old_view.sql": {Data: []byte(
+			"-- view of the table removed in 1.0.1.0\n-- migrator:max-version=1.0.0.0\ncreate view old_v as select id from a",
+		)},
+		"R1_0_0_2__new_view.sql": {Data: []byte(
+			"-- migrator:min-version=1.0.1.0 max-version=1.0.5.0\ncreate view if not exists new_v as select b from a",
+		)},
+	}
+
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.RegisterFS("service1", fsys, "."))
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+	var views []string
+	require.NoError(t, db.Raw("select name from sqlite_master where type = 'view'").Scan(&views).Error)
+	require.Equal(t, []string{"new_v"}, views)
+
+	var reasons []string
+	require.NoError(t, db.Raw(
+		"select reason from migration_state_history where type = ? and to_state = ?", "repeatable", "skipped",
+	).Scan(&reasons).Error)
+	require.Equal(t, []string{
+		"skipped (above_max_version): database version 1.0.1.0 is higher than max version 1.0.0.0",
+	}, reasons)
+}
+
+func TestParseMigrationDirectives(t *testing.T) {
+	valid := map[string]migrationDirectives{
+		"create table a(id int)":                                       {},
+		"-- migrator:min-version=1.0.1\ncreate view v as select 1":     {minVersion: "1.0.1"},
+		"\n-- comment\r\n-- migrator:max-version=1.0.2.0\r\nselect 1":  {maxVersion: "1.0.2.0"},
+		"-- migrator:min-version=1.0.1 max-version=1.0.2\nselect 1":    {minVersion: "1.0.1", maxVersion: "1.0.2"},
+		"select 1;\n-- migrator:min-version=bad\nselect 2":             {},
+		"-- migrator:optional\nselect 1;\n-- migrator:min-version=bad": {},
+		"-- migrator:ticket=OPS-1 min-version=1.0.1\nselect 1":         {ticket: "OPS-1", minVersion: "1.0.1"},
+	}
+	for content, expected := range valid {
+		directives, err := parseMigrationDirectives("R1_0_0_0__v.sql", content)
+		require.NoError(t, err, content)
+		require.Equal(t, expected, directives, content)
+	}
+
+	rejected := map[string]string{
+		"-- migrator:min-version=1.x\nselect 1":       `invalid min-version "1.x": R1_0_0_0__v.sql`,
+		"-- migrator:max-version=latest\nselect 1":    `invalid max-version "latest": R1_0_0_0__v.sql`,
+		"-- migrator:min-version\nselect 1":           `malformed migration directive "min-version": R1_0_0_0__v.sql`,
+		"-- migrator:min-version= \nselect 1":         `malformed migration directive "min-version="`,
+		"-- migrator:since-version=1.0.0.0\nselect 1": `unknown migration directive "since-version": R1_0_0_0__v.sql`,
+	}
+	for content, expected := range rejected {
+		_, err := parseMigrationDirectives("R1_0_0_0__v.sql", content)
+		require.ErrorContains(t, err, expected, content)
+	}
+}
+
+func TestRegisterFSTicketDirective(t *testing.T) {
+	fsys := fstest.MapFS{
+		"B1_0_0_0__baseline.sql":   {Data: []byte("-- migrator:ticket=OPS-1\ncreate table a(id int)")},
+		"V1_0_1_0__add_b.up.sql":   {Data: []byte("-- migrator:ticket=OPS-2\nalter table a add column b text")},
+		"V1_0_1_0__add_b.down.sql": {Data: []byte("alter table a drop column b")},
+	}
+
+	m, _ := newTestManager(t, "1.0.1", WithPolicyProfile(StrictProfile()))
+	require.NoError(t, m.RegisterFS("service1", fsys, "."))
+
+	service, ok := m.service("service1")
+	require.True(t, ok)
+	tickets := make([]string, 0)
+	for _, migration := range service.registeredMigrations {
+		tickets = append(tickets, migration.Ticket)
+	}
+	require.Equal(t, []string{"OPS-1", "OPS-2"}, tickets)
+	require.NoError(t, m.Migrate("service1"))
+
+	// без директивы ticket строгий профиль отклоняет миграцию
+	delete(fsys, "B1_0_0_0__baseline.sql")
+	fsys["V1_0_2_0__add_c.up.sql"] = &fstest.MapFile{Data: []byte("alter table a add column c text")}
+	fsys["V1_0_2_0__add_c.down.sql"] = &fstest.MapFile{Data: []byte("alter table a drop column c")}
+	m, _ = newTestManager(t, "1.0.2", WithPolicyProfile(StrictProfile()))
+	require.ErrorIs(t, m.RegisterFS("service1", fsys, "."), ErrPolicyViolation)
+}
+
+func TestRegisterFSRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{
+			name: "invalid name",
+			files: fstest.MapFS{
+				"B1_0_0_0__baseline.sql": {Data: []byte("create table a(id int)")},
+				"V1_0_1__add_b.sql":      {Data: []byte("alter table a add column b text")},
+			},
+			err: "invalid migration file name",
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"V1_0_1_0__add_b.sql":      {Data: []byte("alter table a add column b text")},
+				"v2/V1_0_1_0__add_b.sql":   {Data: []byte("alter table a add column b text")},
+				"B1_0_0_0__baseline.sql":   {Data: []byte("create table a(id int)")},
+				"V1_0_2_0__add_c.up.sql":   {Data: []byte("alter table a add column c text")},
+				"V1_0_2_0__add_c.down.sql": {Data: []byte("alter table a drop column c")},
+			},
+			err: "duplicate migration",
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"V1_0_1_0__add_b.down.sql": {Data: []byte("alter table a drop column b")},
+			},
+			err: "has no up file",
+		},
+		{
+			name: "different descriptions",
+			files: fstest.MapFS{
+				"V1_0_1_0__add_b.up.sql":    {Data: []byte("alter table a add column b text")},
+				"V1_0_1_0__drop_b.down.sql": {Data: []byte("alter table a drop column b")},
+			},
+			err: "different descriptions",
+		},
+		{
+			name: "malformed version range directive",
+			files: fstest.MapFS{
+				"B1_0_0_0__baseline.sql": {Data: []byte("create table a(id int)")},
+				"R1_0_0_1__view.sql":     {Data: []byte("-- migrator:min-version=1.x\ncreate view v as select id from a")},
+			},
+			err: `invalid min-version "1.x": R1_0_0_1__view.sql`,
+		},
+		{
+			name: "version range directive in versioned migration",
+			files: fstest.MapFS{
+				"V1_0_1_0__add_b.sql": {Data: []byte("-- migrator:min-version=1.0.0.0\nalter table a add column b text")},
+			},
+			err: "allowed only for repeatable migrations: V1_0_1_0__add_b.sql",
+		},
+		{
+			name: "directive in down file",
+			files: fstest.MapFS{
+				"V1_0_1_0__add_b.up.sql":   {Data: []byte("alter table a add column b text")},
+				"V1_0_1_0__add_b.down.sql": {Data: []byte("-- migrator:max-version=1.0.0.0\nalter table a drop column b")},
+			},
+			err: "allowed only in up files: V1_0_1_0__add_b.down.sql",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.1")
+
+			require.ErrorContains(t, m.RegisterFS("service1", test.files, "."), test.err)
+
+			plan, err := m.Plan("service1")
+			require.NoError(t, err)
+			require.Empty(t, plan, "no migration must be registered")
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func registeredVersions(t *testing.T, m *MigrationManager, serviceName string) []string {
+	t.Helper()
+
+	service, ok := m.service(serviceName)
+	require.True(t, ok)
+
+	versions := make([]string, 0, len(service.registeredMigrations))
+	for _, migration := range service.registeredMigrations {
+		versions = append(versions, string(migration.MigrationType)+" "+migration.Version)
+	}
+	return versions
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []ManagerOption
+		first      []Migration
+		second     []Migration
+		wantErr    error
+		registered []string
+	}{
+		{
+			name:       "duplicate of registered migration",
+			first:      []Migration{{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 1"}},
+			second:     []Migration{{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 2"}},
+			wantErr:    ErrDuplicateMigration,
+			registered: []string{"versioned 1.0.0"},
+		},
+		{
+			name:  "duplicate rejects whole batch",
+			first: []Migration{{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 1"}},
+			second: []Migration{
+				{MigrationType: TypeVersioned, Version: "1.0.1", Up: "select 2"},
+				{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 3"},
+			},
+			wantErr:    ErrDuplicateMigration,
+			registered: []string{"versioned 1.0.0"},
+		},
+		{
+			name: "duplicate within batch",
+			second: []Migration{
+				{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 1"},
+				{MigrationType: TypeVersioned, Version: "1.0.0.0", Up: "select 2"},
+			},
+			wantErr:    ErrDuplicateMigration,
+			registered: []string{},
+		},
+		{
+			name:  "same version with different type",
+			first: []Migration{{MigrationType: TypeBaseline, Version: "1.0.0", Up: "select 1"}},
+			second: []Migration{
+				{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 2"},
+			},
+			registered: []string{"baseline 1.0.0", "versioned 1.0.0"},
+		},
+		{
+			name:  "allowed duplicates are skipped",
+			opts:  []ManagerOption{WithAllowDuplicateRegistration()},
+			first: []Migration{{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 1"}},
+			second: []Migration{
+				{MigrationType: TypeVersioned, Version: "1.0.0", Up: "select 2"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", Up: "select 3"},
+				{MigrationType: TypeVersioned, Version: "1.0.1", Up: "select 4"},
+			},
+			registered: []string{"versioned 1.0.0", "versioned 1.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMigrationsManager(tt.opts...)
+			require.NoError(t, err)
+
+			require.NoError(t, m.Register("service1", tt.first...))
+
+			err = m.Register("service1", tt.second...)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.registered, registeredVersions(t, m, "service1"))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package db_migrator
+
+import (
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+type repairConfig struct {
+	version            *models.Version
+	recomputeChecksums bool
+}
+
+type RepairOption func(*repairConfig) error
+
+// RepairVersion ограничивает Repair миграциями с версией version.
+func RepairVersion(version string) RepairOption {
+	return func(c *repairConfig) error {
+		parsed, err := models.ParseVersion(version)
+		if err != nil {
+			return err
+		}
+		c.version = &parsed
+		return nil
+	}
+}
+
+// RepairRecomputeChecksums сохраняет для исправляемых миграций типа TypeRepeatable контрольные суммы текущих
+// зарегистрированных миграций. Такие миграции не выполняются повторно, пока контрольная сумма не изменится, поэтому
+// опция используется, если результат миграции был применен вручную.
+func RepairRecomputeChecksums() RepairOption {
+	return func(c *repairConfig) error {
+		c.recomputeChecksums = true
+		return nil
+	}
+}
+
+// RepairReport - результат Repair.
+type RepairReport struct {
+	Service string `json:"service"`
+	// Repaired - исправленные миграции в состоянии до исправления
+	Repaired []MigrationStatus `json:"repaired"`
+}
+
+// Repair переводит миграции в состояниях StateFailure и StateRunning (прерванные, см. ErrPreviousRunInterrupted) в
+// StateRegistered и сбрасывает счетчик ошибок, чтобы следующий вызов Migrate выполнил их повторно. Используется после
+// ручного исправления базы данных.
+//
+// Изменяется только таблица migrations: схема базы данных не затрагивается, в том числе если нетранзакционная
+// миграция была выполнена частично. Прогресс такой миграции сохраняется и может быть использован
+// RunOptions.ResumeFromLastStatement.
+func (m *MigrationManager) Repair(serviceName string, opts ...RepairOption) (RepairReport, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return RepairReport{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	config := repairConfig{}
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return RepairReport{}, err
+		}
+	}
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	report := RepairReport{Service: serviceName, Repaired: []MigrationStatus{}}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return report, nil
+	}
+
+	err := repository.CreateStateHistoryTable(service.Db)
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	for i := range savedMigrations {
+		migrationModel := savedMigrations[i]
+		if migrationModel.State != models.StateFailure && migrationModel.State != models.StateRunning {
+			continue
+		}
+		if config.version != nil && !migrationModel.Version.Equals(*config.version) {
+			continue
+		}
+
+		err = repository.TransitionState(service.Db, &migrationModel, models.StateRegistered, "repaired")
+		if err != nil {
+			return report, err
+		}
+
+		err = repository.UpdateMigrationFailedAttempts(service.Db, &migrationModel, 0)
+		if err != nil {
+			return report, err
+		}
+
+		if config.recomputeChecksums && migrationModel.Type == string(TypeRepeatable) {
+			migration, found, err := m.findMigration(serviceName, migrationModel)
+			if err != nil {
+				return report, err
+			}
+			if found {
+				err = repository.UpdateMigrationChecksum(service.Db, &migrationModel, migration.checksum(service.Db))
+				if err != nil {
+					return report, err
+				}
+			}
+		}
+
+		m.logger.Info(
+			fmt.Sprintf(
+				"migration (type: %s, Version: %s) repaired, service: %s",
+				migrationModel.Type, migrationModel.Version, serviceName,
+			),
+		)
+		m.audit(AuditEvent{
+			Event:         AuditMigrationRepaired,
+			Service:       serviceName,
+			MigrationType: migrationModel.Type,
+			Version:       migrationModel.Version.String(),
+		})
+
+		report.Repaired = append(report.Repaired, newSavedMigrationInfo(savedMigrations[i]))
+	}
+
+	return report, nil
+}
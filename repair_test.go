@@ -0,0 +1,92 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+	))
+	require.Error(t, m.Migrate("service1"))
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.True(t, status.HasFailed)
+
+	// таблица создается вручную, после чего миграция выполняется повторно
+	db := connect()
+	require.NoError(t, db.Exec("create table missing(id int)").Error)
+
+	report, err := m.Repair("service1")
+	require.NoError(t, err)
+	require.Equal(t, "service1", report.Service)
+	require.Len(t, report.Repaired, 1)
+	require.Equal(t, "1.0.1.0", report.Repaired[0].Version)
+	require.Equal(t, "failure", report.Repaired[0].State)
+
+	status, err = m.Status("service1")
+	require.NoError(t, err)
+	require.False(t, status.HasFailed)
+	require.True(t, status.HasPending)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, db.Migrator().HasColumn("missing", "b"))
+
+	report, err = m.Repair("service1")
+	require.NoError(t, err)
+	require.Empty(t, report.Repaired)
+}
+
+func TestRepairVersion(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+	))
+	require.Error(t, m.Migrate("service1"))
+
+	report, err := m.Repair("service1", RepairVersion("1.0.2"))
+	require.NoError(t, err)
+	require.Empty(t, report.Repaired)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.True(t, status.HasFailed)
+
+	report, err = m.Repair("service1", RepairVersion("1.0.1"))
+	require.NoError(t, err)
+	require.Len(t, report.Repaired, 1)
+
+	_, err = m.Repair("service1", RepairVersion("not a version"))
+	require.Error(t, err)
+}
+
+func TestRepairPartiallyApplied(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.1",
+			Up:            "create table partial(id int); alter table missing add column b text",
+		},
+	))
+	require.Error(t, m.Migrate("service1"))
+
+	db := connect()
+	require.True(t, db.Migrator().HasTable("partial"))
+
+	_, err := m.Repair("service1")
+	require.NoError(t, err)
+	// схема не изменяется: выполненная часть миграции остается
+	require.True(t, db.Migrator().HasTable("partial"))
+
+	require.NoError(t, db.Exec("create table missing(id int)").Error)
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{ResumeFromLastStatement: true}))
+	require.True(t, db.Migrator().HasColumn("missing", "b"))
+}
@@ -0,0 +1,116 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRepeatableInVersionRange(t *testing.T) {
+	migration := &Migration{MigrationType: TypeRepeatable, Version: "1.0.0", MinVersion: "1.2.0", MaxVersion: "1.4.0"}
+
+	tests := []struct {
+		version string
+		inRange bool
+		code    ReasonCode
+	}{
+		{version: "1.1.9", inRange: false, code: ReasonBelowMinVersion},
+		{version: "1.2.0", inRange: true, code: ReasonNone},
+		{version: "1.3.0", inRange: true, code: ReasonNone},
+		{version: "1.4.0", inRange: true, code: ReasonNone},
+		{version: "1.4.0.1", inRange: false, code: ReasonAboveMaxVersion},
+		{version: "2.0.0", inRange: false, code: ReasonAboveMaxVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			version, err := models.ParseVersion(tt.version)
+			require.NoError(t, err)
+
+			inRange, code, _, err := repeatableInVersionRange(version, migration)
+			require.NoError(t, err)
+			require.Equal(t, tt.inRange, inRange)
+			require.Equal(t, tt.code, code)
+		})
+	}
+
+	inRange, _, _, err := repeatableInVersionRange(models.Version{}, &Migration{MigrationType: TypeRepeatable})
+	require.NoError(t, err)
+	require.True(t, inRange)
+}
+
+func TestCheckFulfillmentSkippedAndFailedAllowedRepeatables(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{
+			MigrationType:   TypeRepeatable,
+			Version:         "1.0.0",
+			Description:     "view of the removed table",
+			IsTransactional: true,
+			MaxVersion:      "1.0.0",
+			Up:              "create view if not exists v as select id from a",
+		},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, "%v", reason)
+	require.NoError(t, reason)
+
+	var historyReasons []string
+	require.NoError(t, connect().Raw(
+		"select reason from migration_state_history where type = ? and to_state = ?", "repeatable", "skipped",
+	).Scan(&historyReasons).Error)
+	require.Equal(t, []string{
+		"skipped (above_max_version): database version 1.0.1.0 is higher than max version 1.0.0.0",
+	}, historyReasons)
+
+	require.NoError(t, m.AllowLateRegistrations("service1"))
+	require.NoError(t, m.Register("service1", Migration{
+		MigrationType:   TypeRepeatable,
+		Version:         "1.0.1",
+		IsTransactional: true,
+		OnFailure:       FailureWarnAndContinue,
+		CheckSum:        func(*gorm.DB) string { return "v1" },
+		Up:              "insert into missing_table values (1)",
+	}))
+	require.NoError(t, m.Migrate("service1"))
+
+	reason, ok, err = m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.ErrorIs(t, reason, ErrHasFailedAllowedMigrations)
+}
+
+func TestAppliedRepeatableLeavesVersionRange(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	migrations := []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, MaxVersion: "1.0.0", Up: "create view if not exists v as select id from a", CheckSum: func(*gorm.DB) string { return "v1" }},
+	}
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.0"))
+	require.NoError(t, previous.Register("service1", migrations...))
+	require.NoError(t, previous.Migrate("service1"))
+
+	// после обновления выполненная миграция типа repeatable оказывается вне диапазона версий
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, m.Register("service1", append(migrations,
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	)...))
+	require.NoError(t, m.Migrate("service1"))
+
+	var state string
+	require.NoError(t, connect().Raw("select state from migrations where type = ?", "repeatable").Scan(&state).Error)
+	require.Equal(t, string(models.StateSkipped), state)
+}
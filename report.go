@@ -0,0 +1,180 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+type MigrationOutcome string
+
+const (
+	// OutcomeExecuted - миграция выполнена успешно или ее ошибка допущена IsAllowFailure.
+	OutcomeExecuted MigrationOutcome = "executed"
+	// OutcomeSkipped - миграция исключена из плана (см. MigrationReportEntry.Reason).
+	OutcomeSkipped MigrationOutcome = "skipped"
+	// OutcomeNotFound - сохраненная миграция типа TypeRepeatable не зарегистрирована.
+	OutcomeNotFound MigrationOutcome = "not-found"
+	// OutcomeFailedAllowed - ошибка миграции типа TypeRepeatable допущена политикой OnFailure.
+	OutcomeFailedAllowed MigrationOutcome = "failed-allowed"
+	// OutcomeFailed - миграция завершилась ошибкой, выполнение прервано.
+	OutcomeFailed MigrationOutcome = "failed"
+)
+
+// MigrationReportEntry описывает результат обработки одной миграции.
+type MigrationReportEntry struct {
+	Type    MigrationType    `json:"type"`
+	Version string           `json:"version"`
+	Outcome MigrationOutcome `json:"outcome"`
+	// Reason - код причины пропуска миграции
+	Reason       ReasonCode    `json:"reason,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration"`
+	RowsAffected int64         `json:"rows_affected,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// MigrationReport - отчет о выполнении Migrate. Записи следуют в порядке обработки миграций; миграции типа
+// TypeRepeatable, выполняемые параллельно (RunOptions.RepeatableConcurrency), следуют в порядке завершения.
+type MigrationReport struct {
+	Service string                 `json:"service"`
+	Entries []MigrationReportEntry `json:"entries"`
+	// FinalVersion - сохраненная версия базы данных после выполнения, пустая, если выполнение прервано до начала
+	// запуска
+	FinalVersion string `json:"final_version,omitempty"`
+	// AheadOfBinary - количество сохраненных миграций выше последней зарегистрированной: база данных обновлена более
+	// новой версией приложения (см. WithForbidOlderBinary)
+	AheadOfBinary int `json:"ahead_of_binary,omitempty"`
+	// SatisfiedByOtherInstance - миграции выполнены другим экземпляром приложения, удерживавшим блокировку, пока
+	// запуск ожидал ее освобождения (см. WithLockWaitPolicy)
+	SatisfiedByOtherInstance bool          `json:"satisfied_by_other_instance,omitempty"`
+	StartedAt                time.Time     `json:"started_at"`
+	Duration                 time.Duration `json:"duration"`
+	// Phases - длительность этапов запуска
+	Phases PhaseTimings `json:"phases"`
+}
+
+// MigrateWithReport выполняет MigrateContext и возвращает отчет о выполнении. Отчет заполняется и в случае ошибки,
+// содержа миграции, обработанные до ее возникновения.
+func (m *MigrationManager) MigrateWithReport(ctx context.Context, serviceName string, opts RunOptions) (MigrationReport, error) {
+	service, ok := m.service(serviceName)
+	if !ok {
+		return MigrationReport{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	// с WithRunOncePerProcess повторный запуск без новых регистраций ограничивается чтением сохраненной версии и
+	// возвращает отчет предыдущего запуска
+	if m.runOnce && service.upToDate && len(opts.TargetVersion) == 0 {
+		current, err := m.runCacheCurrent(ctx, serviceName)
+		if err != nil {
+			return *newMigrationReport(serviceName), err
+		}
+		if current {
+			m.logger.Debug(fmt.Sprintf("migrations already completed in current process, service: %s", serviceName))
+			if service.cachedReport == nil {
+				return *newMigrationReport(serviceName), nil
+			}
+			return service.cachedReport.clone(), nil
+		}
+
+		m.logger.Warn(fmt.Sprintf("cached migrate result is outdated, service: %s", serviceName))
+		service.resetRunCache()
+	}
+
+	report := newMigrationReport(serviceName)
+	err := m.migrateWithOptions(ctx, serviceName, opts, report)
+	if (errors.Is(err, ErrMigrationLocked) || errors.Is(err, ErrLockNotAcquired)) && m.lockWait.maxWait > 0 {
+		err = m.waitForLockHolder(ctx, serviceName, err, report)
+	}
+	report.Duration = time.Since(report.StartedAt)
+
+	if err == nil && service.upToDate {
+		cached := report.clone()
+		service.cachedReport = &cached
+	}
+	return *report, err
+}
+
+// runCacheCurrent проверяет, что сохраненная версия базы данных не ниже версии, до которой Migrate обновляет сервис,
+// то есть что база данных не была откачена после кешированного запуска другим экземпляром приложения или в обход
+// менеджера. Проверка не планирует и не сохраняет миграции.
+func (m *MigrationManager) runCacheCurrent(ctx context.Context, serviceName string) (bool, error) {
+	service, _ := m.service(serviceName)
+
+	db, err := m.connect(ctx, serviceName, service)
+	if err != nil {
+		return false, err
+	}
+	defer service.DisconnectFunc(db)
+
+	if !repository.HasVersionTable(db) {
+		return false, nil
+	}
+
+	savedVersion, err := repository.GetVersion(db)
+	if err != nil {
+		return false, err
+	}
+
+	return savedVersion.MoreOrEqual(service.reachableVersion()), nil
+}
+
+// reachableVersion возвращает версию, до которой Migrate обновляет сервис: максимальную версию зарегистрированных
+// миграций типов TypeVersioned и TypeBaseline, не превышающую целевую версию сервиса.
+func (s *ServiceInfo) reachableVersion() models.Version {
+	targetVersion := s.serviceTargetVersion()
+
+	var reachable models.Version
+	for _, migration := range s.registeredMigrations {
+		if migration.MigrationType == TypeRepeatable {
+			continue
+		}
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			continue
+		}
+		if version.MoreThan(reachable) && version.LessOrEqual(targetVersion) {
+			reachable = version
+		}
+	}
+	return reachable
+}
+
+// resetRunCache сбрасывает кешированный результат Migrate (см. WithRunOncePerProcess). Вызывается при регистрации
+// миграций и операциями, изменяющими состояние миграций в обход Migrate.
+func (s *ServiceInfo) resetRunCache() {
+	s.upToDate = false
+	s.cachedReport = nil
+}
+
+// InvalidateRunCache сбрасывает кешированный результат Migrate, сохраняемый при использовании WithRunOncePerProcess.
+// Следующий вызов Migrate для сервиса будет выполнен полностью.
+func (m *MigrationManager) InvalidateRunCache(serviceName string) {
+	if service, ok := m.service(serviceName); ok {
+		service.mutex.Lock()
+		service.resetRunCache()
+		service.mutex.Unlock()
+	}
+}
+
+// clone возвращает копию отчета, не разделяющую записи с исходным.
+func (r *MigrationReport) clone() MigrationReport {
+	clone := *r
+	clone.Entries = append([]MigrationReportEntry{}, r.Entries...)
+	return clone
+}
+
+func newMigrationReport(serviceName string) *MigrationReport {
+	return &MigrationReport{
+		Service:   serviceName,
+		Entries:   []MigrationReportEntry{},
+		StartedAt: time.Now(),
+	}
+}
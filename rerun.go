@@ -0,0 +1,174 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+var ErrRerunRequiresForce = errors.New("rerun requires force option")
+
+type rerunConfig struct {
+	force bool
+}
+
+type RerunOption func(*rerunConfig)
+
+// RerunForce разрешает повторное выполнение миграции типа TypeBaseline в базе данных, в которой выполнены другие
+// миграции.
+func RerunForce() RerunOption {
+	return func(c *rerunConfig) {
+		c.force = true
+	}
+}
+
+// Rerun повторно выполняет сохраненную миграцию с версией version и типом migrationType, например, если результат
+// успешно выполненной миграции был отменен вручную. Миграция выполняется так же, как при Migrate (в том числе в
+// транзакции, если задан IsTransactional); после выполнения обновляются время выполнения и контрольная сумма, ранг и
+// сохраненная версия базы данных не изменяются.
+//
+// Возвращает ErrRerunRequiresForce при попытке повторно выполнить миграцию типа TypeBaseline в базе данных, в которой
+// успешно выполнены другие миграции, без опции RerunForce.
+func (m *MigrationManager) Rerun(serviceName string, version string, migrationType MigrationType, opts ...RerunOption) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.resetRunCache()
+
+	config := rerunConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	parsedVersion, err := models.ParseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	service.Db = service.open()
+	defer func() {
+		m.closeAuxiliaryConnections(serviceName)
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err = m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return err
+	}
+
+	if !repository.HasVersionTable(service.Db) || !repository.HasMigrationsTable(service.Db) {
+		return fmt.Errorf("no migration table or Version table found, cannot perform rerun")
+	}
+
+	err = m.initSystemTables(serviceName)
+	if err != nil {
+		return err
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return err
+	}
+
+	var migrationModel models.MigrationModel
+	found := false
+	populated := false
+	for i := range savedMigrations {
+		if savedMigrations[i].Type == string(migrationType) && savedMigrations[i].Version.Equals(parsedVersion) {
+			migrationModel = savedMigrations[i]
+			found = true
+			continue
+		}
+		if savedMigrations[i].State == models.StateSuccess {
+			populated = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("migration (type: %s, Version: %s) is not saved, service: %s", migrationType, version, serviceName)
+	}
+
+	migration, ok, err := m.findMigration(serviceName, migrationModel)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return m.migrationNotFound(serviceName, string(migrationType), parsedVersion)
+	}
+
+	if migrationType == TypeBaseline && populated && !config.force {
+		return fmt.Errorf(
+			"%w: baseline migration %s cannot be rerun over a populated database, service: %s",
+			ErrRerunRequiresForce, version, serviceName,
+		)
+	}
+
+	if migrationType == TypeBaseline && populated {
+		err = m.confirmMigration(
+			OperationBaselineTeardown, serviceName, migrationModel,
+			fmt.Sprintf("baseline migration %s will be executed again over a populated database", version),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.audit(AuditEvent{
+		Event:         AuditMigrationStarted,
+		Service:       serviceName,
+		Direction:     DirectionUp,
+		MigrationType: migrationModel.Type,
+		Version:       migrationModel.Version.String(),
+	})
+
+	startedAt := time.Now()
+	rowsAffected, err := m.executeMigration(serviceName, service.Db, migrationModel, migration, false)
+	if err != nil {
+		m.audit(AuditEvent{
+			Event:         AuditMigrationFailed,
+			Service:       serviceName,
+			Direction:     DirectionUp,
+			MigrationType: migrationModel.Type,
+			Version:       migrationModel.Version.String(),
+			DurationMs:    time.Since(startedAt).Milliseconds(),
+			Error:         err.Error(),
+		})
+
+		if migration.IsAllowFailure || errors.Is(err, ErrAbortedByHook) {
+			return err
+		}
+		return errors.Join(err, repository.TransitionState(service.Db, &migrationModel, models.StateFailure, "rerun failed"))
+	}
+
+	err = repository.UpdateMigrationRowsAffected(service.Db, &migrationModel, rowsAffected)
+	if err != nil {
+		return err
+	}
+
+	checksum := migration.checksum(service.Db)
+	err = transitionExecuted(service.Db, &migrationModel, models.StateSuccess, "rerun", checksum, m.executedBy(service))
+	if err != nil {
+		return err
+	}
+
+	m.audit(AuditEvent{
+		Event:         AuditMigrationSucceeded,
+		Service:       serviceName,
+		Direction:     DirectionUp,
+		MigrationType: migrationModel.Type,
+		Version:       migrationModel.Version.String(),
+		Checksum:      checksum,
+		DurationMs:    time.Since(startedAt).Milliseconds(),
+	})
+
+	m.logger.Info(fmt.Sprintf("migration (type: %s, Version: %s) rerun completed, service: %s", migrationType, version, serviceName))
+	return nil
+}
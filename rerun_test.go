@@ -0,0 +1,76 @@
+package db_migrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRerun(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+
+	runs := 0
+	var failErr error
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+			runs++
+			if failErr != nil {
+				return failErr
+			}
+			return db.Exec("insert into a(id) values (?)", runs).Error
+		}},
+	))
+	require.NoError(t, m.Migrate("service1"))
+	require.Equal(t, 1, runs)
+
+	saved := func() models.MigrationModel {
+		t.Helper()
+
+		savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+		require.NoError(t, err)
+		require.Len(t, savedMigrations, 2)
+		return savedMigrations[1]
+	}
+	before := saved()
+
+	require.NoError(t, m.Rerun("service1", "1.0.1", TypeVersioned))
+	require.Equal(t, 2, runs)
+
+	after := saved()
+	require.Equal(t, models.StateSuccess, after.State)
+	require.Equal(t, before.Rank, after.Rank)
+	require.False(t, after.ExecutedOn.Before(before.ExecutedOn.Time))
+
+	var count int64
+	require.NoError(t, connect().Table("a").Count(&count).Error)
+	require.Equal(t, int64(2), count)
+
+	version, err := m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", version.String())
+
+	failErr = errors.New("rerun failed")
+	require.ErrorIs(t, m.Rerun("service1", "1.0.1", TypeVersioned), failErr)
+	require.Equal(t, models.StateFailure, saved().State)
+}
+
+func TestRerunRejects(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table if not exists a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	))
+
+	require.ErrorContains(t, m.Rerun("service1", "1.0.0", TypeBaseline), "no migration table")
+
+	require.NoError(t, m.Migrate("service1"))
+
+	require.ErrorContains(t, m.Rerun("service1", "1.0.2", TypeVersioned), "is not saved")
+	require.ErrorIs(t, m.Rerun("service1", "1.0.0", TypeBaseline), ErrRerunRequiresForce)
+	require.NoError(t, m.Rerun("service1", "1.0.0", TypeBaseline, RerunForce()))
+}
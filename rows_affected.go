@@ -0,0 +1,39 @@
+package db_migrator
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+type rowsAffectedKey struct{}
+
+// rowsAffectedCounter накапливает количество строк, затронутых миграцией.
+type rowsAffectedCounter struct {
+	value atomic.Int64
+}
+
+func withRowsAffectedCounter(db *gorm.DB, counter *rowsAffectedCounter) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, rowsAffectedKey{}, counter))
+}
+
+// ReportRowsAffected позволяет сообщить из UpF количество строк, затронутых миграцией. Значение суммируется при
+// многократном вызове и сохраняется в колонку rows_affected таблицы migrations. В качестве db необходимо передавать
+// экземпляр, полученный в UpF (или производный от него).
+func ReportRowsAffected(db *gorm.DB, n int64) {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return
+	}
+
+	counter, ok := db.Statement.Context.Value(rowsAffectedKey{}).(*rowsAffectedCounter)
+	if !ok {
+		return
+	}
+
+	counter.value.Add(n)
+}
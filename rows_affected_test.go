@@ -0,0 +1,74 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRowsAffected(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.4")
+
+	require.NoError(t, m.Register("service1",
+		Migration{
+			MigrationType:   TypeBaseline,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			Up:              "create table a(id int); insert into a(id) values (1), (2), (3)",
+			// sqlite возвращает для create table количество строк предыдущего выражения соединения, поэтому
+			// количество затронутых строк baseline не проверяется
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Up:              "update a set id = id + 10 where id < 3; insert into a(id) values (4)",
+		},
+		Migration{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.2",
+			Up:            "update a set id = id + 10; delete from a where id = 14",
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.3",
+			IsTransactional: true,
+			UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+				ReportRowsAffected(db, 5)
+				ReportRowsAffected(db, 2)
+				return nil
+			},
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.4",
+			IsTransactional: true,
+			Up:              "update a set id = id where id = 13",
+			ExpectRowsMin:   2,
+		},
+	))
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.ErrorIs(t, err, ErrRowsAffectedBelowExpected)
+
+	rowsAffected := make(map[string]int64)
+	for _, entry := range report.Entries {
+		if entry.Type != TypeBaseline {
+			rowsAffected[entry.Version] = entry.RowsAffected
+		}
+	}
+	require.Equal(t, map[string]int64{
+		"1.0.1.0": 3,
+		"1.0.2.0": 5,
+		"1.0.3.0": 7,
+		"1.0.4.0": 1,
+	}, rowsAffected)
+
+	var saved []int64
+	require.NoError(t, connect().Raw(
+		"select rows_affected from migrations where type = ? and state = ? order by version", "versioned", "success",
+	).Scan(&saved).Error)
+	require.Equal(t, []int64{3, 5, 7}, saved)
+}
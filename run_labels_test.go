@@ -0,0 +1,90 @@
+package db_migrator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLabels(t *testing.T) {
+	var audit bytes.Buffer
+	var hookLabels []map[string]string
+	m, _ := newTestManager(t, "1.0.2",
+		WithDefaultRunLabels(map[string]string{"team": "core", "deploy": "default"}),
+		WithAuditWriter(&audit),
+		WithBeforeMigration(func(service string, info MigrationInfo) error {
+			hookLabels = append(hookLabels, info.Labels)
+			return nil
+		}),
+	)
+	registerRunDirectionMigrations(t, m)
+
+	deploy := map[string]string{"team": "core", "deploy": "3f2a9c1-42"}
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{RunLabels: map[string]string{"deploy": "3f2a9c1-42"}}))
+
+	runs, err := m.Runs("service1", 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, deploy, runs[0].Labels)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Len(t, status.Migrations, 3)
+	for _, migration := range status.Migrations {
+		require.Equal(t, deploy, migration.Labels, migration.Version)
+	}
+
+	require.Len(t, hookLabels, 3)
+	for _, labels := range hookLabels {
+		require.Equal(t, deploy, labels)
+	}
+
+	for _, event := range decodeAuditEvents(t, audit.Bytes()) {
+		require.Equal(t, deploy, event.Labels, event.Event)
+	}
+
+	t.Run("downgrade", func(t *testing.T) {
+		require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+
+		runs, err := m.Runs("service1", 1)
+		require.NoError(t, err)
+		require.Equal(t, DirectionDown, runs[0].Direction)
+		require.Equal(t, map[string]string{"team": "core", "deploy": "default"}, runs[0].Labels)
+
+		status, err := m.Status("service1")
+		require.NoError(t, err)
+		for _, migration := range status.Migrations {
+			if migration.Version == "1.0.2.0" {
+				// строка отмененной миграции содержит метки отменившего ее запуска
+				require.Equal(t, map[string]string{"team": "core", "deploy": "default"}, migration.Labels)
+				continue
+			}
+			require.Equal(t, deploy, migration.Labels, migration.Version)
+		}
+	})
+}
+
+func TestRunLabelsValidation(t *testing.T) {
+	tooMany := make(map[string]string, maxRunLabels+1)
+	for i := range maxRunLabels + 1 {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	invalid := map[string]map[string]string{
+		"key":        {"deploy id": "1"},
+		"empty key":  {"": "1"},
+		"long value": {"deploy": strings.Repeat("x", maxRunLabelValueBytes+1)},
+		"too many":   tooMany,
+	}
+	for name, labels := range invalid {
+		t.Run(name, func(t *testing.T) {
+			m, connect := newTestManager(t, "1.0.2")
+			registerRunDirectionMigrations(t, m)
+
+			require.Error(t, m.MigrateWithOptions("service1", RunOptions{RunLabels: labels}))
+			require.False(t, connect().Migrator().HasTable("a"))
+		})
+	}
+}
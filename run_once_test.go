@@ -0,0 +1,117 @@
+package db_migrator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRunOncePerProcess(t *testing.T) {
+	m, err := NewMigrationsManager(WithRunOncePerProcess())
+	require.NoError(t, err)
+
+	var connects atomic.Int64
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", func() *gorm.DB {
+		connects.Add(1)
+		return connect()
+	}, disconnect, "1.0.1"))
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, m.Migrate("service1"))
+		}()
+	}
+	wg.Wait()
+	// один запуск выполняет миграции, остальные только читают сохраненную версию
+	require.EqualValues(t, 8, connects.Load())
+
+	// отчет кешированного запуска, а не пустой отчет повторного выполнения
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 9, connects.Load())
+	require.Len(t, report.Entries, 1)
+	require.Equal(t, "1.0.0.0", report.FinalVersion)
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	))
+	report, err = m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 10, connects.Load())
+	require.Len(t, report.Entries, 1)
+	require.Equal(t, "1.0.1.0", report.FinalVersion)
+
+	report, err = m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 11, connects.Load())
+	require.Len(t, report.Entries, 1)
+
+	m.InvalidateRunCache("service1")
+	report, err = m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 12, connects.Load())
+	require.Empty(t, report.Entries)
+}
+
+func runOnceTestMigrations() []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "create table b(id int)", Down: "drop table b"},
+		{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "create table c(id int)", Down: "drop table c"},
+	}
+}
+
+// TestRunOnceAfterDowngrade проверяет, что Migrate после DowngradeTo в том же процессе повторно выполняет отмененные
+// миграции, а не возвращает кешированный отчет.
+func TestRunOnceAfterDowngrade(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2", WithRunOncePerProcess())
+	require.NoError(t, m.Register("service1", runOnceTestMigrations()...))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+	require.False(t, connect().Migrator().HasTable("c"))
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", report.FinalVersion)
+	require.Len(t, report.Entries, 2)
+	require.True(t, connect().Migrator().HasTable("b"))
+	require.True(t, connect().Migrator().HasTable("c"))
+}
+
+// TestRunOnceDetectsExternalDowngrade проверяет, что кешированный результат не используется, если база данных
+// откачена другим экземпляром приложения.
+func TestRunOnceDetectsExternalDowngrade(t *testing.T) {
+	m, err := NewMigrationsManager(WithRunOncePerProcess())
+	require.NoError(t, err)
+	other, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	for _, manager := range []*MigrationManager{m, other} {
+		require.NoError(t, manager.RegisterService("service1", connect, disconnect, "1.0.2"))
+		require.NoError(t, manager.Register("service1", runOnceTestMigrations()...))
+	}
+
+	require.NoError(t, m.Migrate("service1"))
+	require.NoError(t, other.DowngradeTo("service1", "1.0.1"))
+	require.False(t, connect().Migrator().HasTable("c"))
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Entries, 1)
+	require.Equal(t, "1.0.2.0", report.Entries[0].Version)
+	require.True(t, connect().Migrator().HasTable("c"))
+}
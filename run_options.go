@@ -0,0 +1,100 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const (
+	maxRunLabels          = 32
+	maxRunLabelValueBytes = 256
+)
+
+var runLabelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_.\-/]{1,63}$`)
+
+// RunOptions задает параметры отдельного запуска Migrate или Downgrade.
+type RunOptions struct {
+	// RunLabels - метки запуска (например, идентификатор деплоя). Сохраняются в таблицу migration_runs и в строки
+	// выполненных и отмененных миграций таблицы migrations, передаются обработчикам (MigrationInfo.Labels) и добавляются
+	// в записи журнала аудита. Объединяются с метками, заданными WithDefaultRunLabels.
+	RunLabels map[string]string
+	// AcknowledgeDirectionChange подтверждает запуск в направлении, противоположном недавнему запуску
+	// (см. WithDirectionConflictWindow).
+	AcknowledgeDirectionChange bool
+	// ResumeFromLastStatement продолжает нетранзакционные миграции, ранее завершившиеся ошибкой, с выражения,
+	// следующего за последним успешно выполненным, вместо выполнения Up с начала.
+	ResumeFromLastStatement bool
+	// ResumeInterrupted подтверждает повторное выполнение нетранзакционных миграций, выполнение которых было прервано
+	// (см. ErrPreviousRunInterrupted): такие миграции отмечаются ошибкой и выполняются снова.
+	ResumeInterrupted bool
+	// RepeatableConcurrency - количество миграций типа TypeRepeatable, выполняемых параллельно. Параллельно
+	// выполняются только миграции без Dependency и UsesAuxiliary, после остальных миграций плана, в соединениях пула
+	// сервиса: фактический параллелизм ограничен размером пула (sql.DB.SetMaxOpenConns). Значения 0 и 1 означают
+	// последовательное выполнение.
+	RepeatableConcurrency int
+	// TargetVersion заменяет целевую версию сервиса в рамках запуска, не изменяя ее. При Migrate ограничивает версию
+	// выполняемых миграций и не может быть ниже сохраненной версии базы данных, при Downgrade отменяются миграции
+	// выше TargetVersion. Версия должна соответствовать зарегистрированной миграции.
+	TargetVersion string
+	// Steps - количество последних выполненных миграций типа TypeVersioned, отменяемых Downgrade независимо от
+	// целевой версии сервиса. Если выполненных миграций меньше, Downgrade возвращает ошибку, не отменяя ни одной.
+	// 0 означает отмену всех миграций выше целевой версии. Не может быть задано вместе с TargetVersion, не действует
+	// при Migrate.
+	Steps int
+}
+
+// validateRunLabels проверяет количество меток, формат ключей и длину значений.
+func validateRunLabels(labels map[string]string) error {
+	if len(labels) > maxRunLabels {
+		return fmt.Errorf("too many run labels: %d, maximum is %d", len(labels), maxRunLabels)
+	}
+
+	for key, value := range labels {
+		if !runLabelKeyRegexp.MatchString(key) {
+			return fmt.Errorf("invalid run label key: %q", key)
+		}
+		if len(value) > maxRunLabelValueBytes {
+			return fmt.Errorf("run label %s value is too long: %d bytes, maximum is %d", key, len(value), maxRunLabelValueBytes)
+		}
+	}
+
+	return nil
+}
+
+// runLabels объединяет метки по умолчанию с метками запуска. Метки запуска имеют приоритет.
+func (m *MigrationManager) runLabels(opts RunOptions) (map[string]string, error) {
+	labels := make(map[string]string, len(m.defaultRunLabels)+len(opts.RunLabels))
+	for key, value := range m.defaultRunLabels {
+		labels[key] = value
+	}
+	for key, value := range opts.RunLabels {
+		labels[key] = value
+	}
+
+	if err := validateRunLabels(labels); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+func encodeRunLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	// json.Marshal сортирует ключи map, поэтому результат детерминирован
+	encoded, _ := json.Marshal(labels)
+	return string(encoded)
+}
+
+func decodeRunLabels(encoded string) map[string]string {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string)
+	_ = json.Unmarshal([]byte(encoded), &labels)
+	return labels
+}
@@ -0,0 +1,241 @@
+package db_migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Run описывает один запуск Migrate или Downgrade, сохраненный в таблицу migration_runs.
+type Run struct {
+	Id             int64
+	Service        string
+	Direction      string
+	TargetVersion  string
+	StartedAt      time.Time
+	FinishedAt     *time.Time
+	Applied        int
+	Skipped        int
+	Failed         int
+	RowsAffected   int64
+	FinalVersion   string
+	TriggeredBy    string
+	LibraryVersion string
+	Fingerprint    string
+	PlanHash       string
+	Error          string
+	Labels         map[string]string
+	// Phases - длительность этапов запуска Migrate, nil для Downgrade и запусков, сохраненных предыдущими версиями
+	// библиотеки
+	Phases *PhaseTimings
+}
+
+// Runs возвращает последние limit запусков Migrate и Downgrade сервиса, начиная с самого нового. При limit <= 0
+// возвращаются все запуски.
+func (m *MigrationManager) Runs(serviceName string, limit int) ([]Run, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	if !repository.HasRunsTable(service.Db) {
+		return []Run{}, nil
+	}
+
+	runModels, err := repository.GetRuns(service.Db, serviceName, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]Run, 0, len(runModels))
+	for _, runModel := range runModels {
+		runs = append(runs, runFromModel(runModel))
+	}
+
+	return runs, nil
+}
+
+func runFromModel(runModel models.RunModel) Run {
+	run := Run{
+		Id:             runModel.Id,
+		Service:        runModel.Service,
+		Direction:      runModel.Direction,
+		TargetVersion:  runModel.TargetVersion,
+		StartedAt:      runModel.StartedAt.Time,
+		Applied:        runModel.Applied,
+		Skipped:        runModel.Skipped,
+		Failed:         runModel.Failed,
+		RowsAffected:   runModel.RowsAffected,
+		FinalVersion:   runModel.FinalVersion,
+		TriggeredBy:    runModel.TriggeredBy,
+		LibraryVersion: runModel.LibraryVersion,
+		Fingerprint:    runModel.Fingerprint,
+		PlanHash:       runModel.PlanHash,
+		Error:          runModel.Error,
+		Labels:         decodeRunLabels(runModel.Labels),
+		Phases:         decodePhaseTimings(runModel.PhaseTimings),
+	}
+
+	if runModel.FinishedAt != nil {
+		finishedAt := runModel.FinishedAt.Time
+		run.FinishedAt = &finishedAt
+	}
+
+	return run
+}
+
+// startRun сохраняет запись о начале запуска. Ошибка сохранения выводится в лог и не прерывает выполнение, в этом
+// случае возвращается несохраненная запись с нулевым Id.
+func (m *MigrationManager) startRun(db *gorm.DB, serviceName string, direction string) *models.RunModel {
+	var err error
+	if !repository.HasRunsTable(db) {
+		err = repository.CreateRunsTable(db)
+	} else {
+		err = repository.MigrateRunsTable(db)
+	}
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to create runs table, service: %s, err: %s", serviceName, err))
+		return &models.RunModel{}
+	}
+
+	fingerprint, err := m.fingerprint(serviceName)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to compute fingerprint, service: %s, err: %s", serviceName, err))
+	}
+	now := time.Now().UTC()
+
+	run := &models.RunModel{
+		Id:             now.UnixNano(),
+		Service:        serviceName,
+		Direction:      direction,
+		StartedAt:      models.CustomTime{Time: now},
+		TriggeredBy:    m.appliedBy(),
+		LibraryVersion: libraryVersion,
+		Fingerprint:    fingerprint,
+	}
+
+	if service, ok := m.service(serviceName); ok {
+		run.Labels = encodeRunLabels(service.runLabels)
+		run.TargetVersion = service.targetVersion().String()
+	}
+
+	err = repository.SaveRun(db, run)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to save run, service: %s, err: %s", serviceName, err))
+		return &models.RunModel{}
+	}
+
+	return run
+}
+
+// finishRun сохраняет итог запуска.
+func (m *MigrationManager) finishRun(db *gorm.DB, serviceName string, run *models.RunModel, runErr error) {
+	if run.Id == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	run.FinishedAt = &models.CustomTime{Time: now}
+	run.Error = errorString(runErr)
+
+	if version, err := repository.GetVersion(db); err == nil {
+		run.FinalVersion = version.String()
+	}
+
+	m.recordSchemaSnapshot(db, serviceName, run)
+
+	err := repository.SaveRun(db, run)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to save run, service: %s, err: %s", serviceName, err))
+	}
+}
+
+// planHash вычисляет хеш плана выполнения по типам и версиям миграций.
+func planHash(plan migrationsPlan) string {
+	h := sha256.New()
+	for _, migrationModel := range plan.Migrations() {
+		_, _ = h.Write([]byte(migrationModel.Type + ":" + migrationModel.Version.String() + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkRunDirection проверяет, что запуск не отменяет результат предыдущего запуска, выполненного в противоположном
+// направлении в пределах окна WithDirectionConflictWindow: повышение версии сразу после отката на более низкую версию
+// (или наоборот) требует RunOptions.AcknowledgeDirectionChange.
+func (m *MigrationManager) checkRunDirection(db *gorm.DB, serviceName string, direction string, opts RunOptions) error {
+	if m.directionConflictWindow <= 0 || opts.AcknowledgeDirectionChange || !repository.HasRunsTable(db) {
+		return nil
+	}
+
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	if err := repository.MigrateRunsTable(db); err != nil {
+		return err
+	}
+
+	runs, err := repository.GetRuns(db, serviceName, 1)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	previous := runs[0]
+	if previous.Direction == direction || len(previous.TargetVersion) == 0 {
+		return nil
+	}
+	if time.Since(previous.StartedAt.Time) > m.directionConflictWindow {
+		return nil
+	}
+
+	previousTarget, err := models.ParseVersion(previous.TargetVersion)
+	if err != nil {
+		return err
+	}
+
+	targetVersion := service.targetVersion()
+	conflict := direction == DirectionUp && targetVersion.MoreThan(previousTarget) ||
+		direction == DirectionDown && targetVersion.LessThan(previousTarget)
+	if !conflict {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: previous run (%s to %s by %s at %s) conflicts with current run (%s to %s), "+
+			"set RunOptions.AcknowledgeDirectionChange to proceed",
+		ErrConflictingRunDirection,
+		previous.Direction, previous.TargetVersion, previous.TriggeredBy, previous.StartedAt.Format(time.RFC3339),
+		direction, targetVersion,
+	)
+}
@@ -0,0 +1,131 @@
+package db_migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func registerRunDirectionMigrations(t *testing.T, m *MigrationManager) {
+	t.Helper()
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			Up:              "alter table a add column b text",
+			Down:            "alter table a drop column b",
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			Up:              "alter table a add column c text",
+			Down:            "alter table a drop column c",
+		},
+	))
+}
+
+func TestRunDirectionConflict(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.2")
+		registerRunDirectionMigrations(t, m)
+
+		require.NoError(t, m.Migrate("service1"))
+		require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+		require.NoError(t, m.Migrate("service1"))
+	})
+
+	t.Run("back-to-back conflicting runs", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.2", WithDirectionConflictWindow(time.Hour))
+		registerRunDirectionMigrations(t, m)
+
+		require.NoError(t, m.Migrate("service1"))
+
+		require.ErrorIs(t, m.DowngradeTo("service1", "1.0.1"), ErrConflictingRunDirection)
+		require.NoError(t, m.DowngradeWithOptions("service1", RunOptions{
+			TargetVersion:              "1.0.1",
+			AcknowledgeDirectionChange: true,
+		}))
+
+		require.ErrorIs(t, m.Migrate("service1"), ErrConflictingRunDirection)
+		require.NoError(t, m.MigrateWithOptions("service1", RunOptions{AcknowledgeDirectionChange: true}))
+	})
+}
+
+func TestRuns(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+
+	runs, err := m.Runs("service1", 0)
+	require.NoError(t, err)
+	require.Empty(t, runs)
+
+	require.NoError(t, m.Migrate("service1"))
+	// повторный запуск без ожидающих миграций
+	require.NoError(t, m.Migrate("service1"))
+
+	runs, err = m.Runs("service1", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	noop, applied := runs[0], runs[1]
+
+	require.Equal(t, "service1", applied.Service)
+	require.Equal(t, DirectionUp, applied.Direction)
+	require.Equal(t, "1.0.2.0", applied.TargetVersion)
+	require.Equal(t, "1.0.2.0", applied.FinalVersion)
+	require.Equal(t, 3, applied.Applied)
+	require.Zero(t, applied.Failed)
+	require.Empty(t, applied.Error)
+	require.NotNil(t, applied.FinishedAt)
+	require.False(t, applied.FinishedAt.Before(applied.StartedAt))
+	require.Equal(t, libraryVersion, applied.LibraryVersion)
+	require.NotEmpty(t, applied.Fingerprint)
+	require.NotEmpty(t, applied.PlanHash)
+	require.NotNil(t, applied.Phases)
+
+	require.Equal(t, DirectionUp, noop.Direction)
+	require.Zero(t, noop.Applied)
+	require.Zero(t, noop.Failed)
+	require.Empty(t, noop.Error)
+	require.Equal(t, "1.0.2.0", noop.FinalVersion)
+	require.NotNil(t, noop.FinishedAt)
+	require.NotEqual(t, applied.PlanHash, noop.PlanHash)
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+	runs, err = m.Runs("service1", 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, DirectionDown, runs[0].Direction)
+	require.Equal(t, 1, runs[0].Applied)
+	require.Equal(t, "1.0.1.0", runs[0].FinalVersion)
+	require.Nil(t, runs[0].Phases)
+
+	_, err = m.Runs("unknown", 0)
+	require.ErrorIs(t, err, ErrServiceNotFound)
+}
+
+func TestRunsFailedRun(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table missing add column b text"},
+	))
+
+	migrateErr := m.Migrate("service1")
+	require.Error(t, migrateErr)
+
+	runs, err := m.Runs("service1", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, 1, runs[0].Applied)
+	require.Equal(t, 1, runs[0].Failed)
+	require.Equal(t, migrateErr.Error(), runs[0].Error)
+	require.Contains(t, runs[0].Error, "no such table: missing")
+	require.Equal(t, "1.0.0.0", runs[0].FinalVersion)
+	require.NotNil(t, runs[0].FinishedAt)
+}
@@ -0,0 +1,89 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeignMigrationsTable(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	db := connect()
+	require.NoError(t, db.Exec("create table migrations(id integer primary key, name text, applied_at text)").Error)
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrForeignMigrationsTable)
+	require.ErrorContains(t, err, "missing columns: [")
+	require.ErrorContains(t, err, "checksum")
+	require.ErrorContains(t, err, "unexpected columns: [")
+	require.ErrorContains(t, err, "applied_at")
+
+	// таблица приложения не изменяется и миграции не выполняются
+	columns, err := db.Migrator().ColumnTypes("migrations")
+	require.NoError(t, err)
+	require.Len(t, columns, 3)
+	require.False(t, db.Migrator().HasTable("a"))
+}
+
+func TestAlteredMigrationsTable(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+
+	t.Run("optional column", func(t *testing.T) {
+		// таблица, созданная версией библиотеки без необязательных столбцов, дополняется при запуске
+		require.NoError(t, db.Exec("alter table migrations drop column out_of_order").Error)
+
+		require.NoError(t, m.Migrate("service1"))
+		require.True(t, db.Migrator().HasColumn("migrations", "out_of_order"))
+	})
+
+	t.Run("required column", func(t *testing.T) {
+		require.NoError(t, db.Exec("alter table migrations drop column checksum").Error)
+
+		err := m.Migrate("service1")
+		require.ErrorIs(t, err, ErrForeignMigrationsTable)
+		require.ErrorContains(t, err, "missing columns: [checksum]")
+		require.False(t, db.Migrator().HasColumn("migrations", "checksum"))
+	})
+}
+
+func TestForeignVersionTable(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	db := connect()
+	require.NoError(t, db.Exec("create table version(id integer primary key, name text)").Error)
+
+	err := m.Migrate("service1")
+	require.ErrorIs(t, err, ErrForeignMigrationsTable)
+	require.ErrorContains(t, err, "missing columns: [version]")
+}
+
+func TestForeignMigrationsTableWithSystemTableNames(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.0",
+		WithSystemTableNames("db_migrator_version", "db_migrator_migrations"),
+	))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	db := connect()
+	require.NoError(t, db.Exec("create table migrations(id integer primary key, name text)").Error)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, db.Migrator().HasTable("db_migrator_migrations"))
+	require.True(t, db.Migrator().HasTable("a"))
+}
@@ -0,0 +1,173 @@
+package db_migrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrSchemaDrift = errors.New("schema was changed outside of migrations")
+
+// systemTables - общие таблицы мигратора, не учитываемые при отслеживании изменений схемы.
+var systemTables = []string{
+	models.MetaModel{}.TableName(),
+	models.RunModel{}.TableName(),
+	models.LockModel{}.TableName(),
+}
+
+// systemTablesOf возвращает таблицы мигратора, не учитываемые при отслеживании изменений схемы, с учетом размещения
+// системных таблиц сервиса (см. WithTablePrefix и WithSystemTableNames).
+func systemTablesOf(db *gorm.DB) []string {
+	return append(slices.Clone(systemTables), repository.ScopedTableNames(db)...)
+}
+
+// schemaSnapshot возвращает контрольные суммы определений колонок всех таблиц текущей схемы, кроме системных таблиц
+// мигратора.
+func schemaSnapshot(db *gorm.DB) (map[string]string, error) {
+	var lines []string
+	var err error
+	systemTables := systemTablesOf(db)
+
+	switch db.Dialector.Name() {
+	case "sqlite":
+		var tables []string
+		err = db.Raw(
+			"SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT IN ?",
+			systemTables,
+		).Scan(&tables).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			tableLines, err := queryLines(db, fmt.Sprintf("SELECT '%s', name, type, \"notnull\", dflt_value FROM pragma_table_info('%s')",
+				escapeLiteral(table), escapeLiteral(table)))
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, tableLines...)
+		}
+	case "mysql":
+		lines, err = queryLines(db, `SELECT table_name, column_name, column_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name NOT IN ?`, systemTables)
+	case "postgres":
+		lines, err = queryLines(db, `SELECT table_name, column_name, data_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name NOT IN ?`, systemTables)
+	default:
+		lines, err = queryLines(db, `SELECT table_name, column_name, data_type, is_nullable, column_default
+			FROM information_schema.columns
+			WHERE table_name NOT IN ?`, systemTables)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byTable := make(map[string][]string)
+	for _, line := range lines {
+		table, _, _ := strings.Cut(line, "\x1f")
+		byTable[table] = append(byTable[table], line)
+	}
+
+	snapshot := make(map[string]string, len(byTable))
+	for table, tableLines := range byTable {
+		snapshot[table] = hashLines(tableLines)
+	}
+
+	return snapshot, nil
+}
+
+// schemaDiff возвращает таблицы, добавленные, удаленные или измененные в current по сравнению с previous.
+func schemaDiff(previous map[string]string, current map[string]string) []string {
+	changed := make([]string, 0)
+	for table, hash := range current {
+		previousHash, ok := previous[table]
+		switch {
+		case !ok:
+			changed = append(changed, table+" (added)")
+		case previousHash != hash:
+			changed = append(changed, table+" (changed)")
+		}
+	}
+	for table := range previous {
+		if _, ok := current[table]; !ok {
+			changed = append(changed, table+" (removed)")
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// checkSchemaDrift сравнивает текущую схему со снимком, сохраненным по завершении предыдущего запуска. При
+// расхождении выводит предупреждение или, при WithStrictSchemaTracking, возвращает ErrSchemaDrift.
+func (m *MigrationManager) checkSchemaDrift(db *gorm.DB, serviceName string) error {
+	if !m.schemaTracking || !repository.HasRunsTable(db) {
+		return nil
+	}
+
+	if err := repository.MigrateRunsTable(db); err != nil {
+		return err
+	}
+
+	encoded, err := repository.GetLastSchemaSnapshot(db, serviceName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var previous map[string]string
+	if err = json.Unmarshal([]byte(encoded), &previous); err != nil {
+		return err
+	}
+
+	current, err := schemaSnapshot(db)
+	if err != nil {
+		return err
+	}
+
+	changed := schemaDiff(previous, current)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"schema of service %s was changed outside of migrations since the previous run: %s",
+		serviceName, strings.Join(changed, ", "),
+	)
+	if m.strictSchemaTracking {
+		return fmt.Errorf("%w: %s", ErrSchemaDrift, message)
+	}
+
+	m.logger.Warn(message)
+	return nil
+}
+
+// recordSchemaSnapshot сохраняет снимок схемы в запись о запуске.
+func (m *MigrationManager) recordSchemaSnapshot(db *gorm.DB, serviceName string, run *models.RunModel) {
+	if !m.schemaTracking {
+		return
+	}
+
+	snapshot, err := schemaSnapshot(db)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to take schema snapshot, service: %s, err: %s", serviceName, err))
+		return
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to encode schema snapshot, service: %s, err: %s", serviceName, err))
+		return
+	}
+
+	run.SchemaSnapshot = string(encoded)
+}
@@ -0,0 +1,71 @@
+package db_migrator
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	previous := map[string]string{"a": "1", "b": "2", "c": "3"}
+	current := map[string]string{"a": "1", "b": "changed", "d": "4"}
+
+	require.Equal(t, []string{"b (changed)", "c (removed)", "d (added)"}, schemaDiff(previous, current))
+	require.Empty(t, schemaDiff(previous, previous))
+}
+
+func TestSchemaDrift(t *testing.T) {
+	t.Run("warning", func(t *testing.T) {
+		var logs bytes.Buffer
+		m, connect := newTestManager(t, "1.0.1",
+			WithSchemaTracking(),
+			WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		)
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		))
+		require.NoError(t, m.Migrate("service1"))
+
+		// изменения миграций и системных таблиц не считаются расхождением
+		require.NoError(t, m.Migrate("service1"))
+		require.NotContains(t, logs.String(), "changed outside of migrations")
+
+		require.NoError(t, connect().Exec("alter table a add column hotfix text").Error)
+
+		require.NoError(t, m.Migrate("service1"))
+		require.Contains(t, logs.String(), "changed outside of migrations")
+		require.Contains(t, logs.String(), "a (changed)")
+
+		// снимок обновляется по завершении запуска, расхождение сообщается один раз
+		logs.Reset()
+		require.NoError(t, m.Migrate("service1"))
+		require.NotContains(t, logs.String(), "changed outside of migrations")
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.2", WithStrictSchemaTracking())
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+			Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		))
+		require.NoError(t, m.MigrateWithOptions("service1", RunOptions{TargetVersion: "1.0.1"}))
+
+		db := connect()
+		require.NoError(t, db.Exec("alter table a add column hotfix text").Error)
+		require.NoError(t, db.Exec("create table manual(id int)").Error)
+
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+		))
+
+		err := m.Migrate("service1")
+		require.ErrorIs(t, err, ErrSchemaDrift)
+		require.ErrorContains(t, err, "a (changed)")
+		require.ErrorContains(t, err, "manual (added)")
+		// новые миграции не выполняются
+		require.False(t, db.Migrator().HasColumn("a", "c"))
+	})
+}
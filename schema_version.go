@@ -0,0 +1,207 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrConflictingVersions - таблица version содержит несколько строк с разными версиями (например, после ручного
+// восстановления из резервной копии). Лишние строки удаляются при следующем Migrate или Downgrade.
+var ErrConflictingVersions = repository.ErrConflictingVersions
+
+// SchemaVersion - версия схемы базы данных в формате major.minor.patch.prerelease. Помимо сравнения версий
+// (MoreThan, LessOrEqual и т.д.) предоставляет предикаты AtLeast, Between и SameMinor, принимающие версию в виде строки:
+//
+//	version, _ := db_migrator.ParseSchemaVersion("1.4.0.0")
+//	version.AtLeast("1.2.0.0")            // true
+//	version.Between("1.0.0.0", "1.9.0.0") // true
+//	version.SameMinor("1.4.7.0")          // true
+type SchemaVersion = models.Version
+
+const (
+	// VersionSetByUndo - значение VersionRecord.SetByType для версии, сохраненной при отмене миграции в Downgrade.
+	VersionSetByUndo = "undo"
+	// VersionSetByMark - значение VersionRecord.SetByType для версии, сохраненной MarkApplied без выполнения миграции.
+	VersionSetByMark = "mark"
+)
+
+// VersionRecord описывает сохраненную версию и миграцию, которая ее установила.
+type VersionRecord struct {
+	Version SchemaVersion
+	// SetByVersion - версия миграции, после выполнения или отмены которой сохранена версия.
+	SetByVersion string
+	// SetByType - тип выполненной миграции, VersionSetByUndo при отмене миграции или VersionSetByMark.
+	SetByType string
+	// SetAt - время сохранения версии. Пусто для версий, сохраненных до появления этой информации.
+	SetAt *time.Time
+}
+
+// repairVersionTable оставляет в таблице version сервиса одну строку с максимальной версией.
+func (m *MigrationManager) repairVersionTable(serviceName string, db *gorm.DB) error {
+	removed, err := repository.RepairVersionTable(db)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		m.logger.Warn(fmt.Sprintf("version table of service %s contained %d extra rows, kept the max version", serviceName, removed))
+	}
+	return nil
+}
+
+// ParseSchemaVersion разбирает версию в формате major.minor.patch.prerelease.
+func ParseSchemaVersion(version string) (SchemaVersion, error) {
+	return models.ParseVersion(version)
+}
+
+// SavedVersion возвращает версию, сохраненную в базе данных сервиса. Если системные таблицы еще не созданы,
+// возвращается версия 0.0.0.0.
+func (m *MigrationManager) SavedVersion(serviceName string) (SchemaVersion, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return SchemaVersion{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return SchemaVersion{}, err
+	}
+
+	if !repository.HasVersionTable(service.Db) {
+		return SchemaVersion{}, nil
+	}
+
+	return m.getSavedAppVersion(serviceName)
+}
+
+// SavedVersionAtLeast возвращает true, если версия, сохраненная в базе данных сервиса, больше или равна version.
+func (m *MigrationManager) SavedVersionAtLeast(serviceName string, version string) (bool, error) {
+	parsed, err := ParseSchemaVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	savedVersion, err := m.SavedVersion(serviceName)
+	if err != nil {
+		return false, err
+	}
+
+	return savedVersion.MoreOrEqual(parsed), nil
+}
+
+// SavedVersionRecord возвращает версию, сохраненную в базе данных сервиса, вместе с информацией о миграции, которая
+// ее установила. Если версия еще не сохранялась, возвращается пустая запись.
+func (m *MigrationManager) SavedVersionRecord(serviceName string) (VersionRecord, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return VersionRecord{}, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return VersionRecord{}, err
+	}
+
+	if !repository.HasVersionTable(service.Db) {
+		return VersionRecord{}, nil
+	}
+
+	row, err := repository.GetVersionRow(service.Db)
+	if errors.Is(err, repository.ErrNotFound) {
+		return VersionRecord{}, nil
+	}
+	if err != nil {
+		return VersionRecord{}, err
+	}
+
+	record := VersionRecord{
+		Version:      row.Version,
+		SetByVersion: row.SetByVersion,
+		SetByType:    row.SetByType,
+	}
+	if row.SetAt != nil {
+		setAt := row.SetAt.Time
+		record.SetAt = &setAt
+	}
+
+	return record, nil
+}
+
+// VersionHistoryEntry - запись об изменении версии, сохраненной в базе данных сервиса.
+type VersionHistoryEntry struct {
+	Version SchemaVersion
+	// ReachedOn - время сохранения версии.
+	ReachedOn time.Time
+	// Direction - DirectionUp при выполнении миграции, DirectionDown при отмене миграции в Downgrade.
+	Direction string
+	// MigrationRank - rank миграции, после выполнения или отмены которой сохранена версия.
+	MigrationRank int
+}
+
+// VersionHistory возвращает изменения версии базы данных сервиса в хронологическом порядке, включая откаты. Версии,
+// сохраненные до появления таблицы version_history, в историю не попадают.
+func (m *MigrationManager) VersionHistory(serviceName string) ([]VersionHistoryEntry, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return nil, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]VersionHistoryEntry, 0)
+	if !repository.HasVersionHistoryTable(service.Db) {
+		return entries, nil
+	}
+
+	history, err := repository.GetVersionHistory(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range history {
+		entries = append(entries, VersionHistoryEntry{
+			Version:       row.Version,
+			ReachedOn:     row.ReachedOn.Time,
+			Direction:     row.Direction,
+			MigrationRank: row.MigrationRank,
+		})
+	}
+
+	return entries, nil
+}
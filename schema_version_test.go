@@ -0,0 +1,41 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedVersionAtLeast(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.1")
+	registerRunDirectionMigrations(t, m)
+
+	// системные таблицы еще не созданы, сохраненная версия 0.0.0.0
+	ok, err := m.SavedVersionAtLeast("service1", "0.0.0.0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = m.SavedVersionAtLeast("service1", "1.0.0")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, m.Migrate("service1"))
+
+	tests := map[string]bool{
+		"1.0":       true,
+		"1.0.1":     true,
+		"1.0.1.0":   true,
+		"1.0.1.1":   false,
+		"1.0.2":     false,
+		"1.0.1-rc1": true,
+	}
+	for version, expected := range tests {
+		ok, err := m.SavedVersionAtLeast("service1", version)
+		require.NoError(t, err)
+		require.Equal(t, expected, ok, version)
+	}
+
+	_, err = m.SavedVersionAtLeast("service1", "1.x")
+	require.Error(t, err)
+	_, err = m.SavedVersionAtLeast("unknown", "1.0.0")
+	require.ErrorIs(t, err, ErrServiceNotFound)
+}
@@ -0,0 +1,128 @@
+package db_migrator
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// blockingMigrations возвращает миграции, UpF которых сообщает о начале выполнения в started и ожидает закрытия
+// release. active и maxActive - текущее и максимальное количество одновременно выполняемых UpF.
+func blockingMigrations(serviceName string, started chan<- string, release <-chan struct{}, active, maxActive *atomic.Int32) []Migration {
+	return []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			UpF: func(selfDb *gorm.DB, _ map[string]*gorm.DB) error {
+				current := active.Add(1)
+				defer active.Add(-1)
+				for {
+					previous := maxActive.Load()
+					if current <= previous || maxActive.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+
+				started <- serviceName
+				<-release
+				return selfDb.Exec("alter table a add column b text").Error
+			},
+		},
+	}
+}
+
+// waitStarted ожидает начала выполнения UpF сервиса.
+func waitStarted(t *testing.T, started <-chan string) string {
+	t.Helper()
+
+	select {
+	case serviceName := <-started:
+		return serviceName
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "migration is not started")
+		return ""
+	}
+}
+
+func TestMigrateDifferentServicesConcurrently(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	var active, maxActive atomic.Int32
+
+	for _, serviceName := range []string{"a", "b"} {
+		connect, disconnect := newTestDatabase(t)
+		require.NoError(t, m.RegisterService(serviceName, connect, disconnect, "1.0.1"))
+		require.NoError(t, m.Register(serviceName, blockingMigrations(serviceName, started, release, &active, &maxActive)...))
+	}
+
+	errs := make(chan error, 2)
+	for _, serviceName := range []string{"a", "b"} {
+		go func() {
+			errs <- m.Migrate(serviceName)
+		}()
+	}
+
+	// UpF обоих сервисов выполняются одновременно: вторая миграция начинается до завершения первой
+	require.ElementsMatch(t, []string{"a", "b"}, []string{waitStarted(t, started), waitStarted(t, started)})
+	require.Equal(t, int32(2), maxActive.Load())
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+}
+
+func TestMigrateSameServiceSerialized(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+
+	otherConnect, otherDisconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("other", otherConnect, otherDisconnect, "1.0.0"))
+	require.NoError(t, m.Register("other",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	var active, maxActive atomic.Int32
+	require.NoError(t, m.Register("service1", blockingMigrations("service1", started, release, &active, &maxActive)...))
+
+	service, ok := m.service("service1")
+	require.True(t, ok)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- m.Migrate("service1")
+	}()
+	require.Equal(t, "service1", waitStarted(t, started))
+
+	// сервис заблокирован на время выполнения миграции, другие сервисы доступны
+	require.False(t, service.mutex.TryLock())
+	require.NoError(t, m.Migrate("other"))
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- m.Migrate("service1")
+	}()
+
+	close(release)
+	wg.Wait()
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+
+	// второй запуск дожидается завершения первого и не выполняет миграцию повторно
+	require.Equal(t, int32(1), maxActive.Load())
+	require.Empty(t, started)
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+}
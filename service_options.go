@@ -0,0 +1,118 @@
+package db_migrator
+
+import (
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+type ServiceOption func(*ServiceInfo)
+
+// ServiceConfig описывает параметры сервиса, регистрируемого AddService.
+type ServiceConfig struct {
+	// Connect возвращает соединение с базой данных сервиса. Вызывается в начале каждой операции.
+	Connect func() *gorm.DB
+	// Disconnect закрывает соединение, полученное через Connect.
+	Disconnect func(db *gorm.DB)
+	// TargetVersion - версия базы данных, до которой выполняются миграции, в формате major.minor.patch.prerelease
+	// (см. models.ParseVersion) или TargetLatest.
+	TargetVersion string
+	Options       []ServiceOption
+}
+
+// WithAuxiliaryConnection регистрирует дополнительное подключение сервиса. Подключение открывается при выполнении
+// миграции, указавшей name в Migration.UsesAuxiliary, и передается в UpF и DownF в depsDb под именем name. Если
+// disconnectFunc не задан, подключение закрывается через *sql.DB.
+func WithAuxiliaryConnection(name string, connectFunc func() *gorm.DB, disconnectFunc func(db *gorm.DB)) ServiceOption {
+	return func(s *ServiceInfo) {
+		if s.AuxiliaryConnections == nil {
+			s.AuxiliaryConnections = make(map[string]AuxiliaryConnection)
+		}
+		s.AuxiliaryConnections[name] = AuxiliaryConnection{
+			ConnectFunc:    connectFunc,
+			DisconnectFunc: disconnectFunc,
+		}
+	}
+}
+
+// WithRequiredExtensions задает расширения базы данных, необходимые всем миграциям сервиса, в дополнение к
+// Migration.RequiredExtensions.
+func WithRequiredExtensions(extensions ...string) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.requiredExtensions = append(s.requiredExtensions, extensions...)
+	}
+}
+
+// WithSharedDatabase разрешает сервису использовать базу данных, уже используемую другим сервисом. Без опции такое
+// подключение завершается ошибкой ErrDatabaseAlreadyClaimed, так как сервисы используют общие системные таблицы.
+func WithSharedDatabase() ServiceOption {
+	return func(s *ServiceInfo) {
+		s.sharedDatabase = true
+	}
+}
+
+// tablePrefixRegexp - допустимый префикс системных таблиц: буквы в нижнем регистре, цифры и подчеркивание.
+var tablePrefixRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// WithTablePrefix задает префикс системных таблиц сервиса (migrations, version, version_history,
+// migration_state_history, migration_runs, migrator_meta и migrator_lock), позволяя нескольким сервисам использовать
+// одну базу данных без общих системных таблиц, например "billing_" для таблиц billing_migrations и billing_version.
+// Сервис с префиксом может использовать базу данных, уже используемую другим сервисом, без WithSharedDatabase. Если
+// таблицы с префиксом еще не созданы, а база данных используется только этим сервисом, существующие таблицы
+// migrations, version, version_history и migration_state_history без префикса переименовываются при Migrate; общие
+// таблицы migration_runs, migrator_meta и migrator_lock создаются заново.
+func WithTablePrefix(prefix string) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.tableNames.Prefix = prefix
+	}
+}
+
+// systemIdentifierRegexp - допустимое имя системной таблицы или схемы. Имена экранируются в запросах, поэтому
+// допускаются заглавные буквы, дефис и зарезервированные слова, но не пробелы, точки и кавычки.
+var systemIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$-]{0,62}$`)
+
+// WithSystemTableNames заменяет имена таблиц version и migrations сервиса, например если в базе данных есть таблица
+// приложения с таким же именем. Пустое значение оставляет имя по умолчанию. Префикс WithTablePrefix добавляется и к
+// заданным именам. Существующие таблицы с прежними именами не переименовываются.
+func WithSystemTableNames(versionTable string, migrationsTable string) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.tableNames.Version = versionTable
+		s.tableNames.Migrations = migrationsTable
+	}
+}
+
+// WithSchema размещает системные таблицы сервиса (migrations, version, version_history, migration_state_history,
+// migration_runs, migrator_meta и migrator_lock) в схеме schema вместо текущей схемы соединения. Для postgres схема создается при Migrate, если не существует, для
+// mysql schema - имя базы данных, для sqlite - имя присоединенной базы данных; в этих случаях она должна
+// существовать. Миграции сервиса выполняются в текущей схеме соединения.
+func WithSchema(schema string) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.tableNames.Schema = schema
+	}
+}
+
+// WithEnsureDatabase включает создание базы данных сервиса перед подключением к ней в Migrate, если она не существует
+// (postgres и mysql). Требует соединения с правом создания баз данных, поэтому включается только явно.
+func WithEnsureDatabase(config EnsureDatabaseConfig) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.ensureDatabase = &config
+	}
+}
+
+// WithServiceTemplateData задает данные шаблонов миграций сервиса, дополняющие и переопределяющие значения
+// WithTemplateData.
+func WithServiceTemplateData(data map[string]any) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.templateData = data
+	}
+}
+
+// WithAllowOutOfOrder разрешает регистрировать миграции с версией ниже уже сохраненных (например, после слияния
+// веток, в которых миграции создавались параллельно). Вместо ErrRegisteredVersionTooLow такая миграция сохраняется
+// со следующим рангом, выполняется при Migrate, несмотря на более высокую сохраненную версию базы данных, и не
+// понижает сохраненную версию.
+func WithAllowOutOfOrder() ServiceOption {
+	return func(s *ServiceInfo) {
+		s.allowOutOfOrder = true
+	}
+}
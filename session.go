@@ -0,0 +1,52 @@
+package db_migrator
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// session возвращает соединение db с параметрами Migration.SessionOptions. Исходное соединение не изменяется.
+func (m *Migration) session(db *gorm.DB) *gorm.DB {
+	if m.SessionOptions == nil {
+		return db
+	}
+	return db.Session(m.SessionOptions)
+}
+
+// execer возвращает функцию выполнения SQL вне транзакции, возвращающую количество затронутых строк. Если заданы
+// Migration.SessionOptions, запросы выполняются через gorm с этими параметрами, иначе - напрямую через *sql.DB с
+// контекстом db.
+func (m *Migration) execer(db *gorm.DB) (func(query string) (int64, error), error) {
+	if m.SessionOptions != nil {
+		session := m.session(db)
+		return func(query string) (int64, error) {
+			res := session.Exec(query)
+			return res.RowsAffected, res.Error
+		}, nil
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return func(query string) (int64, error) {
+		res, err := sqlDb.ExecContext(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+
+		// не все драйверы поддерживают RowsAffected, в этом случае значение не учитывается
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, nil
+		}
+		return n, nil
+	}, nil
+}
@@ -0,0 +1,121 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sessionLogger - логгер gorm, сохраняющий выполненные запросы.
+type sessionLogger struct {
+	mutex      sync.Mutex
+	statements []string
+}
+
+func (l *sessionLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l *sessionLogger) Info(context.Context, string, ...interface{}) {}
+
+func (l *sessionLogger) Warn(context.Context, string, ...interface{}) {}
+
+func (l *sessionLogger) Error(context.Context, string, ...interface{}) {}
+
+func (l *sessionLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	statement, _ := fc()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.statements = append(l.statements, statement)
+}
+
+func TestSessionOptions(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		name := "transactional"
+		if !transactional {
+			name = "non-transactional"
+		}
+		t.Run(name, func(t *testing.T) {
+			sessionLog := &sessionLogger{}
+			var sessionErr, pooledErr error
+
+			m, connect := newTestManager(t, "1.0.3")
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int, b text); insert into a values (1, null), (2, null)"},
+				Migration{
+					MigrationType:   TypeVersioned,
+					Version:         "1.0.1",
+					IsTransactional: transactional,
+					SessionOptions:  &gorm.Session{AllowGlobalUpdate: true, Logger: sessionLog},
+					UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+						// параметры сессии применены до вызова UpF
+						if !db.AllowGlobalUpdate {
+							return errors.New("AllowGlobalUpdate is not applied")
+						}
+						sessionErr = db.Table("a").Update("b", "updated").Error
+						return sessionErr
+					},
+				},
+				Migration{
+					MigrationType:   TypeVersioned,
+					Version:         "1.0.2",
+					IsTransactional: transactional,
+					SessionOptions:  &gorm.Session{Logger: sessionLog},
+					Up:              "alter table a add column c text",
+				},
+				Migration{
+					MigrationType:   TypeVersioned,
+					Version:         "1.0.3",
+					IsTransactional: transactional,
+					UpF: func(db *gorm.DB, _ map[string]*gorm.DB) error {
+						// параметры сессии предыдущей миграции не остаются на соединении сервиса
+						if db.AllowGlobalUpdate {
+							return errors.New("AllowGlobalUpdate leaked from the previous migration")
+						}
+						pooledErr = db.Table("a").Update("b", "again").Error
+						return nil
+					},
+				},
+			))
+			require.NoError(t, m.Migrate("service1"))
+
+			require.NoError(t, sessionErr)
+			require.ErrorIs(t, pooledErr, gorm.ErrMissingWhereClause)
+
+			var values []string
+			require.NoError(t, connect().Raw("select b from a order by id").Scan(&values).Error)
+			require.Equal(t, []string{"updated", "updated"}, values)
+
+			// запросы миграций с SessionOptions выполнены через логгер сессии, запросы остальных миграций - нет
+			require.Contains(t, sessionLog.statements, "alter table a add column c text")
+			for _, statement := range sessionLog.statements {
+				require.NotContains(t, statement, "again")
+				require.NotContains(t, statement, "migrations")
+			}
+		})
+	}
+}
+
+func TestSessionOptionsDryRun(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			SessionOptions:  &gorm.Session{DryRun: true},
+			Up:              "alter table a add column b text",
+		},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "success", status.Migrations[1].State)
+}
@@ -0,0 +1,115 @@
+package db_migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// MigrationSource открывает текст миграции для чтения. Используется в Migration.UpSource и Migration.DownSource,
+// чтобы не загружать в память содержимое больших миграций при регистрации.
+type MigrationSource func() (io.ReadCloser, error)
+
+// FileSource возвращает MigrationSource, читающий файл name из fsys при каждом обращении.
+func FileSource(fsys fs.FS, name string) MigrationSource {
+	return func() (io.ReadCloser, error) {
+		return fsys.Open(name)
+	}
+}
+
+// validateSources проверяет, что задан ровно один из Up, UpSource и UpF и не более одного из Down, DownSource и DownF.
+func (m *Migration) validateSources() error {
+	up := 0
+	for _, set := range []bool{len(m.Up) > 0, m.UpSource != nil, m.UpF != nil} {
+		if set {
+			up++
+		}
+	}
+	if up != 1 {
+		return fmt.Errorf("exactly one of Up, UpSource and UpF must be set, version: %s", m.Version)
+	}
+
+	down := 0
+	for _, set := range []bool{len(m.Down) > 0, m.DownSource != nil, m.DownF != nil} {
+		if set {
+			down++
+		}
+	}
+	if down > 1 {
+		return fmt.Errorf("only one of Down, DownSource and DownF may be set, version: %s", m.Version)
+	}
+
+	return nil
+}
+
+// hasUpSQL возвращает true, если Up миграции задан текстом (Up или UpSource).
+func (m *Migration) hasUpSQL() bool {
+	return len(m.Up) > 0 || m.UpSource != nil
+}
+
+// hasDownSQL возвращает true, если Down миграции задан текстом (Down или DownSource).
+func (m *Migration) hasDownSQL() bool {
+	return len(m.Down) > 0 || m.DownSource != nil
+}
+
+// hasDown возвращает true, если для миграции задан Down, DownSource или DownF.
+func (m *Migration) hasDown() bool {
+	return m.hasDownSQL() || m.DownF != nil
+}
+
+// upSQL возвращает текст Up, при необходимости читая UpSource. Прочитанное содержимое не сохраняется в миграции.
+func (m *Migration) upSQL() (string, error) {
+	return readSource(m.Up, m.UpSource)
+}
+
+// downSQL возвращает текст Down, при необходимости читая DownSource.
+func (m *Migration) downSQL() (string, error) {
+	return readSource(m.Down, m.DownSource)
+}
+
+// upChecksum вычисляет контрольную сумму текста Up. Для UpSource содержимое читается потоком.
+func (m *Migration) upChecksum() (string, error) {
+	if m.UpSource == nil {
+		return contentChecksum(m.Up), nil
+	}
+	return sourceChecksum(m.UpSource)
+}
+
+func readSource(content string, source MigrationSource) (string, error) {
+	if source == nil {
+		return content, nil
+	}
+
+	reader, err := source()
+	if err != nil {
+		return "", fmt.Errorf("open migration source: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("read migration source: %w", err)
+	}
+	return string(data), nil
+}
+
+// sourceChecksum вычисляет контрольную сумму содержимого source, совпадающую с contentChecksum от того же текста.
+func sourceChecksum(source MigrationSource) (string, error) {
+	reader, err := source()
+	if err != nil {
+		return "", fmt.Errorf("open migration source: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", fmt.Errorf("read migration source: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,111 @@
+package db_migrator
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// countingSource возвращает MigrationSource с текстом content и счетчик открытий источника.
+func countingSource(content string) (MigrationSource, *int) {
+	opens := 0
+	return func() (io.ReadCloser, error) {
+		opens++
+		return io.NopCloser(strings.NewReader(content)), nil
+	}, &opens
+}
+
+func TestUpSourceIsReadLazily(t *testing.T) {
+	up, upOpens := countingSource("alter table a add column b text")
+	down, downOpens := countingSource("alter table a drop column b")
+
+	m, connect := newTestManager(t, "1.0.1")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, UpSource: up, DownSource: down},
+	))
+	// регистрация не читает источники
+	require.Zero(t, *upOpens)
+	require.Zero(t, *downOpens)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.NotZero(t, *upOpens)
+	require.True(t, connect().Migrator().HasColumn("a", "b"))
+
+	// контрольная сумма источника совпадает с контрольной суммой того же текста в Up
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, contentChecksum("alter table a add column b text"), status.Migrations[1].Checksum)
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+	require.NotZero(t, *downOpens)
+	require.False(t, connect().Migrator().HasColumn("a", "b"))
+}
+
+func TestFileSource(t *testing.T) {
+	fsys := fstest.MapFS{"up.sql": {Data: []byte("create table a(id int)")}}
+
+	m, connect := newTestManager(t, "1.0.0")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, UpSource: FileSource(fsys, "up.sql")},
+	))
+	require.NoError(t, m.Migrate("service1"))
+	require.True(t, connect().Migrator().HasTable("a"))
+}
+
+func TestUpSourceOpenError(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.0")
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, UpSource: func() (io.ReadCloser, error) {
+			return nil, errors.New("dump is not available")
+		}},
+	))
+
+	err := m.Migrate("service1")
+	require.ErrorContains(t, err, "dump is not available")
+	require.False(t, connect().Migrator().HasTable("a"))
+}
+
+func TestSourceConflicts(t *testing.T) {
+	source := FileSource(fstest.MapFS{"up.sql": {Data: []byte("create table a(id int)")}}, "up.sql")
+	upF := func(*gorm.DB, map[string]*gorm.DB) error { return nil }
+
+	rejected := map[string]struct {
+		migration Migration
+		err       string
+	}{
+		"up and up source": {
+			migration: Migration{Up: "create table a(id int)", UpSource: source},
+			err:       "exactly one of Up, UpSource and UpF",
+		},
+		"up function and source": {
+			migration: Migration{UpF: upF, UpSource: source},
+			err:       "exactly one of Up, UpSource and UpF",
+		},
+		"without up": {migration: Migration{}, err: "exactly one of Up, UpSource and UpF"},
+		"down and down source": {
+			migration: Migration{UpSource: source, Down: "drop table a", DownSource: source},
+			err:       "only one of Down, DownSource and DownF",
+		},
+		"down function and source": {
+			migration: Migration{UpSource: source, DownSource: source, DownF: upF},
+			err:       "only one of Down, DownSource and DownF",
+		},
+	}
+	for name, test := range rejected {
+		t.Run(name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.0")
+			migration := test.migration
+			migration.MigrationType = TypeVersioned
+			migration.Version = "1.0.0"
+			migration.IsTransactional = true
+
+			require.ErrorContains(t, m.Register("service1", migration), test.err)
+		})
+	}
+}
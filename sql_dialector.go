@@ -0,0 +1,204 @@
+package db_migrator
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+var numericPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// sqlDialector - диалект gorm поверх *sql.DB, открытого приложением, для сервисов, зарегистрированных
+// RegisterServiceSQL. Поддерживает только запросы, выполняемые мигратором к системным таблицам, и не предназначен для
+// использования в коде миграций вместо драйверов gorm.
+type sqlDialector struct {
+	name string
+	conn gorm.ConnPool
+}
+
+func (d sqlDialector) Name() string {
+	return d.name
+}
+
+func (d sqlDialector) Initialize(db *gorm.DB) error {
+	config := &callbacks.Config{}
+	if d.name == "postgres" || d.name == "sqlite" {
+		config.CreateClauses = []string{"INSERT", "VALUES", "ON CONFLICT", "RETURNING"}
+		config.UpdateClauses = []string{"UPDATE", "SET", "FROM", "WHERE", "RETURNING"}
+		config.DeleteClauses = []string{"DELETE", "FROM", "WHERE", "RETURNING"}
+	}
+	callbacks.RegisterDefaultCallbacks(db, config)
+
+	if d.name == "mysql" {
+		db.ClauseBuilders["ON CONFLICT"] = buildMysqlOnConflict
+	}
+
+	db.ConnPool = d.conn
+	return nil
+}
+
+// buildMysqlOnConflict заменяет ON CONFLICT DO NOTHING на ON DUPLICATE KEY UPDATE, не изменяющий запись.
+func buildMysqlOnConflict(c clause.Clause, builder clause.Builder) {
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	stmt, isStatement := builder.(*gorm.Statement)
+	if !ok || !onConflict.DoNothing || !isStatement || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		c.Build(builder)
+		return
+	}
+
+	column := clause.Column{Name: stmt.Schema.PrioritizedPrimaryField.DBName}
+	_, _ = builder.WriteString("ON DUPLICATE KEY UPDATE ")
+	builder.WriteQuoted(column)
+	_ = builder.WriteByte('=')
+	builder.WriteQuoted(column)
+}
+
+func (d sqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlMigrator{
+		Migrator: migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}},
+		dialect:  d.name,
+	}
+}
+
+// DataTypeOf используется только при создании таблиц через gorm, системные таблицы мигратора создаются запросами.
+func (d sqlDialector) DataTypeOf(field *schema.Field) string {
+	switch field.DataType {
+	case schema.Bool:
+		return "BOOLEAN"
+	case schema.Int, schema.Uint:
+		return "BIGINT"
+	case schema.Float:
+		return "DOUBLE PRECISION"
+	case schema.Time:
+		return "TIMESTAMP"
+	case schema.Bytes:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+func (d sqlDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (d sqlDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, _ interface{}) {
+	if d.name == "postgres" {
+		_ = writer.WriteByte('$')
+		_, _ = writer.WriteString(strconv.Itoa(len(stmt.Vars)))
+		return
+	}
+	_ = writer.WriteByte('?')
+}
+
+func (d sqlDialector) QuoteTo(writer clause.Writer, str string) {
+	quote := "\""
+	if d.name == "mysql" {
+		quote = "`"
+	}
+
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			_ = writer.WriteByte('.')
+		}
+		_, _ = writer.WriteString(quote + strings.ReplaceAll(part, quote, quote+quote) + quote)
+	}
+}
+
+func (d sqlDialector) Explain(sql string, vars ...interface{}) string {
+	if d.name == "postgres" {
+		return logger.ExplainSQL(sql, numericPlaceholder, `'`, vars...)
+	}
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+// SavePoint и RollbackTo поддерживают вложенные транзакции gorm (например, сохранение состояния миграции в транзакции
+// миграции); синтаксис точек сохранения одинаков для всех поддерживаемых диалектов.
+func (d sqlDialector) SavePoint(tx *gorm.DB, name string) error {
+	return tx.Exec("SAVEPOINT " + name).Error
+}
+
+func (d sqlDialector) RollbackTo(tx *gorm.DB, name string) error {
+	return tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error
+}
+
+// sqlMigrator проверяет наличие таблиц и колонок запросами, специфичными для диалекта. Для mysql используются
+// запросы gorm к information_schema.
+type sqlMigrator struct {
+	migrator.Migrator
+	dialect string
+}
+
+func (m sqlMigrator) HasTable(value interface{}) bool {
+	var count int64
+
+	_ = m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		switch m.dialect {
+		case "postgres":
+			return m.DB.Raw(
+				"SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ? AND table_type = 'BASE TABLE'",
+				stmt.Table,
+			).Row().Scan(&count)
+		case "sqlite":
+			return m.DB.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", stmt.Table).Row().Scan(&count)
+		default:
+			count = 0
+			if m.Migrator.HasTable(stmt.Table) {
+				count = 1
+			}
+			return nil
+		}
+	})
+
+	return count > 0
+}
+
+func (m sqlMigrator) HasColumn(value interface{}, field string) bool {
+	var count int64
+
+	_ = m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		name := field
+		if stmt.Schema != nil {
+			if field := stmt.Schema.LookUpField(field); field != nil {
+				name = field.DBName
+			}
+		}
+
+		switch m.dialect {
+		case "postgres":
+			return m.DB.Raw(
+				"SELECT count(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?",
+				stmt.Table, name,
+			).Row().Scan(&count)
+		case "sqlite":
+			return m.DB.Raw("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", stmt.Table, name).Row().Scan(&count)
+		default:
+			count = 0
+			if m.Migrator.HasColumn(stmt.Table, name) {
+				count = 1
+			}
+			return nil
+		}
+	})
+
+	return count > 0
+}
+
+func (m sqlMigrator) CurrentDatabase() (name string) {
+	switch m.dialect {
+	case "postgres":
+		_ = m.DB.Raw("SELECT current_database()").Row().Scan(&name)
+	case "sqlite":
+		name = "main"
+	default:
+		name = m.Migrator.CurrentDatabase()
+	}
+	return name
+}
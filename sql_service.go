@@ -0,0 +1,129 @@
+package db_migrator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// RegisterServiceSQL регистрирует сервис, соединение с базой данных которого открывается через database/sql (например,
+// pgx/stdlib или sqlx), без использования gorm в коде приложения. Миграции такого сервиса удобно задавать через
+// MigrationLite (RegisterLite): функции UpF и DownF получают *sql.DB, UpTxF и DownTxF - *sql.Tx.
+//
+// Диалект определяется по типу драйвера соединения (postgres, mysql, sqlite) или задается WithSQLDialect. Для
+// выполнения запросов к системным таблицам мигратор использует собственную прослойку gorm поверх переданного *sql.DB,
+// поэтому Migration.UpF и зависимости получают *gorm.DB с ограниченной поддержкой gorm; для миграций рекомендуется
+// MigrationLite.
+func (m *MigrationManager) RegisterServiceSQL(
+	name string,
+	connect func() (*sql.DB, error),
+	disconnect func(db *sql.DB),
+	targetVersion string,
+	opts ...ServiceOption,
+) error {
+	return m.AddService(name, ServiceConfig{
+		Connect: func() *gorm.DB {
+			dialect := ""
+			if service, ok := m.service(name); ok {
+				dialect = service.sqlDialect
+			}
+			return m.openSQL(name, connect, disconnect, dialect)
+		},
+		Disconnect: func(db *gorm.DB) {
+			sqlDb, err := db.DB()
+			if err != nil {
+				return
+			}
+			if _, failed := sqlDb.Driver().(failedDriver); failed {
+				_ = sqlDb.Close()
+				return
+			}
+			if disconnect != nil {
+				disconnect(sqlDb)
+			}
+		},
+		TargetVersion: targetVersion,
+		Options:       opts,
+	})
+}
+
+// WithSQLDialect задает диалект сервиса, зарегистрированного RegisterServiceSQL ("postgres", "mysql" или "sqlite"),
+// если он не определяется по типу драйвера.
+func WithSQLDialect(dialect string) ServiceOption {
+	return func(s *ServiceInfo) {
+		s.sqlDialect = dialect
+	}
+}
+
+// openSQL открывает соединение gorm поверх *sql.DB, возвращенного connect. Ошибки подключения и определения диалекта
+// возвращаются первым запросом к базе данных.
+func (m *MigrationManager) openSQL(
+	serviceName string,
+	connect func() (*sql.DB, error),
+	disconnect func(db *sql.DB),
+	dialect string,
+) *gorm.DB {
+	sqlDb, err := connect()
+	if err == nil && len(dialect) == 0 {
+		dialect, err = detectSQLDialect(sqlDb)
+	}
+	if err == nil && dialect != "postgres" && dialect != "mysql" && dialect != "sqlite" {
+		err = fmt.Errorf("dialect %q is not supported for database/sql services", dialect)
+	}
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("fail to connect, service: %s, err: %s", serviceName, err))
+		if sqlDb != nil && disconnect != nil {
+			disconnect(sqlDb)
+		}
+		sqlDb = sql.OpenDB(failedConnector{err: err})
+	}
+
+	db, _ := gorm.Open(sqlDialector{name: dialect, conn: sqlDb}, &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
+	})
+	return db
+}
+
+// detectSQLDialect определяет диалект по имени типа драйвера соединения.
+func detectSQLDialect(db *sql.DB) (string, error) {
+	driverType := strings.ToLower(fmt.Sprintf("%T", db.Driver()))
+
+	switch {
+	case strings.Contains(driverType, "pq.") || strings.Contains(driverType, "pgx") ||
+		strings.Contains(driverType, "stdlib.") || strings.Contains(driverType, "postgres"):
+		return "postgres", nil
+	case strings.Contains(driverType, "mysql"):
+		return "mysql", nil
+	case strings.Contains(driverType, "sqlite"):
+		return "sqlite", nil
+	default:
+		return "", fmt.Errorf("cannot detect dialect of driver %s, use WithSQLDialect", driverType)
+	}
+}
+
+// failedConnector возвращает ошибку подключения при каждой попытке получить соединение.
+type failedConnector struct {
+	err error
+}
+
+func (c failedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c failedConnector) Driver() driver.Driver {
+	return failedDriver{err: c.err}
+}
+
+type failedDriver struct {
+	err error
+}
+
+func (d failedDriver) Open(string) (driver.Conn, error) {
+	return nil, d.err
+}
@@ -0,0 +1,157 @@
+package db_migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLTestService регистрирует сервис service1, подключаемый через database/sql к новой базе данных sqlite, и
+// возвращает функцию открытия соединения для проверок.
+func newSQLTestService(t *testing.T, m *MigrationManager, targetVersion string, opts ...ServiceOption) func() *sql.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), fmt.Sprintf("test%d.db", testDatabaseCounter.Add(1))) + "?_busy_timeout=5000"
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite3", dsn)
+		require.NoError(t, err)
+		return db
+	}
+
+	require.NoError(t, m.RegisterServiceSQL(
+		"service1",
+		func() (*sql.DB, error) { return sql.Open("sqlite3", dsn) },
+		func(db *sql.DB) { _ = db.Close() },
+		targetVersion,
+		opts...,
+	))
+	return open
+}
+
+func sqlTestMigrations() []MigrationLite {
+	return []MigrationLite{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			UpTxF: func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table b(id int)")
+				return err
+			},
+			DownTxF: func(tx *sql.Tx) error {
+				_, err := tx.Exec("drop table b")
+				return err
+			},
+		},
+		{
+			MigrationType: TypeVersioned,
+			Version:       "1.0.2",
+			UpF: func(db *sql.DB) error {
+				_, err := db.Exec("create table c(id int)")
+				return err
+			},
+			DownF: func(db *sql.DB) error {
+				_, err := db.Exec("drop table c")
+				return err
+			},
+		},
+		{
+			MigrationType:   TypeRepeatable,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			Up:              "create view if not exists v as select * from a",
+			CheckSum:        func(*sql.DB) string { return "v1" },
+		},
+	}
+}
+
+func sqlTableExists(t *testing.T, open func() *sql.DB, table string) bool {
+	t.Helper()
+
+	db := open()
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = ?", table).Scan(&count))
+	return count > 0
+}
+
+// TestSQLServiceLifecycle проверяет выполнение, проверку и откат миграций сервиса, зарегистрированного
+// RegisterServiceSQL, через прослойку gorm поверх *sql.DB.
+func TestSQLServiceLifecycle(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []ServiceOption
+	}{
+		{name: "default tables"},
+		{name: "table prefix", opts: []ServiceOption{WithTablePrefix("app_")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewMigrationsManager()
+			require.NoError(t, err)
+			open := newSQLTestService(t, m, "1.0.2", tc.opts...)
+			require.NoError(t, m.RegisterLite("service1", sqlTestMigrations()...))
+
+			reason, ok, err := m.CheckFulfillment("service1")
+			require.NoError(t, err)
+			require.False(t, ok)
+			require.NotEmpty(t, reason)
+
+			require.NoError(t, m.Migrate("service1"))
+			for _, table := range []string{"a", "b", "c", "v"} {
+				require.True(t, sqlTableExists(t, open, table), table)
+			}
+
+			reason, ok, err = m.CheckFulfillment("service1")
+			require.NoError(t, err)
+			require.True(t, ok, "%v", reason)
+
+			status, err := m.Status("service1")
+			require.NoError(t, err)
+			require.Equal(t, "1.0.2.0", status.Version)
+			require.Len(t, status.Migrations, 4)
+			require.False(t, status.HasPending)
+
+			require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+			require.False(t, sqlTableExists(t, open, "b"))
+			require.False(t, sqlTableExists(t, open, "c"))
+
+			status, err = m.Status("service1")
+			require.NoError(t, err)
+			require.Equal(t, "1.0.0.0", status.Version)
+
+			_, ok, err = m.CheckFulfillment("service1")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			require.NoError(t, m.MigrateWithOptions("service1", RunOptions{AcknowledgeDirectionChange: true}))
+			require.True(t, sqlTableExists(t, open, "c"))
+
+			reason, ok, err = m.CheckFulfillment("service1")
+			require.NoError(t, err)
+			require.True(t, ok, "%v", reason)
+		})
+	}
+}
+
+func TestSQLServiceConnectError(t *testing.T) {
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+
+	connectErr := errors.New("connection refused")
+	require.NoError(t, m.RegisterServiceSQL(
+		"service1",
+		func() (*sql.DB, error) { return nil, connectErr },
+		func(db *sql.DB) { _ = db.Close() },
+		"1.0.0",
+		WithSQLDialect("sqlite"),
+	))
+	require.NoError(t, m.RegisterLite("service1", sqlTestMigrations()[0]))
+
+	require.ErrorContains(t, m.Migrate("service1"), "connection refused")
+}
@@ -0,0 +1,86 @@
+package db_migrator
+
+import (
+	"fmt"
+	"time"
+)
+
+// StaleRepeatable описывает миграцию типа TypeRepeatable, которая будет выполнена при следующем вызове Migrate.
+type StaleRepeatable struct {
+	Version         string
+	Description     string
+	StoredChecksum  string
+	CurrentChecksum string
+	// LastExecutedOn - время последнего выполнения миграции, nil если миграция не выполнялась.
+	LastExecutedOn *time.Time
+	// Age - время, прошедшее с последнего выполнения миграции.
+	Age time.Duration
+}
+
+// StaleRepeatables возвращает зарегистрированные миграции типа TypeRepeatable, которые будут выполнены при следующем
+// вызове Migrate (например, из-за изменения контрольной суммы или новые), в порядке выполнения. Миграции определяются
+// по плану Migrate (см. Plan), поэтому учитываются ограничения версий и целевая версия. Метод не изменяет базу данных.
+func (m *MigrationManager) StaleRepeatables(serviceName string) ([]StaleRepeatable, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.staleRepeatables(serviceName, service)
+}
+
+func (m *MigrationManager) staleRepeatables(serviceName string, service *ServiceInfo) ([]StaleRepeatable, error) {
+	plan, err := m.previewMigratePlan(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	stale := make([]StaleRepeatable, 0)
+	for _, migrationModel := range plan.Migrations() {
+		if migrationModel.Type != string(TypeRepeatable) {
+			continue
+		}
+
+		// не зарегистрированные миграции планируются только для изменения состояния
+		migration, found, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		entry := StaleRepeatable{
+			Version:         migrationModel.Version.String(),
+			Description:     migrationModel.Description,
+			StoredChecksum:  migrationModel.Checksum,
+			CurrentChecksum: migration.checksum(service.Db),
+		}
+
+		if migrationModel.ExecutedOn != nil {
+			executedOn := migrationModel.ExecutedOn.Time
+			entry.LastExecutedOn = &executedOn
+			entry.Age = now.Sub(executedOn)
+		}
+
+		stale = append(stale, entry)
+	}
+
+	return stale, nil
+}
@@ -0,0 +1,82 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStaleRepeatablesMatchPlan проверяет, что StaleRepeatables возвращает ровно те миграции типа TypeRepeatable,
+// которые выполняет следующий Migrate.
+func TestStaleRepeatablesMatchPlan(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	versioned := []Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	}
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, previous.Register("service1", versioned...))
+	require.NoError(t, previous.Register("service1",
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v1 as select 1", CheckSum: fixtureChecksum("v1")},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.1", IsTransactional: true, Up: "create view v2 as select 1", CheckSum: fixtureChecksum("v1")},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.1.1", IsTransactional: true, Up: "create view v3 as select 1", CheckSum: fixtureChecksum("v1")},
+	))
+	require.NoError(t, previous.Migrate("service1"))
+
+	// следующая версия: v1 не изменилась, v2 изменилась, но больше не применима, v3 изменилась, v4 добавлена, v5
+	// добавлена для версии выше целевой
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2"))
+	require.NoError(t, m.Register("service1", versioned...))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.0", IsTransactional: true, Up: "create view v1 as select 1", CheckSum: fixtureChecksum("v1")},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.1", IsTransactional: true, Up: "drop view v2; create view v2 as select 2", CheckSum: fixtureChecksum("v2"), MaxVersion: "1.0.1"},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.1.1", IsTransactional: true, Up: "drop view v3; create view v3 as select 2", CheckSum: fixtureChecksum("v2")},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.2", IsTransactional: true, Up: "create view v4 as select 1", CheckSum: fixtureChecksum("v1")},
+		Migration{MigrationType: TypeRepeatable, Version: "1.0.2.1", IsTransactional: true, Up: "create view v5 as select 1", CheckSum: fixtureChecksum("v1"), MinVersion: "1.0.3"},
+	))
+
+	stale, err := m.StaleRepeatables("service1")
+	require.NoError(t, err)
+
+	var staleVersions []string
+	for _, repeatable := range stale {
+		staleVersions = append(staleVersions, repeatable.Version)
+	}
+	require.Equal(t, []string{"1.0.1.1", "1.0.2.0"}, staleVersions)
+
+	require.Equal(t, "v1", stale[0].StoredChecksum)
+	require.Equal(t, "v2", stale[0].CurrentChecksum)
+	require.NotNil(t, stale[0].LastExecutedOn)
+	require.Empty(t, stale[1].StoredChecksum)
+	require.Nil(t, stale[1].LastExecutedOn)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, len(stale), status.StaleRepeatables)
+
+	report, err := m.MigrateWithReport(context.Background(), "service1", RunOptions{})
+	require.NoError(t, err)
+
+	var executed []string
+	for _, entry := range report.Entries {
+		if entry.Type == TypeRepeatable && entry.Outcome == OutcomeExecuted {
+			executed = append(executed, entry.Version)
+		}
+	}
+	require.Equal(t, staleVersions, executed)
+
+	stale, err = m.StaleRepeatables("service1")
+	require.NoError(t, err)
+	require.Empty(t, stale)
+
+	status, err = m.Status("service1")
+	require.NoError(t, err)
+	require.Zero(t, status.StaleRepeatables)
+}
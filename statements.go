@@ -0,0 +1,99 @@
+package db_migrator
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// splitStatements разбивает SQL на отдельные выражения по символу ';'. Разделители внутри строковых литералов,
+// идентификаторов в кавычках, комментариев и dollar-quoted строк postgres не учитываются. Пустые выражения
+// отбрасываются.
+func splitStatements(sql string) []string {
+	statements := make([]string, 0)
+	start := 0
+
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\'' || sql[i] == '"' || sql[i] == '`':
+			i = skipQuoted(sql, i, sql[i])
+		case strings.HasPrefix(sql[i:], "--"):
+			i = skipUntil(sql, i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipUntil(sql, i+2, "*/")
+		case sql[i] == '$':
+			if tag, ok := dollarQuoteTag(sql[i:]); ok {
+				i = skipUntil(sql, i+len(tag), tag)
+			}
+		case sql[i] == ';':
+			statements = appendStatement(statements, sql[start:i])
+			start = i + 1
+		}
+	}
+
+	return appendStatement(statements, sql[start:])
+}
+
+// statementSnippetLength - максимальная длина фрагмента выражения в ошибке выполнения.
+const statementSnippetLength = 80
+
+// statementSnippet возвращает начало выражения в одну строку для сообщения об ошибке.
+func statementSnippet(statement string) string {
+	snippet := strings.Join(strings.Fields(statement), " ")
+	if len(snippet) <= statementSnippetLength {
+		return snippet
+	}
+
+	// обрезаем по границе символа UTF-8
+	end := statementSnippetLength
+	for end > 0 && !utf8.RuneStart(snippet[end]) {
+		end--
+	}
+	return snippet[:end] + "..."
+}
+
+func appendStatement(statements []string, statement string) []string {
+	statement = strings.TrimSpace(statement)
+	if len(statement) == 0 {
+		return statements
+	}
+	return append(statements, statement)
+}
+
+// skipQuoted возвращает позицию закрывающей кавычки quote для литерала, начинающегося в позиции i. Удвоенная кавычка
+// считается частью литерала.
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != quote {
+			continue
+		}
+		if j+1 < len(sql) && sql[j+1] == quote {
+			j++
+			continue
+		}
+		return j
+	}
+	return len(sql)
+}
+
+// skipUntil возвращает позицию последнего символа terminator, найденного начиная с позиции i, или конец строки.
+func skipUntil(sql string, i int, terminator string) int {
+	end := strings.Index(sql[i:], terminator)
+	if end < 0 {
+		return len(sql)
+	}
+	return i + end + len(terminator) - 1
+}
+
+// dollarQuoteTag возвращает открывающий тег dollar-quoted строки ($$ или $tag$), с которого начинается sql.
+func dollarQuoteTag(sql string) (string, bool) {
+	for j := 1; j < len(sql); j++ {
+		c := sql[j]
+		if c == '$' {
+			return sql[:j+1], true
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,91 @@
+package db_migrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "empty",
+			sql:  " \n ; ;",
+			want: []string{},
+		},
+		{
+			name: "single statement without terminator",
+			sql:  "create table a(id int)",
+			want: []string{"create table a(id int)"},
+		},
+		{
+			name: "statements are trimmed",
+			sql:  "create table a(id int);\n\n  insert into a values (1) ;",
+			want: []string{"create table a(id int)", "insert into a values (1)"},
+		},
+		{
+			name: "separator in string literal with doubled quote",
+			sql:  "insert into a values ('x;''y;');select 1",
+			want: []string{"insert into a values ('x;''y;')", "select 1"},
+		},
+		{
+			name: "separator in quoted identifiers",
+			sql:  `select "a;b" from t;select ` + "`c;d`" + ` from t`,
+			want: []string{`select "a;b" from t`, "select `c;d` from t"},
+		},
+		{
+			name: "separator in comments",
+			sql:  "select 1; -- comment; still comment\nselect 2; /* block; comment */ select 3",
+			want: []string{"select 1", "-- comment; still comment\nselect 2", "/* block; comment */ select 3"},
+		},
+		{
+			name: "postgres dollar quoting",
+			sql:  "create function f() returns int as $$ begin return 1; end; $$ language plpgsql;select 1",
+			want: []string{"create function f() returns int as $$ begin return 1; end; $$ language plpgsql", "select 1"},
+		},
+		{
+			name: "postgres tagged dollar quoting with nested $$",
+			sql:  "do $body$ begin perform $$;$$; end $body$;select 2",
+			want: []string{"do $body$ begin perform $$;$$; end $body$", "select 2"},
+		},
+		{
+			name: "positional parameters are not dollar quotes",
+			sql:  "select $1;select $2",
+			want: []string{"select $1", "select $2"},
+		},
+		{
+			name: "unterminated literal runs to the end",
+			sql:  "select 'a;b",
+			want: []string{"select 'a;b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, splitStatements(tt.sql))
+		})
+	}
+}
+
+func TestStatementSnippet(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{name: "whitespace is collapsed", statement: "select\n\t1,\n  2", want: "select 1, 2"},
+		{name: "long statement is cut", statement: strings.Repeat("a", 100), want: strings.Repeat("a", 80) + "..."},
+		{name: "cut at rune boundary", statement: "a" + strings.Repeat("я", 50), want: "a" + strings.Repeat("я", 39) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, statementSnippet(tt.statement))
+		})
+	}
+}
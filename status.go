@@ -0,0 +1,174 @@
+package db_migrator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// MigrationStatus описывает сохраненную миграцию в составе ServiceStatus.
+type MigrationStatus = SavedMigrationInfo
+
+// ServiceStatus - состояние миграций сервиса, сохраненное в базе данных.
+type ServiceStatus struct {
+	Service string `json:"service"`
+	// Version - сохраненная версия базы данных, пустая, если версия еще не сохранялась
+	Version string `json:"version"`
+	// VersionSetByVersion, VersionSetByType и VersionSetAt - миграция, после выполнения или отмены которой сохранена
+	// версия, и время сохранения (см. VersionRecord); пусты в StatusAt
+	VersionSetByVersion string     `json:"version_set_by_version,omitempty"`
+	VersionSetByType    string     `json:"version_set_by_type,omitempty"`
+	VersionSetAt        *time.Time `json:"version_set_at,omitempty"`
+	// Migrations - сохраненные миграции, кроме миграций в состоянии models.StateAbandoned
+	Migrations []MigrationStatus `json:"migrations"`
+	// Abandoned - миграции, переведенные в состояние models.StateAbandoned (см. AbandonRegistrations)
+	Abandoned []MigrationStatus `json:"abandoned"`
+	// HasPending - есть невыполненные миграции (см. ErrHasForthcomingMigrations)
+	HasPending bool `json:"has_pending"`
+	// HasFailed - есть миграции, завершившиеся ошибкой (см. ErrHasFailedMigrations)
+	HasFailed bool `json:"has_failed"`
+	// Dirty - признак наличия миграций в состояниях failure и running, сохраненный в migrator_meta (см. IsDirty)
+	Dirty bool `json:"dirty"`
+	// StaleRepeatables - количество миграций типа TypeRepeatable, которые будут выполнены при следующем вызове Migrate
+	// (см. StaleRepeatables)
+	StaleRepeatables int `json:"stale_repeatables"`
+	// Compatibility - соотношение миграций приложения и базы данных (см. Compatibility)
+	Compatibility CompatibilityReport `json:"compatibility"`
+	// At - момент времени, на который определены Version и Migrations (см. StatusAt), nil для текущего состояния
+	At *time.Time `json:"at,omitempty"`
+}
+
+// Status возвращает сохраненную версию и историю миграций сервиса в порядке сохранения. Признаки HasPending и
+// HasFailed определяются так же, как в CheckFulfillment. Метод не изменяет базу данных.
+func (m *MigrationManager) Status(serviceName string) (ServiceStatus, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return ServiceStatus{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	return m.status(serviceName, service)
+}
+
+// StatusAt возвращает состояние миграций сервиса на момент t: Version - версия базы данных на момент t (см. VersionAt),
+// Migrations - сохраненные миграции, выполненные или отмененные не позднее t. Таблица migrations хранит только время
+// последнего выполнения миграции, поэтому выполненные позднее t миграции в Migrations не попадают, даже если они
+// выполнялись и раньше. Abandoned, StaleRepeatables и признаки HasPending, HasFailed, Dirty и Compatibility описывают
+// текущее состояние.
+func (m *MigrationManager) StatusAt(serviceName string, t time.Time) (ServiceStatus, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return ServiceStatus{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	status, err := m.status(serviceName, service)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	t = t.UTC()
+	status.At = &t
+	status.VersionSetByVersion, status.VersionSetByType, status.VersionSetAt = "", "", nil
+	status.Version, err = m.versionAt(serviceName, service, t)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	migrations := make([]MigrationStatus, 0, len(status.Migrations))
+	for _, migration := range status.Migrations {
+		if migration.ExecutedOn != nil && !migration.ExecutedOn.After(t) {
+			migrations = append(migrations, migration)
+		}
+	}
+	status.Migrations = migrations
+
+	return status, nil
+}
+
+func (m *MigrationManager) status(serviceName string, service *ServiceInfo) (ServiceStatus, error) {
+	status := ServiceStatus{
+		Service:    serviceName,
+		Migrations: []MigrationStatus{},
+		Abandoned:  []MigrationStatus{},
+	}
+
+	if repository.HasVersionTable(service.Db) {
+		row, err := repository.GetVersionRow(service.Db)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return ServiceStatus{}, err
+		}
+		if err == nil {
+			status.Version = row.Version.String()
+			status.VersionSetByVersion = row.SetByVersion
+			status.VersionSetByType = row.SetByType
+			if row.SetAt != nil {
+				setAt := row.SetAt.Time
+				status.VersionSetAt = &setAt
+			}
+		}
+	}
+
+	if repository.HasMigrationsTable(service.Db) {
+		savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+		if err != nil {
+			return ServiceStatus{}, err
+		}
+
+		for _, migrationModel := range savedMigrations {
+			if migrationModel.State == models.StateAbandoned {
+				status.Abandoned = append(status.Abandoned, newSavedMigrationInfo(migrationModel))
+				continue
+			}
+			status.Migrations = append(status.Migrations, newSavedMigrationInfo(migrationModel))
+		}
+	}
+
+	var err error
+	status.HasPending, err = m.hasForthcomingMigrations(serviceName)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	status.HasFailed, err = m.hasFailedMigrations(serviceName)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	status.Dirty, err = isDirty(service.Db)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	stale, err := m.staleRepeatables(serviceName, service)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+	status.StaleRepeatables = len(stale)
+
+	status.Compatibility, err = m.compatibility(serviceName)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	return status, nil
+}
@@ -0,0 +1,44 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusVersionProvenance(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Empty(t, status.Version)
+	require.Empty(t, status.VersionSetByType)
+	require.Nil(t, status.VersionSetAt)
+
+	require.NoError(t, m.Migrate("service1"))
+
+	status, err = m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", status.Version)
+	require.Equal(t, "1.0.2.0", status.VersionSetByVersion)
+	require.Equal(t, string(TypeVersioned), status.VersionSetByType)
+	require.NotNil(t, status.VersionSetAt)
+	migratedAt := *status.VersionSetAt
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+
+	status, err = m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.1.0", status.Version)
+	require.Equal(t, "1.0.2.0", status.VersionSetByVersion, "version is set by the undone migration")
+	require.Equal(t, VersionSetByUndo, status.VersionSetByType)
+	require.NotNil(t, status.VersionSetAt)
+	require.False(t, status.VersionSetAt.Before(migratedAt))
+
+	record, err := m.SavedVersionRecord("service1")
+	require.NoError(t, err)
+	require.Equal(t, status.Version, record.Version.String())
+	require.Equal(t, status.VersionSetByVersion, record.SetByVersion)
+	require.Equal(t, status.VersionSetByType, record.SetByType)
+}
@@ -0,0 +1,87 @@
+package db_migrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+)
+
+// maxVersionSuggestions - количество ближайших версий, предлагаемых в ошибке о ненайденной миграции
+const maxVersionSuggestions = 3
+
+// suggestMigrations возвращает зарегистрированные миграции, похожие на искомую: миграции с той же версией, но другим
+// типом, и ближайшие по версии миграции типа migrationType. Каждая подсказка имеет вид "<тип> <версия>".
+func suggestMigrations(registered []*Migration, migrationType string, version models.Version) []string {
+	suggestions := make([]string, 0)
+	sameType := make([]models.Version, 0)
+
+	for _, migration := range registered {
+		migrationVersion, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			continue
+		}
+
+		if string(migration.MigrationType) != migrationType {
+			if migrationVersion.Equals(version) {
+				suggestions = append(suggestions, fmt.Sprintf("%s %s", migration.MigrationType, migrationVersion))
+			}
+			continue
+		}
+
+		if !migrationVersion.Equals(version) {
+			sameType = append(sameType, migrationVersion)
+		}
+	}
+
+	sort.Slice(sameType, func(i, j int) bool {
+		return sameType[i].LessThan(sameType[j])
+	})
+
+	// ближайшие версии выбираются поочередно ниже и выше искомой
+	above := sort.Search(len(sameType), func(i int) bool {
+		return sameType[i].MoreThan(version)
+	})
+	below := above - 1
+
+	nearest := make([]models.Version, 0, maxVersionSuggestions)
+	for len(nearest) < maxVersionSuggestions && (below >= 0 || above < len(sameType)) {
+		if above < len(sameType) {
+			nearest = append(nearest, sameType[above])
+			above++
+		}
+		if below >= 0 && len(nearest) < maxVersionSuggestions {
+			nearest = append(nearest, sameType[below])
+			below--
+		}
+	}
+
+	sort.Slice(nearest, func(i, j int) bool {
+		return nearest[i].LessThan(nearest[j])
+	})
+
+	for _, nearestVersion := range nearest {
+		suggestions = append(suggestions, fmt.Sprintf("%s %s", migrationType, nearestVersion))
+	}
+
+	return suggestions
+}
+
+// migrationNotFound возвращает ошибку о том, что миграция не зарегистрирована, с подсказкой о похожих
+// зарегистрированных миграциях.
+func (m *MigrationManager) migrationNotFound(serviceName string, migrationType string, version models.Version) error {
+	err := fmt.Errorf("%w (type: %s, Version: %s)", ErrMigrationNotFound, migrationType, version)
+
+	service, ok := m.service(serviceName)
+	if !ok {
+		return err
+	}
+
+	suggestions := suggestMigrations(service.registeredMigrations, migrationType, version)
+	if len(suggestions) == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%w, registered similar migrations: %s", err, strings.Join(suggestions, ", "))
+}
@@ -0,0 +1,74 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestMigrations(t *testing.T) {
+	registered := []*Migration{
+		{MigrationType: TypeBaseline, Version: "1.0.3"},
+		{MigrationType: TypeVersioned, Version: "1.0.1"},
+		{MigrationType: TypeVersioned, Version: "1.0.2"},
+		{MigrationType: TypeVersioned, Version: "1.0.3.1"},
+		{MigrationType: TypeVersioned, Version: "1.0.5"},
+		{MigrationType: TypeVersioned, Version: "2.0.0"},
+		{MigrationType: TypeRepeatable, Version: "1.0.3"},
+	}
+
+	tests := []struct {
+		name          string
+		migrationType MigrationType
+		version       string
+		suggestions   []string
+	}{
+		{
+			name:          "off by one",
+			migrationType: TypeVersioned,
+			version:       "1.0.3",
+			suggestions: []string{
+				"baseline 1.0.3.0", "repeatable 1.0.3.0",
+				"versioned 1.0.2.0", "versioned 1.0.3.1", "versioned 1.0.5.0",
+			},
+		},
+		{
+			name:          "wrong type",
+			migrationType: TypeBaseline,
+			version:       "1.0.1",
+			suggestions:   []string{"versioned 1.0.1.0", "baseline 1.0.3.0"},
+		},
+		{
+			name:          "above all",
+			migrationType: TypeVersioned,
+			version:       "3.0.0",
+			suggestions:   []string{"versioned 1.0.3.1", "versioned 1.0.5.0", "versioned 2.0.0.0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := models.ParseVersion(test.version)
+			require.NoError(t, err)
+			require.Equal(t, test.suggestions, suggestMigrations(registered, string(test.migrationType), version))
+		})
+	}
+
+	version, err := models.ParseVersion("1.0.3")
+	require.NoError(t, err)
+	require.Empty(t, suggestMigrations(nil, string(TypeVersioned), version))
+}
+
+func TestMigrationNotFoundSuggestions(t *testing.T) {
+	m, _ := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+	require.NoError(t, m.Migrate("service1"))
+
+	err := m.ApplyOne("service1", "1.0.3", TypeVersioned, false)
+	require.ErrorIs(t, err, ErrMigrationNotFound)
+	require.ErrorContains(t, err, "registered similar migrations: versioned 1.0.1.0, versioned 1.0.2.0")
+
+	err = m.ApplyOne("service1", "1.0.0", TypeVersioned, false)
+	require.ErrorIs(t, err, ErrMigrationNotFound)
+	require.ErrorContains(t, err, "registered similar migrations: baseline 1.0.0.0, versioned 1.0.1.0, versioned 1.0.2.0")
+}
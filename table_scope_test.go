@@ -0,0 +1,89 @@
+package db_migrator
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTablePrefixIsolatesSystemTables(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+
+	for _, prefix := range []string{"billing_", "orders_"} {
+		m, err := NewMigrationsManager()
+		require.NoError(t, err)
+		require.NoError(t, m.AddService("service1", ServiceConfig{
+			Connect:       connect,
+			Disconnect:    disconnect,
+			TargetVersion: "1.0.0",
+			Options:       []ServiceOption{WithTablePrefix(prefix)},
+		}))
+		require.NoError(t, m.Register("service1",
+			Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table " + prefix + "a(id int)"},
+		))
+		require.NoError(t, m.Migrate("service1"))
+
+		runs, err := m.Runs("service1", 0)
+		require.NoError(t, err)
+		require.Len(t, runs, 1, "runs of one prefix must not include runs of another")
+
+		dirty, err := m.IsDirty("service1")
+		require.NoError(t, err)
+		require.False(t, dirty)
+	}
+
+	var tables []string
+	require.NoError(t, connect().Raw("select name from sqlite_master where type = 'table'").Scan(&tables).Error)
+	sort.Strings(tables)
+	require.Equal(t, []string{
+		"billing_a", "billing_migration_runs", "billing_migration_state_history", "billing_migrations",
+		"billing_migrator_lock", "billing_migrator_meta", "billing_version", "billing_version_history",
+		"orders_a", "orders_migration_runs", "orders_migration_state_history", "orders_migrations",
+		"orders_migrator_lock", "orders_migrator_meta", "orders_version", "orders_version_history",
+	}, tables)
+}
+
+func TestSchemaHoldsAllSystemTables(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+	systemPath := filepath.Join(t.TempDir(), "system.db")
+
+	// присоединенная база данных sqlite доступна только в соединении, в котором выполнен ATTACH
+	attached := func() *gorm.DB {
+		db := connect()
+		sqlDb, err := db.DB()
+		require.NoError(t, err)
+		sqlDb.SetMaxOpenConns(1)
+		require.NoError(t, db.Exec("ATTACH DATABASE ? AS sys", systemPath).Error)
+		return db
+	}
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.AddService("service1", ServiceConfig{
+		Connect:       attached,
+		Disconnect:    disconnect,
+		TargetVersion: "1.0.0",
+		Options:       []ServiceOption{WithSchema("sys")},
+	}))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	runs, err := m.Runs("service1", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+
+	db := attached()
+	var mainTables, systemTables []string
+	require.NoError(t, db.Raw("select name from main.sqlite_master where type = 'table' order by name").Scan(&mainTables).Error)
+	require.NoError(t, db.Raw("select name from sys.sqlite_master where type = 'table' order by name").Scan(&systemTables).Error)
+	require.Equal(t, []string{"a"}, mainTables)
+	require.Equal(t, []string{
+		"migration_runs", "migration_state_history", "migrations", "migrator_lock", "migrator_meta", "version",
+		"version_history",
+	}, systemTables)
+}
@@ -0,0 +1,27 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetLatest(t *testing.T) {
+	m, connect := newTestManager(t, TargetLatest)
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.1.1", IsTransactional: true, Up: "alter table a add column c text"},
+	))
+
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+	var version string
+	require.NoError(t, db.Raw("select version from version").Scan(&version).Error)
+	require.Equal(t, "1.1.1.0", version)
+
+	reason, ok, err := m.CheckFulfillment("service1")
+	require.NoError(t, err)
+	require.True(t, ok, reason)
+}
@@ -0,0 +1,95 @@
+package db_migrator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs - функции, доступные в шаблонах миграций с Migration.RenderTemplate.
+var templateFuncs = template.FuncMap{
+	"quoteIdent":   quoteIdent,
+	"quoteLiteral": quoteLiteral,
+}
+
+// quoteIdent заключает идентификатор в двойные кавычки, удваивая кавычки внутри него.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral заключает строку в одинарные кавычки, удваивая кавычки внутри нее.
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// serviceTemplateData возвращает данные шаблонов сервиса: значения WithTemplateData, дополненные и переопределенные
+// значениями WithServiceTemplateData.
+func (m *MigrationManager) serviceTemplateData(service *ServiceInfo) map[string]any {
+	data := make(map[string]any, len(m.templateData)+len(service.templateData))
+	maps.Copy(data, m.templateData)
+	maps.Copy(data, service.templateData)
+	return data
+}
+
+// renderTemplates выполняет шаблоны Up и Down миграции с Migration.RenderTemplate. Up и Down заменяются результатом,
+// поэтому контрольная сумма вычисляется от SQL конкретного окружения. UpSource и DownSource оборачиваются и
+// выполняются при чтении, ошибки в них возвращаются при планировании или выполнении.
+func renderTemplates(migration *Migration, data map[string]any) error {
+	if !migration.RenderTemplate {
+		return nil
+	}
+
+	var err error
+	if len(migration.Up) > 0 {
+		migration.Up, err = renderTemplate(migration.Version+"/up", migration.Up, data)
+		if err != nil {
+			return err
+		}
+	}
+	if len(migration.Down) > 0 {
+		migration.Down, err = renderTemplate(migration.Version+"/down", migration.Down, data)
+		if err != nil {
+			return err
+		}
+	}
+	if migration.UpSource != nil {
+		migration.UpSource = renderedSource(migration.Version+"/up", migration.UpSource, data)
+	}
+	if migration.DownSource != nil {
+		migration.DownSource = renderedSource(migration.Version+"/down", migration.DownSource, data)
+	}
+
+	return nil
+}
+
+func renderTemplate(name string, text string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse migration template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("render migration template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// renderedSource возвращает MigrationSource, выполняющий шаблон из source при каждом чтении.
+func renderedSource(name string, source MigrationSource, data map[string]any) MigrationSource {
+	return func() (io.ReadCloser, error) {
+		text, err := readSource("", source)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := renderTemplate(name, text, data)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(strings.NewReader(rendered)), nil
+	}
+}
@@ -0,0 +1,134 @@
+package db_migrator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	m, err := NewMigrationsManager(WithTemplateData(map[string]any{"table": "a", "column": "b"}))
+	require.NoError(t, err)
+
+	sources := fstest.MapFS{
+		"up.sql":   {Data: []byte("alter table {{ .table }} add column c text")},
+		"down.sql": {Data: []byte("alter table {{ .table }} drop column c")},
+	}
+
+	connect, disconnect := newTestDatabase(t)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.2",
+		WithServiceTemplateData(map[string]any{"column": "b b"}),
+	))
+	require.NoError(t, m.Register("service1",
+		Migration{
+			MigrationType:   TypeBaseline,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			RenderTemplate:  true,
+			Up:              "create table {{ quoteIdent .table }}(id int)",
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.1",
+			IsTransactional: true,
+			RenderTemplate:  true,
+			Up:              "alter table {{ .table }} add column {{ quoteIdent .column }} text default {{ quoteLiteral \"it's\" }}",
+			Down:            "alter table {{ .table }} drop column {{ quoteIdent .column }}",
+		},
+		Migration{
+			MigrationType:   TypeVersioned,
+			Version:         "1.0.2",
+			IsTransactional: true,
+			RenderTemplate:  true,
+			UpSource:        FileSource(sources, "up.sql"),
+			DownSource:      FileSource(sources, "down.sql"),
+		},
+	))
+	require.NoError(t, m.Migrate("service1"))
+
+	db := connect()
+	// значение сервиса переопределяет значение менеджера
+	require.True(t, db.Migrator().HasColumn("a", "b b"))
+	require.True(t, db.Migrator().HasColumn("a", "c"))
+
+	var value string
+	require.NoError(t, db.Exec("insert into a(id) values (1)").Error)
+	require.NoError(t, db.Raw(`select "b b" from a`).Scan(&value).Error)
+	require.Equal(t, "it's", value)
+
+	// контрольная сумма вычисляется от результата шаблона
+	status, err := m.Status("service1")
+	require.NoError(t, err)
+	require.Equal(t, contentChecksum(`alter table a add column "b b" text default 'it''s'`), status.Migrations[1].Checksum)
+	require.Equal(t, contentChecksum("alter table a add column c text"), status.Migrations[2].Checksum)
+
+	require.NoError(t, m.DowngradeTo("service1", "1.0.0"))
+	require.False(t, db.Migrator().HasColumn("a", "c"))
+	require.False(t, db.Migrator().HasColumn("a", "b b"))
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	tests := map[string]struct {
+		migration Migration
+		err       string
+	}{
+		"missing variable": {
+			migration: Migration{Up: "create table {{ .missing }}(id int)"},
+			err:       `render migration template 1.0.0/up`,
+		},
+		"missing variable in down": {
+			migration: Migration{Up: "create table a(id int)", Down: "drop table {{ .missing }}"},
+			err:       `render migration template 1.0.0/down`,
+		},
+		"syntax": {
+			migration: Migration{Up: "create table {{ .table (id int)"},
+			err:       "parse migration template 1.0.0/up",
+		},
+		"unknown function": {
+			migration: Migration{Up: "create table {{ quote .table }}(id int)"},
+			err:       "parse migration template 1.0.0/up",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, _ := newTestManager(t, "1.0.0", WithTemplateData(map[string]any{"table": "a"}))
+
+			migration := test.migration
+			migration.MigrationType = TypeVersioned
+			migration.Version = "1.0.0"
+			migration.IsTransactional = true
+			migration.RenderTemplate = true
+
+			// ошибка возвращается при регистрации, миграция не регистрируется
+			require.ErrorContains(t, m.Register("service1", migration), test.err)
+			plan, err := m.Plan("service1")
+			require.NoError(t, err)
+			require.Empty(t, plan)
+		})
+	}
+
+	t.Run("missing variable in source", func(t *testing.T) {
+		m, connect := newTestManager(t, "1.0.0")
+		require.NoError(t, m.Register("service1", Migration{
+			MigrationType:   TypeBaseline,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			RenderTemplate:  true,
+			UpSource:        FileSource(fstest.MapFS{"up.sql": {Data: []byte("create table {{ .missing }}(id int)")}}, "up.sql"),
+		}))
+
+		require.ErrorContains(t, m.Migrate("service1"), "render migration template 1.0.0/up")
+		require.False(t, connect().Migrator().HasTable("a"))
+	})
+
+	t.Run("without render template", func(t *testing.T) {
+		m, _ := newTestManager(t, "1.0.0")
+		require.NoError(t, m.Register("service1", Migration{
+			MigrationType:   TypeBaseline,
+			Version:         "1.0.0",
+			IsTransactional: true,
+			Up:              "create table {{ .missing }}(id int)",
+		}))
+	})
+}
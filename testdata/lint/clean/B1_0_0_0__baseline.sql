@@ -0,0 +1,2 @@
+-- migrator:ticket=OPS-1
+create table a(id int)
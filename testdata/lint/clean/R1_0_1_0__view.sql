@@ -0,0 +1,2 @@
+-- migrator:min-version=1.0.1.0
+create view if not exists v as select id from a
@@ -0,0 +1,2 @@
+-- migrator:ticket=OPS-2
+alter table a add column b text
@@ -0,0 +1,3 @@
+-- migrator:ticket=OPS-1
+create table a(id int);
+create table legacy(id int)
@@ -0,0 +1 @@
+create table legacy(id int)
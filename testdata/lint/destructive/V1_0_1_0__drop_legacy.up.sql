@@ -0,0 +1,2 @@
+-- migrator:ticket=OPS-2
+drop table legacy
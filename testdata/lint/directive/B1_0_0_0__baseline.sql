@@ -0,0 +1 @@
+create table a(id int)
@@ -0,0 +1,2 @@
+-- migrator:min-version=1.x
+create view if not exists v as select id from a
@@ -0,0 +1 @@
+create table {{ quoteIdent .schema }}.a(id int)
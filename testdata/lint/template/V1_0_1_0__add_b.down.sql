@@ -0,0 +1 @@
+alter table {{ quoteIdent .schema }}.a drop column b
@@ -0,0 +1 @@
+alter table {{ quoteIdent .schema }}.a add column {{ .column }} text
@@ -0,0 +1 @@
+alter table a drop column c
@@ -0,0 +1 @@
+alter table a add column c text
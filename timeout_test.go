@@ -0,0 +1,56 @@
+package db_migrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// blockingUp ожидает завершения контекста соединения миграции.
+func blockingUp(db *gorm.DB, _ map[string]*gorm.DB) error {
+	<-db.Statement.Context.Done()
+	return db.Statement.Context.Err()
+}
+
+func TestMigrationTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ManagerOption
+		migration Migration
+	}{
+		{
+			name:      "migration timeout",
+			migration: Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Timeout: 100 * time.Millisecond, UpF: blockingUp},
+		},
+		{
+			name:      "default timeout",
+			opts:      []ManagerOption{WithMigrationTimeout(100 * time.Millisecond)},
+			migration: Migration{MigrationType: TypeVersioned, Version: "1.0.1", UpF: blockingUp},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, connect := newTestManager(t, "1.0.1", test.opts...)
+			require.NoError(t, m.Register("service1",
+				Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+				test.migration,
+			))
+
+			startedAt := time.Now()
+			err := m.Migrate("service1")
+			require.ErrorIs(t, err, ErrMigrationTimeout)
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			require.Less(t, time.Since(startedAt), 5*time.Second)
+
+			savedMigrations, err := repository.GetMigrationsSorted(connect(), repository.OrderASC)
+			require.NoError(t, err)
+			require.Equal(t, models.StateSuccess, savedMigrations[0].State)
+			require.Equal(t, models.StateFailure, savedMigrations[1].State)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package db_migrator
+
+import (
+	"log/slog"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition - недопустимое изменение состояния миграции (например, отмененной миграции в пропущенную).
+// Допустимые изменения перечислены в internal/repository/state.go.
+var ErrInvalidTransition = repository.ErrInvalidTransition
+
+// transitionExecuted изменяет состояние выполненной или отмененной миграции и сохраняет время выполнения, контрольную
+// сумму и выполнивший ее процесс.
+func transitionExecuted(
+	db *gorm.DB,
+	migrationModel *models.MigrationModel,
+	state models.MigrationState,
+	reason string,
+	checksum string,
+	executedBy repository.ExecutedBy,
+) error {
+	err := repository.TransitionState(db, migrationModel, state, reason)
+	if err != nil {
+		return err
+	}
+	return repository.UpdateMigrationExecuted(db, migrationModel, checksum, executedBy)
+}
+
+// executedBy возвращает процесс, выполняющий миграции сервиса: идентификатор (см. WithIdentity), целевую версию
+// сервиса в качестве версии приложения и метки выполняемого запуска.
+func (m *MigrationManager) executedBy(service *ServiceInfo) repository.ExecutedBy {
+	return repository.ExecutedBy{
+		AppliedBy:  m.appliedBy(),
+		AppVersion: service.serviceTargetVersion().String(),
+		RunLabels:  encodeRunLabels(service.runLabels),
+	}
+}
+
+// executedByAttrs возвращает атрибуты лога с процессом, выполняющим миграции сервиса, совпадающие со значениями,
+// сохраняемыми в таблице migrations.
+func (m *MigrationManager) executedByAttrs(serviceName string) []any {
+	service, ok := m.service(serviceName)
+	if !ok {
+		return nil
+	}
+
+	executedBy := m.executedBy(service)
+	return []any{slog.String("applied_by", executedBy.AppliedBy), slog.String("app_version", executedBy.AppVersion)}
+}
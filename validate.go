@@ -0,0 +1,230 @@
+package db_migrator
+
+import (
+	"fmt"
+
+	"github.com/Maksumys/db-migrator/internal/models"
+	"github.com/Maksumys/db-migrator/internal/repository"
+)
+
+// ValidationSeverity - критичность замечания Validate.
+type ValidationSeverity string
+
+const (
+	// ValidationError - Migrate завершится ошибкой или выполнит миграции не так, как ожидается.
+	ValidationError ValidationSeverity = "error"
+	// ValidationWarning - состояние допустимо, но требует внимания.
+	ValidationWarning ValidationSeverity = "warning"
+)
+
+// ValidationKind - вид замечания Validate.
+type ValidationKind string
+
+const (
+	// ValidationOrphan - сохраненная миграция не зарегистрирована в приложении.
+	ValidationOrphan ValidationKind = "orphan"
+	// ValidationChecksumMismatch - выполненная миграция была изменена после выполнения (см. ErrChecksumMismatch).
+	ValidationChecksumMismatch ValidationKind = "checksum_mismatch"
+	// ValidationFailed - сохраненная миграция в состоянии failure.
+	ValidationFailed ValidationKind = "failed"
+	// ValidationInterrupted - выполнение нетранзакционной миграции было прервано, миграция в состоянии running
+	// (см. ErrPreviousRunInterrupted).
+	ValidationInterrupted ValidationKind = "interrupted"
+	// ValidationNotExecuted - сохраненная миграция в состоянии registered, еще не выполнялась.
+	ValidationNotExecuted ValidationKind = "not_executed"
+	// ValidationVersionTooLow - новая миграция имеет версию ниже уже сохраненной (см. ErrRegisteredVersionTooLow).
+	ValidationVersionTooLow ValidationKind = "version_too_low"
+)
+
+// ValidationFinding - замечание Validate об одной миграции.
+type ValidationFinding struct {
+	Severity      ValidationSeverity `json:"severity"`
+	Kind          ValidationKind     `json:"kind"`
+	MigrationType MigrationType      `json:"migration_type"`
+	Version       string             `json:"version"`
+	Message       string             `json:"message"`
+}
+
+func (f ValidationFinding) String() string {
+	return fmt.Sprintf("%s: %s %s: %s (%s)", f.Severity, f.MigrationType, f.Version, f.Message, f.Kind)
+}
+
+// ValidationResult - результат Validate.
+type ValidationResult struct {
+	Service  string              `json:"service"`
+	Findings []ValidationFinding `json:"findings"`
+	// SafeToMigrate - нет замечаний с критичностью ValidationError
+	SafeToMigrate bool `json:"safe_to_migrate"`
+}
+
+func (r *ValidationResult) add(severity ValidationSeverity, kind ValidationKind, migrationType string, version string, message string) {
+	r.Findings = append(r.Findings, ValidationFinding{
+		Severity:      severity,
+		Kind:          kind,
+		MigrationType: MigrationType(migrationType),
+		Version:       version,
+		Message:       message,
+	})
+	if severity == ValidationError {
+		r.SafeToMigrate = false
+	}
+}
+
+// Validate сравнивает зарегистрированные миграции с сохраненными в базе данных, не выполняя миграции и не изменяя
+// базу данных: находит сохраненные, но не зарегистрированные миграции, измененные после выполнения миграции,
+// миграции в состояниях failure, running и registered и новые миграции с версией ниже сохраненных. В отличие от
+// CheckFulfillment, отвечающего на вопрос, выполнены ли все миграции, Validate проверяет, что Migrate можно
+// безопасно запустить с текущим набором миграций.
+func (m *MigrationManager) Validate(serviceName string) (ValidationResult, error) {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		return ValidationResult{}, m.misuse(fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName))
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.Db = service.open()
+	defer func() {
+		service.DisconnectFunc(service.Db)
+	}()
+
+	err := m.checkLibraryVersion(service.Db)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	result := ValidationResult{
+		Service:       serviceName,
+		Findings:      []ValidationFinding{},
+		SafeToMigrate: true,
+	}
+
+	if !repository.HasMigrationsTable(service.Db) {
+		return result, nil
+	}
+
+	savedMigrations, err := m.getSavedMigrations(service.Db, repository.OrderASC)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	err = m.validateSaved(serviceName, savedMigrations, &result)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	mismatches, err := m.checksumMismatches(service.Db, serviceName, savedMigrations)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	severity := ValidationError
+	if m.skipChecksumValidation {
+		severity = ValidationWarning
+	}
+	for _, mismatch := range mismatches {
+		result.add(
+			severity, ValidationChecksumMismatch, string(mismatch.MigrationType), mismatch.Version,
+			fmt.Sprintf("stored checksum %s differs from registered %s", mismatch.Stored, mismatch.Expected),
+		)
+	}
+
+	err = validateRegisteredVersions(service, savedMigrations, &result)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	return result, nil
+}
+
+// validateSaved проверяет сохраненные миграции: наличие регистрации и состояние.
+func (m *MigrationManager) validateSaved(
+	serviceName string,
+	savedMigrations []models.MigrationModel,
+	result *ValidationResult,
+) error {
+	service, ok := m.service(serviceName)
+
+	if !ok {
+		m.logger.Error(fmt.Sprintf("service %s not found", serviceName))
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+	}
+
+	var maxRegisteredVersion models.Version
+	for _, migration := range service.registeredMigrations {
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return err
+		}
+		if version.MoreThan(maxRegisteredVersion) {
+			maxRegisteredVersion = version
+		}
+	}
+
+	for _, migrationModel := range savedMigrations {
+		version := migrationModel.Version.String()
+
+		_, found, err := m.findMigration(serviceName, migrationModel)
+		if err != nil {
+			return err
+		}
+		if !found && migrationModel.State != models.StateAbandoned {
+			// миграции выше зарегистрированных означают, что база данных обновлена более новой версией приложения
+			if migrationModel.Version.MoreThan(maxRegisteredVersion) && m.forbidOlderBinary {
+				result.add(ValidationError, ValidationOrphan, migrationModel.Type, version,
+					"migration is saved but not registered, database is ahead of binary")
+			} else {
+				result.add(ValidationWarning, ValidationOrphan, migrationModel.Type, version,
+					"migration is saved but not registered")
+			}
+		}
+
+		switch migrationModel.State {
+		case models.StateFailure:
+			result.add(ValidationError, ValidationFailed, migrationModel.Type, version, "migration is in failure state")
+		case models.StateRunning:
+			result.add(ValidationError, ValidationInterrupted, migrationModel.Type, version,
+				"migration execution was interrupted, migration may be partially applied")
+		case models.StateRegistered:
+			result.add(ValidationWarning, ValidationNotExecuted, migrationModel.Type, version, "migration is not executed yet")
+		}
+	}
+
+	return nil
+}
+
+// validateRegisteredVersions находит новые миграции с версией ниже сохраненных, которые Migrate отклонит с
+// ErrRegisteredVersionTooLow или, с WithAllowOutOfOrder, выполнит вне очереди.
+func validateRegisteredVersions(service *ServiceInfo, savedMigrations []models.MigrationModel, result *ValidationResult) error {
+	var maxSavedVersion models.Version
+	for _, migrationModel := range savedMigrations {
+		if migrationModel.Version.MoreThan(maxSavedVersion) {
+			maxSavedVersion = migrationModel.Version
+		}
+	}
+
+	for _, migration := range service.registeredMigrations {
+		if !migrationIsNew(migration, savedMigrations) {
+			continue
+		}
+
+		version, err := models.ParseVersion(migration.Version)
+		if err != nil {
+			return err
+		}
+		if !maxSavedVersion.MoreThan(version) {
+			continue
+		}
+
+		if service.allowOutOfOrder {
+			result.add(ValidationWarning, ValidationVersionTooLow, string(migration.MigrationType), version.String(),
+				fmt.Sprintf("migration is below saved Version %s and will be applied out of order", maxSavedVersion))
+		} else {
+			result.add(ValidationError, ValidationVersionTooLow, string(migration.MigrationType), version.String(),
+				fmt.Sprintf("migration is below saved Version %s and will be rejected by Migrate", maxSavedVersion))
+		}
+	}
+
+	return nil
+}
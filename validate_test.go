@@ -0,0 +1,81 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateFindings проверяет замечания Validate и их критичность: незарегистрированная, измененная и завершившаяся
+// ошибкой миграции и новая миграция с версией ниже сохраненной.
+func TestValidateFindings(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.3"))
+	require.NoError(t, previous.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.2", IsTransactional: true, Up: "alter table a add column c text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "insert into missing_table values (1)"},
+	))
+	require.Error(t, previous.Migrate("service1"))
+
+	m, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.3"))
+
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		// миграция 1.0.1 изменена после выполнения, миграция 1.0.2 не зарегистрирована
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column bb text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1.5", IsTransactional: true, Up: "alter table a add column d text"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.3", IsTransactional: true, Up: "insert into missing_table values (1)"},
+	))
+
+	result, err := m.Validate("service1")
+	require.NoError(t, err)
+	require.Equal(t, "service1", result.Service)
+	require.False(t, result.SafeToMigrate)
+
+	findings := make(map[string]ValidationSeverity, len(result.Findings))
+	for _, finding := range result.Findings {
+		findings[string(finding.Kind)+" "+finding.Version] = finding.Severity
+	}
+	require.Equal(t, map[string]ValidationSeverity{
+		"orphan 1.0.2.0":            ValidationWarning,
+		"failed 1.0.3.0":            ValidationError,
+		"checksum_mismatch 1.0.1.0": ValidationError,
+		"version_too_low 1.0.1.5":   ValidationError,
+	}, findings)
+}
+
+// TestValidateSkipChecksumValidation проверяет, что с WithSkipChecksumValidation измененная миграция - предупреждение.
+func TestValidateSkipChecksumValidation(t *testing.T) {
+	connect, disconnect := newTestDatabase(t)
+
+	previous, err := NewMigrationsManager()
+	require.NoError(t, err)
+	require.NoError(t, previous.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, previous.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column b text"},
+	))
+	require.NoError(t, previous.Migrate("service1"))
+
+	m, err := NewMigrationsManager(WithSkipChecksumValidation())
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterService("service1", connect, disconnect, "1.0.1"))
+	require.NoError(t, m.Register("service1",
+		Migration{MigrationType: TypeBaseline, Version: "1.0.0", IsTransactional: true, Up: "create table a(id int)"},
+		Migration{MigrationType: TypeVersioned, Version: "1.0.1", IsTransactional: true, Up: "alter table a add column bb text"},
+	))
+
+	result, err := m.Validate("service1")
+	require.NoError(t, err)
+	require.True(t, result.SafeToMigrate)
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, ValidationChecksumMismatch, result.Findings[0].Kind)
+	require.Equal(t, ValidationWarning, result.Findings[0].Severity)
+}
@@ -0,0 +1,91 @@
+package db_migrator
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"gorm.io/gorm"
+)
+
+// Version - версия библиотеки. Сохраняется в таблицы migrator_meta и migration_runs и добавляется в записи журнала
+// аудита.
+const Version = "0.1.0.0"
+
+const modulePath = "github.com/Maksumys/db-migrator"
+
+// commit и buildDate задаются при сборке приложения:
+//
+//	go build -ldflags "-X github.com/Maksumys/db-migrator.commit=$(git rev-parse HEAD) \
+//		-X github.com/Maksumys/db-migrator.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	commit    string
+	buildDate string
+)
+
+// BuildInfo описывает сборку библиотеки.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+}
+
+// VersionInfo возвращает версию, коммит и дату сборки библиотеки. Если коммит не задан при сборке, он определяется по
+// информации о сборке приложения: версии модуля библиотеки в зависимостях или ревизии VCS, если библиотека собирается
+// как основной модуль.
+func VersionInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    commit,
+		BuildDate: buildDate,
+	}
+
+	if len(info.Commit) > 0 {
+		return info
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if buildInfo.Main.Path == modulePath {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				if len(info.BuildDate) == 0 {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+		return info
+	}
+
+	for _, dep := range buildInfo.Deps {
+		if dep.Path == modulePath {
+			info.Commit = dep.Version
+			break
+		}
+	}
+
+	return info
+}
+
+// identifyConnection добавляет версию библиотеки к application_name соединения, если драйвер это поддерживает
+// (postgres), чтобы запросы мигратора были видны в pg_stat_activity. Ошибка не прерывает выполнение.
+func (m *MigrationManager) identifyConnection(db *gorm.DB) {
+	if db.Dialector.Name() != "postgres" {
+		return
+	}
+
+	suffix := " db-migrator/" + Version
+	err := db.Exec(
+		"SELECT set_config('application_name', left(current_setting('application_name') || ?, 63), false) "+
+			"WHERE position(? in current_setting('application_name')) = 0",
+		suffix, suffix,
+	).Error
+	if err != nil {
+		m.logger.Debug(fmt.Sprintf("fail to set application_name: %s", err))
+	}
+}
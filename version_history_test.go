@@ -0,0 +1,65 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func versionHistorySteps(entries []VersionHistoryEntry) []string {
+	steps := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		steps = append(steps, entry.Direction+" "+entry.Version.String())
+	}
+	return steps
+}
+
+func TestVersionHistory(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+
+	history, err := m.VersionHistory("service1")
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+	require.NoError(t, m.Migrate("service1"))
+
+	history, err = m.VersionHistory("service1")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"up 1.0.0.0", "up 1.0.1.0", "up 1.0.2.0",
+		"down 1.0.1.0",
+		"up 1.0.2.0",
+	}, versionHistorySteps(history))
+
+	for i := 1; i < len(history); i++ {
+		require.False(t, history[i].ReachedOn.Before(history[i-1].ReachedOn))
+	}
+	require.Less(t, history[0].MigrationRank, history[1].MigrationRank)
+	require.Less(t, history[1].MigrationRank, history[2].MigrationRank)
+	// откат записывается с rank отмененной миграции
+	require.Equal(t, history[2].MigrationRank, history[3].MigrationRank)
+	require.Equal(t, history[2].MigrationRank, history[4].MigrationRank)
+
+	// текущая версия по-прежнему хранится одной строкой таблицы version
+	var versions int64
+	require.NoError(t, connect().Table("version").Count(&versions).Error)
+	require.EqualValues(t, 1, versions)
+
+	t.Run("created lazily", func(t *testing.T) {
+		// таблица удалена, как в базе данных, выполненной версией библиотеки без истории версий
+		require.NoError(t, connect().Migrator().DropTable("version_history"))
+
+		history, err := m.VersionHistory("service1")
+		require.NoError(t, err)
+		require.Empty(t, history)
+
+		require.NoError(t, m.DowngradeTo("service1", "1.0.1"))
+
+		history, err = m.VersionHistory("service1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"down 1.0.1.0"}, versionHistorySteps(history))
+	})
+}
@@ -0,0 +1,30 @@
+package db_migrator
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateRepairsVersionTable(t *testing.T) {
+	var logs bytes.Buffer
+	m, connect := newTestManager(t, "1.0.2", WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	registerRunDirectionMigrations(t, m)
+	require.NoError(t, m.MigrateWithOptions("service1", RunOptions{TargetVersion: "1.0.1"}))
+
+	// строки без id, добавленные при восстановлении из резервной копии
+	db := connect()
+	require.NoError(t, db.Exec("insert into version(version) values (?), (?)", "1.0.0.0", "1.0.1.0").Error)
+
+	_, err := m.Status("service1")
+	require.ErrorIs(t, err, ErrConflictingVersions)
+
+	require.NoError(t, m.Migrate("service1"))
+	require.Contains(t, logs.String(), "version table of service service1 contained 2 extra rows, kept the max version")
+
+	var versions []string
+	require.NoError(t, db.Raw("select version from version").Scan(&versions).Error)
+	require.Equal(t, []string{"1.0.2.0"}, versions)
+}
@@ -0,0 +1,68 @@
+package db_migrator
+
+import (
+	"testing"
+
+	"github.com/Maksumys/db-migrator/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionInfo(t *testing.T) {
+	info := VersionInfo()
+	require.Equal(t, Version, info.Version)
+	_, err := ParseSchemaVersion(info.Version)
+	require.NoError(t, err)
+
+	// значения, заданные при сборке через -ldflags, имеют приоритет над информацией о сборке приложения
+	defer func(previousCommit string, previousBuildDate string) {
+		commit, buildDate = previousCommit, previousBuildDate
+	}(commit, buildDate)
+	commit, buildDate = "0123abc", "2026-10-01T00:00:00Z"
+
+	require.Equal(t, BuildInfo{Version: Version, Commit: "0123abc", BuildDate: "2026-10-01T00:00:00Z"}, VersionInfo())
+}
+
+func TestVersionInfoInDatabase(t *testing.T) {
+	m, connect := newTestManager(t, "1.0.2")
+	registerRunDirectionMigrations(t, m)
+	db := connect()
+
+	// новая база данных: системные таблицы не созданы, все миграции приложения не выполнены
+	require.False(t, repository.HasMetaTable(db))
+
+	savedVersion, err := m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "0.0.0.0", savedVersion.String())
+
+	report, err := m.Compatibility("service1")
+	require.NoError(t, err)
+	require.Equal(t, CompatibilityBinaryAhead, report.Class)
+	require.Len(t, report.Pending, 3)
+	require.Empty(t, report.Unknown)
+
+	require.NoError(t, m.Migrate("service1"))
+
+	storedLibraryVersion, err := repository.GetMeta(db, repository.MetaLibraryVersion)
+	require.NoError(t, err)
+	require.Equal(t, VersionInfo().Version, storedLibraryVersion)
+	schemaVersion, err := repository.GetMeta(db, repository.MetaSchemaVersion)
+	require.NoError(t, err)
+	require.Equal(t, "1", schemaVersion)
+
+	savedVersion, err = m.SavedVersion("service1")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.2.0", savedVersion.String())
+
+	report, err = m.Compatibility("service1")
+	require.NoError(t, err)
+	require.Equal(t, CompatibilityExact, report.Class)
+	require.Empty(t, report.Pending)
+	require.Empty(t, report.Unknown)
+
+	runs, err := m.Runs("service1", 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, Version, runs[0].LibraryVersion)
+	require.Equal(t, report.Fingerprint, runs[0].Fingerprint)
+	require.Equal(t, "1.0.2.0", runs[0].FinalVersion)
+}
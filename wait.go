@@ -0,0 +1,48 @@
+package db_migrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// waitFor периодически вызывает cond с интервалом interval, пока cond не вернет true или ошибку, не истечет timeout
+// или не будет отменен ctx. Значение timeout <= 0 снимает ограничение по времени.
+//
+// При отмене ctx или истечении timeout возвращается ошибка с указанием места ожидания site, для которой выполняется
+// errors.Is(err, context.Canceled) или errors.Is(err, context.DeadlineExceeded).
+func waitFor(ctx context.Context, site string, interval time.Duration, timeout time.Duration, cond func(ctx context.Context) (bool, error)) error {
+	return waitForIntervals(ctx, site, func() time.Duration { return interval }, timeout, cond)
+}
+
+// waitForIntervals работает как waitFor, но перед каждым повторным вызовом cond запрашивает интервал у interval,
+// что позволяет менять интервал между опросами (например, добавлять к нему случайную величину).
+func waitForIntervals(ctx context.Context, site string, interval func() time.Duration, timeout time.Duration, cond func(ctx context.Context) (bool, error)) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("wait for %s interrupted: %w", site, err)
+		}
+
+		done, err := cond(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		timer := time.NewTimer(interval())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("wait for %s interrupted: %w", site, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package db_migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForIntervals(t *testing.T) {
+	calls := 0
+	intervals := make([]time.Duration, 0)
+	next := time.Millisecond
+
+	err := waitForIntervals(context.Background(), "test", func() time.Duration {
+		intervals = append(intervals, next)
+		next *= 2
+		return intervals[len(intervals)-1]
+	}, 0, func(ctx context.Context) (bool, error) {
+		calls++
+		return calls == 4, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, calls)
+	// интервал запрашивается только перед повторными вызовами cond
+	require.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}, intervals)
+}
+
+func TestWaitForConditionError(t *testing.T) {
+	condErr := errors.New("lock table is missing")
+
+	calls := 0
+	err := waitFor(context.Background(), "test", time.Millisecond, time.Minute, func(ctx context.Context) (bool, error) {
+		calls++
+		if calls == 2 {
+			return false, condErr
+		}
+		return false, nil
+	})
+	require.ErrorIs(t, err, condErr)
+	require.Equal(t, 2, calls)
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	calls := 0
+	err := waitFor(context.Background(), "migration lock", time.Millisecond, 20*time.Millisecond, func(ctx context.Context) (bool, error) {
+		calls++
+		// cond получает контекст, ограниченный timeout
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		return false, nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "wait for migration lock interrupted")
+	require.Greater(t, calls, 1)
+}
+
+func TestWaitForCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	err := waitFor(ctx, "migration lock", time.Hour, 0, func(ctx context.Context) (bool, error) {
+		calls++
+		cancel()
+		return false, nil
+	})
+	// отмена прерывает ожидание интервала
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "wait for migration lock interrupted")
+	require.Equal(t, 1, calls)
+
+	// условие не проверяется, если контекст уже отменен
+	err = waitFor(ctx, "migration lock", time.Millisecond, 0, func(ctx context.Context) (bool, error) {
+		t.Fatal("cond must not be called")
+		return true, nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}